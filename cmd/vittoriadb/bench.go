@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/urfave/cli/v2"
+)
+
+// benchReport is the outcome of a benchmark
+type benchReport struct {
+	Collection string `json:"collection"`
+	Vectors    int    `json:"vectors"`
+	Dimensions int    `json:"dimensions"`
+	IndexType  string `json:"index_type"`
+	Metric     string `json:"metric"`
+	Queries    int    `json:"queries"`
+	K          int    `json:"k"`
+	Workers    int    `json:"concurrency"`
+
+	InsertSeconds    float64 `json:"insert_seconds"`
+	InsertsPerSecond float64 `json:"inserts_per_second"`
+	SearchSeconds    float64 `json:"search_seconds"`
+	QPS              float64 `json:"qps"`
+	LatencyP50MS     float64 `json:"latency_p50_ms"`
+	LatencyP95MS     float64 `json:"latency_p95_ms"`
+	LatencyP99MS     float64 `json:"latency_p99_ms"`
+	LatencyMaxMS     float64 `json:"latency_max_ms"`
+
+	// Recall against an exact search of the same data, left out for flat
+	// collections, which are the baseline
+	Recall *float64 `json:"recall,omitempty"`
+}
+
+// benchmark generates synthetic vectors into a collection, then measures
+// insert throughput, search latency and recall against a flat baseline
+func benchmark(c *cli.Context) error {
+	count, dimensions, queryCount, k := c.Int("vectors"), c.Int("dim"), c.Int("queries"), c.Int("k")
+	batchSize, workers := c.Int("batch-size"), c.Int("concurrency")
+	if count <= 0 || dimensions <= 0 || queryCount <= 0 || k <= 0 || batchSize <= 0 || workers <= 0 {
+		return fmt.Errorf("vectors, dim, queries, k, batch-size and concurrency must be positive")
+	}
+	if dimensions > core.MaxDimensions {
+		return fmt.Errorf("dim cannot exceed %d", core.MaxDimensions)
+	}
+	indexType, err := core.ParseIndexType(c.String("index"))
+	if err != nil {
+		return err
+	}
+	metric, err := core.ParseDistanceMetric(c.String("metric"))
+	if err != nil {
+		return err
+	}
+	var config map[string]interface{}
+	for _, flag := range []string{"m", "ef-construction", "ef-search"} {
+		if !c.IsSet(flag) {
+			continue
+		}
+		if indexType != core.IndexTypeHNSW {
+			return fmt.Errorf("--%s only applies to hnsw collections", flag)
+		}
+		if config == nil {
+			config = map[string]interface{}{}
+		}
+		config[strings.ReplaceAll(flag, "-", "_")] = c.Int(flag)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Without --data-dir the benchmark leaves nothing behind
+	dataDir := c.String("data-dir")
+	if dataDir == "" {
+		if dataDir, err = os.MkdirTemp("", "vittoriadb-bench-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dataDir)
+	}
+	db := core.NewDatabase()
+	if err := db.Open(ctx, &core.Config{DataDir: dataDir}); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	name := c.String("collection")
+	if _, err := db.GetCollection(ctx, name); err == nil {
+		return fmt.Errorf("collection '%s' already exists in %s: the benchmark needs a new one", name, dataDir)
+	}
+	err = db.CreateCollection(ctx, &core.CreateCollectionRequest{
+		Name:          name,
+		Dimensions:    dimensions,
+		Metric:        metric,
+		IndexType:     indexType,
+		Config:        config,
+		ExpectedCount: count,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+	collection, err := db.GetCollection(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	rng := rand.New(rand.NewSource(c.Int64("seed")))
+	vectors := make([]*core.Vector, count)
+	for i := range vectors {
+		vectors[i] = &core.Vector{ID: fmt.Sprintf("v%d", i), Vector: randomBenchVector(rng, dimensions)}
+	}
+	// Queries lie near stored vectors, as real queries lie near the documents
+	// they are after
+	queries := make([][]float32, queryCount)
+	for i := range queries {
+		base := vectors[rng.Intn(count)].Vector
+		query := make([]float32, dimensions)
+		for j := range query {
+			query[j] = base[j] + float32(rng.NormFloat64()*0.1)
+		}
+		queries[i] = query
+	}
+
+	report := &benchReport{
+		Collection: name,
+		Vectors:    count,
+		Dimensions: dimensions,
+		IndexType:  indexType.String(),
+		Metric:     metric.String(),
+		Queries:    queryCount,
+		K:          k,
+		Workers:    workers,
+	}
+	progress := c.String("format") != "json"
+
+	start := time.Now()
+	for offset := 0; offset < count; offset += batchSize {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("benchmark interrupted: %w", err)
+		}
+		end := min(offset+batchSize, count)
+		if err := collection.InsertBatch(ctx, vectors[offset:end]); err != nil {
+			return fmt.Errorf("failed to insert vectors %d to %d: %w", offset+1, end, err)
+		}
+		if progress {
+			fmt.Fprintf(os.Stderr, "\rInserted %d/%d vectors", end, count)
+		}
+	}
+	if progress {
+		fmt.Fprintln(os.Stderr)
+	}
+	insertTime := time.Since(start)
+	report.InsertSeconds = insertTime.Seconds()
+	report.InsertsPerSecond = float64(count) / insertTime.Seconds()
+
+	results, latencies, searchTime, err := runBenchQueries(ctx, collection, queries, k, workers)
+	if err != nil {
+		return err
+	}
+	report.SearchSeconds = searchTime.Seconds()
+	report.QPS = float64(queryCount) / searchTime.Seconds()
+	slices.Sort(latencies)
+	report.LatencyP50MS = benchPercentile(latencies, 50)
+	report.LatencyP95MS = benchPercentile(latencies, 95)
+	report.LatencyP99MS = benchPercentile(latencies, 99)
+	report.LatencyMaxMS = benchPercentile(latencies, 100)
+
+	if indexType != core.IndexTypeFlat {
+		if progress {
+			fmt.Fprintln(os.Stderr, "Computing the flat baseline")
+		}
+		recall, err := benchRecall(ctx, db, name, dimensions, metric, vectors, queries, results, k, batchSize)
+		if err != nil {
+			return err
+		}
+		report.Recall = &recall
+	}
+
+	if c.String("format") == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	printBenchReport(report)
+	return nil
+}
+
+// runBenchQueries runs the queries on workers goroutines, returning the IDs
+// each query found, the latency of each in milliseconds and the total time
+func runBenchQueries(ctx context.Context, collection core.Collection, queries [][]float32, k, workers int) ([][]string, []float64, time.Duration, error) {
+	results := make([][]string, len(queries))
+	latencies := make([]float64, len(queries))
+	errs := make([]error, workers)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range next {
+				queryStart := time.Now()
+				response, err := collection.Search(ctx, &core.SearchRequest{Vector: queries[i], Limit: k})
+				latencies[i] = float64(time.Since(queryStart)) / float64(time.Millisecond)
+				if err != nil {
+					if errs[w] == nil {
+						errs[w] = fmt.Errorf("query %d failed: %w", i+1, err)
+					}
+					continue
+				}
+				results[i] = benchIDs(response)
+			}
+		}(w)
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	return results, latencies, elapsed, nil
+}
+
+// benchRecall loads the vectors into a flat collection, which searches
+// exhaustively, and returns the share of its results the benchmarked
+// collection found
+func benchRecall(ctx context.Context, db core.Database, name string, dimensions int, metric core.DistanceMetric, vectors []*core.Vector, queries [][]float32, results [][]string, k, batchSize int) (float64, error) {
+	baselineName := name + "_flat_baseline"
+	err := db.CreateCollection(ctx, &core.CreateCollectionRequest{
+		Name:          baselineName,
+		Dimensions:    dimensions,
+		Metric:        metric,
+		IndexType:     core.IndexTypeFlat,
+		ExpectedCount: len(vectors),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create the flat baseline: %w", err)
+	}
+	defer db.DropCollection(ctx, baselineName)
+	baseline, err := db.GetCollection(ctx, baselineName)
+	if err != nil {
+		return 0, err
+	}
+	for offset := 0; offset < len(vectors); offset += batchSize {
+		if err := baseline.InsertBatch(ctx, vectors[offset:min(offset+batchSize, len(vectors))]); err != nil {
+			return 0, fmt.Errorf("failed to load the flat baseline: %w", err)
+		}
+	}
+
+	found, expected := 0, 0
+	for i, query := range queries {
+		response, err := baseline.Search(ctx, &core.SearchRequest{Vector: query, Limit: k})
+		if err != nil {
+			return 0, fmt.Errorf("baseline query %d failed: %w", i+1, err)
+		}
+		exact := benchIDs(response)
+		expected += len(exact)
+		for _, id := range exact {
+			if slices.Contains(results[i], id) {
+				found++
+			}
+		}
+	}
+	if expected == 0 {
+		return 1, nil
+	}
+	return float64(found) / float64(expected), nil
+}
+
+// printBenchReport prints a benchmark's outcome
+func printBenchReport(report *benchReport) {
+	fmt.Printf("Collection:  %s (%d vectors, %d dimensions, %s, %s)\n", report.Collection, report.Vectors, report.Dimensions, report.IndexType, report.Metric)
+	fmt.Printf("Insert:      %.2fs (%.0f vectors/s)\n", report.InsertSeconds, report.InsertsPerSecond)
+	fmt.Printf("Search:      %d queries in %.2fs (%.0f QPS, k=%d, concurrency %d)\n", report.Queries, report.SearchSeconds, report.QPS, report.K, report.Workers)
+	fmt.Printf("Latency:     p50 %.3fms, p95 %.3fms, p99 %.3fms, max %.3fms\n", report.LatencyP50MS, report.LatencyP95MS, report.LatencyP99MS, report.LatencyMaxMS)
+	if report.Recall != nil {
+		fmt.Printf("Recall@%d:   %.4f (against a flat baseline)\n", report.K, *report.Recall)
+	}
+}
+
+// randomBenchVector returns a vector of uniformly distributed components
+func randomBenchVector(rng *rand.Rand, dimensions int) []float32 {
+	vector := make([]float32, dimensions)
+	for i := range vector {
+		vector[i] = rng.Float32()*2 - 1
+	}
+	return vector
+}
+
+// benchIDs returns the IDs of a search's results
+func benchIDs(response *core.SearchResponse) []string {
+	ids := make([]string, len(response.Results))
+	for i, result := range response.Results {
+		ids[i] = result.ID
+	}
+	return ids
+}
+
+// benchPercentile returns the pth percentile of sorted values, by the
+// nearest-rank method
+func benchPercentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
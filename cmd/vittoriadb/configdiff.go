@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// diffConfig shows what changes when a running server, or a server started
+// with another file, moves to a configuration file
+func diffConfig(c *cli.Context) error {
+	cli := config.NewCLIManager()
+	if against := c.String("against"); against != "" {
+		return cli.CompareConfigs(against, c.String("file"), c.String("format"))
+	}
+	server := strings.TrimSuffix(c.String("server"), "/")
+	if server == "" {
+		return fmt.Errorf("--server or --against is required")
+	}
+
+	// Only admins read the full view; compare the public sections otherwise
+	live, err := fetchServerConfig(server, config.ViewFull, c.String("api-key"))
+	if errors.Is(err, errForbidden) {
+		fmt.Fprintln(os.Stderr, "⚠️  Not an admin: comparing the public sections only")
+		live, err = fetchServerConfig(server, config.ViewPublic, c.String("api-key"))
+	}
+	if err != nil {
+		return err
+	}
+	return cli.CompareWithServer(c.String("file"), server, live, c.String("format"))
+}
+
+// errForbidden reports a server refusing a request to the API key
+var errForbidden = errors.New("forbidden")
+
+// fetchServerConfig returns a view of the configuration of a running server
+func fetchServerConfig(server, view, apiKey string) (map[string]interface{}, error) {
+	req, err := http.NewRequest(http.MethodGet, server+"/config?view="+view, nil)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, errForbidden
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var result struct {
+		Config map[string]interface{} `json:"config"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid server response: %w", err)
+	}
+	return result.Config, nil
+}
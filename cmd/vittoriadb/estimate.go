@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/estimate"
+	"github.com/antonellof/VittoriaDB/pkg/processor"
+	"github.com/urfave/cli/v2"
+)
+
+// estimateIngestion estimates the cost of ingesting documents, locally or on
+// a running server
+func estimateIngestion(c *cli.Context) error {
+	paths, err := documentPaths(append(c.StringSlice("file"), c.Args().Slice()...))
+	if err != nil {
+		return err
+	}
+
+	var manifest []estimate.ManifestEntry
+	if file := c.String("manifest"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read manifest: %w", err)
+		}
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("invalid manifest: expected a JSON array of {name, size, count}: %w", err)
+		}
+	}
+	if len(paths) == 0 && len(manifest) == 0 {
+		return fmt.Errorf("no documents to estimate: pass files, directories or --manifest")
+	}
+
+	var result *estimate.Estimate
+	if server := c.String("server"); server != "" {
+		result, err = estimateOnServer(c, server, paths, manifest)
+	} else {
+		result, err = estimateLocally(c, paths, manifest)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.String("format") == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	printEstimate(result)
+	return nil
+}
+
+// documentPaths expands directories into the supported documents they
+// contain
+func documentPaths(args []string) ([]string, error) {
+	estimator := estimate.NewEstimator(processor.DefaultProcessingConfig())
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && estimator.Supported(path) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// estimateLocally estimates with the target given by flags, over the
+// configuration's defaults
+func estimateLocally(c *cli.Context, paths []string, manifest []estimate.ManifestEntry) (*estimate.Estimate, error) {
+	unifiedConfig, err := loadConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	target := &estimate.Target{
+		Dimensions: c.Int("dimensions"),
+		Vectorizer: unifiedConfig.Embeddings.Default.Vectorizer(),
+	}
+	if indexType, err := core.ParseIndexType(unifiedConfig.Search.Index.DefaultType); err == nil {
+		target.IndexType = indexType
+	}
+	if c.IsSet("index") {
+		indexType, err := core.ParseIndexType(c.String("index"))
+		if err != nil {
+			return nil, err
+		}
+		target.IndexType = indexType
+	}
+	if c.IsSet("vectorizer") {
+		vectorizerType, err := embeddings.ParseVectorizerType(c.String("vectorizer"))
+		if err != nil {
+			return nil, err
+		}
+		target.Vectorizer = &embeddings.VectorizerConfig{Type: vectorizerType}
+	}
+	if c.IsSet("model") {
+		target.Vectorizer.Model = c.String("model")
+	}
+	// Explicit dimensions or a different model override those of the default
+	if target.Dimensions <= 0 && (c.IsSet("vectorizer") || c.IsSet("model")) {
+		target.Vectorizer.Dimensions = 0
+	}
+	if c.IsSet("price") {
+		price := c.Float64("price")
+		target.PricePerMillionTokens = &price
+	}
+
+	processing := processor.DefaultProcessingConfig()
+	if c.IsSet("chunk-size") {
+		processing.ChunkSize = c.Int("chunk-size")
+	}
+	if c.IsSet("chunk-overlap") {
+		processing.ChunkOverlap = c.Int("chunk-overlap")
+	}
+	estimator := estimate.NewEstimator(processing)
+
+	var files []*estimate.FileEstimate
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, estimator.Document(file, path))
+		file.Close()
+	}
+	for _, entry := range manifest {
+		files = append(files, estimator.ManifestEntry(entry))
+	}
+	return estimate.Summarize(files, target)
+}
+
+// estimateOnServer uploads the documents to a server's /estimate endpoint
+func estimateOnServer(c *cli.Context, server string, paths []string, manifest []estimate.ManifestEntry) (*estimate.Estimate, error) {
+	request := map[string]interface{}{"manifest": manifest}
+	if collection := c.String("collection"); collection != "" {
+		request["collection"] = collection
+	}
+	if c.IsSet("dimensions") {
+		request["dimensions"] = c.Int("dimensions")
+	}
+	if c.IsSet("price") {
+		request["price_per_million_tokens"] = c.Float64("price")
+	}
+	if c.IsSet("chunk-size") {
+		request["chunk_size"] = c.Int("chunk-size")
+	}
+	if c.IsSet("chunk-overlap") {
+		request["chunk_overlap"] = c.Int("chunk-overlap")
+	}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("request", string(requestJSON)); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		part, err := form.CreateFormFile("file", filepath.Base(path))
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(part, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(server, "/")+"/estimate", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if key := c.String("api-key"); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var result estimate.Estimate
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid server response: %w", err)
+	}
+	return &result, nil
+}
+
+// printEstimate prints an estimate as tables
+func printEstimate(result *estimate.Estimate) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOCUMENT\tTYPE\tSIZE\tCHUNKS\tTOKENS\t")
+	for _, file := range result.Files {
+		name := file.Name
+		if file.Count > 1 {
+			name = fmt.Sprintf("%s (x%d)", name, file.Count)
+		}
+		if file.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t%s\n", name, file.Type, formatFileSize(file.Size), file.Error)
+			continue
+		}
+		marker := ""
+		if !file.Measured {
+			marker = "~"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s%d\t%s%d\t\n", name, file.Type, formatFileSize(file.Size*int64(file.Count)), marker, file.Chunks, marker, file.Tokens)
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Printf("Documents:         %d\n", result.Documents)
+	fmt.Printf("Chunks:            %d\n", result.Chunks)
+	fmt.Printf("Embedding tokens:  %d\n", result.EmbeddingTokens)
+	model := result.Provider
+	if result.Model != "" {
+		model += " " + result.Model
+	}
+	fmt.Printf("Embedding cost:    $%.4f (%s at $%.3f per 1M tokens)\n", result.EmbeddingCostUSD, model, result.PricePerMillion)
+	fmt.Printf("Vectors:           %d x %d dimensions, %s index\n", result.Chunks, result.Dimensions, result.IndexType)
+	fmt.Printf("Storage:           %s\n", formatFileSize(result.StorageBytes))
+	fmt.Printf("Index memory:      %s\n", formatFileSize(result.IndexMemoryBytes))
+	for _, note := range result.Notes {
+		fmt.Printf("Note: %s\n", note)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/server"
+	"github.com/urfave/cli/v2"
+)
+
+// exportCollection downloads every record of a collection of a running server
+// to a JSON lines or Parquet file
+func exportCollection(c *cli.Context) error {
+	output := c.String("output")
+	format := c.String("format")
+	if !c.IsSet("format") && strings.EqualFold(filepath.Ext(output), ".parquet") {
+		format = core.ExportFormatParquet
+	}
+	if !slices.Contains(core.ExportFormats, format) {
+		return fmt.Errorf("invalid format '%s': expected one of %s", format, strings.Join(core.ExportFormats, ", "))
+	}
+
+	query := url.Values{"format": {format}}
+	if namespace := c.String("namespace"); namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	target := strings.TrimSuffix(c.String("server"), "/") + "/collections/" + url.PathEscape(c.String("collection")) + "/export?" + query.Encode()
+	req, err := http.NewRequestWithContext(c.Context, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	if apiKey := c.String("api-key"); apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return &statusError{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+
+	// Write aside, so an interrupted export never looks complete
+	tmp := output + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	size, err := io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if message := resp.Trailer.Get(server.ExportErrorTrailer); message != "" {
+			err = fmt.Errorf("server failed: %s", message)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	if err := os.Rename(tmp, output); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %s records of %s to %s (%s, %d bytes)\n", resp.Trailer.Get(server.ExportCountTrailer), c.String("collection"), output, format, size)
+	return nil
+}
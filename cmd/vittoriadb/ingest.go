@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/estimate"
+	"github.com/antonellof/VittoriaDB/pkg/objectstore"
+	"github.com/antonellof/VittoriaDB/pkg/processor"
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+)
+
+// ingestBatchSize is how many chunks are sent per text batch request
+const ingestBatchSize = 100
+
+// ingestDebounce is how long a watched file must stay unchanged before it is
+// ingested, so a file being written is ingested once
+const ingestDebounce = 500 * time.Millisecond
+
+// ingester keeps a collection of a server in sync with the documents of a
+// directory. The chunks of a document have IDs derived from its path relative
+// to the directory, so a changed document replaces its chunks and a removed
+// one deletes them. Documents removed while no ingester runs keep their
+// chunks.
+type ingester struct {
+	server     string
+	apiKey     string
+	collection string
+	namespace  string
+	dir        string
+	config     *processor.ProcessingConfig
+	factory    *processor.ProcessorFactory
+	supported  *estimate.Estimator
+	documents  map[string]bool // Documents seen, to find those of removed directories
+}
+
+// ingestDirectory ingests the documents of a directory, then with --watch
+// keeps ingesting them as they change until interrupted
+func ingestDirectory(c *cli.Context) error {
+	if err := core.ValidateNamespace(c.String("namespace")); err != nil {
+		return err
+	}
+
+	processing := processor.DefaultProcessingConfig()
+	if c.IsSet("chunk-size") {
+		processing.ChunkSize = c.Int("chunk-size")
+	}
+	if c.IsSet("chunk-overlap") {
+		processing.ChunkOverlap = c.Int("chunk-overlap")
+	}
+	in := &ingester{
+		server:     strings.TrimSuffix(c.String("server"), "/"),
+		apiKey:     c.String("api-key"),
+		collection: c.String("collection"),
+		namespace:  c.String("namespace"),
+		config:     processing,
+		factory:    processor.NewProcessorFactory(),
+		supported:  estimate.NewEstimator(processing),
+		documents:  make(map[string]bool),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if objectstore.IsURI(c.String("dir")) {
+		if c.Bool("watch") {
+			return fmt.Errorf("--watch needs a local directory")
+		}
+		unifiedConfig, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+		storeConfig := unifiedConfig.ObjectStoreConfig()
+		store, prefix, err := objectstore.Open(c.String("dir"), &storeConfig)
+		if err != nil {
+			return err
+		}
+		in.dir = c.String("dir")
+		return in.ingestObjects(ctx, store, prefix)
+	}
+
+	dir, err := filepath.Abs(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	in.dir = dir
+
+	// Watch before the first walk, so no change in between is missed
+	var watcher *fsnotify.Watcher
+	if c.Bool("watch") {
+		if watcher, err = fsnotify.NewWatcher(); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		defer watcher.Close()
+		if err := watchTree(watcher, dir); err != nil {
+			return err
+		}
+	}
+
+	paths, err := documentPaths([]string{dir})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Ingesting %d documents from %s into %s\n", len(paths), dir, in.collection)
+	failed := 0
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !in.sync(ctx, path) {
+			failed++
+		}
+	}
+	fmt.Printf("Ingested %d documents, %d failed\n", len(paths)-failed, failed)
+
+	if watcher == nil {
+		if failed > 0 {
+			return fmt.Errorf("%d documents failed to be ingested", failed)
+		}
+		return nil
+	}
+	fmt.Printf("Watching %s for changes (Ctrl+C to stop)\n", dir)
+	return in.watch(ctx, watcher)
+}
+
+// ingestObjects ingests the supported documents under a prefix of an object
+// store. Their keys relative to the prefix stand for paths under a directory.
+func (in *ingester) ingestObjects(ctx context.Context, store *objectstore.Store, prefix string) error {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	objects, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, object := range objects {
+		if !strings.HasSuffix(object.Key, "/") && in.supported.Supported(object.Key) {
+			keys = append(keys, object.Key)
+		}
+	}
+
+	fmt.Printf("Ingesting %d documents from %s into %s\n", len(keys), in.dir, in.collection)
+	failed := 0
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			return nil
+		}
+		rel := strings.TrimPrefix(key, prefix)
+		body, err := store.Get(ctx, key)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", rel, err)
+			failed++
+			continue
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", rel, err)
+			failed++
+			continue
+		}
+		if !in.ingest(ctx, rel, data) {
+			failed++
+		}
+	}
+	fmt.Printf("Ingested %d documents, %d failed\n", len(keys)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d documents failed to be ingested", failed)
+	}
+	return nil
+}
+
+// watchTree watches a directory and its subdirectories
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// watch syncs the documents that change under the directory. Paths are synced
+// once they have not changed for ingestDebounce.
+func (in *ingester) watch(ctx context.Context, watcher *fsnotify.Watcher) error {
+	pending := make(map[string]time.Time)
+	ticker := time.NewTicker(ingestDebounce / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// Documents moved in with the directory raise no events
+					if err := watchTree(watcher, event.Name); err != nil {
+						fmt.Printf("Error: %v\n", err)
+					}
+					if paths, err := documentPaths([]string{event.Name}); err == nil {
+						for _, path := range paths {
+							pending[path] = time.Now()
+						}
+					}
+					continue
+				}
+			}
+			pending[event.Name] = time.Now()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Printf("Error watching %s: %v\n", in.dir, err)
+
+		case <-ticker.C:
+			for path, changed := range pending {
+				if time.Since(changed) < ingestDebounce {
+					continue
+				}
+				delete(pending, path)
+				in.sync(ctx, path)
+			}
+		}
+	}
+}
+
+// sync brings the chunks of the document at path in line with the file:
+// ingests it when it is new or changed, and deletes its chunks when it is
+// gone. Failures are reported and false returned.
+func (in *ingester) sync(ctx context.Context, path string) bool {
+	rel, err := filepath.Rel(in.dir, path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		// A removed directory takes its documents along; their chunks are
+		// found by path prefix as the directory is no longer there to walk
+		deleted, err := in.deleteChunks(ctx, rel, 0)
+		if err != nil {
+			fmt.Printf("Error removing %s: %v\n", rel, err)
+			return false
+		}
+		delete(in.documents, rel)
+		if deleted > 0 {
+			fmt.Printf("Removed %s (%d chunks)\n", rel, deleted)
+		}
+		return true
+	}
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", rel, err)
+		return false
+	}
+	if info.IsDir() || !in.supported.Supported(path) {
+		return true
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", rel, err)
+		return false
+	}
+	return in.ingest(ctx, rel, data)
+}
+
+// ingest replaces the chunks of the document at rel with those of data,
+// unless its contents are unchanged. Failures are reported and false
+// returned.
+func (in *ingester) ingest(ctx context.Context, rel string, data []byte) bool {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	in.documents[rel] = true
+
+	// Unchanged documents are not embedded again
+	if first, err := in.getChunk(ctx, chunkID(rel, 0)); err == nil && first != nil && first.Metadata["source_hash"] == hash {
+		return true
+	}
+
+	proc, err := in.factory.GetProcessorByFilename(rel)
+	if err != nil {
+		fmt.Printf("Skipping %s: %v\n", rel, err)
+		return true
+	}
+	doc, err := proc.ProcessDocument(bytes.NewReader(data), path.Base(rel), in.config)
+	if err != nil {
+		fmt.Printf("Error processing %s: %v\n", rel, err)
+		return false
+	}
+
+	texts := make([]*core.TextVector, 0, len(doc.Chunks))
+	for i, chunk := range doc.Chunks {
+		text := &core.TextVector{
+			ID:        chunkID(rel, i),
+			Namespace: in.namespace,
+			Text:      chunk.Content,
+			Metadata: map[string]interface{}{
+				"document_title": doc.Title,
+				"document_type":  string(doc.Type),
+				"chunk_content":  chunk.Content,
+				"chunk_position": chunk.Position,
+				"chunk_size":     chunk.Size,
+				"source_path":    rel,
+				"source_hash":    hash,
+				"source_chunks":  len(doc.Chunks),
+			},
+		}
+		for k, v := range chunk.Metadata {
+			text.Metadata["chunk_"+k] = v
+		}
+		texts = append(texts, text)
+	}
+	for start := 0; start < len(texts); start += ingestBatchSize {
+		end := min(start+ingestBatchSize, len(texts))
+		if err := in.insertTexts(ctx, texts[start:end]); err != nil {
+			fmt.Printf("Error ingesting %s: %v\n", rel, err)
+			return false
+		}
+	}
+
+	// Drop the chunks the previous version had beyond the new ones
+	if _, err := in.deleteChunks(ctx, rel, len(texts)); err != nil {
+		fmt.Printf("Error removing stale chunks of %s: %v\n", rel, err)
+		return false
+	}
+	fmt.Printf("Ingested %s (%d chunks)\n", rel, len(texts))
+	return true
+}
+
+// chunkID returns the ID of a document's chunk. Paths are hashed, as IDs
+// appear in URL paths.
+func chunkID(rel string, position int) string {
+	sum := sha256.Sum256([]byte(rel))
+	return fmt.Sprintf("file_%s_%d", hex.EncodeToString(sum[:8]), position)
+}
+
+// deleteChunks deletes the chunks of a document from position on. Chunks are
+// numbered consecutively, so deletion stops at the first missing one. When
+// rel is a removed directory, the chunks of the documents under it are
+// deleted instead.
+func (in *ingester) deleteChunks(ctx context.Context, rel string, from int) (int, error) {
+	deleted := 0
+	for position := from; ; position++ {
+		found, err := in.deleteChunk(ctx, chunkID(rel, position))
+		if err != nil {
+			return deleted, err
+		}
+		if !found {
+			break
+		}
+		deleted++
+	}
+	if from > 0 || deleted > 0 {
+		return deleted, nil
+	}
+
+	// Nothing was stored under rel itself: delete the documents under it
+	for document := range in.documents {
+		if !strings.HasPrefix(document, rel+"/") {
+			continue
+		}
+		n, err := in.deleteChunks(ctx, document, 0)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+		delete(in.documents, document)
+	}
+	return deleted, nil
+}
+
+// getChunk returns a chunk, or nil when it does not exist
+func (in *ingester) getChunk(ctx context.Context, id string) (*core.Vector, error) {
+	var vector core.Vector
+	err := in.request(ctx, http.MethodGet, "/vectors/"+url.PathEscape(id), nil, &vector)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &vector, nil
+}
+
+// deleteChunk deletes a chunk, reporting whether it existed
+func (in *ingester) deleteChunk(ctx context.Context, id string) (bool, error) {
+	err := in.request(ctx, http.MethodDelete, "/vectors/"+url.PathEscape(id), nil, nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// insertTexts inserts chunks, which the collection's vectorizer embeds
+func (in *ingester) insertTexts(ctx context.Context, texts []*core.TextVector) error {
+	return in.request(ctx, http.MethodPost, "/text/batch", map[string]interface{}{"texts": texts}, nil)
+}
+
+// statusError is a server's answer to a failed request
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned status %d: %s", e.status, e.body)
+}
+
+// isNotFound reports whether err is a server's 404
+func isNotFound(err error) bool {
+	status, ok := err.(*statusError)
+	return ok && status.status == http.StatusNotFound
+}
+
+// request sends a request about the collection to the server and decodes the
+// JSON answer into out, when given
+func (in *ingester) request(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, in.server+"/collections/"+url.PathEscape(in.collection)+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if in.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+in.apiKey)
+	}
+	if in.namespace != "" {
+		query := req.URL.Query()
+		query.Set("namespace", in.namespace)
+		req.URL.RawQuery = query.Encode()
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &statusError{status: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("invalid server response: %w", err)
+		}
+	}
+	return nil
+}
@@ -7,11 +7,15 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
 	"github.com/antonellof/VittoriaDB/pkg/config"
 	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/objectstore"
 	"github.com/antonellof/VittoriaDB/pkg/server"
 	"github.com/urfave/cli/v2"
 )
@@ -93,6 +97,37 @@ func main() {
 						},
 						Action: showConfig,
 					},
+					{
+						Name:  "diff",
+						Usage: "Show the settings of a configuration file that differ from a running server or another file",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Usage:    "Configuration file to compare",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "server",
+								Usage: "Compare with the configuration of a running server (e.g. http://localhost:8080)",
+							},
+							&cli.StringFlag{
+								Name:  "against",
+								Usage: "Compare with another configuration file instead of a server",
+							},
+							&cli.StringFlag{
+								Name:    "api-key",
+								Usage:   "With --server, the API key to authenticate with",
+								EnvVars: []string{"VITTORIA_API_KEY"},
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Value: "table",
+								Usage: "Output format (table, json)",
+							},
+						},
+						Action: diffConfig,
+					},
 					{
 						Name:  "env",
 						Usage: "Show environment variable configuration",
@@ -161,6 +196,35 @@ func main() {
 						Value: true,
 						Usage: "Enable CORS headers",
 					},
+					&cli.BoolFlag{
+						Name:    "read-only",
+						Usage:   "Serve a data directory another process writes, rejecting writes",
+						EnvVars: []string{"VITTORIADB_READ_ONLY"},
+					},
+					&cli.StringFlag{
+						Name:    "cluster-node-id",
+						Usage:   "Enable clustering with this node ID",
+						EnvVars: []string{"VITTORIADB_CLUSTER_NODE_ID"},
+					},
+					&cli.StringFlag{
+						Name:    "cluster-advertise",
+						Usage:   "URL peers and clients use to reach this node (e.g. http://10.0.0.1:8080)",
+						EnvVars: []string{"VITTORIADB_CLUSTER_ADVERTISE"},
+					},
+					&cli.StringFlag{
+						Name:    "cluster-peers",
+						Usage:   "Other cluster members as comma-separated id=url pairs",
+						EnvVars: []string{"VITTORIADB_CLUSTER_PEERS"},
+					},
+					&cli.StringFlag{
+						Name:    "cluster-secret",
+						Usage:   "Secret shared by the cluster members to authenticate Raft RPCs",
+						EnvVars: []string{"VITTORIADB_CLUSTER_SECRET"},
+					},
+					&cli.BoolFlag{
+						Name:  "selftest",
+						Usage: "Check the data directory, vector kernels, clock and embedder, print a PASS/FAIL report and exit",
+					},
 				},
 				Action: runServer,
 			},
@@ -182,7 +246,7 @@ func main() {
 					&cli.StringFlag{
 						Name:  "metric",
 						Value: "cosine",
-						Usage: "Distance metric (cosine, euclidean, dot_product, manhattan)",
+						Usage: "Distance metric (cosine, euclidean, dot_product, manhattan, hamming, jaccard)",
 					},
 					&cli.StringFlag{
 						Name:  "index",
@@ -220,12 +284,380 @@ func main() {
 					},
 					&cli.StringFlag{
 						Name:     "output",
-						Usage:    "Output backup file",
+						Usage:    "Output backup file, or an s3:// or gs:// URI",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Configuration file with the object storage credentials",
+					},
 				},
 				Action: backupDatabase,
 			},
+			{
+				Name:      "estimate",
+				Usage:     "Estimate chunks, embedding cost, storage and index memory of ingesting documents",
+				ArgsUsage: "[files or directories...]",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "file",
+						Aliases: []string{"f"},
+						Usage:   "Document or directory of documents to estimate",
+					},
+					&cli.StringFlag{
+						Name:  "manifest",
+						Usage: "JSON file listing documents as [{\"name\": \"a.pdf\", \"size\": 1048576, \"count\": 100}]",
+					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Configuration file whose embeddings and index defaults are used",
+					},
+					&cli.StringFlag{
+						Name:  "vectorizer",
+						Usage: "Vectorizer type (sentence_transformers, openai, huggingface, ollama, none)",
+					},
+					&cli.StringFlag{
+						Name:  "model",
+						Usage: "Embedding model",
+					},
+					&cli.IntFlag{
+						Name:    "dimensions",
+						Aliases: []string{"dim"},
+						Usage:   "Vector dimensions (default: those of the model)",
+					},
+					&cli.StringFlag{
+						Name:  "index",
+						Usage: "Index type (flat, hnsw)",
+					},
+					&cli.Float64Flag{
+						Name:  "price",
+						Usage: "Embedding price in USD per million tokens (default: the model's list price)",
+					},
+					&cli.IntFlag{
+						Name:  "chunk-size",
+						Usage: "Chunk size in characters",
+					},
+					&cli.IntFlag{
+						Name:  "chunk-overlap",
+						Usage: "Chunk overlap in characters",
+					},
+					&cli.StringFlag{
+						Name:  "server",
+						Usage: "Estimate on a running server (e.g. http://localhost:8080) instead of locally",
+					},
+					&cli.StringFlag{
+						Name:  "collection",
+						Usage: "With --server, estimate for this collection's dimensions, index and vectorizer",
+					},
+					&cli.StringFlag{
+						Name:    "api-key",
+						Usage:   "With --server, the API key to authenticate with",
+						EnvVars: []string{"VITTORIA_API_KEY"},
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "table",
+						Usage: "Output format (table, json)",
+					},
+				},
+				Action: estimateIngestion,
+			},
+			{
+				Name:  "ingest",
+				Usage: "Ingest the documents of a directory into a collection of a running server, optionally keeping it in sync",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "dir",
+						Aliases:  []string{"d"},
+						Usage:    "Directory of documents to ingest, or an s3:// or gs:// prefix",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "collection",
+						Usage:    "Collection to ingest into; it needs a vectorizer, or text auto-creation enabled",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "watch",
+						Usage: "Keep watching the directory and ingest or remove documents as they change",
+					},
+					&cli.StringFlag{
+						Name:  "server",
+						Value: "http://localhost:8080",
+						Usage: "Server to ingest into",
+					},
+					&cli.StringFlag{
+						Name:    "api-key",
+						Usage:   "The API key to authenticate with",
+						EnvVars: []string{"VITTORIA_API_KEY"},
+					},
+					&cli.StringFlag{
+						Name:  "namespace",
+						Usage: "Namespace to ingest into",
+					},
+					&cli.IntFlag{
+						Name:  "chunk-size",
+						Usage: "Chunk size in characters",
+					},
+					&cli.IntFlag{
+						Name:  "chunk-overlap",
+						Usage: "Chunk overlap in characters",
+					},
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Usage:   "Configuration file with the object storage credentials",
+					},
+				},
+				Action: ingestDirectory,
+			},
+			{
+				Name:  "export",
+				Usage: "Export every vector and its metadata from a collection of a running server to a file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "collection",
+						Usage:    "Collection to export",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "jsonl",
+						Usage: "Output format: jsonl or parquet (default: parquet for a .parquet output)",
+					},
+					&cli.StringFlag{
+						Name:     "output",
+						Aliases:  []string{"o"},
+						Usage:    "Output file",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "server",
+						Value: "http://localhost:8080",
+						Usage: "Server to export from",
+					},
+					&cli.StringFlag{
+						Name:    "api-key",
+						Usage:   "The API key to authenticate with",
+						EnvVars: []string{"VITTORIA_API_KEY"},
+					},
+					&cli.StringFlag{
+						Name:  "namespace",
+						Usage: "Export only this namespace (default: all of them)",
+					},
+				},
+				Action: exportCollection,
+			},
+			{
+				Name:  "compact",
+				Usage: "Compact collections of the data directory, purging deleted index entries and rewriting their files",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "data-dir",
+						Value: "./data",
+						Usage: "Data directory path",
+					},
+					&cli.StringSliceFlag{
+						Name:  "collection",
+						Usage: "Collection to compact (repeatable; default: all)",
+					},
+				},
+				Action: compactCollections,
+			},
+			{
+				Name:  "verify",
+				Usage: "Check the metadata, vector and index files of the data directory's collections",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "data-dir",
+						Value: "./data",
+						Usage: "Data directory path",
+					},
+					&cli.StringSliceFlag{
+						Name:  "collection",
+						Usage: "Collection to verify (repeatable; default: all)",
+					},
+					&cli.BoolFlag{
+						Name:  "repair",
+						Usage: "Repair the indexes of collections whose issues are all repairable",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "table",
+						Usage: "Report format: table or json",
+					},
+				},
+				Action: verifyDataDir,
+			},
+			{
+				Name:  "bench",
+				Usage: "Benchmark insert throughput, search latency and recall on synthetic vectors",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "collection",
+						Value: "bench",
+						Usage: "Collection to create for the benchmark",
+					},
+					&cli.IntFlag{
+						Name:  "vectors",
+						Value: 100000,
+						Usage: "Vectors to insert",
+					},
+					&cli.IntFlag{
+						Name:    "dim",
+						Aliases: []string{"dimensions"},
+						Value:   384,
+						Usage:   "Vector dimensions",
+					},
+					&cli.IntFlag{
+						Name:  "queries",
+						Value: 1000,
+						Usage: "Searches to run",
+					},
+					&cli.IntFlag{
+						Name:  "k",
+						Value: 10,
+						Usage: "Results per search, over which recall is measured",
+					},
+					&cli.StringFlag{
+						Name:  "index",
+						Value: "hnsw",
+						Usage: "Index type (flat, hnsw)",
+					},
+					&cli.StringFlag{
+						Name:  "metric",
+						Value: "cosine",
+						Usage: "Distance metric (cosine, euclidean, dot_product, manhattan)",
+					},
+					&cli.IntFlag{
+						Name:  "m",
+						Usage: "HNSW: connections per node",
+					},
+					&cli.IntFlag{
+						Name:  "ef-construction",
+						Usage: "HNSW: candidate list size while building",
+					},
+					&cli.IntFlag{
+						Name:  "ef-search",
+						Usage: "HNSW: candidate list size while searching",
+					},
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Value: 1000,
+						Usage: "Vectors inserted per batch",
+					},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Value: 1,
+						Usage: "Searches run in parallel",
+					},
+					&cli.Int64Flag{
+						Name:  "seed",
+						Value: 42,
+						Usage: "Seed of the synthetic data",
+					},
+					&cli.StringFlag{
+						Name:  "data-dir",
+						Usage: "Data directory to keep the collection in (default: a temporary one, removed afterwards)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "table",
+						Usage: "Report format: table or json",
+					},
+				},
+				Action: benchmark,
+			},
+			{
+				Name:  "shell",
+				Usage: "Open an interactive prompt to list collections, search and inspect vectors of a server or data directory",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "server",
+						Value: "http://localhost:8080",
+						Usage: "Server to connect to",
+					},
+					&cli.StringFlag{
+						Name:    "api-key",
+						Usage:   "The API key to authenticate with",
+						EnvVars: []string{"VITTORIA_API_KEY"},
+					},
+					&cli.StringFlag{
+						Name:  "data-dir",
+						Usage: "Open this data directory read-only instead of connecting to a server",
+					},
+					&cli.StringFlag{
+						Name:  "collection",
+						Usage: "Collection to use from the start",
+					},
+				},
+				Action: runShell,
+			},
+			{
+				Name:  "migrate",
+				Usage: "Load a Qdrant, Chroma or Pinecone dump into a collection of the data directory",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "Source format: qdrant (scroll API points), chroma (Parquet store) or pinecone (JSON lines)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "input",
+						Aliases:  []string{"i"},
+						Usage:    "Dump to load: a file, or for chroma the store directory",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "collection",
+						Usage:    "Collection to load into; created with the dimensions of the first record if missing",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "data-dir",
+						Value: "./data",
+						Usage: "Data directory path",
+					},
+					&cli.StringFlag{
+						Name:  "metric",
+						Value: "cosine",
+						Usage: "Distance metric of a created collection (cosine, euclidean, dot_product, manhattan, hamming, jaccard)",
+					},
+					&cli.StringFlag{
+						Name:  "index",
+						Value: "flat",
+						Usage: "Index type of a created collection (flat, hnsw)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "map",
+						Usage: "Rename a metadata field as source=target; an empty target drops it (repeatable)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "fields",
+						Usage: "Metadata fields to keep, comma separated (default: all)",
+					},
+					&cli.StringFlag{
+						Name:  "namespace-field",
+						Usage: "Metadata field holding the namespace of each record",
+					},
+					&cli.StringFlag{
+						Name:  "vector-name",
+						Usage: "Qdrant: named vector to import when points have several",
+					},
+					&cli.StringFlag{
+						Name:  "source-collection",
+						Usage: "Chroma: collection of the store to import (default: all)",
+					},
+					&cli.IntFlag{
+						Name:  "batch-size",
+						Value: 1000,
+						Usage: "Records inserted per batch",
+					},
+				},
+				Action: migrateCollection,
+			},
 		},
 	}
 
@@ -234,66 +666,218 @@ func main() {
 	}
 }
 
-func runServer(c *cli.Context) error {
-	// Load unified configuration
-	var unifiedConfig *config.VittoriaConfig
-	var err error
-
+// loadServerConfig loads the configuration of the run command: the file
+// given with --config, or the defaults, environment and flags
+func loadServerConfig(c *cli.Context) (*config.VittoriaConfig, error) {
 	configFile := c.String("config")
 	if configFile != "" {
 		// Load from specified config file
-		unifiedConfig, err = config.LoadConfigFromFile(configFile)
+		unifiedConfig, err := config.LoadConfigFromFile(configFile)
 		if err != nil {
-			return fmt.Errorf("failed to load config file: %w", err)
-		}
-	} else {
-		// Load from defaults and environment variables
-		flags := make(map[string]string)
-		if c.IsSet("host") {
-			flags["host"] = c.String("host")
-		}
-		if c.IsSet("port") {
-			flags["port"] = fmt.Sprintf("%d", c.Int("port"))
-		}
-		if c.IsSet("data-dir") {
-			flags["data-dir"] = c.String("data-dir")
+			return nil, fmt.Errorf("failed to load config file: %w", err)
 		}
+		return unifiedConfig, nil
+	}
 
-		unifiedConfig, err = config.LoadConfigWithOverrides("", "VITTORIA_", flags)
-		if err != nil {
-			return fmt.Errorf("failed to load configuration: %w", err)
+	// Load from defaults and environment variables
+	flags := make(map[string]string)
+	if c.IsSet("host") {
+		flags["host"] = c.String("host")
+	}
+	if c.IsSet("port") {
+		flags["port"] = fmt.Sprintf("%d", c.Int("port"))
+	}
+	if c.IsSet("data-dir") {
+		flags["data-dir"] = c.String("data-dir")
+	}
+	if c.IsSet("read-only") {
+		flags["read-only"] = fmt.Sprintf("%t", c.Bool("read-only"))
+	}
+	for _, name := range []string{"cluster-node-id", "cluster-advertise", "cluster-peers", "cluster-secret"} {
+		if c.IsSet(name) {
+			flags[name] = c.String(name)
 		}
 	}
 
-	// Create migration adapter to convert to legacy format
-	migrator := config.NewConfigMigrator()
-	legacyBundle := migrator.MigrateFromUnified(unifiedConfig)
+	unifiedConfig, err := config.LoadConfigWithOverrides("", "VITTORIA_", flags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return unifiedConfig, nil
+}
 
-	// Use the legacy core config for now (until we fully migrate the core package)
-	coreConfig := legacyBundle.Core
+func runServer(c *cli.Context) error {
+	// Load unified configuration, again on SIGHUP
+	unifiedConfig, err := loadServerConfig(c)
+	if err != nil {
+		return err
+	}
+
+	// Apply GC tuning before the vector heap is loaded
+	runtimeSettings := config.ApplyRuntimeSettings(&unifiedConfig.Performance)
+
+	ctx := context.Background()
+
+	// Check the machine before serving traffic: the short checks run at every
+	// startup, and --selftest adds the embedder probe and exits with the report
+	selfTest := newSelfTest(unifiedConfig.DataDir, unifiedConfig.ReadOnly, unifiedConfig.Embeddings.Default.Vectorizer())
+	if c.Bool("selftest") {
+		checks := selfTest.run(ctx, true)
+		fmt.Printf("VittoriaDB %s self-test\n", Version)
+		printSelfTest(checks, func(format string, args ...interface{}) {
+			fmt.Printf("  "+format+"\n", args...)
+		})
+		if selfTestFailed(checks) {
+			return cli.Exit("", 1)
+		}
+		return nil
+	}
+	checks := selfTest.run(ctx, false)
+	printSelfTest(checks, log.Printf)
+	if selfTestFailed(checks) {
+		return fmt.Errorf("startup self-test failed; see the report above")
+	}
 
 	// Create and open database
 	db := core.NewDatabase()
-	ctx := context.Background()
 
-	if err := db.Open(ctx, coreConfig); err != nil {
+	if err := db.Open(ctx, core.ConfigFromUnified(unifiedConfig)); err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
 	// Create server configuration
 	serverConfig := &server.ServerConfig{
-		Host:         coreConfig.Server.Host,
-		Port:         coreConfig.Server.Port,
-		ReadTimeout:  coreConfig.Server.ReadTimeout,
-		WriteTimeout: coreConfig.Server.WriteTimeout,
-		MaxBodySize:  coreConfig.Server.MaxBodySize,
-		CORS:         coreConfig.Server.CORS,
+		Host:         unifiedConfig.Server.Host,
+		Port:         unifiedConfig.Server.Port,
+		ReadTimeout:  unifiedConfig.Server.ReadTimeout,
+		WriteTimeout: unifiedConfig.Server.WriteTimeout,
+		MaxBodySize:  unifiedConfig.Server.MaxBodySize,
+		CORS:         unifiedConfig.Server.CORS,
+		IdleTimeout:  unifiedConfig.Server.IdleTimeout,
+		KeepAlive:    unifiedConfig.Server.KeepAlive,
+		HTTP2:        unifiedConfig.Server.HTTP2,
 	}
 
 	// Create and start server
 	srv := server.NewServer(db, serverConfig, unifiedConfig)
 
+	// Require API keys, enforcing per-key collection and operation permissions
+	var usage *auth.UsageLedger
+	if unifiedConfig.Auth.Enabled {
+		keysFile := unifiedConfig.Auth.KeysFile
+		if keysFile == "" {
+			keysFile = filepath.Join(unifiedConfig.DataDir, "auth_keys.json")
+		}
+		store := auth.NewStore(keysFile)
+		for _, key := range unifiedConfig.Auth.Keys {
+			permissions := make([]auth.Permission, 0, len(key.Permissions))
+			for _, name := range key.Permissions {
+				p, err := auth.ParsePermission(name)
+				if err != nil {
+					return fmt.Errorf("invalid auth key '%s': %w", key.Name, err)
+				}
+				permissions = append(permissions, p)
+			}
+			if err := store.AddConfigKey(key.Name, key.Key, permissions, key.Collections); err != nil {
+				return fmt.Errorf("invalid auth key '%s': %w", key.Name, err)
+			}
+		}
+		if err := store.Load(); err != nil {
+			return fmt.Errorf("failed to load API keys: %w", err)
+		}
+		srv.SetAuth(store)
+
+		// Account each key's requests, inserts and embedding tokens
+		usageFile := unifiedConfig.Auth.UsageFile
+		if usageFile == "" {
+			usageFile = filepath.Join(unifiedConfig.DataDir, "auth_usage.json")
+		}
+		if unifiedConfig.ReadOnly {
+			// The writer owns the ledger file; a reader counts in memory
+			usageFile = ""
+		}
+		usage = auth.NewUsageLedger(usageFile, time.Minute)
+		if err := usage.Load(); err != nil {
+			return fmt.Errorf("failed to load API key usage: %w", err)
+		}
+		srv.SetUsage(usage)
+	}
+
+	// Give slow ingestion and streaming routes their own timeouts
+	if err := srv.SetRouteTimeouts(unifiedConfig.Server.RouteTimeouts); err != nil {
+		return fmt.Errorf("invalid route timeouts: %w", err)
+	}
+
+	// Throttle clients that exceed their request rate
+	if unifiedConfig.Server.RateLimit.Enabled {
+		srv.SetRateLimit(unifiedConfig.Server.RateLimit)
+	}
+
+	// Keep searches ahead of bulk work when every request slot is busy
+	if unifiedConfig.Server.Concurrency.Enabled {
+		srv.SetConcurrency(unifiedConfig.Server.Concurrency, unifiedConfig.Performance.MaxConcurrency)
+	}
+
+	// Let searches opt into a second, reranking stage
+	if unifiedConfig.Search.Rerank.Enabled {
+		if err := srv.SetRerank(unifiedConfig.Search.Rerank); err != nil {
+			return fmt.Errorf("failed to configure reranking: %w", err)
+		}
+	}
+
+	// Serve collections of an upstream VittoriaDB as a read-through cache
+	if unifiedConfig.Edge.Enabled {
+		srv.SetEdge(unifiedConfig.Edge)
+	}
+
+	// Join the cluster, replicating writes through Raft
+	var node *cluster.Node
+	if unifiedConfig.Cluster.Enabled {
+		clusterConfig := cluster.DefaultConfig()
+		clusterConfig.NodeID = unifiedConfig.Cluster.NodeID
+		clusterConfig.Address = unifiedConfig.Cluster.Advertise
+		clusterConfig.DataDir = unifiedConfig.Cluster.DataDir
+		if clusterConfig.DataDir == "" {
+			clusterConfig.DataDir = filepath.Join(unifiedConfig.DataDir, ".raft")
+		}
+		clusterConfig.ElectionTimeout = unifiedConfig.Cluster.ElectionTimeout
+		clusterConfig.HeartbeatInterval = unifiedConfig.Cluster.HeartbeatInterval
+		clusterConfig.Secret = unifiedConfig.Cluster.Secret
+		for id, addr := range unifiedConfig.Cluster.Peers {
+			clusterConfig.Peers[id] = addr
+		}
+
+		node, err = cluster.NewNode(clusterConfig, cluster.NewDatabaseFSM(db))
+		if err != nil {
+			return fmt.Errorf("failed to start cluster node: %w", err)
+		}
+		node.Start()
+		srv.SetCluster(node)
+	}
+
+	// Apply the reloadable settings of the configuration again on SIGHUP
+	go func() {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		for range hupChan {
+			reloaded, err := loadServerConfig(c)
+			if err != nil {
+				log.Printf("Config reload failed: %v", err)
+				continue
+			}
+			result, err := srv.ReloadConfig(ctx, reloaded)
+			if err != nil {
+				log.Printf("Config reload failed: %v", err)
+				continue
+			}
+			log.Printf("Config reloaded: %d settings applied %v", len(result.Applied), result.Applied)
+			if len(result.RestartRequired) > 0 {
+				log.Printf("Config changes that take effect at the next restart: %v", result.RestartRequired)
+			}
+		}
+	}()
+
 	// Handle graceful shutdown
 	go func() {
 		sigChan := make(chan os.Signal, 1)
@@ -311,6 +895,20 @@ func runServer(c *cli.Context) error {
 			log.Printf("Server shutdown error: %v", err)
 		}
 
+		// Leave the cluster
+		if node != nil {
+			if err := node.Stop(); err != nil {
+				log.Printf("Cluster shutdown error: %v", err)
+			}
+		}
+
+		// Save the usage counted since the last flush
+		if usage != nil {
+			if err := usage.Close(); err != nil {
+				log.Printf("Usage save error: %v", err)
+			}
+		}
+
 		// Close database
 		if err := db.Close(); err != nil {
 			log.Printf("Database close error: %v", err)
@@ -320,23 +918,37 @@ func runServer(c *cli.Context) error {
 	}()
 
 	// Get absolute path for data directory
-	absDataDir, err := filepath.Abs(coreConfig.DataDir)
+	absDataDir, err := filepath.Abs(unifiedConfig.DataDir)
 	if err != nil {
-		absDataDir = coreConfig.DataDir
+		absDataDir = unifiedConfig.DataDir
 	}
 
 	// Enhanced startup information
 	log.Printf("🚀 VittoriaDB %s starting...", Version)
 	log.Printf("📁 Data directory: %s", absDataDir)
-	log.Printf("🌐 HTTP server: http://%s:%d", coreConfig.Server.Host, coreConfig.Server.Port)
-	log.Printf("📊 Web dashboard: http://%s:%d/", coreConfig.Server.Host, coreConfig.Server.Port)
+	log.Printf("🌐 HTTP server: http://%s:%d", unifiedConfig.Server.Host, unifiedConfig.Server.Port)
+	log.Printf("📊 Web dashboard: http://%s:%d/", unifiedConfig.Server.Host, unifiedConfig.Server.Port)
 	log.Printf("⚙️  Configuration:")
 	log.Printf("   • Config source: %s", unifiedConfig.Source)
-	log.Printf("   • Index type: %s", coreConfig.Index.DefaultType)
-	log.Printf("   • Distance metric: %s", coreConfig.Index.DefaultMetric)
-	log.Printf("   • Page size: %d bytes", coreConfig.Storage.PageSize)
-	log.Printf("   • Cache size: %d pages", coreConfig.Storage.CacheSize)
-	log.Printf("   • CORS enabled: %t", coreConfig.Server.CORS)
+	if unifiedConfig.ReadOnly {
+		log.Printf("   • Read-only: serving the data as of startup, writes are rejected")
+	}
+	log.Printf("   • Index type: %s", unifiedConfig.Search.Index.DefaultType)
+	log.Printf("   • Distance metric: %s", unifiedConfig.Search.Index.DefaultMetric)
+	log.Printf("   • Page size: %d bytes", unifiedConfig.Storage.PageSize)
+	log.Printf("   • Cache size: %d pages", unifiedConfig.Storage.CacheSize)
+	log.Printf("   • CORS enabled: %t", unifiedConfig.Server.CORS)
+	log.Printf("   • GC percent: %d, memory limit: %d bytes", runtimeSettings.GCPercent, runtimeSettings.MemoryLimit)
+	if node != nil {
+		log.Printf("   • Cluster: node %s with %d peers", unifiedConfig.Cluster.NodeID, len(unifiedConfig.Cluster.Peers))
+	}
+	if edge := unifiedConfig.Edge; edge.Enabled {
+		log.Printf("   • Edge: caching %s from %s (TTL %s, sync every %s)", strings.Join(edge.Collections, ", "), edge.Upstream, edge.TTL, edge.SyncInterval)
+	}
+	log.Printf("   • API key auth: %t", unifiedConfig.Auth.Enabled)
+	if limit := unifiedConfig.Server.RateLimit; limit.Enabled {
+		log.Printf("   • Rate limit: %d req/s per key, %d req/s per IP", limit.PerKey.RequestsPerSecond, limit.PerIP.RequestsPerSecond)
+	}
 	log.Printf("   • Parallel search: %t (workers: %d)", unifiedConfig.Search.Parallel.Enabled, unifiedConfig.Search.Parallel.MaxWorkers)
 	log.Printf("   • Search cache: %t (entries: %d)", unifiedConfig.Search.Cache.Enabled, unifiedConfig.Search.Cache.MaxEntries)
 	log.Printf("   • Memory-mapped I/O: %t", unifiedConfig.Performance.IO.UseMemoryMap)
@@ -351,30 +963,13 @@ func runServer(c *cli.Context) error {
 }
 
 func createCollection(c *cli.Context) error {
-	// Parse metric
-	var metric core.DistanceMetric
-	switch c.String("metric") {
-	case "cosine":
-		metric = core.DistanceMetricCosine
-	case "euclidean":
-		metric = core.DistanceMetricEuclidean
-	case "dot_product":
-		metric = core.DistanceMetricDotProduct
-	case "manhattan":
-		metric = core.DistanceMetricManhattan
-	default:
-		return fmt.Errorf("invalid metric: %s", c.String("metric"))
-	}
-
-	// Parse index type
-	var indexType core.IndexType
-	switch c.String("index") {
-	case "flat":
-		indexType = core.IndexTypeFlat
-	case "hnsw":
-		indexType = core.IndexTypeHNSW
-	default:
-		return fmt.Errorf("invalid index type: %s", c.String("index"))
+	metric, err := core.ParseDistanceMetric(c.String("metric"))
+	if err != nil {
+		return err
+	}
+	indexType, err := core.ParseIndexType(c.String("index"))
+	if err != nil {
+		return err
 	}
 
 	// Create database configuration
@@ -413,8 +1008,10 @@ func createCollection(c *cli.Context) error {
 
 func showStats(c *cli.Context) error {
 	// Create database configuration
+	// Read-only, so that stats can be shown while a server runs
 	config := &core.Config{
-		DataDir: c.String("data-dir"),
+		DataDir:  c.String("data-dir"),
+		ReadOnly: true,
 	}
 
 	// Create and open database
@@ -456,9 +1053,92 @@ func showStats(c *cli.Context) error {
 	return nil
 }
 
+// backupDatabase writes a backup archive of every collection to a file, or
+// uploads it to an object store
 func backupDatabase(c *cli.Context) error {
-	// TODO: Implement backup functionality
-	return fmt.Errorf("backup functionality not implemented yet")
+	output := c.String("output")
+	var store *objectstore.Store
+	var key string
+	if objectstore.IsURI(output) {
+		unifiedConfig, err := loadConfig(c)
+		if err != nil {
+			return err
+		}
+		storeConfig := unifiedConfig.ObjectStoreConfig()
+		if store, key, err = objectstore.Open(output, &storeConfig); err != nil {
+			return err
+		}
+		if key == "" || strings.HasSuffix(key, "/") {
+			return fmt.Errorf("%s does not name an object", output)
+		}
+	}
+
+	db := core.NewDatabase()
+	ctx := context.Background()
+	// Read-only, so that a running server can be backed up
+	if err := db.Open(ctx, &core.Config{DataDir: c.String("data-dir"), ReadOnly: true}); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	collections, err := db.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Uploads are staged locally, as they need the archive's size up front;
+	// files are written aside so a failed backup never looks complete
+	archive := output + ".tmp"
+	if store != nil {
+		file, err := os.CreateTemp("", "vittoriadb-backup-*.tar.gz")
+		if err != nil {
+			return err
+		}
+		file.Close()
+		archive = file.Name()
+	}
+	defer os.Remove(archive)
+
+	file, err := os.Create(archive)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	err = db.Backup(ctx, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	info, err := os.Stat(archive)
+	if err != nil {
+		return err
+	}
+
+	if store != nil {
+		if err := store.PutFile(ctx, key, archive); err != nil {
+			return fmt.Errorf("failed to upload backup: %w", err)
+		}
+	} else if err := os.Rename(archive, output); err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %d collections to %s (%d bytes)\n", len(collections), output, info.Size())
+	return nil
+}
+
+// loadConfig loads the configuration file given by the --config flag, else
+// the defaults and the environment
+func loadConfig(c *cli.Context) (*config.VittoriaConfig, error) {
+	var unifiedConfig *config.VittoriaConfig
+	var err error
+	if file := c.String("config"); file != "" {
+		unifiedConfig, err = config.LoadConfigFromFile(file)
+	} else {
+		unifiedConfig, err = config.LoadConfigWithOverrides("", "VITTORIA_", nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return unifiedConfig, nil
 }
 
 func showDatabaseInfo(c *cli.Context) error {
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/urfave/cli/v2"
+)
+
+// compactCollections compacts collections of the data directory: their
+// indexes are purged of deleted entries and their files rewritten
+func compactCollections(c *cli.Context) error {
+	ctx := context.Background()
+	db := core.NewDatabase()
+	if err := db.Open(ctx, &core.Config{DataDir: c.String("data-dir")}); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	names := c.StringSlice("collection")
+	if len(names) == 0 {
+		collections, err := db.ListCollections(ctx)
+		if err != nil {
+			return err
+		}
+		for _, info := range collections {
+			names = append(names, info.Name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Println("No collections to compact")
+		return nil
+	}
+
+	var before, after int64
+	for _, name := range names {
+		collection, err := db.GetCollection(ctx, name)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' cannot be compacted", name)
+		}
+		sizeBefore, err := collectionDiskSize(vittoriaCollection)
+		if err != nil {
+			return err
+		}
+		if err := vittoriaCollection.Compact(ctx); err != nil {
+			return fmt.Errorf("failed to compact '%s': %w", name, err)
+		}
+		sizeAfter, err := collectionDiskSize(vittoriaCollection)
+		if err != nil {
+			return err
+		}
+		before += sizeBefore
+		after += sizeAfter
+		fmt.Printf("Compacted '%s': %s -> %s\n", name, formatFileSize(sizeBefore), formatFileSize(sizeAfter))
+	}
+	if len(names) > 1 {
+		fmt.Printf("Compacted %d collections: %s -> %s\n", len(names), formatFileSize(before), formatFileSize(after))
+	}
+	return nil
+}
+
+// collectionDiskSize returns the bytes of a collection's files
+func collectionDiskSize(collection *core.VittoriaCollection) (int64, error) {
+	details, err := collection.Details()
+	if err != nil {
+		return 0, err
+	}
+	return details.Storage.DiskSize, nil
+}
+
+// verifyDataDir checks the files of the data directory's collections, and
+// with --repair fixes the repairable issues
+func verifyDataDir(c *cli.Context) error {
+	ctx := context.Background()
+	dataDir := c.String("data-dir")
+	report, err := verifyCollections(ctx, dataDir, c.StringSlice("collection"))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("repair") {
+		var repairable []string
+		for _, result := range report.Collections {
+			// Shards are repaired through their collection
+			name, _, _ := strings.Cut(result.Collection, "/")
+			if len(result.Issues) > 0 && result.Repairable() && !slices.Contains(repairable, name) {
+				repairable = append(repairable, name)
+			}
+		}
+		if len(repairable) > 0 {
+			if err := repairCollections(ctx, dataDir, repairable); err != nil {
+				return err
+			}
+			if report, err = verifyCollections(ctx, dataDir, c.StringSlice("collection")); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.String("format") == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printVerifyReport(report)
+	}
+	if !report.Healthy {
+		return fmt.Errorf("verification found issues in %s", dataDir)
+	}
+	return nil
+}
+
+// verifyCollections verifies the data directory, keeping the results of the
+// given collections and their shards when any are given
+func verifyCollections(ctx context.Context, dataDir string, names []string) (*core.VerifyReport, error) {
+	report, err := core.VerifyDataDir(ctx, dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", dataDir, err)
+	}
+	if len(names) == 0 {
+		return report, nil
+	}
+
+	filtered := &core.VerifyReport{DataDir: report.DataDir, Collections: []*core.CollectionVerification{}, Healthy: true}
+	for _, result := range report.Collections {
+		name, _, _ := strings.Cut(result.Collection, "/")
+		if !slices.Contains(names, name) {
+			continue
+		}
+		filtered.Collections = append(filtered.Collections, result)
+		if len(result.Issues) > 0 {
+			filtered.Healthy = false
+		}
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(filtered.Collections, func(result *core.CollectionVerification) bool {
+			return strings.HasPrefix(result.Collection+"/", name+"/")
+		}) {
+			return nil, fmt.Errorf("collection '%s' not found in %s", name, dataDir)
+		}
+	}
+	return filtered, nil
+}
+
+// repairCollections opens the data directory, which rebuilds unreadable or
+// stale indexes in memory, and repairs and saves the indexes of collections
+func repairCollections(ctx context.Context, dataDir string, names []string) error {
+	db := core.NewDatabase()
+	if err := db.Open(ctx, &core.Config{DataDir: dataDir}); err != nil {
+		return fmt.Errorf("failed to open database for repair: %w", err)
+	}
+	defer db.Close()
+
+	for _, name := range names {
+		collection, err := db.GetCollection(ctx, name)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' cannot be repaired", name)
+		}
+		if _, err := vittoriaCollection.RepairIndex(ctx); err != nil {
+			return fmt.Errorf("failed to repair '%s': %w", name, err)
+		}
+		fmt.Printf("Repaired the index of '%s'\n", name)
+	}
+	return nil
+}
+
+// printVerifyReport prints the outcome of a verification, with an issue per
+// line under its collection
+func printVerifyReport(report *core.VerifyReport) {
+	withIssues, repairable := 0, 0
+	for _, result := range report.Collections {
+		if len(result.Issues) == 0 {
+			fmt.Printf("%s: ok (%d vectors)\n", result.Collection, result.Vectors)
+			continue
+		}
+		withIssues++
+		if result.Repairable() {
+			repairable++
+		}
+		fmt.Printf("%s: %d issues (%d vectors)\n", result.Collection, len(result.Issues), result.Vectors)
+		for _, issue := range result.Issues {
+			marker := ""
+			if issue.Repairable {
+				marker = " [repairable]"
+			}
+			fmt.Printf("  %s: %s%s\n", issue.File, issue.Problem, marker)
+		}
+	}
+
+	fmt.Printf("\nChecked %d collections in %s: ", len(report.Collections), report.DataDir)
+	switch {
+	case withIssues == 0:
+		fmt.Println("no issues")
+	case repairable > 0:
+		fmt.Printf("%d with issues, %d repairable with --repair\n", withIssues, repairable)
+	default:
+		fmt.Printf("%d with issues, none repairable automatically: fix the files or restore a backup\n", withIssues)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/migrate"
+	"github.com/urfave/cli/v2"
+)
+
+// migrateCollection loads a dump of another vector database into a
+// collection of the data directory, creating it with the dimensions of the
+// first record when it does not exist
+func migrateCollection(c *cli.Context) error {
+	rename, err := migrate.ParseRename(c.StringSlice("map"))
+	if err != nil {
+		return err
+	}
+	mapping := &migrate.Mapping{
+		Fields:         c.StringSlice("fields"),
+		Rename:         rename,
+		NamespaceField: c.String("namespace-field"),
+	}
+	metric, err := core.ParseDistanceMetric(c.String("metric"))
+	if err != nil {
+		return err
+	}
+	indexType, err := core.ParseIndexType(c.String("index"))
+	if err != nil {
+		return err
+	}
+	batchSize := c.Int("batch-size")
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	reader, err := migrate.Open(c.String("from"), c.String("input"), &migrate.Options{
+		VectorName: c.String("vector-name"),
+		Collection: c.String("source-collection"),
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db := core.NewDatabase()
+	if err := db.Open(ctx, &core.Config{DataDir: c.String("data-dir")}); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	name := c.String("collection")
+	var collection core.Collection
+	imported := 0
+	batch := make([]*core.Vector, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := collection.InsertBatch(ctx, batch); err != nil {
+			return fmt.Errorf("failed to insert records %d to %d: %w", imported+1, imported+len(batch), err)
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		fmt.Printf("\rImported %d records", imported)
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			fmt.Println()
+			return fmt.Errorf("migration interrupted after %d records: %w", imported, err)
+		}
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println()
+			return fmt.Errorf("failed to read %s: %w", c.String("input"), err)
+		}
+		if err := mapping.Apply(record); err != nil {
+			fmt.Println()
+			return err
+		}
+
+		if collection == nil {
+			if collection, err = db.GetCollection(ctx, name); err != nil {
+				err = db.CreateCollection(ctx, &core.CreateCollectionRequest{
+					Name:       name,
+					Dimensions: len(record.Vector),
+					Metric:     metric,
+					IndexType:  indexType,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to create collection: %w", err)
+				}
+				fmt.Printf("Created collection '%s' (%d dimensions, %s, %s)\n", name, len(record.Vector), metric.String(), indexType.String())
+				if collection, err = db.GetCollection(ctx, name); err != nil {
+					return err
+				}
+			}
+		}
+
+		batch = append(batch, record)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				fmt.Println()
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		fmt.Println()
+		return err
+	}
+	if imported > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("Migrated %d records from %s into '%s'\n", imported, c.String("input"), name)
+	return nil
+}
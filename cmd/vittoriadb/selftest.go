@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// Self-test check outcomes
+const (
+	checkPass = "PASS"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// selfTestProbeSize is how much the data directory check writes and reads back
+const selfTestProbeSize = 64 << 10
+
+// embedderProbeTimeout bounds the embedder probe, which may load a model
+const embedderProbeTimeout = 60 * time.Second
+
+// selfTestCheck is the outcome of one self-test check
+type selfTestCheck struct {
+	name   string
+	status string
+	detail string
+}
+
+// selfTest holds what the self-test checks
+type selfTest struct {
+	dataDir    string
+	readOnly   bool
+	vectorizer *embeddings.VectorizerConfig // Probed only by the full self-test
+
+	// What the checks exercise, replaced by tests to force failures
+	sync          func(file *os.File) error
+	verifyKernels func() error
+	wallClock     func() time.Time
+}
+
+// newSelfTest creates a self-test of the machine and the data directory
+func newSelfTest(dataDir string, readOnly bool, vectorizer *embeddings.VectorizerConfig) *selfTest {
+	return &selfTest{
+		dataDir:       dataDir,
+		readOnly:      readOnly,
+		vectorizer:    vectorizer,
+		sync:          (*os.File).Sync,
+		verifyKernels: core.VerifyKernels,
+		wallClock:     time.Now,
+	}
+}
+
+// run runs the checks: the short ones made at every startup, and with full
+// the embedder probe as well
+func (t *selfTest) run(ctx context.Context, full bool) []selfTestCheck {
+	checks := []selfTestCheck{t.checkDataDir(), t.checkKernels(), t.checkClock()}
+	if full {
+		checks = append(checks, t.checkEmbedder(ctx))
+	}
+	return checks
+}
+
+// checkDataDir writes a file to the data directory, syncs it and reads it
+// back, so that a full disk, a read-only mount or a lying fsync shows before
+// the database relies on them
+func (t *selfTest) checkDataDir() selfTestCheck {
+	check := selfTestCheck{name: "data directory"}
+
+	if t.readOnly {
+		if _, err := os.ReadDir(t.dataDir); err != nil {
+			check.status, check.detail = checkFail, err.Error()
+			return check
+		}
+		check.status, check.detail = checkPass, fmt.Sprintf("%s is readable (read-only mode, no write test)", t.dataDir)
+		return check
+	}
+
+	if err := os.MkdirAll(t.dataDir, 0755); err != nil {
+		check.status, check.detail = checkFail, err.Error()
+		return check
+	}
+
+	start := time.Now()
+	if err := t.probeDataDir(); err != nil {
+		check.status, check.detail = checkFail, err.Error()
+		return check
+	}
+	check.status = checkPass
+	check.detail = fmt.Sprintf("%s: %d KiB written, synced and read back in %s", t.dataDir, selfTestProbeSize>>10, time.Since(start).Round(time.Microsecond))
+	return check
+}
+
+// probeDataDir makes the write, fsync and read round trip of checkDataDir
+func (t *selfTest) probeDataDir() error {
+	data := make([]byte, selfTestProbeSize)
+	if _, err := rand.Read(data); err != nil {
+		return err
+	}
+
+	path := filepath.Join(t.dataDir, fmt.Sprintf(".selftest-%d", os.Getpid()))
+	defer os.Remove(path)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create a file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write: %w", err)
+	}
+	if err := t.sync(file); err != nil {
+		file.Close()
+		return fmt.Errorf("fsync failed: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close: %w", err)
+	}
+
+	// The new directory entry must be durable too
+	if dir, err := os.Open(t.dataDir); err == nil {
+		err = t.sync(dir)
+		dir.Close()
+		if err != nil {
+			return fmt.Errorf("directory fsync failed: %w", err)
+		}
+	}
+
+	read, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read back: %w", err)
+	}
+	if !bytes.Equal(read, data) {
+		return fmt.Errorf("read back %d bytes that differ from the %d written", len(read), len(data))
+	}
+	return nil
+}
+
+// checkKernels compares the vectorized distance kernels with the scalar ones
+func (t *selfTest) checkKernels() selfTestCheck {
+	check := selfTestCheck{name: "vector kernels"}
+	if err := t.verifyKernels(); err != nil {
+		check.status, check.detail = checkFail, err.Error()
+		return check
+	}
+	check.status, check.detail = checkPass, "vectorized cosine, Euclidean and dot product match the scalar kernels"
+	return check
+}
+
+// checkClock looks for a wall clock that is unset or stepping. Expirations,
+// backups and Raft timeouts all read it.
+func (t *selfTest) checkClock() selfTestCheck {
+	check := selfTestCheck{name: "clock"}
+
+	start, began := t.wallClock().Round(0), time.Now()
+	if start.Year() < 2024 {
+		check.status, check.detail = checkWarn, fmt.Sprintf("wall clock reads %s; is it set?", start.Format(time.RFC3339))
+		return check
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	monotonic := time.Since(began)
+	wall := t.wallClock().Round(0).Sub(start)
+	if drift := (wall - monotonic).Abs(); drift > 100*time.Millisecond {
+		check.status, check.detail = checkWarn, fmt.Sprintf("wall clock moved %s against the monotonic clock in %s", drift, monotonic.Round(time.Millisecond))
+		return check
+	}
+
+	check.status, check.detail = checkPass, fmt.Sprintf("%s, steady against the monotonic clock", start.UTC().Format(time.RFC3339))
+	return check
+}
+
+// checkEmbedder creates the default vectorizer and embeds a probe text
+func (t *selfTest) checkEmbedder(ctx context.Context) selfTestCheck {
+	check := selfTestCheck{name: "embedder"}
+	if t.vectorizer == nil || t.vectorizer.Type == embeddings.VectorizerTypeNone {
+		check.status, check.detail = checkSkip, "no default vectorizer configured"
+		return check
+	}
+	described := fmt.Sprintf("%s %s", t.vectorizer.Type.String(), t.vectorizer.Model)
+
+	vectorizer, err := embeddings.NewVectorizerFactory().CreateVectorizer(t.vectorizer)
+	if err != nil {
+		check.status, check.detail = checkFail, fmt.Sprintf("%s: %v", described, err)
+		return check
+	}
+	defer vectorizer.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, embedderProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	embedding, err := vectorizer.GenerateEmbedding(ctx, "VittoriaDB self-test")
+	if err != nil {
+		check.status, check.detail = checkFail, fmt.Sprintf("%s: %v", described, err)
+		return check
+	}
+	if want := t.vectorizer.Dimensions; want > 0 && len(embedding) != want {
+		check.status, check.detail = checkFail, fmt.Sprintf("%s returned %d dimensions, configured for %d", described, len(embedding), want)
+		return check
+	}
+
+	check.status, check.detail = checkPass, fmt.Sprintf("%s: %d dimensions in %s", described, len(embedding), time.Since(start).Round(time.Millisecond))
+	return check
+}
+
+// selfTestFailed reports whether any check failed
+func selfTestFailed(checks []selfTestCheck) bool {
+	for _, check := range checks {
+		if check.status == checkFail {
+			return true
+		}
+	}
+	return false
+}
+
+// selfTestSummary returns the closing line of a self-test report
+func selfTestSummary(checks []selfTestCheck) string {
+	failed := 0
+	for _, check := range checks {
+		if check.status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Sprintf("Self-test: FAIL (%d of %d checks failed)", failed, len(checks))
+	}
+	return fmt.Sprintf("Self-test: PASS (%d checks)", len(checks))
+}
+
+// printSelfTest writes a self-test report, one line per check
+func printSelfTest(checks []selfTestCheck, printf func(format string, args ...interface{})) {
+	for _, check := range checks {
+		printf("%-4s  %-15s %s", check.status, check.name, check.detail)
+	}
+	printf("%s", selfTestSummary(checks))
+}
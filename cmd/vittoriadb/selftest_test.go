@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelfTestDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	if check := newSelfTest(dataDir, false, nil).checkDataDir(); check.status != checkPass {
+		t.Errorf("writable directory: got %s %q, want PASS", check.status, check.detail)
+	}
+	if entries, _ := os.ReadDir(dataDir); len(entries) != 0 {
+		t.Errorf("the probe left %d files behind", len(entries))
+	}
+
+	for _, test := range []struct {
+		name   string
+		sync   func(file *os.File) error
+		detail string
+	}{
+		{"failing fsync", func(file *os.File) error { return errors.New("input/output error") }, "fsync failed"},
+		{"lost write", func(file *os.File) error {
+			// The data that reaches the disk is not what was written
+			if info, err := file.Stat(); err == nil && !info.IsDir() {
+				file.WriteAt(make([]byte, selfTestProbeSize), 0)
+			}
+			return file.Sync()
+		}, "differ"},
+	} {
+		selfTest := newSelfTest(dataDir, false, nil)
+		selfTest.sync = test.sync
+		if check := selfTest.checkDataDir(); check.status != checkFail || !strings.Contains(check.detail, test.detail) {
+			t.Errorf("%s: got %s %q, want FAIL with %q", test.name, check.status, check.detail, test.detail)
+		}
+	}
+
+	// A data directory that can't be created
+	file := filepath.Join(dataDir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if check := newSelfTest(filepath.Join(file, "data"), false, nil).checkDataDir(); check.status != checkFail {
+		t.Errorf("data directory under a file: got %s %q, want FAIL", check.status, check.detail)
+	}
+
+	// In read-only mode the directory is only read
+	if check := newSelfTest(dataDir, true, nil).checkDataDir(); check.status != checkPass || !strings.Contains(check.detail, "read-only") {
+		t.Errorf("read-only: got %s %q, want PASS", check.status, check.detail)
+	}
+	if check := newSelfTest(filepath.Join(dataDir, "missing"), true, nil).checkDataDir(); check.status != checkFail {
+		t.Errorf("read-only missing directory: got %s %q, want FAIL", check.status, check.detail)
+	}
+}
+
+func TestSelfTestKernels(t *testing.T) {
+	selfTest := newSelfTest(t.TempDir(), false, nil)
+	if check := selfTest.checkKernels(); check.status != checkPass {
+		t.Errorf("got %s %q, want PASS", check.status, check.detail)
+	}
+
+	selfTest.verifyKernels = func() error { return errors.New("cosine at 3 dimensions: vectorized 0.5, scalar 0.25") }
+	if check := selfTest.checkKernels(); check.status != checkFail || !strings.Contains(check.detail, "cosine at 3 dimensions") {
+		t.Errorf("mismatched kernels: got %s %q, want FAIL with the mismatch", check.status, check.detail)
+	}
+}
+
+func TestSelfTestClock(t *testing.T) {
+	selfTest := newSelfTest(t.TempDir(), false, nil)
+	if check := selfTest.checkClock(); check.status != checkPass {
+		t.Errorf("got %s %q, want PASS", check.status, check.detail)
+	}
+
+	// A bad clock is reported without stopping the startup
+	selfTest.wallClock = func() time.Time { return time.Unix(0, 0) }
+	if check := selfTest.checkClock(); check.status != checkWarn || !strings.Contains(check.detail, "is it set?") {
+		t.Errorf("unset clock: got %s %q, want WARN", check.status, check.detail)
+	}
+
+	wall := time.Now()
+	selfTest.wallClock = func() time.Time {
+		wall = wall.Add(time.Second)
+		return wall
+	}
+	if check := selfTest.checkClock(); check.status != checkWarn || !strings.Contains(check.detail, "against the monotonic clock") {
+		t.Errorf("stepping clock: got %s %q, want WARN", check.status, check.detail)
+	}
+}
+
+func TestSelfTestRun(t *testing.T) {
+	selfTest := newSelfTest(t.TempDir(), false, nil)
+	checks := selfTest.run(context.Background(), false)
+	if len(checks) != 3 || selfTestFailed(checks) || selfTestSummary(checks) != "Self-test: PASS (3 checks)" {
+		t.Errorf("got %+v, %q, want three passing checks", checks, selfTestSummary(checks))
+	}
+
+	// The full self-test adds the embedder, skipped without a vectorizer
+	checks = selfTest.run(context.Background(), true)
+	if len(checks) != 4 || checks[3].name != "embedder" || checks[3].status != checkSkip {
+		t.Errorf("got %+v, want a skipped embedder probe", checks)
+	}
+
+	selfTest.verifyKernels = func() error { return errors.New("mismatch") }
+	checks = selfTest.run(context.Background(), false)
+	if !selfTestFailed(checks) || selfTestSummary(checks) != "Self-test: FAIL (1 of 3 checks failed)" {
+		t.Errorf("got %q with a failing check", selfTestSummary(checks))
+	}
+}
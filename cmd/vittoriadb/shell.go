@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/urfave/cli/v2"
+)
+
+// shellHelp lists the commands of the shell
+const shellHelp = `Commands:
+  collections, ls       List the collections
+  use <collection>      Run the following commands against a collection
+  info                  Show the collection's settings
+  get <id>              Show a vector and its metadata
+  search <vector>       Search with a vector, as [0.1, 0.2] or 0.1,0.2
+  similar <id>          Search with the vector of a stored record
+  text <query>          Search with text, embedded by the collection's vectorizer
+  limit <n>             Set the number of results (default 10)
+  filter <json>|off     Set the metadata filter of searches
+  timing on|off         Show how long commands take (default on)
+  help                  Show this help
+  exit, quit            Leave the shell (or Ctrl-D)`
+
+// shellBackend is what the shell runs its commands against: a running server
+// or a data directory opened in the process
+type shellBackend interface {
+	collections(ctx context.Context) ([]*core.CollectionInfo, error)
+	info(ctx context.Context, collection string) (*core.CollectionInfo, error)
+	get(ctx context.Context, collection, id string) (*core.Vector, error)
+	search(ctx context.Context, collection string, req *core.SearchRequest) (*core.SearchResponse, error)
+	searchText(ctx context.Context, collection, query string, limit int, filter *core.Filter) (*core.SearchResponse, error)
+	close() error
+}
+
+// runShell starts an interactive prompt against a running server, or against
+// --data-dir opened read-only
+func runShell(c *cli.Context) error {
+	var backend shellBackend
+	target := strings.TrimSuffix(c.String("server"), "/")
+	if c.IsSet("data-dir") && !c.IsSet("server") {
+		db := core.NewDatabase()
+		// Read-only, so that the shell can be used while a server runs
+		if err := db.Open(c.Context, &core.Config{DataDir: c.String("data-dir"), ReadOnly: true}); err != nil {
+			return fmt.Errorf("failed to open database: %w", err)
+		}
+		backend = &embeddedShell{db: db}
+		target = c.String("data-dir")
+	} else {
+		backend = &serverShell{server: target, apiKey: c.String("api-key")}
+	}
+	defer backend.close()
+
+	// The connection is checked up front rather than at the first command
+	if _, err := backend.collections(c.Context); err != nil {
+		return err
+	}
+
+	sh := &shell{
+		backend:    backend,
+		collection: c.String("collection"),
+		limit:      10,
+		timing:     true,
+		out:        os.Stdout,
+	}
+	// Prompts are left out when commands are piped in
+	interactive := false
+	if stat, err := os.Stdin.Stat(); err == nil {
+		interactive = stat.Mode()&os.ModeCharDevice != 0
+	}
+	if interactive {
+		fmt.Printf("Connected to %s. Type 'help' for the commands.\n", target)
+	}
+	return sh.run(c.Context, os.Stdin, interactive)
+}
+
+// shell holds the state of an interactive session
+type shell struct {
+	backend    shellBackend
+	collection string
+	limit      int
+	filter     *core.Filter
+	filterText string // The filter as typed in
+	timing     bool
+	out        io.Writer
+}
+
+// run reads commands until the input ends or the user leaves
+func (sh *shell) run(ctx context.Context, in io.Reader, interactive bool) error {
+	scanner := bufio.NewScanner(in)
+	// Vectors typed or pasted in can make for long lines
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for {
+		if interactive {
+			fmt.Fprintf(sh.out, "%s> ", sh.prompt())
+		}
+		if !scanner.Scan() {
+			if interactive {
+				fmt.Fprintln(sh.out)
+			}
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		command, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+		if command == "exit" || command == "quit" {
+			return nil
+		}
+
+		start := time.Now()
+		timed, err := sh.execute(ctx, command, args)
+		if err != nil {
+			fmt.Fprintf(sh.out, "Error: %v\n", err)
+			continue
+		}
+		if timed && sh.timing {
+			fmt.Fprintf(sh.out, "(%s)\n", time.Since(start).Round(time.Microsecond))
+		}
+	}
+}
+
+// prompt returns the prompt, which names the collection in use
+func (sh *shell) prompt() string {
+	if sh.collection == "" {
+		return "vittoriadb"
+	}
+	return "vittoriadb:" + sh.collection
+}
+
+// execute runs a command and reports whether it queried the backend, so that
+// its duration is worth showing
+func (sh *shell) execute(ctx context.Context, command, args string) (bool, error) {
+	switch command {
+	case "help", "?":
+		fmt.Fprintln(sh.out, shellHelp)
+		return false, nil
+	case "collections", "ls":
+		return true, sh.listCollections(ctx)
+	case "use":
+		if args == "" {
+			return false, fmt.Errorf("usage: use <collection>")
+		}
+		if _, err := sh.backend.info(ctx, args); err != nil {
+			return false, err
+		}
+		sh.collection = args
+		return false, nil
+	case "limit":
+		limit, err := strconv.Atoi(args)
+		if err != nil || limit <= 0 {
+			return false, fmt.Errorf("usage: limit <n>, with n positive")
+		}
+		sh.limit = limit
+		return false, nil
+	case "filter":
+		return false, sh.setFilter(args)
+	case "timing":
+		switch args {
+		case "on":
+			sh.timing = true
+		case "off":
+			sh.timing = false
+		default:
+			return false, fmt.Errorf("usage: timing on|off")
+		}
+		return false, nil
+	}
+
+	if sh.collection == "" {
+		if command == "info" || command == "get" || command == "search" || command == "similar" || command == "text" {
+			return false, fmt.Errorf("no collection in use: run 'use <collection>' first")
+		}
+	}
+	switch command {
+	case "info":
+		return true, sh.showInfo(ctx)
+	case "get":
+		if args == "" {
+			return false, fmt.Errorf("usage: get <id>")
+		}
+		return true, sh.showVector(ctx, args)
+	case "search":
+		vector, err := parseShellVector(args)
+		if err != nil {
+			return false, err
+		}
+		return true, sh.searchVector(ctx, vector, "")
+	case "similar":
+		if args == "" {
+			return false, fmt.Errorf("usage: similar <id>")
+		}
+		vector, err := sh.backend.get(ctx, sh.collection, args)
+		if err != nil {
+			return false, err
+		}
+		return true, sh.searchVector(ctx, vector.Vector, args)
+	case "text":
+		if args == "" {
+			return false, fmt.Errorf("usage: text <query>")
+		}
+		response, err := sh.backend.searchText(ctx, sh.collection, args, sh.limit, sh.filter)
+		if err != nil {
+			return false, err
+		}
+		sh.printResults(response.Results)
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown command '%s': type 'help' for the commands", command)
+}
+
+// setFilter sets the filter of searches from its JSON form, or clears it
+func (sh *shell) setFilter(args string) error {
+	switch args {
+	case "":
+		if sh.filter == nil {
+			fmt.Fprintln(sh.out, "No filter")
+		} else {
+			fmt.Fprintln(sh.out, sh.filterText)
+		}
+		return nil
+	case "off":
+		sh.filter, sh.filterText = nil, ""
+		return nil
+	}
+	filter := &core.Filter{}
+	if err := json.Unmarshal([]byte(args), filter); err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	sh.filter, sh.filterText = filter, args
+	return nil
+}
+
+// listCollections prints the collections with their sizes and settings
+func (sh *shell) listCollections(ctx context.Context) error {
+	collections, err := sh.backend.collections(ctx)
+	if err != nil {
+		return err
+	}
+	if len(collections) == 0 {
+		fmt.Fprintln(sh.out, "No collections")
+		return nil
+	}
+	w := tabwriter.NewWriter(sh.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVECTORS\tDIMENSIONS\tMETRIC\tINDEX")
+	for _, info := range collections {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", info.Name, info.VectorCount, info.Dimensions, info.Metric.String(), info.IndexType.String())
+	}
+	return w.Flush()
+}
+
+// showInfo prints the settings of the collection in use
+func (sh *shell) showInfo(ctx context.Context) error {
+	info, err := sh.backend.info(ctx, sh.collection)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(sh.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", info.Name)
+	fmt.Fprintf(w, "Vectors:\t%d\n", info.VectorCount)
+	fmt.Fprintf(w, "Dimensions:\t%d\n", info.Dimensions)
+	fmt.Fprintf(w, "Metric:\t%s\n", info.Metric.String())
+	fmt.Fprintf(w, "Index:\t%s\n", info.IndexType.String())
+	if info.Vectorizer != nil {
+		fmt.Fprintf(w, "Vectorizer:\t%s %s\n", info.Vectorizer.Type.String(), info.Vectorizer.Model)
+	}
+	if len(info.IndexedFields) > 0 {
+		fmt.Fprintf(w, "Indexed fields:\t%s\n", strings.Join(info.IndexedFields, ", "))
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", info.Created.Format(time.RFC3339))
+	fmt.Fprintf(w, "Modified:\t%s\n", info.Modified.Format(time.RFC3339))
+	return w.Flush()
+}
+
+// showVector prints a stored vector and its metadata
+func (sh *shell) showVector(ctx context.Context, id string) error {
+	vector, err := sh.backend.get(ctx, sh.collection, id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "ID:         %s\n", vector.ID)
+	if vector.Namespace != "" {
+		fmt.Fprintf(sh.out, "Namespace:  %s\n", vector.Namespace)
+	}
+	fmt.Fprintf(sh.out, "Dimensions: %d\n", len(vector.Vector))
+	fmt.Fprintf(sh.out, "Vector:     %s\n", formatShellVector(vector.Vector, 8))
+	if len(vector.Metadata) > 0 {
+		data, err := json.MarshalIndent(vector.Metadata, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sh.out, "Metadata:   %s\n", data)
+	}
+	return nil
+}
+
+// searchVector prints the nearest neighbours of a vector, leaving out the
+// record exclude when it is the query's own
+func (sh *shell) searchVector(ctx context.Context, vector []float32, exclude string) error {
+	limit := sh.limit
+	if exclude != "" {
+		limit++
+	}
+	response, err := sh.backend.search(ctx, sh.collection, &core.SearchRequest{
+		Vector:          vector,
+		Limit:           limit,
+		Filter:          sh.filter,
+		IncludeMetadata: true,
+	})
+	if err != nil {
+		return err
+	}
+	results := make([]*core.SearchResult, 0, len(response.Results))
+	for _, result := range response.Results {
+		if result.ID != exclude {
+			results = append(results, result)
+		}
+	}
+	if len(results) > sh.limit {
+		results = results[:sh.limit]
+	}
+	sh.printResults(results)
+	return nil
+}
+
+// printResults prints search results as a table, with their metadata
+// shortened to a line
+func (sh *shell) printResults(results []*core.SearchResult) {
+	if len(results) == 0 {
+		fmt.Fprintln(sh.out, "No results")
+		return
+	}
+	w := tabwriter.NewWriter(sh.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tID\tSCORE\tMETADATA")
+	for i, result := range results {
+		metadata := ""
+		if len(result.Metadata) > 0 {
+			if data, err := json.Marshal(result.Metadata); err == nil {
+				metadata = truncateShell(string(data), 80)
+			}
+		}
+		fmt.Fprintf(w, "%d\t%s\t%.4f\t%s\n", i+1, result.ID, result.Score, metadata)
+	}
+	w.Flush()
+}
+
+// parseShellVector parses a vector written as a JSON array or as numbers
+// separated by commas or spaces
+func parseShellVector(args string) ([]float32, error) {
+	if args == "" {
+		return nil, fmt.Errorf("usage: search <vector>")
+	}
+	if strings.HasPrefix(args, "[") {
+		var vector []float32
+		if err := json.Unmarshal([]byte(args), &vector); err != nil {
+			return nil, fmt.Errorf("invalid vector: %w", err)
+		}
+		return vector, nil
+	}
+	fields := strings.FieldsFunc(args, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	vector := make([]float32, 0, len(fields))
+	for _, field := range fields {
+		value, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector component '%s'", field)
+		}
+		vector = append(vector, float32(value))
+	}
+	return vector, nil
+}
+
+// formatShellVector formats the first components of a vector
+func formatShellVector(vector []float32, shown int) string {
+	parts := make([]string, 0, shown+1)
+	for i, value := range vector {
+		if i == shown {
+			parts = append(parts, fmt.Sprintf("... (%d more)", len(vector)-shown))
+			break
+		}
+		parts = append(parts, strconv.FormatFloat(float64(value), 'g', 6, 32))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// truncateShell shortens s to at most max characters
+func truncateShell(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// serverShell runs the shell's commands against a running server
+type serverShell struct {
+	server string
+	apiKey string
+}
+
+func (s *serverShell) collections(ctx context.Context) ([]*core.CollectionInfo, error) {
+	var response struct {
+		Collections []*core.CollectionInfo `json:"collections"`
+	}
+	if err := s.request(ctx, http.MethodGet, "/collections", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Collections, nil
+}
+
+func (s *serverShell) info(ctx context.Context, collection string) (*core.CollectionInfo, error) {
+	info := &core.CollectionInfo{}
+	if err := s.request(ctx, http.MethodGet, "/collections/"+url.PathEscape(collection), nil, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *serverShell) get(ctx context.Context, collection, id string) (*core.Vector, error) {
+	vector := &core.Vector{}
+	if err := s.request(ctx, http.MethodGet, "/collections/"+url.PathEscape(collection)+"/vectors/"+url.PathEscape(id), nil, vector); err != nil {
+		return nil, err
+	}
+	return vector, nil
+}
+
+func (s *serverShell) search(ctx context.Context, collection string, req *core.SearchRequest) (*core.SearchResponse, error) {
+	response := &core.SearchResponse{}
+	if err := s.request(ctx, http.MethodPost, "/collections/"+url.PathEscape(collection)+"/search", req, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *serverShell) searchText(ctx context.Context, collection, query string, limit int, filter *core.Filter) (*core.SearchResponse, error) {
+	if filter != nil {
+		return nil, fmt.Errorf("the server's text search takes no filter: run 'filter off' first")
+	}
+	body := map[string]interface{}{
+		"query":            query,
+		"limit":            limit,
+		"include_metadata": true,
+	}
+	response := &core.SearchResponse{}
+	if err := s.request(ctx, http.MethodPost, "/collections/"+url.PathEscape(collection)+"/search/text", body, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *serverShell) close() error {
+	return nil
+}
+
+// request sends a request to the server and decodes the JSON answer into out
+func (s *serverShell) request(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.server+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &statusError{status: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid server response: %w", err)
+	}
+	return nil
+}
+
+// embeddedShell runs the shell's commands against a database opened in the
+// process
+type embeddedShell struct {
+	db core.Database
+}
+
+func (e *embeddedShell) collections(ctx context.Context) ([]*core.CollectionInfo, error) {
+	return e.db.ListCollections(ctx)
+}
+
+func (e *embeddedShell) info(ctx context.Context, collection string) (*core.CollectionInfo, error) {
+	c, err := e.db.GetCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	vittoriaCollection, ok := c.(*core.VittoriaCollection)
+	if !ok {
+		return nil, fmt.Errorf("collection '%s' has no info", collection)
+	}
+	return vittoriaCollection.Info()
+}
+
+func (e *embeddedShell) get(ctx context.Context, collection, id string) (*core.Vector, error) {
+	c, err := e.db.GetCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	return c.Get(ctx, id)
+}
+
+func (e *embeddedShell) search(ctx context.Context, collection string, req *core.SearchRequest) (*core.SearchResponse, error) {
+	c, err := e.db.GetCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	return c.Search(ctx, req)
+}
+
+func (e *embeddedShell) searchText(ctx context.Context, collection, query string, limit int, filter *core.Filter) (*core.SearchResponse, error) {
+	c, err := e.db.GetCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	return c.SearchText(ctx, query, limit, filter)
+}
+
+func (e *embeddedShell) close() error {
+	return e.db.Close()
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
 )
 
 func main() {
@@ -102,23 +103,17 @@ func main() {
 			currentConfig.Performance.IO.VectorizedOps, updatedConfig.Performance.IO.VectorizedOps)
 	}
 
-	// Demo 6: Legacy configuration migration
-	fmt.Println("\n🔄 6. Legacy Configuration Migration:")
+	// Demo 6: Database settings of the unified configuration
+	fmt.Println("\n🔄 6. Database Settings:")
 
-	migrator := config.NewConfigMigrator()
-	legacyBundle := migrator.MigrateFromUnified(unifiedConfig)
+	dbConfig := core.ConfigFromUnified(unifiedConfig)
+	vectorizer := unifiedConfig.Embeddings.Default.Vectorizer()
 
-	fmt.Printf("   • Converted to legacy format:\n")
-	fmt.Printf("     - Core config data dir: %s\n", legacyBundle.Core.DataDir)
-	fmt.Printf("     - Core config server port: %d\n", legacyBundle.Core.Server.Port)
-	fmt.Printf("     - Embeddings config type: %s\n", legacyBundle.Embeddings.Type.String())
-	fmt.Printf("     - Embeddings config model: %s\n", legacyBundle.Embeddings.Model)
-	fmt.Printf("     - Processing config chunk size: %d\n", legacyBundle.Processing.ChunkSize)
-
-	// Convert back to unified
-	reconverted := migrator.MigrateFromUnified(unifiedConfig)
-	fmt.Printf("   • Round-trip conversion successful: %t\n",
-		reconverted.Core.DataDir == unifiedConfig.DataDir)
+	fmt.Printf("   • Settings the database is opened with:\n")
+	fmt.Printf("     - Data dir: %s\n", dbConfig.DataDir)
+	fmt.Printf("     - Default index type: %s\n", dbConfig.Index.DefaultType)
+	fmt.Printf("     - HNSW ef_search: %d\n", dbConfig.Index.HNSWConfig.EfSearch)
+	fmt.Printf("     - Default vectorizer: %s (%s)\n", vectorizer.Type.String(), vectorizer.Model)
 
 	// Demo 7: Export configuration to YAML
 	fmt.Println("\n📄 7. Configuration Export:")
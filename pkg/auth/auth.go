@@ -0,0 +1,351 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Permission is a class of operations an API key may perform
+type Permission string
+
+const (
+	PermissionRead  Permission = "read"  // Get vectors, search, inspect collections
+	PermissionWrite Permission = "write" // Insert and delete vectors
+	PermissionAdmin Permission = "admin" // Manage collections and keys; implies read and write
+)
+
+// Key sources
+const (
+	SourceConfig = "config" // Defined in the YAML configuration
+	SourceAPI    = "api"    // Created through the /auth/keys API
+)
+
+// keyPrefix marks generated secrets so they are recognizable in logs and configs
+const keyPrefix = "vdb_"
+
+// ParsePermission parses a permission name
+func ParsePermission(s string) (Permission, error) {
+	switch p := Permission(s); p {
+	case PermissionRead, PermissionWrite, PermissionAdmin:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown permission '%s': expected read, write or admin", s)
+	}
+}
+
+// Key is an API key and the access it grants. The secret itself is never
+// stored, only its SHA-256 hash.
+type Key struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+	Collections []string     `json:"collections,omitempty"` // Collection names or path.Match patterns; empty grants all
+	Source      string       `json:"source"`
+	Created     time.Time    `json:"created,omitzero"` // Unset for keys from the configuration
+
+	hash string
+}
+
+// Can reports whether the key grants permission p
+func (k *Key) Can(p Permission) bool {
+	for _, granted := range k.Permissions {
+		if granted == p || granted == PermissionAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllCollections reports whether the key is not restricted to specific collections
+func (k *Key) AllCollections() bool {
+	if len(k.Collections) == 0 {
+		return true
+	}
+	for _, pattern := range k.Collections {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess reports whether the key may operate on the named collection
+func (k *Key) CanAccess(collection string) bool {
+	if k.AllCollections() {
+		return true
+	}
+	for _, pattern := range k.Collections {
+		if matched, err := path.Match(pattern, collection); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the key grants p on the named collection. An empty
+// collection means the whole database, which restricted keys cannot act on.
+func (k *Key) Allows(collection string, p Permission) bool {
+	if !k.Can(p) {
+		return false
+	}
+	if collection == "" {
+		return k.AllCollections()
+	}
+	return k.CanAccess(collection)
+}
+
+// storedKey is the on-disk form of a key created through the API
+type storedKey struct {
+	Name        string       `json:"name"`
+	Hash        string       `json:"hash"`
+	Permissions []Permission `json:"permissions"`
+	Collections []string     `json:"collections,omitempty"`
+	Created     time.Time    `json:"created"`
+}
+
+// Store holds the API keys known to the server. Keys created through the API
+// are persisted to a JSON file; keys from the configuration are not.
+type Store struct {
+	mu     sync.RWMutex
+	byHash map[string]*Key
+	byName map[string]*Key
+	path   string
+}
+
+// NewStore creates an empty key store persisting API-created keys to path
+// ("" keeps them in memory only)
+func NewStore(path string) *Store {
+	return &Store{
+		byHash: make(map[string]*Key),
+		byName: make(map[string]*Key),
+		path:   path,
+	}
+}
+
+// AddConfigKey registers a key defined in the configuration
+func (s *Store) AddConfigKey(name, secret string, permissions []Permission, collections []string) error {
+	if secret == "" {
+		return fmt.Errorf("key '%s' has an empty secret", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(&Key{
+		Name:        name,
+		Permissions: permissions,
+		Collections: collections,
+		Source:      SourceConfig,
+		hash:        hashSecret(secret),
+	})
+}
+
+// Load reads the keys previously created through the API
+func (s *Store) Load() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read keys file: %w", err)
+	}
+
+	var stored []storedKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse keys file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sk := range stored {
+		key := &Key{
+			Name:        sk.Name,
+			Permissions: sk.Permissions,
+			Collections: sk.Collections,
+			Source:      SourceAPI,
+			Created:     sk.Created,
+			hash:        sk.Hash,
+		}
+		if err := s.add(key); err != nil {
+			return fmt.Errorf("keys file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Authenticate returns the key matching secret
+func (s *Store) Authenticate(secret string) (*Key, bool) {
+	if secret == "" {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.byHash[hashSecret(secret)]
+	return key, exists
+}
+
+// Create generates a new key and persists it. The returned secret is not
+// stored and cannot be retrieved again.
+func (s *Store) Create(name string, permissions []Permission, collections []string) (*Key, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("key name cannot be empty")
+	}
+	if len(permissions) == 0 {
+		return nil, "", fmt.Errorf("key must be granted at least one permission")
+	}
+	for _, pattern := range collections {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, "", fmt.Errorf("invalid collection pattern '%s': %w", pattern, err)
+		}
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	secret := keyPrefix + hex.EncodeToString(buf)
+
+	key := &Key{
+		Name:        name,
+		Permissions: permissions,
+		Collections: collections,
+		Source:      SourceAPI,
+		Created:     time.Now().UTC(),
+		hash:        hashSecret(secret),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.add(key); err != nil {
+		return nil, "", err
+	}
+	if err := s.save(); err != nil {
+		s.remove(key)
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// Errors returned for keys that are missing or taken; test with errors.Is
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrKeyExists   = errors.New("key already exists")
+)
+
+// Delete revokes a key created through the API
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.byName[name]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, name)
+	}
+	if key.Source == SourceConfig {
+		return fmt.Errorf("key '%s' is defined in the configuration and cannot be deleted through the API", name)
+	}
+
+	s.remove(key)
+	if err := s.save(); err != nil {
+		s.add(key)
+		return err
+	}
+	return nil
+}
+
+// List returns all keys sorted by name
+func (s *Store) List() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(s.byName))
+	for _, key := range s.byName {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// add indexes a key, rejecting duplicate names and secrets
+func (s *Store) add(key *Key) error {
+	if _, exists := s.byName[key.Name]; exists {
+		return fmt.Errorf("%w: %s", ErrKeyExists, key.Name)
+	}
+	if _, exists := s.byHash[key.hash]; exists {
+		return fmt.Errorf("key '%s' reuses the secret of another key", key.Name)
+	}
+	s.byName[key.Name] = key
+	s.byHash[key.hash] = key
+	return nil
+}
+
+// remove drops a key from the indexes
+func (s *Store) remove(key *Key) {
+	delete(s.byName, key.Name)
+	delete(s.byHash, key.hash)
+}
+
+// save writes the API-created keys to the keys file
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	stored := make([]storedKey, 0, len(s.byName))
+	for _, key := range s.byName {
+		if key.Source != SourceAPI {
+			continue
+		}
+		stored = append(stored, storedKey{
+			Name:        key.Name,
+			Hash:        key.hash,
+			Permissions: key.Permissions,
+			Collections: key.Collections,
+			Created:     key.Created,
+		})
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].Name < stored[j].Name
+	})
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create keys directory: %w", err)
+	}
+
+	// Write atomically so a crash cannot leave a truncated keys file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keys file: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// hashSecret returns the hex SHA-256 of a key secret
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
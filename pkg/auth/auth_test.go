@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKey_Allows(t *testing.T) {
+	key := &Key{
+		Name:        "tenant-a",
+		Permissions: []Permission{PermissionRead, PermissionWrite},
+		Collections: []string{"tenant_a_*", "shared"},
+	}
+
+	cases := []struct {
+		collection string
+		permission Permission
+		want       bool
+	}{
+		{"tenant_a_docs", PermissionRead, true},
+		{"tenant_a_docs", PermissionWrite, true},
+		{"shared", PermissionRead, true},
+		{"tenant_a_docs", PermissionAdmin, false},
+		{"tenant_b_docs", PermissionRead, false},
+		{"", PermissionRead, false}, // Database-wide access needs an unrestricted key
+	}
+	for _, tc := range cases {
+		if got := key.Allows(tc.collection, tc.permission); got != tc.want {
+			t.Errorf("Allows(%q, %s) = %t, want %t", tc.collection, tc.permission, got, tc.want)
+		}
+	}
+
+	admin := &Key{Name: "ops", Permissions: []Permission{PermissionAdmin}}
+	if !admin.Allows("", PermissionWrite) || !admin.Allows("anything", PermissionRead) {
+		t.Error("Expected an unrestricted admin key to allow everything")
+	}
+}
+
+func TestStore_PersistsAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth_keys.json")
+
+	store := NewStore(path)
+	if err := store.AddConfigKey("ops", "config-secret-0123456789", []Permission{PermissionAdmin}, nil); err != nil {
+		t.Fatalf("Failed to add config key: %v", err)
+	}
+	key, secret, err := store.Create("reader", []Permission{PermissionRead}, []string{"docs"})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, _, err := store.Create("reader", []Permission{PermissionRead}, nil); err == nil {
+		t.Error("Expected duplicate key name to be rejected")
+	}
+
+	// A fresh store sees the API key but not the config key
+	reloaded := NewStore(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	found, ok := reloaded.Authenticate(secret)
+	if !ok || found.Name != key.Name || !found.Allows("docs", PermissionRead) {
+		t.Fatalf("Reloaded store did not authenticate the created key: %+v", found)
+	}
+	if _, ok := reloaded.Authenticate("config-secret-0123456789"); ok {
+		t.Error("Config keys must not be persisted to the keys file")
+	}
+
+	if err := store.Delete("ops"); err == nil {
+		t.Error("Expected config key deletion to be rejected")
+	}
+	if err := store.Delete("reader"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	if _, ok := store.Authenticate(secret); ok {
+		t.Error("Deleted key still authenticates")
+	}
+}
+
+func TestUsageLedger_Report(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth_usage.json")
+	ledger := NewUsageLedger(path, 0)
+
+	now := time.Now().UTC()
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	ledger.Record("team-a", Usage{Requests: 1, VectorsInserted: 10, BytesStored: 400}, now)
+	ledger.Record("team-a", Usage{Requests: 1, EmbeddingTokens: 25}, now)
+	ledger.Record("team-b", Usage{Requests: 1}, now)
+	ledger.Record("team-a", Usage{Requests: 5}, lastMonth)
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("Failed to save usage: %v", err)
+	}
+
+	// A fresh ledger reports what the first one saved
+	reloaded := NewUsageLedger(path, 0)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to load usage: %v", err)
+	}
+
+	month, err := reloaded.Report(PeriodMonth, now)
+	if err != nil {
+		t.Fatalf("Failed to report usage: %v", err)
+	}
+	want := Usage{Requests: 2, VectorsInserted: 10, BytesStored: 400, EmbeddingTokens: 25}
+	if month.Keys["team-a"] != want {
+		t.Errorf("Expected team-a usage %+v this month, got %+v", want, month.Keys["team-a"])
+	}
+	if month.Total.Requests != 3 {
+		t.Errorf("Expected 3 requests this month, got %d", month.Total.Requests)
+	}
+
+	previous, _ := reloaded.Report(PeriodMonth, lastMonth)
+	if previous.Total.Requests != 5 || len(previous.Keys) != 1 {
+		t.Errorf("Expected only team-a's 5 requests last month, got %+v", previous)
+	}
+
+	all, _ := reloaded.Report(PeriodAll, now)
+	if all.Keys["team-a"].Requests != 7 {
+		t.Errorf("Expected 7 team-a requests overall, got %d", all.Keys["team-a"].Requests)
+	}
+
+	if _, err := reloaded.Report("week", now); err == nil {
+		t.Error("Expected an unknown period to be rejected")
+	}
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// usageRetention is how long daily usage is kept, enough for a year-on-year report
+const usageRetention = 400 * 24 * time.Hour
+
+// usageDayFormat keys the daily usage buckets
+const usageDayFormat = "2006-01-02"
+
+// Usage report periods
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+	PeriodAll   = "all"
+)
+
+// Usage is the activity attributed to an API key
+type Usage struct {
+	Requests        int64 `json:"requests"`
+	VectorsInserted int64 `json:"vectors_inserted"`
+	BytesStored     int64 `json:"bytes_stored"`     // Estimated size of the inserted IDs, vectors, content and metadata
+	EmbeddingTokens int64 `json:"embedding_tokens"` // As reported by the embedding provider
+}
+
+// Add adds other to u
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.VectorsInserted += other.VectorsInserted
+	u.BytesStored += other.BytesStored
+	u.EmbeddingTokens += other.EmbeddingTokens
+}
+
+// UsageReport is the usage of every key over a period
+type UsageReport struct {
+	Period string           `json:"period"`
+	From   string           `json:"from,omitempty"` // First day of the period, inclusive
+	To     string           `json:"to,omitempty"`   // Last day of the period, inclusive
+	Keys   map[string]Usage `json:"keys"`
+	Total  Usage            `json:"total"`
+}
+
+// UsageLedger accumulates the usage of each API key per UTC day. It is kept in
+// memory and written to a JSON file every flush interval and on Close.
+type UsageLedger struct {
+	mu    sync.Mutex
+	days  map[string]map[string]*Usage // Day, then key name
+	path  string
+	dirty bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewUsageLedger creates an empty ledger persisted to path ("" keeps it in
+// memory only), flushed every flushInterval
+func NewUsageLedger(path string, flushInterval time.Duration) *UsageLedger {
+	l := &UsageLedger{
+		days: make(map[string]map[string]*Usage),
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if path == "" || flushInterval <= 0 {
+		close(l.done)
+		return l
+	}
+
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.Save(); err != nil {
+					log.Printf("Failed to save API key usage: %v", err)
+				}
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+	return l
+}
+
+// Load reads the usage saved by a previous run
+func (l *UsageLedger) Load() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read usage file: %w", err)
+	}
+
+	var days map[string]map[string]*Usage
+	if err := json.Unmarshal(data, &days); err != nil {
+		return fmt.Errorf("failed to parse usage file: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for day, keys := range days {
+		if l.days[day] == nil {
+			l.days[day] = make(map[string]*Usage)
+		}
+		for name, usage := range keys {
+			if l.days[day][name] == nil {
+				l.days[day][name] = &Usage{}
+			}
+			l.days[day][name].Add(*usage)
+		}
+	}
+	return nil
+}
+
+// Record adds usage to the named key on the day of at
+func (l *UsageLedger) Record(key string, usage Usage, at time.Time) {
+	day := at.UTC().Format(usageDayFormat)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys, exists := l.days[day]
+	if !exists {
+		keys = make(map[string]*Usage)
+		l.days[day] = keys
+	}
+	if keys[key] == nil {
+		keys[key] = &Usage{}
+	}
+	keys[key].Add(usage)
+	l.dirty = true
+}
+
+// Report sums the usage of each key over the day, month or year containing
+// at, or over everything recorded for PeriodAll
+func (l *UsageLedger) Report(period string, at time.Time) (*UsageReport, error) {
+	at = at.UTC()
+	report := &UsageReport{Period: period, Keys: make(map[string]Usage)}
+
+	var from, to time.Time
+	switch period {
+	case PeriodDay:
+		from = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+		to = from
+	case PeriodMonth:
+		from = time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 1, -1)
+	case PeriodYear:
+		from = time.Date(at.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(1, 0, -1)
+	case PeriodAll:
+	default:
+		return nil, fmt.Errorf("unknown period '%s': expected day, month, year or all", period)
+	}
+	if period != PeriodAll {
+		report.From = from.Format(usageDayFormat)
+		report.To = to.Format(usageDayFormat)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for day, keys := range l.days {
+		// Days sort lexically in date order
+		if report.From != "" && (day < report.From || day > report.To) {
+			continue
+		}
+		for name, usage := range keys {
+			total := report.Keys[name]
+			total.Add(*usage)
+			report.Keys[name] = total
+			report.Total.Add(*usage)
+		}
+	}
+	return report, nil
+}
+
+// Save writes the ledger to its file if it changed, dropping days older than
+// the retention period
+func (l *UsageLedger) Save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	oldest := time.Now().UTC().Add(-usageRetention).Format(usageDayFormat)
+	for day := range l.days {
+		if day < oldest {
+			delete(l.days, day)
+		}
+	}
+	data, err := json.MarshalIndent(l.days, "", "  ")
+	l.dirty = false
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+
+	// Write atomically so a crash cannot leave a truncated usage file
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		l.markDirty()
+		return fmt.Errorf("failed to write usage file: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		l.markDirty()
+		return err
+	}
+	return nil
+}
+
+// markDirty schedules another save after a failed one
+func (l *UsageLedger) markDirty() {
+	l.mu.Lock()
+	l.dirty = true
+	l.mu.Unlock()
+}
+
+// Close stops the periodic flush and saves the ledger
+func (l *UsageLedger) Close() error {
+	select {
+	case <-l.stop:
+	default:
+		close(l.stop)
+	}
+	<-l.done
+	return l.Save()
+}
@@ -0,0 +1,258 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// Command operations replicated through the log
+const (
+	OpCreateCollection  = "create_collection"
+	OpDropCollection    = "drop_collection"
+	OpInsert            = "insert"
+	OpDelete            = "delete"
+	OpReshard           = "reshard"
+	OpUpdateCollection  = "update_collection"
+	OpDropNamespace     = "drop_namespace"
+	OpRestoreCollection = "restore_collection"
+	OpCreateGroup       = "create_group"
+	OpDropGroup         = "drop_group"
+	OpPatchMetadata     = "patch_metadata"
+	OpDeleteBatch       = "delete_batch"
+	OpRebuildIndex      = "rebuild_index"
+)
+
+// Command represents a replicated write against the database
+type Command struct {
+	Op          string                        `json:"op"`
+	Collection  string                        `json:"collection,omitempty"`
+	Create      *core.CreateCollectionRequest `json:"create,omitempty"`
+	Vectors     []*core.Vector                `json:"vectors,omitempty"`
+	IDs         []string                      `json:"ids,omitempty"`
+	Shards      int                           `json:"shards,omitempty"`
+	Update      *core.UpdateCollectionRequest `json:"update,omitempty"`
+	Namespace   string                        `json:"namespace,omitempty"` // Namespace of IDs for delete and drop_namespace
+	Group       string                        `json:"group,omitempty"`
+	CreateGroup *core.CreateGroupRequest      `json:"create_group,omitempty"`
+	Patch       *core.MetadataPatchRequest    `json:"patch,omitempty"`
+	Condition   *core.Filter                  `json:"condition,omitempty"`  // Condition the stored records must meet for an insert
+	Filter      *core.Filter                  `json:"filter,omitempty"`     // Records to delete for delete_batch, instead of IDs
+	Embeddings  int                           `json:"embeddings,omitempty"` // How many inserted vectors the collection's vectorizer generated
+	Rebuild     *core.IndexRebuildRequest     `json:"rebuild,omitempty"`
+}
+
+// Encode serializes the command for the replicated log
+func (c *Command) Encode() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// Execute applies a command directly to a database
+func Execute(ctx context.Context, db core.Database, cmd *Command) error {
+	switch cmd.Op {
+	case OpCreateCollection:
+		if cmd.Create == nil {
+			return fmt.Errorf("create_collection command requires a collection request")
+		}
+		return db.CreateCollection(ctx, cmd.Create)
+
+	case OpDropCollection:
+		return db.DropCollection(ctx, cmd.Collection)
+
+	case OpRestoreCollection:
+		return db.RestoreCollection(ctx, cmd.Collection)
+
+	case OpCreateGroup:
+		if cmd.CreateGroup == nil {
+			return fmt.Errorf("create_group command requires a group request")
+		}
+		return db.CreateGroup(ctx, cmd.CreateGroup)
+
+	case OpDropGroup:
+		return db.DropGroup(ctx, cmd.Group)
+
+	case OpInsert:
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		if cmd.Condition != nil {
+			vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+			if !ok {
+				return fmt.Errorf("collection '%s' does not support conditional writes", cmd.Collection)
+			}
+			return vittoriaCollection.InsertIf(ctx, cmd.Vectors, cmd.Condition)
+		}
+		if len(cmd.Vectors) == 1 {
+			err = collection.Insert(ctx, cmd.Vectors[0])
+		} else {
+			err = collection.InsertBatch(ctx, cmd.Vectors)
+		}
+		if err == nil && cmd.Embeddings > 0 {
+			if vittoriaCollection, ok := collection.(*core.VittoriaCollection); ok {
+				vittoriaCollection.RecordEmbeddings(cmd.Embeddings)
+			}
+		}
+		return err
+
+	case OpDelete:
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		for _, id := range cmd.IDs {
+			if err := collection.DeleteInNamespace(ctx, cmd.Namespace, id); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case OpReshard:
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' does not support resharding", cmd.Collection)
+		}
+		_, err = vittoriaCollection.Reshard(ctx, cmd.Shards)
+		return err
+
+	case OpUpdateCollection:
+		if cmd.Update == nil {
+			return fmt.Errorf("update_collection command requires an update request")
+		}
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' does not support updates", cmd.Collection)
+		}
+		return vittoriaCollection.Update(ctx, cmd.Update)
+
+	case OpDropNamespace:
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' does not support namespaces", cmd.Collection)
+		}
+		_, err = vittoriaCollection.DropNamespace(ctx, cmd.Namespace)
+		return err
+
+	case OpPatchMetadata:
+		if cmd.Patch == nil {
+			return fmt.Errorf("patch_metadata command requires a patch request")
+		}
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' does not support metadata patches", cmd.Collection)
+		}
+		_, err = vittoriaCollection.PatchMetadata(ctx, cmd.Patch)
+		return err
+
+	case OpDeleteBatch:
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' does not support batch deletes", cmd.Collection)
+		}
+		if cmd.Filter != nil {
+			_, err = vittoriaCollection.DeleteByFilter(ctx, cmd.Namespace, cmd.Filter)
+		} else {
+			_, err = vittoriaCollection.DeleteBatch(ctx, cmd.Namespace, cmd.IDs)
+		}
+		return err
+
+	case OpRebuildIndex:
+		collection, err := db.GetCollection(ctx, cmd.Collection)
+		if err != nil {
+			return err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			return fmt.Errorf("collection '%s' does not support index rebuilds", cmd.Collection)
+		}
+		_, err = vittoriaCollection.StartIndexRebuild(ctx, cmd.Rebuild)
+		return err
+
+	default:
+		return fmt.Errorf("unknown command operation '%s'", cmd.Op)
+	}
+}
+
+// DatabaseFSM applies replicated commands to a local database
+type DatabaseFSM struct {
+	db core.Database
+}
+
+// NewDatabaseFSM creates an FSM backed by db
+func NewDatabaseFSM(db core.Database) *DatabaseFSM {
+	return &DatabaseFSM{db: db}
+}
+
+// Apply decodes and executes a replicated command
+func (f *DatabaseFSM) Apply(data []byte) error {
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return fmt.Errorf("failed to decode command: %w", err)
+	}
+
+	return Execute(context.Background(), f.db, &cmd)
+}
+
+// Snapshot flushes every collection, so that the commands applied so far
+// survive a restart without being applied again
+func (f *DatabaseFSM) Snapshot() error {
+	ctx := context.Background()
+	collections, err := f.db.ListAllCollections(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range collections {
+		collection, err := f.db.GetCollection(ctx, info.Name)
+		if err != nil {
+			return err
+		}
+		if err := collection.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush collection %s: %w", info.Name, err)
+		}
+	}
+	return nil
+}
+
+// Replayable reports whether a command can be applied again. Writes and
+// deletes of records and collection updates leave the same state. Creating,
+// dropping and restoring collections and groups, resharding and index
+// rebuilds don't: replaying a create and a drop puts a second copy of the
+// collection in the trash.
+func (f *DatabaseFSM) Replayable(data []byte) bool {
+	var cmd struct {
+		Op string `json:"op"`
+	}
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return false
+	}
+
+	switch cmd.Op {
+	case OpInsert, OpDelete, OpDeleteBatch, OpPatchMetadata, OpDropNamespace, OpUpdateCollection:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,109 @@
+package cluster
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// openDatabase opens a database in dir that keeps dropped collections in the trash
+func openDatabase(t *testing.T, dir string) core.Database {
+	t.Helper()
+	db := core.NewDatabase()
+	config := &core.Config{DataDir: dir, Storage: core.StorageConfig{TrashRetention: time.Hour}}
+	if err := db.Open(context.Background(), config); err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	return db
+}
+
+// startSoloNode starts a single-node cluster applying its log to db
+func startSoloNode(t *testing.T, db core.Database, raftDir string) *Node {
+	t.Helper()
+	config := DefaultConfig()
+	config.NodeID = "solo"
+	config.Address = "http://localhost:0"
+	config.DataDir = raftDir
+	node, err := NewNode(config, NewDatabaseFSM(db))
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	node.Start()
+	return node
+}
+
+func TestDatabaseFSMRestart(t *testing.T) {
+	ctx := context.Background()
+	dataDir, raftDir := t.TempDir(), t.TempDir()
+	db := openDatabase(t, dataDir)
+	node := startSoloNode(t, db, raftDir)
+
+	create := &core.CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: core.DistanceMetricCosine, IndexType: core.IndexTypeFlat}
+	for _, cmd := range []*Command{
+		{Op: OpCreateCollection, Create: create},
+		{Op: OpInsert, Collection: "docs", Vectors: []*core.Vector{{ID: "a", Vector: []float32{1, 0}}}},
+		{Op: OpDropCollection, Collection: "docs"},
+		{Op: OpCreateCollection, Create: create},
+		{Op: OpInsert, Collection: "docs", Vectors: []*core.Vector{{ID: "b", Vector: []float32{0, 1}}}},
+	} {
+		data, err := cmd.Encode()
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if err := node.Apply(ctx, data); err != nil {
+			t.Fatalf("Apply %s failed: %v", cmd.Op, err)
+		}
+	}
+
+	// The state was snapshotted after the second create, which can't be replayed
+	if status := node.Status(); status.SnapshotIndex != status.LastLogIndex-1 {
+		t.Errorf("Snapshot covers entry %d, expected %d", status.SnapshotIndex, status.LastLogIndex-1)
+	}
+	node.Stop()
+
+	// A crash loses what the database had not flushed: restart from a copy of
+	// the files as they are now
+	crashedData, crashedRaft := t.TempDir(), t.TempDir()
+	if err := os.CopyFS(crashedData, os.DirFS(dataDir)); err != nil {
+		t.Fatalf("Failed to copy the data directory: %v", err)
+	}
+	if err := os.CopyFS(crashedRaft, os.DirFS(raftDir)); err != nil {
+		t.Fatalf("Failed to copy the raft directory: %v", err)
+	}
+	db.Close()
+
+	db = openDatabase(t, crashedData)
+	defer db.Close()
+	node = startSoloNode(t, db, crashedRaft)
+	defer node.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status := node.Status(); status.AppliedIndex < status.LastLogIndex; status = node.Status() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Applied %d of %d entries after the restart", status.AppliedIndex, status.LastLogIndex)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Only the insert after the snapshot was applied again
+	collection, err := db.GetCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("GetCollection failed: %v", err)
+	}
+	if _, err := collection.Get(ctx, "b"); err != nil {
+		t.Errorf("The replayed insert is missing: %v", err)
+	}
+	if _, err := collection.Get(ctx, "a"); err == nil {
+		t.Error("The dropped collection's record is back")
+	}
+	trashed, err := db.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(trashed) != 1 {
+		t.Errorf("%d collections in the trash, expected 1", len(trashed))
+	}
+}
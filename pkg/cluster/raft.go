@@ -0,0 +1,783 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Node is a Raft consensus node. Writes submitted to the leader through Apply
+// are appended to the replicated log and applied to the FSM on every node once
+// a majority has persisted them. Nodes talk to each other over HTTP using the
+// RequestVote and AppendEntries RPCs served at VotePath and AppendPath.
+//
+// The log is kept in full, so that lagging followers can always catch up from
+// it. An FSM implementing Snapshotter persists its state every so often, and a
+// restarted node only applies the entries after the last snapshot again; other
+// FSMs get the whole log again, so their commands must be safe to replay.
+type Node struct {
+	config *Config
+	fsm    FSM
+	store  *storage
+	client *http.Client
+
+	mu               sync.Mutex
+	state            NodeState
+	currentTerm      uint64
+	votedFor         string
+	log              []*LogEntry // log[0] is a sentinel so that log[i].Index == i
+	commitIndex      uint64
+	lastApplied      uint64
+	snapshotIndex    uint64 // Last entry covered by an FSM snapshot
+	leaderID         string
+	leaderAddr       string
+	electionDeadline time.Time
+	lastHeartbeat    time.Time
+	nextIndex        map[string]uint64
+	matchIndex       map[string]uint64
+	inflight         map[string]bool
+	waiters          map[uint64]chan error
+	stopped          bool
+
+	applyCh     chan struct{}
+	replicateCh chan struct{}
+	stopCh      chan struct{}
+	stopCtx     context.Context // Cancelled by Stop to abort RPCs in flight
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup // Loops, replication and vote requests Stop waits for
+}
+
+// NewNode creates a Raft node and restores its persisted term, vote and log
+func NewNode(config *Config, fsm FSM) (*Node, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.NodeID == "" {
+		return nil, fmt.Errorf("cluster node ID cannot be empty")
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("cluster node address cannot be empty")
+	}
+	if config.DataDir == "" {
+		return nil, fmt.Errorf("cluster data directory cannot be empty")
+	}
+	if _, exists := config.Peers[config.NodeID]; exists {
+		return nil, fmt.Errorf("peer list must not contain the local node '%s'", config.NodeID)
+	}
+	if len(config.Peers) > 0 && config.Secret == "" {
+		return nil, fmt.Errorf("cluster secret cannot be empty when the node has peers")
+	}
+	if config.HeartbeatInterval <= 0 || config.ElectionTimeout <= config.HeartbeatInterval {
+		return nil, fmt.Errorf("election timeout must be greater than the heartbeat interval")
+	}
+	if config.MaxAppendEntries <= 0 {
+		config.MaxAppendEntries = DefaultConfig().MaxAppendEntries
+	}
+	if config.RPCTimeout <= 0 {
+		config.RPCTimeout = DefaultConfig().RPCTimeout
+	}
+	if config.SnapshotInterval <= 0 {
+		config.SnapshotInterval = DefaultConfig().SnapshotInterval
+	}
+
+	store, err := openStorage(config.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := store.loadState()
+	if err != nil {
+		store.close()
+		return nil, err
+	}
+
+	entries, err := store.loadLog()
+	if err != nil {
+		store.close()
+		return nil, err
+	}
+
+	stopCtx, cancel := context.WithCancel(context.Background())
+	n := &Node{
+		config:      config,
+		fsm:         fsm,
+		store:       store,
+		client:      &http.Client{Timeout: config.RPCTimeout},
+		state:       StateFollower,
+		currentTerm: state.CurrentTerm,
+		votedFor:    state.VotedFor,
+		log:         []*LogEntry{{}},
+		nextIndex:   make(map[string]uint64),
+		matchIndex:  make(map[string]uint64),
+		inflight:    make(map[string]bool),
+		waiters:     make(map[uint64]chan error),
+		applyCh:     make(chan struct{}, 1),
+		replicateCh: make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+		stopCtx:     stopCtx,
+		cancel:      cancel,
+	}
+
+	for _, entry := range entries {
+		if entry.Index != n.lastIndex()+1 {
+			cancel()
+			store.close()
+			return nil, fmt.Errorf("raft log is corrupted: expected index %d, got %d", n.lastIndex()+1, entry.Index)
+		}
+		n.log = append(n.log, entry)
+	}
+
+	// The entries covered by the FSM's last snapshot were committed and
+	// applied, and must not be applied again
+	if _, ok := fsm.(Snapshotter); ok {
+		n.snapshotIndex = min(state.SnapshotIndex, n.lastIndex())
+		n.commitIndex = n.snapshotIndex
+		n.lastApplied = n.snapshotIndex
+	}
+
+	return n, nil
+}
+
+// Start starts the election timer, replication and apply loops
+func (n *Node) Start() {
+	n.mu.Lock()
+	n.resetElectionDeadline()
+	// A single-node cluster doesn't need to wait for an election timeout
+	if len(n.config.Peers) == 0 {
+		n.currentTerm++
+		n.votedFor = n.config.NodeID
+		if err := n.persistState(); err != nil {
+			log.Printf("cluster: failed to persist state: %v", err)
+		}
+		n.becomeLeader()
+	}
+	n.mu.Unlock()
+
+	n.wg.Add(2)
+	go n.run()
+	go n.applier()
+}
+
+// Stop stops the node and fails any pending writes
+func (n *Node) Stop() error {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		return nil
+	}
+	n.stopped = true
+	n.mu.Unlock()
+
+	close(n.stopCh)
+	n.cancel()
+	n.wg.Wait()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failWaiters(fmt.Errorf("cluster node stopped"))
+	return n.store.close()
+}
+
+// Apply submits a command to the replicated log and waits until it has been
+// committed and applied locally. It returns ErrNotLeader on followers.
+func (n *Node) Apply(ctx context.Context, command []byte) error {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+		return fmt.Errorf("cluster node stopped")
+	}
+	if n.state != StateLeader {
+		n.mu.Unlock()
+		return ErrNotLeader
+	}
+
+	entry := &LogEntry{Index: n.lastIndex() + 1, Term: n.currentTerm, Command: command}
+	if err := n.store.appendEntries([]*LogEntry{entry}); err != nil {
+		n.mu.Unlock()
+		return fmt.Errorf("failed to persist log entry: %w", err)
+	}
+	n.log = append(n.log, entry)
+
+	done := make(chan error, 1)
+	n.waiters[entry.Index] = done
+	n.advanceCommitIndex()
+	n.mu.Unlock()
+
+	n.signal(n.replicateCh)
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.stopCh:
+		return fmt.Errorf("cluster node stopped")
+	}
+}
+
+// IsLeader reports whether this node is the current leader
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.state == StateLeader
+}
+
+// Leader returns the ID and address of the current leader, if known
+func (n *Node) Leader() (string, string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leaderID, n.leaderAddr
+}
+
+// LogError returns the error from the last write to the Raft log or state
+// file, or nil if that write succeeded
+func (n *Node) LogError() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.store.lastErr
+}
+
+// Status returns a snapshot of the node's view of the cluster
+func (n *Node) Status() *Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	status := &Status{
+		NodeID:        n.config.NodeID,
+		Address:       n.config.Address,
+		State:         n.state.String(),
+		Term:          n.currentTerm,
+		LeaderID:      n.leaderID,
+		LeaderAddress: n.leaderAddr,
+		CommitIndex:   n.commitIndex,
+		AppliedIndex:  n.lastApplied,
+		SnapshotIndex: n.snapshotIndex,
+		LastLogIndex:  n.lastIndex(),
+		Peers:         make([]*PeerStatus, 0, len(n.config.Peers)),
+	}
+
+	for id, addr := range n.config.Peers {
+		peer := &PeerStatus{ID: id, Address: addr}
+		if n.state == StateLeader {
+			peer.MatchIndex = n.matchIndex[id]
+			peer.NextIndex = n.nextIndex[id]
+		}
+		status.Peers = append(status.Peers, peer)
+	}
+	sort.Slice(status.Peers, func(i, j int) bool { return status.Peers[i].ID < status.Peers[j].ID })
+
+	return status
+}
+
+// Authenticate reports whether an RPC carried the cluster secret. A node
+// without a secret accepts no RPCs.
+func (n *Node) Authenticate(secret string) bool {
+	if n.config.Secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(n.config.Secret)) == 1
+}
+
+// isPeer reports whether id is a configured member of the cluster
+func (n *Node) isPeer(id string) bool {
+	_, ok := n.config.Peers[id]
+	return ok
+}
+
+// RequestVote handles an incoming RequestVote RPC. Candidates that are not
+// configured peers are refused without affecting the term.
+func (n *Node) RequestVote(req *VoteRequest) *VoteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.isPeer(req.CandidateID) {
+		log.Printf("cluster: refused vote request from unknown node '%s'", req.CandidateID)
+		return &VoteResponse{Term: n.currentTerm}
+	}
+	if req.Term > n.currentTerm {
+		n.stepDown(req.Term)
+	}
+
+	resp := &VoteResponse{Term: n.currentTerm}
+	if req.Term < n.currentTerm {
+		return resp
+	}
+
+	lastTerm := n.log[n.lastIndex()].Term
+	upToDate := req.LastLogTerm > lastTerm || (req.LastLogTerm == lastTerm && req.LastLogIndex >= n.lastIndex())
+
+	if (n.votedFor == "" || n.votedFor == req.CandidateID) && upToDate {
+		n.votedFor = req.CandidateID
+		if err := n.persistState(); err != nil {
+			log.Printf("cluster: failed to persist vote: %v", err)
+			return resp
+		}
+		n.resetElectionDeadline()
+		resp.VoteGranted = true
+	}
+
+	return resp
+}
+
+// AppendEntries handles an incoming AppendEntries RPC. Leaders that are not
+// configured peers are refused without affecting the term or the log.
+func (n *Node) AppendEntries(req *AppendRequest) *AppendResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.isPeer(req.LeaderID) {
+		log.Printf("cluster: refused entries from unknown node '%s'", req.LeaderID)
+		return &AppendResponse{Term: n.currentTerm, LastIndex: n.lastIndex()}
+	}
+	if req.Term < n.currentTerm {
+		return &AppendResponse{Term: n.currentTerm, LastIndex: n.lastIndex()}
+	}
+	if req.Term > n.currentTerm || n.state != StateFollower {
+		n.stepDown(req.Term)
+	}
+
+	n.leaderID = req.LeaderID
+	n.leaderAddr = req.LeaderAddr
+	n.resetElectionDeadline()
+
+	resp := &AppendResponse{Term: n.currentTerm}
+
+	// The entry preceding the new ones must match for the logs to line up
+	if req.PrevLogIndex > n.lastIndex() {
+		resp.LastIndex = n.lastIndex()
+		return resp
+	}
+	if n.log[req.PrevLogIndex].Term != req.PrevLogTerm {
+		resp.LastIndex = req.PrevLogIndex - 1
+		return resp
+	}
+
+	// Skip entries we already have and truncate on the first conflict
+	truncated := false
+	var newEntries []*LogEntry
+	for i, entry := range req.Entries {
+		if entry.Index <= n.lastIndex() {
+			if n.log[entry.Index].Term == entry.Term {
+				continue
+			}
+			n.log = n.log[:entry.Index]
+			truncated = true
+		}
+		newEntries = req.Entries[i:]
+		break
+	}
+
+	var err error
+	if truncated {
+		n.log = append(n.log, newEntries...)
+		err = n.store.rewriteLog(n.log[1:])
+	} else if len(newEntries) > 0 {
+		if err = n.store.appendEntries(newEntries); err == nil {
+			n.log = append(n.log, newEntries...)
+		}
+	}
+	if err != nil {
+		log.Printf("cluster: failed to persist log entries: %v", err)
+		resp.LastIndex = req.PrevLogIndex
+		return resp
+	}
+
+	// A delayed request may cover less of the log than is already committed,
+	// so the commit index only ever moves forward
+	lastNew := req.PrevLogIndex + uint64(len(req.Entries))
+	if commit := min(req.LeaderCommit, lastNew); commit > n.commitIndex {
+		n.commitIndex = commit
+		n.signal(n.applyCh)
+	}
+
+	resp.Success = true
+	resp.LastIndex = n.lastIndex()
+	return resp
+}
+
+// run drives elections on followers and candidates and heartbeats on the leader
+func (n *Node) run() {
+	defer n.wg.Done()
+
+	tick := n.config.HeartbeatInterval / 2
+	if tick < 10*time.Millisecond {
+		tick = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		replicate := false
+		select {
+		case <-n.stopCh:
+			return
+		case <-ticker.C:
+		case <-n.replicateCh:
+			replicate = true
+		}
+
+		n.mu.Lock()
+		switch n.state {
+		case StateLeader:
+			if replicate || time.Since(n.lastHeartbeat) >= n.config.HeartbeatInterval {
+				n.lastHeartbeat = time.Now()
+				n.broadcastAppend()
+			}
+		default:
+			if time.Now().After(n.electionDeadline) {
+				n.startElection()
+			}
+		}
+		n.mu.Unlock()
+	}
+}
+
+// startElection becomes a candidate and requests votes from all peers.
+// Must be called with n.mu held.
+func (n *Node) startElection() {
+	n.state = StateCandidate
+	n.currentTerm++
+	n.votedFor = n.config.NodeID
+	n.leaderID = ""
+	n.leaderAddr = ""
+	n.resetElectionDeadline()
+	if err := n.persistState(); err != nil {
+		log.Printf("cluster: failed to persist state: %v", err)
+		return
+	}
+
+	term := n.currentTerm
+	req := &VoteRequest{
+		Term:         term,
+		CandidateID:  n.config.NodeID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.log[n.lastIndex()].Term,
+	}
+
+	votes := 1
+	for id, addr := range n.config.Peers {
+		n.wg.Add(1)
+		go func(id, addr string) {
+			defer n.wg.Done()
+
+			var resp VoteResponse
+			if err := n.call(addr, VotePath, req, &resp); err != nil {
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			if resp.Term > n.currentTerm {
+				n.stepDown(resp.Term)
+				return
+			}
+			if n.stopped || n.state != StateCandidate || n.currentTerm != term || !resp.VoteGranted {
+				return
+			}
+
+			votes++
+			if votes >= n.quorum() {
+				n.becomeLeader()
+			}
+		}(id, addr)
+	}
+}
+
+// becomeLeader takes over leadership for the current term.
+// Must be called with n.mu held.
+func (n *Node) becomeLeader() {
+	n.state = StateLeader
+	n.leaderID = n.config.NodeID
+	n.leaderAddr = n.config.Address
+
+	for id := range n.config.Peers {
+		n.nextIndex[id] = n.lastIndex() + 1
+		n.matchIndex[id] = 0
+	}
+
+	// Entries from earlier terms can only be committed indirectly, so start
+	// the term with a no-op entry
+	noop := &LogEntry{Index: n.lastIndex() + 1, Term: n.currentTerm}
+	if err := n.store.appendEntries([]*LogEntry{noop}); err != nil {
+		log.Printf("cluster: failed to persist log entry: %v", err)
+		n.stepDown(n.currentTerm)
+		return
+	}
+	n.log = append(n.log, noop)
+	n.advanceCommitIndex()
+
+	log.Printf("cluster: node %s elected leader for term %d", n.config.NodeID, n.currentTerm)
+
+	n.lastHeartbeat = time.Now()
+	n.broadcastAppend()
+}
+
+// stepDown reverts to follower, adopting term if it is newer.
+// Must be called with n.mu held.
+func (n *Node) stepDown(term uint64) {
+	if term > n.currentTerm {
+		n.currentTerm = term
+		n.votedFor = ""
+		if err := n.persistState(); err != nil {
+			log.Printf("cluster: failed to persist state: %v", err)
+		}
+	}
+
+	if n.state == StateLeader {
+		n.failWaiters(ErrNotLeader)
+		n.leaderID = ""
+		n.leaderAddr = ""
+	}
+	n.state = StateFollower
+	n.resetElectionDeadline()
+}
+
+// broadcastAppend starts replication to every peer that has no request in flight.
+// Must be called with n.mu held.
+func (n *Node) broadcastAppend() {
+	for id, addr := range n.config.Peers {
+		if n.inflight[id] {
+			continue
+		}
+		n.inflight[id] = true
+		n.wg.Add(1)
+		go n.replicateTo(id, addr)
+	}
+}
+
+// replicateTo sends AppendEntries to a peer until it has caught up with the leader's log
+func (n *Node) replicateTo(id, addr string) {
+	defer n.wg.Done()
+	defer func() {
+		n.mu.Lock()
+		n.inflight[id] = false
+		n.mu.Unlock()
+	}()
+
+	for {
+		n.mu.Lock()
+		if n.stopped || n.state != StateLeader {
+			n.mu.Unlock()
+			return
+		}
+
+		next := n.nextIndex[id]
+		end := min(n.lastIndex()+1, next+uint64(n.config.MaxAppendEntries))
+		req := &AppendRequest{
+			Term:         n.currentTerm,
+			LeaderID:     n.config.NodeID,
+			LeaderAddr:   n.config.Address,
+			PrevLogIndex: next - 1,
+			PrevLogTerm:  n.log[next-1].Term,
+			Entries:      append([]*LogEntry(nil), n.log[next:end]...),
+			LeaderCommit: n.commitIndex,
+		}
+		term := n.currentTerm
+		n.mu.Unlock()
+
+		var resp AppendResponse
+		if err := n.call(addr, AppendPath, req, &resp); err != nil {
+			return
+		}
+
+		n.mu.Lock()
+		if resp.Term > n.currentTerm {
+			n.stepDown(resp.Term)
+			n.mu.Unlock()
+			return
+		}
+		if n.state != StateLeader || n.currentTerm != term {
+			n.mu.Unlock()
+			return
+		}
+
+		if resp.Success {
+			match := req.PrevLogIndex + uint64(len(req.Entries))
+			if match > n.matchIndex[id] {
+				n.matchIndex[id] = match
+			}
+			n.nextIndex[id] = n.matchIndex[id] + 1
+			n.advanceCommitIndex()
+		} else {
+			// Back off to just past the follower's log, at least one entry per round
+			n.nextIndex[id] = max(1, min(next-1, resp.LastIndex+1))
+		}
+
+		caughtUp := n.nextIndex[id] > n.lastIndex()
+		n.mu.Unlock()
+
+		if caughtUp {
+			return
+		}
+
+		select {
+		case <-n.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+// advanceCommitIndex commits the highest entry of the current term stored on a majority.
+// Must be called with n.mu held.
+func (n *Node) advanceCommitIndex() {
+	for idx := n.lastIndex(); idx > n.commitIndex; idx-- {
+		if n.log[idx].Term != n.currentTerm {
+			break
+		}
+
+		replicas := 1
+		for id := range n.config.Peers {
+			if n.matchIndex[id] >= idx {
+				replicas++
+			}
+		}
+
+		if replicas >= n.quorum() {
+			n.commitIndex = idx
+			n.signal(n.applyCh)
+			return
+		}
+	}
+}
+
+// applier applies committed entries to the FSM in log order
+func (n *Node) applier() {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		case <-n.applyCh:
+		}
+
+		for {
+			n.mu.Lock()
+			if n.lastApplied >= n.commitIndex {
+				n.mu.Unlock()
+				break
+			}
+			index := n.lastApplied + 1
+			entry := n.log[index]
+			n.mu.Unlock()
+
+			var err error
+			if entry.Command != nil && n.fsm != nil {
+				err = n.fsm.Apply(entry.Command)
+			}
+			snapshotted := n.snapshot(index, entry)
+
+			n.mu.Lock()
+			n.lastApplied = index
+			if snapshotted {
+				n.snapshotIndex = index
+				if err := n.persistState(); err != nil {
+					log.Printf("cluster: failed to persist snapshot index: %v", err)
+				}
+			}
+			if done, exists := n.waiters[index]; exists {
+				done <- err
+				delete(n.waiters, index)
+			}
+			n.mu.Unlock()
+		}
+	}
+}
+
+// snapshot snapshots the FSM after the entry at index was applied when the
+// entry can't be replayed or enough entries were applied since the last
+// snapshot, and reports whether it did
+func (n *Node) snapshot(index uint64, entry *LogEntry) bool {
+	snapshotter, ok := n.fsm.(Snapshotter)
+	if !ok {
+		return false
+	}
+
+	n.mu.Lock()
+	due := index-n.snapshotIndex >= uint64(n.config.SnapshotInterval)
+	n.mu.Unlock()
+	if !due && (entry.Command == nil || snapshotter.Replayable(entry.Command)) {
+		return false
+	}
+
+	if err := snapshotter.Snapshot(); err != nil {
+		log.Printf("cluster: failed to snapshot state: %v", err)
+		return false
+	}
+	return true
+}
+
+// call sends an RPC to a peer
+func (n *Node) call(addr, path string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(n.stopCtx, http.MethodPost, strings.TrimRight(addr, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(SecretHeader, n.config.Secret)
+
+	httpResp, err := n.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer %s returned status %d", addr, httpResp.StatusCode)
+	}
+
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// failWaiters fails all pending writes. Must be called with n.mu held.
+func (n *Node) failWaiters(err error) {
+	for index, done := range n.waiters {
+		done <- err
+		delete(n.waiters, index)
+	}
+}
+
+// persistState saves the current term and vote. Must be called with n.mu held.
+func (n *Node) persistState() error {
+	return n.store.saveState(&persistentState{CurrentTerm: n.currentTerm, VotedFor: n.votedFor, SnapshotIndex: n.snapshotIndex})
+}
+
+// resetElectionDeadline picks a new randomized election deadline. Must be called with n.mu held.
+func (n *Node) resetElectionDeadline() {
+	timeout := n.config.ElectionTimeout + time.Duration(rand.Int63n(int64(n.config.ElectionTimeout)))
+	n.electionDeadline = time.Now().Add(timeout)
+}
+
+// lastIndex returns the index of the last log entry. Must be called with n.mu held.
+func (n *Node) lastIndex() uint64 {
+	return uint64(len(n.log) - 1)
+}
+
+// quorum returns the number of nodes forming a majority
+func (n *Node) quorum() int {
+	return (len(n.config.Peers)+1)/2 + 1
+}
+
+// signal performs a non-blocking notification on ch
+func (n *Node) signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,388 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingFSM records applied commands
+type recordingFSM struct {
+	mu       sync.Mutex
+	commands []string
+}
+
+func (f *recordingFSM) Apply(command []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, string(command))
+	return nil
+}
+
+func (f *recordingFSM) applied() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+type testNode struct {
+	node   *Node
+	fsm    *recordingFSM
+	server *httptest.Server
+}
+
+// newTestCluster starts size nodes talking to each other over httptest servers
+func newTestCluster(t *testing.T, size int) []*testNode {
+	t.Helper()
+
+	nodes := make([]*testNode, size)
+	for i := range nodes {
+		tn := &testNode{fsm: &recordingFSM{}}
+		mux := http.NewServeMux()
+		mux.HandleFunc(VotePath, func(w http.ResponseWriter, r *http.Request) {
+			if !tn.node.Authenticate(r.Header.Get(SecretHeader)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var req VoteRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(tn.node.RequestVote(&req))
+		})
+		mux.HandleFunc(AppendPath, func(w http.ResponseWriter, r *http.Request) {
+			if !tn.node.Authenticate(r.Header.Get(SecretHeader)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var req AppendRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(tn.node.AppendEntries(&req))
+		})
+		tn.server = httptest.NewServer(mux)
+		nodes[i] = tn
+	}
+
+	for i, tn := range nodes {
+		config := DefaultConfig()
+		config.NodeID = fmt.Sprintf("node-%d", i)
+		config.Address = tn.server.URL
+		config.DataDir = t.TempDir()
+		config.ElectionTimeout = 300 * time.Millisecond
+		config.HeartbeatInterval = 50 * time.Millisecond
+		config.Secret = "test-cluster-secret"
+		for j, peer := range nodes {
+			if j != i {
+				config.Peers[fmt.Sprintf("node-%d", j)] = peer.server.URL
+			}
+		}
+
+		node, err := NewNode(config, tn.fsm)
+		if err != nil {
+			t.Fatalf("Failed to create node: %v", err)
+		}
+		tn.node = node
+	}
+
+	for _, tn := range nodes {
+		tn.node.Start()
+	}
+
+	t.Cleanup(func() {
+		for _, tn := range nodes {
+			tn.server.Close()
+			tn.node.Stop()
+		}
+	})
+
+	return nodes
+}
+
+func waitForLeader(t *testing.T, nodes []*testNode) *testNode {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, tn := range nodes {
+			if tn.node.IsLeader() {
+				return tn
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Fatal("No leader elected")
+	return nil
+}
+
+func TestClusterElectsLeaderAndReplicates(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	leader := waitForLeader(t, nodes)
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := leader.node.Apply(ctx, []byte(fmt.Sprintf("cmd-%d", i))); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+
+	// Followers must reject writes and know the leader
+	for _, tn := range nodes {
+		if tn == leader {
+			continue
+		}
+		if err := tn.node.Apply(ctx, []byte("rejected")); err != ErrNotLeader {
+			t.Errorf("Expected ErrNotLeader from follower, got %v", err)
+		}
+	}
+
+	// Every node eventually applies the same commands in the same order
+	deadline := time.Now().Add(5 * time.Second)
+	for _, tn := range nodes {
+		for len(tn.fsm.applied()) < 5 && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		applied := tn.fsm.applied()
+		if len(applied) != 5 {
+			t.Fatalf("Node %s applied %d commands, expected 5", tn.node.config.NodeID, len(applied))
+		}
+		for i, cmd := range applied {
+			if cmd != fmt.Sprintf("cmd-%d", i) {
+				t.Errorf("Node %s applied %q at position %d", tn.node.config.NodeID, cmd, i)
+			}
+		}
+		if leaderID, _ := tn.node.Leader(); leaderID != leader.node.config.NodeID {
+			t.Errorf("Node %s reports leader %q, expected %q", tn.node.config.NodeID, leaderID, leader.node.config.NodeID)
+		}
+	}
+}
+
+func TestClusterFailover(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	leader := waitForLeader(t, nodes)
+
+	if err := leader.node.Apply(context.Background(), []byte("before")); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// Isolate the leader; the remaining majority must elect a new one
+	leader.server.Close()
+	leader.node.Stop()
+
+	var remaining []*testNode
+	for _, tn := range nodes {
+		if tn != leader {
+			remaining = append(remaining, tn)
+		}
+	}
+
+	newLeader := waitForLeader(t, remaining)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := newLeader.node.Apply(ctx, []byte("after")); err != nil {
+		t.Fatalf("Apply on new leader failed: %v", err)
+	}
+
+	applied := newLeader.fsm.applied()
+	if len(applied) != 2 || applied[0] != "before" || applied[1] != "after" {
+		t.Errorf("Unexpected applied commands on new leader: %v", applied)
+	}
+}
+
+func TestNodeRestoresLog(t *testing.T) {
+	config := DefaultConfig()
+	config.NodeID = "solo"
+	config.Address = "http://localhost:0"
+	config.DataDir = t.TempDir()
+
+	fsm := &recordingFSM{}
+	node, err := NewNode(config, fsm)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	node.Start()
+
+	for _, cmd := range []string{"a", "b"} {
+		if err := node.Apply(context.Background(), []byte(cmd)); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	}
+	node.Stop()
+
+	// A restarted node replays its log
+	fsm = &recordingFSM{}
+	node, err = NewNode(config, fsm)
+	if err != nil {
+		t.Fatalf("Failed to reopen node: %v", err)
+	}
+	node.Start()
+	defer node.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(fsm.applied()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if applied := fsm.applied(); len(applied) != 2 || applied[0] != "a" || applied[1] != "b" {
+		t.Errorf("Unexpected replayed commands: %v", applied)
+	}
+}
+
+func TestNodeRefusesUnknownPeers(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	leader := waitForLeader(t, nodes)
+	if err := leader.node.Apply(context.Background(), []byte("sound")); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	var follower *testNode
+	for _, tn := range nodes {
+		if tn != leader {
+			follower = tn
+			break
+		}
+	}
+	status := follower.node.Status()
+	term, last := status.Term, status.LastLogIndex
+
+	// A higher term from a node outside the peers neither wins a vote nor
+	// appends entries
+	vote := follower.node.RequestVote(&VoteRequest{Term: term + 10, CandidateID: "intruder", LastLogIndex: 100, LastLogTerm: term + 10})
+	if vote.VoteGranted {
+		t.Error("Vote granted to an unknown candidate")
+	}
+	appended := follower.node.AppendEntries(&AppendRequest{
+		Term:         term + 10,
+		LeaderID:     "intruder",
+		PrevLogIndex: last,
+		PrevLogTerm:  term,
+		Entries:      []*LogEntry{{Index: last + 1, Term: term + 10, Command: []byte("injected")}},
+		LeaderCommit: last + 1,
+	})
+	if appended.Success {
+		t.Error("Entries accepted from an unknown leader")
+	}
+	if status = follower.node.Status(); status.Term != term || status.LeaderID != leader.node.config.NodeID {
+		t.Errorf("Unknown node changed the term to %d and the leader to %q", status.Term, status.LeaderID)
+	}
+	for _, cmd := range follower.fsm.applied() {
+		if cmd == "injected" {
+			t.Fatal("Entry of an unknown leader was applied")
+		}
+	}
+
+	// RPCs must carry the secret
+	if follower.node.Authenticate("") || follower.node.Authenticate("wrong-secret") {
+		t.Error("RPC authenticated without the cluster secret")
+	}
+	if !follower.node.Authenticate("test-cluster-secret") {
+		t.Error("RPC with the cluster secret refused")
+	}
+	resp, err := http.Post(follower.server.URL+AppendPath, "application/json", nil)
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("RPC without the secret answered %d", resp.StatusCode)
+	}
+}
+
+func TestNodeRequiresSecretWithPeers(t *testing.T) {
+	config := DefaultConfig()
+	config.NodeID = "n1"
+	config.Address = "http://localhost:0"
+	config.DataDir = t.TempDir()
+	config.Peers["n2"] = "http://localhost:1"
+	if _, err := NewNode(config, &recordingFSM{}); err == nil {
+		t.Fatal("Expected a node with peers and no secret to be refused")
+	}
+}
+
+func TestAppendEntriesNeverLowersCommitIndex(t *testing.T) {
+	config := DefaultConfig()
+	config.NodeID = "n1"
+	config.Address = "http://localhost:0"
+	config.DataDir = t.TempDir()
+	config.Peers["n2"] = "http://localhost:1"
+	config.Secret = "test-cluster-secret"
+	node, err := NewNode(config, &recordingFSM{})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	defer node.Stop()
+
+	entries := []*LogEntry{{Index: 1, Term: 1}, {Index: 2, Term: 1}, {Index: 3, Term: 1}}
+	if resp := node.AppendEntries(&AppendRequest{Term: 1, LeaderID: "n2", Entries: entries, LeaderCommit: 3}); !resp.Success {
+		t.Fatal("AppendEntries failed")
+	}
+	if commit := node.Status().CommitIndex; commit != 3 {
+		t.Fatalf("Commit index is %d, expected 3", commit)
+	}
+
+	// A delayed heartbeat covering only the first entry, sent after the
+	// leader committed further
+	if resp := node.AppendEntries(&AppendRequest{Term: 1, LeaderID: "n2", PrevLogIndex: 1, PrevLogTerm: 1, LeaderCommit: 5}); !resp.Success {
+		t.Fatal("AppendEntries failed")
+	}
+	if commit := node.Status().CommitIndex; commit != 3 {
+		t.Errorf("Commit index moved from 3 to %d", commit)
+	}
+}
+
+func TestStopAbortsPendingRPCs(t *testing.T) {
+	// A peer that doesn't answer until the node gives up on it
+	requested := make(chan struct{}, 1)
+	aborted := make(chan struct{}, 1)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		<-r.Context().Done()
+		select {
+		case aborted <- struct{}{}:
+		default:
+		}
+	}))
+	defer peer.Close()
+
+	config := DefaultConfig()
+	config.NodeID = "n1"
+	config.Address = "http://localhost:0"
+	config.DataDir = t.TempDir()
+	config.Peers["n2"] = peer.URL
+	config.Secret = "test-cluster-secret"
+	config.ElectionTimeout = 50 * time.Millisecond
+	config.HeartbeatInterval = 10 * time.Millisecond
+	config.RPCTimeout = time.Minute
+	node, err := NewNode(config, &recordingFSM{})
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	node.Start()
+
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No vote requested")
+	}
+
+	// Stop waits for the vote request, which it aborts
+	start := time.Now()
+	if err := node.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Stop took %v", elapsed)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Error("The vote request was not aborted")
+	}
+}
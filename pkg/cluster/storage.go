@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	stateFileName = "raft-state.json"
+	logFileName   = "raft-log.jsonl"
+)
+
+// persistentState is the Raft state that must survive restarts
+type persistentState struct {
+	CurrentTerm uint64 `json:"current_term"`
+	VotedFor    string `json:"voted_for"`
+	// Last entry covered by an FSM snapshot
+	SnapshotIndex uint64 `json:"snapshot_index,omitempty"`
+}
+
+// storage persists the Raft term, vote and log under the cluster data directory.
+// The log is an append-only JSON lines file; it is rewritten only when a
+// follower has to truncate conflicting entries.
+type storage struct {
+	dir     string
+	log     *os.File
+	lastErr error // Result of the last write, reported by health checks
+}
+
+// openStorage opens (or creates) the Raft storage in dir
+func openStorage(dir string) (*storage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cluster directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open raft log: %w", err)
+	}
+
+	return &storage{dir: dir, log: f}, nil
+}
+
+// loadState reads the persisted term and vote
+func (s *storage) loadState() (*persistentState, error) {
+	state := &persistentState{}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, stateFileName))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read raft state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse raft state: %w", err)
+	}
+	return state, nil
+}
+
+// saveState persists the term and vote atomically
+func (s *storage) saveState(state *persistentState) (err error) {
+	defer func() { s.lastErr = err }()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, stateFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadLog reads all persisted log entries
+func (s *storage) loadLog() ([]*LogEntry, error) {
+	if _, err := s.log.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	var entries []*LogEntry
+	scanner := bufio.NewScanner(s.log)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn final write is discarded; the leader re-sends it
+			break
+		}
+		entries = append(entries, &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read raft log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// appendEntries appends entries to the log file and syncs it
+func (s *storage) appendEntries(entries []*LogEntry) (err error) {
+	defer func() { s.lastErr = err }()
+
+	w := bufio.NewWriter(s.log)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// rewriteLog replaces the log file with the given entries
+func (s *storage) rewriteLog(entries []*LogEntry) (err error) {
+	defer func() { s.lastErr = err }()
+
+	path := filepath.Join(s.dir, logFileName)
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	s.log.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	s.log, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	return err
+}
+
+// close closes the log file
+func (s *storage) close() error {
+	return s.log.Close()
+}
@@ -0,0 +1,149 @@
+package cluster
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotLeader is returned when a write is submitted to a node that is not the current leader
+var ErrNotLeader = errors.New("node is not the cluster leader")
+
+// Config represents clustering configuration
+type Config struct {
+	NodeID            string            `json:"node_id"`
+	Address           string            `json:"address"` // Base URL peers and clients use to reach this node
+	Peers             map[string]string `json:"peers"`   // Node ID -> base URL, excluding this node
+	Secret            string            `json:"-"`       // Shared by the members, sent with every RPC
+	DataDir           string            `json:"data_dir"`
+	ElectionTimeout   time.Duration     `json:"election_timeout"`
+	HeartbeatInterval time.Duration     `json:"heartbeat_interval"`
+	RPCTimeout        time.Duration     `json:"rpc_timeout"`
+	MaxAppendEntries  int               `json:"max_append_entries"`
+	SnapshotInterval  int               `json:"snapshot_interval"` // Applied entries between snapshots of an FSM that supports them
+}
+
+// DefaultConfig returns a default clustering configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Peers:             make(map[string]string),
+		ElectionTimeout:   1 * time.Second,
+		HeartbeatInterval: 150 * time.Millisecond,
+		RPCTimeout:        500 * time.Millisecond,
+		MaxAppendEntries:  256,
+		SnapshotInterval:  1024,
+	}
+}
+
+// NodeState represents the Raft role of a node
+type NodeState int
+
+const (
+	StateFollower NodeState = iota
+	StateCandidate
+	StateLeader
+)
+
+// String returns the string representation of the node state
+func (s NodeState) String() string {
+	switch s {
+	case StateFollower:
+		return "follower"
+	case StateCandidate:
+		return "candidate"
+	case StateLeader:
+		return "leader"
+	default:
+		return "unknown"
+	}
+}
+
+// LogEntry represents a replicated log entry
+type LogEntry struct {
+	Index   uint64 `json:"index"`
+	Term    uint64 `json:"term"`
+	Command []byte `json:"command"`
+}
+
+// VoteRequest is the RequestVote RPC payload
+type VoteRequest struct {
+	Term         uint64 `json:"term"`
+	CandidateID  string `json:"candidate_id"`
+	LastLogIndex uint64 `json:"last_log_index"`
+	LastLogTerm  uint64 `json:"last_log_term"`
+}
+
+// VoteResponse is the RequestVote RPC result
+type VoteResponse struct {
+	Term        uint64 `json:"term"`
+	VoteGranted bool   `json:"vote_granted"`
+}
+
+// AppendRequest is the AppendEntries RPC payload (also used as heartbeat)
+type AppendRequest struct {
+	Term         uint64      `json:"term"`
+	LeaderID     string      `json:"leader_id"`
+	LeaderAddr   string      `json:"leader_addr"`
+	PrevLogIndex uint64      `json:"prev_log_index"`
+	PrevLogTerm  uint64      `json:"prev_log_term"`
+	Entries      []*LogEntry `json:"entries,omitempty"`
+	LeaderCommit uint64      `json:"leader_commit"`
+}
+
+// AppendResponse is the AppendEntries RPC result
+type AppendResponse struct {
+	Term    uint64 `json:"term"`
+	Success bool   `json:"success"`
+	// LastIndex is the follower's last log index, used by the leader to
+	// back off nextIndex quickly after a rejection
+	LastIndex uint64 `json:"last_index"`
+}
+
+// PeerStatus represents the replication state of a peer as seen by the leader
+type PeerStatus struct {
+	ID         string `json:"id"`
+	Address    string `json:"address"`
+	MatchIndex uint64 `json:"match_index"`
+	NextIndex  uint64 `json:"next_index"`
+}
+
+// Status represents the cluster state as seen by a node
+type Status struct {
+	NodeID        string        `json:"node_id"`
+	Address       string        `json:"address"`
+	State         string        `json:"state"`
+	Term          uint64        `json:"term"`
+	LeaderID      string        `json:"leader_id"`
+	LeaderAddress string        `json:"leader_address"`
+	CommitIndex   uint64        `json:"commit_index"`
+	AppliedIndex  uint64        `json:"applied_index"`
+	SnapshotIndex uint64        `json:"snapshot_index"`
+	LastLogIndex  uint64        `json:"last_log_index"`
+	Peers         []*PeerStatus `json:"peers"`
+}
+
+// FSM is the state machine replicated log entries are applied to.
+// Apply must be deterministic: every node applies the same commands in the same order.
+type FSM interface {
+	Apply(command []byte) error
+}
+
+// Snapshotter is implemented by an FSM that can persist its state. The node
+// records the last entry a snapshot covers and, on restart, only applies the
+// entries after it again.
+type Snapshotter interface {
+	// Snapshot persists the effects of every command applied so far
+	Snapshot() error
+	// Replayable reports whether command leaves the same state when applied
+	// again on top of its own effects. The node snapshots right after
+	// applying a command that doesn't.
+	Replayable(command []byte) bool
+}
+
+// RPC endpoint paths, relative to a node's base URL
+const (
+	VotePath   = "/cluster/raft/vote"
+	AppendPath = "/cluster/raft/append"
+)
+
+// SecretHeader carries the cluster secret on RPCs between members
+const SecretHeader = "X-Cluster-Secret"
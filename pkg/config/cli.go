@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -104,60 +105,99 @@ func (cli *CLIManager) ShowConfig(configPath string, format string) error {
 	return nil
 }
 
-// CompareConfigs compares two configuration files
-func (cli *CLIManager) CompareConfigs(config1Path, config2Path string) error {
+// CompareConfigs shows the settings that differ between two configuration
+// files, and whether a server running with the first applies them on reload
+func (cli *CLIManager) CompareConfigs(config1Path, config2Path, format string) error {
 	cfg1, err := LoadConfigFromFile(config1Path)
 	if err != nil {
 		return fmt.Errorf("failed to load config1: %w", err)
 	}
-
 	cfg2, err := LoadConfigFromFile(config2Path)
 	if err != nil {
 		return fmt.Errorf("failed to load config2: %w", err)
 	}
 
-	// Convert to YAML for comparison
-	data1, _ := yaml.Marshal(cfg1)
-	data2, _ := yaml.Marshal(cfg2)
-
-	if string(data1) == string(data2) {
-		fmt.Println("✅ Configurations are identical")
-		return nil
+	view1, err := cfg1.View(true)
+	if err != nil {
+		return err
 	}
-
-	fmt.Printf("📊 Configuration differences between %s and %s:\n\n", config1Path, config2Path)
-
-	// Simple line-by-line comparison
-	lines1 := strings.Split(string(data1), "\n")
-	lines2 := strings.Split(string(data2), "\n")
-
-	maxLines := len(lines1)
-	if len(lines2) > maxLines {
-		maxLines = len(lines2)
+	view2, err := cfg2.View(true)
+	if err != nil {
+		return err
 	}
+	return cli.printChanges(filepath.Base(config1Path), filepath.Base(config2Path), DiffViews(view1, view2), format)
+}
 
-	for i := 0; i < maxLines; i++ {
-		line1 := ""
-		line2 := ""
+// CompareWithServer shows the settings of a configuration file that differ
+// from those of a running server, given as its GET /config view, and whether
+// the server applies them on reload. Sections the view leaves out, as the
+// public view does, are not compared.
+func (cli *CLIManager) CompareWithServer(configPath, server string, live map[string]interface{}, format string) error {
+	cfg, err := LoadConfigFromFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	view, err := cfg.View(true)
+	if err != nil {
+		return err
+	}
+	for section := range view {
+		if _, ok := live[section]; !ok {
+			delete(view, section)
+		}
+	}
+	return cli.printChanges(server, filepath.Base(configPath), DiffViews(live, view), format)
+}
 
-		if i < len(lines1) {
-			line1 = lines1[i]
+// printChanges prints the settings that differ from one configuration to
+// another
+func (cli *CLIManager) printChanges(from, to string, changes []SettingChange, format string) error {
+	switch format {
+	case "json":
+		if changes == nil {
+			changes = []SettingChange{}
 		}
-		if i < len(lines2) {
-			line2 = lines2[i]
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
 		}
+		fmt.Println(string(data))
 
-		if line1 != line2 {
-			fmt.Printf("Line %d:\n", i+1)
-			fmt.Printf("  %s: %s\n", filepath.Base(config1Path), line1)
-			fmt.Printf("  %s: %s\n", filepath.Base(config2Path), line2)
-			fmt.Println()
+	case "table":
+		if len(changes) == 0 {
+			fmt.Println("✅ Configurations are identical")
+			return nil
 		}
-	}
+		fmt.Printf("📊 %d settings differ from %s to %s:\n\n", len(changes), from, to)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SETTING\tFROM\tTO\tON RELOAD")
+		for _, change := range changes {
+			onReload := "restart required"
+			if change.Reloadable {
+				onReload = "applied"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Path, settingValue(change.From), settingValue(change.To), onReload)
+		}
+		w.Flush()
 
+	default:
+		return fmt.Errorf("unsupported format: %s (supported: table, json)", format)
+	}
 	return nil
 }
 
+// settingValue renders a value of a configuration view
+func settingValue(value interface{}) string {
+	if value == nil {
+		return "(unset)"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
 // ListEnvVars lists all supported environment variables
 func (cli *CLIManager) ListEnvVars(prefix string) {
 	fmt.Printf("🌍 Environment Variables (prefix: %s)\n\n", prefix)
@@ -178,6 +218,7 @@ func (cli *CLIManager) ListEnvVars(prefix string) {
 	fmt.Fprintf(w, "%sSTORAGE_ENGINE\tStorage engine type\tfile\n", prefix)
 	fmt.Fprintf(w, "%sSTORAGE_PAGE_SIZE\tStorage page size\t4096\n", prefix)
 	fmt.Fprintf(w, "%sSTORAGE_CACHE_SIZE\tStorage cache size\t1000\n", prefix)
+	fmt.Fprintf(w, "%sSTORAGE_TTL_CHECK_INTERVAL\tExpired vector cleanup interval\t1m\n", prefix)
 	fmt.Fprintf(w, "%sSTORAGE_SYNC_WRITES\tSync writes to disk\ttrue\n", prefix)
 
 	// Search configuration
@@ -196,6 +237,10 @@ func (cli *CLIManager) ListEnvVars(prefix string) {
 	fmt.Fprintf(w, "%sPERF_ENABLE_SIMD\tEnable SIMD optimizations\ttrue\n", prefix)
 	fmt.Fprintf(w, "%sPERF_IO_USE_MEMORY_MAP\tUse memory-mapped I/O\ttrue\n", prefix)
 
+	// Auth configuration
+	fmt.Fprintf(w, "%sAUTH_ENABLED\tRequire API keys\tfalse\n", prefix)
+	fmt.Fprintf(w, "%sAUTH_KEYS_FILE\tFile for keys created via /auth/keys\t<data_dir>/auth_keys.json\n", prefix)
+
 	// Logging configuration
 	fmt.Fprintf(w, "%sLOG_LEVEL\tLogging level\tinfo\n", prefix)
 	fmt.Fprintf(w, "%sLOG_FORMAT\tLogging format\ttext\n", prefix)
@@ -203,6 +248,7 @@ func (cli *CLIManager) ListEnvVars(prefix string) {
 
 	// Data directory
 	fmt.Fprintf(w, "%sDATA_DIR\tData directory path\tdata\n", prefix)
+	fmt.Fprintf(w, "%sREAD_ONLY\tShare a data directory another process writes\tfalse\n", prefix)
 
 	w.Flush()
 
@@ -255,18 +301,30 @@ func (cli *CLIManager) printConfigTable(config *VittoriaConfig) {
 	fmt.Fprintf(w, "Server\tPort\t%d\n", config.Server.Port)
 	fmt.Fprintf(w, "Server\tCORS\t%t\n", config.Server.CORS)
 	fmt.Fprintf(w, "Server\tTLS Enabled\t%t\n", config.Server.TLS.Enabled)
+	fmt.Fprintf(w, "Server\tHTTP/2\t%t\n", config.Server.HTTP2.Enabled)
+	fmt.Fprintf(w, "Server\tKeep-Alive\t%t\n", config.Server.KeepAlive)
+	fmt.Fprintf(w, "Server\tRate Limit\t%t\n", config.Server.RateLimit.Enabled)
+	fmt.Fprintf(w, "Server\tConcurrency Limit\t%t\n", config.Server.Concurrency.Enabled)
 
 	// Storage settings
 	fmt.Fprintf(w, "Storage\tEngine\t%s\n", config.Storage.Engine)
 	fmt.Fprintf(w, "Storage\tPage Size\t%d\n", config.Storage.PageSize)
 	fmt.Fprintf(w, "Storage\tCache Size\t%d\n", config.Storage.CacheSize)
 	fmt.Fprintf(w, "Storage\tSync Writes\t%t\n", config.Storage.SyncWrites)
+	fmt.Fprintf(w, "Storage\tTTL Check Interval\t%s\n", config.Storage.TTLCheckInterval)
+	fmt.Fprintf(w, "Storage\tTrash Retention\t%s\n", config.Storage.TrashRetention)
+	fmt.Fprintf(w, "Storage\tRecover Corrupted\t%t\n", config.Storage.RecoverCorrupted)
+
+	// Limits
+	fmt.Fprintf(w, "Limits\tMax Dimensions\t%d\n", config.Limits.MaxDimensions)
+	fmt.Fprintf(w, "Limits\tMax Collections\t%d\n", config.Limits.MaxCollections)
 
 	// Search settings
 	fmt.Fprintf(w, "Search\tParallel Enabled\t%t\n", config.Search.Parallel.Enabled)
 	fmt.Fprintf(w, "Search\tMax Workers\t%d\n", config.Search.Parallel.MaxWorkers)
 	fmt.Fprintf(w, "Search\tCache Enabled\t%t\n", config.Search.Cache.Enabled)
 	fmt.Fprintf(w, "Search\tCache Max Entries\t%d\n", config.Search.Cache.MaxEntries)
+	fmt.Fprintf(w, "Search\tAuto Rebuild Ratio\t%g\n", config.Search.Index.AutoRebuild.DeletedRatio)
 
 	// Embeddings settings
 	fmt.Fprintf(w, "Embeddings\tDefault Type\t%s\n", config.Embeddings.Default.Type)
@@ -285,6 +343,20 @@ func (cli *CLIManager) printConfigTable(config *VittoriaConfig) {
 	fmt.Fprintf(w, "Logging\tFormat\t%s\n", config.Logging.Format)
 	fmt.Fprintf(w, "Logging\tOutput\t%s\n", config.Logging.Output)
 
+	// Cluster settings
+	fmt.Fprintf(w, "Cluster\tEnabled\t%t\n", config.Cluster.Enabled)
+	if config.Cluster.Enabled {
+		fmt.Fprintf(w, "Cluster\tNode ID\t%s\n", config.Cluster.NodeID)
+		fmt.Fprintf(w, "Cluster\tAdvertise\t%s\n", config.Cluster.Advertise)
+		fmt.Fprintf(w, "Cluster\tPeers\t%d\n", len(config.Cluster.Peers))
+	}
+
+	// Auth settings
+	fmt.Fprintf(w, "Auth\tEnabled\t%t\n", config.Auth.Enabled)
+	if config.Auth.Enabled {
+		fmt.Fprintf(w, "Auth\tConfigured Keys\t%d\n", len(config.Auth.Keys))
+	}
+
 	// General settings
 	fmt.Fprintf(w, "General\tData Directory\t%s\n", config.DataDir)
 	fmt.Fprintf(w, "General\tVersion\t%s\n", config.Version)
@@ -310,6 +382,27 @@ server:
     enabled: ` + fmt.Sprintf("%t", config.Server.TLS.Enabled) + `           # Enable TLS/HTTPS
     cert_file: ""             # Path to TLS certificate file
     key_file: ""              # Path to TLS private key file
+  idle_timeout: ` + config.Server.IdleTimeout.String() + `        # How long an idle kept-alive connection stays open
+  keep_alive: ` + fmt.Sprintf("%t", config.Server.KeepAlive) + `            # Serve more than one request per connection
+  http2:
+    enabled: ` + fmt.Sprintf("%t", config.Server.HTTP2.Enabled) + `           # HTTP/2 over TLS and cleartext (h2c); Arrow Flight needs it
+    max_concurrent_streams: ` + fmt.Sprintf("%d", config.Server.HTTP2.MaxConcurrentStreams) + ` # Requests in flight per connection
+    ping_interval: ` + config.Server.HTTP2.PingInterval.String() + `        # Ping quiet connections to close dead ones (0s = never)
+  rate_limit:
+    enabled: ` + fmt.Sprintf("%t", config.Server.RateLimit.Enabled) + `           # Throttle clients with HTTP 429
+    per_key:
+      requests_per_second: ` + fmt.Sprintf("%d", config.Server.RateLimit.PerKey.RequestsPerSecond) + `  # Sustained rate per API key (0 = unlimited)
+      burst_size: ` + fmt.Sprintf("%d", config.Server.RateLimit.PerKey.BurstSize) + `           # Burst allowed per API key
+    per_ip:
+      requests_per_second: ` + fmt.Sprintf("%d", config.Server.RateLimit.PerIP.RequestsPerSecond) + `   # Sustained rate per client IP (0 = unlimited)
+      burst_size: ` + fmt.Sprintf("%d", config.Server.RateLimit.PerIP.BurstSize) + `           # Burst allowed per client IP
+    trust_proxy: false        # Take client IPs from X-Forwarded-For
+  concurrency:
+    enabled: ` + fmt.Sprintf("%t", config.Server.Concurrency.Enabled) + `           # Queue requests past max_requests, interactive ones first
+    max_requests: ` + fmt.Sprintf("%d", config.Server.Concurrency.MaxRequests) + `           # Requests served at once (0 = performance.max_concurrency)
+    reserved_interactive: ` + fmt.Sprintf("%d", config.Server.Concurrency.ReservedInteractive) + `   # Slots batch requests never take
+    queue_timeout: ` + config.Server.Concurrency.QueueTimeout.String() + `        # Wait for a slot before HTTP 503
+  route_timeouts: []          # Per-route overrides, e.g. {path: "/collections/{name}/documents", write_timeout: 30m}
 
 # Storage Configuration
 storage:
@@ -323,6 +416,12 @@ storage:
     sync_interval: ` + config.Storage.WAL.SyncInterval.String() + `   # WAL sync interval
     max_size: ` + fmt.Sprintf("%d", config.Storage.WAL.MaxSize) + `        # Maximum WAL file size (bytes)
     checkpoint_age: ` + config.Storage.WAL.CheckpointAge.String() + ` # WAL checkpoint age
+  ttl_check_interval: ` + config.Storage.TTLCheckInterval.String() + `   # Expired vector cleanup interval (0 disables)
+  trash_retention: ` + config.Storage.TrashRetention.String() + `     # How long dropped collections can be restored (0 disables the trash)
+  recover_corrupted: ` + fmt.Sprintf("%t", config.Storage.RecoverCorrupted) + `     # Load the intact segments of damaged vector files instead of failing
+  backup:
+    directory: "` + config.Storage.Backup.Directory + `"     # Where maintenance backup jobs write (relative to data_dir, or s3:// or gs://)
+    retention: ` + fmt.Sprintf("%d", config.Storage.Backup.Retention) + `             # Backup archives kept (0 keeps all)
 
 # Search Configuration
 search:
@@ -330,6 +429,7 @@ search:
     enabled: ` + fmt.Sprintf("%t", config.Search.Parallel.Enabled) + `        # Enable parallel search processing
     max_workers: ` + fmt.Sprintf("%d", config.Search.Parallel.MaxWorkers) + `        # Maximum parallel workers
     batch_size: ` + fmt.Sprintf("%d", config.Search.Parallel.BatchSize) + `         # Batch size for parallel processing
+    min_vectors_for_parallel: ` + fmt.Sprintf("%d", config.Search.Parallel.MinVectorsForParallel) + ` # Scan smaller collections on one goroutine
     use_cache: ` + fmt.Sprintf("%t", config.Search.Parallel.UseCache) + `          # Use search result caching
     preload_vectors: ` + fmt.Sprintf("%t", config.Search.Parallel.PreloadVectors) + ` # Preload vectors into memory
   cache:
@@ -340,6 +440,9 @@ search:
   index:
     default_type: "` + config.Search.Index.DefaultType + `"   # Default index type (flat, hnsw, ivf)
     default_metric: "` + config.Search.Index.DefaultMetric + `" # Default distance metric (cosine, euclidean)
+    auto_rebuild:
+      deleted_ratio: ` + fmt.Sprintf("%g", config.Search.Index.AutoRebuild.DeletedRatio) + `     # Rebuild an index once this share of it was deleted (0 = never)
+      min_vectors: ` + fmt.Sprintf("%d", config.Search.Index.AutoRebuild.MinVectors) + `      # Smallest index rebuilt automatically
   default_limit: ` + fmt.Sprintf("%d", config.Search.DefaultLimit) + `          # Default search result limit
   max_limit: ` + fmt.Sprintf("%d", config.Search.MaxLimit) + `             # Maximum search result limit
 
@@ -349,6 +452,11 @@ embeddings:
     type: "` + config.Embeddings.Default.Type + `"  # Default vectorizer type
     model: "` + config.Embeddings.Default.Model + `"    # Default model name
     dimensions: ` + fmt.Sprintf("%d", config.Embeddings.Default.Dimensions) + `           # Vector dimensions
+  # profiles:                                # Vectorizers collections name with vectorizer_profile
+  #   openai-large:
+  #     type: "openai"
+  #     model: "text-embedding-3-large"
+  #     api_key: "sk-..."
   batch:
     enabled: ` + fmt.Sprintf("%t", config.Embeddings.Batch.Enabled) + `           # Enable batch processing
     default_batch_size: ` + fmt.Sprintf("%d", config.Embeddings.Batch.DefaultBatchSize) + `  # Default batch size
@@ -359,6 +467,10 @@ embeddings:
     chunk_size: ` + fmt.Sprintf("%d", config.Embeddings.Processing.ChunkSize) + `          # Text chunk size
     chunk_overlap: ` + fmt.Sprintf("%d", config.Embeddings.Processing.ChunkOverlap) + `       # Text chunk overlap
     strategy: "` + config.Embeddings.Processing.Strategy + `"        # Chunking strategy (smart, sentence, paragraph)
+    ocr:
+      enabled: ` + fmt.Sprintf("%t", config.Embeddings.Processing.OCR.Enabled) + `         # Recognize PDF pages without extractable text
+      url: "` + config.Embeddings.Processing.OCR.URL + `"              # OCR service URL (empty uses tesseract)
+      language: "` + config.Embeddings.Processing.OCR.Language + `"       # Tesseract language codes
 
 # Performance Configuration
 performance:
@@ -373,7 +485,59 @@ performance:
   cpu:
     enable_simd: ` + fmt.Sprintf("%t", config.Performance.CPU.EnableSIMD) + `       # Enable CPU SIMD instructions
     vectorized_math: ` + fmt.Sprintf("%t", config.Performance.CPU.VectorizedMath) + `  # Enable vectorized math operations
-    num_threads: ` + fmt.Sprintf("%d", config.Performance.CPU.NumThreads) + `        # Number of CPU threads to use
+    num_threads: ` + fmt.Sprintf("%d", config.Performance.CPU.NumThreads) + `        # Threads used to build HNSW indexes
+
+# Authentication Configuration
+auth:
+  enabled: ` + fmt.Sprintf("%t", config.Auth.Enabled) + `            # Require an API key on every request
+  keys_file: ""             # Keys created via /auth/keys (default: <data_dir>/auth_keys.json)
+  usage_file: ""            # Per-key usage for /admin/usage (default: <data_dir>/auth_usage.json)
+  keys: []                  # Keys with name, key, permissions (read, write, admin) and collections
+
+# Automatic Collection Creation (for prototyping)
+auto_create:
+  enabled: ` + fmt.Sprintf("%t", config.AutoCreate.Enabled) + `           # Create missing collections on their first text insert
+  collections: []           # Names or glob patterns that may be created (default: any)
+  template:
+    index_type: ""          # flat or hnsw (default: search.index.default_type)
+    metric: ""              # Distance metric (default: search.index.default_metric)
+    dimensions: 0           # 0 takes the vectorizer's dimensions
+                            # vectorizer: defaults to embeddings.default
+
+# Collection Creation Limits
+limits:
+  max_dimensions: ` + fmt.Sprintf("%d", config.Limits.MaxDimensions) + `     # Most dimensions a collection may have
+  max_collections: ` + fmt.Sprintf("%d", config.Limits.MaxCollections) + `        # Most collections, group fields included (0 = no limit)
+  reserved_prefixes: []     # Name prefixes new collections cannot start with
+
+# Edge Mode (read-through cache of a remote VittoriaDB)
+edge:
+  enabled: ` + fmt.Sprintf("%t", config.Edge.Enabled) + `           # Serve edge.collections from a local copy of the upstream
+  upstream: ""              # Base URL of the remote VittoriaDB, the source of truth
+  api_key: ""               # API key sent to the upstream
+  collections: []           # Collections to cache
+  ttl: ` + config.Edge.TTL.String() + `                   # Pull a record again once it is older than this
+  sync_interval: ` + config.Edge.SyncInterval.String() + `        # Full sync interval (0 = at startup only)
+
+# Scheduled Maintenance
+maintenance:
+  timezone: ""              # Time zone schedules are evaluated in (default: local time)
+  jobs: []                  # Jobs with name, type (compact, backup, dedupe, retention, index_check),
+                            # cron schedule, and optional collections and repair
+  windows: []               # When heavy jobs may run: cron schedule, duration and optional collections
+
+# Object Storage (for s3:// and gs:// backup directories and ingestion sources)
+object_storage:
+  s3:
+    region: ""              # Default: AWS_REGION, else us-east-1
+    endpoint: ""            # S3-compatible endpoint, such as http://localhost:9000 (default: AWS)
+    access_key_id: ""       # Default: AWS_ACCESS_KEY_ID
+    secret_access_key: ""   # Default: AWS_SECRET_ACCESS_KEY
+    path_style: false       # Address buckets by path (MinIO and most S3-compatible stores)
+  gcs:
+    endpoint: ""            # Default: https://storage.googleapis.com
+    access_key_id: ""       # HMAC access ID (default: GCS_ACCESS_KEY_ID)
+    secret_access_key: ""   # HMAC secret (default: GCS_SECRET_ACCESS_KEY)
 
 # Logging Configuration
 logging:
@@ -388,6 +552,7 @@ logging:
 
 # General Configuration
 data_dir: "` + config.DataDir + `"              # Data directory path
+read_only: ` + fmt.Sprintf("%t", config.ReadOnly) + `                   # Serve a data directory another process writes
 version: "` + config.Version + `"               # Configuration version
 `
 
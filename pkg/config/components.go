@@ -0,0 +1,53 @@
+package config
+
+import (
+	"maps"
+
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/objectstore"
+	"github.com/antonellof/VittoriaDB/pkg/processor"
+)
+
+// ObjectStoreConfig returns the endpoints and credentials of the object
+// stores
+func (c *VittoriaConfig) ObjectStoreConfig() objectstore.Config {
+	return objectstore.Config{
+		S3:  objectstore.S3Config(c.ObjectStorage.S3),
+		GCS: objectstore.GCSConfig(c.ObjectStorage.GCS),
+	}
+}
+
+// OCRConfig returns the OCR settings of document processing
+func (c *VittoriaConfig) OCRConfig() *processor.OCRConfig {
+	ocr := c.Embeddings.Processing.OCR
+	return &processor.OCRConfig{
+		Enabled:  ocr.Enabled,
+		URL:      ocr.URL,
+		Command:  ocr.Command,
+		Renderer: ocr.Renderer,
+		Language: ocr.Language,
+		DPI:      ocr.DPI,
+		Timeout:  ocr.Timeout,
+	}
+}
+
+// Vectorizer returns the vectorizer the settings describe, with a copy of
+// their options and the API key among them. An unknown type falls back to
+// sentence_transformers.
+func (v VectorizerConfig) Vectorizer() *embeddings.VectorizerConfig {
+	vectorizerType, err := embeddings.ParseVectorizerType(v.Type)
+	if err != nil {
+		vectorizerType = embeddings.VectorizerTypeSentenceTransformers
+	}
+	options := make(map[string]interface{}, len(v.Options)+1)
+	maps.Copy(options, v.Options)
+	if v.APIKey != "" {
+		options["api_key"] = v.APIKey
+	}
+	return &embeddings.VectorizerConfig{
+		Type:       vectorizerType,
+		Model:      v.Model,
+		Dimensions: v.Dimensions,
+		Options:    options,
+	}
+}
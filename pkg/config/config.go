@@ -2,13 +2,21 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"math"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/objectstore"
+	"github.com/antonellof/VittoriaDB/pkg/scheduler"
 	"gopkg.in/yaml.v3"
 )
 
@@ -32,9 +40,33 @@ type VittoriaConfig struct {
 	// Logging configuration
 	Logging LoggingConfig `yaml:"logging" json:"logging" env:"VITTORIA_LOGGING"`
 
+	// Cluster configuration
+	Cluster ClusterConfig `yaml:"cluster" json:"cluster" env:"VITTORIA_CLUSTER"`
+
+	// Authentication and access control configuration
+	Auth AuthConfig `yaml:"auth" json:"auth" env:"VITTORIA_AUTH"`
+
+	// Scheduled maintenance configuration
+	Maintenance MaintenanceConfig `yaml:"maintenance" json:"maintenance"`
+
+	// Object storage endpoints and credentials, for s3:// and gs:// URIs
+	ObjectStorage ObjectStorageConfig `yaml:"object_storage" json:"object_storage"`
+
+	// Automatic collection creation on first text insert
+	AutoCreate AutoCreateConfig `yaml:"auto_create" json:"auto_create"`
+
+	// Edge mode: serve collections of a remote VittoriaDB as a read-through cache
+	Edge EdgeConfig `yaml:"edge" json:"edge"`
+
+	// Guardrails on collection creation
+	Limits LimitsConfig `yaml:"limits" json:"limits"`
+
 	// Data directory (overrides individual data dirs)
 	DataDir string `yaml:"data_dir" json:"data_dir" env:"VITTORIA_DATA_DIR"`
 
+	// Serve a data directory another process writes, without writing to it
+	ReadOnly bool `yaml:"read_only" json:"read_only" env:"READ_ONLY"`
+
 	// Configuration metadata
 	Version string `yaml:"version" json:"version"`
 	Source  string `yaml:"-" json:"-"` // Where config was loaded from
@@ -49,13 +81,60 @@ type ServerConfig struct {
 	MaxBodySize  int64         `yaml:"max_body_size" json:"max_body_size" env:"MAX_BODY_SIZE"`
 	CORS         bool          `yaml:"cors" json:"cors" env:"CORS"`
 	TLS          TLSConfig     `yaml:"tls" json:"tls"`
+
+	// Connection reuse, for clients sending many small requests
+	IdleTimeout time.Duration `yaml:"idle_timeout" json:"idle_timeout" env:"IDLE_TIMEOUT"` // How long a kept-alive connection may wait for its next request
+	KeepAlive   bool          `yaml:"keep_alive" json:"keep_alive" env:"KEEP_ALIVE"`       // Serve more than one request per connection
+	HTTP2       HTTP2Config   `yaml:"http2" json:"http2"`
+
+	RateLimit   ServerRateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+	Concurrency ConcurrencyConfig     `yaml:"concurrency" json:"concurrency"`
+
+	// Per-route overrides of the read and write timeouts, on top of the
+	// built-in ones for ingestion and export routes
+	RouteTimeouts []RouteTimeoutConfig `yaml:"route_timeouts" json:"route_timeouts"`
+}
+
+// RouteTimeoutConfig overrides the server's timeouts for one route
+type RouteTimeoutConfig struct {
+	Path         string        `yaml:"path" json:"path"`                   // Route template, such as /collections/{name}/documents
+	ReadTimeout  time.Duration `yaml:"read_timeout" json:"read_timeout"`   // 0 keeps server.read_timeout
+	WriteTimeout time.Duration `yaml:"write_timeout" json:"write_timeout"` // 0 keeps server.write_timeout
+	Stream       bool          `yaml:"stream" json:"stream"`               // No write deadline, for responses that stream as long as they need
+}
+
+// HTTP2Config tunes HTTP/2, negotiated over TLS and spoken in cleartext (h2c)
+// by clients that know the server supports it
+type HTTP2Config struct {
+	Enabled              bool          `yaml:"enabled" json:"enabled" env:"HTTP2_ENABLED"`                                              // Arrow Flight needs it
+	MaxConcurrentStreams int           `yaml:"max_concurrent_streams" json:"max_concurrent_streams" env:"HTTP2_MAX_CONCURRENT_STREAMS"` // Requests in flight per connection (0 = 250)
+	PingInterval         time.Duration `yaml:"ping_interval" json:"ping_interval" env:"HTTP2_PING_INTERVAL"`                            // Ping connections quiet for this long, closing dead ones (0 = never)
+}
+
+// ServerRateLimitConfig limits the request rate of each API key and each
+// client IP. A zero requests_per_second disables that limit.
+type ServerRateLimitConfig struct {
+	Enabled    bool            `yaml:"enabled" json:"enabled" env:"RATE_LIMIT_ENABLED"`
+	PerKey     RateLimitConfig `yaml:"per_key" json:"per_key"`
+	PerIP      RateLimitConfig `yaml:"per_ip" json:"per_ip"`
+	TrustProxy bool            `yaml:"trust_proxy" json:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY"` // Take the client IP from X-Forwarded-For
+}
+
+// ConcurrencyConfig bounds the requests the server works on at once. When
+// every slot is taken, requests wait in line, interactive ones ahead of batch
+// ones, which never take the reserved slots.
+type ConcurrencyConfig struct {
+	Enabled             bool          `yaml:"enabled" json:"enabled" env:"CONCURRENCY_ENABLED"`
+	MaxRequests         int           `yaml:"max_requests" json:"max_requests" env:"CONCURRENCY_MAX_REQUESTS"`                         // Requests served at once (0 = performance.max_concurrency)
+	ReservedInteractive int           `yaml:"reserved_interactive" json:"reserved_interactive" env:"CONCURRENCY_RESERVED_INTERACTIVE"` // Slots batch requests never take
+	QueueTimeout        time.Duration `yaml:"queue_timeout" json:"queue_timeout" env:"CONCURRENCY_QUEUE_TIMEOUT"`                      // How long a request waits for a slot before HTTP 503 (0 = as long as it takes)
 }
 
 // TLSConfig represents TLS configuration
 type TLSConfig struct {
 	Enabled  bool   `yaml:"enabled" json:"enabled" env:"TLS_ENABLED"`
 	CertFile string `yaml:"cert_file" json:"cert_file" env:"TLS_CERT_FILE"`
-	KeyFile  string `yaml:"key_file" json:"key_file" env:"TLS_KEY_FILE"`
+	KeyFile  string `yaml:"key_file" json:"key_file" env:"TLS_KEY_FILE" redact:"secret"`
 }
 
 // StorageConfig represents storage configuration
@@ -67,6 +146,17 @@ type StorageConfig struct {
 	WAL         WALConfig    `yaml:"wal" json:"wal"`
 	Backup      BackupConfig `yaml:"backup" json:"backup"`
 	Compression bool         `yaml:"compression" json:"compression" env:"COMPRESSION"` // For future use
+
+	// Interval at which vectors past their expires_at metadata are removed (0 disables)
+	TTLCheckInterval time.Duration `yaml:"ttl_check_interval" json:"ttl_check_interval" env:"TTL_CHECK_INTERVAL"`
+
+	// How long dropped collections are kept in the trash, where they can be
+	// restored (0 deletes them at once)
+	TrashRetention time.Duration `yaml:"trash_retention" json:"trash_retention" env:"TRASH_RETENTION"`
+
+	// Load the intact segments of a collection whose vectors file fails its
+	// checksums, instead of failing the collection
+	RecoverCorrupted bool `yaml:"recover_corrupted" json:"recover_corrupted" env:"RECOVER_CORRUPTED"`
 }
 
 // WALConfig represents Write-Ahead Log configuration
@@ -82,7 +172,35 @@ type BackupConfig struct {
 	Enabled   bool          `yaml:"enabled" json:"enabled" env:"BACKUP_ENABLED"`
 	Interval  time.Duration `yaml:"interval" json:"interval" env:"BACKUP_INTERVAL"`
 	Retention int           `yaml:"retention" json:"retention" env:"BACKUP_RETENTION"`
-	Directory string        `yaml:"directory" json:"directory" env:"BACKUP_DIRECTORY"`
+	Directory string        `yaml:"directory" json:"directory" env:"BACKUP_DIRECTORY"` // Local path, or an s3:// or gs:// URI
+}
+
+// ObjectStorageConfig holds the endpoints and credentials used for s3:// and
+// gs:// URIs, such as backup directories and ingestion sources
+type ObjectStorageConfig struct {
+	S3  S3Config  `yaml:"s3" json:"s3"`
+	GCS GCSConfig `yaml:"gcs" json:"gcs"`
+}
+
+// S3Config configures Amazon S3 or an S3-compatible store. Unset credentials
+// and region are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN and AWS_REGION.
+type S3Config struct {
+	Region          string `yaml:"region" json:"region" env:"S3_REGION"`
+	Endpoint        string `yaml:"endpoint" json:"endpoint" env:"S3_ENDPOINT" redact:"url"` // Such as http://localhost:9000 for MinIO; empty for AWS
+	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id" env:"S3_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"-" env:"S3_SECRET_ACCESS_KEY" redact:"secret"`
+	SessionToken    string `yaml:"session_token" json:"-" env:"S3_SESSION_TOKEN" redact:"secret"`
+	PathStyle       bool   `yaml:"path_style" json:"path_style" env:"S3_PATH_STYLE"` // Address buckets by path, as most S3-compatible stores expect
+}
+
+// GCSConfig configures Google Cloud Storage, reached through its XML API with
+// an HMAC key. Unset credentials are read from GCS_ACCESS_KEY_ID and
+// GCS_SECRET_ACCESS_KEY.
+type GCSConfig struct {
+	Endpoint        string `yaml:"endpoint" json:"endpoint" env:"GCS_ENDPOINT" redact:"url"`
+	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id" env:"GCS_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"-" env:"GCS_SECRET_ACCESS_KEY" redact:"secret"`
 }
 
 // SearchConfig represents search configuration
@@ -96,12 +214,28 @@ type SearchConfig struct {
 	// Index settings
 	Index IndexConfig `yaml:"index" json:"index"`
 
+	// Second-stage reranking
+	Rerank RerankConfig `yaml:"rerank" json:"rerank"`
+
 	// Default search parameters
 	DefaultLimit int     `yaml:"default_limit" json:"default_limit" env:"DEFAULT_LIMIT"`
 	MaxLimit     int     `yaml:"max_limit" json:"max_limit" env:"MAX_LIMIT"`
 	MinScore     float32 `yaml:"min_score" json:"min_score" env:"MIN_SCORE"`
 }
 
+// RerankConfig configures the reranking service searches can opt into with
+// "rerank": true
+type RerankConfig struct {
+	Enabled   bool          `yaml:"enabled" json:"enabled" env:"ENABLED"`
+	Format    string        `yaml:"format" json:"format" env:"FORMAT"`     // "cohere" (Cohere, Jina, Voyage and compatible /rerank APIs) or "tei" (text-embeddings-inference)
+	URL       string        `yaml:"url" json:"url" env:"URL" redact:"url"` // Full URL of the rerank endpoint
+	Model     string        `yaml:"model" json:"model" env:"MODEL"`
+	APIKey    string        `yaml:"api_key" json:"-" env:"API_KEY" redact:"secret"`
+	Timeout   time.Duration `yaml:"timeout" json:"timeout" env:"TIMEOUT"`
+	TopK      int           `yaml:"top_k" json:"top_k" env:"TOP_K"`                // Candidates reranked when a request does not set rerank_top_k
+	TextField string        `yaml:"text_field" json:"text_field" env:"TEXT_FIELD"` // Metadata field reranked for results without stored content
+}
+
 // ParallelSearchConfig holds configuration for parallel search
 type ParallelSearchConfig struct {
 	Enabled               bool `yaml:"enabled" json:"enabled" env:"PARALLEL_ENABLED"`
@@ -127,6 +261,9 @@ type IndexConfig struct {
 	HNSW          HNSWConfig `yaml:"hnsw" json:"hnsw"`
 	Flat          FlatConfig `yaml:"flat" json:"flat"`
 	IVF           IVFConfig  `yaml:"ivf" json:"ivf"`
+
+	// Background rebuilds of indexes that lost many entries to deletes and updates
+	AutoRebuild AutoRebuildConfig `yaml:"auto_rebuild" json:"auto_rebuild"`
 }
 
 // HNSWConfig represents HNSW index configuration
@@ -138,6 +275,9 @@ type HNSWConfig struct {
 	EfConstruction int     `yaml:"ef_construction" json:"ef_construction" env:"HNSW_EF_CONSTRUCTION"`
 	EfSearch       int     `yaml:"ef_search" json:"ef_search" env:"HNSW_EF_SEARCH"`
 	Seed           int64   `yaml:"seed" json:"seed" env:"HNSW_SEED"`
+
+	NeighborSelection     string `yaml:"neighbor_selection" json:"neighbor_selection" env:"HNSW_NEIGHBOR_SELECTION"`                // "heuristic" (Malkov Algorithm 4) or "simple" (closest M)
+	KeepPrunedConnections bool   `yaml:"keep_pruned_connections" json:"keep_pruned_connections" env:"HNSW_KEEP_PRUNED_CONNECTIONS"` // Fill free neighbor slots with candidates the heuristic discarded
 }
 
 // FlatConfig represents flat index configuration
@@ -145,6 +285,12 @@ type FlatConfig struct {
 	BatchSize int `yaml:"batch_size" json:"batch_size" env:"FLAT_BATCH_SIZE"`
 }
 
+// AutoRebuildConfig sets when an index is rebuilt in the background
+type AutoRebuildConfig struct {
+	DeletedRatio float64 `yaml:"deleted_ratio" json:"deleted_ratio" env:"INDEX_AUTO_REBUILD_DELETED_RATIO"` // Share of the index removed since it was built (0 disables)
+	MinVectors   int     `yaml:"min_vectors" json:"min_vectors" env:"INDEX_AUTO_REBUILD_MIN_VECTORS"`       // Smallest index considered
+}
+
 // IVFConfig represents IVF index configuration
 type IVFConfig struct {
 	NClusters int `yaml:"n_clusters" json:"n_clusters" env:"IVF_N_CLUSTERS"`
@@ -156,6 +302,10 @@ type EmbeddingsConfig struct {
 	// Default vectorizer settings
 	Default VectorizerConfig `yaml:"default" json:"default"`
 
+	// Named vectorizers collections are created with by vectorizer_profile,
+	// so that models and API keys stay out of client requests
+	Profiles map[string]VectorizerConfig `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+
 	// Batch processing settings
 	Batch BatchProcessorConfig `yaml:"batch" json:"batch"`
 
@@ -175,6 +325,7 @@ type VectorizerConfig struct {
 	Model      string                 `yaml:"model" json:"model" env:"VECTORIZER_MODEL"`
 	Dimensions int                    `yaml:"dimensions" json:"dimensions" env:"VECTORIZER_DIMENSIONS"`
 	Options    map[string]interface{} `yaml:"options" json:"options"`
+	APIKey     string                 `yaml:"api_key,omitempty" json:"-" redact:"secret"` // Sent to the provider; kept out of collection metadata
 }
 
 // BatchProcessorConfig represents batch processing configuration
@@ -197,12 +348,25 @@ type ProcessingConfig struct {
 	MaxChunkSize int               `yaml:"max_chunk_size" json:"max_chunk_size" env:"PROCESSING_MAX_CHUNK_SIZE"`
 	Strategy     string            `yaml:"strategy" json:"strategy" env:"PROCESSING_STRATEGY"`
 	Metadata     map[string]string `yaml:"metadata" json:"metadata"`
+	OCR          OCRConfig         `yaml:"ocr" json:"ocr"`
+}
+
+// OCRConfig configures optical character recognition of uploaded PDF pages
+// without extractable text, through an OCR service or tesseract
+type OCRConfig struct {
+	Enabled  bool          `yaml:"enabled" json:"enabled" env:"OCR_ENABLED"`
+	URL      string        `yaml:"url" json:"url" env:"OCR_URL" redact:"url"`   // OCR service each page is sent to; empty uses local tools
+	Command  string        `yaml:"command" json:"command" env:"OCR_COMMAND"`    // tesseract binary
+	Renderer string        `yaml:"renderer" json:"renderer" env:"OCR_RENDERER"` // pdftoppm binary rendering pages to images
+	Language string        `yaml:"language" json:"language" env:"OCR_LANGUAGE"` // Tesseract language codes, such as "eng+ita"
+	DPI      int           `yaml:"dpi" json:"dpi" env:"OCR_DPI"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout" env:"OCR_TIMEOUT"` // Per page
 }
 
 // Provider-specific configurations
 type OpenAIConfig struct {
-	APIKey     string          `yaml:"api_key" json:"-" env:"OPENAI_API_KEY"`
-	BaseURL    string          `yaml:"base_url" json:"base_url" env:"OPENAI_BASE_URL"`
+	APIKey     string          `yaml:"api_key" json:"-" env:"OPENAI_API_KEY" redact:"secret"`
+	BaseURL    string          `yaml:"base_url" json:"base_url" env:"OPENAI_BASE_URL" redact:"url"`
 	Model      string          `yaml:"model" json:"model" env:"OPENAI_MODEL"`
 	Timeout    time.Duration   `yaml:"timeout" json:"timeout" env:"OPENAI_TIMEOUT"`
 	MaxRetries int             `yaml:"max_retries" json:"max_retries" env:"OPENAI_MAX_RETRIES"`
@@ -210,15 +374,15 @@ type OpenAIConfig struct {
 }
 
 type HuggingFaceConfig struct {
-	APIKey    string          `yaml:"api_key" json:"-" env:"HUGGINGFACE_API_KEY"`
-	BaseURL   string          `yaml:"base_url" json:"base_url" env:"HUGGINGFACE_BASE_URL"`
+	APIKey    string          `yaml:"api_key" json:"-" env:"HUGGINGFACE_API_KEY" redact:"secret"`
+	BaseURL   string          `yaml:"base_url" json:"base_url" env:"HUGGINGFACE_BASE_URL" redact:"url"`
 	Model     string          `yaml:"model" json:"model" env:"HUGGINGFACE_MODEL"`
 	Timeout   time.Duration   `yaml:"timeout" json:"timeout" env:"HUGGINGFACE_TIMEOUT"`
 	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
 }
 
 type OllamaConfig struct {
-	BaseURL string        `yaml:"base_url" json:"base_url" env:"OLLAMA_BASE_URL"`
+	BaseURL string        `yaml:"base_url" json:"base_url" env:"OLLAMA_BASE_URL" redact:"url"`
 	Model   string        `yaml:"model" json:"model" env:"OLLAMA_MODEL"`
 	Timeout time.Duration `yaml:"timeout" json:"timeout" env:"OLLAMA_TIMEOUT"`
 }
@@ -278,6 +442,140 @@ type LoggingConfig struct {
 	Compress   bool          `yaml:"compress" json:"compress" env:"LOG_COMPRESS"`
 }
 
+// LogLevels are the values of logging.level, from the most verbose. Requests
+// are logged at info, failed ones at warn and server errors at error.
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// Logs reports whether messages of a level are logged; an empty level logs
+// everything but debug messages
+func (l LoggingConfig) Logs(level string) bool {
+	current := slices.Index(LogLevels, l.Level)
+	if current < 0 {
+		current = slices.Index(LogLevels, "info")
+	}
+	return slices.Index(LogLevels, level) >= current
+}
+
+// ClusterConfig represents Raft clustering configuration
+type ClusterConfig struct {
+	Enabled           bool              `yaml:"enabled" json:"enabled" env:"CLUSTER_ENABLED"`
+	NodeID            string            `yaml:"node_id" json:"node_id" env:"CLUSTER_NODE_ID"`
+	Advertise         string            `yaml:"advertise" json:"advertise" env:"CLUSTER_ADVERTISE" redact:"url"` // URL peers and clients use to reach this node
+	Peers             map[string]string `yaml:"peers" json:"peers" redact:"url"`                                 // Node ID -> URL of the other members
+	Secret            string            `yaml:"secret" json:"-" env:"CLUSTER_SECRET" redact:"secret"`            // Shared by the members to authenticate Raft RPCs
+	DataDir           string            `yaml:"data_dir" json:"data_dir" env:"CLUSTER_DATA_DIR"`                 // Defaults to <data_dir>/.raft
+	ElectionTimeout   time.Duration     `yaml:"election_timeout" json:"election_timeout" env:"CLUSTER_ELECTION_TIMEOUT"`
+	HeartbeatInterval time.Duration     `yaml:"heartbeat_interval" json:"heartbeat_interval" env:"CLUSTER_HEARTBEAT_INTERVAL"`
+}
+
+// AuthConfig represents API key authentication and role-based access control
+type AuthConfig struct {
+	Enabled   bool           `yaml:"enabled" json:"enabled" env:"AUTH_ENABLED"`
+	KeysFile  string         `yaml:"keys_file" json:"keys_file" env:"AUTH_KEYS_FILE"`    // Keys created via /auth/keys; defaults to <data_dir>/auth_keys.json
+	UsageFile string         `yaml:"usage_file" json:"usage_file" env:"AUTH_USAGE_FILE"` // Per-key usage reported by /admin/usage; defaults to <data_dir>/auth_usage.json
+	Keys      []APIKeyConfig `yaml:"keys" json:"keys"`
+}
+
+// APIKeyConfig defines an API key and the access it grants
+type APIKeyConfig struct {
+	Name        string   `yaml:"name" json:"name"`
+	Key         string   `yaml:"key" json:"-" redact:"secret"`                       // Secret sent as "Authorization: Bearer <key>"
+	Permissions []string `yaml:"permissions" json:"permissions"`                     // read, write and/or admin
+	Collections []string `yaml:"collections,omitempty" json:"collections,omitempty"` // Names or glob patterns; empty grants all
+}
+
+// MaintenanceConfig schedules background maintenance jobs. Backup jobs write
+// to storage.backup.directory and keep storage.backup.retention archives.
+type MaintenanceConfig struct {
+	Timezone string                 `yaml:"timezone" json:"timezone" env:"MAINTENANCE_TIMEZONE"` // IANA zone schedules are evaluated in; empty for local time
+	Jobs     []MaintenanceJobConfig `yaml:"jobs" json:"jobs"`
+
+	// Windows limit when compaction, deduplication, index repairs and
+	// rebuilds may run on the collections they match
+	Windows []MaintenanceWindowConfig `yaml:"windows,omitempty" json:"windows,omitempty"`
+}
+
+// MaintenanceWindowConfig defines a recurring maintenance window: it opens on
+// a cron schedule and stays open for a duration
+type MaintenanceWindowConfig struct {
+	Collections []string      `yaml:"collections,omitempty" json:"collections,omitempty"` // Names or glob patterns; empty for all
+	Schedule    string        `yaml:"schedule" json:"schedule"`                           // Cron expression of when the window opens, such as "0 1 * * *"
+	Duration    time.Duration `yaml:"duration" json:"duration"`                           // How long the window stays open
+}
+
+// Maintenance job types
+const (
+	MaintenanceCompact    = "compact"     // Purge index tombstones and rewrite collection files
+	MaintenanceBackup     = "backup"      // Write a backup archive to the backup directory
+	MaintenanceDedupe     = "dedupe"      // Remove vectors identical to another one
+	MaintenanceRetention  = "retention"   // Remove vectors past their expires_at
+	MaintenanceIndexCheck = "index_check" // Check, and optionally repair, HNSW graphs
+)
+
+// MaintenanceJobTypes lists the maintenance job types
+var MaintenanceJobTypes = []string{
+	MaintenanceCompact,
+	MaintenanceBackup,
+	MaintenanceDedupe,
+	MaintenanceRetention,
+	MaintenanceIndexCheck,
+}
+
+// MaintenanceJobConfig defines a maintenance job and its cron schedule
+type MaintenanceJobConfig struct {
+	Name        string   `yaml:"name" json:"name"`
+	Type        string   `yaml:"type" json:"type"`                                   // compact, backup, dedupe, retention or index_check
+	Schedule    string   `yaml:"schedule" json:"schedule"`                           // Cron expression, such as "0 3 * * *"
+	Collections []string `yaml:"collections,omitempty" json:"collections,omitempty"` // Names or glob patterns; empty for all
+	Repair      bool     `yaml:"repair,omitempty" json:"repair,omitempty"`           // index_check: repair damaged graphs
+}
+
+// AutoCreateConfig creates a collection that does not exist yet when text is
+// first inserted into it, from a template. It is meant for prototyping and is
+// disabled by default.
+type AutoCreateConfig struct {
+	Enabled     bool                     `yaml:"enabled" json:"enabled" env:"AUTO_CREATE_ENABLED"`
+	Collections []string                 `yaml:"collections,omitempty" json:"collections,omitempty"` // Names or glob patterns that may be created; empty allows any
+	Template    CollectionTemplateConfig `yaml:"template" json:"template"`
+}
+
+// EdgeConfig makes the instance a read-through cache of collections of a
+// remote VittoriaDB, the source of truth: records missing locally or older
+// than the TTL are pulled from it on read, whole collections are synced
+// periodically, and writes are redirected to it.
+type EdgeConfig struct {
+	Enabled      bool          `yaml:"enabled" json:"enabled" env:"ENABLED"`
+	Upstream     string        `yaml:"upstream" json:"upstream" env:"UPSTREAM" redact:"url"`   // Base URL of the remote VittoriaDB
+	APIKey       string        `yaml:"api_key" json:"-" env:"API_KEY" redact:"secret"`         // Sent to the upstream when it requires authentication
+	Collections  []string      `yaml:"collections" json:"collections"`                         // Collections cached
+	TTL          time.Duration `yaml:"ttl" json:"ttl" env:"TTL"`                               // How long a pulled record is served before it is pulled again
+	SyncInterval time.Duration `yaml:"sync_interval" json:"sync_interval" env:"SYNC_INTERVAL"` // Interval of full syncs; 0 syncs at startup only
+}
+
+// DefaultMaxDimensions is the default of limits.max_dimensions
+const DefaultMaxDimensions = 10000
+
+// DimensionsCeiling bounds the max_dimensions limit itself
+const DimensionsCeiling = 65536
+
+// LimitsConfig bounds what collection creation accepts, so that a typo in a
+// request cannot create a collection the server cannot serve
+type LimitsConfig struct {
+	MaxDimensions    int      `yaml:"max_dimensions" json:"max_dimensions" env:"MAX_DIMENSIONS"`      // Dimensions of a collection
+	MaxCollections   int      `yaml:"max_collections" json:"max_collections" env:"MAX_COLLECTIONS"`   // Collections of the database, group fields included; 0 for no limit
+	ReservedPrefixes []string `yaml:"reserved_prefixes,omitempty" json:"reserved_prefixes,omitempty"` // Name prefixes new collections cannot start with
+}
+
+// CollectionTemplateConfig describes the collections created automatically
+type CollectionTemplateConfig struct {
+	IndexType  string            `yaml:"index_type" json:"index_type"`                     // flat or hnsw; defaults to search.index.default_type
+	Metric     string            `yaml:"metric" json:"metric"`                             // Defaults to search.index.default_metric
+	Dimensions int               `yaml:"dimensions" json:"dimensions"`                     // 0 takes the vectorizer's dimensions
+	Vectorizer *VectorizerConfig `yaml:"vectorizer,omitempty" json:"vectorizer,omitempty"` // Defaults to embeddings.default
+
+	VectorizerProfile string `yaml:"vectorizer_profile,omitempty" json:"vectorizer_profile,omitempty"` // An embeddings.profiles entry, instead of vectorizer
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *VittoriaConfig {
 	return &VittoriaConfig{
@@ -291,6 +589,28 @@ func DefaultConfig() *VittoriaConfig {
 			TLS: TLSConfig{
 				Enabled: false,
 			},
+			IdleTimeout: 120 * time.Second,
+			KeepAlive:   true,
+			HTTP2: HTTP2Config{
+				Enabled:              true,
+				MaxConcurrentStreams: 250,
+			},
+			RateLimit: ServerRateLimitConfig{
+				Enabled: false,
+				PerKey: RateLimitConfig{
+					RequestsPerSecond: 100,
+					BurstSize:         200,
+				},
+				PerIP: RateLimitConfig{
+					RequestsPerSecond: 50,
+					BurstSize:         100,
+				},
+			},
+			Concurrency: ConcurrencyConfig{
+				Enabled:             false,
+				ReservedInteractive: 1,
+				QueueTimeout:        30 * time.Second,
+			},
 		},
 		Storage: StorageConfig{
 			Engine:      "file",
@@ -310,6 +630,8 @@ func DefaultConfig() *VittoriaConfig {
 				Retention: 7,
 				Directory: "backups",
 			},
+			TTLCheckInterval: 1 * time.Minute,
+			TrashRetention:   24 * time.Hour,
 		},
 		Search: SearchConfig{
 			Parallel: ParallelSearchConfig{
@@ -337,15 +659,28 @@ func DefaultConfig() *VittoriaConfig {
 					EfConstruction: 200,
 					EfSearch:       50,
 					Seed:           42,
+
+					NeighborSelection: "heuristic",
 				},
 				Flat: FlatConfig{
 					BatchSize: 1000,
 				},
+				AutoRebuild: AutoRebuildConfig{
+					DeletedRatio: 0.5,
+					MinVectors:   1000,
+				},
 				IVF: IVFConfig{
 					NClusters: 100,
 					NProbe:    10,
 				},
 			},
+			Rerank: RerankConfig{
+				Enabled:   false,
+				Format:    "cohere",
+				Timeout:   10 * time.Second,
+				TopK:      50,
+				TextField: "text",
+			},
 			DefaultLimit: 10,
 			MaxLimit:     1000,
 			MinScore:     0.0,
@@ -374,6 +709,14 @@ func DefaultConfig() *VittoriaConfig {
 				MaxChunkSize: 2048,
 				Strategy:     "smart",
 				Metadata:     make(map[string]string),
+				OCR: OCRConfig{
+					Enabled:  false,
+					Command:  "tesseract",
+					Renderer: "pdftoppm",
+					Language: "eng",
+					DPI:      300,
+					Timeout:  60 * time.Second,
+				},
 			},
 			OpenAI: OpenAIConfig{
 				BaseURL:    "https://api.openai.com/v1",
@@ -435,6 +778,19 @@ func DefaultConfig() *VittoriaConfig {
 			MaxAge:     7 * 24 * time.Hour, // 7 days
 			Compress:   true,
 		},
+		Cluster: ClusterConfig{
+			Enabled:           false,
+			Peers:             make(map[string]string),
+			ElectionTimeout:   1 * time.Second,
+			HeartbeatInterval: 150 * time.Millisecond,
+		},
+		Edge: EdgeConfig{
+			TTL:          5 * time.Minute,
+			SyncInterval: 15 * time.Minute,
+		},
+		Limits: LimitsConfig{
+			MaxDimensions: DefaultMaxDimensions,
+		},
 		DataDir: "data",
 		Version: "1.0",
 	}
@@ -472,6 +828,52 @@ func (c *VittoriaConfig) Validate() error {
 	if c.Server.WriteTimeout <= 0 {
 		errors = append(errors, "server.write_timeout must be positive")
 	}
+	if c.Server.IdleTimeout < 0 {
+		errors = append(errors, "server.idle_timeout must be non-negative")
+	}
+	if c.Server.HTTP2.MaxConcurrentStreams < 0 || c.Server.HTTP2.PingInterval < 0 {
+		errors = append(errors, "server.http2 values must be non-negative")
+	}
+	routePaths := make(map[string]bool)
+	for i, route := range c.Server.RouteTimeouts {
+		if !strings.HasPrefix(route.Path, "/") {
+			errors = append(errors, fmt.Sprintf("server.route_timeouts[%d].path must be a route path starting with /", i))
+		} else if routePaths[route.Path] {
+			errors = append(errors, fmt.Sprintf("server.route_timeouts lists %s more than once", route.Path))
+		}
+		routePaths[route.Path] = true
+		if route.ReadTimeout < 0 || route.WriteTimeout < 0 {
+			errors = append(errors, fmt.Sprintf("server.route_timeouts[%d] timeouts must be non-negative", i))
+		}
+	}
+	if c.Server.RateLimit.Enabled {
+		limits := []struct {
+			name  string
+			limit RateLimitConfig
+		}{{"per_key", c.Server.RateLimit.PerKey}, {"per_ip", c.Server.RateLimit.PerIP}}
+		for _, l := range limits {
+			if l.limit.RequestsPerSecond < 0 || l.limit.Timeout < 0 {
+				errors = append(errors, fmt.Sprintf("server.rate_limit.%s values must be non-negative", l.name))
+			}
+			if l.limit.RequestsPerSecond > 0 && l.limit.BurstSize < 1 {
+				errors = append(errors, fmt.Sprintf("server.rate_limit.%s.burst_size must be at least 1", l.name))
+			}
+		}
+	}
+
+	if c.Server.Concurrency.Enabled {
+		concurrency := c.Server.Concurrency
+		if concurrency.MaxRequests < 0 || concurrency.ReservedInteractive < 0 || concurrency.QueueTimeout < 0 {
+			errors = append(errors, "server.concurrency values must be non-negative")
+		}
+		limit := concurrency.MaxRequests
+		if limit == 0 {
+			limit = c.Performance.MaxConcurrency
+		}
+		if limit > 0 && concurrency.ReservedInteractive >= limit {
+			errors = append(errors, "server.concurrency.reserved_interactive must leave batch requests at least one slot")
+		}
+	}
 
 	// Storage validation
 	if c.Storage.PageSize <= 0 || (c.Storage.PageSize&(c.Storage.PageSize-1)) != 0 {
@@ -480,6 +882,25 @@ func (c *VittoriaConfig) Validate() error {
 	if c.Storage.CacheSize < 0 {
 		errors = append(errors, "storage.cache_size must be non-negative")
 	}
+	if c.Storage.TTLCheckInterval < 0 {
+		errors = append(errors, "storage.ttl_check_interval must be non-negative")
+	}
+	if c.Storage.TrashRetention < 0 {
+		errors = append(errors, "storage.trash_retention must be non-negative")
+	}
+
+	// Limits validation
+	if c.Limits.MaxDimensions < 1 || c.Limits.MaxDimensions > DimensionsCeiling {
+		errors = append(errors, fmt.Sprintf("limits.max_dimensions must be between 1 and %d", DimensionsCeiling))
+	}
+	if c.Limits.MaxCollections < 0 {
+		errors = append(errors, "limits.max_collections must be non-negative")
+	}
+	for i, prefix := range c.Limits.ReservedPrefixes {
+		if prefix == "" {
+			errors = append(errors, fmt.Sprintf("limits.reserved_prefixes[%d] is empty", i))
+		}
+	}
 
 	// Search validation
 	if c.Search.Parallel.MaxWorkers <= 0 {
@@ -497,11 +918,44 @@ func (c *VittoriaConfig) Validate() error {
 	if c.Search.MaxLimit < c.Search.DefaultLimit {
 		errors = append(errors, "search.max_limit must be >= search.default_limit")
 	}
+	switch c.Search.Index.HNSW.NeighborSelection {
+	case "", "heuristic", "simple":
+	default:
+		errors = append(errors, "search.index.hnsw.neighbor_selection must be \"heuristic\" or \"simple\"")
+	}
+	if c.Search.Index.AutoRebuild.DeletedRatio < 0 || c.Search.Index.AutoRebuild.DeletedRatio > 1 {
+		errors = append(errors, "search.index.auto_rebuild.deleted_ratio must be between 0 and 1")
+	}
+	if c.Search.Index.AutoRebuild.MinVectors < 0 {
+		errors = append(errors, "search.index.auto_rebuild.min_vectors cannot be negative")
+	}
+	if c.Search.Rerank.Enabled {
+		if c.Search.Rerank.URL == "" {
+			errors = append(errors, "search.rerank.url is required when reranking is enabled")
+		}
+		switch c.Search.Rerank.Format {
+		case "", "cohere", "tei":
+		default:
+			errors = append(errors, "search.rerank.format must be \"cohere\" or \"tei\"")
+		}
+		if c.Search.Rerank.TopK <= 0 {
+			errors = append(errors, "search.rerank.top_k must be positive")
+		}
+	}
 
 	// Embeddings validation
 	if c.Embeddings.Default.Dimensions <= 0 {
 		errors = append(errors, "embeddings.default.dimensions must be positive")
 	}
+	for _, name := range slices.Sorted(maps.Keys(c.Embeddings.Profiles)) {
+		profile := c.Embeddings.Profiles[name]
+		if t, err := embeddings.ParseVectorizerType(profile.Type); err != nil || t == embeddings.VectorizerTypeNone {
+			errors = append(errors, fmt.Sprintf("embeddings.profiles.%s.type '%s' is not a vectorizer", name, profile.Type))
+		}
+		if profile.Dimensions < 0 {
+			errors = append(errors, fmt.Sprintf("embeddings.profiles.%s.dimensions must be non-negative", name))
+		}
+	}
 	if c.Embeddings.Batch.DefaultBatchSize <= 0 {
 		errors = append(errors, "embeddings.batch.default_batch_size must be positive")
 	}
@@ -516,11 +970,169 @@ func (c *VittoriaConfig) Validate() error {
 	if c.Performance.CPU.NumThreads <= 0 {
 		errors = append(errors, "performance.cpu.num_threads must be positive")
 	}
+	if c.Performance.GCTarget < -1 {
+		errors = append(errors, "performance.gc_target must be -1 (disabled), 0 (runtime default) or positive")
+	}
+	if c.Performance.MemoryLimit < 0 {
+		errors = append(errors, "performance.memory_limit must be non-negative")
+	}
+
+	if c.Embeddings.Processing.OCR.DPI < 0 || c.Embeddings.Processing.OCR.Timeout < 0 {
+		errors = append(errors, "embeddings.processing.ocr.dpi and timeout must be non-negative")
+	}
+
+	// Cluster validation
+	if c.Cluster.Enabled {
+		if c.Cluster.NodeID == "" {
+			errors = append(errors, "cluster.node_id is required when clustering is enabled")
+		}
+		if c.Cluster.Advertise == "" {
+			errors = append(errors, "cluster.advertise is required when clustering is enabled")
+		}
+		if _, exists := c.Cluster.Peers[c.Cluster.NodeID]; exists {
+			errors = append(errors, "cluster.peers must not contain the local node")
+		}
+		if len(c.Cluster.Peers) > 0 && len(c.Cluster.Secret) < 16 {
+			errors = append(errors, "cluster.secret of at least 16 characters is required when the cluster has peers")
+		}
+		if c.Cluster.HeartbeatInterval <= 0 || c.Cluster.ElectionTimeout <= c.Cluster.HeartbeatInterval {
+			errors = append(errors, "cluster.election_timeout must be greater than cluster.heartbeat_interval")
+		}
+	}
+
+	// Auth validation
+	if c.Auth.Enabled && len(c.Auth.Keys) == 0 {
+		errors = append(errors, "auth.keys must define at least one key when auth is enabled")
+	}
+	names := make(map[string]bool)
+	for i, key := range c.Auth.Keys {
+		if key.Name == "" {
+			errors = append(errors, fmt.Sprintf("auth.keys[%d].name is required", i))
+		} else if names[key.Name] {
+			errors = append(errors, fmt.Sprintf("auth.keys[%d].name '%s' is duplicated", i, key.Name))
+		}
+		names[key.Name] = true
+		if len(key.Key) < 16 {
+			errors = append(errors, fmt.Sprintf("auth.keys[%d].key must be at least 16 characters", i))
+		}
+		if len(key.Permissions) == 0 {
+			errors = append(errors, fmt.Sprintf("auth.keys[%d].permissions must not be empty", i))
+		}
+		for _, p := range key.Permissions {
+			if _, err := auth.ParsePermission(p); err != nil {
+				errors = append(errors, fmt.Sprintf("auth.keys[%d]: %v", i, err))
+			}
+		}
+	}
+
+	// Maintenance validation
+	if c.Maintenance.Timezone != "" {
+		if _, err := time.LoadLocation(c.Maintenance.Timezone); err != nil {
+			errors = append(errors, fmt.Sprintf("maintenance.timezone: %v", err))
+		}
+	}
+	jobNames := make(map[string]bool)
+	for i, job := range c.Maintenance.Jobs {
+		if job.Name == "" {
+			errors = append(errors, fmt.Sprintf("maintenance.jobs[%d].name is required", i))
+		} else if jobNames[job.Name] {
+			errors = append(errors, fmt.Sprintf("maintenance.jobs[%d].name '%s' is duplicated", i, job.Name))
+		}
+		jobNames[job.Name] = true
+		if !slices.Contains(MaintenanceJobTypes, job.Type) {
+			errors = append(errors, fmt.Sprintf("maintenance.jobs[%d].type must be one of %s", i, strings.Join(MaintenanceJobTypes, ", ")))
+		}
+		if _, err := scheduler.ParseCron(job.Schedule); err != nil {
+			errors = append(errors, fmt.Sprintf("maintenance.jobs[%d].schedule: %v", i, err))
+		}
+	}
+	for i, window := range c.Maintenance.Windows {
+		if _, err := scheduler.ParseCron(window.Schedule); err != nil {
+			errors = append(errors, fmt.Sprintf("maintenance.windows[%d].schedule: %v", i, err))
+		}
+		if window.Duration <= 0 {
+			errors = append(errors, fmt.Sprintf("maintenance.windows[%d].duration must be positive", i))
+		}
+	}
+	if c.Storage.Backup.Retention < 0 {
+		errors = append(errors, "storage.backup.retention must be non-negative")
+	}
+	if objectstore.IsURI(c.Storage.Backup.Directory) {
+		if _, err := objectstore.ParseURI(c.Storage.Backup.Directory); err != nil {
+			errors = append(errors, fmt.Sprintf("storage.backup.directory: %v", err))
+		}
+	}
+
+	// Auto-create validation
+	if c.AutoCreate.Enabled {
+		template := c.AutoCreate.Template
+		switch template.IndexType {
+		case "", "flat", "hnsw":
+		default:
+			errors = append(errors, "auto_create.template.index_type must be \"flat\" or \"hnsw\"")
+		}
+		switch template.Metric {
+		case "", "cosine", "euclidean", "dot_product", "manhattan", "hamming", "jaccard":
+		default:
+			errors = append(errors, "auto_create.template.metric must be \"cosine\", \"euclidean\", \"dot_product\", \"manhattan\", \"hamming\" or \"jaccard\"")
+		}
+		if template.Dimensions < 0 {
+			errors = append(errors, "auto_create.template.dimensions must be non-negative")
+		}
+		vectorizer := c.Embeddings.Default
+		if template.Vectorizer != nil {
+			vectorizer = *template.Vectorizer
+		}
+		if template.VectorizerProfile != "" {
+			if template.Vectorizer != nil {
+				errors = append(errors, "auto_create.template sets both vectorizer and vectorizer_profile")
+			} else if _, ok := c.Embeddings.Profiles[template.VectorizerProfile]; !ok {
+				errors = append(errors, fmt.Sprintf("auto_create.template.vectorizer_profile '%s' is not in embeddings.profiles", template.VectorizerProfile))
+			}
+		} else if t, err := embeddings.ParseVectorizerType(vectorizer.Type); err != nil || t == embeddings.VectorizerTypeNone {
+			errors = append(errors, fmt.Sprintf("auto_create.template.vectorizer.type '%s' is not a vectorizer", vectorizer.Type))
+		}
+		for _, pattern := range c.AutoCreate.Collections {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errors = append(errors, fmt.Sprintf("auto_create.collections: invalid pattern '%s'", pattern))
+			}
+		}
+	}
+
+	// Edge validation
+	if c.Edge.Enabled {
+		if _, err := url.Parse(c.Edge.Upstream); err != nil || !strings.HasPrefix(c.Edge.Upstream, "http://") && !strings.HasPrefix(c.Edge.Upstream, "https://") {
+			errors = append(errors, "edge.upstream must be an http:// or https:// URL when edge mode is enabled")
+		}
+		if len(c.Edge.Collections) == 0 {
+			errors = append(errors, "edge.collections must list at least one collection when edge mode is enabled")
+		}
+		if c.Edge.TTL <= 0 {
+			errors = append(errors, "edge.ttl must be positive")
+		}
+		if c.Edge.SyncInterval < 0 {
+			errors = append(errors, "edge.sync_interval must be non-negative")
+		}
+		if c.Cluster.Enabled {
+			errors = append(errors, "edge mode cannot be combined with clustering")
+		}
+	}
+
+	// Logging validation
+	if c.Logging.Level != "" && !slices.Contains(LogLevels, c.Logging.Level) {
+		errors = append(errors, fmt.Sprintf("logging.level must be one of %s", strings.Join(LogLevels, ", ")))
+	}
 
 	// Data directory validation
 	if c.DataDir == "" {
 		errors = append(errors, "data_dir cannot be empty")
 	}
+	if c.ReadOnly && c.Cluster.Enabled {
+		errors = append(errors, "read_only cannot be combined with clustering")
+	}
+	if c.ReadOnly && c.Edge.Enabled {
+		errors = append(errors, "read_only cannot be combined with edge mode")
+	}
 
 	if len(errors) > 0 {
 		return fmt.Errorf("validation errors:\n- %s", strings.Join(errors, "\n- "))
@@ -369,6 +369,7 @@ func CreateDefaultManager(sources ...ConfigSource) *ConfigManager {
 	// Add default change listeners
 	manager.AddChangeListener(&LoggingChangeListener{})
 	manager.AddChangeListener(&CacheChangeListener{})
+	manager.AddChangeListener(&RuntimeChangeListener{})
 
 	return manager
 }
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// GET /config renders the configuration through View, never by marshaling
+// it directly. Fields tagged redact:"secret" are replaced with Redacted when
+// set, and the credentials of fields tagged redact:"url" are removed; fields
+// holding secrets are also tagged json:"-", so they stay out of any other
+// rendering. A new secret or endpoint field only needs the tag.
+
+// ConfigAPIVersion is the version of the layout GET /config answers with
+const ConfigAPIVersion = "v1"
+
+// Redacted replaces the value of secret settings in configuration views
+const Redacted = "[redacted]"
+
+// Configuration views
+const (
+	ViewPublic = "public" // The sections in PublicSections, for any reader
+	ViewFull   = "full"   // Every section, for admins
+)
+
+// PublicSections are the top-level settings the public view shows. Auth,
+// cluster, object storage and edge settings describe the deployment rather
+// than the database, and only the full view shows them.
+var PublicSections = []string{
+	"server", "storage", "search", "embeddings", "performance", "logging",
+	"maintenance", "auto_create", "limits", "data_dir", "read_only", "version",
+}
+
+// View returns the configuration as JSON-style sections with secrets
+// redacted, limited to PublicSections unless full is set
+func (c *VittoriaConfig) View(full bool) (map[string]interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var view map[string]interface{}
+	if err := json.Unmarshal(data, &view); err != nil {
+		return nil, err
+	}
+	redactFields(reflect.TypeOf(*c), view)
+
+	if !full {
+		for section := range view {
+			if !slices.Contains(PublicSections, section) {
+				delete(view, section)
+			}
+		}
+	}
+	return view, nil
+}
+
+// Fingerprint returns a digest of the full redacted view, which changes
+// whenever a setting other than a secret does
+func (c *VittoriaConfig) Fingerprint() (string, error) {
+	view, err := c.View(true)
+	if err != nil {
+		return "", err
+	}
+	// Maps marshal with sorted keys, so equal configurations digest alike
+	data, err := json.Marshal(view)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// redactFields applies the redact tags of struct type t to fields, the
+// marshaled form of a value of t
+func redactFields(t reflect.Type, fields map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		value, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		switch field.Tag.Get("redact") {
+		case "secret":
+			if s, ok := value.(string); ok && s != "" {
+				fields[name] = Redacted
+			}
+			continue
+		case "url":
+			fields[name] = redactURLs(value)
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		switch fieldType.Kind() {
+		case reflect.Struct:
+			if nested, ok := value.(map[string]interface{}); ok {
+				redactFields(fieldType, nested)
+			}
+		case reflect.Slice:
+			elem := fieldType.Elem()
+			if elem.Kind() != reflect.Struct {
+				continue
+			}
+			items, _ := value.([]interface{})
+			for _, item := range items {
+				if nested, ok := item.(map[string]interface{}); ok {
+					redactFields(elem, nested)
+				}
+			}
+		}
+	}
+}
+
+// redactURLs removes the credentials of a URL, or of the URLs in a map
+func redactURLs(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redactURL(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = redactURLs(item)
+		}
+	}
+	return value
+}
+
+// redactURL masks the password of a URL and the values of its query, which
+// may carry tokens, as url.URL.Redacted does
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{"xxxxx"}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A running server takes a new configuration when SIGHUP makes it load its
+// sources again or PUT /config changes settings. The settings in
+// reloadableSettings take effect at once; any other setting that changed is
+// reported as needing a restart and keeps its running value until then.
+// Changes are found on the full redacted view, so a changed secret goes
+// unnoticed and, like every secret, takes a restart.
+
+// reloadableSettings are the settings a running server applies, by their
+// path in the configuration views, with how to copy each between
+// configurations. A path covers the settings nested under it.
+var reloadableSettings = []struct {
+	path string
+	copy func(to, from *VittoriaConfig)
+}{
+	{"logging.level", func(to, from *VittoriaConfig) { to.Logging.Level = from.Logging.Level }},
+	{"search.cache.max_entries", func(to, from *VittoriaConfig) { to.Search.Cache.MaxEntries = from.Search.Cache.MaxEntries }},
+	{"search.cache.ttl", func(to, from *VittoriaConfig) { to.Search.Cache.TTL = from.Search.Cache.TTL }},
+	{"search.index.hnsw.ef_search", func(to, from *VittoriaConfig) { to.Search.Index.HNSW.EfSearch = from.Search.Index.HNSW.EfSearch }},
+	{"server.rate_limit", func(to, from *VittoriaConfig) { to.Server.RateLimit = from.Server.RateLimit }},
+	{"performance.gc_target", func(to, from *VittoriaConfig) { to.Performance.GCTarget = from.Performance.GCTarget }},
+	{"performance.memory_limit", func(to, from *VittoriaConfig) { to.Performance.MemoryLimit = from.Performance.MemoryLimit }},
+}
+
+// ReloadResult reports the settings a reload changed
+type ReloadResult struct {
+	Applied         []string `json:"applied"`          // Now in effect
+	RestartRequired []string `json:"restart_required"` // Kept at their running value until a restart
+}
+
+// ReloadableSettings returns the paths of the settings a running server
+// applies without a restart
+func ReloadableSettings() []string {
+	paths := make([]string, len(reloadableSettings))
+	for i, setting := range reloadableSettings {
+		paths[i] = setting.path
+	}
+	return paths
+}
+
+// Reload returns the configuration a running server with current moves to
+// when it is given next: current with the reloadable settings of next. The
+// result lists the changed settings by whether they took effect.
+func Reload(current, next *VittoriaConfig) (*VittoriaConfig, *ReloadResult, error) {
+	changed, err := ChangedSettings(current, next)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	effective := current.Clone()
+	effective.Source = current.Source
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	for _, path := range changed {
+		if IsReloadable(path) {
+			result.Applied = append(result.Applied, path)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, path)
+		}
+	}
+	for _, setting := range reloadableSettings {
+		setting.copy(effective, next)
+	}
+	return effective, result, nil
+}
+
+// SettingChange is a setting that differs between two configurations, with
+// its values in the views
+type SettingChange struct {
+	Path       string      `json:"path"`
+	From       interface{} `json:"from"`
+	To         interface{} `json:"to"`
+	Reloadable bool        `json:"reloadable"` // A running server applies it without a restart
+}
+
+// ChangedSettings returns the paths of the settings that differ between two
+// configurations, sorted
+func ChangedSettings(a, b *VittoriaConfig) ([]string, error) {
+	viewA, err := a.View(true)
+	if err != nil {
+		return nil, err
+	}
+	viewB, err := b.View(true)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := DiffViews(viewA, viewB)
+	changed := make([]string, len(changes))
+	for i, change := range changes {
+		changed[i] = change.Path
+	}
+	return changed, nil
+}
+
+// DiffViews returns the settings that differ between two configuration
+// views, such as a GET /config answer and the view of a file, sorted by path
+func DiffViews(from, to map[string]interface{}) []SettingChange {
+	var changes []SettingChange
+	diffSettings("", from, to, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// diffSettings adds the values that differ between two views under prefix
+// to changes. Lists compare as a whole.
+func diffSettings(prefix string, a, b map[string]interface{}, changes *[]SettingChange) {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	for key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		nestedA, okA := a[key].(map[string]interface{})
+		nestedB, okB := b[key].(map[string]interface{})
+		if okA && okB {
+			diffSettings(path, nestedA, nestedB, changes)
+		} else if !settingsEqual(a[key], b[key]) {
+			*changes = append(*changes, SettingChange{Path: path, From: a[key], To: b[key], Reloadable: IsReloadable(path)})
+		}
+	}
+}
+
+// settingsEqual compares two values of a view
+func settingsEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	// Views of unset and empty lists differ only in their encoding
+	dataA, _ := json.Marshal(a)
+	dataB, _ := json.Marshal(b)
+	empty := func(data []byte) bool { return string(data) == "null" || string(data) == "[]" || string(data) == "{}" }
+	return empty(dataA) && empty(dataB)
+}
+
+// IsReloadable reports whether a running server applies a setting, given by
+// its path, without a restart
+func IsReloadable(path string) bool {
+	for _, setting := range reloadableSettings {
+		if path == setting.path || strings.HasPrefix(path, setting.path+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	"sync"
+)
+
+// RuntimeSettings reports the Go runtime tuning currently in effect
+type RuntimeSettings struct {
+	GCPercent   int   `json:"gc_percent"`   // -1 means the garbage collector is disabled
+	MemoryLimit int64 `json:"memory_limit"` // Soft memory limit in bytes, 0 means unlimited
+}
+
+var (
+	runtimeMu sync.Mutex
+
+	// Values the process started with (GOGC / GOMEMLIMIT or the Go defaults),
+	// restored when the configuration stops overriding them
+	defaultGCPercent   = readGCPercent()
+	defaultMemoryLimit = debug.SetMemoryLimit(-1)
+
+	currentGCPercent = defaultGCPercent
+)
+
+// ApplyRuntimeSettings applies performance.gc_target and performance.memory_limit
+// to the Go runtime and returns the effective values. A gc_target of 0 and a
+// memory_limit of 0 leave the process defaults in place.
+func ApplyRuntimeSettings(perf *PerformanceConfig) *RuntimeSettings {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	gcPercent := defaultGCPercent
+	if perf.GCTarget != 0 {
+		gcPercent = perf.GCTarget
+	}
+	debug.SetGCPercent(gcPercent)
+	currentGCPercent = gcPercent
+
+	memoryLimit := defaultMemoryLimit
+	if perf.MemoryLimit > 0 {
+		memoryLimit = perf.MemoryLimit
+	}
+	debug.SetMemoryLimit(memoryLimit)
+
+	return currentRuntimeSettings()
+}
+
+// CurrentRuntimeSettings returns the Go runtime tuning currently in effect
+func CurrentRuntimeSettings() *RuntimeSettings {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	return currentRuntimeSettings()
+}
+
+func currentRuntimeSettings() *RuntimeSettings {
+	memoryLimit := debug.SetMemoryLimit(-1)
+	if memoryLimit == math.MaxInt64 {
+		memoryLimit = 0
+	}
+
+	return &RuntimeSettings{
+		GCPercent:   currentGCPercent,
+		MemoryLimit: memoryLimit,
+	}
+}
+
+// readGCPercent returns the current GC percentage; the runtime only exposes it
+// through SetGCPercent, so it is set and immediately restored
+func readGCPercent() int {
+	percent := debug.SetGCPercent(100)
+	debug.SetGCPercent(percent)
+	return percent
+}
+
+// RuntimeChangeListener re-applies GC tuning when the configuration is reloaded
+type RuntimeChangeListener struct{}
+
+func (rcl *RuntimeChangeListener) Name() string {
+	return "runtime"
+}
+
+func (rcl *RuntimeChangeListener) OnConfigChange(old, new *VittoriaConfig) error {
+	if old != nil &&
+		old.Performance.GCTarget == new.Performance.GCTarget &&
+		old.Performance.MemoryLimit == new.Performance.MemoryLimit {
+		return nil
+	}
+
+	settings := ApplyRuntimeSettings(&new.Performance)
+	fmt.Printf("Runtime tuning changed: gc_percent=%d memory_limit=%d\n", settings.GCPercent, settings.MemoryLimit)
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+)
+
+// keepRuntimeSettings restores the GC percentage and memory limit the test
+// started with once it is done
+func keepRuntimeSettings(t *testing.T) {
+	gcPercent := readGCPercent()
+	memoryLimit := debug.SetMemoryLimit(-1)
+	t.Cleanup(func() {
+		runtimeMu.Lock()
+		defer runtimeMu.Unlock()
+		debug.SetGCPercent(gcPercent)
+		debug.SetMemoryLimit(memoryLimit)
+		currentGCPercent = gcPercent
+	})
+}
+
+func TestApplyRuntimeSettings(t *testing.T) {
+	keepRuntimeSettings(t)
+
+	settings := ApplyRuntimeSettings(&PerformanceConfig{GCTarget: 50, MemoryLimit: 1 << 30})
+	if settings.GCPercent != 50 || settings.MemoryLimit != 1<<30 {
+		t.Errorf("reported %+v, want gc_percent 50 and a 1GB memory_limit", settings)
+	}
+	if percent := readGCPercent(); percent != 50 {
+		t.Errorf("GC percentage is %d, want 50", percent)
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != 1<<30 {
+		t.Errorf("memory limit is %d, want 1GB", limit)
+	}
+	if current := CurrentRuntimeSettings(); *current != *settings {
+		t.Errorf("CurrentRuntimeSettings reported %+v, want %+v", current, settings)
+	}
+
+	// -1 disables the garbage collector
+	if settings := ApplyRuntimeSettings(&PerformanceConfig{GCTarget: -1}); settings.GCPercent != -1 || readGCPercent() != -1 {
+		t.Errorf("reported %+v with a GC percentage of %d, want the collector disabled", settings, readGCPercent())
+	}
+
+	// Zero values restore what the process started with
+	settings = ApplyRuntimeSettings(&PerformanceConfig{})
+	if percent := readGCPercent(); percent != defaultGCPercent || settings.GCPercent != defaultGCPercent {
+		t.Errorf("GC percentage is %d and reported %d, want the default %d", percent, settings.GCPercent, defaultGCPercent)
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != defaultMemoryLimit {
+		t.Errorf("memory limit is %d, want the default %d", limit, defaultMemoryLimit)
+	}
+	if defaultMemoryLimit == math.MaxInt64 && settings.MemoryLimit != 0 {
+		t.Errorf("reported a memory_limit of %d without a limit, want 0", settings.MemoryLimit)
+	}
+}
+
+func TestRuntimeChangeListener(t *testing.T) {
+	keepRuntimeSettings(t)
+	listener := &RuntimeChangeListener{}
+
+	old := DefaultConfig()
+	new := DefaultConfig()
+	new.Performance.GCTarget = 75
+	new.Performance.MemoryLimit = 1 << 31
+	if err := listener.OnConfigChange(old, new); err != nil {
+		t.Fatalf("OnConfigChange failed: %v", err)
+	}
+	if settings := CurrentRuntimeSettings(); settings.GCPercent != 75 || settings.MemoryLimit != 1<<31 {
+		t.Errorf("reloading applied %+v, want gc_percent 75 and a 2GB memory_limit", settings)
+	}
+
+	// A reload that changes neither setting leaves the runtime alone
+	debug.SetMemoryLimit(1 << 32)
+	if err := listener.OnConfigChange(new, new); err != nil {
+		t.Fatalf("OnConfigChange failed: %v", err)
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != 1<<32 {
+		t.Errorf("an unrelated reload reset the memory limit to %d", limit)
+	}
+}
@@ -193,6 +193,14 @@ func (f *FlagSource) Load(config *VittoriaConfig) error {
 			config.DataDir = value
 			return nil
 		},
+		"read-only": func(value string) error {
+			readOnly, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			config.ReadOnly = readOnly
+			return nil
+		},
 		"log-level": func(value string) error {
 			config.Logging.Level = value
 			return nil
@@ -213,6 +221,36 @@ func (f *FlagSource) Load(config *VittoriaConfig) error {
 			config.Search.Parallel.MaxWorkers = workers
 			return nil
 		},
+		"cluster-node-id": func(value string) error {
+			config.Cluster.Enabled = true
+			config.Cluster.NodeID = value
+			return nil
+		},
+		"cluster-advertise": func(value string) error {
+			config.Cluster.Advertise = value
+			return nil
+		},
+		"cluster-secret": func(value string) error {
+			config.Cluster.Secret = value
+			return nil
+		},
+		"cluster-peers": func(value string) error {
+			// Comma-separated list of id=url pairs
+			peers := make(map[string]string)
+			for _, pair := range strings.Split(value, ",") {
+				pair = strings.TrimSpace(pair)
+				if pair == "" {
+					continue
+				}
+				id, addr, ok := strings.Cut(pair, "=")
+				if !ok || id == "" || addr == "" {
+					return fmt.Errorf("invalid peer %q, expected id=url", pair)
+				}
+				peers[id] = addr
+			}
+			config.Cluster.Peers = peers
+			return nil
+		},
 	}
 
 	for flag, value := range f.flags {
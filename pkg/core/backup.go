@@ -0,0 +1,139 @@
+package core
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Backup writes a gzip-compressed tar archive of every collection to w.
+// Extracting it into an empty data directory restores the database.
+func (db *VittoriaDB) Backup(ctx context.Context, w io.Writer) error {
+	collections, err := db.matchCollections(nil)
+	if err != nil {
+		return err
+	}
+	return backupCollections(ctx, w, collections, db.groupsCovering(collections))
+}
+
+// backupCollections archives collections to w, with the definitions of
+// groups. Each collection is flushed and then archived under its read lock,
+// so the archive holds a consistent copy of each one.
+func backupCollections(ctx context.Context, w io.Writer, collections []*VittoriaCollection, groups []*CollectionGroup) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if len(groups) > 0 {
+		data, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: groupsFile, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+
+	for _, collection := range collections {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := collection.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush collection %s: %w", collection.Name(), err)
+		}
+		if err := collection.archive(tw, collection.Name()); err != nil {
+			return fmt.Errorf("failed to back up collection %s: %w", collection.Name(), err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// archive adds the collection's files to tw under prefix, followed by those
+// of its local shards
+func (c *VittoriaCollection) archive(tw *tar.Writer, prefix string) error {
+	c.mu.RLock()
+	err := archiveFiles(tw, c.dataDir, prefix)
+	c.mu.RUnlock()
+	if err != nil || !c.isSharded() {
+		return err
+	}
+
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	for _, s := range c.shards {
+		if local, ok := s.(*VittoriaCollection); ok {
+			shardPrefix := path.Join(prefix, shardsDirName, filepath.Base(local.dataDir))
+			if err := local.archive(tw, shardPrefix); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// archiveFiles adds the regular files directly inside dir to tw under prefix
+func archiveFiles(tw *tar.Writer, dir, prefix string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := archiveFile(tw, filepath.Join(dir, entry.Name()), path.Join(prefix, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveFile adds one file to tw under name
+func archiveFile(tw *tar.Writer, filePath, name string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// Collections with a Hamming or Jaccard metric store binary vectors, such as
+// binarized embeddings. Each vector is also kept packed one bit per component,
+// and brute-force scans compare packed words with popcounts. Scores are
+// similarities like every other metric's: the share of matching bits for
+// Hamming, and the Jaccard similarity for Jaccard.
+
+// validateBinaryVector checks that every component of a vector is 0 or 1
+func validateBinaryVector(vector []float32) error {
+	for i, value := range vector {
+		if value != 0 && value != 1 {
+			return fmt.Errorf("binary vectors hold only 0 and 1: component %d is %v", i, value)
+		}
+	}
+	return nil
+}
+
+// packVector returns the packed bits of a stored vector, or nil when the
+// metric is not binary or the record holds metadata only
+func (c *VittoriaCollection) packVector(vector *Vector) []uint64 {
+	if !c.metric.Binary() || !vector.hasVector() {
+		return nil
+	}
+	return index.PackBits(vector.Vector)
+}
+
+// packVectors packs every stored vector, after they are loaded
+func (c *VittoriaCollection) packVectors() {
+	if !c.metric.Binary() {
+		return
+	}
+	for _, vector := range c.vectors {
+		vector.bits = c.packVector(vector)
+	}
+}
+
+// queryScorer scores stored vectors against a search query. With a binary
+// metric the query is packed once, and vectors are compared on their bits;
+// quantized vectors are compared on their codes, and half-precision vectors
+// widened as they are read. Other vectors go through the SIMD kernels when
+// performance.enable_simd is set.
+type queryScorer struct {
+	c         *VittoriaCollection
+	query     []float32
+	bits      []uint64
+	quantized *quantizedQuery
+	kernels   *SIMDVectorOps
+	norm      float32 // Norm of the query, for cosine scores from the kernels or of half-precision vectors
+}
+
+// newQueryScorer returns a scorer for a query vector
+func (c *VittoriaCollection) newQueryScorer(query []float32) *queryScorer {
+	scorer := &queryScorer{c: c, query: query}
+	if c.metric.Binary() && len(query) > 0 {
+		scorer.bits = index.PackBits(query)
+	}
+	if c.quantizer != nil && len(query) > 0 {
+		scorer.quantized = c.quantizer.newQuantizedQuery(c.metric, query)
+	}
+	if c.indexOptions.kernels != nil && c.metric.kernelScored() {
+		scorer.kernels = c.indexOptions.kernels
+	}
+	if scorer.kernels != nil || c.halfPrecision() {
+		scorer.norm = vectorNorm(query)
+	}
+	return scorer
+}
+
+// score returns the similarity of a stored vector to the query
+func (s *queryScorer) score(vector *Vector) float32 {
+	if s.quantized != nil && vector.codes != nil {
+		return s.quantized.score(vector)
+	}
+	if vector.halves != nil {
+		return s.halfScore(vector)
+	}
+	if s.bits != nil && len(vector.bits) == len(s.bits) {
+		switch s.c.metric {
+		case DistanceMetricHamming:
+			return s.c.scoreFromDistance(float32(index.HammingBits(s.bits, vector.bits)))
+		case DistanceMetricJaccard:
+			return s.c.scoreFromDistance(index.JaccardBits(s.bits, vector.bits))
+		}
+	}
+	if s.kernels != nil {
+		return s.kernelScore(vector)
+	}
+	return s.c.calculateSimilarity(s.query, vector.Vector)
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestBinaryMetrics(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	// 70 dimensions span two packed words
+	bitsAt := func(set ...int) []float32 {
+		vector := make([]float32, 70)
+		for _, i := range set {
+			vector[i] = 1
+		}
+		return vector
+	}
+	query := bitsAt(0, 1, 65, 69)
+	vectors := map[string][]float32{
+		"same":    bitsAt(0, 1, 65, 69),
+		"half":    bitsAt(0, 65),
+		"other":   bitsAt(2, 3, 66),
+		"nothing": bitsAt(),
+	}
+	want := map[DistanceMetric]map[string]float32{
+		DistanceMetricHamming: {"same": 1, "half": 1 - 2.0/70, "other": 1 - 7.0/70, "nothing": 1 - 4.0/70},
+		DistanceMetricJaccard: {"same": 1, "half": 0.5, "other": 0, "nothing": 0},
+	}
+
+	for _, metric := range []DistanceMetric{DistanceMetricHamming, DistanceMetricJaccard} {
+		for _, indexType := range []IndexType{IndexTypeFlat, IndexTypeHNSW} {
+			name := metric.String() + "_" + indexType.String()
+			if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: name, Dimensions: 70, Metric: metric, IndexType: indexType}); err != nil {
+				t.Fatalf("CreateCollection failed: %v", err)
+			}
+			collection, _ := db.GetCollection(ctx, name)
+			for id, vector := range vectors {
+				if err := collection.Insert(ctx, &Vector{ID: id, Vector: vector}); err != nil {
+					t.Fatalf("%s: Insert failed: %v", name, err)
+				}
+			}
+			if err := collection.Insert(ctx, &Vector{ID: "float", Vector: make([]float32, 70)}); err != nil {
+				t.Fatalf("%s: Insert of zeros failed: %v", name, err)
+			}
+			collection.Delete(ctx, "float")
+			if err := collection.Insert(ctx, &Vector{ID: "float", Vector: append(bitsAt()[:69], 0.5)}); err == nil {
+				t.Errorf("%s: a non-binary vector was accepted", name)
+			}
+
+			response, err := collection.Search(ctx, &SearchRequest{Vector: query, Limit: 10})
+			if err != nil {
+				t.Fatalf("%s: Search failed: %v", name, err)
+			}
+			if len(response.Results) != len(vectors) || response.Results[0].ID != "same" {
+				t.Fatalf("%s: got %+v", name, response.Results)
+			}
+			for _, result := range response.Results {
+				if math.Abs(float64(result.Score-want[metric][result.ID])) > 1e-5 {
+					t.Errorf("%s: %s scored %v, want %v", name, result.ID, result.Score, want[metric][result.ID])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetBulkLoad switches bulk-load mode. While enabled, inserts and deletes only
+// touch the vector store and searches fall back to a brute-force scan. Turning
+// it off constructs the index once from all stored vectors, which is much
+// faster than growing the graph one insert at a time.
+func (c *VittoriaCollection) SetBulkLoad(ctx context.Context, enabled bool) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errorf(ErrClosed, "collection is closed")
+	}
+
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		for i, s := range c.shards {
+			if err := s.SetBulkLoad(ctx, enabled); err != nil {
+				return fmt.Errorf("shard %s: %w", c.shardName(i), err)
+			}
+		}
+	}
+
+	if c.bulkLoading == enabled {
+		return nil
+	}
+
+	if !c.isSharded() && c.index != nil {
+		if enabled {
+			// Drop the graph; it is rebuilt from scratch when the load finishes
+			if err := c.initIndex(); err != nil {
+				return err
+			}
+		} else {
+			c.index.Reserve(max(c.expectedCount, len(c.vectors)))
+			if err := c.rebuildIndex(); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.bulkLoading = enabled
+	c.touch()
+
+	if !enabled {
+		if err := c.saveIndex(); err != nil {
+			return fmt.Errorf("failed to save index: %w", err)
+		}
+	}
+	return c.saveMetadata()
+}
+
+// BulkLoading reports whether the collection is in bulk-load mode
+func (c *VittoriaCollection) BulkLoading() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.bulkLoading
+}
+
+// indexReady reports whether searches can be served by the ANN index
+func (c *VittoriaCollection) indexReady() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.index != nil && !c.bulkLoading
+}
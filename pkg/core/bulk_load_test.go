@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// searchIDs returns the IDs of the 10 best matches of query
+func searchIDs(t *testing.T, collection Collection, query []float32) []string {
+	t.Helper()
+	response, err := collection.Search(context.Background(), &SearchRequest{Vector: query, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	ids := make([]string, len(response.Results))
+	for i, result := range response.Results {
+		ids[i] = result.ID
+	}
+	return ids
+}
+
+// overlap returns the fraction of want found in got
+func overlap(got, want []string) float64 {
+	found := make(map[string]bool, len(got))
+	for _, id := range got {
+		found[id] = true
+	}
+	n := 0
+	for _, id := range want {
+		if found[id] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(want))
+}
+
+func TestBulkLoad(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+	rng := rand.New(rand.NewSource(7))
+
+	vectors := make([]*Vector, 1000)
+	for i := range vectors {
+		v := make([]float32, 8)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%d", i), Vector: v}
+	}
+	queries := make([][]float32, 20)
+	for i := range queries {
+		queries[i] = vectors[rng.Intn(len(vectors))].Vector
+	}
+
+	for _, req := range []*CreateCollectionRequest{
+		{Name: "incremental", Dimensions: 8, Metric: DistanceMetricEuclidean, IndexType: IndexTypeHNSW},
+		{Name: "bulk", Dimensions: 8, Metric: DistanceMetricEuclidean, IndexType: IndexTypeHNSW, BulkLoad: true},
+	} {
+		if err := db.CreateCollection(ctx, req); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+		collection, _ := db.GetCollection(ctx, req.Name)
+		for start := 0; start < len(vectors); start += 100 {
+			if err := collection.InsertBatch(ctx, vectors[start:start+100]); err != nil {
+				t.Fatalf("InsertBatch failed: %v", err)
+			}
+		}
+	}
+	collection, _ := db.GetCollection(ctx, "incremental")
+	incremental := collection.(*VittoriaCollection)
+	collection, _ = db.GetCollection(ctx, "bulk")
+	bulk := collection.(*VittoriaCollection)
+
+	// While loading, inserts leave the graph alone and searches scan every vector
+	if size := bulk.index.Size(); size != 0 {
+		t.Fatalf("the graph holds %d vectors during the bulk load, want 0", size)
+	}
+	exact := make([][]string, len(queries))
+	for i, query := range queries {
+		exact[i] = searchIDs(t, bulk, query)
+	}
+
+	// Ending the load builds the graph at once, which finds what one built an
+	// insert at a time finds
+	if err := bulk.SetBulkLoad(ctx, false); err != nil {
+		t.Fatalf("SetBulkLoad failed: %v", err)
+	}
+	if size := bulk.index.Size(); size != len(vectors) {
+		t.Fatalf("the graph holds %d vectors after the bulk load, want %d", size, len(vectors))
+	}
+	var bulkRecall, incrementalRecall, agreement float64
+	for i, query := range queries {
+		built, grown := searchIDs(t, bulk, query), searchIDs(t, incremental, query)
+		bulkRecall += overlap(built, exact[i]) / float64(len(queries))
+		incrementalRecall += overlap(grown, exact[i]) / float64(len(queries))
+		agreement += overlap(built, grown) / float64(len(queries))
+	}
+	if bulkRecall < 0.9 || incrementalRecall < 0.9 || agreement < 0.9 {
+		t.Errorf("recall %.3f after the bulk load and %.3f inserting one at a time, agreeing on %.3f of the results", bulkRecall, incrementalRecall, agreement)
+	}
+}
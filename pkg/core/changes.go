@@ -0,0 +1,152 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChangeType identifies the kind of write a change event reports
+type ChangeType string
+
+const (
+	ChangeInsert ChangeType = "insert" // A new vector was stored
+	ChangeUpdate ChangeType = "update" // An existing vector was replaced
+	ChangeDelete ChangeType = "delete" // A vector was deleted, dropped with its namespace or expired
+)
+
+// changeType returns the change an upsert makes
+func changeType(replace bool) ChangeType {
+	if replace {
+		return ChangeUpdate
+	}
+	return ChangeInsert
+}
+
+// ChangeEvent reports one write applied to a collection
+type ChangeEvent struct {
+	Type       ChangeType `json:"type"`
+	Collection string     `json:"collection"`
+	ID         string     `json:"id"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Time       time.Time  `json:"time"`
+}
+
+// ChangeSubscription receives the change events of one collection. Events are
+// delivered in the order the writes were applied; when the subscriber falls
+// behind its buffer, further events are dropped and counted instead of
+// slowing down writers.
+type ChangeSubscription struct {
+	events chan *ChangeEvent
+	missed atomic.Int64
+	feed   *changeFeed
+}
+
+// Events returns the channel the events arrive on. It is closed when the
+// subscription is closed or the collection is dropped.
+func (s *ChangeSubscription) Events() <-chan *ChangeEvent {
+	return s.events
+}
+
+// TakeMissed returns the number of events dropped since the last call
+func (s *ChangeSubscription) TakeMissed() int64 {
+	return s.missed.Swap(0)
+}
+
+// Close stops the subscription
+func (s *ChangeSubscription) Close() {
+	s.feed.unsubscribe(s)
+}
+
+// changeFeed fans the writes of a collection out to its subscribers. Local
+// shards share the feed of their sharded collection.
+type changeFeed struct {
+	collection string
+	mu         sync.Mutex
+	subs       map[*ChangeSubscription]struct{}
+	count      atomic.Int32 // len(subs), read without the lock on every write
+	closed     bool
+}
+
+// newChangeFeed creates the feed of a collection
+func newChangeFeed(collection string) *changeFeed {
+	return &changeFeed{
+		collection: collection,
+		subs:       make(map[*ChangeSubscription]struct{}),
+	}
+}
+
+// subscribe registers a subscriber with room for buffer pending events
+func (f *changeFeed) subscribe(buffer int) *ChangeSubscription {
+	sub := &ChangeSubscription{events: make(chan *ChangeEvent, buffer), feed: f}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		close(sub.events)
+		return sub
+	}
+	f.subs[sub] = struct{}{}
+	f.count.Add(1)
+	return sub
+}
+
+// unsubscribe removes a subscriber and closes its channel
+func (f *changeFeed) unsubscribe(sub *ChangeSubscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subs[sub]; ok {
+		delete(f.subs, sub)
+		f.count.Add(-1)
+		close(sub.events)
+	}
+}
+
+// publish delivers an event to every subscriber without blocking. It is called
+// with the collection's write lock held, which keeps events in apply order.
+func (f *changeFeed) publish(changeType ChangeType, vector *Vector) {
+	if f == nil || f.count.Load() == 0 {
+		return
+	}
+
+	event := &ChangeEvent{
+		Type:       changeType,
+		Collection: f.collection,
+		ID:         vector.ID,
+		Namespace:  vector.Namespace,
+		Time:       time.Now(),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		select {
+		case sub.events <- event:
+		default:
+			sub.missed.Add(1)
+		}
+	}
+}
+
+// close ends every subscription, once the collection is dropped or closed
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		close(sub.events)
+	}
+	f.subs = make(map[*ChangeSubscription]struct{})
+	f.count.Store(0)
+	f.closed = true
+}
+
+// SubscribeChanges subscribes to the inserts, updates and deletes applied to
+// the collection, buffering up to buffer events. Writes to remote shards are
+// not reported.
+func (c *VittoriaCollection) SubscribeChanges(buffer int) *ChangeSubscription {
+	return c.changes.subscribe(buffer)
+}
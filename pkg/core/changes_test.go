@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubscribeChanges(t *testing.T) {
+	ctx := context.Background()
+
+	collection, err := NewCollection("changes", 2, DistanceMetricCosine, IndexTypeFlat, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := collection.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize collection: %v", err)
+	}
+
+	sub := collection.SubscribeChanges(16)
+	slow := collection.SubscribeChanges(1)
+
+	for _, vector := range []*Vector{
+		{ID: "a", Vector: []float32{1, 0}},
+		{ID: "a", Vector: []float32{0, 1}},
+		{ID: "b", Namespace: "acme", Vector: []float32{1, 1}},
+	} {
+		if err := collection.Insert(ctx, vector); err != nil {
+			t.Fatalf("Failed to insert vector: %v", err)
+		}
+	}
+	if _, err := collection.DropNamespace(ctx, "acme"); err != nil {
+		t.Fatalf("Failed to drop namespace: %v", err)
+	}
+	if err := collection.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Failed to delete vector: %v", err)
+	}
+
+	expected := []ChangeEvent{
+		{Type: ChangeInsert, ID: "a"},
+		{Type: ChangeUpdate, ID: "a"},
+		{Type: ChangeInsert, ID: "b", Namespace: "acme"},
+		{Type: ChangeDelete, ID: "b", Namespace: "acme"},
+		{Type: ChangeDelete, ID: "a"},
+	}
+	for i, want := range expected {
+		event := <-sub.Events()
+		if event.Type != want.Type || event.ID != want.ID || event.Namespace != want.Namespace || event.Collection != "changes" {
+			t.Errorf("Event %d: expected %s %s/%s, got %+v", i, want.Type, want.Namespace, want.ID, event)
+		}
+	}
+	if missed := sub.TakeMissed(); missed != 0 {
+		t.Errorf("Expected no missed events, got %d", missed)
+	}
+
+	// The slow subscriber keeps the first event and counts the rest
+	if event := <-slow.Events(); event.Type != ChangeInsert {
+		t.Errorf("Expected the first event to be buffered, got %+v", event)
+	}
+	if missed := slow.TakeMissed(); missed != 4 {
+		t.Errorf("Expected 4 missed events, got %d", missed)
+	}
+
+	sub.Close()
+	slow.Close()
+	if _, ok := <-sub.Events(); ok {
+		t.Error("Expected the events channel to be closed")
+	}
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checksumsFile records the checksums of a collection's vector and index
+// files, by segment, so that damage is detected and located when they load
+const checksumsFile = "checksums.json"
+
+// vectorsFileName is the file the collection's records are persisted to
+const vectorsFileName = "vectors.json"
+
+// vectorSegmentSize is the number of vectors of a checksummed segment of the
+// vectors file. Damage is reported, and records lost, a segment at a time.
+const vectorSegmentSize = 1024
+
+// indexSegmentSize is the number of bytes of a checksummed segment of the
+// index file
+const indexSegmentSize = 1 << 20
+
+// checksumTable is the CRC-32C polynomial, which CPUs compute in hardware
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumManifest holds the checksums of the files of a collection
+type checksumManifest struct {
+	Files map[string]*checksumEntry `json:"files"`
+}
+
+// checksumEntry holds the checksums of a file and of the content it
+// replaced: the manifest is written first, so the file may still hold the
+// previous content when the process stopped in between
+type checksumEntry struct {
+	Current  *fileChecksums `json:"current"`
+	Previous *fileChecksums `json:"previous,omitempty"`
+}
+
+// fileChecksums are the checksums of a file's content and of its segments
+type fileChecksums struct {
+	Size     int64              `json:"size"`
+	CRC32    uint32             `json:"crc32"`
+	Segments []*segmentChecksum `json:"segments"`
+}
+
+// segmentChecksum is the CRC-32C of a byte range of a file. Segments of the
+// vectors file also record the vectors they hold.
+type segmentChecksum struct {
+	Offset  int64  `json:"offset"`
+	Length  int64  `json:"length"`
+	CRC32   uint32 `json:"crc32"`
+	Vectors int    `json:"vectors,omitempty"`
+	First   string `json:"first,omitempty"` // Key of the first vector
+	Last    string `json:"last,omitempty"`  // Key of the last vector
+}
+
+// damagedSegment is a segment whose content no longer matches its checksum
+type damagedSegment struct {
+	file    string
+	number  int // From 1; 0 for bytes outside the segments
+	total   int
+	segment *segmentChecksum
+}
+
+func (d *damagedSegment) String() string {
+	if d.number == 0 {
+		return fmt.Sprintf("%s outside its %d segments", d.file, d.total)
+	}
+	description := fmt.Sprintf("%s segment %d of %d (bytes %d-%d", d.file, d.number, d.total, d.segment.Offset, d.segment.Offset+d.segment.Length-1)
+	if d.segment.Vectors > 0 {
+		description += fmt.Sprintf(", %d vectors from '%s' to '%s'", d.segment.Vectors, d.segment.First, d.segment.Last)
+	}
+	return description + ")"
+}
+
+// describeDamage lists damaged segments in a sentence
+func describeDamage(damaged []*damagedSegment) string {
+	parts := make([]string, len(damaged))
+	for i, segment := range damaged {
+		parts[i] = segment.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// newSegmentChecksum returns the checksum of data[offset:offset+length]
+func newSegmentChecksum(data []byte, offset, length int) *segmentChecksum {
+	return &segmentChecksum{
+		Offset: int64(offset),
+		Length: int64(length),
+		CRC32:  crc32.Checksum(data[offset:offset+length], checksumTable),
+	}
+}
+
+// intact reports whether the segment's bytes of data match its checksum
+func (s *segmentChecksum) intact(data []byte) bool {
+	end := s.Offset + s.Length
+	if s.Offset < 0 || end > int64(len(data)) {
+		return false
+	}
+	return crc32.Checksum(data[s.Offset:end], checksumTable) == s.CRC32
+}
+
+// byteChecksums returns the checksums of data split in segments of size bytes
+func byteChecksums(data []byte, size int) *fileChecksums {
+	sums := &fileChecksums{Size: int64(len(data)), CRC32: crc32.Checksum(data, checksumTable)}
+	for offset := 0; offset < len(data); offset += size {
+		sums.Segments = append(sums.Segments, newSegmentChecksum(data, offset, min(size, len(data)-offset)))
+	}
+	return sums
+}
+
+// damaged returns the segments of data that do not match the checksums
+func (f *fileChecksums) damaged(file string, data []byte) []*damagedSegment {
+	if int64(len(data)) == f.Size && crc32.Checksum(data, checksumTable) == f.CRC32 {
+		return nil
+	}
+	var damaged []*damagedSegment
+	for i, segment := range f.Segments {
+		if !segment.intact(data) {
+			damaged = append(damaged, &damagedSegment{file: file, number: i + 1, total: len(f.Segments), segment: segment})
+		}
+	}
+	if len(damaged) == 0 {
+		// The bytes between or after the segments changed
+		damaged = append(damaged, &damagedSegment{file: file, total: len(f.Segments)})
+	}
+	return damaged
+}
+
+// check compares data with the checksums of the file's current and previous
+// content, returning the damaged segments when it matches neither. Without
+// checksums, as for files written before they were recorded, nothing is
+// damaged.
+func (e *checksumEntry) check(file string, data []byte) (*fileChecksums, []*damagedSegment) {
+	if e == nil || e.Current == nil {
+		return nil, nil
+	}
+	damaged := e.Current.damaged(file, data)
+	if len(damaged) == 0 {
+		return e.Current, nil
+	}
+	if e.Previous != nil {
+		if previous := e.Previous.damaged(file, data); len(previous) == 0 {
+			return e.Previous, nil
+		} else if e.Previous.Size == int64(len(data)) && e.Current.Size != int64(len(data)) {
+			// Located against the content of the same size
+			return e.Previous, previous
+		}
+	}
+	return e.Current, damaged
+}
+
+// readChecksums reads the checksum manifest of a collection directory,
+// returning an empty one when there is none
+func readChecksums(dir string) (*checksumManifest, error) {
+	manifest := &checksumManifest{Files: make(map[string]*checksumEntry)}
+	data, err := os.ReadFile(filepath.Join(dir, checksumsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, manifest)
+	}
+	if err != nil {
+		return &checksumManifest{Files: make(map[string]*checksumEntry)}, fmt.Errorf("unreadable %s: %w", checksumsFile, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]*checksumEntry)
+	}
+	return manifest, nil
+}
+
+// writeChecksummed records the checksums of a file of the collection, then
+// writes the file; the caller holds mu
+func (c *VittoriaCollection) writeChecksummed(file string, data []byte, sums *fileChecksums) error {
+	if c.checksums == nil {
+		c.checksums = &checksumManifest{Files: make(map[string]*checksumEntry)}
+	}
+	entry := &checksumEntry{Current: sums}
+	if previous := c.checksums.Files[file]; previous != nil {
+		entry.Previous = previous.Current
+	}
+	c.checksums.Files[file] = entry
+
+	manifest, err := json.Marshal(c.checksums)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(c.dataDir, checksumsFile), manifest); err != nil {
+		return fmt.Errorf("failed to save checksums: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(c.dataDir, file), data)
+}
+
+// forgetChecksums drops the checksums of a removed file; the caller holds mu
+func (c *VittoriaCollection) forgetChecksums(file string) {
+	if c.checksums != nil {
+		delete(c.checksums.Files, file)
+	}
+}
+
+// encodeVectors encodes vectors as the JSON object of the vectors file, in
+// key order, with the checksums of segments of vectorSegmentSize vectors.
+// Each segment is a list of object members, so that an intact segment
+// decodes on its own when others are damaged.
+func encodeVectors(vectors map[string]*Vector, encode func(*Vector) (*Vector, error)) ([]byte, *fileChecksums, error) {
+	keys := make([]string, 0, len(vectors))
+	for key := range vectors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	var segments []*segmentChecksum
+	buf.WriteString("{\n")
+	for start := 0; start < len(keys); start += vectorSegmentSize {
+		if start > 0 {
+			buf.WriteString(",\n")
+		}
+		offset := buf.Len()
+		end := min(start+vectorSegmentSize, len(keys))
+		for i, key := range keys[start:end] {
+			vector, err := encode(vectors[key])
+			if err != nil {
+				return nil, nil, err
+			}
+			name, err := json.Marshal(key)
+			if err != nil {
+				return nil, nil, err
+			}
+			value, err := json.MarshalIndent(vector, "  ", "  ")
+			if err != nil {
+				return nil, nil, err
+			}
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString("  ")
+			buf.Write(name)
+			buf.WriteString(": ")
+			buf.Write(value)
+		}
+		segment := newSegmentChecksum(buf.Bytes(), offset, buf.Len()-offset)
+		segment.Vectors, segment.First, segment.Last = end-start, keys[start], keys[end-1]
+		segments = append(segments, segment)
+	}
+	buf.WriteString("\n}\n")
+
+	data := buf.Bytes()
+	return data, &fileChecksums{Size: int64(len(data)), CRC32: crc32.Checksum(data, checksumTable), Segments: segments}, nil
+}
+
+// decodeVectors decodes the vectors file. When its content matches no
+// checksums, the vectors of the intact segments are returned with the
+// damaged segments.
+func decodeVectors(data []byte, entry *checksumEntry) (map[string]*Vector, []*damagedSegment, error) {
+	vectors := make(map[string]*Vector)
+	sums, damaged := entry.check(vectorsFileName, data)
+	if len(damaged) == 0 {
+		if err := json.Unmarshal(data, &vectors); err != nil {
+			return nil, nil, err
+		}
+		return vectors, nil, nil
+	}
+
+	for i, segment := range sums.Segments {
+		if !segment.intact(data) {
+			continue
+		}
+		members := data[segment.Offset : segment.Offset+segment.Length]
+		object := make([]byte, 0, len(members)+2)
+		object = append(append(append(object, '{'), members...), '}')
+		if err := json.Unmarshal(object, &vectors); err != nil {
+			// Intact, yet not a list of members: the checksums are wrong
+			return nil, nil, fmt.Errorf("segment %d of %s does not decode: %w", i+1, vectorsFileName, err)
+		}
+	}
+	return vectors, damaged, nil
+}
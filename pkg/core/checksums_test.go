@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	db := openTestDatabase(t, &Config{DataDir: dataDir})
+	for _, req := range []*CreateCollectionRequest{
+		{Name: "records", Dimensions: 3, IndexType: IndexTypeFlat},
+		{Name: "graph", Dimensions: 3, IndexType: IndexTypeHNSW},
+	} {
+		if err := db.CreateCollection(ctx, req); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+	}
+	records, _ := db.GetCollection(ctx, "records")
+	vectors := make([]*Vector, 2*vectorSegmentSize+10)
+	for i := range vectors {
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%05d", i), Vector: []float32{float32(i), 1, 2}}
+	}
+	if err := records.InsertBatch(ctx, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	graph, _ := db.GetCollection(ctx, "graph")
+	if err := graph.InsertBatch(ctx, vectors[:50]); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	db.Close()
+
+	// A sound data directory loads as it was saved
+	db = openTestDatabase(t, &Config{DataDir: dataDir})
+	records, err := db.GetCollection(ctx, "records")
+	if err != nil {
+		t.Fatalf("GetCollection failed: %v", err)
+	}
+	if count, _ := records.Count(); count != int64(len(vectors)) {
+		t.Fatalf("expected %d vectors, got %d", len(vectors), count)
+	}
+	db.Close()
+
+	// Damage the second segment of the vectors and the index
+	manifest, err := readChecksums(filepath.Join(dataDir, "records"))
+	if err != nil {
+		t.Fatalf("readChecksums failed: %v", err)
+	}
+	segments := manifest.Files[vectorsFileName].Current.Segments
+	if len(segments) != 3 {
+		t.Fatalf("expected 3 segments, got %d", len(segments))
+	}
+	vectorsPath := filepath.Join(dataDir, "records", vectorsFileName)
+	data, _ := os.ReadFile(vectorsPath)
+	offset := segments[1].Offset + segments[1].Length/2
+	data[offset] ^= 0xff
+	if err := os.WriteFile(vectorsPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	indexPath := filepath.Join(dataDir, "graph", indexFileName)
+	data, _ = os.ReadFile(indexPath)
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(indexPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	report, err := VerifyDataDir(ctx, dataDir)
+	if err != nil {
+		t.Fatalf("VerifyDataDir failed: %v", err)
+	}
+	for _, result := range report.Collections {
+		if len(result.Issues) != 1 || !strings.Contains(result.Issues[0].Problem, "checksum mismatch") {
+			t.Fatalf("%s: unexpected issues %+v", result.Collection, result.Issues)
+		}
+		if result.Collection == "records" && !strings.Contains(result.Issues[0].Problem, "segment 2 of 3") {
+			t.Errorf("damage not located: %s", result.Issues[0].Problem)
+		}
+	}
+
+	// By default the damaged collection fails to load, naming the segment
+	_, err = openCollection("records", dataDir, indexOptions{}, true)
+	if err == nil || !strings.Contains(err.Error(), "segment 2 of 3") || !strings.Contains(err.Error(), "v01024") {
+		t.Fatalf("expected the damaged segment to fail the load, got %v", err)
+	}
+
+	// Recovering keeps the intact segments, and the damaged index is rebuilt
+	db = openTestDatabase(t, &Config{DataDir: dataDir, Storage: StorageConfig{RecoverCorrupted: true}})
+	records, err = db.GetCollection(ctx, "records")
+	if err != nil {
+		t.Fatalf("GetCollection failed: %v", err)
+	}
+	if count, _ := records.Count(); count != int64(len(vectors)-vectorSegmentSize) {
+		t.Fatalf("expected %d recovered vectors, got %d", len(vectors)-vectorSegmentSize, count)
+	}
+	if _, err := records.Get(ctx, "v01023"); err != nil {
+		t.Errorf("vector of an intact segment lost: %v", err)
+	}
+	if _, err := records.Get(ctx, "v01024"); err == nil {
+		t.Error("vector of the damaged segment loaded")
+	}
+	if _, err := os.Stat(vectorsPath + ".damaged"); err != nil {
+		t.Errorf("damaged vectors not set aside: %v", err)
+	}
+	graph, err = db.GetCollection(ctx, "graph")
+	if err != nil {
+		t.Fatalf("GetCollection failed: %v", err)
+	}
+	response, err := graph.Search(ctx, &SearchRequest{Vector: []float32{10, 1, 2}, Limit: 1})
+	if err != nil || len(response.Results) != 1 || response.Results[0].ID != "v00010" {
+		t.Fatalf("search of the rebuilt index failed: %+v %v", response, err)
+	}
+	db.Close()
+
+	// The recovered vectors were saved with new checksums
+	db = openTestDatabase(t, &Config{DataDir: dataDir})
+	if _, err := db.GetCollection(ctx, "records"); err != nil {
+		t.Fatalf("recovered collection fails to load: %v", err)
+	}
+}
@@ -4,14 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/index"
 )
 
+// maxExpectedCount bounds capacity hints so a typo cannot pre-allocate unbounded memory
+const maxExpectedCount = 100_000_000
+
 // VittoriaCollection implements the Collection interface
 type VittoriaCollection struct {
 	name           string
@@ -23,10 +30,39 @@ type VittoriaCollection struct {
 	mu             sync.RWMutex
 	created        time.Time
 	modified       time.Time
+	generation     atomic.Uint64 // Bumped by every write, so that cached search results of earlier data are never served
 	closed         bool
 	vectorizer     embeddings.Vectorizer
+	vectorizerConf *embeddings.VectorizerConfig // Persisted vectorizer settings, without secrets
 	contentStorage *ContentStorageConfig
 	searchEngine   *ParallelSearchEngine // Enhanced search capabilities
+	index          index.Index           // ANN index (nil for flat and sharded collections)
+	sharding       *ShardingConfig       // Sharding configuration (nil when not sharded)
+	shards         []shard               // Shards a sharded collection fans out to
+	shardMu        sync.RWMutex          // Guards shards; acquired after mu when both are held
+	expectedCount  int                   // Capacity hint for pre-sizing the vector map and index
+	bulkLoading    bool                  // Index construction is deferred until the bulk load ends
+	indexOptions   indexOptions          // Database-wide settings for the HNSW index
+	hnsw           *HNSWParams           // HNSW parameters set on the collection (nil takes the database-wide ones)
+	changes        *changeFeed           // Subscribers to inserts, updates and deletes
+	internal       bool                  // Hidden from default listings and protected from DropCollection
+	group          string                // Collection group this collection stores a field of
+	readOnly       bool                  // Opened by a reader of a data directory another process writes
+	models         []*EmbeddingModel     // Models that generated the collection's embeddings
+	quantization   *QuantizationConfig   // nil unless vectors are quantized
+	quantizer      *scalarQuantizer      // Codes of quantized vectors
+	originals      *originalsFile        // Full-precision vectors of a quantized collection
+	indexedFields  []string              // Metadata fields with range indexes
+	fieldIndexes   []*fieldIndex         // Range indexes of indexedFields (nil for sharded collections)
+	lastFlush      time.Time             // When the vectors were last written, zero before the first
+	lastCompaction time.Time             // When the collection was last compacted, kept in its metadata
+	checksums      *checksumManifest     // Checksums of the vector and index files (nil until loaded or first saved)
+
+	rebuildMu         sync.Mutex      // Guards rebuild; acquired after mu when both are held
+	rebuild           *rebuildJob     // Latest background index rebuild (nil if none since opening)
+	rebuildDirty      map[string]bool // Keys written while a rebuild runs (nil otherwise)
+	builtSize         int             // Index entries when the index was last built
+	removedSinceBuild int             // Index entries removed since then
 }
 
 // CollectionMetadata represents collection metadata stored on disk
@@ -38,25 +74,46 @@ type CollectionMetadata struct {
 	Created        time.Time             `json:"created"`
 	Modified       time.Time             `json:"modified"`
 	ContentStorage *ContentStorageConfig `json:"content_storage,omitempty"`
+	Sharding       *ShardingConfig       `json:"sharding,omitempty"`
+	ExpectedCount  int                   `json:"expected_count,omitempty"`
+	BulkLoad       bool                  `json:"bulk_load,omitempty"`
+	Internal       bool                  `json:"internal,omitempty"`
+	Quantization   *QuantizationConfig   `json:"quantization,omitempty"`
+	IndexedFields  []string              `json:"indexed_fields,omitempty"`
+	LastCompaction time.Time             `json:"last_compaction,omitzero"`
+	HNSW           *HNSWParams           `json:"hnsw,omitempty"`
+
+	Vectorizer      *embeddings.VectorizerConfig `json:"vectorizer,omitempty"` // Without inline API keys
+	EmbeddingModels []*EmbeddingModel            `json:"embedding_models,omitempty"`
 }
 
 // NewCollection creates a new collection
 func NewCollection(name string, dimensions int, metric DistanceMetric, indexType IndexType, dataDir string) (*VittoriaCollection, error) {
+	collectionDir, err := collectionPath(dataDir, name)
+	if err != nil {
+		return nil, err
+	}
+
 	collection := &VittoriaCollection{
 		name:           name,
 		dimensions:     dimensions,
 		metric:         metric,
 		indexType:      indexType,
-		dataDir:        filepath.Join(dataDir, name),
+		dataDir:        collectionDir,
 		vectors:        make(map[string]*Vector),
 		created:        time.Now(),
 		modified:       time.Now(),
 		contentStorage: DefaultContentStorageConfig(),
+		changes:        newChangeFeed(name),
 	}
 
 	// Initialize parallel search engine
 	collection.searchEngine = NewParallelSearchEngine(collection, DefaultParallelSearchConfig())
 
+	if err := collection.initIndex(); err != nil {
+		return nil, err
+	}
+
 	return collection, nil
 }
 
@@ -65,22 +122,31 @@ func NewCollectionWithContentStorage(name string, dimensions int, metric Distanc
 	if contentStorage == nil {
 		contentStorage = DefaultContentStorageConfig()
 	}
+	collectionDir, err := collectionPath(dataDir, name)
+	if err != nil {
+		return nil, err
+	}
 
 	collection := &VittoriaCollection{
 		name:           name,
 		dimensions:     dimensions,
 		metric:         metric,
 		indexType:      indexType,
-		dataDir:        filepath.Join(dataDir, name),
+		dataDir:        collectionDir,
 		vectors:        make(map[string]*Vector),
 		created:        time.Now(),
 		modified:       time.Now(),
 		contentStorage: contentStorage,
+		changes:        newChangeFeed(name),
 	}
 
 	// Initialize parallel search engine
 	collection.searchEngine = NewParallelSearchEngine(collection, DefaultParallelSearchConfig())
 
+	if err := collection.initIndex(); err != nil {
+		return nil, err
+	}
+
 	return collection, nil
 }
 
@@ -104,6 +170,9 @@ func (c *VittoriaCollection) GetContentStorageConfig() *ContentStorageConfig {
 
 // SetContentStorageConfig updates the content storage configuration
 func (c *VittoriaCollection) SetContentStorageConfig(config *ContentStorageConfig) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
 	if config == nil {
 		return fmt.Errorf("content storage config cannot be nil")
 	}
@@ -129,14 +198,144 @@ func (c *VittoriaCollection) SetContentStorageConfig(config *ContentStorageConfi
 	}
 
 	// Mark collection as modified
-	c.modified = time.Now()
+	c.touch()
 
 	return nil
 }
 
+// Update applies the settings present in req
+func (c *VittoriaCollection) Update(ctx context.Context, req *UpdateCollectionRequest) error {
+	if req.ExpectedCount != nil {
+		if err := c.SetExpectedCount(ctx, *req.ExpectedCount); err != nil {
+			return err
+		}
+	}
+	if req.BulkLoad != nil {
+		if err := c.SetBulkLoad(ctx, *req.BulkLoad); err != nil {
+			return err
+		}
+	}
+	if req.Internal != nil {
+		if err := c.SetInternal(*req.Internal); err != nil {
+			return err
+		}
+	}
+	if req.Vectorizer != nil {
+		if err := c.SetVectorizerConfig(req.Vectorizer); err != nil {
+			return err
+		}
+	}
+	if req.EfSearch != nil {
+		if err := c.SetEfSearch(ctx, *req.EfSearch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Internal reports whether the collection is internal: left out of default
+// listings and statistics, and protected from DropCollection
+func (c *VittoriaCollection) Internal() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.internal
+}
+
+// SetInternal marks the collection internal or not. The collections of a
+// group stay internal.
+func (c *VittoriaCollection) SetInternal(internal bool) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errorf(ErrClosed, "collection is closed")
+	}
+	if !internal && c.group != "" {
+		return errorf(ErrConflict, "collection '%s' belongs to group '%s' and stays internal", c.name, c.group)
+	}
+	if c.internal == internal {
+		return nil
+	}
+
+	c.internal = internal
+	c.touch()
+	return c.saveMetadata()
+}
+
+// SetExpectedCount updates the capacity hint and grows the vector map and
+// index so that loading up to n vectors does not trigger incremental growth
+func (c *VittoriaCollection) SetExpectedCount(ctx context.Context, n int) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	if n < 0 {
+		return fmt.Errorf("expected_count cannot be negative")
+	}
+	if n > maxExpectedCount {
+		return fmt.Errorf("expected_count cannot exceed %d", maxExpectedCount)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errorf(ErrClosed, "collection is closed")
+	}
+
+	c.reserve(n)
+
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		perShard := c.perShardCount()
+		for i, s := range c.shards {
+			if err := s.SetExpectedCount(ctx, perShard); err != nil {
+				return fmt.Errorf("shard %s: %w", c.shardName(i), err)
+			}
+		}
+	}
+
+	c.touch()
+	return c.saveMetadata()
+}
+
+// reserve records the capacity hint and pre-sizes the vector map and index.
+// The caller must hold c.mu or own the collection exclusively.
+func (c *VittoriaCollection) reserve(n int) {
+	c.expectedCount = n
+	if c.isSharded() {
+		// The coordinator stores nothing; shards receive their share
+		return
+	}
+
+	if n > len(c.vectors) {
+		vectors := make(map[string]*Vector, n)
+		for id, vector := range c.vectors {
+			vectors[id] = vector
+		}
+		c.vectors = vectors
+	}
+	if c.index != nil {
+		c.index.Reserve(n)
+	}
+}
+
 // LoadCollection loads an existing collection from disk
 func LoadCollection(name string, dataDir string) (*VittoriaCollection, error) {
-	collectionDir := filepath.Join(dataDir, name)
+	return openCollection(name, dataDir, indexOptions{}, false)
+}
+
+// openCollection loads an existing collection from disk, creating its index
+// with the given options. A read-only collection never writes to disk.
+func openCollection(name string, dataDir string, options indexOptions, readOnly bool) (*VittoriaCollection, error) {
+	collectionDir, err := collectionPath(dataDir, name)
+	if err != nil {
+		return nil, err
+	}
 	metadataPath := filepath.Join(collectionDir, "metadata.json")
 
 	// Read metadata
@@ -156,22 +355,79 @@ func LoadCollection(name string, dataDir string) (*VittoriaCollection, error) {
 		contentStorage = DefaultContentStorageConfig()
 	}
 
+	// The directory names the collection, whatever the metadata says
 	collection := &VittoriaCollection{
-		name:           metadata.Name,
+		name:           name,
 		dimensions:     metadata.Dimensions,
 		metric:         metadata.Metric,
 		indexType:      metadata.IndexType,
 		dataDir:        collectionDir,
-		vectors:        make(map[string]*Vector),
+		vectors:        make(map[string]*Vector, metadata.ExpectedCount),
 		created:        metadata.Created,
 		modified:       metadata.Modified,
 		contentStorage: contentStorage,
+		expectedCount:  metadata.ExpectedCount,
+		bulkLoading:    metadata.BulkLoad,
+		internal:       metadata.Internal,
+		indexOptions:   options,
+		hnsw:           metadata.HNSW,
+		changes:        newChangeFeed(name),
+		vectorizerConf: metadata.Vectorizer,
+		readOnly:       readOnly,
+		models:         metadata.EmbeddingModels,
+		lastCompaction: metadata.LastCompaction,
+	}
+	collection.searchEngine = NewParallelSearchEngine(collection, DefaultParallelSearchConfig())
+	collection.applySearchOptions()
+
+	// Files are then loaded unchecked, and checksummed again as they are saved
+	if collection.checksums, err = readChecksums(collectionDir); err != nil {
+		fmt.Printf("Ignoring the checksums of collection %s: %v\n", name, err)
+	}
+
+	// Recreate the vectorizer. A collection whose API key is gone still opens,
+	// for vector operations; text operations report the missing vectorizer.
+	if metadata.Vectorizer != nil {
+		vectorizer, err := embeddings.NewVectorizerFactory().CreateVectorizer(options.vectorizer(metadata.Vectorizer))
+		if err != nil {
+			fmt.Printf("Error restoring vectorizer of collection %s: %v\n", name, err)
+		} else {
+			collection.vectorizer = vectorizer
+		}
+	}
+
+	// A sharded collection only coordinates its shards
+	if metadata.Sharding != nil {
+		if err := collection.enableSharding(metadata.Sharding); err != nil {
+			return nil, fmt.Errorf("invalid sharding config: %w", err)
+		}
+		collection.setIndexedFields(metadata.IndexedFields)
+		if err := collection.openShards(context.Background(), false); err != nil {
+			return nil, err
+		}
+		return collection, nil
 	}
 
 	// Load vectors from disk
 	if err := collection.loadVectors(); err != nil {
 		return nil, fmt.Errorf("failed to load vectors: %w", err)
 	}
+	collection.packVectors()
+	collection.normVectors()
+	collection.setIndexedFields(metadata.IndexedFields)
+	if metadata.Quantization != nil {
+		if err := collection.enableQuantization(metadata.Quantization); err != nil {
+			return nil, err
+		}
+	}
+
+	// Restore (or rebuild) the ANN index
+	if err := collection.loadIndex(); err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	if collection.index != nil && collection.expectedCount > 0 {
+		collection.index.Reserve(collection.expectedCount)
+	}
 
 	return collection, nil
 }
@@ -191,6 +447,13 @@ func (c *VittoriaCollection) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to save metadata: %w", err)
 	}
 
+	// Create the shards of a sharded collection
+	if c.isSharded() {
+		if err := c.openShards(ctx, true); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -202,22 +465,48 @@ func (c *VittoriaCollection) Close() error {
 	if c.closed {
 		return nil
 	}
+	c.cancelRebuild()
+	if c.searchEngine != nil {
+		c.searchEngine.Close()
+	}
+
+	if c.isSharded() {
+		if err := c.closeShards(); err != nil {
+			return err
+		}
+	}
+	if c.readOnly {
+		c.closeOriginals()
+		c.closed = true
+		return nil
+	}
 
 	// Save vectors to disk
 	if err := c.saveVectors(); err != nil {
 		return fmt.Errorf("failed to save vectors: %w", err)
 	}
 
+	// Save index to disk
+	if err := c.saveIndex(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+
 	// Update metadata
-	c.modified = time.Now()
+	c.touch()
 	if err := c.saveMetadata(); err != nil {
 		return fmt.Errorf("failed to save metadata: %w", err)
 	}
 
+	c.closeOriginals()
 	c.closed = true
 	return nil
 }
 
+// errReadOnly is the error of a write to a read-only collection
+func (c *VittoriaCollection) errReadOnly() error {
+	return errorf(ErrReadOnly, "collection '%s' is open read-only", c.name)
+}
+
 // Name returns the collection name
 func (c *VittoriaCollection) Name() string {
 	return c.name
@@ -235,11 +524,15 @@ func (c *VittoriaCollection) Metric() DistanceMetric {
 
 // Count returns the number of vectors in the collection
 func (c *VittoriaCollection) Count() (int64, error) {
+	if c.isSharded() {
+		return c.shardedCount()
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if c.closed {
-		return 0, fmt.Errorf("collection is closed")
+		return 0, errorf(ErrClosed, "collection is closed")
 	}
 
 	return int64(len(c.vectors)), nil
@@ -247,11 +540,18 @@ func (c *VittoriaCollection) Count() (int64, error) {
 
 // Insert inserts a vector into the collection
 func (c *VittoriaCollection) Insert(ctx context.Context, vector *Vector) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	if c.isSharded() {
+		return c.shardedInsertBatch(ctx, []*Vector{vector})
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.closed {
-		return fmt.Errorf("collection is closed")
+		return errorf(ErrClosed, "collection is closed")
 	}
 
 	// Validate vector
@@ -259,36 +559,62 @@ func (c *VittoriaCollection) Insert(ctx context.Context, vector *Vector) error {
 		return err
 	}
 
+	key := vector.key()
+	previous, replace := c.vectors[key]
+
 	// Store vector
-	c.vectors[vector.ID] = &Vector{
-		ID:       vector.ID,
-		Vector:   make([]float32, len(vector.Vector)),
-		Metadata: make(map[string]interface{}),
+	c.vectors[key] = &Vector{
+		ID:        vector.ID,
+		Namespace: vector.Namespace,
+		Vector:    make([]float32, len(vector.Vector)),
+		Metadata:  make(map[string]interface{}),
 	}
 
 	// Copy vector data
-	copy(c.vectors[vector.ID].Vector, vector.Vector)
+	copy(c.vectors[key].Vector, vector.Vector)
+	c.vectors[key].bits = c.packVector(c.vectors[key])
+	c.normVector(c.vectors[key])
+	if err := c.quantizeVector(c.vectors[key]); err != nil {
+		return fmt.Errorf("failed to quantize vector: %w", err)
+	}
 
 	// Copy metadata
 	if vector.Metadata != nil {
 		for k, v := range vector.Metadata {
-			c.vectors[vector.ID].Metadata[k] = v
+			c.vectors[key].Metadata[k] = v
 		}
 	}
 
-	c.modified = time.Now()
+	if err := c.indexUpsert(ctx, c.vectors[key], previous); err != nil {
+		return fmt.Errorf("failed to index vector: %w", err)
+	}
+	c.indexFields(c.vectors[key])
+	c.changes.publish(changeType(replace), c.vectors[key])
+
+	c.touch()
 	return nil
 }
 
 // InsertBatch inserts multiple vectors into the collection
 func (c *VittoriaCollection) InsertBatch(ctx context.Context, vectors []*Vector) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	if c.isSharded() {
+		return c.shardedInsertBatch(ctx, vectors)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.closed {
-		return fmt.Errorf("collection is closed")
+		return errorf(ErrClosed, "collection is closed")
 	}
+	return c.insertLocked(ctx, vectors)
+}
 
+// insertLocked validates and stores vectors; the caller holds mu
+func (c *VittoriaCollection) insertLocked(ctx context.Context, vectors []*Vector) error {
 	// Validate all vectors first
 	for _, vector := range vectors {
 		if err := c.validateVector(vector); err != nil {
@@ -298,49 +624,98 @@ func (c *VittoriaCollection) InsertBatch(ctx context.Context, vectors []*Vector)
 
 	// Insert all vectors
 	for _, vector := range vectors {
-		c.vectors[vector.ID] = &Vector{
-			ID:       vector.ID,
-			Vector:   make([]float32, len(vector.Vector)),
-			Metadata: make(map[string]interface{}),
+		key := vector.key()
+		previous, replace := c.vectors[key]
+
+		c.vectors[key] = &Vector{
+			ID:        vector.ID,
+			Namespace: vector.Namespace,
+			Vector:    make([]float32, len(vector.Vector)),
+			Metadata:  make(map[string]interface{}),
 		}
 
 		// Copy vector data
-		copy(c.vectors[vector.ID].Vector, vector.Vector)
+		copy(c.vectors[key].Vector, vector.Vector)
+		c.vectors[key].bits = c.packVector(c.vectors[key])
+		c.normVector(c.vectors[key])
+		if err := c.quantizeVector(c.vectors[key]); err != nil {
+			return fmt.Errorf("failed to quantize vector %s: %w", vector.ID, err)
+		}
 
 		// Copy metadata
 		if vector.Metadata != nil {
 			for k, v := range vector.Metadata {
-				c.vectors[vector.ID].Metadata[k] = v
+				c.vectors[key].Metadata[k] = v
 			}
 		}
+
+		if err := c.indexUpsert(ctx, c.vectors[key], previous); err != nil {
+			return fmt.Errorf("failed to index vector %s: %w", vector.ID, err)
+		}
+		c.indexFields(c.vectors[key])
+		c.changes.publish(changeType(replace), c.vectors[key])
 	}
 
-	c.modified = time.Now()
+	c.touch()
 	return nil
 }
 
-// Get retrieves a vector by ID
+// Get retrieves a vector by ID from the default namespace
 func (c *VittoriaCollection) Get(ctx context.Context, id string) (*Vector, error) {
+	return c.GetInNamespace(ctx, "", id)
+}
+
+// GetInNamespace retrieves a vector by ID from the given namespace
+func (c *VittoriaCollection) GetInNamespace(ctx context.Context, namespace, id string) (*Vector, error) {
+	return c.get(ctx, namespace, id, false)
+}
+
+// GetView retrieves a vector by ID from the given namespace without copying
+// its components and metadata, which stay owned by the collection: callers
+// may read them, and keep them, but must never modify them. Writes replace
+// stored vectors rather than modify them, so a view keeps showing the vector
+// as it was retrieved. Quantized collections read the components from disk
+// or widen them from half precision, and remote shards send copies.
+func (c *VittoriaCollection) GetView(ctx context.Context, namespace, id string) (*Vector, error) {
+	return c.get(ctx, namespace, id, true)
+}
+
+// get retrieves a vector by ID from the given namespace, a view of the
+// stored one when view is set
+func (c *VittoriaCollection) get(ctx context.Context, namespace, id string, view bool) (*Vector, error) {
+	if c.isSharded() {
+		return c.shardedGet(ctx, namespace, id, view)
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if c.closed {
-		return nil, fmt.Errorf("collection is closed")
+		return nil, errorf(ErrClosed, "collection is closed")
 	}
 
-	vector, exists := c.vectors[id]
-	if !exists {
-		return nil, fmt.Errorf("vector '%s' not found", id)
+	vector, exists := c.vectors[vectorKey(namespace, id)]
+	if !exists || isExpired(vector, time.Now()) {
+		return nil, errorf(ErrNotFound, "vector '%s' not found", id)
+	}
+
+	data, err := c.vectorData(vector)
+	if err != nil {
+		return nil, err
+	}
+	if view {
+		return &Vector{ID: vector.ID, Namespace: vector.Namespace, Vector: data, Metadata: vector.Metadata}, nil
 	}
 
 	// Return a copy to prevent external modification
 	result := &Vector{
-		ID:       vector.ID,
-		Vector:   make([]float32, len(vector.Vector)),
-		Metadata: make(map[string]interface{}),
+		ID:        vector.ID,
+		Namespace: vector.Namespace,
+		Vector:    make([]float32, len(data)),
+		Metadata:  make(map[string]interface{}),
 	}
 
-	copy(result.Vector, vector.Vector)
+	copy(result.Vector, data)
 	for k, v := range vector.Metadata {
 		result.Metadata[k] = v
 	}
@@ -348,28 +723,83 @@ func (c *VittoriaCollection) Get(ctx context.Context, id string) (*Vector, error
 	return result, nil
 }
 
-// Delete removes a vector by ID
+// Delete removes a vector by ID from the default namespace
 func (c *VittoriaCollection) Delete(ctx context.Context, id string) error {
+	return c.DeleteInNamespace(ctx, "", id)
+}
+
+// DeleteInNamespace removes a vector by ID from the given namespace
+func (c *VittoriaCollection) DeleteInNamespace(ctx context.Context, namespace, id string) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	if c.isSharded() {
+		return c.shardedDelete(ctx, namespace, id)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.closed {
-		return fmt.Errorf("collection is closed")
+		return errorf(ErrClosed, "collection is closed")
 	}
 
-	if _, exists := c.vectors[id]; !exists {
-		return fmt.Errorf("vector '%s' not found", id)
+	key := vectorKey(namespace, id)
+	vector, exists := c.vectors[key]
+	if !exists {
+		return errorf(ErrNotFound, "vector '%s' not found", id)
 	}
 
-	delete(c.vectors, id)
-	c.modified = time.Now()
+	if err := c.indexRemove(ctx, vector); err != nil {
+		return fmt.Errorf("failed to remove vector from index: %w", err)
+	}
+
+	delete(c.vectors, key)
+	c.changes.publish(ChangeDelete, vector)
+	c.touch()
 	return nil
 }
 
 // Search performs vector similarity search
 func (c *VittoriaCollection) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
 	if c.closed {
-		return nil, fmt.Errorf("collection is closed")
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	if c.isSharded() {
+		return c.shardedSearch(ctx, req)
+	}
+
+	req, explain := c.planSearch(req)
+	response, err := c.dispatchSearch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	addPlan(response, explain)
+	return response, nil
+}
+
+// addPlan adds the filter plan of a search to the explanation of its
+// response, which holds how the index applied the filter
+func addPlan(response *SearchResponse, explain *SearchExplain) {
+	if explain == nil {
+		return
+	}
+	if response.Explain != nil {
+		explain.FilterStrategy = response.Explain.FilterStrategy
+		explain.EstimatedSelectivity = response.Explain.EstimatedSelectivity
+	}
+	response.Explain = explain
+}
+
+// dispatchSearch runs a search on an unsharded collection with the search
+// path that fits it
+func (c *VittoriaCollection) dispatchSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	if req.CountOnly {
+		return c.countSearch(ctx, req)
+	}
+	if req.Rescore && c.quantizer != nil {
+		return c.rescoredSearch(ctx, req)
 	}
 
 	// Use parallel search engine if available
@@ -377,112 +807,98 @@ func (c *VittoriaCollection) Search(ctx context.Context, req *SearchRequest) (*S
 		return c.searchEngine.Search(ctx, req)
 	}
 
-	// Fallback to original implementation
+	// Fallback to the index, or to the original implementation for flat collections
+	if c.indexReady() {
+		return c.indexSearch(ctx, req)
+	}
 	return c.legacySearch(ctx, req)
 }
 
-// legacySearch provides the original search implementation as fallback
+// legacySearch is the brute-force search of collections without a search
+// engine, parallel under the database's parallel search settings
 func (c *VittoriaCollection) legacySearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	startTime := time.Now()
+	response, _, err := c.scanSearch(ctx, req, c.parallelConfig())
+	return response, err
+}
 
-	// Validate search request
-	if err := c.validateSearchRequest(req); err != nil {
-		return nil, err
+// Compact purges the index entries left behind by deletes and rewrites the
+// collection's files. Sharded collections compact their local shards.
+func (c *VittoriaCollection) Compact(ctx context.Context) error {
+	if c.readOnly {
+		return c.errReadOnly()
 	}
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
 
-	// Perform brute force search for now (will be optimized with proper indexing)
-	candidates := make([]*SearchResult, 0, len(c.vectors))
-
-	for _, vector := range c.vectors {
-		// Apply metadata filter if specified
-		if req.Filter != nil && !c.matchesFilter(vector.Metadata, req.Filter) {
-			continue
-		}
-
-		// Calculate similarity score
-		score := c.calculateSimilarity(req.Vector, vector.Vector)
-
-		result := &SearchResult{
-			ID:    vector.ID,
-			Score: score,
-		}
-
-		// Include vector if requested
-		if req.IncludeVector {
-			result.Vector = make([]float32, len(vector.Vector))
-			copy(result.Vector, vector.Vector)
-		}
-
-		// Include metadata if requested
-		if req.IncludeMetadata {
-			result.Metadata = make(map[string]interface{})
-			for k, v := range vector.Metadata {
-				result.Metadata[k] = v
-			}
-		}
-
-		// Include content if requested and content storage is enabled
-		if req.IncludeContent && c.contentStorage != nil && c.contentStorage.Enabled {
-			if content, exists := vector.Metadata[c.contentStorage.FieldName]; exists {
-				if contentStr, ok := content.(string); ok {
-					result.Content = contentStr
+		for i, s := range c.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				if err := local.Compact(ctx); err != nil {
+					return fmt.Errorf("failed to compact shard %s: %w", c.shardName(i), err)
 				}
 			}
 		}
-
-		candidates = append(candidates, result)
+		return nil
 	}
 
-	// Sort by score (descending for similarity)
-	c.sortCandidates(candidates)
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// Apply limit and offset
-	start := req.Offset
-	if start > len(candidates) {
-		start = len(candidates)
+	if c.closed {
+		return errorf(ErrClosed, "collection is closed")
 	}
 
-	end := start + req.Limit
-	if end > len(candidates) {
-		end = len(candidates)
+	if c.index != nil {
+		if err := c.index.Optimize(); err != nil {
+			return fmt.Errorf("failed to optimize index: %w", err)
+		}
+	}
+	if err := c.saveVectors(); err != nil {
+		return fmt.Errorf("failed to save vectors: %w", err)
+	}
+	if err := c.saveIndex(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	c.lastCompaction = time.Now()
+	c.lastFlush = c.lastCompaction
+	if err := c.saveMetadata(); err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
 	}
-
-	results := candidates[start:end]
-	tookMS := time.Since(startTime).Milliseconds()
-
-	return &SearchResponse{
-		Results:   results,
-		Total:     int64(len(candidates)),
-		TookMS:    tookMS,
-		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
-	}, nil
-}
-
-// Compact performs collection compaction
-func (c *VittoriaCollection) Compact(ctx context.Context) error {
-	// TODO: Implement compaction
 	return nil
 }
 
 // Flush flushes pending changes to disk
 func (c *VittoriaCollection) Flush(ctx context.Context) error {
+	// A reader has nothing to save
+	if c.readOnly {
+		return nil
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.closed {
-		return fmt.Errorf("collection is closed")
+		return errorf(ErrClosed, "collection is closed")
+	}
+
+	if c.isSharded() {
+		if err := c.flushShards(ctx); err != nil {
+			return err
+		}
 	}
 
 	// Save vectors to disk
 	if err := c.saveVectors(); err != nil {
 		return fmt.Errorf("failed to save vectors: %w", err)
 	}
+	c.lastFlush = time.Now()
+
+	// Save index to disk
+	if err := c.saveIndex(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
 
 	// Update metadata
-	c.modified = time.Now()
+	c.touch()
 	if err := c.saveMetadata(); err != nil {
 		return fmt.Errorf("failed to save metadata: %w", err)
 	}
@@ -497,7 +913,7 @@ func (c *VittoriaCollection) Info() (*CollectionInfo, error) {
 
 	count, _ := c.Count()
 
-	return &CollectionInfo{
+	info := &CollectionInfo{
 		Name:        c.name,
 		Dimensions:  c.dimensions,
 		Metric:      c.metric,
@@ -505,7 +921,27 @@ func (c *VittoriaCollection) Info() (*CollectionInfo, error) {
 		VectorCount: count,
 		Created:     c.created,
 		Modified:    c.modified,
-	}, nil
+	}
+	if c.isSharded() {
+		info.Shards = c.sharding.Shards
+	}
+	info.ExpectedCount = c.expectedCount
+	info.BulkLoad = c.bulkLoading
+	info.Internal = c.internal
+	info.Group = c.group
+	info.Quantization = c.quantization
+	info.IndexedFields = c.indexedFields
+	if c.indexType == IndexTypeHNSW {
+		params := c.hnswParams()
+		info.HNSW = &params
+	}
+	if rebuild := c.rebuildStatus(); rebuild != nil && rebuild.State == RebuildRunning {
+		info.IndexRebuild = rebuild
+	}
+	info.Vectorizer = c.vectorizerConf
+	info.EmbeddingModels = c.embeddingModels()
+
+	return info, nil
 }
 
 // validateVector validates a vector before insertion
@@ -514,8 +950,22 @@ func (c *VittoriaCollection) validateVector(vector *Vector) error {
 		return fmt.Errorf("vector ID cannot be empty")
 	}
 
-	if len(vector.Vector) != c.dimensions {
-		return fmt.Errorf("vector dimensions (%d) don't match collection dimensions (%d)", len(vector.Vector), c.dimensions)
+	if err := validateNamespacedID(vector.Namespace, vector.ID); err != nil {
+		return err
+	}
+
+	// Records without a vector hold metadata only
+	if len(vector.Vector) != c.dimensions && vector.hasVector() {
+		return errorf(ErrDimensionMismatch, "vector dimensions (%d) don't match collection dimensions (%d)", len(vector.Vector), c.dimensions)
+	}
+	if c.metric.Binary() {
+		if err := validateBinaryVector(vector.Vector); err != nil {
+			return err
+		}
+	}
+
+	if _, _, err := expirationTime(vector.Metadata); err != nil {
+		return err
 	}
 
 	return nil
@@ -523,11 +973,13 @@ func (c *VittoriaCollection) validateVector(vector *Vector) error {
 
 // validateSearchRequest validates a search request
 func (c *VittoriaCollection) validateSearchRequest(req *SearchRequest) error {
-	if len(req.Vector) != c.dimensions {
-		return fmt.Errorf("query vector dimensions (%d) don't match collection dimensions (%d)", len(req.Vector), c.dimensions)
+	// A count needs the query vector only to apply a score threshold
+	vectorless := req.CountOnly && req.MinScore == nil && len(req.Vector) == 0
+	if len(req.Vector) != c.dimensions && !vectorless {
+		return errorf(ErrDimensionMismatch, "query vector dimensions (%d) don't match collection dimensions (%d)", len(req.Vector), c.dimensions)
 	}
 
-	if req.Limit <= 0 {
+	if req.Limit <= 0 && !req.CountOnly {
 		return fmt.Errorf("limit must be positive")
 	}
 
@@ -535,7 +987,11 @@ func (c *VittoriaCollection) validateSearchRequest(req *SearchRequest) error {
 		return fmt.Errorf("offset cannot be negative")
 	}
 
-	return nil
+	if err := ValidateNamespace(req.Namespace); err != nil {
+		return err
+	}
+
+	return ValidateFilter(req.Filter)
 }
 
 // calculateSimilarity calculates similarity between two vectors
@@ -549,6 +1005,8 @@ func (c *VittoriaCollection) calculateSimilarity(a, b []float32) float32 {
 		return dotProduct(a, b)
 	case DistanceMetricManhattan:
 		return 1.0 / (1.0 + manhattanDistance(a, b))
+	case DistanceMetricHamming, DistanceMetricJaccard:
+		return c.scoreFromDistance(index.NewDistanceCalculator(index.DistanceMetric(c.metric)).Calculate(a, b))
 	default:
 		return 0.0
 	}
@@ -556,21 +1014,26 @@ func (c *VittoriaCollection) calculateSimilarity(a, b []float32) float32 {
 
 // matchesFilter checks if metadata matches the filter
 func (c *VittoriaCollection) matchesFilter(metadata map[string]interface{}, filter *Filter) bool {
-	// TODO: Implement proper filter matching
-	// For now, return true (no filtering)
-	return true
-}
-
-// sortCandidates sorts search results by score (descending)
-func (c *VittoriaCollection) sortCandidates(candidates []*SearchResult) {
-	// Simple bubble sort for now (will be optimized)
-	n := len(candidates)
-	for i := 0; i < n-1; i++ {
-		for j := 0; j < n-i-1; j++ {
-			if candidates[j].Score < candidates[j+1].Score {
-				candidates[j], candidates[j+1] = candidates[j+1], candidates[j]
-			}
-		}
+	return matchFilter(metadata, filter)
+}
+
+// resultLess reports whether a ranks before b: by descending score, with
+// equal scores ordered by ascending ID so that every search path returns
+// them in the same order from run to run, and pagination is stable
+func resultLess(a, b *SearchResult) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.ID < b.ID
+}
+
+// touch records a write to the collection and drops the search results
+// cached for it; the caller holds mu
+func (c *VittoriaCollection) touch() {
+	c.modified = time.Now()
+	c.generation.Add(1)
+	if c.searchEngine != nil {
+		c.searchEngine.ClearCache()
 	}
 }
 
@@ -584,6 +1047,17 @@ func (c *VittoriaCollection) saveMetadata() error {
 		Created:        c.created,
 		Modified:       c.modified,
 		ContentStorage: c.contentStorage,
+		Sharding:       c.sharding,
+		ExpectedCount:  c.expectedCount,
+		BulkLoad:       c.bulkLoading,
+		Internal:       c.internal,
+		Quantization:   c.quantization,
+		IndexedFields:  c.indexedFields,
+		LastCompaction: c.lastCompaction,
+		HNSW:           c.hnsw,
+		Vectorizer:     c.vectorizerConf,
+
+		EmbeddingModels: c.models,
 	}
 
 	data, err := json.MarshalIndent(metadata, "", "  ")
@@ -592,24 +1066,37 @@ func (c *VittoriaCollection) saveMetadata() error {
 	}
 
 	metadataPath := filepath.Join(c.dataDir, "metadata.json")
-	return os.WriteFile(metadataPath, data, 0644)
+	return writeFileAtomic(metadataPath, data)
 }
 
 // saveVectors saves vectors to disk
 func (c *VittoriaCollection) saveVectors() error {
-	vectorsPath := filepath.Join(c.dataDir, "vectors.json")
+	if c.quantizer != nil {
+		return c.saveQuantizedVectors()
+	}
 
-	data, err := json.MarshalIndent(c.vectors, "", "  ")
+	encode := func(vector *Vector) (*Vector, error) {
+		return vector, nil
+	}
+	if c.halfPrecision() {
+		// Saved widened, as they were inserted but rounded
+		encode = func(vector *Vector) (*Vector, error) {
+			full, _ := c.withVectorData(vector)
+			return full, nil
+		}
+	}
+	data, sums, err := encodeVectors(c.vectors, encode)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(vectorsPath, data, 0644)
+	return c.writeChecksummed(vectorsFileName, data, sums)
 }
 
-// loadVectors loads vectors from disk
+// loadVectors loads vectors from disk. Damaged segments of the vectors file
+// fail the load, unless the database recovers the vectors of the others.
 func (c *VittoriaCollection) loadVectors() error {
-	vectorsPath := filepath.Join(c.dataDir, "vectors.json")
+	vectorsPath := filepath.Join(c.dataDir, vectorsFileName)
 
 	// Check if vectors file exists
 	if _, err := os.Stat(vectorsPath); os.IsNotExist(err) {
@@ -622,7 +1109,36 @@ func (c *VittoriaCollection) loadVectors() error {
 		return err
 	}
 
-	return json.Unmarshal(data, &c.vectors)
+	vectors, damaged, err := decodeVectors(data, c.checksumEntry(vectorsFileName))
+	if err != nil {
+		return err
+	}
+	if len(damaged) > 0 && !c.indexOptions.recoverCorrupted {
+		return fmt.Errorf("checksum mismatch in %s: set storage.recover_corrupted to load the intact segments", describeDamage(damaged))
+	}
+	maps.Copy(c.vectors, vectors)
+	if len(damaged) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Collection %s: checksum mismatch in %s, loaded the %d vectors of the intact segments\n", c.name, describeDamage(damaged), len(vectors))
+	if c.readOnly {
+		return nil
+	}
+	// The damaged file is kept aside, and the recovered vectors saved in its
+	// place
+	if err := os.Rename(vectorsPath, vectorsPath+".damaged"); err != nil {
+		return fmt.Errorf("failed to set the damaged vectors aside: %w", err)
+	}
+	return c.saveVectors()
+}
+
+// checksumEntry returns the checksums recorded for a file, or nil
+func (c *VittoriaCollection) checksumEntry(file string) *checksumEntry {
+	if c.checksums == nil {
+		return nil
+	}
+	return c.checksums.Files[file]
 }
 
 // Distance calculation functions
@@ -639,7 +1155,7 @@ func cosineSimilarity(a, b []float32) float32 {
 		return 0
 	}
 
-	return dotProduct / (float32(sqrt(float64(normA))) * float32(sqrt(float64(normB))))
+	return dotProduct / (float32(math.Sqrt(float64(normA))) * float32(math.Sqrt(float64(normB))))
 }
 
 func euclideanDistance(a, b []float32) float32 {
@@ -648,7 +1164,7 @@ func euclideanDistance(a, b []float32) float32 {
 		diff := a[i] - b[i]
 		sum += diff * diff
 	}
-	return float32(sqrt(float64(sum)))
+	return float32(math.Sqrt(float64(sum)))
 }
 
 func dotProduct(a, b []float32) float32 {
@@ -671,21 +1187,22 @@ func manhattanDistance(a, b []float32) float32 {
 	return sum
 }
 
-// sqrt is a simple square root implementation
-func sqrt(x float64) float64 {
-	if x == 0 {
-		return 0
-	}
-
-	z := x
-	for i := 0; i < 10; i++ {
-		z = (z + x/z) / 2
+// checkEmbeddingDimensions rejects embeddings whose size differs from the
+// collection's, which happens when the dimensions inferred or configured for
+// the vectorizer do not match what its model actually produces
+func (c *VittoriaCollection) checkEmbeddingDimensions(embedding []float32) error {
+	if len(embedding) != c.dimensions {
+		return fmt.Errorf("vectorizer model '%s' produces %d dimensions but collection '%s' has %d: recreate the collection with \"dimensions\": %d",
+			c.vectorizer.GetModel(), len(embedding), c.name, c.dimensions, len(embedding))
 	}
-	return z
+	return nil
 }
 
 // InsertText inserts text that will be automatically vectorized
 func (c *VittoriaCollection) InsertText(ctx context.Context, textVector *TextVector) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
 	if c.vectorizer == nil {
 		return fmt.Errorf("no vectorizer configured for collection '%s'", c.name)
 	}
@@ -695,6 +1212,9 @@ func (c *VittoriaCollection) InsertText(ctx context.Context, textVector *TextVec
 	if err != nil {
 		return fmt.Errorf("failed to generate embedding: %w", err)
 	}
+	if err := c.checkEmbeddingDimensions(embedding); err != nil {
+		return err
+	}
 
 	// Prepare metadata - preserve original content if enabled
 	metadata := make(map[string]interface{})
@@ -725,18 +1245,41 @@ func (c *VittoriaCollection) InsertText(ctx context.Context, textVector *TextVec
 
 	// Create vector and insert
 	vector := &Vector{
-		ID:       textVector.ID,
-		Vector:   embedding,
-		Metadata: metadata,
+		ID:        textVector.ID,
+		Namespace: textVector.Namespace,
+		Vector:    embedding,
+		Metadata:  metadata,
 	}
 
-	return c.Insert(ctx, vector)
+	if err := c.Insert(ctx, vector); err != nil {
+		return err
+	}
+	c.RecordEmbeddings(1)
+	return nil
 }
 
 // InsertTextBatch inserts multiple text vectors that will be automatically vectorized
 func (c *VittoriaCollection) InsertTextBatch(ctx context.Context, textVectors []*TextVector) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	vectors, err := c.PrepareTextVectors(ctx, textVectors)
+	if err != nil {
+		return err
+	}
+
+	if err := c.InsertBatch(ctx, vectors); err != nil {
+		return err
+	}
+	c.RecordEmbeddings(len(vectors))
+	return nil
+}
+
+// PrepareTextVectors generates embeddings for text vectors and returns the
+// vectors that InsertTextBatch would insert, without inserting them
+func (c *VittoriaCollection) PrepareTextVectors(ctx context.Context, textVectors []*TextVector) ([]*Vector, error) {
 	if c.vectorizer == nil {
-		return fmt.Errorf("no vectorizer configured for collection '%s'", c.name)
+		return nil, fmt.Errorf("no vectorizer configured for collection '%s'", c.name)
 	}
 
 	// Extract texts for batch embedding generation
@@ -748,10 +1291,15 @@ func (c *VittoriaCollection) InsertTextBatch(ctx context.Context, textVectors []
 	// Generate embeddings in batch
 	embeddings, err := c.vectorizer.GenerateEmbeddings(ctx, texts)
 	if err != nil {
-		return fmt.Errorf("failed to generate embeddings: %w", err)
+		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	for _, embedding := range embeddings {
+		if err := c.checkEmbeddingDimensions(embedding); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create vectors and insert
+	// Create vectors
 	vectors := make([]*Vector, len(textVectors))
 	for i, tv := range textVectors {
 		// Prepare metadata - preserve original content if enabled
@@ -768,7 +1316,7 @@ func (c *VittoriaCollection) InsertTextBatch(ctx context.Context, textVectors []
 		if c.contentStorage != nil && c.contentStorage.Enabled {
 			// Check content size limits
 			if c.contentStorage.MaxSize > 0 && int64(len(tv.Text)) > c.contentStorage.MaxSize {
-				return fmt.Errorf("content size (%d bytes) exceeds maximum allowed size (%d bytes) for vector %s", len(tv.Text), c.contentStorage.MaxSize, tv.ID)
+				return nil, fmt.Errorf("content size (%d bytes) exceeds maximum allowed size (%d bytes) for vector %s", len(tv.Text), c.contentStorage.MaxSize, tv.ID)
 			}
 
 			// Store content (with optional compression in future)
@@ -782,13 +1330,14 @@ func (c *VittoriaCollection) InsertTextBatch(ctx context.Context, textVectors []
 		}
 
 		vectors[i] = &Vector{
-			ID:       tv.ID,
-			Vector:   embeddings[i],
-			Metadata: metadata,
+			ID:        tv.ID,
+			Namespace: tv.Namespace,
+			Vector:    embeddings[i],
+			Metadata:  metadata,
 		}
 	}
 
-	return c.InsertBatch(ctx, vectors)
+	return vectors, nil
 }
 
 // SearchText performs text-based search (automatically vectorizes query)
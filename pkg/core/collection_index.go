@@ -0,0 +1,511 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// indexFileName is the file the collection's ANN index is persisted to
+const indexFileName = "index.json"
+
+// indexOptions are the database-wide settings every HNSW index is created
+// with, along with those of brute-force searches and the search cache
+type indexOptions struct {
+	buildThreads          int        // Workers used to build the index (0 uses all CPUs)
+	neighborSelection     string     // "heuristic" or "simple" ("" uses the index default)
+	keepPrunedConnections bool       // Top up neighbor lists with candidates the heuristic discarded
+	hnsw                  HNSWParams // Graph parameters of collections that set none
+	autoRebuild           AutoRebuildConfig
+	parallel              *ParallelSearchConfig // nil takes DefaultParallelSearchConfig
+	cache                 *SearchCache          // Database-wide search cache (nil: the collection keeps its own)
+	kernels               *SIMDVectorOps        // Distance kernels of searches (nil: scalar loops)
+	windows               []*maintenanceWindow  // When heavy jobs may run (nil: at any time)
+
+	// Vectorizer profiles, which supply the API keys of the collections
+	// created with them
+	profiles map[string]*embeddings.VectorizerConfig
+
+	// Load the intact segments of a damaged vectors file instead of failing
+	recoverCorrupted bool
+}
+
+// forShards returns the options of the shards of a collection, which leave
+// caching to the collection: shards of different collections share names
+func (o indexOptions) forShards() indexOptions {
+	o.cache = nil
+	return o
+}
+
+// newIndexOptions returns the index options set in the database configuration
+func newIndexOptions(config *Config) indexOptions {
+	if config == nil {
+		return indexOptions{}
+	}
+	return indexOptions{
+		buildThreads:          config.Performance.NumThreads,
+		neighborSelection:     config.Index.HNSWConfig.NeighborSelection,
+		keepPrunedConnections: config.Index.HNSWConfig.KeepPrunedConnections,
+		hnsw: HNSWParams{
+			M:              config.Index.HNSWConfig.M,
+			EfConstruction: config.Index.HNSWConfig.EfConstruction,
+			EfSearch:       config.Index.HNSWConfig.EfSearch,
+		},
+		autoRebuild: config.Index.AutoRebuild,
+		parallel:    config.Parallel,
+		kernels:     newSearchKernels(config.Performance.EnableSIMD),
+		profiles:    config.VectorizerProfiles,
+
+		recoverCorrupted: config.Storage.RecoverCorrupted,
+	}
+}
+
+// indexOptions returns the index options of the named collection of the
+// database
+func (db *VittoriaDB) indexOptions(name string) indexOptions {
+	options := newIndexOptions(db.config)
+	options.cache = db.searchCache
+	options.windows = db.windowsFor(name)
+	return options
+}
+
+// initIndex creates the ANN index backing the collection.
+// Flat collections are served by a brute-force scan over the vector map and
+// don't keep a separate index structure.
+func (c *VittoriaCollection) initIndex() error {
+	idx, err := c.newIndex(c.indexType, c.hnswParams())
+	if err != nil {
+		return err
+	}
+
+	c.index = idx
+	return nil
+}
+
+// newIndex creates an empty index of the given type, nil for the types
+// served by a brute-force scan
+func (c *VittoriaCollection) newIndex(indexType IndexType, params HNSWParams) (index.Index, error) {
+	if indexType != IndexTypeHNSW {
+		return nil, nil
+	}
+
+	config := map[string]interface{}{
+		"m":                       params.M,
+		"max_m0":                  2 * params.M,
+		"ef_construction":         params.EfConstruction,
+		"ef_search":               params.EfSearch,
+		"build_threads":           c.indexOptions.buildThreads,
+		"keep_pruned_connections": c.indexOptions.keepPrunedConnections,
+	}
+	if c.indexOptions.neighborSelection != "" {
+		config["neighbor_selection"] = c.indexOptions.neighborSelection
+	}
+	idx, err := index.CreateIndex(index.IndexTypeHNSW, c.dimensions, index.DistanceMetric(c.metric), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+	return idx, nil
+}
+
+// loadIndex restores the persisted index, rebuilding it from the stored
+// vectors when the index file is missing or unreadable
+func (c *VittoriaCollection) loadIndex() error {
+	if err := c.initIndex(); err != nil {
+		return err
+	}
+	if c.index == nil || c.bulkLoading {
+		// In bulk-load mode the index is built when the load finishes
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dataDir, indexFileName))
+	if err == nil {
+		if _, damaged := c.checksumEntry(indexFileName).check(indexFileName, data); len(damaged) > 0 {
+			fmt.Printf("Collection %s: checksum mismatch in %s, rebuilding the index from the vectors\n", c.name, describeDamage(damaged))
+		} else if loadErr := c.index.Load(bytes.NewReader(data)); loadErr == nil && c.index.Size() == c.indexedCount() {
+			c.markIndexBuilt()
+			return nil
+		}
+		// Stale or corrupted index: start over from the vectors
+		if err := c.initIndex(); err != nil {
+			return err
+		}
+	}
+
+	return c.rebuildIndex()
+}
+
+// rebuildIndex rebuilds the index from the vectors currently in the collection
+func (c *VittoriaCollection) rebuildIndex() error {
+	if c.index == nil {
+		return nil
+	}
+
+	if err := c.index.Build(c.indexVectors()); err != nil {
+		return fmt.Errorf("failed to build index: %w", err)
+	}
+	c.markIndexBuilt()
+	return nil
+}
+
+// indexVectors returns the index entries of the stored vectors; the caller
+// holds mu
+func (c *VittoriaCollection) indexVectors() []*index.IndexVector {
+	vectors := make([]*index.IndexVector, 0, len(c.vectors))
+	for key, vector := range c.vectors {
+		if vector.hasVector() {
+			vectors = append(vectors, &index.IndexVector{ID: key, Vector: vector.Vector})
+		}
+	}
+	return vectors
+}
+
+// saveIndex persists the index next to the collection vectors
+func (c *VittoriaCollection) saveIndex() error {
+	if c.index == nil || c.bulkLoading {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := c.index.Save(&buf); err != nil {
+		return err
+	}
+
+	data := buf.Bytes()
+	return c.writeChecksummed(indexFileName, data, byteChecksums(data, indexSegmentSize))
+}
+
+// indexUpsert adds a vector to the index under its storage key, replacing the
+// entry of the previous vector stored under that key, if any
+func (c *VittoriaCollection) indexUpsert(ctx context.Context, vector, previous *Vector) error {
+	c.trackRebuild(vector.key())
+	if c.index == nil || c.bulkLoading {
+		return nil
+	}
+
+	if previous != nil && previous.hasVector() {
+		if err := c.index.Delete(ctx, vector.key()); err != nil {
+			return fmt.Errorf("failed to remove previous index entry: %w", err)
+		}
+		c.countIndexRemovals(1)
+	}
+	if !vector.hasVector() {
+		return nil
+	}
+
+	return c.index.Add(ctx, &index.IndexVector{ID: vector.key(), Vector: vector.Vector})
+}
+
+// indexRemove removes a stored vector from the index
+func (c *VittoriaCollection) indexRemove(ctx context.Context, vector *Vector) error {
+	c.trackRebuild(vector.key())
+	if c.index == nil || c.bulkLoading || !vector.hasVector() {
+		return nil
+	}
+	if err := c.index.Delete(ctx, vector.key()); err != nil {
+		return err
+	}
+	c.countIndexRemovals(1)
+	return nil
+}
+
+// indexRemoveBatch removes many vectors from the index at once, so that it is
+// repaired once rather than per vector; the caller holds mu
+func (c *VittoriaCollection) indexRemoveBatch(ctx context.Context, vectors []*Vector) error {
+	for _, vector := range vectors {
+		c.trackRebuild(vector.key())
+	}
+	if c.index == nil || c.bulkLoading {
+		return nil
+	}
+	ids := make([]string, 0, len(vectors))
+	for _, vector := range vectors {
+		if vector.hasVector() {
+			ids = append(ids, vector.key())
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	removed, err := c.index.DeleteBatch(ctx, ids)
+	if err != nil {
+		return err
+	}
+	c.countIndexRemovals(removed)
+	return nil
+}
+
+// indexedCount returns how many stored vectors belong in the index; the
+// caller holds mu
+func (c *VittoriaCollection) indexedCount() int {
+	n := 0
+	for _, vector := range c.vectors {
+		if vector.hasVector() {
+			n++
+		}
+	}
+	return n
+}
+
+// IndexStats returns the internals of the collection's index: node count,
+// layer histogram, average degree, memory breakdown and serialized size on disk
+func (c *VittoriaCollection) IndexStats() (*index.IndexStats, error) {
+	if c.isSharded() {
+		return c.shardedIndexStats()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	var stats *index.IndexStats
+	if c.index != nil {
+		stats = c.index.Stats()
+	} else {
+		// Flat collections scan the vector map directly, with a byte per
+		// component when quantized to int8 and two with float16
+		componentSize := int64(4)
+		if c.quantizer != nil {
+			componentSize = 1
+		} else if c.halfPrecision() {
+			componentSize = 2
+		}
+		vectorMemory := int64(len(c.vectors)) * int64(c.dimensions) * componentSize
+		stats = &index.IndexStats{
+			IndexType:    index.IndexTypeFlat,
+			VectorCount:  len(c.vectors),
+			Dimensions:   c.dimensions,
+			MemoryUsage:  vectorMemory,
+			VectorMemory: vectorMemory,
+		}
+	}
+
+	if info, err := os.Stat(filepath.Join(c.dataDir, indexFileName)); err == nil {
+		stats.DiskSize = info.Size()
+	}
+
+	return stats, nil
+}
+
+// indexSearch performs an approximate nearest neighbor search through the index
+func (c *VittoriaCollection) indexSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	return c.searchIndex(ctx, req, nil)
+}
+
+// searchIndex is indexSearch, reporting the results that survive filtering
+// each time the search has to widen
+func (c *VittoriaCollection) searchIndex(ctx context.Context, req *SearchRequest, report *progressReporter) (*SearchResponse, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	startTime := time.Now()
+
+	if err := c.validateSearchRequest(req); err != nil {
+		return nil, err
+	}
+
+	k := req.Offset + req.Limit
+	params := &index.SearchParams{Params: req.SearchParams}
+	switch ef := req.SearchParams["ef"].(type) {
+	case float64:
+		params.EF = int(ef)
+	case int:
+		params.EF = ef
+	}
+	// The beam must be at least as wide as the number of requested results;
+	// without an explicit ef the index falls back to its configured ef_search
+	if params.EF > 0 && params.EF < k {
+		params.EF = k
+	}
+
+	strategy, err := filterStrategy(req)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	selectivity := c.estimateSelectivity(req, now)
+	if strategy == FilterStrategyAuto {
+		strategy = FilterStrategyPost
+		if selectivity < prefilterMaxSelectivity {
+			strategy = FilterStrategyPre
+		}
+	}
+	var explain *SearchExplain
+	if req.Explain {
+		explain = &SearchExplain{FilterStrategy: strategy, EstimatedSelectivity: &selectivity}
+	}
+
+	fetch := postfilterFetch(k, selectivity, len(c.vectors))
+	if strategy == FilterStrategyPre {
+		passed := c.prefilter(req, now)
+		if len(passed) <= max(prefilterExactMax, k) {
+			return c.exactSearch(req, passed, startTime, explain), nil
+		}
+		params.Allowed = make([]string, len(passed))
+		for i, vector := range passed {
+			params.Allowed[i] = vector.key()
+		}
+		fetch = k
+	}
+
+	// The graph is shared by every namespace, so candidates from other
+	// namespaces or rejected by the filter are dropped; widen the search
+	// until enough results survive or the whole index has been considered
+	var results []*SearchResult
+	for ; ; fetch *= 4 {
+		candidates, err := c.index.Search(ctx, req.Vector, fetch, params)
+		if err != nil {
+			return nil, fmt.Errorf("index search failed: %w", err)
+		}
+
+		// Candidates come nearest first, so once one misses the score
+		// threshold no wider search can find more results
+		belowThreshold := false
+		results = make([]*SearchResult, 0, len(candidates))
+		for _, candidate := range candidates {
+			score := c.scoreFromDistance(candidate.Score)
+			if c.belowMinScore(req, score) {
+				belowThreshold = true
+				break
+			}
+			vector, exists := c.vectors[candidate.ID]
+			if !exists || !searchable(vector, req, now) {
+				continue
+			}
+			if !c.matchesFilter(vector.Metadata, req.searchFilter()) {
+				continue
+			}
+			results = append(results, c.newSearchResult(vector, score, req))
+		}
+
+		if belowThreshold || len(results) >= k || len(candidates) < fetch || fetch >= len(c.vectors) {
+			break
+		}
+		if err := report.snapshot(results, req, len(candidates), min(fetch*4, len(c.vectors))); err != nil {
+			return nil, err
+		}
+	}
+	// Converting distances to scores can round neighbours to the same score,
+	// so the order is settled here as on the other search paths
+	sort.Slice(results, func(i, j int) bool {
+		return resultLess(results[i], results[j])
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	total := int64(len(results))
+	start := req.Offset
+	if start > len(results) {
+		start = len(results)
+	}
+
+	return &SearchResponse{
+		Results:   results[start:],
+		Total:     total,
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+		Explain:   explain,
+	}, nil
+}
+
+// exactSearch scores the pre-filtered vectors of an index search directly,
+// answering as the index would; the caller holds mu
+func (c *VittoriaCollection) exactSearch(req *SearchRequest, passed []*Vector, startTime time.Time, explain *SearchExplain) *SearchResponse {
+	k := req.Offset + req.Limit
+	batch := newScanBatch(c.newQueryScorer(req.Vector), req, k)
+	for _, vector := range passed {
+		batch.add(vector)
+	}
+	batch.flush()
+	top := batch.top.sorted()
+
+	return &SearchResponse{
+		Results:   c.rankedResults(top[min(req.Offset, len(top)):], req),
+		Total:     int64(len(top)),
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+		Explain:   explain,
+	}
+}
+
+// scoreFromDistance converts an index distance into the similarity score
+// reported by the brute-force search path
+func (c *VittoriaCollection) scoreFromDistance(distance float32) float32 {
+	switch c.metric {
+	case DistanceMetricCosine:
+		return 1.0 - distance
+	case DistanceMetricEuclidean, DistanceMetricManhattan:
+		return 1.0 / (1.0 + distance)
+	case DistanceMetricDotProduct:
+		return -distance
+	case DistanceMetricHamming:
+		return 1.0 - distance/float32(c.dimensions)
+	case DistanceMetricJaccard:
+		return 1.0 - distance
+	default:
+		return 0.0
+	}
+}
+
+// newSearchResult builds a search result for a stored vector honoring the request's include flags
+func (c *VittoriaCollection) newSearchResult(vector *Vector, score float32, req *SearchRequest) *SearchResult {
+	result := &SearchResult{
+		ID:    vector.ID,
+		Score: score,
+	}
+	if expiresAt, ok, err := expirationTime(vector.Metadata); ok && err == nil {
+		result.expiresAt = expiresAt
+	}
+	if req.NormalizeScores {
+		distance := c.scoreDistance(score)
+		result.Score = normalizedScore(c.metric, score)
+		result.Distance = &distance
+	}
+
+	if req.IncludeVector {
+		data, err := c.vectorData(vector)
+		if err != nil {
+			fmt.Printf("Error reading vector %s of collection %s: %v\n", vector.ID, c.name, err)
+		}
+		if req.ReadOnlyResults {
+			result.Vector = data
+		} else {
+			result.Vector = make([]float32, len(data))
+			copy(result.Vector, data)
+		}
+	}
+
+	if req.IncludeMetadata && req.ReadOnlyResults {
+		result.Metadata = vector.Metadata
+	} else if req.IncludeMetadata {
+		result.Metadata = make(map[string]interface{})
+		for k, v := range vector.Metadata {
+			result.Metadata[k] = v
+		}
+	}
+
+	if req.IncludeContent && c.contentStorage != nil && c.contentStorage.Enabled {
+		if content, exists := vector.Metadata[c.contentStorage.FieldName]; exists {
+			if contentStr, ok := content.(string); ok {
+				result.Content = contentStr
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,244 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+const (
+	// Vectors whose metadata field statistics are measured on
+	fieldStatsSampleSize = 10000
+
+	// Distinct values counted per field; cardinalities stop there
+	maxFieldCardinality = 10000
+)
+
+// CollectionDetails details a collection: its index, its footprint in memory
+// and on disk, its metadata fields and its maintenance history
+type CollectionDetails struct {
+	Name           string            `json:"name"`
+	Dimensions     int               `json:"dimensions"`
+	Metric         string            `json:"metric"`
+	VectorCount    int64             `json:"vector_count"`
+	Index          *index.IndexStats `json:"index"`
+	MemoryEstimate int64             `json:"memory_estimate"` // Approximate bytes of vectors, metadata and index
+	Storage        *StorageStats     `json:"storage"`
+	Fields         []*FieldStats     `json:"fields"`
+	FieldsSampled  int               `json:"fields_sampled"` // Vectors the field statistics were measured on
+
+	LastFlush      *time.Time `json:"last_flush,omitempty"`      // When the vectors were last written to disk
+	LastCompaction *time.Time `json:"last_compaction,omitempty"` // When the collection was last compacted
+}
+
+// StorageStats is the footprint of a collection on disk
+type StorageStats struct {
+	DiskSize int64            `json:"disk_size"` // Bytes of every file of the collection
+	Files    map[string]int64 `json:"files"`     // Bytes by file or directory of the collection's directory
+}
+
+// FieldStats describes a top-level metadata field over the sampled vectors
+type FieldStats struct {
+	Field             string   `json:"field"`
+	Count             int      `json:"count"`       // Sampled vectors holding the field
+	Cardinality       int      `json:"cardinality"` // Distinct values, up to maxFieldCardinality
+	CardinalityCapped bool     `json:"cardinality_capped,omitempty"`
+	Types             []string `json:"types"`             // JSON types of the values
+	Indexed           bool     `json:"indexed,omitempty"` // The field has a range index
+}
+
+// fieldAccumulator gathers the statistics of a field
+type fieldAccumulator struct {
+	count  int
+	values map[interface{}]bool
+	capped bool
+	types  map[string]bool
+}
+
+// Details returns the detailed statistics of the collection. Sharded
+// collections add up those of their local shards.
+func (c *VittoriaCollection) Details() (*CollectionDetails, error) {
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	count, err := c.Count()
+	if err != nil {
+		return nil, err
+	}
+	indexStats, err := c.IndexStats()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := storageStats(c.dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure storage: %w", err)
+	}
+
+	stats := &CollectionDetails{
+		Name:           c.name,
+		Dimensions:     c.dimensions,
+		Metric:         c.metric.String(),
+		VectorCount:    count,
+		Index:          indexStats,
+		MemoryEstimate: c.MemoryEstimate(),
+		Storage:        storage,
+	}
+
+	fields := make(map[string]*fieldAccumulator)
+	if c.isSharded() {
+		c.shardMu.RLock()
+		for _, s := range c.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				stats.FieldsSampled += local.sampleFields(fields, fieldStatsSampleSize-stats.FieldsSampled)
+				if compacted := local.compactedAt(); compacted != nil && (stats.LastCompaction == nil || compacted.After(*stats.LastCompaction)) {
+					stats.LastCompaction = compacted
+				}
+			}
+		}
+		c.shardMu.RUnlock()
+	} else {
+		stats.FieldsSampled = c.sampleFields(fields, fieldStatsSampleSize)
+	}
+	stats.Fields = c.fieldStats(fields)
+
+	c.mu.RLock()
+	if !c.lastFlush.IsZero() {
+		flushed := c.lastFlush
+		stats.LastFlush = &flushed
+	}
+	c.mu.RUnlock()
+	if stats.LastFlush == nil {
+		// Flushed by an earlier process, when the vectors were written
+		if info, err := os.Stat(filepath.Join(c.dataDir, "vectors.json")); err == nil {
+			flushed := info.ModTime()
+			stats.LastFlush = &flushed
+		}
+	}
+	if compacted := c.compactedAt(); compacted != nil && (stats.LastCompaction == nil || compacted.After(*stats.LastCompaction)) {
+		stats.LastCompaction = compacted
+	}
+	return stats, nil
+}
+
+// compactedAt returns when the collection was last compacted, or nil
+func (c *VittoriaCollection) compactedAt() *time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.lastCompaction.IsZero() {
+		return nil
+	}
+	compacted := c.lastCompaction
+	return &compacted
+}
+
+// sampleFields adds the top-level metadata fields of up to limit vectors to
+// fields, and returns how many vectors it sampled
+func (c *VittoriaCollection) sampleFields(fields map[string]*fieldAccumulator, limit int) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sampled := 0
+	for _, vector := range c.vectors {
+		if sampled >= limit {
+			break
+		}
+		sampled++
+		for key, value := range vector.Metadata {
+			field := fields[key]
+			if field == nil {
+				field = &fieldAccumulator{values: make(map[interface{}]bool), types: make(map[string]bool)}
+				fields[key] = field
+			}
+			field.count++
+			field.types[jsonType(value)] = true
+			if field.capped {
+				continue
+			}
+			distinct := value
+			switch value.(type) {
+			case string, float64, bool, nil:
+			default:
+				distinct = fmt.Sprint(value)
+			}
+			field.values[distinct] = true
+			if len(field.values) > maxFieldCardinality {
+				field.capped = true
+			}
+		}
+	}
+	return sampled
+}
+
+// fieldStats returns the statistics of the accumulated fields, by name
+func (c *VittoriaCollection) fieldStats(fields map[string]*fieldAccumulator) []*FieldStats {
+	stats := make([]*FieldStats, 0, len(fields))
+	for name, field := range fields {
+		types := make([]string, 0, len(field.types))
+		for kind := range field.types {
+			types = append(types, kind)
+		}
+		sort.Strings(types)
+		stats = append(stats, &FieldStats{
+			Field:             name,
+			Count:             field.count,
+			Cardinality:       min(len(field.values), maxFieldCardinality),
+			CardinalityCapped: field.capped,
+			Types:             types,
+			Indexed:           slices.Contains(c.indexedFields, name),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Field < stats[j].Field })
+	return stats
+}
+
+// jsonType returns the JSON type of a metadata value
+func jsonType(value interface{}) string {
+	if _, ok := filterNumber(value); ok {
+		return "number"
+	}
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "array"
+}
+
+// storageStats measures the files of a collection directory
+func storageStats(dir string) (*StorageStats, error) {
+	stats := &StorageStats{Files: make(map[string]int64)}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		relative, _ := filepath.Rel(dir, path)
+		top, _, _ := strings.Cut(filepath.ToSlash(relative), "/")
+		stats.Files[top] += info.Size()
+		stats.DiskSize += info.Size()
+		return nil
+	})
+	return stats, err
+}
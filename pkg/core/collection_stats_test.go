@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCollectionDetails(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := openTestDatabase(t, &Config{DataDir: dir})
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "items", Dimensions: 2, IndexType: IndexTypeHNSW, IndexedFields: []string{"rating"}}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "items")
+	for i := 0; i < 50; i++ {
+		metadata := map[string]interface{}{"rating": float64(i % 5), "name": fmt.Sprintf("item %d", i)}
+		if i%2 == 0 {
+			metadata["tags"] = []interface{}{"even"}
+		}
+		if err := collection.Insert(ctx, &Vector{ID: fmt.Sprintf("v%d", i), Vector: []float32{1, float32(i)}, Metadata: metadata}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	local := collection.(*VittoriaCollection)
+	details, err := local.Details()
+	if err != nil {
+		t.Fatalf("Details failed: %v", err)
+	}
+	if details.VectorCount != 50 || details.Dimensions != 2 || details.Index == nil || details.Index.VectorCount != 50 {
+		t.Errorf("unexpected details: %+v", details)
+	}
+	if details.LastCompaction != nil || details.FieldsSampled != 50 {
+		t.Errorf("unexpected compaction %v or sample %d", details.LastCompaction, details.FieldsSampled)
+	}
+	fields := make(map[string]*FieldStats)
+	for _, field := range details.Fields {
+		fields[field.Field] = field
+	}
+	if rating := fields["rating"]; rating == nil || rating.Cardinality != 5 || !rating.Indexed || rating.Types[0] != "number" {
+		t.Errorf("unexpected rating stats: %+v", rating)
+	}
+	if name := fields["name"]; name == nil || name.Cardinality != 50 || name.Indexed {
+		t.Errorf("unexpected name stats: %+v", name)
+	}
+	if tags := fields["tags"]; tags == nil || tags.Count != 25 || tags.Cardinality != 1 || tags.Types[0] != "array" {
+		t.Errorf("unexpected tags stats: %+v", tags)
+	}
+
+	before := time.Now()
+	if err := collection.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if err := local.Compact(ctx); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	details, err = local.Details()
+	if err != nil {
+		t.Fatalf("Details failed: %v", err)
+	}
+	if details.Storage.DiskSize == 0 || details.Storage.Files["vectors.json"] == 0 {
+		t.Errorf("unexpected storage: %+v", details.Storage)
+	}
+	if details.LastFlush == nil || details.LastFlush.Before(before) || details.LastCompaction == nil || details.LastCompaction.Before(before) {
+		t.Errorf("unexpected flush %v or compaction %v", details.LastFlush, details.LastCompaction)
+	}
+
+	// The compaction is kept with the collection
+	compacted := *details.LastCompaction
+	db.Close()
+	db = openTestDatabase(t, &Config{DataDir: dir})
+	collection, _ = db.GetCollection(ctx, "items")
+	details, err = collection.(*VittoriaCollection).Details()
+	if err != nil {
+		t.Fatalf("Details failed: %v", err)
+	}
+	if details.LastCompaction == nil || !details.LastCompaction.Equal(compacted) || details.LastFlush == nil {
+		t.Errorf("unexpected compaction %v or flush %v after reopening", details.LastCompaction, details.LastFlush)
+	}
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestInternalCollection(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "_text_index", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat, Internal: true})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+
+	listed, _ := db.ListCollections(ctx)
+	all, _ := db.ListAllCollections(ctx)
+	if len(listed) != 0 || len(all) != 1 || !all[0].Internal {
+		t.Errorf("internal collection listed %d times by default, %d times in all", len(listed), len(all))
+	}
+	if stats, _ := db.Stats(ctx); stats.InternalCollections != 1 || len(stats.Collections) != 0 {
+		t.Errorf("stats = %d internal, %d listed; want 1, 0", stats.InternalCollections, len(stats.Collections))
+	}
+
+	if err := db.DropCollection(ctx, "_text_index"); err == nil {
+		t.Fatal("internal collection was dropped")
+	}
+	collection, _ := db.GetCollection(ctx, "_text_index")
+	internal := false
+	if err := collection.(*VittoriaCollection).Update(ctx, &UpdateCollectionRequest{Internal: &internal}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := db.DropCollection(ctx, "_text_index"); err != nil {
+		t.Errorf("DropCollection after making it regular failed: %v", err)
+	}
+}
+
+func TestReadOnlyViews(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 4, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	metadata := map[string]interface{}{"title": "hello", "year": 2024, "tags": "a,b"}
+	if err := collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 0, 0, 0}, Metadata: metadata}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	view, err := collection.GetView(ctx, "", "a")
+	if err != nil {
+		t.Fatalf("GetView failed: %v", err)
+	}
+	copied, _ := collection.Get(ctx, "a")
+	if !reflect.DeepEqual(view, copied) {
+		t.Errorf("view %+v differs from copy %+v", view, copied)
+	}
+	viewAllocs := testing.AllocsPerRun(100, func() { collection.GetView(ctx, "", "a") })
+	copyAllocs := testing.AllocsPerRun(100, func() { collection.Get(ctx, "a") })
+	if viewAllocs >= copyAllocs {
+		t.Errorf("GetView allocates %v times, Get %v", viewAllocs, copyAllocs)
+	}
+
+	// A write replaces the stored vector, and leaves the view as it was
+	if err := collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{0, 1, 0, 0}, Metadata: map[string]interface{}{"title": "bye"}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if view.Vector[0] != 1 || view.Metadata["title"] != "hello" {
+		t.Errorf("view changed by a later write: %+v", view)
+	}
+
+	// Repeated read-only searches keep sharing the stored vector, as they
+	// bypass the search cache, which holds copies
+	stored, _ := collection.GetView(ctx, "", "a")
+	hits := db.searchCacheStats().Hits
+	for i := 0; i < 2; i++ {
+		response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{0, 1, 0, 0}, Limit: 1, IncludeVector: true, IncludeMetadata: true, ReadOnlyResults: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		result := response.Results[0]
+		if &result.Vector[0] != &stored.Vector[0] || result.Metadata["title"] != "bye" {
+			t.Errorf("read-only result %d %+v does not share the stored vector", i+1, result)
+		}
+	}
+	if stats := db.searchCacheStats(); stats.Hits != hits || stats.Entries != 0 {
+		t.Errorf("read-only searches used the cache: %+v", stats)
+	}
+
+	// A normal search after them returns copies, cached or not
+	for i := 0; i < 2; i++ {
+		response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{0, 1, 0, 0}, Limit: 1, IncludeVector: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if &response.Results[0].Vector[0] == &stored.Vector[0] {
+			t.Errorf("search %d returned the stored vector", i+1)
+		}
+	}
+}
+
+func TestExpectedCount(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := NewDatabase()
+	if err := db.Open(ctx, &Config{DataDir: dir}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeHNSW, ExpectedCount: 1000}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	c := collection.(*VittoriaCollection)
+
+	for _, n := range []int{-1, maxExpectedCount + 1} {
+		if err := c.SetExpectedCount(ctx, n); err == nil {
+			t.Errorf("expected_count %d was accepted", n)
+		}
+	}
+	if err := c.SetExpectedCount(ctx, 5000); err != nil {
+		t.Fatalf("SetExpectedCount failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The hint survives a restart
+	db = openTestDatabase(t, &Config{DataDir: dir})
+	collection, _ = db.GetCollection(ctx, "docs")
+	if info, _ := collection.(*VittoriaCollection).Info(); info.ExpectedCount != 5000 {
+		t.Errorf("expected_count is %d after a restart, want 5000", info.ExpectedCount)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// conditionHolds reports whether a write condition holds for the record
+// currently stored under a key. A missing or expired record has no metadata,
+// so {"field": "version", "operator": "exists", "value": false} only lets a
+// write create records.
+func (c *VittoriaCollection) conditionHolds(condition *Filter, key string, now time.Time) bool {
+	metadata := map[string]interface{}{}
+	if stored, exists := c.vectors[key]; exists && !isExpired(stored, now) {
+		metadata = stored.Metadata
+	}
+	return matchFilter(metadata, condition)
+}
+
+// InsertIf inserts vectors only when condition holds for the record stored
+// under each one's ID. The check and the write happen under one lock, so
+// concurrent writers cannot interleave; when the condition fails for any
+// vector, none is written.
+func (c *VittoriaCollection) InsertIf(ctx context.Context, vectors []*Vector, condition *Filter) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	if condition == nil {
+		return c.InsertBatch(ctx, vectors)
+	}
+	if err := ValidateFilter(condition); err != nil {
+		return err
+	}
+
+	if c.isSharded() {
+		return c.shardedInsertIf(ctx, vectors, condition)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errorf(ErrClosed, "collection is closed")
+	}
+
+	now := time.Now()
+	for _, vector := range vectors {
+		if !c.conditionHolds(condition, vector.key(), now) {
+			return errorf(ErrConditionFailed, "condition not met for vector '%s'", vector.ID)
+		}
+	}
+	return c.insertLocked(ctx, vectors)
+}
+
+// shardedInsertIf routes conditional writes to their shards, each of which
+// checks and writes its own vectors atomically
+func (c *VittoriaCollection) shardedInsertIf(ctx context.Context, vectors []*Vector, condition *Filter) error {
+	if !c.routesByID() {
+		return fmt.Errorf("conditional writes require shards routed by ID")
+	}
+
+	c.shardMu.RLock()
+	groups := make(map[int][]*Vector)
+	for _, vector := range vectors {
+		i := c.shardFor(vector, len(c.shards))
+		groups[i] = append(groups[i], vector)
+	}
+	for i, group := range groups {
+		local, ok := c.shards[i].(*VittoriaCollection)
+		if !ok {
+			c.shardMu.RUnlock()
+			return fmt.Errorf("shard %s: conditional writes require local shards", c.shardName(i))
+		}
+		if err := local.InsertIf(ctx, group, condition); err != nil {
+			c.shardMu.RUnlock()
+			return fmt.Errorf("shard %s: %w", c.shardName(i), err)
+		}
+	}
+	c.shardMu.RUnlock()
+
+	// Lock order is c.mu before c.shardMu, so shardMu must be released here
+	c.mu.Lock()
+	c.touch()
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInsertIf(t *testing.T) {
+	ctx := context.Background()
+	collection, err := NewCollection("docs", 2, DistanceMetricCosine, IndexTypeFlat, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	if err := collection.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	defer collection.Close()
+
+	createOnly := &Filter{Field: "version", Operator: FilterOpExists, Value: false}
+	older := &Filter{Field: "version", Operator: FilterOpLt, Value: 2}
+	write := func(version int, condition *Filter) error {
+		return collection.InsertIf(ctx, []*Vector{{ID: "a", Vector: []float32{1, 0}, Metadata: map[string]interface{}{"version": version}}}, condition)
+	}
+
+	if err := write(1, createOnly); err != nil {
+		t.Fatalf("create-only write of a new record failed: %v", err)
+	}
+	if err := write(1, createOnly); err == nil {
+		t.Error("create-only write replaced an existing record")
+	}
+	if err := write(2, older); err != nil {
+		t.Errorf("write over version 1 failed: %v", err)
+	}
+	if err := write(3, older); err == nil || !strings.Contains(err.Error(), "condition not met") {
+		t.Errorf("write over version 2 = %v, want a failed condition", err)
+	}
+	stored, _ := collection.Get(ctx, "a")
+	if stored.Metadata["version"] != 2 {
+		t.Errorf("version = %v, want 2", stored.Metadata["version"])
+	}
+}
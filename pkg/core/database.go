@@ -6,27 +6,49 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/antonellof/VittoriaDB/pkg/config"
 	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/scheduler"
 )
 
+// MaxDimensions bounds the dimensions of a collection unless
+// limits.max_dimensions sets another bound
+const MaxDimensions = config.DefaultMaxDimensions
+
 // VittoriaDB implements the Database interface
 type VittoriaDB struct {
 	config      *Config
 	dataDir     string
 	collections map[string]*VittoriaCollection
+	groups      map[string]*CollectionGroup // Collection groups by name
 	mu          sync.RWMutex
 	startTime   time.Time
 	closed      bool
+	stopJanitor chan struct{}
+	scheduler   *scheduler.Scheduler // Runs the configured maintenance jobs
+	windows     []*maintenanceWindow // When heavy jobs may run on the collections they match
+	deferred    deferredJobs         // Heavy jobs waiting for a maintenance window
+	lock        *dirLock             // Writer lock of the data directory (nil when read-only)
+	searchCache *SearchCache         // Recent search results of all collections
+	memory      memoryGuard          // Writes refused for performance.memory_limit
+	now         func() time.Time     // Clock the janitor expires vectors by
+
+	growth   map[string][]GrowthSample // Daily size history by collection
+	growthMu sync.Mutex
 }
 
 // NewDatabase creates a new VittoriaDB instance
 func NewDatabase() *VittoriaDB {
 	return &VittoriaDB{
 		collections: make(map[string]*VittoriaCollection),
+		groups:      make(map[string]*CollectionGroup),
 		startTime:   time.Now(),
+		now:         time.Now,
 	}
 }
 
@@ -36,27 +58,107 @@ func (db *VittoriaDB) Open(ctx context.Context, config *Config) error {
 	defer db.mu.Unlock()
 
 	if db.closed {
-		return fmt.Errorf("database is closed")
+		return errorf(ErrClosed, "database is closed")
 	}
 
 	db.config = config
 	db.dataDir = config.DataDir
+	db.memory.limit.Store(max(config.Performance.MemoryLimit, 0))
+
+	windows, err := parseMaintenanceWindows(config.Maintenance)
+	if err != nil {
+		return err
+	}
+	db.windows = windows
 
-	// Create data directory if it doesn't exist
-	if err := os.MkdirAll(db.dataDir, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
+	if config.ReadOnly {
+		// A reader shares the directory of a writer and never creates it
+		if _, err := os.Stat(db.dataDir); err != nil {
+			return fmt.Errorf("failed to open data directory: %w", err)
+		}
+	} else {
+		// Create data directory if it doesn't exist
+		if err := os.MkdirAll(db.dataDir, 0755); err != nil {
+			return fmt.Errorf("failed to create data directory: %w", err)
+		}
+		lock, err := lockDataDir(db.dataDir)
+		if err != nil {
+			return err
+		}
+		db.lock = lock
 	}
 
-	// Load existing collections
+	db.searchCache = NewSearchCache(config.Cache)
+	if err := db.load(ctx); err != nil {
+		db.searchCache.Close()
+		if db.lock != nil {
+			db.lock.release()
+			db.lock = nil
+		}
+		return err
+	}
+
+	// Readers see the data as of Open and leave all upkeep to the writer
+	db.stopJanitor = make(chan struct{})
+	if config.ReadOnly {
+		return nil
+	}
+
+	if err := db.restoreSearchCache(); err != nil {
+		fmt.Printf("Warning: failed to restore the search cache: %v\n", err)
+	}
+
+	// Periodically record the size of the collections, remove vectors past
+	// their expires_at, and dropped collections past their trash retention
+	go db.runGrowthSampler(growthSampleInterval, db.stopJanitor)
+	if config.Storage.TTLCheckInterval > 0 {
+		go db.runJanitor(config.Storage.TTLCheckInterval, db.stopJanitor)
+	}
+	if config.Storage.TrashRetention > 0 {
+		go db.runTrashPurge(trashPurgeInterval(config.Storage.TrashRetention), db.stopJanitor)
+	}
+
+	if err := db.startMaintenance(config.Maintenance); err != nil {
+		return err
+	}
+	if len(db.windows) > 0 {
+		go db.runDeferredMaintenance(windowCheckInterval, db.stopJanitor)
+	}
+
+	return nil
+}
+
+// load reads the collections, groups and growth history of the data directory
+func (db *VittoriaDB) load(ctx context.Context) error {
 	if err := db.loadCollections(ctx); err != nil {
 		return fmt.Errorf("failed to load collections: %w", err)
 	}
+	if err := db.loadGroups(); err != nil {
+		return fmt.Errorf("failed to load collection groups: %w", err)
+	}
+	if err := db.loadGrowth(); err != nil {
+		return fmt.Errorf("failed to load collection growth: %w", err)
+	}
+	return nil
+}
 
+// writable returns why the database cannot be written to, if it cannot; the
+// caller holds mu
+func (db *VittoriaDB) writable() error {
+	if db.closed {
+		return errorf(ErrClosed, "database is closed")
+	}
+	if db.config != nil && db.config.ReadOnly {
+		return errorf(ErrReadOnly, "database is open read-only")
+	}
 	return nil
 }
 
 // Close closes the database and all collections
 func (db *VittoriaDB) Close() error {
+	// Let running maintenance jobs finish before their collections close
+	db.stopMaintenance()
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -64,12 +166,37 @@ func (db *VittoriaDB) Close() error {
 		return nil
 	}
 
+	if db.stopJanitor != nil {
+		close(db.stopJanitor)
+	}
+
+	// Closing the collections drops their cached search results
+	var cached *savedSearchCache
+	if db.writable() == nil {
+		cached = db.snapshotSearchCache()
+	}
+
 	// Close all collections
 	for _, collection := range db.collections {
 		if err := collection.Close(); err != nil {
 			// Log error but continue closing other collections
 			fmt.Printf("Error closing collection %s: %v\n", collection.Name(), err)
 		}
+		collection.changes.close()
+	}
+	if cached != nil {
+		if err := db.saveSearchCache(cached); err != nil {
+			fmt.Printf("Error saving search cache: %v\n", err)
+		}
+	}
+	db.searchCache.Close()
+
+	// Only once everything is saved may another writer open the directory
+	if db.lock != nil {
+		if err := db.lock.release(); err != nil {
+			fmt.Printf("Error releasing data directory lock: %v\n", err)
+		}
+		db.lock = nil
 	}
 
 	db.closed = true
@@ -81,19 +208,25 @@ func (db *VittoriaDB) Health() *HealthStatus {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	var totalVectors int64
+	var totalVectors, memoryUsage int64
 	for _, collection := range db.collections {
 		if count, err := collection.Count(); err == nil {
 			totalVectors += count
 		}
+		memoryUsage += collection.MemoryEstimate()
+	}
+
+	status := "healthy"
+	if db.closed {
+		status = "closed"
 	}
 
 	return &HealthStatus{
-		Status:       "healthy",
+		Status:       status,
 		Uptime:       int64(time.Since(db.startTime).Seconds()),
 		Collections:  len(db.collections),
 		TotalVectors: totalVectors,
-		MemoryUsage:  0, // TODO: Implement memory usage calculation
+		MemoryUsage:  memoryUsage,
 		DiskUsage:    0, // TODO: Implement disk usage calculation
 	}
 }
@@ -103,13 +236,63 @@ func (db *VittoriaDB) CreateCollection(ctx context.Context, req *CreateCollectio
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if db.closed {
-		return fmt.Errorf("database is closed")
+	if err := db.writable(); err != nil {
+		return err
+	}
+	return db.createCollection(ctx, req)
+}
+
+// ValidateCreateCollection checks a collection creation request as
+// CreateCollection does, without creating anything. Dimensions left out are
+// inferred from the vectorizer into req.
+func (db *VittoriaDB) ValidateCreateCollection(ctx context.Context, req *CreateCollectionRequest) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := db.writable(); err != nil {
+		return err
+	}
+	if _, exists := db.collections[req.Name]; exists {
+		return errorf(ErrAlreadyExists, "collection '%s' already exists", req.Name)
+	}
+	vectorizerConfig, err := db.requestVectorizer(req)
+	if err != nil {
+		return err
+	}
+	if req.Dimensions == 0 && vectorizerConfig != nil {
+		dimensions, err := embeddings.InferDimensions(vectorizerConfig)
+		if err != nil {
+			return err
+		}
+		req.Dimensions = dimensions
+	}
+	if err := db.validateCreateCollectionRequest(req); err != nil {
+		return err
 	}
+	if req.Sharding != nil {
+		return validateShardingConfig(req.Sharding)
+	}
+	return nil
+}
 
+// createCollection creates a collection; the caller holds mu
+func (db *VittoriaDB) createCollection(ctx context.Context, req *CreateCollectionRequest) error {
 	// Check if collection already exists
 	if _, exists := db.collections[req.Name]; exists {
-		return fmt.Errorf("collection '%s' already exists", req.Name)
+		return errorf(ErrAlreadyExists, "collection '%s' already exists", req.Name)
+	}
+
+	// Without dimensions, take those of the vectorizer's model
+	requested, err := db.requestVectorizer(req)
+	if err != nil {
+		return err
+	}
+	if req.Dimensions == 0 && requested != nil {
+		dimensions, err := embeddings.InferDimensions(requested)
+		if err != nil {
+			return err
+		}
+		req.Dimensions = dimensions
 	}
 
 	// Validate request
@@ -123,19 +306,58 @@ func (db *VittoriaDB) CreateCollection(ctx context.Context, req *CreateCollectio
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
 
-	// Initialize collection
-	if err := collection.Initialize(ctx); err != nil {
-		return fmt.Errorf("failed to initialize collection: %w", err)
+	// Split the collection across shards if requested
+	if req.Sharding != nil {
+		if err := collection.enableSharding(req.Sharding); err != nil {
+			return err
+		}
+	}
+
+	// Pre-size storage for the expected number of vectors
+	if req.ExpectedCount > 0 {
+		collection.reserve(req.ExpectedCount)
+	}
+	collection.bulkLoading = req.BulkLoad
+	collection.internal = req.Internal
+	// The index is recreated with the database-wide and requested settings
+	params, err := parseHNSWParams(req.Config, req.IndexType)
+	if err != nil {
+		return err
+	}
+	if err := collection.setIndexOptions(db.indexOptions(req.Name), params); err != nil {
+		return err
+	}
+	collection.setIndexedFields(req.IndexedFields)
+	if req.Quantization != nil {
+		if err := collection.enableQuantization(req.Quantization); err != nil {
+			return err
+		}
 	}
 
-	// Set up vectorizer if configured
-	if req.VectorizerConfig != nil {
+	// Set up the vectorizer before anything is written, so a bad config
+	// leaves nothing behind; it is persisted with the collection
+	if requested != nil {
+		vectorizerConfig := *requested
+		if vectorizerConfig.Dimensions == 0 {
+			vectorizerConfig.Dimensions = req.Dimensions
+		}
+
 		factory := embeddings.NewVectorizerFactory()
-		vectorizer, err := factory.CreateVectorizer(req.VectorizerConfig)
+		vectorizer, err := factory.CreateVectorizer(&vectorizerConfig)
 		if err != nil {
 			return fmt.Errorf("failed to create vectorizer: %w", err)
 		}
+		if vectorizer.GetDimensions() != req.Dimensions {
+			vectorizer.Close()
+			return fmt.Errorf("vectorizer produces %d dimensions but the collection has %d", vectorizer.GetDimensions(), req.Dimensions)
+		}
 		collection.SetVectorizer(vectorizer)
+		collection.vectorizerConf = vectorizerConfig.WithoutSecrets()
+	}
+
+	// Initialize collection
+	if err := collection.Initialize(ctx); err != nil {
+		return fmt.Errorf("failed to initialize collection: %w", err)
 	}
 
 	db.collections[req.Name] = collection
@@ -148,28 +370,42 @@ func (db *VittoriaDB) GetCollection(ctx context.Context, name string) (Collectio
 	defer db.mu.RUnlock()
 
 	if db.closed {
-		return nil, fmt.Errorf("database is closed")
+		return nil, errorf(ErrClosed, "database is closed")
 	}
 
 	collection, exists := db.collections[name]
 	if !exists {
-		return nil, fmt.Errorf("collection '%s' not found", name)
+		return nil, errorf(ErrNotFound, "collection '%s' not found", name)
 	}
 
 	return collection, nil
 }
 
-// ListCollections returns information about all collections
+// ListCollections returns information about all collections but internal ones
 func (db *VittoriaDB) ListCollections(ctx context.Context) ([]*CollectionInfo, error) {
+	return db.listCollections(false)
+}
+
+// ListAllCollections returns information about all collections, internal ones
+// included
+func (db *VittoriaDB) ListAllCollections(ctx context.Context) ([]*CollectionInfo, error) {
+	return db.listCollections(true)
+}
+
+// listCollections returns information about the collections
+func (db *VittoriaDB) listCollections(includeInternal bool) ([]*CollectionInfo, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	if db.closed {
-		return nil, fmt.Errorf("database is closed")
+		return nil, errorf(ErrClosed, "database is closed")
 	}
 
 	collections := make([]*CollectionInfo, 0, len(db.collections))
 	for _, collection := range db.collections {
+		if collection.Internal() && !includeInternal {
+			continue
+		}
 		info, err := collection.Info()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get collection info: %w", err)
@@ -180,29 +416,62 @@ func (db *VittoriaDB) ListCollections(ctx context.Context) ([]*CollectionInfo, e
 	return collections, nil
 }
 
-// DropCollection deletes a collection
+// DropCollection deletes a collection. With a trash retention configured, its
+// files are moved to the trash, from which RestoreCollection can bring it back
+// until the retention has passed. Internal collections cannot be dropped: those
+// of a group are dropped with it, and others must be made regular first.
 func (db *VittoriaDB) DropCollection(ctx context.Context, name string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if db.closed {
-		return fmt.Errorf("database is closed")
+	if err := db.writable(); err != nil {
+		return err
 	}
+	collection, exists := db.collections[name]
+	if !exists {
+		return errorf(ErrNotFound, "collection '%s' not found", name)
+	}
+	if collection.group != "" {
+		return errorf(ErrConflict, "collection '%s' belongs to group '%s'; drop the group instead", name, collection.group)
+	}
+	if collection.Internal() {
+		return errorf(ErrConflict, "collection '%s' is internal; set internal to false before dropping it", name)
+	}
+	return db.dropCollection(ctx, name, db.config.Storage.TrashRetention)
+}
 
+// dropCollection closes a collection and moves its files to the trash for
+// retention, or deletes them when retention is 0; the caller holds mu
+func (db *VittoriaDB) dropCollection(ctx context.Context, name string, retention time.Duration) error {
 	collection, exists := db.collections[name]
 	if !exists {
-		return fmt.Errorf("collection '%s' not found", name)
+		return errorf(ErrNotFound, "collection '%s' not found", name)
+	}
+
+	// Remote shards are regular collections on their nodes and must be dropped there
+	if err := collection.dropRemoteShards(ctx); err != nil {
+		fmt.Printf("Error dropping shards of %s: %v\n", name, err)
 	}
 
 	// Close and remove collection
 	if err := collection.Close(); err != nil {
 		return fmt.Errorf("failed to close collection: %w", err)
 	}
+	collection.changes.close()
 
 	// Remove collection files
-	collectionDir := filepath.Join(db.dataDir, name)
-	if err := os.RemoveAll(collectionDir); err != nil {
-		return fmt.Errorf("failed to remove collection files: %w", err)
+	if retention > 0 {
+		if err := db.trashCollection(name, retention); err != nil {
+			return err
+		}
+	} else {
+		collectionDir, err := collectionPath(db.dataDir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(collectionDir); err != nil {
+			return fmt.Errorf("failed to remove collection files: %w", err)
+		}
 	}
 
 	delete(db.collections, name)
@@ -215,7 +484,7 @@ func (db *VittoriaDB) Stats(ctx context.Context) (*DatabaseStats, error) {
 	defer db.mu.RUnlock()
 
 	if db.closed {
-		return nil, fmt.Errorf("database is closed")
+		return nil, errorf(ErrClosed, "database is closed")
 	}
 
 	var totalVectors int64
@@ -223,23 +492,30 @@ func (db *VittoriaDB) Stats(ctx context.Context) (*DatabaseStats, error) {
 	var indexSize int64
 	collectionStats := make([]*CollectionStats, 0, len(db.collections))
 
+	internal := 0
 	for _, collection := range db.collections {
 		count, err := collection.Count()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get collection count: %w", err)
 		}
+		totalVectors += count
+
+		if collection.Internal() {
+			internal++
+			continue
+		}
 
 		stats := &CollectionStats{
 			Name:         collection.Name(),
 			VectorCount:  count,
 			Dimensions:   collection.Dimensions(),
 			IndexType:    collection.indexType,
+			MemoryBytes:  collection.MemoryEstimate(),
 			IndexSize:    0,          // TODO: Implement index size calculation
 			LastModified: time.Now(), // TODO: Implement last modified tracking
 		}
 
 		collectionStats = append(collectionStats, stats)
-		totalVectors += count
 	}
 
 	return &DatabaseStats{
@@ -250,13 +526,13 @@ func (db *VittoriaDB) Stats(ctx context.Context) (*DatabaseStats, error) {
 		QueriesTotal:    0, // TODO: Implement query tracking
 		QueriesPerSec:   0, // TODO: Implement QPS calculation
 		AvgQueryLatency: 0, // TODO: Implement latency tracking
-	}, nil
-}
+		Maintenance:     db.maintenanceStatuses(),
+		Deferred:        db.deferredStatuses(),
+		SearchCache:     db.searchCacheStats(),
+		Memory:          db.memoryStats(),
 
-// Backup creates a backup of the database
-func (db *VittoriaDB) Backup(ctx context.Context, w io.Writer) error {
-	// TODO: Implement backup functionality
-	return fmt.Errorf("backup not implemented yet")
+		InternalCollections: internal,
+	}, nil
 }
 
 // Restore restores the database from a backup
@@ -285,8 +561,13 @@ func (db *VittoriaDB) loadCollections(ctx context.Context) error {
 			continue
 		}
 
+		collectionName, err := db.migrateCollectionDir(collectionName)
+		if err != nil {
+			return fmt.Errorf("failed to migrate collection %s: %w", entry.Name(), err)
+		}
+
 		// Load collection metadata and create collection
-		collection, err := LoadCollection(collectionName, db.dataDir)
+		collection, err := openCollection(collectionName, db.dataDir, db.indexOptions(collectionName), db.config.ReadOnly)
 		if err != nil {
 			return fmt.Errorf("failed to load collection %s: %w", collectionName, err)
 		}
@@ -299,32 +580,48 @@ func (db *VittoriaDB) loadCollections(ctx context.Context) error {
 
 // validateCreateCollectionRequest validates the collection creation request
 func (db *VittoriaDB) validateCreateCollectionRequest(req *CreateCollectionRequest) error {
-	if req.Name == "" {
-		return fmt.Errorf("collection name cannot be empty")
+	if err := ValidateCollectionName(req.Name); err != nil {
+		return err
+	}
+
+	// Directories of names that differ only in case are one directory on
+	// case-insensitive file systems
+	for name := range db.collections {
+		if strings.EqualFold(name, req.Name) && name != req.Name {
+			return errorf(ErrAlreadyExists, "collection '%s' already exists, and names differing only in case are not allowed", name)
+		}
+	}
+
+	if err := db.validateLimits(req); err != nil {
+		return err
+	}
+
+	if !slices.Contains(DistanceMetrics, req.Metric) {
+		return fmt.Errorf("invalid metric %d: use one of %s", req.Metric, allowedValues(DistanceMetrics))
+	}
+
+	if !slices.Contains(IndexTypes, req.IndexType) {
+		return fmt.Errorf("invalid index type %d: use one of %s", req.IndexType, allowedValues(IndexTypes))
 	}
 
-	if req.Dimensions <= 0 {
-		return fmt.Errorf("dimensions must be positive")
+	if req.ExpectedCount < 0 {
+		return fmt.Errorf("expected_count cannot be negative")
 	}
 
-	if req.Dimensions > 10000 {
-		return fmt.Errorf("dimensions cannot exceed 10000")
+	if req.ExpectedCount > maxExpectedCount {
+		return fmt.Errorf("expected_count cannot exceed %d", maxExpectedCount)
 	}
 
-	// Validate metric
-	switch req.Metric {
-	case DistanceMetricCosine, DistanceMetricEuclidean, DistanceMetricDotProduct, DistanceMetricManhattan:
-		// Valid metrics
-	default:
-		return fmt.Errorf("invalid distance metric")
+	if _, err := parseHNSWParams(req.Config, req.IndexType); err != nil {
+		return err
+	}
+
+	if err := validateIndexedFields(req.IndexedFields); err != nil {
+		return err
 	}
 
-	// Validate index type
-	switch req.IndexType {
-	case IndexTypeFlat, IndexTypeHNSW:
-		// Valid index types
-	default:
-		return fmt.Errorf("invalid index type")
+	if req.Quantization != nil {
+		return validateQuantization(req)
 	}
 
 	return nil
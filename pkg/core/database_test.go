@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// openTestDatabase opens a database for a test, in a temporary directory
+// unless config names one, and closes it when the test ends
+func openTestDatabase(t *testing.T, config *Config) *VittoriaDB {
+	t.Helper()
+	if config == nil {
+		config = &Config{}
+	}
+	if config.DataDir == "" {
+		config.DataDir = t.TempDir()
+	}
+	db := NewDatabase()
+	if err := db.Open(context.Background(), config); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestValidateCreateCollection(t *testing.T) {
+	var request CreateCollectionRequest
+	if err := json.Unmarshal([]byte(`{"name":"docs","dimensions":2,"metric":"dot_product","index_type":1}`), &request); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if request.Metric != DistanceMetricDotProduct || request.IndexType != IndexTypeHNSW {
+		t.Errorf("got metric %v and index %v", request.Metric, request.IndexType)
+	}
+	for _, body := range []string{`{"metric":"chebyshev"}`, `{"metric":7}`, `{"index_type":"ivf"}`} {
+		if err := json.Unmarshal([]byte(body), &CreateCollectionRequest{}); err == nil {
+			t.Errorf("%s: expected an error", body)
+		}
+	}
+
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	if err := db.ValidateCreateCollection(ctx, &request); err != nil {
+		t.Fatalf("ValidateCreateCollection failed: %v", err)
+	}
+	if collections, _ := db.ListCollections(ctx); len(collections) != 0 {
+		t.Fatalf("validation created a collection")
+	}
+	if err := db.ValidateCreateCollection(ctx, &CreateCollectionRequest{Name: "big", Dimensions: MaxDimensions + 1}); err == nil {
+		t.Errorf("expected too many dimensions to be rejected")
+	}
+	db.CreateCollection(ctx, &request)
+	if err := db.ValidateCreateCollection(ctx, &request); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected already exists, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// duplicate is a vector found to repeat the one stored under kept
+type duplicate struct {
+	key         string
+	kept        string
+	fingerprint string
+}
+
+// Deduplicate removes vectors that repeat another vector of the same
+// namespace, with identical components and metadata, keeping the one with the
+// smallest ID. It returns how many were removed. Sharded collections
+// deduplicate each local shard.
+func (c *VittoriaCollection) Deduplicate(ctx context.Context) (int, error) {
+	if c.readOnly {
+		return 0, c.errReadOnly()
+	}
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		removed := 0
+		for _, s := range c.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				n, err := local.Deduplicate(ctx)
+				removed += n
+				if err != nil {
+					return removed, err
+				}
+			}
+		}
+		return removed, nil
+	}
+
+	// Scan under the read lock so collections without duplicates do not block writers
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return 0, nil
+	}
+	keys := make([]string, 0, len(c.vectors))
+	for key := range c.vectors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kept := make(map[string]string, len(keys))
+	var duplicates []duplicate
+	for _, key := range keys {
+		fingerprint, err := c.fingerprint(c.vectors[key])
+		if err != nil {
+			c.mu.RUnlock()
+			return 0, err
+		}
+		if first, exists := kept[fingerprint]; exists {
+			duplicates = append(duplicates, duplicate{key: key, kept: first, fingerprint: fingerprint})
+			continue
+		}
+		kept[fingerprint] = key
+	}
+	c.mu.RUnlock()
+
+	if len(duplicates) == 0 {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	removed := 0
+	for _, d := range duplicates {
+		// Either vector may have been replaced or deleted since the scan
+		vector, exists := c.vectors[d.key]
+		original, keptExists := c.vectors[d.kept]
+		if !exists || !keptExists || !c.sameFingerprint(vector, d.fingerprint) || !c.sameFingerprint(original, d.fingerprint) {
+			continue
+		}
+		if err := c.indexRemove(ctx, vector); err != nil {
+			c.mu.Unlock()
+			return removed, fmt.Errorf("failed to remove vector from index: %w", err)
+		}
+		delete(c.vectors, d.key)
+		c.changes.publish(ChangeDelete, vector)
+		removed++
+	}
+	if removed > 0 {
+		c.touch()
+	}
+	c.mu.Unlock()
+
+	return removed, nil
+}
+
+// vectorFingerprint hashes what makes two vectors duplicates: their
+// namespace, components and metadata
+func vectorFingerprint(vector *Vector) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(vector.Namespace))
+	h.Write([]byte{0})
+
+	var buf [4]byte
+	for _, v := range vector.Vector {
+		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(v))
+		h.Write(buf[:])
+	}
+
+	// Map keys are marshaled in sorted order, so equal metadata encodes equally
+	metadata, err := json.Marshal(vector.Metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata of vector %s: %w", vector.ID, err)
+	}
+	h.Write(metadata)
+
+	return string(h.Sum(nil)), nil
+}
+
+// fingerprint returns the fingerprint of a stored vector. The caller must
+// hold c.mu.
+func (c *VittoriaCollection) fingerprint(vector *Vector) (string, error) {
+	full, err := c.withVectorData(vector)
+	if err != nil {
+		return "", err
+	}
+	return vectorFingerprint(full)
+}
+
+// sameFingerprint reports whether a stored vector still has the given
+// fingerprint. The caller must hold c.mu.
+func (c *VittoriaCollection) sameFingerprint(vector *Vector, fingerprint string) bool {
+	current, err := c.fingerprint(vector)
+	return err == nil && current == fingerprint
+}
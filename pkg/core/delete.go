@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DeleteBatch removes the vectors with the given IDs from a namespace and
+// returns how many were removed. IDs that do not exist are skipped.
+func (c *VittoriaCollection) DeleteBatch(ctx context.Context, namespace string, ids []string) (int, error) {
+	if c.readOnly {
+		return 0, c.errReadOnly()
+	}
+	if err := ValidateNamespace(namespace); err != nil {
+		return 0, err
+	}
+
+	if c.isSharded() {
+		removed := 0
+		for _, id := range ids {
+			if err := c.deleteFromShards(ctx, namespace, id); err != nil {
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+				return removed, err
+			}
+			removed++
+		}
+		if removed > 0 {
+			c.mu.Lock()
+			c.touch()
+			c.mu.Unlock()
+		}
+		return removed, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, errorf(ErrClosed, "collection is closed")
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if key := vectorKey(namespace, id); c.vectors[key] != nil {
+			keys = append(keys, key)
+		}
+	}
+	return c.deleteKeys(ctx, keys)
+}
+
+// DeleteByFilter removes every vector of a namespace whose metadata matches
+// filter and returns how many were removed. Expired vectors are left to the
+// janitor, as they match no query.
+func (c *VittoriaCollection) DeleteByFilter(ctx context.Context, namespace string, filter *Filter) (int, error) {
+	if c.readOnly {
+		return 0, c.errReadOnly()
+	}
+	if err := ValidateDeleteFilter(filter); err != nil {
+		return 0, err
+	}
+	if err := ValidateNamespace(namespace); err != nil {
+		return 0, err
+	}
+
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		removed := 0
+		for i, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				return removed, fmt.Errorf("shard %s: deleting by filter requires local shards", c.shardName(i))
+			}
+			n, err := local.DeleteByFilter(ctx, namespace, filter)
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+		}
+		return removed, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, errorf(ErrClosed, "collection is closed")
+	}
+
+	var keys []string
+	now := time.Now()
+	for key, vector := range c.vectors {
+		if vector.Namespace == namespace && !isExpired(vector, now) && matchFilter(vector.Metadata, filter) {
+			keys = append(keys, key)
+		}
+	}
+	return c.deleteKeys(ctx, keys)
+}
+
+// ValidateDeleteFilter checks a filter selecting records to delete. An empty
+// filter would match every record, so it is refused: dropping the collection
+// says so more clearly.
+func ValidateDeleteFilter(filter *Filter) error {
+	if filter == nil || (filter.Field == "" && len(filter.And) == 0 && len(filter.Or) == 0 && filter.Not == nil) {
+		return fmt.Errorf("a delete filter needs at least one condition")
+	}
+	return ValidateFilter(filter)
+}
+
+// deleteKeys removes the stored vectors with the given keys and returns how
+// many were removed; the caller holds mu. The index drops them in one batch.
+func (c *VittoriaCollection) deleteKeys(ctx context.Context, keys []string) (int, error) {
+	vectors := make([]*Vector, 0, len(keys))
+	listed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if vector, exists := c.vectors[key]; exists && !listed[key] {
+			listed[key] = true
+			vectors = append(vectors, vector)
+		}
+	}
+	if err := c.indexRemoveBatch(ctx, vectors); err != nil {
+		return 0, fmt.Errorf("failed to remove vectors from index: %w", err)
+	}
+
+	removed := 0
+	for _, vector := range vectors {
+		delete(c.vectors, vector.key())
+		c.changes.publish(ChangeDelete, vector)
+		removed++
+	}
+
+	if removed > 0 {
+		c.touch()
+	}
+	return removed, nil
+}
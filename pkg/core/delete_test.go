@@ -0,0 +1,39 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestDeleteBatchAndByFilter(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, IndexType: IndexTypeHNSW}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	vc := collection.(*VittoriaCollection)
+	for i := 0; i < 6; i++ {
+		vc.Insert(ctx, &Vector{ID: fmt.Sprintf("v%d", i), Vector: []float32{float32(i), 1}, Metadata: map[string]interface{}{"document_id": fmt.Sprintf("doc%d", i%2)}})
+	}
+
+	if n, err := vc.DeleteBatch(ctx, "", []string{"v0", "v0", "missing"}); err != nil || n != 1 {
+		t.Fatalf("DeleteBatch = %d, %v; want 1", n, err)
+	}
+	if _, err := vc.DeleteByFilter(ctx, "", &Filter{}); err == nil {
+		t.Errorf("expected an empty filter to be refused")
+	}
+	if n, err := vc.DeleteByFilter(ctx, "", &Filter{Field: "document_id", Value: "doc1"}); err != nil || n != 3 {
+		t.Fatalf("DeleteByFilter = %d, %v; want 3", n, err)
+	}
+
+	response, err := vc.Search(ctx, &SearchRequest{Vector: []float32{1, 1}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Results) != 2 {
+		t.Errorf("got %d results after the deletes, want 2", len(response.Results))
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors classifying what went wrong; test for them with errors.Is
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrAlreadyExists     = errors.New("already exists")
+	ErrDimensionMismatch = errors.New("dimension mismatch")
+	ErrConditionFailed   = errors.New("condition not met")
+	ErrClosed            = errors.New("closed")
+	ErrConflict          = errors.New("conflict")
+	ErrReadOnly          = errors.New("read-only")
+	ErrLocked            = errors.New("locked")
+	ErrLimitExceeded     = errors.New("limit exceeded")
+	ErrMemoryLimit       = errors.New("memory limit reached")
+)
+
+// Error codes identifying the sentinel errors to clients
+const (
+	CodeNotFound          = "not_found"
+	CodeAlreadyExists     = "already_exists"
+	CodeDimensionMismatch = "dimension_mismatch"
+	CodeConditionFailed   = "condition_failed"
+	CodeClosed            = "closed"
+	CodeConflict          = "conflict"
+	CodeReadOnly          = "read_only"
+	CodeLocked            = "locked"
+	CodeLimitExceeded     = "limit_exceeded"
+	CodeMemoryLimit       = "memory_limit"
+)
+
+// errorCodes maps the sentinel errors to their codes
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrNotFound, CodeNotFound},
+	{ErrAlreadyExists, CodeAlreadyExists},
+	{ErrDimensionMismatch, CodeDimensionMismatch},
+	{ErrConditionFailed, CodeConditionFailed},
+	{ErrClosed, CodeClosed},
+	{ErrConflict, CodeConflict},
+	{ErrReadOnly, CodeReadOnly},
+	{ErrLocked, CodeLocked},
+	{ErrLimitExceeded, CodeLimitExceeded},
+	{ErrMemoryLimit, CodeMemoryLimit},
+}
+
+// kindError is an error of the kind of a sentinel with its own message
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string { return e.message }
+
+func (e *kindError) Unwrap() error { return e.kind }
+
+// errorf formats an error that matches the sentinel kind with errors.Is
+func errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCode returns the code of the sentinel err wraps, or "" when it wraps
+// none
+func ErrorCode(err error) string {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// ErrorFromCode rebuilds an error reported with a code by another node, so
+// that errors.Is matches the sentinel of the code
+func ErrorFromCode(code, message string) error {
+	for _, c := range errorCodes {
+		if c.code == code {
+			return &kindError{kind: c.err, message: message}
+		}
+	}
+	return errors.New(message)
+}
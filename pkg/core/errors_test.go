@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	request := &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}
+	if err := db.CreateCollection(ctx, request); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if err := db.CreateCollection(ctx, request); !errors.Is(err, ErrAlreadyExists) || ErrorCode(err) != CodeAlreadyExists {
+		t.Errorf("expected already exists, got %v", err)
+	}
+	if _, err := db.GetCollection(ctx, "missing"); !errors.Is(err, ErrNotFound) || err.Error() != "collection 'missing' not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	collection, _ := db.GetCollection(ctx, "docs")
+	err := collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 2, 3}})
+	if !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("expected dimension mismatch, got %v", err)
+	}
+	if _, err := collection.Get(ctx, "a"); ErrorCode(err) != CodeNotFound {
+		t.Errorf("expected code %s, got %q for %v", CodeNotFound, ErrorCode(err), err)
+	}
+
+	// Codes survive the trip through another node
+	remote := ErrorFromCode(CodeNotFound, "vector 'a' not found")
+	if !errors.Is(remote, ErrNotFound) || remote.Error() != "vector 'a' not found" {
+		t.Errorf("ErrorFromCode lost the kind or message: %v", remote)
+	}
+
+	db.Close()
+	if _, err := db.ListCollections(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected closed, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Export formats
+const (
+	ExportFormatJSONL   = "jsonl"   // One vector per line, in the JSON of the vectors API
+	ExportFormatParquet = "parquet" // Columns id, namespace, vector and metadata (a JSON string)
+)
+
+// ExportFormats lists the export formats
+var ExportFormats = []string{ExportFormatJSONL, ExportFormatParquet}
+
+// exportRowGroupSize is how many records a Parquet row group holds, which
+// bounds the memory an export buffers
+const exportRowGroupSize = 10000
+
+// ExportRequest selects the format and the records of an export
+type ExportRequest struct {
+	Format    string
+	Namespace *string // Only this namespace; nil exports every namespace
+}
+
+// exportRow is a record as written to Parquet
+type exportRow struct {
+	ID        string    `parquet:"id"`
+	Namespace string    `parquet:"namespace"`
+	Vector    []float32 `parquet:"vector,list"`
+	Metadata  string    `parquet:"metadata,json"`
+}
+
+// Export writes the records of the collection to w, ordered by namespace and
+// ID, and returns how many it wrote. Metadata-only records are exported with
+// an empty vector.
+func (c *VittoriaCollection) Export(ctx context.Context, w io.Writer, req *ExportRequest) (int, error) {
+	if req.Namespace != nil {
+		if err := ValidateNamespace(*req.Namespace); err != nil {
+			return 0, err
+		}
+	}
+
+	var write func(*Vector) error
+	var finish func() error
+	switch req.Format {
+	case ExportFormatJSONL, "":
+		encoder := json.NewEncoder(w)
+		write = func(record *Vector) error { return encoder.Encode(record) }
+		finish = func() error { return nil }
+	case ExportFormatParquet:
+		writer := parquet.NewGenericWriter[exportRow](w, parquet.Compression(&parquet.Zstd))
+		rows := 0
+		write = func(record *Vector) error {
+			metadata, err := json.Marshal(record.Metadata)
+			if err != nil {
+				return err
+			}
+			row := exportRow{ID: record.ID, Namespace: record.Namespace, Vector: record.Vector, Metadata: string(metadata)}
+			if _, err := writer.Write([]exportRow{row}); err != nil {
+				return err
+			}
+			if rows++; rows%exportRowGroupSize == 0 {
+				return writer.Flush()
+			}
+			return nil
+		}
+		finish = writer.Close
+	default:
+		return 0, fmt.Errorf("unknown export format '%s': expected one of %s", req.Format, strings.Join(ExportFormats, ", "))
+	}
+
+	records, err := c.ExportRecords(req.Namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, record := range records {
+		if i%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return i, err
+			}
+		}
+		if err := write(record); err != nil {
+			return i, fmt.Errorf("failed to write export: %w", err)
+		}
+	}
+	if err := finish(); err != nil {
+		return len(records), fmt.Errorf("failed to write export: %w", err)
+	}
+	return len(records), nil
+}
+
+// ExportRecords returns the unexpired records of a namespace, or of all of
+// them when namespace is nil, ordered by namespace and ID as exports are
+func (c *VittoriaCollection) ExportRecords(namespace *string) ([]*Vector, error) {
+	if namespace != nil {
+		if err := ValidateNamespace(*namespace); err != nil {
+			return nil, err
+		}
+	}
+	records, err := c.exportRecords(namespace)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Namespace != records[j].Namespace {
+			return records[i].Namespace < records[j].Namespace
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records, nil
+}
+
+// exportRecords returns the unexpired records of a namespace, or of all of
+// them when namespace is nil, from every shard of sharded collections.
+// Records are replaced rather than changed in place, so they can be written
+// out after the lock is released.
+func (c *VittoriaCollection) exportRecords(namespace *string) ([]*Vector, error) {
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		var records []*Vector
+		for i, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				return nil, fmt.Errorf("shard %s: exports require local shards", c.shardName(i))
+			}
+			shardRecords, err := local.exportRecords(namespace)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, shardRecords...)
+		}
+		return records, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	now := time.Now()
+	records := make([]*Vector, 0, len(c.vectors))
+	for _, vector := range c.vectors {
+		if namespace != nil && vector.Namespace != *namespace {
+			continue
+		}
+		if isExpired(vector, now) {
+			continue
+		}
+		record, err := c.withVectorData(vector)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	collection, err := NewCollection("docs", 2, DistanceMetricCosine, IndexTypeFlat, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	if err := collection.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	defer collection.Close()
+
+	collection.InsertBatch(ctx, []*Vector{
+		{ID: "b", Vector: []float32{0, 1}, Metadata: map[string]interface{}{"label": "draft"}},
+		{ID: "a", Vector: []float32{1, 0}, Metadata: map[string]interface{}{"label": "final"}},
+		{ID: "a", Namespace: "tenant", Vector: []float32{1, 1}},
+		{ID: "note", Metadata: map[string]interface{}{"text": "metadata only"}},
+	})
+
+	var jsonl bytes.Buffer
+	n, err := collection.Export(ctx, &jsonl, &ExportRequest{Format: ExportFormatJSONL})
+	if err != nil || n != 4 {
+		t.Fatalf("Export = %d, %v; want 4 records", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], `{"id":"a",`) || !strings.HasPrefix(lines[3], `{"id":"a","namespace":"tenant"`) {
+		t.Errorf("unexpected JSON lines export:\n%s", jsonl.String())
+	}
+
+	var buf bytes.Buffer
+	tenant := "tenant"
+	if n, err := collection.Export(ctx, &buf, &ExportRequest{Format: ExportFormatParquet, Namespace: &tenant}); err != nil || n != 1 {
+		t.Fatalf("Parquet export = %d, %v; want 1 record", n, err)
+	}
+	rows, err := parquet.Read[exportRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to read Parquet export: %v", err)
+	}
+	if len(rows) != 1 || rows[0].ID != "a" || rows[0].Namespace != "tenant" || len(rows[0].Vector) != 2 || rows[0].Metadata != "{}" {
+		t.Errorf("unexpected Parquet rows: %+v", rows)
+	}
+
+	if _, err := collection.Export(ctx, &buf, &ExportRequest{Format: "csv"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,291 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Metadata fields named in indexed_fields when a collection is created get a
+// range index: their numeric values, sorted, with the key of the vector each
+// came from. A search whose filter requires such a field to be equal to a
+// number, in a list of numbers or within a range takes its candidates from
+// the index instead of scanning every vector, and scores only those that
+// pass the whole filter. Writes append to a pending tail that is merged into
+// the sorted entries as it grows. Entries of overwritten and deleted vectors
+// are left behind until the index is rebuilt, as they are harmless:
+// candidates are looked up again and filtered on their current metadata.
+
+const (
+	maxIndexedFields = 16 // Range indexes a collection may have
+
+	// Least pending entries before they are merged into the sorted ones
+	fieldIndexMergeMin = 256
+
+	// Share of the collection past which a range index's candidates are
+	// scanned as a whole instead
+	fieldIndexMaxShare = 0.5
+)
+
+// fieldEntry is a value of an indexed field and the key of its vector
+type fieldEntry struct {
+	value float64
+	key   string
+}
+
+// fieldIndex is the range index of a metadata field. The caller holds the
+// collection's mu, for writing to change the index.
+type fieldIndex struct {
+	field   string
+	entries []fieldEntry // Sorted by value
+	pending []fieldEntry // Added since the last merge, unsorted
+}
+
+// validateIndexedFields checks the indexed fields of a collection creation
+// request
+func validateIndexedFields(fields []string) error {
+	if len(fields) > maxIndexedFields {
+		return fmt.Errorf("indexed_fields cannot name more than %d fields", maxIndexedFields)
+	}
+	for i, field := range fields {
+		if strings.TrimSpace(field) == "" {
+			return fmt.Errorf("indexed_fields cannot hold an empty field name")
+		}
+		if slices.Contains(fields[:i], field) {
+			return fmt.Errorf("indexed field '%s' is named twice", field)
+		}
+	}
+	return nil
+}
+
+// setIndexedFields gives the collection range indexes on fields and builds
+// them; the caller holds mu or owns the collection exclusively. Sharded
+// collections record the fields for their shards.
+func (c *VittoriaCollection) setIndexedFields(fields []string) {
+	c.indexedFields = fields
+	c.fieldIndexes = nil
+	if c.isSharded() {
+		return
+	}
+	for _, field := range fields {
+		idx := &fieldIndex{field: field}
+		c.buildFieldIndex(idx)
+		c.fieldIndexes = append(c.fieldIndexes, idx)
+	}
+}
+
+// buildFieldIndex fills a range index from the stored vectors
+func (c *VittoriaCollection) buildFieldIndex(idx *fieldIndex) {
+	idx.entries = idx.entries[:0]
+	idx.pending = nil
+	for key, vector := range c.vectors {
+		for _, value := range numericValues(vector.Metadata, idx.field) {
+			idx.entries = append(idx.entries, fieldEntry{value: value, key: key})
+		}
+	}
+	sortFieldEntries(idx.entries)
+}
+
+// indexFields adds the values of a stored vector to the range indexes; the
+// caller holds mu for writing
+func (c *VittoriaCollection) indexFields(vector *Vector) {
+	key := vector.key()
+	for _, idx := range c.fieldIndexes {
+		for _, value := range numericValues(vector.Metadata, idx.field) {
+			idx.pending = append(idx.pending, fieldEntry{value: value, key: key})
+		}
+		if len(idx.pending) < max(fieldIndexMergeMin, len(idx.entries)/64) {
+			continue
+		}
+		// Rebuilt rather than merged once left-behind entries outnumber
+		// those of the stored vectors
+		if len(idx.entries)+len(idx.pending) > 2*len(c.vectors)+fieldIndexMergeMin {
+			c.buildFieldIndex(idx)
+		} else {
+			idx.merge()
+		}
+	}
+}
+
+// merge sorts the pending entries into the sorted ones
+func (idx *fieldIndex) merge() {
+	sortFieldEntries(idx.pending)
+	merged := make([]fieldEntry, 0, len(idx.entries)+len(idx.pending))
+	i, j := 0, 0
+	for i < len(idx.entries) && j < len(idx.pending) {
+		if idx.pending[j].value < idx.entries[i].value {
+			merged = append(merged, idx.pending[j])
+			j++
+		} else {
+			merged = append(merged, idx.entries[i])
+			i++
+		}
+	}
+	merged = append(merged, idx.entries[i:]...)
+	idx.entries = append(merged, idx.pending[j:]...)
+	idx.pending = nil
+}
+
+// lookup returns the keys of the entries whose value is within a range,
+// each bound included when its flag is set; keys may repeat
+func (idx *fieldIndex) lookup(low, high float64, includeLow, includeHigh bool) []string {
+	within := func(value float64) bool {
+		return (value > low || includeLow && value == low) && (value < high || includeHigh && value == high)
+	}
+
+	start := sort.Search(len(idx.entries), func(i int) bool {
+		if includeLow {
+			return idx.entries[i].value >= low
+		}
+		return idx.entries[i].value > low
+	})
+	var keys []string
+	for _, entry := range idx.entries[start:] {
+		if !within(entry.value) {
+			break
+		}
+		keys = append(keys, entry.key)
+	}
+	for _, entry := range idx.pending {
+		if within(entry.value) {
+			keys = append(keys, entry.key)
+		}
+	}
+	return keys
+}
+
+// candidates returns the keys of the vectors whose field may satisfy a
+// filter condition, and false when the index cannot answer the condition
+func (idx *fieldIndex) candidates(condition *Filter) ([]string, bool) {
+	if condition.Operator == FilterOpIn {
+		values, ok := numericList(condition.Value)
+		if !ok {
+			return nil, false
+		}
+		var keys []string
+		for _, value := range values {
+			keys = append(keys, idx.lookup(value, value, true, true)...)
+		}
+		return keys, true
+	}
+
+	value, ok := filterNumber(condition.Value)
+	if !ok || math.IsNaN(value) {
+		return nil, false
+	}
+	switch condition.Operator {
+	case FilterOpEq, "":
+		return idx.lookup(value, value, true, true), true
+	case FilterOpGt:
+		return idx.lookup(value, math.Inf(1), false, true), true
+	case FilterOpGte:
+		return idx.lookup(value, math.Inf(1), true, true), true
+	case FilterOpLt:
+		return idx.lookup(math.Inf(-1), value, true, false), true
+	case FilterOpLte:
+		return idx.lookup(math.Inf(-1), value, true, true), true
+	}
+	return nil, false
+}
+
+// filterCandidates returns the vectors a filter may accept according to the
+// range indexes: those of the condition that narrows them most among the
+// ones the filter requires. It returns nil when no index applies or the
+// candidates are too large a share of the collection to be worth it. The
+// caller holds mu.
+func (c *VittoriaCollection) filterCandidates(filter *Filter) []*Vector {
+	if filter == nil || len(c.fieldIndexes) == 0 {
+		return nil
+	}
+
+	var best []string
+	found := false
+	for _, clause := range filterClauses(filter) {
+		if clause.Field == "" {
+			continue
+		}
+		for _, idx := range c.fieldIndexes {
+			if idx.field != clause.Field {
+				continue
+			}
+			if keys, ok := idx.candidates(&clause); ok && (!found || len(keys) < len(best)) {
+				best, found = keys, true
+			}
+		}
+	}
+	if !found || float64(len(best)) > fieldIndexMaxShare*float64(len(c.vectors)) {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(best))
+	candidates := make([]*Vector, 0, len(best))
+	for _, key := range best {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if vector, exists := c.vectors[key]; exists {
+			candidates = append(candidates, vector)
+		}
+	}
+	return candidates
+}
+
+// scanVectors calls fn on every vector a filter may accept, the candidates
+// of a range index when one applies, until fn returns false; the caller
+// holds mu
+func (c *VittoriaCollection) scanVectors(filter *Filter, fn func(*Vector) bool) {
+	if candidates := c.filterCandidates(filter); candidates != nil {
+		for _, vector := range candidates {
+			if !fn(vector) {
+				return
+			}
+		}
+		return
+	}
+	for _, vector := range c.vectors {
+		if !fn(vector) {
+			return
+		}
+	}
+}
+
+// numericValues returns the numbers a metadata field holds: its value, or
+// the elements of its list
+func numericValues(metadata map[string]interface{}, field string) []float64 {
+	value, exists := fieldValue(metadata, field)
+	if !exists {
+		return nil
+	}
+	var values []float64
+	anyValue(value, func(v interface{}) bool {
+		if number, ok := filterNumber(v); ok && !math.IsNaN(number) {
+			values = append(values, number)
+		}
+		return false
+	})
+	return values
+}
+
+// numericList returns the values of a list of numbers, and false when the
+// list holds anything else
+func numericList(list interface{}) ([]float64, bool) {
+	items, ok := list.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	values := make([]float64, len(items))
+	for i, item := range items {
+		if values[i], ok = filterNumber(item); !ok {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// sortFieldEntries sorts entries by value
+func sortFieldEntries(entries []fieldEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].value < entries[j].value })
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRangeIndexes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := openTestDatabase(t, &Config{DataDir: dir})
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "bad", Dimensions: 2, IndexedFields: []string{"rating", "rating"}}); err == nil {
+		t.Error("a field indexed twice was accepted")
+	}
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "items", Dimensions: 2, IndexedFields: []string{"rating", "stats.sold"}}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "items")
+	vectors := make([]*Vector, 1000)
+	for i := range vectors {
+		vectors[i] = &Vector{
+			ID:     fmt.Sprintf("v%d", i),
+			Vector: []float32{1, float32(i)},
+			Metadata: map[string]interface{}{
+				"rating": float64(i%100) / 10,
+				"stats":  map[string]interface{}{"sold": []interface{}{float64(i), float64(i + 1000)}},
+			},
+		}
+	}
+	if err := collection.InsertBatch(ctx, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	// Entries left behind by overwrites, patches and deletes are not matched
+	if err := collection.Insert(ctx, &Vector{ID: "v99", Vector: []float32{1, 0}, Metadata: map[string]interface{}{"rating": 1.0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	local := collection.(*VittoriaCollection)
+	if _, err := local.PatchMetadata(ctx, &MetadataPatchRequest{IDs: []string{"v1"}, Set: map[string]interface{}{"rating": 9.9}}); err != nil {
+		t.Fatalf("PatchMetadata failed: %v", err)
+	}
+	if err := collection.Delete(ctx, "v199"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	count := func(collection Collection, filter *Filter) int64 {
+		response, err := collection.Search(ctx, &SearchRequest{Filter: filter, CountOnly: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return response.Total
+	}
+	for _, tc := range []struct {
+		filter *Filter
+		want   int64
+	}{
+		{&Filter{Field: "rating", Operator: FilterOpGte, Value: 9.9}, 9},
+		{&Filter{Field: "rating", Operator: FilterOpGt, Value: 9.8}, 9},
+		{&Filter{Field: "rating", Operator: FilterOpEq, Value: 1}, 11},
+		{&Filter{Field: "rating", Operator: FilterOpIn, Value: []interface{}{0.5, 1.0}}, 21},
+		{&Filter{Field: "rating", Operator: FilterOpLt, Value: 0.1}, 10},
+		{&Filter{Field: "stats.sold", Operator: FilterOpLte, Value: 4}, 5},
+		{&Filter{And: []Filter{
+			{Field: "rating", Operator: FilterOpGte, Value: 9.0},
+			{Field: "stats.sold", Operator: FilterOpGte, Value: 1900},
+		}}, 10},
+	} {
+		if got := count(collection, tc.filter); got != tc.want {
+			t.Errorf("%s matched %d, want %d", describeFilter(tc.filter), got, tc.want)
+		}
+	}
+
+	local.mu.RLock()
+	candidates := local.filterCandidates(&Filter{Field: "rating", Operator: FilterOpGte, Value: 9.9})
+	local.mu.RUnlock()
+	if len(candidates) == 0 || len(candidates) > 20 {
+		t.Errorf("range index returned %d candidates, want 9 or so", len(candidates))
+	}
+
+	response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 3, Filter: &Filter{Field: "rating", Operator: FilterOpGte, Value: 9.9}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(response.Results) != 3 || response.Total != 9 {
+		t.Errorf("filtered search returned %d of %d results", len(response.Results), response.Total)
+	}
+
+	// The indexes are rebuilt when the collection is loaded again
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db = openTestDatabase(t, &Config{DataDir: dir})
+	collection, _ = db.GetCollection(ctx, "items")
+	info, _ := collection.(*VittoriaCollection).Info()
+	if !reflect.DeepEqual(info.IndexedFields, []string{"rating", "stats.sold"}) {
+		t.Errorf("indexed fields %v were not kept", info.IndexedFields)
+	}
+	if got := count(collection, &Filter{Field: "rating", Operator: FilterOpGte, Value: 9.9}); got != 9 {
+		t.Errorf("reloaded collection matched %d, want 9", got)
+	}
+}
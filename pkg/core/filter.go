@@ -0,0 +1,335 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// filterKeys are the keys of a filter object; objects without any of them are
+// shorthand for equality on each of their fields
+var filterKeys = []string{"and", "or", "not", "field", "operator", "value"}
+
+// UnmarshalJSON reads a filter, or the shorthand {"category": "technology",
+// "author": "John Doe"} requiring each field to equal its value
+func (f *Filter) UnmarshalJSON(data []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	shorthand := len(object) > 0
+	for _, key := range filterKeys {
+		if _, exists := object[key]; exists {
+			shorthand = false
+		}
+	}
+
+	if !shorthand {
+		type plain Filter
+		return json.Unmarshal(data, (*plain)(f))
+	}
+
+	fields := make([]string, 0, len(object))
+	for field := range object {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	*f = Filter{}
+	for _, field := range fields {
+		var value interface{}
+		if err := json.Unmarshal(object[field], &value); err != nil {
+			return err
+		}
+		f.And = append(f.And, Filter{Field: field, Operator: FilterOpEq, Value: value})
+	}
+	return nil
+}
+
+// matchFilter reports whether metadata satisfies a filter. A filter with
+// neither a field nor sub-filters matches everything; a missing field only
+// satisfies "ne", "not_in" and "exists": false. Fields may be dot paths into
+// nested objects, and a list field satisfies eq, the comparisons, "in" and
+// the geo operators when one of its elements does; "ne" and "not_in" then
+// require that none does.
+func matchFilter(metadata map[string]interface{}, filter *Filter) bool {
+	if filter == nil {
+		return true
+	}
+	for i := range filter.And {
+		if !matchFilter(metadata, &filter.And[i]) {
+			return false
+		}
+	}
+	if len(filter.Or) > 0 {
+		matched := false
+		for i := range filter.Or {
+			if matchFilter(metadata, &filter.Or[i]) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if filter.Not != nil && matchFilter(metadata, filter.Not) {
+		return false
+	}
+	if filter.Field == "" {
+		return true
+	}
+
+	value, exists := fieldValue(metadata, filter.Field)
+	equal := func(v interface{}) bool { return filterEqual(v, filter.Value) }
+	in := func(v interface{}) bool { return filterIn(v, filter.Value) }
+	switch filter.Operator {
+	case FilterOpEq, "":
+		return exists && anyValue(value, equal)
+	case FilterOpNe:
+		return !exists || !anyValue(value, equal)
+	case FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+		return exists && anyValue(value, func(v interface{}) bool {
+			cmp, ok := filterCompare(v, filter.Value)
+			if !ok {
+				return false
+			}
+			switch filter.Operator {
+			case FilterOpGt:
+				return cmp > 0
+			case FilterOpGte:
+				return cmp >= 0
+			case FilterOpLt:
+				return cmp < 0
+			default:
+				return cmp <= 0
+			}
+		})
+	case FilterOpIn:
+		return exists && anyValue(value, in)
+	case FilterOpNotIn:
+		return !exists || !anyValue(value, in)
+	case FilterOpContains:
+		return exists && filterContains(value, filter.Value)
+	case FilterOpGeoRadius:
+		return exists && anyValue(value, func(v interface{}) bool { return matchGeoRadius(v, filter.Value) })
+	case FilterOpGeoBBox:
+		return exists && anyValue(value, func(v interface{}) bool { return matchGeoBBox(v, filter.Value) })
+	case FilterOpExists:
+		want, ok := filter.Value.(bool)
+		if !ok {
+			want = true
+		}
+		return exists == want
+	default:
+		return false
+	}
+}
+
+// ValidateFilter checks the operators and values of a filter, so that an
+// invalid one is refused before it is used
+func ValidateFilter(filter *Filter) error {
+	if filter == nil {
+		return nil
+	}
+	for i := range filter.And {
+		if err := ValidateFilter(&filter.And[i]); err != nil {
+			return err
+		}
+	}
+	for i := range filter.Or {
+		if err := ValidateFilter(&filter.Or[i]); err != nil {
+			return err
+		}
+	}
+	if err := ValidateFilter(filter.Not); err != nil {
+		return err
+	}
+	if filter.Field == "" {
+		if filter.Operator != "" {
+			return fmt.Errorf("filter operator '%s' has no field", filter.Operator)
+		}
+		return nil
+	}
+
+	switch filter.Operator {
+	case "", FilterOpEq, FilterOpNe, FilterOpContains, FilterOpExists:
+	case FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+		if _, isNumber := filterNumber(filter.Value); !isNumber {
+			if _, isString := filter.Value.(string); !isString {
+				return fmt.Errorf("filter operator '%s' on field '%s' needs a number or a string", filter.Operator, filter.Field)
+			}
+		}
+	case FilterOpIn, FilterOpNotIn:
+		if filter.Value == nil || reflect.TypeOf(filter.Value).Kind() != reflect.Slice {
+			return fmt.Errorf("filter operator '%s' on field '%s' needs a list of values", filter.Operator, filter.Field)
+		}
+	case FilterOpGeoRadius, FilterOpGeoBBox:
+		return validateGeoFilter(filter)
+	default:
+		return fmt.Errorf("unknown filter operator '%s'", filter.Operator)
+	}
+	return nil
+}
+
+// fieldValue returns the value of a metadata field. A key holding dots is
+// looked up as it is first, and otherwise as a path such as "author.name"
+// into nested objects; a path through a list collects the values of its
+// elements into a list.
+func fieldValue(metadata map[string]interface{}, field string) (interface{}, bool) {
+	if value, exists := metadata[field]; exists || !strings.Contains(field, ".") {
+		return value, exists
+	}
+	head, rest, _ := strings.Cut(field, ".")
+	value, exists := metadata[head]
+	if !exists {
+		return nil, false
+	}
+	return pathValue(value, rest)
+}
+
+// pathValue returns the value of a dot path within a metadata value
+func pathValue(value interface{}, path string) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return fieldValue(v, path)
+	case []interface{}:
+		var values []interface{}
+		for _, item := range v {
+			found, ok := pathValue(item, path)
+			if !ok {
+				continue
+			}
+			if list, isList := found.([]interface{}); isList {
+				values = append(values, list...)
+			} else {
+				values = append(values, found)
+			}
+		}
+		return values, len(values) > 0
+	}
+	return nil, false
+}
+
+// anyValue reports whether match holds for a metadata value or, when the
+// value is a list, for one of its elements
+func anyValue(value interface{}, match func(interface{}) bool) bool {
+	if match(value) {
+		return true
+	}
+	switch list := value.(type) {
+	case nil, string, bool, float64:
+		return false
+	case []interface{}:
+		for _, item := range list {
+			if match(item) {
+				return true
+			}
+		}
+		return false
+	}
+	items := reflect.ValueOf(value)
+	if items.Kind() != reflect.Slice {
+		return false
+	}
+	for i := 0; i < items.Len(); i++ {
+		if match(items.Index(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterEqual compares metadata and filter values, numbers by value whatever
+// their types
+func filterEqual(a, b interface{}) bool {
+	if x, ok := filterNumber(a); ok {
+		y, ok := filterNumber(b)
+		return ok && x == y
+	}
+	switch a.(type) {
+	case string, bool, nil:
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// filterCompare orders two numbers or two strings
+func filterCompare(a, b interface{}) (int, bool) {
+	if x, ok := filterNumber(a); ok {
+		y, ok := filterNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	y, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+// filterIn reports whether value is one of the values of list
+func filterIn(value, list interface{}) bool {
+	if list == nil {
+		return false
+	}
+	items := reflect.ValueOf(list)
+	if items.Kind() != reflect.Slice {
+		return false
+	}
+	for i := 0; i < items.Len(); i++ {
+		if filterEqual(value, items.Index(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterContains reports whether a string holds a substring, or a list an
+// element
+func filterContains(value, want interface{}) bool {
+	if s, ok := value.(string); ok {
+		sub, ok := want.(string)
+		return ok && strings.Contains(s, sub)
+	}
+	return filterIn(want, value)
+}
+
+// filterNumber converts the numeric types found in metadata to float64
+func filterNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
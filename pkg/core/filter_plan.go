@@ -0,0 +1,298 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Searches evaluate the clauses of a filter in the order that settles each
+// vector soonest: the clauses of an "and" by rising cost over the share of
+// vectors they reject, and those of an "or" by rising cost over the share
+// they accept. Shares are measured on a sample of the collection's metadata,
+// and costs are fixed per operator. Clauses with the same rank keep the
+// order they were written in.
+
+// filterSampleSize is the number of vectors whose metadata a filter plan is
+// measured on
+const filterSampleSize = 256
+
+// FilterClausePlan describes one clause of a planned filter
+type FilterClausePlan struct {
+	Clause      string  `json:"clause"`
+	Cost        float64 `json:"cost"`        // Relative cost of evaluating the clause once
+	Selectivity float64 `json:"selectivity"` // Share of the sampled vectors the clause accepts
+}
+
+// SearchExplain describes how a search was run, returned when it asks to
+// explain itself
+type SearchExplain struct {
+	FilterOrder []FilterClausePlan `json:"filter_order,omitempty"` // Top-level filter clauses, in the order they are evaluated
+
+	// How an HNSW search applied its filter, pre or post, and the share of
+	// vectors it expected to pass
+	FilterStrategy       string   `json:"filter_strategy,omitempty"`
+	EstimatedSelectivity *float64 `json:"estimated_selectivity,omitempty"`
+}
+
+// plannedClause is a clause of a filter with its measured rank
+type plannedClause struct {
+	filter      Filter
+	cost        float64
+	selectivity float64
+}
+
+// planSearch returns req with its filter clauses put in evaluation order,
+// and the explanation of the order when req asks for one
+func (c *VittoriaCollection) planSearch(req *SearchRequest) (*SearchRequest, *SearchExplain) {
+	if req.Filter == nil {
+		if req.Explain {
+			return req, &SearchExplain{}
+		}
+		return req, nil
+	}
+	if filterClauseCount(req.Filter) < 2 && !req.Explain {
+		return req, nil
+	}
+
+	sample := c.filterSample()
+	clauses := planClauses(filterClauses(req.Filter), sample, true)
+
+	planned := *req
+	planned.filterPlan = &Filter{And: make([]Filter, len(clauses))}
+	for i, clause := range clauses {
+		planned.filterPlan.And[i] = clause.filter
+	}
+	if !req.Explain {
+		return &planned, nil
+	}
+
+	explain := &SearchExplain{FilterOrder: make([]FilterClausePlan, len(clauses))}
+	for i, clause := range clauses {
+		explain.FilterOrder[i] = FilterClausePlan{
+			Clause:      describeFilter(&clause.filter),
+			Cost:        clause.cost,
+			Selectivity: clause.selectivity,
+		}
+	}
+	return &planned, explain
+}
+
+// searchFilter returns the filter a search evaluates: the planned one when
+// the search has been planned
+func (req *SearchRequest) searchFilter() *Filter {
+	if req.filterPlan != nil {
+		return req.filterPlan
+	}
+	return req.Filter
+}
+
+// filterSample returns the metadata of up to filterSampleSize vectors
+func (c *VittoriaCollection) filterSample() []map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sample := make([]map[string]interface{}, 0, min(len(c.vectors), filterSampleSize))
+	for _, vector := range c.vectors {
+		if len(sample) == filterSampleSize {
+			break
+		}
+		sample = append(sample, vector.Metadata)
+	}
+	return sample
+}
+
+// filterClauses splits a filter into the clauses that must all hold, in the
+// order matchFilter evaluates them
+func filterClauses(filter *Filter) []Filter {
+	clauses := append([]Filter(nil), filter.And...)
+	if len(filter.Or) > 0 {
+		clauses = append(clauses, Filter{Or: filter.Or})
+	}
+	if filter.Not != nil {
+		clauses = append(clauses, Filter{Not: filter.Not})
+	}
+	if filter.Field != "" {
+		clauses = append(clauses, Filter{Field: filter.Field, Operator: filter.Operator, Value: filter.Value})
+	}
+	return clauses
+}
+
+// filterClauseCount returns the number of clauses of a filter, counting
+// those of its groups
+func filterClauseCount(filter *Filter) int {
+	if filter == nil {
+		return 0
+	}
+	count := filterClauseCount(filter.Not)
+	for i := range filter.And {
+		count += filterClauseCount(&filter.And[i])
+	}
+	for i := range filter.Or {
+		count += filterClauseCount(&filter.Or[i])
+	}
+	if filter.Field != "" {
+		count++
+	}
+	return count
+}
+
+// planClauses measures clauses on sample and orders them, those of an "and"
+// when all is set and those of an "or" otherwise; the groups within them are
+// ordered too
+func planClauses(clauses []Filter, sample []map[string]interface{}, all bool) []plannedClause {
+	planned := make([]plannedClause, len(clauses))
+	for i, clause := range clauses {
+		clause = planGroups(clause, sample)
+		planned[i] = plannedClause{
+			filter:      clause,
+			cost:        filterCost(&clause),
+			selectivity: filterSelectivity(&clause, sample),
+		}
+	}
+
+	rank := func(clause plannedClause) float64 {
+		// The share of vectors the clause settles the group for
+		settled := 1 - clause.selectivity
+		if !all {
+			settled = clause.selectivity
+		}
+		if settled == 0 {
+			return math.Inf(1)
+		}
+		return clause.cost / settled
+	}
+	sort.SliceStable(planned, func(i, j int) bool {
+		return rank(planned[i]) < rank(planned[j])
+	})
+	return planned
+}
+
+// planGroups orders the clauses of the groups of a filter
+func planGroups(filter Filter, sample []map[string]interface{}) Filter {
+	if len(filter.And) > 1 {
+		filter.And = plannedFilters(planClauses(filter.And, sample, true))
+	}
+	if len(filter.Or) > 1 {
+		filter.Or = plannedFilters(planClauses(filter.Or, sample, false))
+	}
+	if filter.Not != nil {
+		not := planGroups(*filter.Not, sample)
+		filter.Not = &not
+	}
+	return filter
+}
+
+// plannedFilters returns the filters of planned clauses
+func plannedFilters(planned []plannedClause) []Filter {
+	filters := make([]Filter, len(planned))
+	for i, clause := range planned {
+		filters[i] = clause.filter
+	}
+	return filters
+}
+
+// filterSelectivity returns the share of sample a filter accepts, 1 for an
+// empty sample
+func filterSelectivity(filter *Filter, sample []map[string]interface{}) float64 {
+	if len(sample) == 0 {
+		return 1
+	}
+	matched := 0
+	for _, metadata := range sample {
+		if matchFilter(metadata, filter) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(sample))
+}
+
+// filterCost returns the relative cost of evaluating a filter once: the sum
+// of the costs of its clauses
+func filterCost(filter *Filter) float64 {
+	if filter == nil {
+		return 0
+	}
+	cost := filterCost(filter.Not)
+	for i := range filter.And {
+		cost += filterCost(&filter.And[i])
+	}
+	for i := range filter.Or {
+		cost += filterCost(&filter.Or[i])
+	}
+	if filter.Field == "" {
+		return cost
+	}
+
+	switch filter.Operator {
+	case FilterOpExists:
+		cost += 1
+	case FilterOpEq, FilterOpNe, "":
+		cost += 2
+	case FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+		cost += 3
+	case FilterOpContains, FilterOpGeoRadius:
+		cost += 5
+	case FilterOpGeoBBox:
+		cost += 4
+	case FilterOpIn, FilterOpNotIn:
+		cost += 2
+		if filter.Value != nil && reflect.TypeOf(filter.Value).Kind() == reflect.Slice {
+			cost += float64(reflect.ValueOf(filter.Value).Len())
+		}
+	}
+	return cost
+}
+
+// describeFilter renders a filter as text such as
+// category eq "news" and (year gte 2020 or featured exists true)
+func describeFilter(filter *Filter) string {
+	var parts []string
+	for i := range filter.And {
+		parts = append(parts, describeGroup(&filter.And[i]))
+	}
+	if len(filter.Or) > 0 {
+		alternatives := make([]string, len(filter.Or))
+		for i := range filter.Or {
+			alternatives[i] = describeGroup(&filter.Or[i])
+		}
+		parts = append(parts, "("+strings.Join(alternatives, " or ")+")")
+	}
+	if filter.Not != nil {
+		parts = append(parts, "not "+describeGroup(filter.Not))
+	}
+	if filter.Field != "" {
+		operator := filter.Operator
+		if operator == "" {
+			operator = FilterOpEq
+		}
+		value, _ := json.Marshal(filter.Value)
+		parts = append(parts, fmt.Sprintf("%s %s %s", filter.Field, operator, value))
+	}
+	if len(parts) == 0 {
+		return "true"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// describeGroup is describeFilter, in parentheses when the filter joins
+// several parts with "and"
+func describeGroup(filter *Filter) string {
+	parts := len(filter.And)
+	if len(filter.Or) > 0 {
+		parts++
+	}
+	if filter.Not != nil {
+		parts++
+	}
+	if filter.Field != "" {
+		parts++
+	}
+	if parts > 1 {
+		return "(" + describeFilter(filter) + ")"
+	}
+	return describeFilter(filter)
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFilterPlanOrdersClauses(t *testing.T) {
+	ctx := context.Background()
+	collection, err := NewCollection("plan", 2, DistanceMetricCosine, IndexTypeFlat, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	vectors := make([]*Vector, 200)
+	for i := range vectors {
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%03d", i), Vector: []float32{1, float32(i)}, Metadata: map[string]interface{}{
+			"common": true,
+			"rare":   i%50 == 0,
+			"tags":   []interface{}{"a", "b"},
+		}}
+	}
+	if err := collection.InsertBatch(ctx, vectors); err != nil {
+		t.Fatalf("Failed to insert batch: %v", err)
+	}
+
+	filter := &Filter{And: []Filter{
+		{Field: "tags", Operator: FilterOpContains, Value: "a"},
+		{Field: "common", Operator: FilterOpEq, Value: true},
+		{Field: "rare", Operator: FilterOpEq, Value: true},
+	}}
+	response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 10, Filter: filter, Explain: true})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if response.Total != 4 || len(response.Results) != 4 {
+		t.Fatalf("expected 4 matches, got %d results of %d", len(response.Results), response.Total)
+	}
+
+	// The clause rejecting most vectors goes first; the others reject none,
+	// so they keep their written order
+	want := []string{`rare eq true`, `tags contains "a"`, `common eq true`}
+	if response.Explain == nil || len(response.Explain.FilterOrder) != len(want) {
+		t.Fatalf("explain = %+v, want %d clauses", response.Explain, len(want))
+	}
+	for i, clause := range response.Explain.FilterOrder {
+		if clause.Clause != want[i] {
+			t.Errorf("clause %d = %q, want %q", i, clause.Clause, want[i])
+		}
+	}
+	if selectivity := response.Explain.FilterOrder[0].Selectivity; selectivity != 0.02 {
+		t.Errorf("selectivity of rare = %v, want 0.02", selectivity)
+	}
+
+	// Searches that do not ask get no explanation
+	response, err = collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 10, Filter: filter})
+	if err != nil || response.Explain != nil {
+		t.Errorf("search without explain: explain %+v, err %v", response.Explain, err)
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// HNSW searches apply their filter, namespace and expiry in one of two ways.
+// Post-filtering asks the graph for more candidates than requested, in
+// proportion to the share of vectors expected to pass, drops those that do
+// not, and widens while too few survive. Pre-filtering first collects the
+// vectors that pass, through a range index when one applies, and has the
+// graph return only those; when there are few enough, it scores them
+// directly instead. The share is estimated on a sample of the collection,
+// and searches expected to keep less than prefilterMaxSelectivity of it
+// pre-filter. search_params.filter_strategy forces either.
+
+// Filter strategies of HNSW searches, set in search_params.filter_strategy
+const (
+	FilterStrategyAuto = "auto" // Chosen from the estimated selectivity
+	FilterStrategyPre  = "pre"
+	FilterStrategyPost = "post"
+)
+
+const (
+	// Estimated share of passing vectors below which searches pre-filter
+	prefilterMaxSelectivity = 0.1
+
+	// Most passing vectors a pre-filtered search scores directly rather
+	// than through the graph
+	prefilterExactMax = 1000
+
+	// Candidates a post-filtered search asks for beyond those it expects
+	// to pass, as a multiple of them
+	postfilterOversampling = 1.5
+)
+
+// filterStrategy returns the filter strategy a search asks for
+func filterStrategy(req *SearchRequest) (string, error) {
+	value, exists := req.SearchParams["filter_strategy"]
+	if !exists {
+		return FilterStrategyAuto, nil
+	}
+	strategy, _ := value.(string)
+	switch strategy {
+	case FilterStrategyAuto, FilterStrategyPre, FilterStrategyPost:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("invalid filter_strategy %v: use %s, %s or %s", value, FilterStrategyAuto, FilterStrategyPre, FilterStrategyPost)
+}
+
+// estimateSelectivity returns the share of up to filterSampleSize vectors
+// the search accepts by namespace, expiry and filter; the caller holds mu
+func (c *VittoriaCollection) estimateSelectivity(req *SearchRequest, now time.Time) float64 {
+	filter := req.searchFilter()
+	sampled, passed := 0, 0
+	for _, vector := range c.vectors {
+		if sampled == filterSampleSize {
+			break
+		}
+		sampled++
+		if searchable(vector, req, now) && c.matchesFilter(vector.Metadata, filter) {
+			passed++
+		}
+	}
+	if sampled == 0 {
+		return 1
+	}
+	return float64(passed) / float64(sampled)
+}
+
+// prefilter returns the vectors the search accepts by namespace, expiry and
+// filter; the caller holds mu
+func (c *VittoriaCollection) prefilter(req *SearchRequest, now time.Time) []*Vector {
+	filter := req.searchFilter()
+	var passed []*Vector
+	c.scanVectors(filter, func(vector *Vector) bool {
+		if searchable(vector, req, now) && c.matchesFilter(vector.Metadata, filter) {
+			passed = append(passed, vector)
+		}
+		return true
+	})
+	return passed
+}
+
+// postfilterFetch returns how many candidates a post-filtered search asks
+// the graph for first, to find k results when a share selectivity of the
+// vectors passes
+func postfilterFetch(k int, selectivity float64, size int) int {
+	if selectivity <= 0 || selectivity >= 1 {
+		return k
+	}
+	fetch := math.Ceil(float64(k) / selectivity * postfilterOversampling)
+	return max(k, min(int(fetch), size))
+}
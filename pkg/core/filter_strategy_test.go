@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestFilterStrategies(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	for _, req := range []*CreateCollectionRequest{
+		{Name: "graph", Dimensions: 8, IndexType: IndexTypeHNSW},
+		{Name: "flat", Dimensions: 8, IndexType: IndexTypeFlat},
+	} {
+		if err := db.CreateCollection(ctx, req); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+	}
+	graph, _ := db.GetCollection(ctx, "graph")
+	flat, _ := db.GetCollection(ctx, "flat")
+
+	rng := rand.New(rand.NewSource(11))
+	vectors := make([]*Vector, 3000)
+	for i := range vectors {
+		vector := make([]float32, 8)
+		for j := range vector {
+			vector[j] = rng.Float32()
+		}
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%d", i), Vector: vector, Metadata: map[string]interface{}{"group": float64(i % 100)}}
+	}
+	for _, collection := range []Collection{graph, flat} {
+		if err := collection.InsertBatch(ctx, vectors); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+	}
+
+	search := func(collection Collection, filter *Filter, strategy string) *SearchResponse {
+		req := &SearchRequest{Vector: vectors[0].Vector, Limit: 10, Filter: filter, Explain: true}
+		if strategy != "" {
+			req.SearchParams = map[string]interface{}{"filter_strategy": strategy}
+		}
+		response, err := collection.Search(ctx, req)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return response
+	}
+	ids := func(response *SearchResponse) []string {
+		var ids []string
+		for _, result := range response.Results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+
+	// A selective filter pre-filters, and scores its few vectors exactly
+	selective := &Filter{Field: "group", Operator: FilterOpEq, Value: 7}
+	response := search(graph, selective, "")
+	if response.Explain.FilterStrategy != FilterStrategyPre {
+		t.Errorf("selective filter used %q", response.Explain.FilterStrategy)
+	}
+	if got, want := ids(response), ids(search(flat, selective, "")); !reflect.DeepEqual(got, want) {
+		t.Errorf("pre-filtered results %v, want %v", got, want)
+	}
+
+	// A repeated search, answered from the cache, still explains how the
+	// index applied the filter
+	cached := search(graph, selective, "")
+	if cached.Explain.FilterStrategy != FilterStrategyPre || cached.Explain.EstimatedSelectivity == nil ||
+		*cached.Explain.EstimatedSelectivity != *response.Explain.EstimatedSelectivity {
+		t.Errorf("cached explain %+v, want %+v", cached.Explain, response.Explain)
+	}
+	if stats := db.searchCacheStats(); stats == nil || stats.Hits == 0 {
+		t.Errorf("repeated search missed the cache: %+v", stats)
+	}
+
+	// A broad filter post-filters, and a forced pre-filter goes through the
+	// graph; both return only matching vectors
+	broad := &Filter{Field: "group", Operator: FilterOpLt, Value: 50}
+	for _, strategy := range []string{"", FilterStrategyPre} {
+		response := search(graph, broad, strategy)
+		want := FilterStrategyPost
+		if strategy != "" {
+			want = strategy
+		}
+		if response.Explain.FilterStrategy != want {
+			t.Errorf("filter_strategy %q used %q", strategy, response.Explain.FilterStrategy)
+		}
+		if len(response.Results) != 10 {
+			t.Fatalf("filter_strategy %q returned %d results", strategy, len(response.Results))
+		}
+		for _, result := range response.Results {
+			var group int
+			fmt.Sscanf(result.ID, "v%d", &group)
+			if group%100 >= 50 {
+				t.Errorf("filter_strategy %q returned %s", strategy, result.ID)
+			}
+		}
+	}
+
+	if _, err := graph.Search(ctx, &SearchRequest{Vector: vectors[0].Vector, Limit: 10, SearchParams: map[string]interface{}{"filter_strategy": "sideways"}}); err == nil {
+		t.Error("an unknown filter_strategy was accepted")
+	}
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestNestedFieldFilters(t *testing.T) {
+	metadata := map[string]interface{}{
+		"author":    map[string]interface{}{"name": "Dr. Sarah Chen", "h_index": 42.0},
+		"tags":      []interface{}{"ai", "ml"},
+		"reviewers": []interface{}{map[string]interface{}{"name": "Ann"}, map[string]interface{}{"name": "Bob"}},
+		"v1.2":      "flat key with a dot",
+	}
+	for _, tc := range []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{Field: "author.name", Operator: FilterOpEq, Value: "Dr. Sarah Chen"}, true},
+		{Filter{Field: "author.h_index", Operator: FilterOpGte, Value: 40}, true},
+		{Filter{Field: "author.email", Operator: FilterOpExists, Value: false}, true},
+		{Filter{Field: "author.name.first", Operator: FilterOpExists}, false},
+		{Filter{Field: "tags", Operator: FilterOpEq, Value: "ml"}, true},
+		{Filter{Field: "tags", Operator: FilterOpEq, Value: []interface{}{"ai", "ml"}}, true},
+		{Filter{Field: "tags", Operator: FilterOpNe, Value: "ai"}, false},
+		{Filter{Field: "tags", Operator: FilterOpIn, Value: []interface{}{"nlp", "ai"}}, true},
+		{Filter{Field: "tags", Operator: FilterOpNotIn, Value: []interface{}{"nlp"}}, true},
+		{Filter{Field: "reviewers.name", Operator: FilterOpEq, Value: "Bob"}, true},
+		{Filter{Field: "reviewers.name", Operator: FilterOpContains, Value: "Ann"}, true},
+		{Filter{Field: "v1.2", Operator: FilterOpEq, Value: "flat key with a dot"}, true},
+	} {
+		if got := matchFilter(metadata, &tc.filter); got != tc.want {
+			t.Errorf("%s = %v, want %v", describeFilter(&tc.filter), got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"math"
+	"sync"
+)
+
+// Collections created with float16 quantization keep each vector in memory
+// as IEEE 754 half-precision components: half the memory of float32, with
+// about three significant decimal digits, which embeddings rarely use more
+// of. Components are widened to float32 as they are scored, through a table
+// of every half-precision value. Unlike int8 quantization, nothing is kept
+// in full precision: the rounded vector is what the collection stores and
+// returns.
+
+// QuantizationFloat16 keeps two bytes per vector component
+const QuantizationFloat16 = "float16"
+
+// float16Table maps every half-precision value to its float32 value
+var float16Table = sync.OnceValue(func() *[1 << 16]float32 {
+	table := new([1 << 16]float32)
+	for h := range table {
+		table[h] = float16ToFloat32(uint16(h))
+	}
+	return table
+})
+
+// float32ToFloat16 rounds a float32 to the nearest half-precision value,
+// ties to even; values past the largest half become infinities
+func float32ToFloat16(value float32) uint16 {
+	bits := math.Float32bits(value)
+	sign := uint16(bits>>16) & 0x8000
+	exponent := int32(bits>>23) & 0xff
+	mantissa := bits & 0x7fffff
+
+	switch {
+	case exponent == 0xff: // Infinity or NaN
+		if mantissa != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exponent-127 > 15: // Too large
+		return sign | 0x7c00
+	case exponent-127 >= -14: // Normal
+		half := uint32(exponent-127+15)<<10 | mantissa>>13
+		// Rounding may carry into the exponent, up to infinity
+		return sign | uint16(roundHalfEven(half, mantissa, 13))
+	case exponent-127 >= -25: // Subnormal
+		mantissa |= 0x800000
+		shift := uint32(-(exponent - 127) - 14 + 13)
+		return sign | uint16(roundHalfEven(mantissa>>shift, mantissa, shift))
+	default: // Too small
+		return sign
+	}
+}
+
+// roundHalfEven rounds truncated, the bits of value above its low dropped
+// bits, to the nearest value, ties to even
+func roundHalfEven(truncated, value, dropped uint32) uint32 {
+	remainder := value & (1<<dropped - 1)
+	halfway := uint32(1) << (dropped - 1)
+	if remainder > halfway || remainder == halfway && truncated&1 == 1 {
+		truncated++
+	}
+	return truncated
+}
+
+// float16ToFloat32 returns the float32 value of a half-precision value
+func float16ToFloat32(half uint16) float32 {
+	sign := uint32(half&0x8000) << 16
+	exponent := uint32(half>>10) & 0x1f
+	mantissa := uint32(half & 0x3ff)
+
+	switch {
+	case exponent == 0x1f: // Infinity or NaN
+		return math.Float32frombits(sign | 0x7f800000 | mantissa<<13)
+	case exponent != 0: // Normal
+		return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+	case mantissa == 0:
+		return math.Float32frombits(sign)
+	default: // Subnormal: mantissa * 2^-24
+		value := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			return -value
+		}
+		return value
+	}
+}
+
+// encodeFloat16 returns the half-precision components of a vector
+func encodeFloat16(vector []float32) []uint16 {
+	halves := make([]uint16, len(vector))
+	for d, value := range vector {
+		halves[d] = float32ToFloat16(value)
+	}
+	return halves
+}
+
+// decodeFloat16 returns the float32 components of a half-precision vector
+func decodeFloat16(halves []uint16) []float32 {
+	table := float16Table()
+	vector := make([]float32, len(halves))
+	for d, half := range halves {
+		vector[d] = table[half]
+	}
+	return vector
+}
+
+// halfPrecision reports whether the collection keeps its vectors in
+// half precision
+func (c *VittoriaCollection) halfPrecision() bool {
+	return c.quantization != nil && c.quantization.Type == QuantizationFloat16
+}
+
+// halfScore returns the similarity of a half-precision vector to the query,
+// widening its components as they are read
+func (s *queryScorer) halfScore(vector *Vector) float32 {
+	if len(s.query) != len(vector.halves) {
+		return 0
+	}
+	table := float16Table()
+	query := s.query
+	switch s.c.metric {
+	case DistanceMetricCosine, DistanceMetricDotProduct:
+		var dot float32
+		for d, half := range vector.halves {
+			dot += query[d] * table[half]
+		}
+		if s.c.metric == DistanceMetricDotProduct {
+			return dot
+		}
+		return s.cosine(vector, dot)
+	case DistanceMetricEuclidean:
+		var sum float32
+		for d, half := range vector.halves {
+			diff := query[d] - table[half]
+			sum += diff * diff
+		}
+		return 1.0 / (1.0 + float32(math.Sqrt(float64(sum))))
+	case DistanceMetricManhattan:
+		var sum float32
+		for d, half := range vector.halves {
+			sum += float32(math.Abs(float64(query[d] - table[half])))
+		}
+		return 1.0 / (1.0 + sum)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFloat16Conversion(t *testing.T) {
+	cases := []struct {
+		value float32
+		half  uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.1, 0x2e66},
+		{65504, 0x7bff},                     // Largest half
+		{65520, 0x7c00},                     // Rounds up to infinity
+		{float32(math.Pow(2, -24)), 0x0001}, // Smallest subnormal
+		{float32(math.Pow(2, -26)), 0x0000}, // Below half the smallest subnormal
+		{1 + 1.0/2048, 0x3c00},              // Tie, to even
+		{1 + 3.0/2048, 0x3c02},              // Tie, to even
+	}
+	for _, c := range cases {
+		if got := float32ToFloat16(c.value); got != c.half {
+			t.Errorf("float32ToFloat16(%v) = %#04x, want %#04x", c.value, got, c.half)
+		}
+	}
+	// Every finite half converts back to itself
+	for h := range 1 << 16 {
+		if h&0x7c00 == 0x7c00 {
+			continue
+		}
+		if got := float32ToFloat16(float16ToFloat32(uint16(h))); got != uint16(h) {
+			t.Fatalf("round trip of %#04x gave %#04x", h, got)
+		}
+	}
+}
+
+func TestFloat16Collection(t *testing.T) {
+	dataDir := t.TempDir()
+	ctx := context.Background()
+	db := openTestDatabase(t, &Config{DataDir: dataDir})
+
+	quantization := &QuantizationConfig{Type: QuantizationFloat16}
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "hnsw", Dimensions: 8, IndexType: IndexTypeHNSW, Quantization: quantization}); err == nil {
+		t.Errorf("float16 quantization was accepted with an HNSW index")
+	}
+	for _, name := range []string{"half", "full"} {
+		req := &CreateCollectionRequest{Name: name, Dimensions: 64, IndexType: IndexTypeFlat}
+		if name == "half" {
+			req.Quantization = quantization
+		}
+		if err := db.CreateCollection(ctx, req); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+	}
+	half, _ := db.GetCollection(ctx, "half")
+	full, _ := db.GetCollection(ctx, "full")
+
+	rng := rand.New(rand.NewSource(7))
+	vectors := make([]*Vector, 300)
+	for i := range vectors {
+		vector := make([]float32, 64)
+		for d := range vector {
+			vector[d] = rng.Float32()*2 - 1
+		}
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%d", i), Vector: vector}
+	}
+	half.InsertBatch(ctx, vectors)
+	full.InsertBatch(ctx, vectors)
+	query := vectors[42].Vector
+
+	check := func(half Collection) {
+		t.Helper()
+		stored, err := half.Get(ctx, "v7")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		for d, value := range stored.Vector {
+			if want := vectors[7].Vector[d]; math.Abs(float64(value-want)) > 1e-3 {
+				t.Fatalf("component %d is %v, want %v within half precision", d, value, want)
+			}
+		}
+
+		approximate, _ := half.Search(ctx, &SearchRequest{Vector: query, Limit: 10})
+		exact, _ := full.Search(ctx, &SearchRequest{Vector: query, Limit: 10})
+		for i := range exact.Results {
+			if approximate.Results[i].ID != exact.Results[i].ID || math.Abs(float64(approximate.Results[i].Score-exact.Results[i].Score)) > 1e-3 {
+				t.Errorf("result %d: float16 %+v, float32 %+v", i, approximate.Results[i], exact.Results[i])
+			}
+		}
+	}
+	check(half)
+	if halfMemory, fullMemory := half.(*VittoriaCollection).MemoryEstimate(), full.(*VittoriaCollection).MemoryEstimate(); halfMemory >= fullMemory {
+		t.Errorf("float16 collection estimated at %d bytes, float32 at %d", halfMemory, fullMemory)
+	}
+
+	// The rounded vectors are saved, and stored in half precision again on reopen
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db = openTestDatabase(t, &Config{DataDir: dataDir})
+	half, _ = db.GetCollection(ctx, "half")
+	full, _ = db.GetCollection(ctx, "full")
+	if stored := half.(*VittoriaCollection).vectors["v7"]; stored.halves == nil || stored.Vector != nil {
+		t.Fatalf("reopened vector is not in half precision: %+v", stored)
+	}
+	check(half)
+}
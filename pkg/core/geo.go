@@ -0,0 +1,201 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// A geo point is a metadata object {"lat": 45.46, "lon": 9.19} in degrees;
+// "lng" is accepted for "lon". The geo_radius filter operator keeps the
+// vectors with a point within a distance of a center, and geo_bbox those
+// with a point within a box of latitudes and longitudes. Like the other
+// operators they apply to dot paths, and a list of points matches when one
+// of them does, so a record can carry several locations.
+
+// earthRadius is the mean radius of the Earth in meters
+const earthRadius = 6371008.8
+
+// GeoPoint is a location in degrees, stored in metadata as
+// {"lat": ..., "lon": ...}
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// GeoRadius is the value of a geo_radius condition: the points within
+// Radius meters of a center
+type GeoRadius struct {
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Radius float64 `json:"radius"`
+}
+
+// GeoBBox is the value of a geo_bbox condition: the points within latitudes
+// and longitudes. A box whose MinLon is past its MaxLon crosses the 180th
+// meridian.
+type GeoBBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+// geoPoint reads a geo point from a metadata value
+func geoPoint(value interface{}) (GeoPoint, bool) {
+	switch v := value.(type) {
+	case GeoPoint:
+		return v, true
+	case *GeoPoint:
+		if v == nil {
+			return GeoPoint{}, false
+		}
+		return *v, true
+	case map[string]interface{}:
+		lat, ok := filterNumber(v["lat"])
+		if !ok {
+			return GeoPoint{}, false
+		}
+		lon, ok := filterNumber(v["lon"])
+		if !ok {
+			if lon, ok = filterNumber(v["lng"]); !ok {
+				return GeoPoint{}, false
+			}
+		}
+		return GeoPoint{Lat: lat, Lon: lon}, true
+	}
+	return GeoPoint{}, false
+}
+
+// geoRadius reads the value of a geo_radius condition
+func geoRadius(value interface{}) (GeoRadius, bool) {
+	switch v := value.(type) {
+	case GeoRadius:
+		return v, true
+	case *GeoRadius:
+		if v == nil {
+			return GeoRadius{}, false
+		}
+		return *v, true
+	case map[string]interface{}:
+		center, ok := geoPoint(v)
+		if !ok {
+			return GeoRadius{}, false
+		}
+		radius, ok := filterNumber(v["radius"])
+		if !ok {
+			return GeoRadius{}, false
+		}
+		return GeoRadius{Lat: center.Lat, Lon: center.Lon, Radius: radius}, true
+	}
+	return GeoRadius{}, false
+}
+
+// geoBBox reads the value of a geo_bbox condition
+func geoBBox(value interface{}) (GeoBBox, bool) {
+	switch v := value.(type) {
+	case GeoBBox:
+		return v, true
+	case *GeoBBox:
+		if v == nil {
+			return GeoBBox{}, false
+		}
+		return *v, true
+	case map[string]interface{}:
+		var box GeoBBox
+		for key, bound := range map[string]*float64{
+			"min_lat": &box.MinLat, "min_lon": &box.MinLon,
+			"max_lat": &box.MaxLat, "max_lon": &box.MaxLon,
+		} {
+			number, ok := filterNumber(v[key])
+			if !ok {
+				return GeoBBox{}, false
+			}
+			*bound = number
+		}
+		return box, true
+	}
+	return GeoBBox{}, false
+}
+
+// validateGeoFilter checks the value of a geo_radius or geo_bbox condition
+func validateGeoFilter(filter *Filter) error {
+	switch filter.Operator {
+	case FilterOpGeoRadius:
+		radius, ok := geoRadius(filter.Value)
+		if !ok {
+			return fmt.Errorf("filter operator '%s' on field '%s' needs {\"lat\", \"lon\", \"radius\"}", filter.Operator, filter.Field)
+		}
+		if err := validateGeoPoint(radius.Lat, radius.Lon); err != nil {
+			return fmt.Errorf("filter operator '%s' on field '%s': %w", filter.Operator, filter.Field, err)
+		}
+		if radius.Radius <= 0 {
+			return fmt.Errorf("filter operator '%s' on field '%s': radius must be positive", filter.Operator, filter.Field)
+		}
+	case FilterOpGeoBBox:
+		box, ok := geoBBox(filter.Value)
+		if !ok {
+			return fmt.Errorf("filter operator '%s' on field '%s' needs {\"min_lat\", \"min_lon\", \"max_lat\", \"max_lon\"}", filter.Operator, filter.Field)
+		}
+		for _, corner := range []GeoPoint{{box.MinLat, box.MinLon}, {box.MaxLat, box.MaxLon}} {
+			if err := validateGeoPoint(corner.Lat, corner.Lon); err != nil {
+				return fmt.Errorf("filter operator '%s' on field '%s': %w", filter.Operator, filter.Field, err)
+			}
+		}
+		if box.MinLat > box.MaxLat {
+			return fmt.Errorf("filter operator '%s' on field '%s': min_lat is past max_lat", filter.Operator, filter.Field)
+		}
+	}
+	return nil
+}
+
+// validateGeoPoint checks that a latitude and longitude are in range
+func validateGeoPoint(lat, lon float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return fmt.Errorf("latitude %g is out of range", lat)
+	}
+	if math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return fmt.Errorf("longitude %g is out of range", lon)
+	}
+	return nil
+}
+
+// matchGeoRadius reports whether a metadata value is a point within the
+// radius of a geo_radius condition
+func matchGeoRadius(value, condition interface{}) bool {
+	point, ok := geoPoint(value)
+	if !ok {
+		return false
+	}
+	radius, ok := geoRadius(condition)
+	return ok && geoDistance(point, GeoPoint{Lat: radius.Lat, Lon: radius.Lon}) <= radius.Radius
+}
+
+// matchGeoBBox reports whether a metadata value is a point within the box
+// of a geo_bbox condition
+func matchGeoBBox(value, condition interface{}) bool {
+	point, ok := geoPoint(value)
+	if !ok {
+		return false
+	}
+	box, ok := geoBBox(condition)
+	if !ok || point.Lat < box.MinLat || point.Lat > box.MaxLat {
+		return false
+	}
+	if box.MinLon <= box.MaxLon {
+		return point.Lon >= box.MinLon && point.Lon <= box.MaxLon
+	}
+	return point.Lon >= box.MinLon || point.Lon <= box.MaxLon
+}
+
+// geoDistance returns the great-circle distance between two points in
+// meters, by the haversine formula
+func geoDistance(a, b GeoPoint) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(min(h, 1)))
+}
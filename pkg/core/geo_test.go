@@ -0,0 +1,45 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestGeoFilters(t *testing.T) {
+	milan := map[string]interface{}{"lat": 45.4642, "lon": 9.19}
+	metadata := map[string]interface{}{
+		"location": milan,
+		"branches": []interface{}{map[string]interface{}{"lat": 41.9028, "lng": 12.4964}, GeoPoint{Lat: -17.7134, Lon: 178.065}},
+	}
+	for _, tc := range []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{Field: "location", Operator: FilterOpGeoRadius, Value: map[string]interface{}{"lat": 45.4654, "lon": 9.1859, "radius": 1000.0}}, true},
+		{Filter{Field: "location", Operator: FilterOpGeoRadius, Value: GeoRadius{Lat: 45.0703, Lon: 7.6869, Radius: 100000}}, false},
+		{Filter{Field: "location", Operator: FilterOpGeoRadius, Value: GeoRadius{Lat: 45.0703, Lon: 7.6869, Radius: 130000}}, true},
+		{Filter{Field: "location", Operator: FilterOpGeoBBox, Value: GeoBBox{MinLat: 45, MinLon: 9, MaxLat: 46, MaxLon: 10}}, true},
+		{Filter{Field: "location", Operator: FilterOpGeoBBox, Value: GeoBBox{MinLat: 40, MinLon: 10, MaxLat: 46, MaxLon: 20}}, false},
+		{Filter{Field: "branches", Operator: FilterOpGeoRadius, Value: GeoRadius{Lat: 41.9, Lon: 12.5, Radius: 1000}}, true},
+		{Filter{Field: "branches", Operator: FilterOpGeoBBox, Value: GeoBBox{MinLat: -20, MinLon: 170, MaxLat: -10, MaxLon: -170}}, true},
+		{Filter{Field: "missing", Operator: FilterOpGeoRadius, Value: GeoRadius{Lat: 0, Lon: 0, Radius: 1e9}}, false},
+	} {
+		if err := ValidateFilter(&tc.filter); err != nil {
+			t.Fatalf("%s: %v", describeFilter(&tc.filter), err)
+		}
+		if got := matchFilter(metadata, &tc.filter); got != tc.want {
+			t.Errorf("%s = %v, want %v", describeFilter(&tc.filter), got, tc.want)
+		}
+	}
+
+	for _, invalid := range []Filter{
+		{Field: "location", Operator: FilterOpGeoRadius, Value: map[string]interface{}{"lat": 45.0, "lon": 9.0}},
+		{Field: "location", Operator: FilterOpGeoRadius, Value: GeoRadius{Lat: 95, Lon: 9, Radius: 10}},
+		{Field: "location", Operator: FilterOpGeoRadius, Value: GeoRadius{Lat: 45, Lon: 9, Radius: 0}},
+		{Field: "location", Operator: FilterOpGeoBBox, Value: GeoBBox{MinLat: 46, MinLon: 9, MaxLat: 45, MaxLon: 10}},
+		{Field: "location", Operator: FilterOpGeoBBox, Value: "45,9,46,10"},
+	} {
+		if err := ValidateFilter(&invalid); err == nil {
+			t.Errorf("%s was accepted", describeFilter(&invalid))
+		}
+	}
+}
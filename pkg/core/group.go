@@ -0,0 +1,513 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// groupsFile records the collection groups of a data directory
+const groupsFile = "groups.json"
+
+// groupSearchOversample is how many more candidates than requested each
+// field contributes to a group search, so records ranked lower in one field
+// can still be fused
+const groupSearchOversample = 4
+
+// rrfRank dampens the reciprocal ranks of the "rrf" fusion
+const rrfRank = 60
+
+// groupNamePattern restricts group and field names, which make up the names
+// of the member collections
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// GroupField is a vector field of a collection group, stored in a collection
+// of its own
+type GroupField struct {
+	Name             string                       `json:"name"`
+	Dimensions       int                          `json:"dimensions"`
+	Metric           DistanceMetric               `json:"metric"`
+	IndexType        IndexType                    `json:"index_type"`
+	VectorizerConfig *embeddings.VectorizerConfig `json:"vectorizer_config,omitempty"`
+	Weight           float32                      `json:"weight,omitempty"` // Weight in group searches (default 1)
+}
+
+// CreateGroupRequest represents a collection group creation request
+type CreateGroupRequest struct {
+	Name   string       `json:"name"`
+	Fields []GroupField `json:"fields"`
+}
+
+// CollectionGroup is a set of collections, one per vector field of the same
+// records, created, dropped, backed up and searched together
+type CollectionGroup struct {
+	Name    string       `json:"name"`
+	Fields  []GroupField `json:"fields"`
+	Created time.Time    `json:"created"`
+}
+
+// GroupMemberName returns the name of the collection holding a group's field
+func GroupMemberName(group, field string) string {
+	return group + "." + field
+}
+
+// Field returns the group's field named name
+func (g *CollectionGroup) Field(name string) (*GroupField, bool) {
+	for i := range g.Fields {
+		if g.Fields[i].Name == name {
+			return &g.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// GroupFieldInfo describes a field of a collection group
+type GroupFieldInfo struct {
+	Name        string                       `json:"name"`
+	Collection  string                       `json:"collection"`
+	Dimensions  int                          `json:"dimensions"`
+	Metric      DistanceMetric               `json:"metric"`
+	IndexType   IndexType                    `json:"index_type"`
+	Weight      float32                      `json:"weight"`
+	VectorCount int64                        `json:"vector_count"`
+	Vectorizer  *embeddings.VectorizerConfig `json:"vectorizer,omitempty"`
+}
+
+// GroupInfo describes a collection group
+type GroupInfo struct {
+	Name    string            `json:"name"`
+	Fields  []*GroupFieldInfo `json:"fields"`
+	Created time.Time         `json:"created"`
+}
+
+// GroupFieldQuery is a group search's query on one field: a vector, or a
+// text for fields with a vectorizer
+type GroupFieldQuery struct {
+	Vector []float32 `json:"vector,omitempty"`
+	Text   string    `json:"text,omitempty"`
+	Weight *float32  `json:"weight,omitempty"` // Overrides the field's weight
+}
+
+// GroupSearchRequest searches several fields of a group at once
+type GroupSearchRequest struct {
+	Queries         map[string]*GroupFieldQuery `json:"queries"`
+	Limit           int                         `json:"limit"`
+	Filter          *Filter                     `json:"filter,omitempty"`
+	Namespace       string                      `json:"namespace,omitempty"`
+	IncludeMetadata bool                        `json:"include_metadata"`
+	Fusion          string                      `json:"fusion,omitempty"` // "weighted" (default) sums weighted scores, "rrf" weighted reciprocal ranks
+}
+
+// GroupSearchResult is a record found by a group search
+type GroupSearchResult struct {
+	ID          string                 `json:"id"`
+	Score       float32                `json:"score"`
+	FieldScores map[string]float32     `json:"field_scores"` // Score in each field the record was found in
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// GroupSearchResponse represents group search results
+type GroupSearchResponse struct {
+	Results []*GroupSearchResult `json:"results"`
+	Total   int64                `json:"total"`
+	TookMS  int64                `json:"took_ms"`
+}
+
+// CreateGroup creates a collection group and a collection for each of its
+// fields. Nothing is left behind when one of them fails to be created.
+func (db *VittoriaDB) CreateGroup(ctx context.Context, req *CreateGroupRequest) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.writable(); err != nil {
+		return err
+	}
+	if _, exists := db.groups[req.Name]; exists {
+		return errorf(ErrAlreadyExists, "group '%s' already exists", req.Name)
+	}
+	if err := validateCreateGroupRequest(req); err != nil {
+		return err
+	}
+
+	group := &CollectionGroup{Name: req.Name, Created: time.Now()}
+	for _, field := range req.Fields {
+		member := &CreateCollectionRequest{
+			Name:             GroupMemberName(req.Name, field.Name),
+			Dimensions:       field.Dimensions,
+			Metric:           field.Metric,
+			IndexType:        field.IndexType,
+			VectorizerConfig: field.VectorizerConfig,
+			Internal:         true,
+		}
+		if err := db.createCollection(ctx, member); err != nil {
+			db.removeMembers(ctx, group)
+			return fmt.Errorf("failed to create field '%s': %w", field.Name, err)
+		}
+		db.collections[member.Name].group = req.Name
+
+		// Keep the inferred dimensions, and no API keys
+		field.Dimensions = member.Dimensions
+		if field.VectorizerConfig != nil {
+			field.VectorizerConfig = field.VectorizerConfig.WithoutSecrets()
+		}
+		group.Fields = append(group.Fields, field)
+	}
+
+	db.groups[req.Name] = group
+	if err := db.saveGroups(); err != nil {
+		delete(db.groups, req.Name)
+		db.removeMembers(ctx, group)
+		return err
+	}
+	return nil
+}
+
+// removeMembers deletes the collections of a group that failed to be
+// created; the caller holds mu
+func (db *VittoriaDB) removeMembers(ctx context.Context, group *CollectionGroup) {
+	for _, field := range group.Fields {
+		if err := db.dropCollection(ctx, GroupMemberName(group.Name, field.Name), 0); err != nil {
+			fmt.Printf("Error removing collection of group %s: %v\n", group.Name, err)
+		}
+	}
+}
+
+// DropGroup drops a collection group and its collections, which go to the
+// trash like dropped collections
+func (db *VittoriaDB) DropGroup(ctx context.Context, name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.writable(); err != nil {
+		return err
+	}
+	group, exists := db.groups[name]
+	if !exists {
+		return errorf(ErrNotFound, "group '%s' not found", name)
+	}
+
+	for _, field := range group.Fields {
+		member := GroupMemberName(name, field.Name)
+		if _, exists := db.collections[member]; !exists {
+			continue
+		}
+		if err := db.dropCollection(ctx, member, db.config.Storage.TrashRetention); err != nil {
+			return fmt.Errorf("failed to drop field '%s': %w", field.Name, err)
+		}
+	}
+
+	delete(db.groups, name)
+	return db.saveGroups()
+}
+
+// GetGroup returns a collection group
+func (db *VittoriaDB) GetGroup(ctx context.Context, name string) (*GroupInfo, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, errorf(ErrClosed, "database is closed")
+	}
+	group, exists := db.groups[name]
+	if !exists {
+		return nil, errorf(ErrNotFound, "group '%s' not found", name)
+	}
+	return db.groupInfo(group), nil
+}
+
+// ListGroups returns the collection groups, sorted by name
+func (db *VittoriaDB) ListGroups(ctx context.Context) ([]*GroupInfo, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, errorf(ErrClosed, "database is closed")
+	}
+
+	groups := make([]*GroupInfo, 0, len(db.groups))
+	for _, group := range db.groups {
+		groups = append(groups, db.groupInfo(group))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, nil
+}
+
+// groupInfo describes a group with the counts of its collections; the
+// caller holds mu
+func (db *VittoriaDB) groupInfo(group *CollectionGroup) *GroupInfo {
+	info := &GroupInfo{Name: group.Name, Created: group.Created}
+	for _, field := range group.Fields {
+		fieldInfo := &GroupFieldInfo{
+			Name:       field.Name,
+			Collection: GroupMemberName(group.Name, field.Name),
+			Dimensions: field.Dimensions,
+			Metric:     field.Metric,
+			IndexType:  field.IndexType,
+			Weight:     fieldWeight(&field),
+			Vectorizer: field.VectorizerConfig,
+		}
+		if collection, exists := db.collections[fieldInfo.Collection]; exists {
+			fieldInfo.VectorCount, _ = collection.Count()
+		}
+		info.Fields = append(info.Fields, fieldInfo)
+	}
+	return info
+}
+
+// groupMembers returns the collections of a group's fields, by field name
+func (db *VittoriaDB) groupMembers(name string) (*CollectionGroup, map[string]*VittoriaCollection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, nil, errorf(ErrClosed, "database is closed")
+	}
+	group, exists := db.groups[name]
+	if !exists {
+		return nil, nil, errorf(ErrNotFound, "group '%s' not found", name)
+	}
+
+	members := make(map[string]*VittoriaCollection, len(group.Fields))
+	for _, field := range group.Fields {
+		collection, exists := db.collections[GroupMemberName(name, field.Name)]
+		if !exists {
+			return nil, nil, fmt.Errorf("collection of field '%s' of group '%s' is missing", field.Name, name)
+		}
+		members[field.Name] = collection
+	}
+	return group, members, nil
+}
+
+// SearchGroup searches the fields of a group named in the request and fuses
+// the results by record ID, so that a record matching several fields ranks
+// above one matching a single field
+func (db *VittoriaDB) SearchGroup(ctx context.Context, name string, req *GroupSearchRequest) (*GroupSearchResponse, error) {
+	start := time.Now()
+
+	group, members, err := db.groupMembers(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Queries) == 0 {
+		return nil, fmt.Errorf("at least one field query is required")
+	}
+	switch req.Fusion {
+	case "", "weighted", "rrf":
+	default:
+		return nil, fmt.Errorf("invalid fusion '%s': use weighted or rrf", req.Fusion)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	results := make(map[string]*GroupSearchResult)
+	for fieldName, query := range req.Queries {
+		field, ok := group.Field(fieldName)
+		if !ok {
+			return nil, fmt.Errorf("group '%s' has no field '%s'", name, fieldName)
+		}
+		collection := members[fieldName]
+
+		vector := query.Vector
+		if len(vector) == 0 {
+			if query.Text == "" {
+				return nil, fmt.Errorf("query on field '%s' needs a vector or a text", fieldName)
+			}
+			if !collection.HasVectorizer() {
+				return nil, fmt.Errorf("field '%s' has no vectorizer for text queries", fieldName)
+			}
+			if vector, err = collection.GetVectorizer().GenerateEmbedding(ctx, query.Text); err != nil {
+				return nil, fmt.Errorf("failed to generate query embedding for field '%s': %w", fieldName, err)
+			}
+		}
+
+		weight := fieldWeight(field)
+		if query.Weight != nil {
+			weight = *query.Weight
+		}
+
+		response, err := collection.Search(ctx, &SearchRequest{
+			Vector:          vector,
+			Limit:           limit * groupSearchOversample,
+			Filter:          req.Filter,
+			IncludeMetadata: req.IncludeMetadata,
+			Namespace:       req.Namespace,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("search on field '%s' failed: %w", fieldName, err)
+		}
+
+		for rank, hit := range response.Results {
+			result, exists := results[hit.ID]
+			if !exists {
+				result = &GroupSearchResult{ID: hit.ID, FieldScores: make(map[string]float32), Metadata: hit.Metadata}
+				results[hit.ID] = result
+			}
+			result.FieldScores[fieldName] = hit.Score
+			if req.Fusion == "rrf" {
+				result.Score += weight / float32(rrfRank+rank+1)
+			} else {
+				result.Score += weight * hit.Score
+			}
+		}
+	}
+
+	fused := make([]*GroupSearchResult, 0, len(results))
+	for _, result := range results {
+		fused = append(fused, result)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].ID < fused[j].ID
+	})
+
+	total := int64(len(fused))
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return &GroupSearchResponse{
+		Results: fused,
+		Total:   total,
+		TookMS:  time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// BackupGroup writes a gzip-compressed tar archive of a group's collections
+// and its definition to w, in the layout of Backup
+func (db *VittoriaDB) BackupGroup(ctx context.Context, name string, w io.Writer) error {
+	group, members, err := db.groupMembers(name)
+	if err != nil {
+		return err
+	}
+
+	collections := make([]*VittoriaCollection, 0, len(members))
+	for _, field := range group.Fields {
+		collections = append(collections, members[field.Name])
+	}
+	return backupCollections(ctx, w, collections, []*CollectionGroup{group})
+}
+
+// groupsCovering returns the groups whose collections are all among
+// collections, for backing them up with their definitions
+func (db *VittoriaDB) groupsCovering(collections []*VittoriaCollection) []*CollectionGroup {
+	included := make(map[string]bool, len(collections))
+	for _, collection := range collections {
+		included[collection.Name()] = true
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var groups []*CollectionGroup
+	for _, group := range db.groups {
+		covered := true
+		for _, field := range group.Fields {
+			if !included[GroupMemberName(group.Name, field.Name)] {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// loadGroups reads the groups file of the data directory; the caller holds mu
+func (db *VittoriaDB) loadGroups() error {
+	data, err := os.ReadFile(filepath.Join(db.dataDir, groupsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var groups []*CollectionGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return fmt.Errorf("invalid %s: %w", groupsFile, err)
+	}
+	for _, group := range groups {
+		db.groups[group.Name] = group
+		for _, field := range group.Fields {
+			if collection, exists := db.collections[GroupMemberName(group.Name, field.Name)]; exists {
+				collection.group = group.Name
+			}
+		}
+	}
+	return nil
+}
+
+// saveGroups writes the groups file of the data directory; the caller holds mu
+func (db *VittoriaDB) saveGroups() error {
+	groups := make([]*CollectionGroup, 0, len(db.groups))
+	for _, group := range db.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(db.dataDir, groupsFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", groupsFile, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", groupsFile, err)
+	}
+	return nil
+}
+
+// validateCreateGroupRequest validates the group creation request
+func validateCreateGroupRequest(req *CreateGroupRequest) error {
+	if !groupNamePattern.MatchString(req.Name) {
+		return fmt.Errorf("group name must be made of letters, digits, '_' and '-'")
+	}
+	if len(req.Fields) == 0 {
+		return fmt.Errorf("group needs at least one field")
+	}
+
+	seen := make(map[string]bool, len(req.Fields))
+	for _, field := range req.Fields {
+		if !groupNamePattern.MatchString(field.Name) {
+			return fmt.Errorf("field name '%s' must be made of letters, digits, '_' and '-'", field.Name)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("duplicate field '%s'", field.Name)
+		}
+		seen[field.Name] = true
+		if field.Weight < 0 {
+			return fmt.Errorf("weight of field '%s' cannot be negative", field.Name)
+		}
+	}
+	return nil
+}
+
+// fieldWeight returns the weight of a field in group searches
+func fieldWeight(field *GroupField) float32 {
+	if field.Weight == 0 {
+		return 1
+	}
+	return field.Weight
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestCollectionGroup_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	config := &Config{DataDir: t.TempDir()}
+
+	db := openTestDatabase(t, config)
+
+	err := db.CreateGroup(ctx, &CreateGroupRequest{Name: "articles", Fields: []GroupField{
+		{Name: "title", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat},
+		{Name: "body", Dimensions: 3, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat, Weight: 2},
+	}})
+	if err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+
+	title, _ := db.GetCollection(ctx, GroupMemberName("articles", "title"))
+	body, _ := db.GetCollection(ctx, GroupMemberName("articles", "body"))
+	title.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 0}})
+	title.Insert(ctx, &Vector{ID: "b", Vector: []float32{0.9, 0.1}})
+	body.Insert(ctx, &Vector{ID: "b", Vector: []float32{1, 0, 0}})
+	body.Insert(ctx, &Vector{ID: "a", Vector: []float32{0, 1, 0}})
+
+	// b is the best match of the heavier field
+	results, err := db.SearchGroup(ctx, "articles", &GroupSearchRequest{
+		Queries: map[string]*GroupFieldQuery{
+			"title": {Vector: []float32{1, 0}},
+			"body":  {Vector: []float32{1, 0, 0}},
+		},
+		Limit: 2,
+	})
+	if err != nil {
+		t.Fatalf("SearchGroup failed: %v", err)
+	}
+	if len(results.Results) != 2 || results.Results[0].ID != "b" || len(results.Results[0].FieldScores) != 2 {
+		t.Fatalf("unexpected fused results: %+v", results.Results)
+	}
+
+	// Members are listed with the group only, and dropped with it
+	collections, _ := db.ListCollections(ctx)
+	if len(collections) != 0 {
+		t.Errorf("group members should not be listed as collections: %d listed", len(collections))
+	}
+	if err := db.DropCollection(ctx, GroupMemberName("articles", "title")); err == nil {
+		t.Error("dropping a group member directly should fail")
+	}
+
+	var backup bytes.Buffer
+	if err := db.BackupGroup(ctx, "articles", &backup); err != nil {
+		t.Fatalf("BackupGroup failed: %v", err)
+	}
+	if backup.Len() == 0 {
+		t.Error("empty group backup")
+	}
+
+	// Groups survive a restart
+	db.Close()
+	db = openTestDatabase(t, config)
+	group, err := db.GetGroup(ctx, "articles")
+	if err != nil {
+		t.Fatalf("GetGroup after reopen failed: %v", err)
+	}
+	if len(group.Fields) != 2 || group.Fields[1].VectorCount != 2 || group.Fields[1].Weight != 2 {
+		t.Errorf("unexpected group after reopen: %+v", group.Fields[1])
+	}
+
+	if err := db.DropGroup(ctx, "articles"); err != nil {
+		t.Fatalf("DropGroup failed: %v", err)
+	}
+	if _, err := db.GetCollection(ctx, GroupMemberName("articles", "body")); err == nil {
+		t.Error("group members should be dropped with the group")
+	}
+}
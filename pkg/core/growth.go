@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// growthFile is the file of the data directory holding the daily size
+// history of the collections
+const growthFile = "growth.json"
+
+// Collection sizes are sampled every growthSampleInterval; a sample replaces
+// the one taken earlier the same day, and growthRetention days are kept
+const (
+	growthSampleInterval = time.Hour
+	growthRetention      = 365
+)
+
+// growthTrendDays is how many days of history the growth trend spans
+const growthTrendDays = 30
+
+// GrowthSample is the size of a collection on a day, as last sampled that day
+type GrowthSample struct {
+	Date        string `json:"date"` // UTC, as YYYY-MM-DD
+	Vectors     int64  `json:"vectors"`
+	DiskBytes   int64  `json:"disk_bytes"`
+	MemoryBytes int64  `json:"memory_bytes"` // Vectors and index, as reported by the index stats
+}
+
+// GrowthTrend is the average daily growth of a collection over its recent
+// history
+type GrowthTrend struct {
+	Days              int     `json:"days"` // Days between the first and last samples used
+	VectorsPerDay     float64 `json:"vectors_per_day"`
+	DiskBytesPerDay   float64 `json:"disk_bytes_per_day"`
+	MemoryBytesPerDay float64 `json:"memory_bytes_per_day"`
+}
+
+// CollectionGrowth is the size history of a collection, for capacity
+// planning. With a memory limit configured, it also projects when the
+// database as a whole reaches it if every collection keeps its trend.
+type CollectionGrowth struct {
+	Collection           string         `json:"collection"`
+	Samples              []GrowthSample `json:"samples"`
+	Trend                *GrowthTrend   `json:"trend,omitempty"` // Needs samples on two days
+	MemoryLimit          int64          `json:"memory_limit,omitempty"`
+	DatabaseMemoryBytes  int64          `json:"database_memory_bytes,omitempty"`
+	DaysUntilMemoryLimit *float64       `json:"days_until_memory_limit,omitempty"` // Omitted when memory is not growing
+}
+
+// loadGrowth reads the growth history of the data directory
+func (db *VittoriaDB) loadGrowth() error {
+	db.growth = make(map[string][]GrowthSample)
+	data, err := os.ReadFile(filepath.Join(db.dataDir, growthFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &db.growth)
+}
+
+// runGrowthSampler samples the size of every collection now and then every
+// interval, until stop is closed
+func (db *VittoriaDB) runGrowthSampler(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := db.sampleGrowth(time.Now()); err != nil {
+			fmt.Printf("Error recording collection growth: %v\n", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sampleGrowth records the current size of every collection as its sample
+// for the day and saves the history. Collections that no longer exist lose
+// their history.
+func (db *VittoriaDB) sampleGrowth(now time.Time) error {
+	// Held until the history is saved, so that no write lands after Close
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.closed {
+		return nil
+	}
+	collections := make([]*VittoriaCollection, 0, len(db.collections))
+	for _, collection := range db.collections {
+		collections = append(collections, collection)
+	}
+
+	date := now.UTC().Format(time.DateOnly)
+	samples := make(map[string]*GrowthSample, len(collections))
+	for _, collection := range collections {
+		// A collection that cannot be counted, such as one closing, keeps
+		// its history without a sample
+		samples[collection.Name()] = nil
+		count, err := collection.Count()
+		if err != nil {
+			continue
+		}
+		sample := &GrowthSample{Date: date, Vectors: count, DiskBytes: dirSize(collection.dataDir)}
+		if stats, err := collection.IndexStats(); err == nil {
+			sample.MemoryBytes = stats.MemoryUsage
+		}
+		samples[collection.Name()] = sample
+	}
+
+	db.growthMu.Lock()
+	defer db.growthMu.Unlock()
+
+	history := make(map[string][]GrowthSample, len(samples))
+	for name, sample := range samples {
+		days := db.growth[name]
+		// Keep the history as is for a clock set back
+		if sample == nil || (len(days) > 0 && days[len(days)-1].Date > date) {
+			if len(days) > 0 {
+				history[name] = days
+			}
+			continue
+		}
+		if len(days) > 0 && days[len(days)-1].Date == date {
+			days = days[:len(days)-1]
+		}
+		days = append(days, *sample)
+		if len(days) > growthRetention {
+			days = days[len(days)-growthRetention:]
+		}
+		history[name] = days
+	}
+	db.growth = history
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(db.dataDir, growthFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// CollectionGrowth returns the size history of a collection and its trend
+func (db *VittoriaDB) CollectionGrowth(ctx context.Context, name string) (*CollectionGrowth, error) {
+	if _, err := db.GetCollection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	db.growthMu.Lock()
+	defer db.growthMu.Unlock()
+
+	growth := &CollectionGrowth{
+		Collection: name,
+		Samples:    append([]GrowthSample{}, db.growth[name]...),
+		Trend:      growthTrend(db.growth[name]),
+	}
+
+	if limit := db.memoryLimit(); limit > 0 {
+		growth.MemoryLimit = limit
+		var rate float64
+		for _, days := range db.growth {
+			if len(days) == 0 {
+				continue
+			}
+			growth.DatabaseMemoryBytes += days[len(days)-1].MemoryBytes
+			if trend := growthTrend(days); trend != nil {
+				rate += trend.MemoryBytesPerDay
+			}
+		}
+		if rate > 0 {
+			remaining := max(float64(growth.MemoryLimit-growth.DatabaseMemoryBytes), 0)
+			days := remaining / rate
+			growth.DaysUntilMemoryLimit = &days
+		}
+	}
+	return growth, nil
+}
+
+// growthTrend returns the average daily growth over the last growthTrendDays
+// of samples, or nil when they do not span two days
+func growthTrend(samples []GrowthSample) *GrowthTrend {
+	if len(samples) < 2 {
+		return nil
+	}
+	last := samples[len(samples)-1]
+	lastDate, err := time.Parse(time.DateOnly, last.Date)
+	if err != nil {
+		return nil
+	}
+
+	// The oldest sample within the window
+	cutoff := lastDate.AddDate(0, 0, -growthTrendDays).Format(time.DateOnly)
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Date >= cutoff })
+	first := samples[i]
+	firstDate, err := time.Parse(time.DateOnly, first.Date)
+	if err != nil {
+		return nil
+	}
+	days := int(lastDate.Sub(firstDate).Hours() / 24)
+	if days == 0 {
+		return nil
+	}
+
+	return &GrowthTrend{
+		Days:              days,
+		VectorsPerDay:     float64(last.Vectors-first.Vectors) / float64(days),
+		DiskBytesPerDay:   float64(last.DiskBytes-first.DiskBytes) / float64(days),
+		MemoryBytesPerDay: float64(last.MemoryBytes-first.MemoryBytes) / float64(days),
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectionGrowth(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	config := &Config{DataDir: dataDir, Performance: PerfConfig{MemoryLimit: 1 << 30}}
+	db := openTestDatabase(t, config)
+	err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+
+	insert := func(from, to int) {
+		for i := from; i < to; i++ {
+			collection.Insert(ctx, &Vector{ID: fmt.Sprintf("v%d", i), Vector: []float32{1, float32(i)}})
+		}
+	}
+	// Days ahead of today, so the background sampler cannot interleave
+	day := time.Now().AddDate(0, 0, 1)
+	insert(0, 10)
+	if err := db.sampleGrowth(day); err != nil {
+		t.Fatalf("sampleGrowth failed: %v", err)
+	}
+	insert(10, 15)
+	db.sampleGrowth(day.AddDate(0, 0, 2))
+	insert(15, 30)
+	db.sampleGrowth(day.AddDate(0, 0, 2).Add(time.Minute)) // Replaces the sample of the same day
+	db.Close()
+
+	db = openTestDatabase(t, config)
+
+	growth, err := db.CollectionGrowth(ctx, "docs")
+	if err != nil {
+		t.Fatalf("CollectionGrowth failed: %v", err)
+	}
+	if len(growth.Samples) != 2 || growth.Samples[0].Vectors != 10 || growth.Samples[1].Vectors != 30 {
+		t.Fatalf("unexpected samples %+v", growth.Samples)
+	}
+	if growth.Samples[1].DiskBytes == 0 || growth.Samples[1].MemoryBytes <= growth.Samples[0].MemoryBytes {
+		t.Errorf("expected disk and growing memory usage, got %+v", growth.Samples)
+	}
+	if growth.Trend == nil || growth.Trend.Days != 2 || growth.Trend.VectorsPerDay != 10 {
+		t.Errorf("trend = %+v; want 10 vectors per day over 2 days", growth.Trend)
+	}
+	if growth.DaysUntilMemoryLimit == nil || *growth.DaysUntilMemoryLimit <= 0 {
+		t.Errorf("expected a forecast of the memory limit, got %v", growth.DaysUntilMemoryLimit)
+	}
+
+	if _, err := db.CollectionGrowth(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
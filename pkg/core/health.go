@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// Component states reported by readiness checks
+const (
+	ComponentOK       = "ok"
+	ComponentDegraded = "degraded" // Usable with reduced functionality
+	ComponentFailed   = "failed"
+	ComponentDisabled = "disabled"
+)
+
+// vectorizerPingTimeout bounds how long a readiness check waits for an
+// embedding service
+const vectorizerPingTimeout = 2 * time.Second
+
+// healthProbeFileName is written and removed to check the data directory
+const healthProbeFileName = ".health-probe"
+
+// ComponentHealth is the result of checking one component
+type ComponentHealth struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Message   string  `json:"message,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// CheckComponents checks the components the database depends on: that the
+// data directory is writable, that every collection's index is loaded and
+// that the embedding services used by vectorizers are reachable
+func (db *VittoriaDB) CheckComponents(ctx context.Context) []*ComponentHealth {
+	db.mu.RLock()
+	dataDir := db.dataDir
+	collections := make([]*VittoriaCollection, 0, len(db.collections))
+	for _, collection := range db.collections {
+		collections = append(collections, collection)
+	}
+	db.mu.RUnlock()
+
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].name < collections[j].name
+	})
+
+	return []*ComponentHealth{
+		timeCheck("storage", func() (string, string) { return checkStorage(dataDir) }),
+		timeCheck("index", func() (string, string) { return checkIndexes(collections) }),
+		timeCheck("vectorizer", func() (string, string) { return checkVectorizers(ctx, collections) }),
+	}
+}
+
+// timeCheck runs check and records how long it took
+func timeCheck(name string, check func() (string, string)) *ComponentHealth {
+	start := time.Now()
+	status, message := check()
+	return &ComponentHealth{
+		Name:      name,
+		Status:    status,
+		Message:   message,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+}
+
+// checkStorage writes, syncs and removes a probe file in the data directory
+func checkStorage(dataDir string) (string, string) {
+	if dataDir == "" {
+		return ComponentFailed, "database is not open"
+	}
+
+	path := filepath.Join(dataDir, healthProbeFileName)
+	f, err := os.Create(path)
+	if err != nil {
+		return ComponentFailed, fmt.Sprintf("data directory is not writable: %v", err)
+	}
+	_, err = f.WriteString("ok")
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	os.Remove(path)
+	if err != nil {
+		return ComponentFailed, fmt.Sprintf("failed to write to data directory: %v", err)
+	}
+	return ComponentOK, ""
+}
+
+// checkIndexes reports collections whose index is unusable (failed) or out
+// of step with the stored vectors (degraded)
+func checkIndexes(collections []*VittoriaCollection) (string, string) {
+	var failed, degraded []string
+	for _, collection := range collections {
+		status, message := collection.indexHealth()
+		switch status {
+		case ComponentFailed:
+			failed = append(failed, message)
+		case ComponentDegraded:
+			degraded = append(degraded, message)
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		return ComponentFailed, strings.Join(append(failed, degraded...), "; ")
+	case len(degraded) > 0:
+		return ComponentDegraded, strings.Join(degraded, "; ")
+	}
+	return ComponentOK, fmt.Sprintf("%d collections loaded", len(collections))
+}
+
+// indexHealth checks the collection's index, or its local shards' indexes
+func (c *VittoriaCollection) indexHealth() (string, string) {
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		status, messages := ComponentOK, []string(nil)
+		for _, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				// Remote shards are checked by the node that owns them
+				continue
+			}
+			shardStatus, message := local.indexHealth()
+			if shardStatus == ComponentOK {
+				continue
+			}
+			if status != ComponentFailed {
+				status = shardStatus
+			}
+			messages = append(messages, message)
+		}
+		return status, strings.Join(messages, "; ")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	switch {
+	case c.closed:
+		return ComponentFailed, fmt.Sprintf("collection '%s' is closed", c.name)
+	case c.indexType != IndexTypeHNSW || c.bulkLoading:
+		// Flat collections have no index and bulk loads build theirs at the end
+		return ComponentOK, ""
+	case c.index == nil:
+		return ComponentFailed, fmt.Sprintf("collection '%s' has no index loaded", c.name)
+	case c.index.Size() != len(c.vectors):
+		return ComponentDegraded, fmt.Sprintf("collection '%s' index holds %d of %d vectors", c.name, c.index.Size(), len(c.vectors))
+	}
+	return ComponentOK, ""
+}
+
+// checkVectorizers pings the embedding services behind the collections'
+// vectorizers. An unreachable service only degrades the database: vector
+// operations keep working, text operations on those collections fail.
+func checkVectorizers(ctx context.Context, collections []*VittoriaCollection) (string, string) {
+	ctx, cancel := context.WithTimeout(ctx, vectorizerPingTimeout)
+	defer cancel()
+
+	checked := 0
+	var unreachable []string
+	for _, collection := range collections {
+		pinger, ok := collection.GetVectorizer().(embeddings.Pinger)
+		if !ok {
+			continue
+		}
+		checked++
+		if err := pinger.Ping(ctx); err != nil {
+			unreachable = append(unreachable, fmt.Sprintf("collection '%s': %v", collection.name, err))
+		}
+	}
+
+	switch {
+	case len(unreachable) > 0:
+		return ComponentDegraded, strings.Join(unreachable, "; ")
+	case checked == 0:
+		return ComponentDisabled, "no collection uses a remote embedding service"
+	}
+	return ComponentOK, fmt.Sprintf("%d embedding services reachable", checked)
+}
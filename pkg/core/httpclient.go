@@ -0,0 +1,36 @@
+package core
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connection pool sizes of NewHTTPClient. The default transport keeps two
+// idle connections per server, so bursts of small requests to one server
+// keep opening new ones.
+const (
+	clientMaxIdleConns        = 256
+	clientMaxIdleConnsPerHost = 64
+	clientIdleConnTimeout     = 90 * time.Second
+)
+
+// NewHTTPClient returns a client for requests to other VittoriaDB servers,
+// such as remote shards and an edge cache's upstream. It keeps connections
+// alive for reuse, and negotiates HTTP/2 with servers reached over TLS.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          clientMaxIdleConns,
+			MaxIdleConnsPerHost:   clientMaxIdleConnsPerHost,
+			IdleConnTimeout:       clientIdleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
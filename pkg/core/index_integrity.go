@@ -0,0 +1,167 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// IndexIntegrityReport is the result of checking a collection's HNSW graph
+// against the vectors the collection stores
+type IndexIntegrityReport struct {
+	Collection   string                  `json:"collection"`
+	Graph        *index.IntegrityReport  `json:"graph,omitempty"`
+	MissingNodes int                     `json:"missing_nodes"` // Stored vectors absent from the graph
+	StaleNodes   int                     `json:"stale_nodes"`   // Graph nodes without a stored vector
+	Healthy      bool                    `json:"healthy"`
+	Repair       *IndexRepairReport      `json:"repair,omitempty"`
+	Shards       []*IndexIntegrityReport `json:"shards,omitempty"`
+}
+
+// IndexRepairReport describes what RepairIndex changed
+type IndexRepairReport struct {
+	*index.RepairReport
+	AddedNodes         int  `json:"added_nodes"`          // Stored vectors inserted into the graph
+	RebuiltFromVectors bool `json:"rebuilt_from_vectors"` // The graph held stale nodes and was rebuilt from the stored vectors
+}
+
+// CheckIndex validates the collection's HNSW graph: its structure (links,
+// layers, entry point, reachability) and that it holds exactly the stored
+// vectors. Sharded collections check every local shard.
+func (c *VittoriaCollection) CheckIndex(ctx context.Context) (*IndexIntegrityReport, error) {
+	if c.isSharded() {
+		return c.shardedIndexIntegrity(func(local *VittoriaCollection) (*IndexIntegrityReport, error) {
+			return local.CheckIndex(ctx)
+		})
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	graph, err := c.graphIndex()
+	if err != nil {
+		return nil, err
+	}
+	return c.checkIndex(graph), nil
+}
+
+// RepairIndex fixes the problems CheckIndex finds and persists the repaired
+// graph. The returned report describes the graph after the repair, with the
+// changes made in Repair.
+func (c *VittoriaCollection) RepairIndex(ctx context.Context) (*IndexIntegrityReport, error) {
+	if c.readOnly {
+		return nil, c.errReadOnly()
+	}
+	if c.isSharded() {
+		return c.shardedIndexIntegrity(func(local *VittoriaCollection) (*IndexIntegrityReport, error) {
+			return local.RepairIndex(ctx)
+		})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	graph, err := c.graphIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	before := c.checkIndex(graph)
+	repair := &IndexRepairReport{RepairReport: &index.RepairReport{}}
+
+	if before.StaleNodes > 0 {
+		// The index cannot list its nodes, so drop the stale ones by
+		// rebuilding from the stored vectors
+		if err := c.rebuildIndex(); err != nil {
+			return nil, err
+		}
+		repair.RebuiltFromVectors = true
+	} else {
+		if before.MissingNodes > 0 {
+			for key, vector := range c.vectors {
+				if !vector.hasVector() || graph.GetNode(key) != nil {
+					continue
+				}
+				if err := c.index.Add(ctx, &index.IndexVector{ID: key, Vector: vector.Vector}); err != nil {
+					return nil, fmt.Errorf("failed to add vector %s to index: %w", vector.ID, err)
+				}
+				repair.AddedNodes++
+			}
+		}
+
+		changes, err := graph.Repair()
+		if err != nil {
+			return nil, err
+		}
+		repair.RepairReport = changes
+	}
+
+	if err := c.saveIndex(); err != nil {
+		return nil, fmt.Errorf("failed to save repaired index: %w", err)
+	}
+	if c.searchEngine != nil {
+		c.searchEngine.ClearCache()
+	}
+
+	report := c.checkIndex(graph)
+	report.Repair = repair
+	return report, nil
+}
+
+// graphIndex returns the collection's HNSW index; the caller holds mu
+func (c *VittoriaCollection) graphIndex() (index.HNSWIndex, error) {
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+	if c.bulkLoading {
+		return nil, errorf(ErrConflict, "collection '%s' is bulk loading and has no index yet", c.name)
+	}
+	graph, ok := c.index.(index.HNSWIndex)
+	if !ok {
+		return nil, errorf(ErrConflict, "collection '%s' has no graph index to check (index type %s)", c.name, c.indexType.String())
+	}
+	return graph, nil
+}
+
+// checkIndex compares the graph with the stored vectors; the caller holds mu
+func (c *VittoriaCollection) checkIndex(graph index.HNSWIndex) *IndexIntegrityReport {
+	report := &IndexIntegrityReport{
+		Collection: c.name,
+		Graph:      graph.CheckIntegrity(),
+	}
+
+	for key, vector := range c.vectors {
+		if vector.hasVector() && graph.GetNode(key) == nil {
+			report.MissingNodes++
+		}
+	}
+	report.StaleNodes = report.Graph.Nodes - (c.indexedCount() - report.MissingNodes)
+	report.Healthy = report.Graph.Healthy && report.MissingNodes == 0 && report.StaleNodes == 0
+
+	return report
+}
+
+// shardedIndexIntegrity runs check on every local shard and combines the reports
+func (c *VittoriaCollection) shardedIndexIntegrity(check func(*VittoriaCollection) (*IndexIntegrityReport, error)) (*IndexIntegrityReport, error) {
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	report := &IndexIntegrityReport{Collection: c.name, Healthy: true}
+	for i, s := range c.shards {
+		local, ok := s.(*VittoriaCollection)
+		if !ok {
+			// Remote shards are checked on the node that owns them
+			continue
+		}
+		shardReport, err := check(local)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", c.shardName(i), err)
+		}
+		report.MissingNodes += shardReport.MissingNodes
+		report.StaleNodes += shardReport.StaleNodes
+		report.Healthy = report.Healthy && shardReport.Healthy
+		report.Shards = append(report.Shards, shardReport)
+	}
+	return report, nil
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// Bounds of the HNSW parameters a collection can be created with
+const (
+	maxHNSWM  = 256
+	maxHNSWEf = 10000
+)
+
+// HNSWParams are the graph parameters of an HNSW collection. Zero fields take
+// the database-wide settings, and the index defaults without those.
+type HNSWParams struct {
+	M              int `json:"m,omitempty"`               // Links per node and layer
+	EfConstruction int `json:"ef_construction,omitempty"` // Beam width of inserts
+	EfSearch       int `json:"ef_search,omitempty"`       // Beam width of searches; changes without a rebuild
+}
+
+// hnswConfigKeys are the keys of a creation request's config
+var hnswConfigKeys = []string{"m", "ef_construction", "ef_search"}
+
+// parseHNSWParams reads the HNSW parameters from the config of a collection
+// creation request, returning nil when it sets none
+func parseHNSWParams(config map[string]interface{}, indexType IndexType) (*HNSWParams, error) {
+	if len(config) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	params := &HNSWParams{}
+	for _, key := range keys {
+		value, err := positiveInt(config[key])
+		if err != nil {
+			return nil, fmt.Errorf("config.%s %w", key, err)
+		}
+		switch key {
+		case "m":
+			if value < 2 || value > maxHNSWM {
+				return nil, fmt.Errorf("config.m must be between 2 and %d, got %d", maxHNSWM, value)
+			}
+			params.M = value
+		case "ef_construction":
+			if value > maxHNSWEf {
+				return nil, fmt.Errorf("config.ef_construction cannot exceed %d", maxHNSWEf)
+			}
+			params.EfConstruction = value
+		case "ef_search":
+			if err := validateEfSearch(value); err != nil {
+				return nil, fmt.Errorf("config.%w", err)
+			}
+			params.EfSearch = value
+		default:
+			return nil, fmt.Errorf("unknown config key '%s': use %s", key, strings.Join(hnswConfigKeys, ", "))
+		}
+	}
+
+	if indexType != IndexTypeHNSW {
+		return nil, fmt.Errorf("config sets HNSW parameters, which a %s index does not have", indexType)
+	}
+	return params, nil
+}
+
+// positiveInt returns a config value as a positive integer. Decoded JSON
+// numbers are float64.
+func positiveInt(value interface{}) (int, error) {
+	var n float64
+	switch v := value.(type) {
+	case int:
+		n = float64(v)
+	case float64:
+		n = v
+	default:
+		return 0, fmt.Errorf("must be a number")
+	}
+	if n != math.Trunc(n) || n < 1 || n > math.MaxInt32 {
+		return 0, fmt.Errorf("must be a positive integer, got %v", value)
+	}
+	return int(n), nil
+}
+
+// validateEfSearch checks an ef_search value
+func validateEfSearch(ef int) error {
+	if ef < 1 || ef > maxHNSWEf {
+		return fmt.Errorf("ef_search must be between 1 and %d, got %d", maxHNSWEf, ef)
+	}
+	return nil
+}
+
+// hnswParams returns the parameters the collection's HNSW graph is built and
+// searched with: its own, the database-wide ones, then the index defaults
+func (c *VittoriaCollection) hnswParams() HNSWParams {
+	return c.hnswParamsWith(c.ownHNSWParams())
+}
+
+// hnswParamsWith is hnswParams with own in place of the collection's parameters
+func (c *VittoriaCollection) hnswParamsWith(own HNSWParams) HNSWParams {
+	defaults := index.DefaultHNSWConfig()
+	params := HNSWParams{M: defaults.M, EfConstruction: defaults.EfConstruction, EfSearch: defaults.EfSearch}
+	for _, source := range []HNSWParams{c.indexOptions.hnsw, own} {
+		if source.M > 0 {
+			params.M = source.M
+		}
+		if source.EfConstruction > 0 {
+			params.EfConstruction = source.EfConstruction
+		}
+		if source.EfSearch > 0 {
+			params.EfSearch = source.EfSearch
+		}
+	}
+	return params
+}
+
+// config returns the parameters set as the config of a creation request
+func (p *HNSWParams) config() map[string]interface{} {
+	if p == nil {
+		return nil
+	}
+	config := make(map[string]interface{})
+	for key, value := range map[string]int{"m": p.M, "ef_construction": p.EfConstruction, "ef_search": p.EfSearch} {
+		if value > 0 {
+			config[key] = value
+		}
+	}
+	return config
+}
+
+// ownHNSWParams returns the parameters set on the collection itself
+func (c *VittoriaCollection) ownHNSWParams() HNSWParams {
+	if c.hnsw == nil {
+		return HNSWParams{}
+	}
+	return *c.hnsw
+}
+
+// setIndexOptions sets the index and search settings of a new, empty
+// collection and recreates its index with them
+func (c *VittoriaCollection) setIndexOptions(options indexOptions, params *HNSWParams) error {
+	c.indexOptions = options
+	c.hnsw = params
+	c.applySearchOptions()
+	return c.initIndex()
+}
+
+// applySearchOptions hands the database-wide parallel search settings and
+// search cache to the collection's search engine
+func (c *VittoriaCollection) applySearchOptions() {
+	if c.searchEngine == nil {
+		return
+	}
+	if c.indexOptions.parallel != nil {
+		config := *c.indexOptions.parallel
+		c.searchEngine.UpdateConfig(&config)
+	}
+	if c.indexOptions.cache != nil {
+		c.searchEngine.useCache(c.indexOptions.cache)
+	}
+}
+
+// SetEfSearch changes the beam width of the collection's HNSW searches. The
+// graph is unchanged, so it applies at once, without a rebuild.
+func (c *VittoriaCollection) SetEfSearch(ctx context.Context, ef int) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+	if err := validateEfSearch(ef); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errorf(ErrClosed, "collection is closed")
+	}
+	if c.indexType != IndexTypeHNSW {
+		return fmt.Errorf("ef_search applies to HNSW collections; '%s' has a %s index", c.name, c.indexType)
+	}
+
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		for i, s := range c.shards {
+			if err := s.SetEfSearch(ctx, ef); err != nil {
+				return fmt.Errorf("shard %s: %w", c.shardName(i), err)
+			}
+		}
+	}
+
+	params := c.ownHNSWParams()
+	params.EfSearch = ef
+	c.hnsw = &params
+	if hnsw, ok := c.index.(index.HNSWIndex); ok {
+		hnsw.SetEfSearch(ef)
+	}
+
+	c.touch()
+	return c.saveMetadata()
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestHNSWParams(t *testing.T) {
+	dataDir := t.TempDir()
+	ctx := context.Background()
+	db := openTestDatabase(t, &Config{DataDir: dataDir})
+
+	for _, config := range []map[string]interface{}{
+		{"m": 1.0},
+		{"m": 8.5},
+		{"ef_search": "wide"},
+		{"efsearch": 64.0},
+	} {
+		if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "bad", Dimensions: 4, IndexType: IndexTypeHNSW, Config: config}); err == nil {
+			t.Errorf("config %v was accepted", config)
+		}
+	}
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "bad", Dimensions: 4, IndexType: IndexTypeFlat, Config: map[string]interface{}{"m": 8.0}}); err == nil {
+		t.Errorf("HNSW parameters were accepted for a flat index")
+	}
+
+	// Decoded JSON numbers are float64
+	config := map[string]interface{}{"m": 8.0, "ef_search": 20.0}
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 4, IndexType: IndexTypeHNSW, Config: config}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	vittoriaCollection := collection.(*VittoriaCollection)
+	info, _ := vittoriaCollection.Info()
+	if want := (HNSWParams{M: 8, EfConstruction: 200, EfSearch: 20}); info.HNSW == nil || *info.HNSW != want {
+		t.Fatalf("got HNSW parameters %+v, want %+v", info.HNSW, want)
+	}
+
+	for i := 0; i < 50; i++ {
+		vector := []float32{float32(i), 1, float32(i % 7), 0.5}
+		if err := collection.Insert(ctx, &Vector{ID: fmt.Sprintf("v%d", i), Vector: vector}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	ef := 128
+	if err := vittoriaCollection.Update(ctx, &UpdateCollectionRequest{EfSearch: &ef}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{3, 1, 3, 0.5}, Limit: 1}); err != nil || response.Results[0].ID != "v3" {
+		t.Fatalf("Search after changing ef_search returned %v (%v)", response, err)
+	}
+	ef = 0
+	if err := vittoriaCollection.Update(ctx, &UpdateCollectionRequest{EfSearch: &ef}); err == nil {
+		t.Errorf("ef_search 0 was accepted")
+	}
+
+	// The parameters are kept with the collection
+	db.Close()
+	db = openTestDatabase(t, &Config{DataDir: dataDir})
+	collection, _ = db.GetCollection(ctx, "docs")
+	info, _ = collection.(*VittoriaCollection).Info()
+	if want := (HNSWParams{M: 8, EfConstruction: 200, EfSearch: 128}); info.HNSW == nil || *info.HNSW != want {
+		t.Fatalf("reopened with HNSW parameters %+v, want %+v", info.HNSW, want)
+	}
+}
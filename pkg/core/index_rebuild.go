@@ -0,0 +1,412 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// Index rebuild states
+const (
+	RebuildRunning   = "running"
+	RebuildCompleted = "completed"
+	RebuildFailed    = "failed"
+)
+
+// What started an index rebuild
+const (
+	RebuildTriggerManual = "manual"
+	RebuildTriggerAuto   = "auto" // Deletes passed search.index.auto_rebuild.deleted_ratio
+)
+
+// IndexRebuildRequest asks for the collection's index to be rebuilt in the
+// background, optionally as another index type or with other HNSW parameters
+type IndexRebuildRequest struct {
+	IndexType *IndexType             `json:"index_type,omitempty"` // Default: the current type
+	Config    map[string]interface{} `json:"config,omitempty"`     // HNSW parameters of the new graph, as at creation; default: the current ones
+}
+
+// IndexRebuild reports the progress of a background index rebuild
+type IndexRebuild struct {
+	Collection string          `json:"collection"`
+	State      string          `json:"state"`   // running, completed or failed
+	Trigger    string          `json:"trigger"` // manual or auto
+	IndexType  IndexType       `json:"index_type"`
+	Total      int             `json:"total"`    // Vectors to index
+	Indexed    int             `json:"indexed"`  // Vectors linked into the new graph so far
+	Progress   float64         `json:"progress"` // From 0 to 1
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Shards     []*IndexRebuild `json:"shards,omitempty"`
+}
+
+// rebuildJob is a background index rebuild
+type rebuildJob struct {
+	status IndexRebuild // Guarded by the collection's rebuildMu
+	linked atomic.Int64
+	cancel context.CancelFunc
+}
+
+// StartIndexRebuild starts rebuilding the collection's index from the stored
+// vectors in the background and returns at once. Searches keep using the
+// current index, and writes keep updating it, until the new one replaces it;
+// writes made during the rebuild are carried over then. Sharded collections
+// rebuild each local shard, keeping their index type.
+func (c *VittoriaCollection) StartIndexRebuild(ctx context.Context, req *IndexRebuildRequest) (*IndexRebuild, error) {
+	if c.readOnly {
+		return nil, c.errReadOnly()
+	}
+	if req == nil {
+		req = &IndexRebuildRequest{}
+	}
+	if c.isSharded() {
+		return c.startShardRebuilds(ctx, req)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+	if c.bulkLoading {
+		return nil, errorf(ErrConflict, "collection '%s' is bulk loading; its index is built when the load ends", c.name)
+	}
+
+	indexType := c.indexType
+	if req.IndexType != nil {
+		indexType = *req.IndexType
+	}
+	if !slices.Contains(IndexTypes, indexType) {
+		return nil, fmt.Errorf("invalid index type %d: use one of %s", indexType, allowedValues(IndexTypes))
+	}
+	if indexType == IndexTypeHNSW && c.quantization != nil {
+		return nil, fmt.Errorf("%s quantization requires a flat index", c.quantization.Type)
+	}
+
+	params := c.hnsw
+	if req.Config != nil {
+		parsed, err := parseHNSWParams(req.Config, indexType)
+		if err != nil {
+			return nil, err
+		}
+		params = parsed
+	}
+	if indexType != IndexTypeHNSW {
+		params = nil
+	}
+
+	if err := c.startRebuild(indexType, params, RebuildTriggerManual); err != nil {
+		return nil, err
+	}
+	return c.rebuildStatus(), nil
+}
+
+// startShardRebuilds rebuilds the index of every local shard
+func (c *VittoriaCollection) startShardRebuilds(ctx context.Context, req *IndexRebuildRequest) (*IndexRebuild, error) {
+	c.mu.RLock()
+	indexType := c.indexType
+	c.mu.RUnlock()
+	if req.IndexType != nil && *req.IndexType != indexType {
+		return nil, fmt.Errorf("the shards of sharded collection '%s' cannot change index type", c.name)
+	}
+
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	for i, s := range c.shards {
+		local, ok := s.(*VittoriaCollection)
+		if !ok {
+			// Remote shards are rebuilt on the node that owns them
+			continue
+		}
+		if _, err := local.StartIndexRebuild(ctx, req); err != nil {
+			return nil, fmt.Errorf("shard %s: %w", c.shardName(i), err)
+		}
+	}
+	return c.shardRebuildStatus(), nil
+}
+
+// IndexRebuildStatus reports the collection's latest index rebuild since it
+// was opened
+func (c *VittoriaCollection) IndexRebuildStatus() (*IndexRebuild, error) {
+	var status *IndexRebuild
+	if c.isSharded() {
+		c.shardMu.RLock()
+		status = c.shardRebuildStatus()
+		c.shardMu.RUnlock()
+	} else {
+		status = c.rebuildStatus()
+	}
+	if status == nil {
+		return nil, errorf(ErrNotFound, "collection '%s' has not rebuilt its index since it was opened", c.name)
+	}
+	return status, nil
+}
+
+// rebuildStatus returns a snapshot of the latest rebuild, or nil
+func (c *VittoriaCollection) rebuildStatus() *IndexRebuild {
+	c.rebuildMu.Lock()
+	defer c.rebuildMu.Unlock()
+
+	if c.rebuild == nil {
+		return nil
+	}
+	status := c.rebuild.status
+	if status.State == RebuildRunning {
+		status.Indexed = int(c.rebuild.linked.Load())
+	}
+	status.Progress = 1
+	if status.Total > 0 {
+		status.Progress = float64(status.Indexed) / float64(status.Total)
+	}
+	return &status
+}
+
+// shardRebuildStatus combines the latest rebuilds of the local shards, or
+// returns nil when none has run; the caller holds shardMu
+func (c *VittoriaCollection) shardRebuildStatus() *IndexRebuild {
+	var combined *IndexRebuild
+	for _, s := range c.shards {
+		local, ok := s.(*VittoriaCollection)
+		if !ok {
+			continue
+		}
+		status := local.rebuildStatus()
+		if status == nil {
+			continue
+		}
+		if combined == nil {
+			combined = &IndexRebuild{Collection: c.name, State: RebuildCompleted, Trigger: status.Trigger, IndexType: status.IndexType, StartedAt: status.StartedAt}
+		}
+		combined.Total += status.Total
+		combined.Indexed += status.Indexed
+		if status.StartedAt.Before(combined.StartedAt) {
+			combined.StartedAt = status.StartedAt
+		}
+		switch {
+		case status.State == RebuildFailed:
+			combined.State = RebuildFailed
+		case status.State == RebuildRunning && combined.State != RebuildFailed:
+			combined.State = RebuildRunning
+		}
+		combined.Shards = append(combined.Shards, status)
+	}
+	if combined != nil {
+		combined.Progress = 1
+		if combined.Total > 0 {
+			combined.Progress = float64(combined.Indexed) / float64(combined.Total)
+		}
+	}
+	return combined
+}
+
+// startRebuild snapshots the stored vectors and builds an index of the given
+// type from them in the background; the caller holds mu exclusively
+func (c *VittoriaCollection) startRebuild(indexType IndexType, params *HNSWParams, trigger string) error {
+	c.rebuildMu.Lock()
+	defer c.rebuildMu.Unlock()
+
+	if c.rebuild != nil && c.rebuild.status.State == RebuildRunning {
+		return errorf(ErrConflict, "collection '%s' is already rebuilding its index", c.name)
+	}
+
+	var vectors []*index.IndexVector
+	if indexType == IndexTypeHNSW {
+		vectors = c.indexVectors()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &rebuildJob{
+		status: IndexRebuild{
+			Collection: c.name,
+			State:      RebuildRunning,
+			Trigger:    trigger,
+			IndexType:  indexType,
+			Total:      len(vectors),
+			StartedAt:  time.Now(),
+		},
+		cancel: cancel,
+	}
+	c.rebuild = job
+	c.rebuildDirty = make(map[string]bool)
+
+	go c.runRebuild(ctx, job, indexType, params, vectors)
+	return nil
+}
+
+// runRebuild builds the new index and swaps it in, recording the outcome
+func (c *VittoriaCollection) runRebuild(ctx context.Context, job *rebuildJob, indexType IndexType, params *HNSWParams, vectors []*index.IndexVector) {
+	err := c.buildAndSwap(ctx, job, indexType, params, vectors)
+	job.cancel()
+
+	c.rebuildMu.Lock()
+	defer c.rebuildMu.Unlock()
+
+	finished := time.Now()
+	job.status.FinishedAt = &finished
+	job.status.Indexed = int(job.linked.Load())
+	if err != nil {
+		job.status.State = RebuildFailed
+		job.status.Error = err.Error()
+		fmt.Printf("Index rebuild of collection %s failed: %v\n", c.name, err)
+		return
+	}
+	job.status.State = RebuildCompleted
+}
+
+// buildAndSwap builds the new index outside the collection lock, then
+// replaces the current one with it, carrying over the writes made meanwhile
+func (c *VittoriaCollection) buildAndSwap(ctx context.Context, job *rebuildJob, indexType IndexType, params *HNSWParams, vectors []*index.IndexVector) error {
+	own := HNSWParams{}
+	if params != nil {
+		own = *params
+	}
+	c.mu.RLock()
+	built, err := c.newIndex(indexType, c.hnswParamsWith(own))
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if built != nil {
+		graph, ok := built.(index.HNSWIndex)
+		if !ok {
+			return fmt.Errorf("index type %s cannot be rebuilt in the background", indexType)
+		}
+		if err := graph.BuildTracked(ctx, vectors, &job.linked); err != nil {
+			return fmt.Errorf("failed to build index: %w", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	defer func() { c.rebuildDirty = nil }()
+	if c.closed {
+		return errorf(ErrClosed, "collection is closed")
+	}
+	if c.bulkLoading {
+		return errorf(ErrConflict, "a bulk load started during the rebuild")
+	}
+
+	if built != nil && len(c.rebuildDirty) > 0 {
+		keys := make([]string, 0, len(c.rebuildDirty))
+		for key := range c.rebuildDirty {
+			keys = append(keys, key)
+		}
+		if _, err := built.DeleteBatch(ctx, keys); err != nil {
+			return fmt.Errorf("failed to carry over writes: %w", err)
+		}
+		for _, key := range keys {
+			if vector, exists := c.vectors[key]; exists && vector.hasVector() {
+				if err := built.Add(ctx, &index.IndexVector{ID: key, Vector: vector.Vector}); err != nil {
+					return fmt.Errorf("failed to carry over writes: %w", err)
+				}
+			}
+		}
+	}
+
+	c.index = built
+	c.indexType = indexType
+	c.hnsw = params
+	c.markIndexBuilt()
+
+	if built == nil {
+		if err := os.Remove(filepath.Join(c.dataDir, indexFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove index file: %w", err)
+		}
+		c.forgetChecksums(indexFileName)
+	} else if err := c.saveIndex(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	c.touch()
+	return c.saveMetadata()
+}
+
+// cancelRebuild stops a running rebuild, which then fails
+func (c *VittoriaCollection) cancelRebuild() {
+	c.rebuildMu.Lock()
+	defer c.rebuildMu.Unlock()
+
+	if c.rebuild != nil {
+		c.rebuild.cancel()
+	}
+}
+
+// trackRebuild records a write to key while a rebuild runs, for the new index
+// to carry over; the caller holds mu exclusively
+func (c *VittoriaCollection) trackRebuild(key string) {
+	if c.rebuildDirty != nil {
+		c.rebuildDirty[key] = true
+	}
+}
+
+// markIndexBuilt restarts the count of removals since the index was built;
+// the caller holds mu exclusively
+func (c *VittoriaCollection) markIndexBuilt() {
+	c.builtSize = 0
+	if c.index != nil {
+		c.builtSize = c.index.Size()
+	}
+	c.removedSinceBuild = 0
+}
+
+// countIndexRemovals counts entries removed from the index, rebuilding it in
+// the background once they pass search.index.auto_rebuild.deleted_ratio of
+// its size when built. Outside the collection's maintenance windows the
+// rebuild waits for the next one to open. The caller holds mu exclusively.
+func (c *VittoriaCollection) countIndexRemovals(n int) {
+	c.removedSinceBuild += n
+
+	auto := c.indexOptions.autoRebuild
+	if auto.DeletedRatio <= 0 || c.readOnly || c.builtSize < max(auto.MinVectors, 1) {
+		return
+	}
+	if float64(c.removedSinceBuild) < auto.DeletedRatio*float64(c.builtSize) {
+		return
+	}
+	if !c.inMaintenanceWindow(time.Now()) {
+		return
+	}
+
+	err := c.startRebuild(c.indexType, c.hnsw, RebuildTriggerAuto)
+	if errors.Is(err, ErrConflict) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("Failed to start index rebuild of collection %s: %v\n", c.name, err)
+		return
+	}
+	fmt.Printf("Rebuilding index of collection %s: %d of %d entries removed since it was built\n", c.name, c.removedSinceBuild, c.builtSize)
+	// A failed rebuild is only retried after as many removals again
+	c.removedSinceBuild = 0
+}
+
+// resumeAutoRebuild starts the automatic index rebuilds that were held back
+// until a maintenance window opened, on the collection or its local shards
+func (c *VittoriaCollection) resumeAutoRebuild() {
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+		for _, s := range c.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				local.resumeAutoRebuild()
+			}
+		}
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.countIndexRemovals(0)
+	}
+}
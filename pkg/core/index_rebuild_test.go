@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// waitForRebuild waits for the latest index rebuild of a collection to end
+func waitForRebuild(t *testing.T, collection *VittoriaCollection) *IndexRebuild {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := collection.IndexRebuildStatus()
+		if err == nil && status.State != RebuildRunning {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("index rebuild did not finish")
+	return nil
+}
+
+func TestIndexRebuild(t *testing.T) {
+	ctx := context.Background()
+	config := &Config{DataDir: t.TempDir(), Index: IndexConfig{AutoRebuild: AutoRebuildConfig{DeletedRatio: 0.5, MinVectors: 10}}}
+	db := openTestDatabase(t, config)
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 4, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	vittoriaCollection := collection.(*VittoriaCollection)
+	if _, err := vittoriaCollection.IndexRebuildStatus(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("status before any rebuild: err = %v, want ErrNotFound", err)
+	}
+	for i := 0; i < 40; i++ {
+		if err := collection.Insert(ctx, &Vector{ID: fmt.Sprintf("v%d", i), Vector: []float32{float32(i), 1, float32(i % 7), 0.5}}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// Flat to HNSW, with writes made while the graph is built carried over
+	hnsw := IndexTypeHNSW
+	if _, err := vittoriaCollection.StartIndexRebuild(ctx, &IndexRebuildRequest{IndexType: &hnsw, Config: map[string]interface{}{"m": 8.0}}); err != nil {
+		t.Fatalf("StartIndexRebuild failed: %v", err)
+	}
+	if err := collection.Insert(ctx, &Vector{ID: "late", Vector: []float32{100, 1, 2, 0.5}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := collection.Delete(ctx, "v0"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	status := waitForRebuild(t, vittoriaCollection)
+	if status.State != RebuildCompleted || status.Trigger != RebuildTriggerManual || status.Indexed != status.Total {
+		t.Fatalf("rebuild ended as %+v", status)
+	}
+	info, _ := vittoriaCollection.Info()
+	if info.IndexType != IndexTypeHNSW || info.HNSW == nil || info.HNSW.M != 8 {
+		t.Fatalf("rebuilt collection has index type %s and HNSW parameters %+v", info.IndexType, info.HNSW)
+	}
+	if size := vittoriaCollection.index.Size(); size != 40 {
+		t.Errorf("rebuilt index holds %d vectors, want 40", size)
+	}
+	if response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{100, 1, 2, 0.5}, Limit: 1}); err != nil || response.Results[0].ID != "late" {
+		t.Errorf("Search after the rebuild returned %v (%v)", response, err)
+	}
+
+	// Deleting half of the graph rebuilds it on its own
+	for i := 1; i <= 20; i++ {
+		if err := collection.Delete(ctx, fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	status = waitForRebuild(t, vittoriaCollection)
+	if status.State != RebuildCompleted || status.Trigger != RebuildTriggerAuto {
+		t.Fatalf("automatic rebuild ended as %+v", status)
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import "math"
+
+// With performance.enable_simd, searches score vectors with the SIMD kernels,
+// and brute-force scans score the vectors that pass the filter in batches.
+// Cosine collections record the norm of each vector as it is stored, so that
+// a kernel scores it with one dot product instead of three sums.
+
+// kernelBatchSize is the number of vectors a scan scores at once
+const kernelBatchSize = 256
+
+// newSearchKernels returns the distance kernels of searches, or nil to keep
+// the scalar loops
+func newSearchKernels(enabled bool) *SIMDVectorOps {
+	if !enabled {
+		return nil
+	}
+	// Scans already spread over workers, so batches are scored in place
+	return NewSIMDVectorOps(&SIMDConfig{
+		Enabled:        true,
+		VectorizedMath: true,
+		ChunkSize:      kernelBatchSize,
+		NumWorkers:     1,
+	})
+}
+
+// kernelScored reports whether the SIMD kernels can score the metric
+func (d DistanceMetric) kernelScored() bool {
+	return d == DistanceMetricCosine || d == DistanceMetricEuclidean || d == DistanceMetricDotProduct
+}
+
+// vectorNorm returns the Euclidean norm of a vector
+func vectorNorm(vector []float32) float32 {
+	return float32(math.Sqrt(float64(dotProduct(vector, vector))))
+}
+
+// normVector records the norm of a stored vector in a cosine collection
+func (c *VittoriaCollection) normVector(vector *Vector) {
+	if c.metric == DistanceMetricCosine && vector.hasVector() {
+		vector.norm = vectorNorm(vector.Vector)
+	}
+}
+
+// normVectors records the norm of every stored vector, after they are loaded
+func (c *VittoriaCollection) normVectors() {
+	if c.metric != DistanceMetricCosine {
+		return
+	}
+	for _, vector := range c.vectors {
+		c.normVector(vector)
+	}
+}
+
+// kernelScore returns the similarity of a stored vector to the query, scored
+// by the kernels
+func (s *queryScorer) kernelScore(vector *Vector) float32 {
+	switch s.c.metric {
+	case DistanceMetricCosine:
+		return s.cosine(vector, s.kernels.DotProduct(s.query, vector.Vector))
+	case DistanceMetricEuclidean:
+		return 1.0 / (1.0 + s.kernels.EuclideanDistance(s.query, vector.Vector))
+	default:
+		return s.kernels.DotProduct(s.query, vector.Vector)
+	}
+}
+
+// cosine returns the cosine similarity of a stored vector to the query from
+// their dot product and the recorded norms
+func (s *queryScorer) cosine(vector *Vector, dot float32) float32 {
+	if s.norm == 0 || vector.norm == 0 {
+		return 0
+	}
+	return dot / (s.norm * vector.norm)
+}
+
+// scoreBatch scores vectors into scores, with the batch kernel when the
+// metric is built on dot products; data is scratch space for the components
+func (s *queryScorer) scoreBatch(vectors []*Vector, scores []float32, data [][]float32) {
+	if s.kernels == nil || s.quantized != nil || s.c.halfPrecision() || s.c.metric == DistanceMetricEuclidean {
+		for i, vector := range vectors {
+			scores[i] = s.score(vector)
+		}
+		return
+	}
+	data = data[:0]
+	for _, vector := range vectors {
+		data = append(data, vector.Vector)
+	}
+	s.kernels.DotProductBatch(s.query, data, scores)
+	if s.c.metric == DistanceMetricCosine {
+		for i, vector := range vectors {
+			scores[i] = s.cosine(vector, scores[i])
+		}
+	}
+}
+
+// scanBatch gathers the vectors of a scan that pass the filter and scores
+// them kernelBatchSize at a time into a top k
+type scanBatch struct {
+	scorer  *queryScorer
+	req     *SearchRequest
+	top     *topK
+	matched int // Vectors scored above the request's minimum score
+	vectors []*Vector
+	scores  []float32
+	data    [][]float32
+}
+
+// newScanBatch returns an empty batch keeping the k best vectors
+func newScanBatch(scorer *queryScorer, req *SearchRequest, k int) *scanBatch {
+	return &scanBatch{
+		scorer:  scorer,
+		req:     req,
+		top:     newTopK(k),
+		vectors: make([]*Vector, 0, kernelBatchSize),
+		scores:  make([]float32, kernelBatchSize),
+		data:    make([][]float32, 0, kernelBatchSize),
+	}
+}
+
+// add queues a vector to be scored, scoring the batch once it is full
+func (b *scanBatch) add(vector *Vector) {
+	b.vectors = append(b.vectors, vector)
+	if len(b.vectors) == kernelBatchSize {
+		b.flush()
+	}
+}
+
+// flush scores the queued vectors and offers them to the top k
+func (b *scanBatch) flush() {
+	scores := b.scores[:len(b.vectors)]
+	b.scorer.scoreBatch(b.vectors, scores, b.data)
+	for i, vector := range b.vectors {
+		if b.scorer.c.belowMinScore(b.req, scores[i]) {
+			continue
+		}
+		b.top.offer(vector, scores[i])
+		b.matched++
+	}
+	b.vectors = b.vectors[:0]
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+)
+
+// DimensionsCeiling bounds the max_dimensions limit itself
+const DimensionsCeiling = config.DimensionsCeiling
+
+// MaxCollectionNameLength bounds the length of collection names
+const MaxCollectionNameLength = 128
+
+// collectionNamePattern restricts collection names, which name directories
+// of the data directory and appear in URLs: no path separators, no leading
+// dot, nothing a shell or URL would need to quote
+var collectionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
+
+// internalPrefix starts the names of internal collections only
+const internalPrefix = "_"
+
+// reservedNames are the files kept next to the collections of a data
+// directory, compared regardless of case for case-insensitive file systems
+var reservedNames = []string{groupsFile, growthFile, searchCacheFile, lockFileName, "auth_keys.json", "auth_usage.json"}
+
+// ValidateCollectionName checks that name can name a new collection
+func ValidateCollectionName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("collection name cannot be empty")
+	case len(name) > MaxCollectionNameLength:
+		return fmt.Errorf("collection name must be at most %d characters", MaxCollectionNameLength)
+	case !collectionNamePattern.MatchString(name):
+		return fmt.Errorf("collection name '%s' must start with a letter, digit or '_' and contain only letters, digits, '.', '_' and '-'", name)
+	}
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("collection name '%s' is reserved", name)
+		}
+	}
+	return nil
+}
+
+// limits returns the creation limits of the database, with defaults for
+// those left unset
+func (db *VittoriaDB) limits() LimitsConfig {
+	var limits LimitsConfig
+	if db.config != nil {
+		limits = db.config.Limits
+	}
+	if limits.MaxDimensions <= 0 {
+		limits.MaxDimensions = MaxDimensions
+	}
+	return limits
+}
+
+// validateLimits checks a collection creation request against the limits of
+// the database; the caller holds mu
+func (db *VittoriaDB) validateLimits(req *CreateCollectionRequest) error {
+	limits := db.limits()
+
+	if strings.HasPrefix(req.Name, internalPrefix) && !req.Internal {
+		return fmt.Errorf("collection names starting with '%s' are reserved for internal collections", internalPrefix)
+	}
+	for _, prefix := range limits.ReservedPrefixes {
+		if prefix != "" && strings.HasPrefix(req.Name, prefix) {
+			return fmt.Errorf("collection name '%s' starts with the reserved prefix '%s'", req.Name, prefix)
+		}
+	}
+
+	if req.Dimensions <= 0 || req.Dimensions > limits.MaxDimensions {
+		return fmt.Errorf("dimensions must be between 1 and %d (limits.max_dimensions), got %d", limits.MaxDimensions, req.Dimensions)
+	}
+
+	return db.checkCollectionLimit()
+}
+
+// checkCollectionLimit fails when the database holds as many collections as
+// limits.max_collections allows; the caller holds mu
+func (db *VittoriaDB) checkCollectionLimit() error {
+	limit := db.limits().MaxCollections
+	if limit > 0 && len(db.collections) >= limit {
+		return errorf(ErrLimitExceeded, "the database holds %d collections, the most limits.max_collections allows", len(db.collections))
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCollectionLimits(t *testing.T) {
+	ctx := context.Background()
+	config := &Config{DataDir: t.TempDir(), Limits: LimitsConfig{MaxDimensions: 100, MaxCollections: 2, ReservedPrefixes: []string{"tmp_"}}}
+	db := openTestDatabase(t, config)
+
+	create := func(name string, dimensions int) error {
+		return db.CreateCollection(ctx, &CreateCollectionRequest{Name: name, Dimensions: dimensions, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat})
+	}
+	for name, dimensions := range map[string]int{"wide": 101, "tmp_x": 2, "a/b": 2, "_x": 2, "..": 2, "Groups.json": 2} {
+		if err := create(name, dimensions); err == nil {
+			t.Errorf("created collection %q with %d dimensions", name, dimensions)
+		}
+	}
+
+	if err := create("docs", 100); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if err := create("Docs", 2); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("case-insensitive duplicate: err = %v, want ErrAlreadyExists", err)
+	}
+	if err := create("faq", 2); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	if err := create("more", 2); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("third collection: err = %v, want ErrLimitExceeded", err)
+	}
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A load test inserts and searches random vectors in a temporary internal
+// collection at a fixed rate, and reports the latency of both. Operations
+// are paced by a single clock and handed to a pool of workers; an operation
+// that comes due while every worker is busy is skipped rather than queued,
+// so a saturated deployment shows up as skipped operations instead of an
+// ever-growing backlog. The collection is deleted when the test ends.
+
+// Bounds of a load test, so that one request cannot hold a server for long
+// or fill its memory
+const (
+	MaxLoadTestDuration    = 5 * time.Minute
+	maxLoadTestQPS         = 10000
+	maxLoadTestVectors     = 100000
+	maxLoadTestConcurrency = 64
+)
+
+// loadTestPrefix starts the names of the collections of load tests
+const loadTestPrefix = internalPrefix + "loadtest-"
+
+// LoadTestRequest configures a load test
+type LoadTestRequest struct {
+	Dimensions      int            `json:"dimensions"`       // Default 128
+	Metric          DistanceMetric `json:"metric"`           // Default cosine
+	IndexType       IndexType      `json:"index_type"`       // Default flat
+	Vectors         int            `json:"vectors"`          // Inserted before the timed run, default 1000
+	DurationSeconds float64        `json:"duration_seconds"` // Length of the timed run, default 10
+	QPS             float64        `json:"qps"`              // Operations started per second, default 100
+	SearchRatio     *float64       `json:"search_ratio"`     // Share of the operations that are searches, default 0.8
+	Concurrency     int            `json:"concurrency"`      // Workers running operations, default 4
+	Limit           int            `json:"limit"`            // Results per search, default 10
+}
+
+// Normalize fills in the defaults of a load test and checks its bounds
+func (req *LoadTestRequest) Normalize() error {
+	if req.Dimensions == 0 {
+		req.Dimensions = 128
+	}
+	if req.Vectors == 0 {
+		req.Vectors = 1000
+	}
+	if req.DurationSeconds == 0 {
+		req.DurationSeconds = 10
+	}
+	if req.QPS == 0 {
+		req.QPS = 100
+	}
+	if req.SearchRatio == nil {
+		ratio := 0.8
+		req.SearchRatio = &ratio
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 4
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	switch {
+	case req.Dimensions < 0 || req.Dimensions > MaxDimensions:
+		return fmt.Errorf("dimensions must be between 1 and %d", MaxDimensions)
+	case req.Vectors < 0 || req.Vectors > maxLoadTestVectors:
+		return fmt.Errorf("vectors must be between 1 and %d", maxLoadTestVectors)
+	case req.DurationSeconds < 0 || req.DurationSeconds > MaxLoadTestDuration.Seconds():
+		return fmt.Errorf("duration_seconds must be between 0 and %g", MaxLoadTestDuration.Seconds())
+	case req.QPS < 0 || req.QPS > maxLoadTestQPS:
+		return fmt.Errorf("qps must be between 0 and %d", maxLoadTestQPS)
+	case *req.SearchRatio < 0 || *req.SearchRatio > 1:
+		return fmt.Errorf("search_ratio must be between 0 and 1")
+	case req.Concurrency < 0 || req.Concurrency > maxLoadTestConcurrency:
+		return fmt.Errorf("concurrency must be between 1 and %d", maxLoadTestConcurrency)
+	case req.Limit < 0:
+		return fmt.Errorf("limit must be positive")
+	}
+	return nil
+}
+
+// LoadTestLatency summarizes the operations of one kind in a load test
+type LoadTestLatency struct {
+	Count  int64   `json:"count"`
+	Errors int64   `json:"errors"`
+	MeanMS float64 `json:"mean_ms"`
+	P50MS  float64 `json:"p50_ms"`
+	P90MS  float64 `json:"p90_ms"`
+	P99MS  float64 `json:"p99_ms"`
+	MaxMS  float64 `json:"max_ms"`
+}
+
+// LoadTestReport is the outcome of a load test
+type LoadTestReport struct {
+	Collection  string          `json:"collection"`
+	Dimensions  int             `json:"dimensions"`
+	Vectors     int64           `json:"vectors"`     // Vectors in the collection at the end
+	PreloadMS   int64           `json:"preload_ms"`  // Time taken to insert the initial vectors
+	DurationMS  int64           `json:"duration_ms"` // Length of the timed run
+	TargetQPS   float64         `json:"target_qps"`
+	AchievedQPS float64         `json:"achieved_qps"` // Operations completed per second
+	Skipped     int64           `json:"skipped"`      // Operations skipped because every worker was busy
+	Inserts     LoadTestLatency `json:"inserts"`
+	Searches    LoadTestLatency `json:"searches"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// loadTestOp is an operation of the timed run
+type loadTestOp int
+
+const (
+	loadTestInsert loadTestOp = iota
+	loadTestSearch
+)
+
+// latencyRecorder collects the latencies of the operations of one kind
+type latencyRecorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int64
+}
+
+// record adds the outcome of one operation
+func (r *latencyRecorder) record(took time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.latencies = append(r.latencies, took)
+}
+
+// summary returns the count, mean and percentiles of the latencies
+func (r *latencyRecorder) summary() LoadTestLatency {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	summary := LoadTestLatency{Count: int64(len(r.latencies)), Errors: r.errors}
+	if len(r.latencies) == 0 {
+		return summary
+	}
+	slices.Sort(r.latencies)
+	var total time.Duration
+	for _, took := range r.latencies {
+		total += took
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	percentile := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(r.latencies)))) - 1
+		return ms(r.latencies[max(i, 0)])
+	}
+	summary.MeanMS = ms(total) / float64(len(r.latencies))
+	summary.P50MS = percentile(0.5)
+	summary.P90MS = percentile(0.9)
+	summary.P99MS = percentile(0.99)
+	summary.MaxMS = ms(r.latencies[len(r.latencies)-1])
+	return summary
+}
+
+// LoadTest runs a load test against a temporary collection, which it
+// deletes afterwards. When ctx ends first, it reports what ran until then.
+func (db *VittoriaDB) LoadTest(ctx context.Context, req *LoadTestRequest) (*LoadTestReport, error) {
+	if err := req.Normalize(); err != nil {
+		return nil, err
+	}
+	inserts := int64(req.Vectors) + int64(req.QPS*req.DurationSeconds*(1-*req.SearchRatio))
+	if err := db.CheckMemory(inserts * (vectorOverhead + 4*int64(req.Dimensions))); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s%d", loadTestPrefix, time.Now().UnixNano())
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{
+		Name:       name,
+		Dimensions: req.Dimensions,
+		Metric:     req.Metric,
+		IndexType:  req.IndexType,
+		Internal:   true,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create load test collection: %w", err)
+	}
+	defer func() {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if err := db.dropCollection(context.WithoutCancel(ctx), name, 0); err != nil {
+			fmt.Printf("Error dropping load test collection %s: %v\n", name, err)
+		}
+	}()
+	db.mu.RLock()
+	collection, exists := db.collections[name]
+	db.mu.RUnlock()
+	if !exists {
+		return nil, errorf(ErrNotFound, "collection '%s' not found", name)
+	}
+	// Random queries never repeat, and would only evict the cached results
+	// of real searches
+	if collection.searchEngine != nil {
+		collection.searchEngine.useCache(nil)
+	}
+
+	report := &LoadTestReport{Collection: name, Dimensions: req.Dimensions, TargetQPS: req.QPS}
+	var nextID atomic.Int64
+	randomVector := func() []float32 {
+		vector := make([]float32, req.Dimensions)
+		for i := range vector {
+			vector[i] = rand.Float32()*2 - 1
+		}
+		return vector
+	}
+	newVector := func() *Vector {
+		return &Vector{
+			ID:       fmt.Sprintf("load-%d", nextID.Add(1)),
+			Vector:   randomVector(),
+			Metadata: map[string]interface{}{"group": rand.IntN(10)},
+		}
+	}
+
+	// The initial vectors go in batches, as a bulk load would
+	start := time.Now()
+	for loaded := 0; loaded < req.Vectors; {
+		batch := make([]*Vector, min(1000, req.Vectors-loaded))
+		for i := range batch {
+			batch[i] = newVector()
+		}
+		if err := collection.InsertBatch(ctx, batch); err != nil {
+			return nil, fmt.Errorf("failed to insert the initial vectors: %w", err)
+		}
+		loaded += len(batch)
+	}
+	report.PreloadMS = time.Since(start).Milliseconds()
+
+	var insertLatency, searchLatency latencyRecorder
+	var lastError atomic.Value
+	ops := make(chan loadTestOp)
+	var workers sync.WaitGroup
+	for range req.Concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for op := range ops {
+				var err error
+				began := time.Now()
+				if op == loadTestInsert {
+					err = collection.Insert(ctx, newVector())
+					insertLatency.record(time.Since(began), err)
+				} else {
+					_, err = collection.Search(ctx, &SearchRequest{Vector: randomVector(), Limit: req.Limit})
+					searchLatency.record(time.Since(began), err)
+				}
+				if err != nil {
+					lastError.Store(err.Error())
+				}
+			}
+		}()
+	}
+
+	start = time.Now()
+	duration := time.Duration(req.DurationSeconds * float64(time.Second))
+	if req.QPS > 0 && duration > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / req.QPS))
+		deadline := time.NewTimer(duration)
+	run:
+		for {
+			select {
+			case <-ticker.C:
+				op := loadTestInsert
+				if rand.Float64() < *req.SearchRatio {
+					op = loadTestSearch
+				}
+				select {
+				case ops <- op:
+				default:
+					report.Skipped++
+				}
+			case <-deadline.C:
+				break run
+			case <-ctx.Done():
+				break run
+			}
+		}
+		ticker.Stop()
+		deadline.Stop()
+	}
+	close(ops)
+	workers.Wait()
+	elapsed := time.Since(start)
+
+	report.DurationMS = elapsed.Milliseconds()
+	report.Inserts = insertLatency.summary()
+	report.Searches = searchLatency.summary()
+	if elapsed > 0 {
+		completed := report.Inserts.Count + report.Searches.Count
+		report.AchievedQPS = float64(completed) / elapsed.Seconds()
+	}
+	if message, ok := lastError.Load().(string); ok {
+		report.LastError = message
+	}
+	report.Vectors, _ = collection.Count()
+	return report, nil
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLoadTest(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	ratio := 0.5
+	report, err := db.LoadTest(ctx, &LoadTestRequest{Dimensions: 8, Vectors: 200, DurationSeconds: 0.5, QPS: 100, SearchRatio: &ratio})
+	if err != nil {
+		t.Fatalf("LoadTest failed: %v", err)
+	}
+	if report.Inserts.Count == 0 || report.Searches.Count == 0 {
+		t.Errorf("report = %+v, want both inserts and searches", report)
+	}
+	if report.Vectors != 200+report.Inserts.Count {
+		t.Errorf("vectors = %d, want 200 plus %d inserts", report.Vectors, report.Inserts.Count)
+	}
+	if s := report.Searches; s.Errors != 0 || s.P50MS > s.P99MS || s.P99MS > s.MaxMS {
+		t.Errorf("search latencies = %+v", s)
+	}
+
+	// The temporary collection is gone
+	collections, _ := db.ListAllCollections(ctx)
+	if len(collections) != 0 {
+		t.Errorf("collections left after the load test: %v", collections)
+	}
+
+	if _, err := db.LoadTest(ctx, &LoadTestRequest{QPS: 1e6}); err == nil {
+		t.Error("LoadTest accepted a qps past the limit")
+	}
+}
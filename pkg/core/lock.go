@@ -0,0 +1,61 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lockFileName is the file a writer keeps locked in its data directory
+const lockFileName = "LOCK"
+
+// dirLock is the lock of the single writer of a data directory. Readers open
+// the directory with Config.ReadOnly and take no lock, so any number of them
+// can share it with the writer.
+type dirLock struct {
+	file *os.File
+}
+
+// lockDataDir takes the writer lock of a data directory, failing with
+// ErrLocked when another writer, in this process or another, holds it. The
+// operating system releases the lock when the holder exits.
+func lockDataDir(dir string) (*dirLock, error) {
+	path := filepath.Join(dir, lockFileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		holder := ""
+		if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+			holder = " (pid " + strings.TrimSpace(string(data)) + ")"
+		}
+		return nil, errorf(ErrLocked, "data directory %s is in use by another writer%s; open it read-only to share it", dir, holder)
+	}
+
+	// The holder's pid, for the error above
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &dirLock{file: file}, nil
+}
+
+// release gives the lock up
+func (l *dirLock) release() error {
+	unlockFile(l.file)
+	return l.file.Close()
+}
+
+// writeFileAtomic replaces a file so that readers in other processes see
+// either its old or its new content, never a partial write
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReadOnlyOpen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	writer := NewDatabase()
+	if err := writer.Open(ctx, &Config{DataDir: dir}); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if err := writer.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := writer.GetCollection(ctx, "docs")
+	collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 0}})
+	if err := collection.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// A second writer is refused while the first holds the directory
+	if err := NewDatabase().Open(ctx, &Config{DataDir: dir}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected the directory to be locked, got %v", err)
+	}
+
+	reader := NewDatabase()
+	if err := reader.Open(ctx, &Config{DataDir: dir, ReadOnly: true}); err != nil {
+		t.Fatalf("read-only open failed: %v", err)
+	}
+	shared, err := reader.GetCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("reader cannot see the collection: %v", err)
+	}
+	if _, err := shared.Get(ctx, "a"); err != nil {
+		t.Errorf("reader cannot see the flushed vector: %v", err)
+	}
+	if err := shared.Insert(ctx, &Vector{ID: "b", Vector: []float32{0, 1}}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected a read-only insert error, got %v", err)
+	}
+	if err := reader.DropCollection(ctx, "docs"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected a read-only drop error, got %v", err)
+	}
+
+	// The writer's later inserts survive the reader closing after it
+	collection.Insert(ctx, &Vector{ID: "c", Vector: []float32{0, 1}})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("writer close failed: %v", err)
+	}
+	reader.Close()
+
+	reopened := NewDatabase()
+	if err := reopened.Open(ctx, &Config{DataDir: dir}); err != nil {
+		t.Fatalf("reopen after the writer closed failed: %v", err)
+	}
+	defer reopened.Close()
+	collection, _ = reopened.GetCollection(ctx, "docs")
+	if count, _ := collection.Count(); count != 2 {
+		t.Errorf("count = %d; want 2", count)
+	}
+}
@@ -0,0 +1,404 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/objectstore"
+	"github.com/antonellof/VittoriaDB/pkg/scheduler"
+)
+
+// Maintenance job types
+const (
+	MaintenanceCompact    = config.MaintenanceCompact
+	MaintenanceBackup     = config.MaintenanceBackup
+	MaintenanceDedupe     = config.MaintenanceDedupe
+	MaintenanceRetention  = config.MaintenanceRetention
+	MaintenanceIndexCheck = config.MaintenanceIndexCheck
+)
+
+// MaintenanceJobTypes lists the maintenance job types
+var MaintenanceJobTypes = config.MaintenanceJobTypes
+
+// backupFilePrefix and backupFileSuffix name the archives written by backup
+// jobs, with a timestamp in between so that they sort by age
+const (
+	backupFilePrefix = "vittoriadb-"
+	backupFileSuffix = ".tar.gz"
+)
+
+// startMaintenance schedules the configured maintenance jobs; the caller
+// holds mu
+func (db *VittoriaDB) startMaintenance(config MaintenanceConfig) error {
+	if len(config.Jobs) == 0 {
+		return nil
+	}
+
+	location, err := maintenanceLocation(config)
+	if err != nil {
+		return err
+	}
+
+	s := scheduler.New(location)
+	for _, job := range config.Jobs {
+		fn, err := db.maintenanceJob(job, config)
+		if err != nil {
+			return fmt.Errorf("invalid maintenance job '%s': %w", job.Name, err)
+		}
+		if err := s.Add(job.Name, job.Type, job.Schedule, fn); err != nil {
+			return fmt.Errorf("invalid maintenance job '%s': %w", job.Name, err)
+		}
+	}
+
+	s.Start()
+	db.scheduler = s
+	return nil
+}
+
+// stopMaintenance stops the scheduler and waits for running jobs. It is
+// called without mu held, since jobs take it.
+func (db *VittoriaDB) stopMaintenance() {
+	db.mu.RLock()
+	s := db.scheduler
+	db.mu.RUnlock()
+
+	if s != nil {
+		s.Stop()
+	}
+}
+
+// maintenanceStatuses returns the status of the scheduled maintenance jobs
+func (db *VittoriaDB) maintenanceStatuses() []*scheduler.JobStatus {
+	if db.scheduler == nil {
+		return nil
+	}
+	return db.scheduler.Statuses()
+}
+
+// maintenanceJob returns the function running a maintenance job
+func (db *VittoriaDB) maintenanceJob(job MaintenanceJob, config MaintenanceConfig) (scheduler.JobFunc, error) {
+	if job.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	for _, pattern := range job.Collections {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid collection pattern '%s': %w", pattern, err)
+		}
+	}
+
+	switch job.Type {
+	case MaintenanceCompact:
+		return db.forEachCollection(job, true, func(ctx context.Context, c *VittoriaCollection) (int, error) {
+			if err := c.Compact(ctx); err != nil {
+				return 0, err
+			}
+			return 1, nil
+		}, "compacted %d of %d collections"), nil
+
+	case MaintenanceDedupe:
+		return db.forEachCollection(job, true, func(ctx context.Context, c *VittoriaCollection) (int, error) {
+			return c.Deduplicate(ctx)
+		}, "removed %d duplicate vectors from %d collections"), nil
+
+	case MaintenanceRetention:
+		return db.forEachCollection(job, false, func(ctx context.Context, c *VittoriaCollection) (int, error) {
+			return c.DeleteExpired(ctx)
+		}, "removed %d expired vectors from %d collections"), nil
+
+	case MaintenanceIndexCheck:
+		summary := "found %d unhealthy indexes in %d collections"
+		if job.Repair {
+			summary = "repaired %d indexes in %d collections"
+		}
+		// Only repairs are heavy: a check reads the graph without changing it
+		return db.forEachCollection(job, job.Repair, func(ctx context.Context, c *VittoriaCollection) (int, error) {
+			if c.indexType != IndexTypeHNSW {
+				return 0, nil
+			}
+			report, err := c.CheckIndex(ctx)
+			if err != nil || report.Healthy {
+				return 0, err
+			}
+			if job.Repair {
+				if _, err := c.RepairIndex(ctx); err != nil {
+					return 0, err
+				}
+			}
+			return 1, nil
+		}, summary), nil
+
+	case MaintenanceBackup:
+		if objectstore.IsURI(config.BackupDir) {
+			store, prefix, err := objectstore.Open(config.BackupDir, &db.config.ObjectStorage)
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context) (string, error) {
+				return db.uploadBackup(ctx, job, store, strings.Trim(prefix, "/"), config.BackupRetention)
+			}, nil
+		}
+		dir := config.BackupDir
+		if dir == "" {
+			dir = "backups"
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(db.dataDir, dir)
+		}
+		return func(ctx context.Context) (string, error) {
+			return db.writeBackup(ctx, job, dir, config.BackupRetention)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown type '%s': expected one of %s", job.Type, strings.Join(MaintenanceJobTypes, ", "))
+	}
+}
+
+// forEachCollection returns a job function applying fn to every collection
+// the job targets. fn returns a count that is summed into the summary, which
+// is formatted from the total and the number of collections. Heavy jobs are
+// deferred on the collections outside their maintenance windows.
+func (db *VittoriaDB) forEachCollection(job MaintenanceJob, heavy bool, fn func(context.Context, *VittoriaCollection) (int, error), summary string) scheduler.JobFunc {
+	return func(ctx context.Context) (string, error) {
+		collections, err := db.matchCollections(job.Collections)
+		if err != nil {
+			return "", err
+		}
+
+		total, deferred := 0, 0
+		var failed []string
+		for _, collection := range collections {
+			if err := ctx.Err(); err != nil {
+				return fmt.Sprintf(summary, total, len(collections)), err
+			}
+			if now := time.Now(); heavy && !collection.inMaintenanceWindow(now) {
+				db.deferJob(collection, job.Name, fn, now)
+				deferred++
+				continue
+			}
+			n, err := fn(ctx, collection)
+			total += n
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", collection.Name(), err))
+			}
+		}
+
+		result := fmt.Sprintf(summary, total, len(collections)-deferred)
+		if deferred > 0 {
+			result += fmt.Sprintf(", deferred %d outside their maintenance windows", deferred)
+		}
+		if len(failed) > 0 {
+			return result, fmt.Errorf("%d collections failed: %s", len(failed), strings.Join(failed, "; "))
+		}
+		return result, nil
+	}
+}
+
+// writeBackup archives the collections the job targets into a new file in
+// dir and removes the oldest archives beyond retention
+func (db *VittoriaDB) writeBackup(ctx context.Context, job MaintenanceJob, dir string, retention int) (string, error) {
+	collections, err := db.matchCollections(job.Collections)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	target := filepath.Join(dir, backupName())
+
+	// Write to a temporary file so a failed backup never looks complete
+	tmp := target + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup file: %w", err)
+	}
+	err = backupCollections(ctx, file, collections, db.groupsCovering(collections))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", err
+	}
+	result := fmt.Sprintf("wrote %s (%d collections, %d bytes)", target, len(collections), info.Size())
+
+	if retention > 0 {
+		removed, err := pruneBackups(dir, retention)
+		if err != nil {
+			return result, fmt.Errorf("failed to remove old backups: %w", err)
+		}
+		if removed > 0 {
+			result += fmt.Sprintf(", removed %d old backups", removed)
+		}
+	}
+	return result, nil
+}
+
+// uploadBackup archives the collections the job targets into a new object
+// under prefix and removes the oldest archives there beyond retention
+func (db *VittoriaDB) uploadBackup(ctx context.Context, job MaintenanceJob, store *objectstore.Store, prefix string, retention int) (string, error) {
+	collections, err := db.matchCollections(job.Collections)
+	if err != nil {
+		return "", err
+	}
+
+	// The archive is staged locally, as an upload needs its size up front
+	file, err := os.CreateTemp("", "vittoriadb-backup-*"+backupFileSuffix)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	err = backupCollections(ctx, file, collections, db.groupsCovering(collections))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(file.Name())
+	if err != nil {
+		return "", err
+	}
+
+	key := path.Join(prefix, backupName())
+	if err := store.PutFile(ctx, key, file.Name()); err != nil {
+		return "", fmt.Errorf("failed to upload backup: %w", err)
+	}
+	result := fmt.Sprintf("wrote %s (%d collections, %d bytes)", store.URI(key), len(collections), info.Size())
+
+	if retention > 0 {
+		removed, err := pruneObjectBackups(ctx, store, prefix, retention)
+		if err != nil {
+			return result, fmt.Errorf("failed to remove old backups: %w", err)
+		}
+		if removed > 0 {
+			result += fmt.Sprintf(", removed %d old backups", removed)
+		}
+	}
+	return result, nil
+}
+
+// backupName returns the name of a backup archive written now
+func backupName() string {
+	return backupFilePrefix + time.Now().UTC().Format("20060102T150405Z") + backupFileSuffix
+}
+
+// isBackupName reports whether name is that of a backup archive
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupFilePrefix) && strings.HasSuffix(name, backupFileSuffix)
+}
+
+// oldBackups returns all but the newest keep of the named backup archives
+func oldBackups(names []string, keep int) []string {
+	if len(names) <= keep {
+		return nil
+	}
+	// Timestamped names sort oldest first
+	sort.Strings(names)
+	return names[:len(names)-keep]
+}
+
+// pruneBackups removes all but the newest keep backup archives in dir
+func pruneBackups(dir string, keep int) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isBackupName(entry.Name()) {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	removed := 0
+	for _, name := range oldBackups(backups, keep) {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// pruneObjectBackups removes all but the newest keep backup archives directly
+// under prefix in an object store
+func pruneObjectBackups(ctx context.Context, store *objectstore.Store, prefix string, keep int) (int, error) {
+	listPrefix := ""
+	if prefix != "" {
+		listPrefix = prefix + "/"
+	}
+	objects, err := store.List(ctx, listPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var backups []string
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, listPrefix)
+		if !strings.Contains(name, "/") && isBackupName(name) {
+			backups = append(backups, name)
+		}
+	}
+
+	removed := 0
+	for _, name := range oldBackups(backups, keep) {
+		if err := store.Delete(ctx, listPrefix+name); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// matchCollections returns the collections whose names match one of the
+// patterns (all of them when there are none), sorted by name
+func (db *VittoriaDB) matchCollections(patterns []string) ([]*VittoriaCollection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, errorf(ErrClosed, "database is closed")
+	}
+
+	collections := make([]*VittoriaCollection, 0, len(db.collections))
+	for name, collection := range db.collections {
+		if matchesAny(name, patterns) {
+			collections = append(collections, collection)
+		}
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].Name() < collections[j].Name()
+	})
+	return collections, nil
+}
+
+// matchesAny reports whether name matches one of the glob patterns, or
+// whether there are no patterns
+func matchesAny(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
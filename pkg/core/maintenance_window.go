@@ -0,0 +1,277 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/scheduler"
+)
+
+// Maintenance windows keep heavy jobs (compaction, deduplication, index
+// repairs and rebuilds) away from latency-sensitive hours. A window opens on
+// a cron schedule and stays open for a duration; a collection matched by
+// windows only runs heavy jobs while one of them is open. Scheduled jobs and
+// automatic rebuilds that come due outside are deferred, and run once the
+// next window opens; manual repairs and rebuilds are refused unless forced.
+
+// windowCheckInterval is how often deferred jobs are checked for an open
+// window
+const windowCheckInterval = time.Minute
+
+// maintenanceWindow is a parsed maintenance window
+type maintenanceWindow struct {
+	collections []string
+	schedule    *scheduler.Schedule
+	duration    time.Duration
+	location    *time.Location
+}
+
+// DeferredMaintenance is a heavy job waiting for a maintenance window
+type DeferredMaintenance struct {
+	Collection string    `json:"collection"`
+	Job        string    `json:"job"`
+	DeferredAt time.Time `json:"deferred_at"`
+	NextWindow time.Time `json:"next_window"` // When the collection's next window opens
+}
+
+// deferredJob is a heavy job deferred until the collection's window opens
+type deferredJob struct {
+	DeferredMaintenance
+	run func(context.Context, *VittoriaCollection) (int, error)
+}
+
+// deferredJobs are the heavy jobs waiting for a window, by collection and
+// job name
+type deferredJobs struct {
+	mu   sync.Mutex
+	jobs map[string]map[string]*deferredJob
+}
+
+// maintenanceLocation returns the time zone maintenance schedules are
+// evaluated in
+func maintenanceLocation(config MaintenanceConfig) (*time.Location, error) {
+	if config.Timezone == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance timezone: %w", err)
+	}
+	return location, nil
+}
+
+// parseMaintenanceWindows parses the maintenance windows of config
+func parseMaintenanceWindows(config MaintenanceConfig) ([]*maintenanceWindow, error) {
+	if len(config.Windows) == 0 {
+		return nil, nil
+	}
+	location, err := maintenanceLocation(config)
+	if err != nil {
+		return nil, err
+	}
+
+	windows := make([]*maintenanceWindow, 0, len(config.Windows))
+	for i, window := range config.Windows {
+		schedule, err := scheduler.ParseCron(window.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %d: %w", i, err)
+		}
+		if window.Duration <= 0 {
+			return nil, fmt.Errorf("invalid maintenance window %d: duration must be positive", i)
+		}
+		for _, pattern := range window.Collections {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid maintenance window %d: invalid collection pattern '%s': %w", i, pattern, err)
+			}
+		}
+		windows = append(windows, &maintenanceWindow{
+			collections: window.Collections,
+			schedule:    schedule,
+			duration:    window.Duration,
+			location:    location,
+		})
+	}
+	return windows, nil
+}
+
+// openAt reports whether the window is open at t: whether it last opened
+// less than its duration before t
+func (w *maintenanceWindow) openAt(t time.Time) bool {
+	opened := w.schedule.Next(t.In(w.location).Add(-w.duration))
+	return !opened.IsZero() && !opened.After(t)
+}
+
+// nextOpening returns when the window opens next after t, or the zero time
+// if it never does
+func (w *maintenanceWindow) nextOpening(t time.Time) time.Time {
+	return w.schedule.Next(t.In(w.location))
+}
+
+// windowsFor returns the maintenance windows matching the named collection
+func (db *VittoriaDB) windowsFor(name string) []*maintenanceWindow {
+	var windows []*maintenanceWindow
+	for _, window := range db.windows {
+		if matchesAny(name, window.collections) {
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// inMaintenanceWindow reports whether the collection may run heavy jobs at
+// t: whether it has no windows or one of them is open
+func (c *VittoriaCollection) inMaintenanceWindow(t time.Time) bool {
+	windows := c.indexOptions.windows
+	if len(windows) == 0 {
+		return true
+	}
+	for _, window := range windows {
+		if window.openAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextMaintenanceWindow returns when the collection's next window opens
+// after t, or the zero time if none ever does
+func (c *VittoriaCollection) nextMaintenanceWindow(t time.Time) time.Time {
+	var next time.Time
+	for _, window := range c.indexOptions.windows {
+		opening := window.nextOpening(t)
+		if !opening.IsZero() && (next.IsZero() || opening.Before(next)) {
+			next = opening
+		}
+	}
+	return next
+}
+
+// CheckMaintenanceWindow fails with ErrConflict when the collection is
+// outside its maintenance windows, so heavy jobs should not start on it now
+func (c *VittoriaCollection) CheckMaintenanceWindow() error {
+	now := time.Now()
+	if c.inMaintenanceWindow(now) {
+		return nil
+	}
+	if next := c.nextMaintenanceWindow(now); !next.IsZero() {
+		return errorf(ErrConflict, "collection '%s' is outside its maintenance windows until %s", c.name, next.Format(time.RFC3339))
+	}
+	return errorf(ErrConflict, "collection '%s' is outside its maintenance windows", c.name)
+}
+
+// deferJob queues a heavy job to run on the collection once its window
+// opens; a job deferred again replaces the earlier one
+func (db *VittoriaDB) deferJob(c *VittoriaCollection, job string, run func(context.Context, *VittoriaCollection) (int, error), now time.Time) {
+	db.deferred.mu.Lock()
+	defer db.deferred.mu.Unlock()
+
+	if db.deferred.jobs == nil {
+		db.deferred.jobs = make(map[string]map[string]*deferredJob)
+	}
+	jobs := db.deferred.jobs[c.name]
+	if jobs == nil {
+		jobs = make(map[string]*deferredJob)
+		db.deferred.jobs[c.name] = jobs
+	}
+	jobs[job] = &deferredJob{
+		DeferredMaintenance: DeferredMaintenance{
+			Collection: c.name,
+			Job:        job,
+			DeferredAt: now,
+			NextWindow: c.nextMaintenanceWindow(now),
+		},
+		run: run,
+	}
+}
+
+// deferredStatuses returns the jobs waiting for a window, by collection and
+// job name
+func (db *VittoriaDB) deferredStatuses() []*DeferredMaintenance {
+	db.deferred.mu.Lock()
+	defer db.deferred.mu.Unlock()
+
+	var statuses []*DeferredMaintenance
+	for _, jobs := range db.deferred.jobs {
+		for _, job := range jobs {
+			status := job.DeferredMaintenance
+			statuses = append(statuses, &status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Collection != statuses[j].Collection {
+			return statuses[i].Collection < statuses[j].Collection
+		}
+		return statuses[i].Job < statuses[j].Job
+	})
+	return statuses
+}
+
+// runDeferredMaintenance runs deferred jobs as the windows of their
+// collections open, until stop is closed
+func (db *VittoriaDB) runDeferredMaintenance(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			db.runOpenWindows(context.Background(), now)
+		}
+	}
+}
+
+// runOpenWindows runs the deferred jobs and pending automatic index rebuilds
+// of the collections whose window is open at now
+func (db *VittoriaDB) runOpenWindows(ctx context.Context, now time.Time) {
+	collections, err := db.matchCollections(nil)
+	if err != nil {
+		return
+	}
+
+	// Jobs of dropped collections are forgotten
+	existing := make(map[string]bool, len(collections))
+	for _, collection := range collections {
+		existing[collection.name] = true
+	}
+	db.deferred.mu.Lock()
+	for name := range db.deferred.jobs {
+		if !existing[name] {
+			delete(db.deferred.jobs, name)
+		}
+	}
+	db.deferred.mu.Unlock()
+
+	for _, collection := range collections {
+		if len(collection.indexOptions.windows) == 0 || !collection.inMaintenanceWindow(now) {
+			continue
+		}
+		collection.resumeAutoRebuild()
+
+		db.deferred.mu.Lock()
+		jobs := db.deferred.jobs[collection.name]
+		delete(db.deferred.jobs, collection.name)
+		db.deferred.mu.Unlock()
+
+		names := make([]string, 0, len(jobs))
+		for name := range jobs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := ctx.Err(); err != nil {
+				return
+			}
+			if _, err := jobs[name].run(ctx, collection); err != nil {
+				fmt.Printf("Deferred maintenance job %s failed on collection %s: %v\n", name, collection.name, err)
+			} else {
+				fmt.Printf("Ran deferred maintenance job %s on collection %s\n", name, collection.name)
+			}
+		}
+	}
+}
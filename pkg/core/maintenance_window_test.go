@@ -0,0 +1,77 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindows(t *testing.T) {
+	ctx := context.Background()
+	// A daily window that opens three hours from now, so it is closed now
+	opens := time.Now().UTC().Add(3 * time.Hour).Truncate(time.Minute)
+	config := MaintenanceConfig{
+		Timezone: "UTC",
+		Windows: []MaintenanceWindow{{
+			Collections: []string{"night_*"},
+			Schedule:    fmt.Sprintf("%d %d * * *", opens.Minute(), opens.Hour()),
+			Duration:    time.Hour,
+		}},
+	}
+	db := openTestDatabase(t, &Config{Maintenance: config})
+
+	window := db.windows[0]
+	for _, tc := range []struct {
+		at   time.Time
+		open bool
+	}{
+		{opens.Add(-time.Minute), false},
+		{opens, true},
+		{opens.Add(59 * time.Minute), true},
+		{opens.Add(time.Hour), false},
+	} {
+		if got := window.openAt(tc.at); got != tc.open {
+			t.Errorf("openAt(%s) = %v, want %v", tc.at, got, tc.open)
+		}
+	}
+
+	for _, name := range []string{"night_docs", "day_docs"} {
+		if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: name, Dimensions: 2}); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+	}
+	night, day := db.collections["night_docs"], db.collections["day_docs"]
+	if err := night.CheckMaintenanceWindow(); !errors.Is(err, ErrConflict) {
+		t.Errorf("CheckMaintenanceWindow outside the window = %v, want a conflict", err)
+	}
+	if err := day.CheckMaintenanceWindow(); err != nil {
+		t.Errorf("CheckMaintenanceWindow without windows = %v", err)
+	}
+
+	// Compaction runs at once where no window applies and is deferred
+	// elsewhere
+	job, err := db.maintenanceJob(MaintenanceJob{Name: "compact", Type: MaintenanceCompact}, config)
+	if err != nil {
+		t.Fatalf("maintenanceJob failed: %v", err)
+	}
+	summary, err := job(ctx)
+	if err != nil || summary != "compacted 1 of 1 collections, deferred 1 outside their maintenance windows" {
+		t.Errorf("job = %q, %v", summary, err)
+	}
+	stats, _ := db.Stats(ctx)
+	if len(stats.Deferred) != 1 || stats.Deferred[0].Collection != "night_docs" || !stats.Deferred[0].NextWindow.Equal(opens) {
+		t.Fatalf("deferred = %+v, want compact on night_docs until %s", stats.Deferred, opens)
+	}
+
+	// Deferred jobs wait while the window is closed, and run once it opens
+	db.runOpenWindows(ctx, opens.Add(-time.Minute))
+	if deferred := db.deferredStatuses(); len(deferred) != 1 {
+		t.Errorf("deferred before the window = %+v", deferred)
+	}
+	db.runOpenWindows(ctx, opens.Add(time.Minute))
+	if deferred := db.deferredStatuses(); len(deferred) != 0 {
+		t.Errorf("deferred after the window opened = %+v", deferred)
+	}
+}
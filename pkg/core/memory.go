@@ -0,0 +1,210 @@
+package core
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Writes are refused once storing them would take the Go heap past
+// performance.memory_limit, so that bulk loads get an error to back off on
+// rather than running the process out of memory. The check is approximate:
+// it adds an estimate of the incoming vectors to the live heap measured at
+// the last garbage collection, which the runtime starts early as the heap
+// nears the same limit.
+
+const (
+	memoryWarningRatio    = 0.9         // Share of the limit past which the log is warned
+	memoryWarningInterval = time.Minute // Least time between two warnings
+
+	// Approximate bytes a stored vector takes besides its components and
+	// metadata: the Vector struct, its map entry and the slice headers
+	vectorOverhead = 160
+	// Approximate bytes a metadata entry takes besides its key and value
+	metadataEntryOverhead = 48
+)
+
+// MemoryStats reports the memory the database holds against its limit
+type MemoryStats struct {
+	Limit          int64 `json:"limit"`           // performance.memory_limit, 0 when unlimited
+	HeapLive       int64 `json:"heap_live"`       // Live heap after the last garbage collection
+	Collections    int64 `json:"collections"`     // Estimated memory of the vectors and indexes of all collections
+	RejectedWrites int64 `json:"rejected_writes"` // Writes refused for the limit since the database opened
+}
+
+// memoryGuard holds the memory limit, counts the writes refused for it and
+// rate-limits the warnings about it
+type memoryGuard struct {
+	limit       atomic.Int64 // performance.memory_limit, 0 when unlimited; changed by Reconfigure
+	rejected    atomic.Int64
+	mu          sync.Mutex
+	lastWarning time.Time
+}
+
+// warn prints a warning unless one was printed within memoryWarningInterval
+func (g *memoryGuard) warn(format string, args ...interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Since(g.lastWarning) < memoryWarningInterval {
+		return
+	}
+	g.lastWarning = time.Now()
+	fmt.Printf("Warning: "+format+"\n", args...)
+}
+
+// memoryLimit returns performance.memory_limit, 0 when unlimited
+func (db *VittoriaDB) memoryLimit() int64 {
+	return db.memory.limit.Load()
+}
+
+// CheckMemory fails with ErrMemoryLimit when storing incoming more bytes
+// would take the heap past performance.memory_limit, and warns in the log
+// as the heap nears the limit. Use EstimateVectorMemory for incoming.
+func (db *VittoriaDB) CheckMemory(incoming int64) error {
+	limit := db.memoryLimit()
+	if limit == 0 {
+		return nil
+	}
+
+	live := heapLive()
+	if live+incoming > limit {
+		rejected := db.memory.rejected.Add(1)
+		db.memory.warn("refusing writes: the heap holds %d of the %d byte memory limit (%d writes refused so far)", live, limit, rejected)
+		return errorf(ErrMemoryLimit, "memory limit reached: %d bytes in use and %d more would exceed the %d byte limit", live, incoming, limit)
+	}
+	if float64(live+incoming) > memoryWarningRatio*float64(limit) {
+		db.memory.warn("the heap holds %d of the %d byte memory limit; writes are refused past it", live, limit)
+	}
+	return nil
+}
+
+// memoryStats returns the memory statistics of the database, or nil when it
+// has no memory limit; the caller holds mu
+func (db *VittoriaDB) memoryStats() *MemoryStats {
+	limit := db.memoryLimit()
+	if limit == 0 {
+		return nil
+	}
+	stats := &MemoryStats{
+		Limit:          limit,
+		HeapLive:       heapLive(),
+		RejectedWrites: db.memory.rejected.Load(),
+	}
+	for _, collection := range db.collections {
+		stats.Collections += collection.MemoryEstimate()
+	}
+	return stats
+}
+
+// heapLive returns the bytes of live heap objects as of the last garbage
+// collection
+func heapLive() int64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(sample[0].Value.Uint64())
+}
+
+// EstimateVectorMemory returns the approximate bytes storing vectors takes
+func EstimateVectorMemory(vectors []*Vector) int64 {
+	var total int64
+	for _, vector := range vectors {
+		total += vectorOverhead + int64(len(vector.ID)+len(vector.Namespace)) +
+			4*int64(len(vector.Vector)) + metadataMemory(vector.Metadata)
+	}
+	return total
+}
+
+// EstimateTextMemory returns the approximate bytes storing texts takes once
+// they are vectorized into dimensions components; the texts themselves
+// count, as content storage may keep them
+func EstimateTextMemory(texts []*TextVector, dimensions int) int64 {
+	var total int64
+	for _, text := range texts {
+		total += vectorOverhead + int64(len(text.ID)+len(text.Namespace)+len(text.Text)) +
+			4*int64(dimensions) + metadataMemory(text.Metadata)
+	}
+	return total
+}
+
+// metadataMemory returns the approximate bytes a metadata map takes
+func metadataMemory(metadata map[string]interface{}) int64 {
+	var total int64
+	for key, value := range metadata {
+		total += metadataEntryOverhead + int64(len(key)) + valueMemory(value)
+	}
+	return total
+}
+
+// valueMemory returns the approximate bytes a metadata value takes
+func valueMemory(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return 16 + int64(len(v))
+	case []interface{}:
+		total := int64(24)
+		for _, item := range v {
+			total += valueMemory(item)
+		}
+		return total
+	case map[string]interface{}:
+		return metadataMemory(v)
+	}
+	return 16
+}
+
+// MemoryEstimate returns the approximate bytes the collection's vectors,
+// metadata and index take in memory. Metadata is measured on a sample.
+func (c *VittoriaCollection) MemoryEstimate() int64 {
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		var total int64
+		for _, s := range c.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				total += local.MemoryEstimate()
+			}
+		}
+		return total
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := int64(len(c.vectors))
+	if n == 0 {
+		return 0
+	}
+
+	var sampled, metadata int64
+	for _, vector := range c.vectors {
+		if sampled == filterSampleSize {
+			break
+		}
+		metadata += int64(len(vector.ID)+len(vector.Namespace)) + metadataMemory(vector.Metadata)
+		sampled++
+	}
+
+	perVector := vectorOverhead + metadata/sampled
+	if c.quantizer != nil {
+		perVector += int64(c.dimensions) // A byte code per component
+	} else if c.halfPrecision() {
+		perVector += 2 * int64(c.dimensions)
+	} else {
+		perVector += 4 * int64(c.dimensions)
+	}
+	if c.metric.Binary() {
+		perVector += 8 * int64((c.dimensions+63)/64)
+	}
+	if c.index != nil {
+		// The graph keeps its own copy of the vector, and up to 2*M
+		// neighbors on the bottom layer
+		perVector += 4*int64(c.dimensions) + 8*int64(c.hnswParams().M)
+	}
+	return n * perVector
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+)
+
+func TestCheckMemory(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, &Config{Performance: PerfConfig{MemoryLimit: 1 << 40}})
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 4, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	vectors := []*Vector{{ID: "a", Vector: []float32{1, 0, 0, 0}, Metadata: map[string]interface{}{"title": "hello"}}}
+	if err := db.CheckMemory(EstimateVectorMemory(vectors)); err != nil {
+		t.Fatalf("CheckMemory below the limit: %v", err)
+	}
+	if err := collection.InsertBatch(ctx, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	// A write that cannot fit is refused and counted
+	if err := db.CheckMemory(1 << 41); !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("CheckMemory past the limit = %v, want ErrMemoryLimit", err)
+	}
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Memory == nil || stats.Memory.RejectedWrites != 1 || stats.Memory.Limit != 1<<40 {
+		t.Errorf("memory stats = %+v, want 1 refused write under a 1 TiB limit", stats.Memory)
+	}
+	if stats.Collections[0].MemoryBytes <= 16 || stats.Memory.Collections != stats.Collections[0].MemoryBytes {
+		t.Errorf("collection memory %d, total %d", stats.Collections[0].MemoryBytes, stats.Memory.Collections)
+	}
+
+	// Without a limit nothing is refused
+	unified := config.DefaultConfig()
+	unified.Performance.MemoryLimit = 0
+	if err := db.Reconfigure(ctx, unified); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if err := db.CheckMemory(1 << 41); err != nil {
+		t.Errorf("CheckMemory without a limit: %v", err)
+	}
+}
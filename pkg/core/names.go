@@ -0,0 +1,108 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// collectionPath returns the directory of the collection name in dataDir. It
+// fails for names that are not a single path element, so that no name, even
+// one read from a tampered metadata or trash file, reaches outside dataDir.
+func collectionPath(dataDir, name string) (string, error) {
+	if strings.ContainsAny(name, "/\\\x00") || !filepath.IsLocal(name) || name == "." {
+		return "", fmt.Errorf("collection name '%s' is not a valid directory name", name)
+	}
+	return filepath.Join(dataDir, name), nil
+}
+
+// SanitizeCollectionName turns name into one ValidateCollectionName accepts
+// as far as characters and length go: leading characters other than letters
+// and digits are dropped, and other characters that are not allowed become '_'
+func SanitizeCollectionName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		case b.Len() == 0:
+		case r == '.' || r == '_' || r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+		if b.Len() == MaxCollectionNameLength {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "collection"
+	}
+	return b.String()
+}
+
+// migrateCollectionDir prepares the directory dirName of the data directory
+// to be loaded and returns the name to load it under. Directories named in
+// ways ValidateCollectionName rejects, as older versions allowed, are renamed
+// to a sanitized name, and metadata naming another collection than its
+// directory is corrected; the caller holds mu.
+func (db *VittoriaDB) migrateCollectionDir(dirName string) (string, error) {
+	metadataPath := filepath.Join(db.dataDir, dirName, "metadata.json")
+	data, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata: %w", err)
+	}
+	// Fields are kept as they are, so that only the name is rewritten
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return "", fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	var storedName string
+	json.Unmarshal(metadata["name"], &storedName)
+
+	name := dirName
+	if ValidateCollectionName(dirName) != nil {
+		if db.config.ReadOnly {
+			fmt.Printf("Warning: collection directory %q has an invalid name; open the database writable to rename it\n", dirName)
+			return dirName, nil
+		}
+		base := SanitizeCollectionName(dirName)
+		name = base
+		for i := 2; ValidateCollectionName(name) != nil || db.collectionNameTaken(name); i++ {
+			suffix := fmt.Sprintf("-%d", i)
+			name = base[:min(len(base), MaxCollectionNameLength-len(suffix))] + suffix
+		}
+		if err := os.Rename(filepath.Join(db.dataDir, dirName), filepath.Join(db.dataDir, name)); err != nil {
+			return "", fmt.Errorf("failed to rename collection directory: %w", err)
+		}
+		fmt.Printf("Renamed collection %q to %q: collection names may only contain letters, digits, '.', '_' and '-'\n", dirName, name)
+		metadataPath = filepath.Join(db.dataDir, name, "metadata.json")
+	}
+
+	if storedName == name || db.config.ReadOnly {
+		return name, nil
+	}
+	metadata["name"], _ = json.Marshal(name)
+	data, err = json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(metadataPath, data); err != nil {
+		return "", fmt.Errorf("failed to rename collection in metadata: %w", err)
+	}
+	return name, nil
+}
+
+// collectionNameTaken reports whether a loaded collection or a directory of
+// the data directory already uses name, regardless of case; the caller holds mu
+func (db *VittoriaDB) collectionNameTaken(name string) bool {
+	for existing := range db.collections {
+		if strings.EqualFold(existing, name) {
+			return true
+		}
+	}
+	_, err := os.Lstat(filepath.Join(db.dataDir, name))
+	return err == nil
+}
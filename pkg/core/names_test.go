@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectionNameMigration(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	db := openTestDatabase(t, &Config{DataDir: dataDir})
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	if err := collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	db.Close()
+
+	if _, err := NewCollection("../escape", 2, DistanceMetricCosine, IndexTypeFlat, dataDir); err == nil {
+		t.Error("created a collection outside the data directory")
+	}
+
+	// A directory named before names were validated, with metadata naming a
+	// path outside the data directory
+	if err := os.Rename(filepath.Join(dataDir, "docs"), filepath.Join(dataDir, "my docs")); err != nil {
+		t.Fatal(err)
+	}
+	metadataPath := filepath.Join(dataDir, "my docs", "metadata.json")
+	data, _ := os.ReadFile(metadataPath)
+	data = bytes.Replace(data, []byte(`"name": "docs"`), []byte(`"name": "../evil"`), 1)
+	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = openTestDatabase(t, &Config{DataDir: dataDir})
+
+	collection, err := db.GetCollection(ctx, "my_docs")
+	if err != nil {
+		t.Fatalf("migrated collection: %v", err)
+	}
+	if collection.Name() != "my_docs" {
+		t.Errorf("Name() = %q, want my_docs", collection.Name())
+	}
+	if count, _ := collection.Count(); count != 1 {
+		t.Errorf("Count() = %d, want 1", count)
+	}
+	data, _ = os.ReadFile(filepath.Join(dataDir, "my_docs", "metadata.json"))
+	if !bytes.Contains(data, []byte(`"name": "my_docs"`)) {
+		t.Errorf("metadata was not renamed: %s", data)
+	}
+}
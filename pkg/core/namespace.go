@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// namespaceSeparator joins a namespace and a vector ID into the collection's
+// storage key. It cannot appear in namespaces or vector IDs, so keys from
+// different namespaces never collide.
+const namespaceSeparator = "\x1f"
+
+// namespacePattern restricts namespace names to URL- and header-safe identifiers
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// NamespaceInfo describes a namespace within a collection
+type NamespaceInfo struct {
+	Name        string `json:"name"`
+	VectorCount int64  `json:"vector_count"`
+}
+
+// ValidateNamespace checks that ns is a valid namespace name. The empty string
+// is the default namespace.
+func ValidateNamespace(ns string) error {
+	if ns == "" || namespacePattern.MatchString(ns) {
+		return nil
+	}
+	return fmt.Errorf("invalid namespace '%s': use up to 128 letters, digits, '_', '-' or '.'", ns)
+}
+
+// vectorKey returns the storage key of a vector ID within a namespace
+func vectorKey(ns, id string) string {
+	if ns == "" {
+		return id
+	}
+	return ns + namespaceSeparator + id
+}
+
+// key returns the storage key of the vector
+func (v *Vector) key() string {
+	return vectorKey(v.Namespace, v.ID)
+}
+
+// searchable reports whether a stored vector may appear in the results of req.
+// Vectors are only visible to searches in their own namespace, and records
+// without a vector to none.
+func searchable(vector *Vector, req *SearchRequest, now time.Time) bool {
+	return vector.hasVector() && vector.Namespace == req.Namespace && !isExpired(vector, now)
+}
+
+// validateNamespacedID validates a namespace and a vector ID used together
+func validateNamespacedID(ns, id string) error {
+	if err := ValidateNamespace(ns); err != nil {
+		return err
+	}
+	if strings.Contains(id, namespaceSeparator) {
+		return fmt.Errorf("vector ID cannot contain control character 0x1f")
+	}
+	return nil
+}
+
+// Namespaces lists the namespaces holding vectors, with their vector counts.
+// The default namespace is reported with an empty name.
+func (c *VittoriaCollection) Namespaces() ([]*NamespaceInfo, error) {
+	counts := make(map[string]int64)
+
+	if c.isSharded() {
+		c.shardMu.RLock()
+		for i, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				c.shardMu.RUnlock()
+				return nil, fmt.Errorf("shard %s: listing namespaces requires local shards", c.shardName(i))
+			}
+			namespaces, err := local.Namespaces()
+			if err != nil {
+				c.shardMu.RUnlock()
+				return nil, err
+			}
+			for _, ns := range namespaces {
+				counts[ns.Name] += ns.VectorCount
+			}
+		}
+		c.shardMu.RUnlock()
+	} else {
+		c.mu.RLock()
+		if c.closed {
+			c.mu.RUnlock()
+			return nil, errorf(ErrClosed, "collection is closed")
+		}
+		for _, vector := range c.vectors {
+			counts[vector.Namespace]++
+		}
+		c.mu.RUnlock()
+	}
+
+	namespaces := make([]*NamespaceInfo, 0, len(counts))
+	for name, count := range counts {
+		namespaces = append(namespaces, &NamespaceInfo{Name: name, VectorCount: count})
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+	return namespaces, nil
+}
+
+// DropNamespace deletes every vector in the namespace and returns how many were removed
+func (c *VittoriaCollection) DropNamespace(ctx context.Context, ns string) (int, error) {
+	if c.readOnly {
+		return 0, c.errReadOnly()
+	}
+	if ns == "" {
+		return 0, fmt.Errorf("the default namespace cannot be dropped")
+	}
+	if err := ValidateNamespace(ns); err != nil {
+		return 0, err
+	}
+
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		removed := 0
+		for i, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				return removed, fmt.Errorf("shard %s: dropping namespaces requires local shards", c.shardName(i))
+			}
+			n, err := local.DropNamespace(ctx, ns)
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+		}
+		return removed, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, errorf(ErrClosed, "collection is closed")
+	}
+
+	var keys []string
+	for key, vector := range c.vectors {
+		if vector.Namespace == ns {
+			keys = append(keys, key)
+		}
+	}
+	return c.deleteKeys(ctx, keys)
+}
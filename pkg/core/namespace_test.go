@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestNamespaces_Isolation(t *testing.T) {
+	for _, indexType := range []IndexType{IndexTypeFlat, IndexTypeHNSW} {
+		t.Run(indexType.String(), func(t *testing.T) {
+			ctx := context.Background()
+			rng := rand.New(rand.NewSource(7))
+
+			collection, err := NewCollection("tenants", 8, DistanceMetricCosine, indexType, t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create collection: %v", err)
+			}
+			if err := collection.Initialize(ctx); err != nil {
+				t.Fatalf("Failed to initialize collection: %v", err)
+			}
+
+			// Both tenants use the same IDs; tenant-b holds far more vectors
+			var vectors []*Vector
+			for _, tenant := range []struct {
+				name  string
+				count int
+			}{{"tenant-a", 5}, {"tenant-b", 300}} {
+				for i := 0; i < tenant.count; i++ {
+					v := make([]float32, 8)
+					for j := range v {
+						v[j] = rng.Float32()
+					}
+					vectors = append(vectors, &Vector{
+						ID:        fmt.Sprintf("doc%d", i),
+						Namespace: tenant.name,
+						Vector:    v,
+						Metadata:  map[string]interface{}{"tenant": tenant.name},
+					})
+				}
+			}
+			if err := collection.InsertBatch(ctx, vectors); err != nil {
+				t.Fatalf("Failed to insert vectors: %v", err)
+			}
+
+			count, _ := collection.Count()
+			if count != 305 {
+				t.Fatalf("Expected 305 vectors, got %d", count)
+			}
+
+			response, err := collection.Search(ctx, &SearchRequest{
+				Vector:          vectors[len(vectors)-1].Vector,
+				Limit:           10,
+				IncludeMetadata: true,
+				Namespace:       "tenant-a",
+			})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(response.Results) != 5 {
+				t.Errorf("Expected all 5 tenant-a vectors, got %d", len(response.Results))
+			}
+			for _, result := range response.Results {
+				if result.Metadata["tenant"] != "tenant-a" {
+					t.Errorf("Search leaked vector %s from %v", result.ID, result.Metadata["tenant"])
+				}
+			}
+
+			if _, err := collection.Get(ctx, "doc0"); err == nil {
+				t.Error("Expected doc0 to be absent from the default namespace")
+			}
+			if _, err := collection.GetInNamespace(ctx, "tenant-a", "doc100"); err == nil {
+				t.Error("Expected doc100 to be absent from tenant-a")
+			}
+
+			if err := collection.DeleteInNamespace(ctx, "tenant-a", "doc0"); err != nil {
+				t.Fatalf("Failed to delete vector: %v", err)
+			}
+			if _, err := collection.GetInNamespace(ctx, "tenant-b", "doc0"); err != nil {
+				t.Errorf("Deleting tenant-a/doc0 removed tenant-b/doc0: %v", err)
+			}
+
+			removed, err := collection.DropNamespace(ctx, "tenant-b")
+			if err != nil {
+				t.Fatalf("Failed to drop namespace: %v", err)
+			}
+			if removed != 300 {
+				t.Errorf("Expected 300 removed vectors, got %d", removed)
+			}
+
+			namespaces, err := collection.Namespaces()
+			if err != nil {
+				t.Fatalf("Failed to list namespaces: %v", err)
+			}
+			if len(namespaces) != 1 || namespaces[0].Name != "tenant-a" || namespaces[0].VectorCount != 4 {
+				t.Errorf("Unexpected namespaces after drop: %+v", namespaces[0])
+			}
+		})
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"runtime"
-	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +16,10 @@ type ParallelSearchConfig struct {
 	BatchSize      int  `json:"batch_size" yaml:"batch_size"`
 	UseCache       bool `json:"use_cache" yaml:"use_cache"`
 	PreloadVectors bool `json:"preload_vectors" yaml:"preload_vectors"`
+
+	// Collections with fewer vectors are scanned by one worker (0 takes
+	// MaxWorkers × BatchSize)
+	MinVectorsForParallel int `json:"min_vectors_for_parallel" yaml:"min_vectors_for_parallel"`
 }
 
 // DefaultParallelSearchConfig returns sensible defaults
@@ -31,11 +35,12 @@ func DefaultParallelSearchConfig() *ParallelSearchConfig {
 
 // ParallelSearchEngine provides enhanced search capabilities
 type ParallelSearchEngine struct {
-	collection *VittoriaCollection
-	cache      *SearchCache
-	config     *ParallelSearchConfig
-	stats      *ParallelSearchStats
-	mu         sync.RWMutex
+	collection  *VittoriaCollection
+	cache       *SearchCache // nil when UseCache is off
+	sharedCache *SearchCache // Database-wide cache, closed by the database (nil for standalone collections)
+	config      *ParallelSearchConfig
+	stats       *ParallelSearchStats
+	mu          sync.RWMutex
 }
 
 // ParallelSearchStats tracks search performance
@@ -77,13 +82,14 @@ func (pse *ParallelSearchEngine) Search(ctx context.Context, req *SearchRequest)
 	pse.mu.Unlock()
 
 	// Check cache first if enabled
+	cached, generation, found := pse.lookup(req)
+	if found {
+		pse.mu.Lock()
+		pse.stats.CacheHits++
+		pse.mu.Unlock()
+		return cached, nil
+	}
 	if pse.cache != nil {
-		if cached, found := pse.cache.Get(req); found {
-			pse.mu.Lock()
-			pse.stats.CacheHits++
-			pse.mu.Unlock()
-			return cached, nil
-		}
 		pse.mu.Lock()
 		pse.stats.CacheMisses++
 		pse.mu.Unlock()
@@ -93,7 +99,11 @@ func (pse *ParallelSearchEngine) Search(ctx context.Context, req *SearchRequest)
 	var response *SearchResponse
 	var err error
 
-	if pse.config.Enabled && pse.shouldUseParallelSearch(req) {
+	if pse.collection.isSharded() {
+		response, err = pse.collection.shardedSearch(ctx, req)
+	} else if pse.collection.indexReady() {
+		response, err = pse.collection.indexSearch(ctx, req)
+	} else if pse.config.Enabled && pse.shouldUseParallelSearch(req) {
 		response, err = pse.parallelSearch(ctx, req)
 		pse.mu.Lock()
 		pse.stats.ParallelSearches++
@@ -110,9 +120,7 @@ func (pse *ParallelSearchEngine) Search(ctx context.Context, req *SearchRequest)
 	}
 
 	// Cache the result if caching is enabled
-	if pse.cache != nil {
-		pse.cache.Set(req, response)
-	}
+	pse.store(generation, req, response)
 
 	// Update statistics
 	latency := time.Since(startTime)
@@ -149,168 +157,163 @@ func (pse *ParallelSearchEngine) SearchText(ctx context.Context, query string, l
 
 // parallelSearch performs search using multiple workers
 func (pse *ParallelSearchEngine) parallelSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
-	pse.collection.mu.RLock()
-	defer pse.collection.mu.RUnlock()
-
-	if pse.collection.closed {
-		return nil, fmt.Errorf("collection is closed")
-	}
-
-	startTime := time.Now()
-
-	// Validate search request
-	if err := pse.collection.validateSearchRequest(req); err != nil {
+	response, workers, err := pse.collection.scanSearch(ctx, req, pse.config)
+	if err != nil {
 		return nil, err
 	}
 
-	// Convert map to slice for parallel processing
-	vectors := make([]*Vector, 0, len(pse.collection.vectors))
-	for _, vector := range pse.collection.vectors {
-		vectors = append(vectors, vector)
-	}
-
-	// Determine number of workers and batch size
-	numWorkers := pse.config.MaxWorkers
-	if numWorkers > len(vectors) {
-		numWorkers = len(vectors)
-	}
-	if numWorkers <= 0 {
-		numWorkers = 1
-	}
-
-	batchSize := (len(vectors) + numWorkers - 1) / numWorkers
-	if batchSize < pse.config.BatchSize {
-		batchSize = pse.config.BatchSize
-	}
+	pse.mu.Lock()
+	pse.stats.WorkersUsed = workers
+	pse.mu.Unlock()
 
-	// Channel for collecting results
-	resultsChan := make(chan []*SearchResult, numWorkers)
-	var wg sync.WaitGroup
+	return response, nil
+}
 
-	// Launch workers
-	for i := 0; i < numWorkers; i++ {
-		start := i * batchSize
-		end := start + batchSize
-		if end > len(vectors) {
-			end = len(vectors)
-		}
+// shouldUseParallelSearch determines if parallel search should be used
+func (pse *ParallelSearchEngine) shouldUseParallelSearch(req *SearchRequest) bool {
+	pse.collection.mu.RLock()
+	vectorCount := len(pse.collection.vectors)
+	pse.collection.mu.RUnlock()
 
-		if start >= len(vectors) {
-			break
-		}
+	return pse.config.workers(vectorCount) > 1
+}
 
-		wg.Add(1)
-		go func(batch []*Vector) {
-			defer wg.Done()
+// workers returns how many workers scan n vectors: MaxWorkers from
+// MinVectorsForParallel vectors on (MaxWorkers × BatchSize when unset), one
+// below that or with parallel search disabled
+func (config *ParallelSearchConfig) workers(n int) int {
+	threshold := config.MinVectorsForParallel
+	if threshold <= 0 {
+		threshold = config.MaxWorkers * config.BatchSize
+	}
+	if !config.Enabled || config.MaxWorkers <= 1 || n < threshold {
+		return 1
+	}
+	return config.MaxWorkers
+}
 
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				results := pse.processBatch(req, batch)
-				resultsChan <- results
-			}
-		}(vectors[start:end])
+// parallelConfig returns the parallel search settings of the database, or
+// the defaults
+func (c *VittoriaCollection) parallelConfig() *ParallelSearchConfig {
+	if c.indexOptions.parallel != nil {
+		return c.indexOptions.parallel
 	}
+	return DefaultParallelSearchConfig()
+}
 
-	// Close results channel when all workers complete
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
+// scanSearch performs a brute-force search, spreading the scan over the
+// workers config allows, and returns the number of workers used
+func (c *VittoriaCollection) scanSearch(ctx context.Context, req *SearchRequest, config *ParallelSearchConfig) (*SearchResponse, int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	// Collect results from all workers
-	var allResults []*SearchResult
-	for results := range resultsChan {
-		allResults = append(allResults, results...)
+	if c.closed {
+		return nil, 0, errorf(ErrClosed, "collection is closed")
 	}
 
-	// Sort by score (descending)
-	sort.Slice(allResults, func(i, j int) bool {
-		return allResults[i].Score > allResults[j].Score
-	})
+	startTime := time.Now()
 
-	// Apply limit and offset
-	start := req.Offset
-	if start > len(allResults) {
-		start = len(allResults)
+	if err := c.validateSearchRequest(req); err != nil {
+		return nil, 0, err
 	}
 
-	end := start + req.Limit
-	if end > len(allResults) {
-		end = len(allResults)
+	workers := config.workers(len(c.vectors))
+	top, matched, err := c.scanTopK(ctx, req, req.Offset+req.Limit, workers, config.BatchSize)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	finalResults := allResults[start:end]
-	tookMS := time.Since(startTime).Milliseconds()
-
-	pse.mu.Lock()
-	pse.stats.WorkersUsed = numWorkers
-	pse.mu.Unlock()
-
 	return &SearchResponse{
-		Results: finalResults,
-		Total:   int64(len(allResults)),
-		TookMS:  tookMS,
-	}, nil
+		Results:   c.rankedResults(top[min(req.Offset, len(top)):], req),
+		Total:     int64(matched),
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+	}, workers, nil
 }
 
-// processBatch processes a batch of vectors for similarity search
-func (pse *ParallelSearchEngine) processBatch(req *SearchRequest, vectors []*Vector) []*SearchResult {
-	var results []*SearchResult
-
-	for _, vector := range vectors {
-		// Apply metadata filter if specified
-		if req.Filter != nil && !pse.collection.matchesFilter(vector.Metadata, req.Filter) {
-			continue
-		}
-
-		// Calculate similarity score
-		score := pse.collection.calculateSimilarity(req.Vector, vector.Vector)
-
-		result := &SearchResult{
-			ID:    vector.ID,
-			Score: score,
+// scanTopK scores every searchable vector that passes the filter and the
+// score threshold, returning the k best, best first, and how many matched.
+// Only the candidates of a range index are scanned when one applies. With
+// several workers, each scans batches of batchSize vectors into its own top
+// k, and those are merged at the end. The caller holds mu.
+func (c *VittoriaCollection) scanTopK(ctx context.Context, req *SearchRequest, k, workers, batchSize int) ([]scoredVector, int, error) {
+	now := time.Now()
+	scorer := c.newQueryScorer(req.Vector)
+	filter := req.searchFilter()
+	passes := func(vector *Vector) bool {
+		if !searchable(vector, req, now) {
+			return false
 		}
+		return filter == nil || c.matchesFilter(vector.Metadata, filter)
+	}
 
-		// Include vector if requested
-		if req.IncludeVector {
-			result.Vector = make([]float32, len(vector.Vector))
-			copy(result.Vector, vector.Vector)
+	if workers <= 1 {
+		batch := newScanBatch(scorer, req, k)
+		scanned := 0
+		var err error
+		c.scanVectors(filter, func(vector *Vector) bool {
+			scanned++
+			if scanned%progressChunkSize == 0 {
+				if err = ctx.Err(); err != nil {
+					return false
+				}
+			}
+			if passes(vector) {
+				batch.add(vector)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, 0, err
 		}
+		batch.flush()
+		return batch.top.sorted(), batch.matched, nil
+	}
 
-		// Include metadata if requested
-		if req.IncludeMetadata {
-			result.Metadata = make(map[string]interface{})
-			for k, v := range vector.Metadata {
-				result.Metadata[k] = v
-			}
+	vectors := c.filterCandidates(filter)
+	if vectors == nil {
+		vectors = make([]*Vector, 0, len(c.vectors))
+		for _, vector := range c.vectors {
+			vectors = append(vectors, vector)
 		}
+	}
+	batchSize = max(batchSize, 1)
+	workers = min(workers, (len(vectors)+batchSize-1)/batchSize)
 
-		// Include content if requested and content storage is enabled
-		if req.IncludeContent && pse.collection.contentStorage != nil && pse.collection.contentStorage.Enabled {
-			if content, exists := vector.Metadata[pse.collection.contentStorage.FieldName]; exists {
-				if contentStr, ok := content.(string); ok {
-					result.Content = contentStr
+	batches := make([]*scanBatch, workers)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := range workers {
+		batches[w] = newScanBatch(scorer, req, k)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer batches[w].flush()
+			for ctx.Err() == nil {
+				start := int(next.Add(1)-1) * batchSize
+				if start >= len(vectors) {
+					return
+				}
+				for _, vector := range vectors[start:min(start+batchSize, len(vectors))] {
+					if passes(vector) {
+						batches[w].add(vector)
+					}
 				}
 			}
-		}
-
-		results = append(results, result)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
 	}
 
-	return results
-}
-
-// shouldUseParallelSearch determines if parallel search should be used
-func (pse *ParallelSearchEngine) shouldUseParallelSearch(req *SearchRequest) bool {
-	// Use parallel search for larger datasets or when specifically beneficial
-	vectorCount := len(pse.collection.vectors)
-
-	// Use parallel search if we have enough vectors to benefit from parallelization
-	minVectorsForParallel := pse.config.MaxWorkers * pse.config.BatchSize
-
-	return vectorCount >= minVectorsForParallel
+	top := newTopK(k)
+	matched := 0
+	for _, batch := range batches {
+		top.merge(batch.top)
+		matched += batch.matched
+	}
+	return top.sorted(), matched, nil
 }
 
 // updateLatencyStats updates average latency statistics
@@ -334,16 +337,7 @@ func (pse *ParallelSearchEngine) GetStats() ParallelSearchStats {
 	pse.mu.RLock()
 	defer pse.mu.RUnlock()
 
-	stats := *pse.stats
-
-	// Add cache stats if available
-	if pse.cache != nil {
-		cacheStats := pse.cache.GetStats()
-		stats.CacheHits = cacheStats.Hits
-		stats.CacheMisses = cacheStats.Misses
-	}
-
-	return stats
+	return *pse.stats
 }
 
 // GetCacheStats returns cache statistics
@@ -356,10 +350,45 @@ func (pse *ParallelSearchEngine) GetCacheStats() *SearchCacheStats {
 	return &stats
 }
 
-// ClearCache clears the search cache
+// ClearCache drops the cached results of the collection
 func (pse *ParallelSearchEngine) ClearCache() {
 	if pse.cache != nil {
-		pse.cache.Clear()
+		pse.cache.Invalidate(pse.collection.name)
+	}
+}
+
+// lookup returns the cached response to req, if any, along with the write
+// generation of the collection to store a fresh response under. Read-only
+// searches bypass the cache, which only holds and returns copies.
+func (pse *ParallelSearchEngine) lookup(req *SearchRequest) (*SearchResponse, uint64, bool) {
+	generation := pse.collection.generation.Load()
+	if pse.cache == nil || req.ReadOnlyResults {
+		return nil, generation, false
+	}
+	response, found := pse.cache.get(pse.collection.name, generation, req)
+	return response, generation, found
+}
+
+// store caches the response to req, computed at the given write generation
+func (pse *ParallelSearchEngine) store(generation uint64, req *SearchRequest, response *SearchResponse) {
+	if pse.cache != nil && !req.ReadOnlyResults {
+		pse.cache.set(pse.collection.name, generation, req, response)
+	}
+}
+
+// useCache caches results in the database-wide cache instead of one of the
+// engine's own
+func (pse *ParallelSearchEngine) useCache(cache *SearchCache) {
+	pse.mu.Lock()
+	defer pse.mu.Unlock()
+
+	if pse.cache != nil && pse.cache != pse.sharedCache {
+		pse.cache.Close()
+	}
+	pse.sharedCache = cache
+	pse.cache = nil
+	if pse.config.UseCache {
+		pse.cache = cache
 	}
 }
 
@@ -372,16 +401,21 @@ func (pse *ParallelSearchEngine) UpdateConfig(config *ParallelSearchConfig) {
 
 	// Update cache if needed
 	if config.UseCache && pse.cache == nil {
-		pse.cache = NewSearchCache(DefaultSearchCacheConfig())
+		pse.cache = pse.sharedCache
+		if pse.cache == nil {
+			pse.cache = NewSearchCache(DefaultSearchCacheConfig())
+		}
 	} else if !config.UseCache && pse.cache != nil {
-		pse.cache.Close()
+		if pse.cache != pse.sharedCache {
+			pse.cache.Close()
+		}
 		pse.cache = nil
 	}
 }
 
 // Close cleans up resources
 func (pse *ParallelSearchEngine) Close() {
-	if pse.cache != nil {
+	if pse.cache != nil && pse.cache != pse.sharedCache {
 		pse.cache.Close()
 	}
 }
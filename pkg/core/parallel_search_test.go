@@ -3,6 +3,9 @@ package core
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -381,3 +384,299 @@ func abs(x float64) float64 {
 	}
 	return x
 }
+
+func TestSearchProgressive_MatchesSearch(t *testing.T) {
+	collection, err := NewCollection("progressive", 8, DistanceMetricCosine, IndexTypeFlat, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 3000; i++ {
+		vector := make([]float32, 8)
+		for j := range vector {
+			vector[j] = rng.Float32()
+		}
+		err := collection.Insert(ctx, &Vector{
+			ID:       fmt.Sprintf("v%d", i),
+			Vector:   vector,
+			Metadata: map[string]interface{}{"group": float64(i % 3)},
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert vector: %v", err)
+		}
+	}
+
+	req := &SearchRequest{
+		Vector:          []float32{1, 0, 1, 0, 1, 0, 1, 0},
+		Limit:           20,
+		Offset:          5,
+		Filter:          &Filter{Field: "group", Operator: FilterOpEq, Value: float64(1)},
+		IncludeMetadata: true,
+	}
+
+	want, err := collection.legacySearch(ctx, req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	got, err := collection.SearchProgressive(ctx, req, func(progress *SearchProgress) error {
+		if len(progress.Results) > req.Limit || progress.Scanned > progress.Total {
+			t.Errorf("Invalid snapshot: %d results, %d of %d scanned", len(progress.Results), progress.Scanned, progress.Total)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Progressive search failed: %v", err)
+	}
+
+	if got.Total != want.Total || len(got.Results) != len(want.Results) {
+		t.Fatalf("Expected %d of %d results, got %d of %d", len(want.Results), want.Total, len(got.Results), got.Total)
+	}
+	for i := range want.Results {
+		if got.Results[i].ID != want.Results[i].ID || got.Results[i].Score != want.Results[i].Score {
+			t.Errorf("Result %d: expected %s (%.4f), got %s (%.4f)", i,
+				want.Results[i].ID, want.Results[i].Score, got.Results[i].ID, got.Results[i].Score)
+		}
+	}
+}
+
+// newRandomFlatCollection returns a flat collection of n random vectors
+func newRandomFlatCollection(tb testing.TB, n, dimensions int) *VittoriaCollection {
+	tb.Helper()
+	collection, err := NewCollection("scan", dimensions, DistanceMetricCosine, IndexTypeFlat, tb.TempDir())
+	if err != nil {
+		tb.Fatalf("Failed to create collection: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(7))
+	vectors := make([]*Vector, n)
+	for i := range vectors {
+		vector := make([]float32, dimensions)
+		for j := range vector {
+			vector[j] = rng.Float32()
+		}
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%d", i), Vector: vector, Metadata: map[string]interface{}{"group": float64(i % 4)}}
+	}
+	if err := collection.InsertBatch(context.Background(), vectors); err != nil {
+		tb.Fatalf("Failed to insert batch: %v", err)
+	}
+	return collection
+}
+
+func TestScanSearch_ParallelMatchesSerial(t *testing.T) {
+	collection := newRandomFlatCollection(t, 5000, 16)
+	ctx := context.Background()
+	serial := &ParallelSearchConfig{Enabled: false, MaxWorkers: 1, BatchSize: 100}
+	parallel := &ParallelSearchConfig{Enabled: true, MaxWorkers: 4, BatchSize: 64, MinVectorsForParallel: 1000}
+
+	for _, req := range []*SearchRequest{
+		{Vector: make([]float32, 16), Limit: 10},
+		{Vector: []float32{1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0}, Limit: 25, Offset: 7, Filter: &Filter{Field: "group", Operator: FilterOpEq, Value: float64(2)}},
+		{Vector: []float32{0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1, 0, 1}, Limit: 6000},
+	} {
+		req.Vector[0] += 0.5 // The zero vector has no cosine similarity
+		want, workers, err := collection.scanSearch(ctx, req, serial)
+		if err != nil || workers != 1 {
+			t.Fatalf("serial scan: %d workers, err %v", workers, err)
+		}
+		got, workers, err := collection.scanSearch(ctx, req, parallel)
+		if err != nil || workers != 4 {
+			t.Fatalf("parallel scan: %d workers, err %v", workers, err)
+		}
+
+		if got.Total != want.Total || len(got.Results) != len(want.Results) {
+			t.Fatalf("expected %d of %d results, got %d of %d", len(want.Results), want.Total, len(got.Results), got.Total)
+		}
+		for i := range want.Results {
+			if got.Results[i].ID != want.Results[i].ID || got.Results[i].Score != want.Results[i].Score {
+				t.Fatalf("result %d: expected %s (%.4f), got %s (%.4f)", i, want.Results[i].ID, want.Results[i].Score, got.Results[i].ID, got.Results[i].Score)
+			}
+			if i > 0 && resultLess(got.Results[i], got.Results[i-1]) {
+				t.Fatalf("results %d and %d are out of order", i-1, i)
+			}
+		}
+	}
+
+	if workers := parallel.workers(999); workers != 1 {
+		t.Errorf("%d workers below min_vectors_for_parallel, want 1", workers)
+	}
+}
+
+func TestScanSearch_KernelsMatchScalar(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(3))
+	random := func() []float32 {
+		vector := make([]float32, 37)
+		for i := range vector {
+			vector[i] = rng.Float32()*2 - 1
+		}
+		return vector
+	}
+	config := &ParallelSearchConfig{Enabled: false, MaxWorkers: 1, BatchSize: 100}
+
+	for _, metric := range []DistanceMetric{DistanceMetricCosine, DistanceMetricEuclidean, DistanceMetricDotProduct} {
+		collection, err := NewCollection("kernels", 37, metric, IndexTypeFlat, t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		vectors := make([]*Vector, 1000)
+		for i := range vectors {
+			vectors[i] = &Vector{ID: fmt.Sprintf("v%d", i), Vector: random()}
+		}
+		if err := collection.InsertBatch(ctx, vectors); err != nil {
+			t.Fatalf("Failed to insert batch: %v", err)
+		}
+
+		req := &SearchRequest{Vector: random(), Limit: 300}
+		want, _, err := collection.scanSearch(ctx, req, config)
+		if err != nil {
+			t.Fatalf("scalar scan: %v", err)
+		}
+		collection.indexOptions.kernels = newSearchKernels(true)
+		got, _, err := collection.scanSearch(ctx, req, config)
+		if err != nil {
+			t.Fatalf("kernel scan: %v", err)
+		}
+
+		if got.Total != want.Total || len(got.Results) != len(want.Results) {
+			t.Fatalf("%s: expected %d of %d results, got %d of %d", metric, len(want.Results), want.Total, len(got.Results), got.Total)
+		}
+		for i := range want.Results {
+			if diff := math.Abs(float64(got.Results[i].Score - want.Results[i].Score)); diff > 1e-5 {
+				t.Fatalf("%s: result %d scored %v by the kernels, %v by the scalar loops", metric, i, got.Results[i].Score, want.Results[i].Score)
+			}
+		}
+	}
+}
+
+// BenchmarkScanSearch compares a serial brute-force scan with the parallel
+// one: go test ./pkg/core -run '^$' -bench ScanSearch
+func BenchmarkScanSearch(b *testing.B) {
+	collection := newRandomFlatCollection(b, 50000, 128)
+	req := &SearchRequest{Vector: collection.vectors["v0"].Vector, Limit: 10}
+
+	for _, workers := range []int{1, 2, 4, 8, 16} {
+		config := &ParallelSearchConfig{Enabled: true, MaxWorkers: workers, BatchSize: 1000, MinVectorsForParallel: 1}
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			if workers > runtime.NumCPU() {
+				b.Skipf("only %d CPUs", runtime.NumCPU())
+			}
+			for b.Loop() {
+				if _, _, err := collection.scanSearch(context.Background(), req, config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkScanKernels compares a serial scan scored by the scalar loops with
+// one scored by the SIMD kernels: go test ./pkg/core -run '^$' -bench ScanKernels
+func BenchmarkScanKernels(b *testing.B) {
+	collection := newRandomFlatCollection(b, 50000, 384)
+	req := &SearchRequest{Vector: collection.vectors["v0"].Vector, Limit: 10}
+	config := &ParallelSearchConfig{Enabled: false, MaxWorkers: 1, BatchSize: 1000}
+
+	for _, simd := range []bool{false, true} {
+		b.Run(fmt.Sprintf("simd=%t", simd), func(b *testing.B) {
+			collection.indexOptions.kernels = newSearchKernels(simd)
+			for b.Loop() {
+				if _, _, err := collection.scanSearch(context.Background(), req, config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRangeIndex compares a selective filtered search scanning the
+// whole collection with one taking its candidates from a range index:
+// go test ./pkg/core -run '^$' -bench RangeIndex
+func BenchmarkRangeIndex(b *testing.B) {
+	collection := newRandomFlatCollection(b, 50000, 128)
+	rank := 0
+	for _, vector := range collection.vectors {
+		vector.Metadata["rank"] = float64(rank)
+		rank++
+	}
+	req := &SearchRequest{Vector: collection.vectors["v0"].Vector, Limit: 10, Filter: &Filter{Field: "rank", Operator: FilterOpLt, Value: 500}}
+	config := &ParallelSearchConfig{Enabled: false, MaxWorkers: 1, BatchSize: 1000}
+
+	for _, indexed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("indexed=%t", indexed), func(b *testing.B) {
+			if indexed {
+				collection.setIndexedFields([]string{"rank"})
+			}
+			for b.Loop() {
+				if _, _, err := collection.scanSearch(context.Background(), req, config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkTopK guards the cost of keeping the best k of n scores, which
+// must grow with n log k rather than with n log n or n*k:
+// go test ./pkg/core -run '^$' -bench TopK
+func BenchmarkTopK(b *testing.B) {
+	rng := rand.New(rand.NewSource(7))
+	vectors := make([]*Vector, 200000)
+	scores := make([]float32, len(vectors))
+	for i := range vectors {
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%d", i)}
+		scores[i] = rng.Float32()
+	}
+
+	for _, k := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("k=%d", k), func(b *testing.B) {
+			for b.Loop() {
+				top := newTopK(k)
+				for i, vector := range vectors {
+					top.offer(vector, scores[i])
+				}
+				top.sorted()
+			}
+		})
+	}
+}
+
+// BenchmarkProgressiveScan measures the progressive brute-force scan with
+// small and large pages: go test ./pkg/core -run '^$' -bench ProgressiveScan
+func BenchmarkProgressiveScan(b *testing.B) {
+	collection := newRandomFlatCollection(b, 50000, 128)
+
+	for _, limit := range []int{10, 1000} {
+		req := &SearchRequest{Vector: collection.vectors["v0"].Vector, Limit: limit}
+		b.Run(fmt.Sprintf("limit=%d", limit), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := collection.progressiveScan(context.Background(), req, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReadOnlyResults compares building search results that copy the
+// stored vectors and metadata with building read-only views of them:
+// go test ./pkg/core -run '^$' -bench ReadOnlyResults -benchmem
+func BenchmarkReadOnlyResults(b *testing.B) {
+	collection := newRandomFlatCollection(b, 1000, 384)
+	top := newTopK(100)
+	for _, vector := range collection.vectors {
+		top.offer(vector, 0)
+	}
+	items := top.sorted()
+
+	for _, readOnly := range []bool{false, true} {
+		req := &SearchRequest{Limit: 100, IncludeVector: true, IncludeMetadata: true, ReadOnlyResults: readOnly}
+		b.Run(fmt.Sprintf("read_only=%t", readOnly), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				collection.rankedResults(items, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,794 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// A Parquet dataset is a gzip-compressed tar archive of Parquet files laid
+// out in Hive-style partitions (namespace=tenant/part-00000.parquet) under a
+// directory named after the collection, with a manifest describing the
+// collection. Metadata keys become typed columns, so DuckDB or Spark read the
+// extracted directory as one table, for example
+// read_parquet('docs/**/*.parquet', hive_partitioning = true).
+
+// ParquetManifestFile is the name of the manifest in the dataset directory
+const ParquetManifestFile = "_vittoriadb.json"
+
+// ParquetDefaultPartition names the partition of records without a value, as
+// Hive does for null
+const ParquetDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// Partitionings of a Parquet dataset besides a metadata key
+const (
+	ParquetPartitionNamespace = "namespace" // One partition per namespace, the default
+	ParquetPartitionNone      = "none"      // All files in the dataset directory
+)
+
+// Types of the metadata columns of a Parquet dataset
+const (
+	ParquetTypeBoolean = "boolean"
+	ParquetTypeInt64   = "int64"
+	ParquetTypeDouble  = "double"
+	ParquetTypeString  = "string"
+	ParquetTypeJSON    = "json" // Lists, objects and keys whose values differ in type, as JSON text
+)
+
+// defaultParquetFileRows is how many records a dataset file holds by default
+const defaultParquetFileRows = 100000
+
+// maxParquetColumns bounds the metadata columns of a dataset
+const maxParquetColumns = 4096
+
+// ParquetExportRequest selects the records and the layout of a Parquet
+// dataset
+type ParquetExportRequest struct {
+	PartitionBy    string  `json:"partition_by,omitempty"`      // namespace (default), none, or a metadata key
+	MaxRowsPerFile int     `json:"max_rows_per_file,omitempty"` // Records per file; 0 uses 100000
+	Namespace      *string `json:"-"`                           // Only this namespace; nil exports every namespace
+}
+
+// ParquetColumn maps a metadata key to a column of a Parquet dataset
+type ParquetColumn struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Type string `json:"type"`
+}
+
+// ParquetManifest describes a Parquet dataset: the collection it was
+// exported from, how it is partitioned and how metadata maps to columns
+type ParquetManifest struct {
+	Collection  string         `json:"collection"`
+	Dimensions  int            `json:"dimensions"`
+	Metric      DistanceMetric `json:"metric"`
+	IndexType   IndexType      `json:"index_type"`
+	PartitionBy string         `json:"partition_by"`
+	// PartitionType is the type of the metadata key partitioned by, whose
+	// values are parsed back from the directory names on import
+	PartitionType string          `json:"partition_type,omitempty"`
+	Columns       []ParquetColumn `json:"columns"`
+	Records       int             `json:"records"`
+	Files         []string        `json:"files"`
+	Exported      time.Time       `json:"exported"`
+}
+
+// ExportParquet writes the records of the collection to w as a Parquet
+// dataset and returns its manifest
+func (c *VittoriaCollection) ExportParquet(ctx context.Context, w io.Writer, req *ParquetExportRequest) (*ParquetManifest, error) {
+	rowsPerFile := req.MaxRowsPerFile
+	if rowsPerFile == 0 {
+		rowsPerFile = defaultParquetFileRows
+	}
+	if rowsPerFile < 0 {
+		return nil, fmt.Errorf("max_rows_per_file cannot be negative")
+	}
+	partitionBy := req.PartitionBy
+	if partitionBy == "" {
+		partitionBy = ParquetPartitionNamespace
+	}
+	if partitionBy == "id" || partitionBy == "vector" {
+		return nil, fmt.Errorf("cannot partition by '%s': use namespace, none or a metadata key", partitionBy)
+	}
+
+	records, err := c.ExportRecords(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	info, err := c.Info()
+	if err != nil {
+		return nil, err
+	}
+
+	types := metadataTypes(records)
+	manifest := &ParquetManifest{
+		Collection:  c.name,
+		Dimensions:  info.Dimensions,
+		Metric:      info.Metric,
+		IndexType:   info.IndexType,
+		PartitionBy: partitionBy,
+		Records:     len(records),
+		Exported:    time.Now().UTC(),
+	}
+	if partitionBy != ParquetPartitionNamespace && partitionBy != ParquetPartitionNone {
+		manifest.PartitionType = types[partitionBy]
+		delete(types, partitionBy)
+	}
+	manifest.Columns = parquetColumns(types, partitionBy)
+	if len(manifest.Columns) > maxParquetColumns {
+		return nil, fmt.Errorf("records have %d metadata keys; at most %d fit in a Parquet dataset", len(manifest.Columns), maxParquetColumns)
+	}
+
+	partitions, err := partitionRecords(records, partitionBy)
+	if err != nil {
+		return nil, err
+	}
+	layout := newParquetLayout(manifest)
+
+	// Files are encoded before the manifest is written, which lists them
+	type dataFile struct {
+		name string
+		data []byte
+	}
+	var files []dataFile
+	for _, partition := range partitions {
+		for start, part := 0, 0; start < len(partition.records); start, part = start+rowsPerFile, part+1 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			end := min(start+rowsPerFile, len(partition.records))
+			data, err := layout.encode(partition.records[start:end])
+			if err != nil {
+				return nil, fmt.Errorf("failed to write Parquet file: %w", err)
+			}
+			name := fmt.Sprintf("part-%05d.parquet", part)
+			if partition.dir != "" {
+				name = partition.dir + "/" + name
+			}
+			files = append(files, dataFile{name: name, data: data})
+			manifest.Files = append(manifest.Files, name)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, path.Join(c.name, ParquetManifestFile), manifestData); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if err := writeTarFile(tw, path.Join(c.name, file.name), file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write Parquet dataset: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write Parquet dataset: %w", err)
+	}
+	return manifest, nil
+}
+
+// writeTarFile adds a file holding data to tw
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write Parquet dataset: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write Parquet dataset: %w", err)
+	}
+	return nil
+}
+
+// metadataTypes infers the column type of every metadata key of records
+func metadataTypes(records []*Vector) map[string]string {
+	types := make(map[string]string)
+	for _, record := range records {
+		for key, value := range record.Metadata {
+			valueType := parquetType(value)
+			if previous, seen := types[key]; seen && previous != valueType {
+				if (previous == ParquetTypeInt64 || previous == ParquetTypeDouble) &&
+					(valueType == ParquetTypeInt64 || valueType == ParquetTypeDouble) {
+					valueType = ParquetTypeDouble
+				} else {
+					valueType = ParquetTypeJSON
+				}
+			}
+			types[key] = valueType
+		}
+	}
+	return types
+}
+
+// parquetColumns names the columns of metadata keys of the given types,
+// sorted by key
+func parquetColumns(types map[string]string, partitionBy string) []ParquetColumn {
+	keys := make([]string, 0, len(types))
+	for key := range types {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Keys clashing with the record columns are prefixed
+	taken := map[string]bool{"id": true, "namespace": true, "vector": true, partitionBy: true}
+	columns := make([]ParquetColumn, len(keys))
+	for i, key := range keys {
+		name := key
+		for taken[name] {
+			name = "meta_" + name
+		}
+		taken[name] = true
+		columns[i] = ParquetColumn{Name: name, Key: key, Type: types[key]}
+	}
+	return columns
+}
+
+// parquetType returns the column type holding a metadata value
+func parquetType(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		return ParquetTypeBoolean
+	case string:
+		return ParquetTypeString
+	case int, int32, int64:
+		return ParquetTypeInt64
+	case float32:
+		return parquetFloatType(float64(v))
+	case float64:
+		return parquetFloatType(v)
+	default:
+		return ParquetTypeJSON
+	}
+}
+
+// parquetFloatType returns int64 for whole numbers, which JSON decodes as
+// floats
+func parquetFloatType(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return ParquetTypeInt64
+	}
+	return ParquetTypeDouble
+}
+
+// parquetPartition is the records of one partition directory
+type parquetPartition struct {
+	dir     string // Relative to the dataset directory; empty when unpartitioned
+	records []*Vector
+}
+
+// partitionRecords groups records by the partition they are written to,
+// sorted by directory and keeping their order within each
+func partitionRecords(records []*Vector, partitionBy string) ([]*parquetPartition, error) {
+	if partitionBy == ParquetPartitionNone {
+		return []*parquetPartition{{records: records}}, nil
+	}
+
+	byDir := make(map[string]*parquetPartition)
+	var partitions []*parquetPartition
+	for _, record := range records {
+		var value string
+		if partitionBy == ParquetPartitionNamespace {
+			value = record.Namespace
+		} else {
+			var err error
+			if value, err = partitionValue(record.Metadata[partitionBy]); err != nil {
+				return nil, fmt.Errorf("cannot partition by '%s': record %s: %w", partitionBy, record.ID, err)
+			}
+		}
+		if value == "" {
+			value = ParquetDefaultPartition
+		} else {
+			value = escapePartitionValue(value)
+		}
+
+		dir := escapePartitionValue(partitionBy) + "=" + value
+		partition, exists := byDir[dir]
+		if !exists {
+			partition = &parquetPartition{dir: dir}
+			byDir[dir] = partition
+			partitions = append(partitions, partition)
+		}
+		partition.records = append(partition.records, record)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].dir < partitions[j].dir })
+	return partitions, nil
+}
+
+// partitionValue formats a metadata value as a partition directory value;
+// missing values give an empty string
+func partitionValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("partition values must be strings, numbers or booleans, got %T", value)
+	}
+}
+
+// escapePartitionValue percent-encodes the bytes of a partition directory
+// name that paths or Hive partitioning give a meaning to
+func escapePartitionValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.,+@ ", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// parquetLayout is the schema of the files of a dataset and the position of
+// every column in it
+type parquetLayout struct {
+	schema    *parquet.Schema
+	columns   []string                 // Column names by leaf index
+	metadata  map[string]ParquetColumn // Metadata columns by name
+	namespace bool                     // Whether files hold a namespace column
+}
+
+// newParquetLayout builds the file schema of a dataset
+func newParquetLayout(manifest *ParquetManifest) *parquetLayout {
+	group := parquet.Group{
+		"id":     parquet.String(),
+		"vector": parquet.Optional(parquet.List(parquet.Leaf(parquet.FloatType))),
+	}
+	layout := &parquetLayout{metadata: make(map[string]ParquetColumn, len(manifest.Columns))}
+	if manifest.PartitionBy != ParquetPartitionNamespace {
+		group["namespace"] = parquet.String()
+		layout.namespace = true
+	}
+	for _, column := range manifest.Columns {
+		var node parquet.Node
+		switch column.Type {
+		case ParquetTypeBoolean:
+			node = parquet.Leaf(parquet.BooleanType)
+		case ParquetTypeInt64:
+			node = parquet.Int(64)
+		case ParquetTypeDouble:
+			node = parquet.Leaf(parquet.DoubleType)
+		case ParquetTypeString:
+			node = parquet.String()
+		default:
+			node = parquet.JSON()
+		}
+		group[column.Name] = parquet.Optional(node)
+		layout.metadata[column.Name] = column
+	}
+
+	layout.schema = parquet.NewSchema("vittoriadb", group)
+	for _, path := range layout.schema.Columns() {
+		layout.columns = append(layout.columns, path[0])
+	}
+	return layout
+}
+
+// encode writes records as one Parquet file
+func (l *parquetLayout) encode(records []*Vector) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := parquet.NewWriter(&buf, l.schema, parquet.Compression(&parquet.Zstd))
+	rows := make([]parquet.Row, 0, len(records))
+	for _, record := range records {
+		row, err := l.row(record)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if _, err := writer.WriteRows(rows); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// row returns the values of a record, in the order of the leaf columns
+func (l *parquetLayout) row(record *Vector) (parquet.Row, error) {
+	row := make(parquet.Row, 0, len(l.columns)+len(record.Vector))
+	for i, name := range l.columns {
+		switch name {
+		case "id":
+			row = append(row, parquet.ValueOf(record.ID).Level(0, 0, i))
+		case "namespace":
+			row = append(row, parquet.ValueOf(record.Namespace).Level(0, 0, i))
+		case "vector":
+			// Levels: 0 is a null list, as for metadata-only records, and 2
+			// an element present
+			if !record.hasVector() {
+				row = append(row, parquet.Value{}.Level(0, 0, i))
+			}
+			for j, f := range record.Vector {
+				row = append(row, parquet.ValueOf(f).Level(min(j, 1), 2, i))
+			}
+		default:
+			column := l.metadata[name]
+			value, err := parquetValue(record.Metadata[column.Key], column.Type)
+			if err != nil {
+				return nil, fmt.Errorf("record %s: metadata key '%s': %w", record.ID, column.Key, err)
+			}
+			if value.IsNull() {
+				row = append(row, value.Level(0, 0, i))
+			} else {
+				row = append(row, value.Level(0, 1, i))
+			}
+		}
+	}
+	return row, nil
+}
+
+// parquetValue converts a metadata value to a value of a column type; nil
+// gives a null value
+func parquetValue(value interface{}, columnType string) (parquet.Value, error) {
+	if value == nil {
+		return parquet.Value{}, nil
+	}
+	switch columnType {
+	case ParquetTypeInt64:
+		if f, isNumber := filterNumber(value); isNumber {
+			return parquet.ValueOf(int64(f)), nil
+		}
+	case ParquetTypeDouble:
+		if f, isNumber := filterNumber(value); isNumber {
+			return parquet.ValueOf(f), nil
+		}
+	case ParquetTypeBoolean, ParquetTypeString:
+		return parquet.ValueOf(value), nil
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.ValueOf(string(data)), nil
+	}
+	return parquet.Value{}, fmt.Errorf("value %v does not fit a %s column", value, columnType)
+}
+
+// ParquetDatasetReader reads the records of a Parquet dataset archive one
+// file at a time
+type ParquetDatasetReader struct {
+	gz       *gzip.Reader
+	tr       *tar.Reader
+	manifest *ParquetManifest
+	pending  *tar.Header // Entry read while looking for the manifest
+}
+
+// NewParquetDatasetReader starts reading a Parquet dataset archive. Archives
+// without a manifest, such as repacked datasets, are read by column name.
+func NewParquetDatasetReader(r io.Reader) (*ParquetDatasetReader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a Parquet dataset archive: %w", err)
+	}
+	reader := &ParquetDatasetReader{gz: gz, tr: tar.NewReader(gz)}
+
+	header, err := reader.tr.Next()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("not a Parquet dataset archive: %w", err)
+	}
+	if header != nil && path.Base(header.Name) == ParquetManifestFile {
+		var manifest ParquetManifest
+		if err := json.NewDecoder(reader.tr).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("invalid dataset manifest: %w", err)
+		}
+		reader.manifest = &manifest
+	} else {
+		reader.pending = header
+	}
+	return reader, nil
+}
+
+// Manifest returns the manifest of the dataset, or nil when it has none
+func (r *ParquetDatasetReader) Manifest() *ParquetManifest {
+	return r.manifest
+}
+
+// Next returns the records of the next Parquet file of the archive, or
+// io.EOF after the last one
+func (r *ParquetDatasetReader) Next() ([]*Vector, error) {
+	for {
+		header := r.pending
+		r.pending = nil
+		if header == nil {
+			var err error
+			if header, err = r.tr.Next(); err != nil {
+				if err != io.EOF {
+					err = fmt.Errorf("failed to read Parquet dataset: %w", err)
+				}
+				return nil, err
+			}
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ".parquet") {
+			continue
+		}
+
+		data, err := io.ReadAll(r.tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		partitions, err := r.partitions(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		records, err := ReadParquetRecords(data, partitions, r.manifest)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		return records, nil
+	}
+}
+
+// partitions returns the key=value directories of a file's path, unescaped
+func (r *ParquetDatasetReader) partitions(name string) (map[string]string, error) {
+	partitions := make(map[string]string)
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		key, value, ok := strings.Cut(dir, "=")
+		if !ok {
+			continue
+		}
+		var err error
+		if key, err = url.PathUnescape(key); err == nil {
+			value, err = url.PathUnescape(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid partition directory '%s'", name, dir)
+		}
+		partitions[key] = value
+	}
+	return partitions, nil
+}
+
+// Close releases the reader
+func (r *ParquetDatasetReader) Close() error {
+	return r.gz.Close()
+}
+
+// ReadParquetRecords decodes the records of a Parquet file: an id column, and
+// optionally namespace, vector (a list of floats), metadata (JSON text, as
+// written by exports in the parquet format) and any other columns, which
+// become metadata keys. partitions holds values of the file's partition
+// directories, and manifest, when not nil, maps columns and partitions back
+// to metadata keys of their original types.
+func ReadParquetRecords(data []byte, partitions map[string]string, manifest *ParquetManifest) ([]*Vector, error) {
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a Parquet file: %w", err)
+	}
+
+	keys := make(map[string]ParquetColumn)
+	if manifest != nil {
+		for _, column := range manifest.Columns {
+			keys[column.Name] = column
+		}
+	}
+
+	// Leaf columns by index: their name, and whether they hold JSON text or
+	// a list
+	schema := file.Schema()
+	type leaf struct {
+		name string
+		json bool
+		list bool
+	}
+	leaves := make([]leaf, 0, len(schema.Columns()))
+	idColumn := false
+	for _, columnPath := range schema.Columns() {
+		column, _ := schema.Lookup(columnPath...)
+		lt := column.Node.Type().LogicalType()
+		l := leaf{name: columnPath[0], json: lt != nil && lt.Json != nil, list: column.MaxRepetitionLevel > 0}
+		if len(columnPath) > 1 && !l.list || column.MaxRepetitionLevel > 1 {
+			return nil, fmt.Errorf("column '%s' is nested: only scalar and list columns are supported", strings.Join(columnPath, "."))
+		}
+		idColumn = idColumn || l.name == "id"
+		leaves = append(leaves, l)
+	}
+	if !idColumn {
+		return nil, fmt.Errorf("no id column")
+	}
+
+	// Partition values are the same for every record of the file
+	partitionMetadata := make(map[string]interface{})
+	partitionNamespace := ""
+	for key, value := range partitions {
+		if value == ParquetDefaultPartition {
+			continue
+		}
+		if key == ParquetPartitionNamespace {
+			partitionNamespace = value
+			continue
+		}
+		partitionMetadata[key] = typedPartitionValue(value, manifest, key)
+	}
+
+	var records []*Vector
+	buffer := make([]parquet.Row, 256)
+	for _, rowGroup := range file.RowGroups() {
+		rows := rowGroup.Rows()
+		for {
+			n, err := rows.ReadRows(buffer)
+			for _, row := range buffer[:n] {
+				record := &Vector{Namespace: partitionNamespace, Metadata: make(map[string]interface{}, len(leaves)+len(partitionMetadata))}
+				for key, value := range partitionMetadata {
+					record.Metadata[key] = value
+				}
+				var invalid error
+				row.Range(func(column int, values []parquet.Value) bool {
+					invalid = decodeParquetColumn(record, leaves[column].name, leaves[column].json, leaves[column].list, values, keys)
+					return invalid == nil
+				})
+				if invalid != nil {
+					rows.Close()
+					return nil, fmt.Errorf("record '%s': %w", record.ID, invalid)
+				}
+				if record.ID == "" {
+					rows.Close()
+					return nil, fmt.Errorf("a record has no id")
+				}
+				records = append(records, record)
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+		}
+		rows.Close()
+	}
+	return records, nil
+}
+
+// decodeParquetColumn sets the field of record held by the values of one
+// column
+func decodeParquetColumn(record *Vector, name string, isJSON, isList bool, values []parquet.Value, keys map[string]ParquetColumn) error {
+	switch name {
+	case "id":
+		record.ID = parquetText(values[0])
+		return nil
+	case "namespace":
+		record.Namespace = parquetText(values[0])
+		return nil
+	case "vector":
+		for _, value := range values {
+			switch value.Kind() {
+			case parquet.Float:
+				record.Vector = append(record.Vector, value.Float())
+			case parquet.Double:
+				record.Vector = append(record.Vector, float32(value.Double()))
+			default:
+				if !value.IsNull() {
+					return fmt.Errorf("vector values are %s, not floats", value.Kind())
+				}
+			}
+		}
+		return nil
+	case "metadata":
+		if text := parquetText(values[0]); text != "" {
+			var metadata map[string]interface{}
+			if err := json.Unmarshal([]byte(text), &metadata); err != nil {
+				return fmt.Errorf("invalid metadata: %w", err)
+			}
+			for key, value := range metadata {
+				record.Metadata[key] = value
+			}
+		}
+		return nil
+	}
+
+	key := name
+	if column, mapped := keys[name]; mapped {
+		key = column.Key
+	}
+	if isList {
+		if values[0].IsNull() && values[0].DefinitionLevel() == 0 {
+			return nil
+		}
+		list := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			if !value.IsNull() {
+				list = append(list, parquetScalar(value))
+			}
+		}
+		record.Metadata[key] = list
+		return nil
+	}
+	if value := parquetScalar(values[0]); value != nil {
+		if text, ok := value.(string); ok && isJSON {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+				return fmt.Errorf("column '%s' holds invalid JSON: %w", name, err)
+			}
+			value = decoded
+		}
+		record.Metadata[key] = value
+	}
+	return nil
+}
+
+// parquetScalar converts a value to the type JSON decodes it as: numbers are
+// float64. Null values give nil.
+func parquetScalar(value parquet.Value) interface{} {
+	switch value.Kind() {
+	case parquet.Boolean:
+		return value.Boolean()
+	case parquet.Int32:
+		return float64(value.Int32())
+	case parquet.Int64:
+		return float64(value.Int64())
+	case parquet.Float:
+		return float64(value.Float())
+	case parquet.Double:
+		return value.Double()
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(value.ByteArray())
+	}
+	if value.IsNull() {
+		return nil
+	}
+	return value.String()
+}
+
+// parquetText returns a value as text, for the id and namespace columns
+func parquetText(value parquet.Value) string {
+	switch {
+	case value.IsNull():
+		return ""
+	case value.Kind() == parquet.ByteArray || value.Kind() == parquet.FixedLenByteArray:
+		return string(value.ByteArray())
+	default:
+		return value.String()
+	}
+}
+
+// typedPartitionValue converts the value of a partition directory back to
+// the type of its metadata key recorded in the manifest; without one, or
+// for keys of mixed types, values stay text
+func typedPartitionValue(value string, manifest *ParquetManifest, key string) interface{} {
+	if manifest == nil || manifest.PartitionBy != key {
+		return value
+	}
+	switch manifest.PartitionType {
+	case ParquetTypeBoolean:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case ParquetTypeInt64, ParquetTypeDouble:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParquetDatasetRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	vc := collection.(*VittoriaCollection)
+	records := []*Vector{
+		{ID: "a", Vector: []float32{1, 2}, Metadata: map[string]interface{}{"year": float64(2020), "title": "first/draft=1", "tags": []interface{}{"x"}}},
+		{ID: "b", Namespace: "tenant", Vector: []float32{3, 4}, Metadata: map[string]interface{}{"year": float64(2021), "score": 0.5, "id": "clash"}},
+		{ID: "c", Metadata: map[string]interface{}{"year": float64(2020), "draft": true}},
+	}
+	if err := vc.InsertBatch(ctx, records); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	for _, partitionBy := range []string{"", "year", "title", ParquetPartitionNone} {
+		var buf bytes.Buffer
+		manifest, err := vc.ExportParquet(ctx, &buf, &ParquetExportRequest{PartitionBy: partitionBy, MaxRowsPerFile: 1})
+		if err != nil {
+			t.Fatalf("%q: ExportParquet failed: %v", partitionBy, err)
+		}
+		if manifest.Records != 3 || len(manifest.Files) != 3 {
+			t.Errorf("%q: manifest lists %d records in %v", partitionBy, manifest.Records, manifest.Files)
+		}
+
+		reader, err := NewParquetDatasetReader(&buf)
+		if err != nil {
+			t.Fatalf("%q: NewParquetDatasetReader failed: %v", partitionBy, err)
+		}
+		if reader.Manifest() == nil || reader.Manifest().Dimensions != 2 {
+			t.Fatalf("%q: manifest not read back", partitionBy)
+		}
+		got := make(map[string]*Vector)
+		for {
+			batch, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%q: Next failed: %v", partitionBy, err)
+			}
+			for _, record := range batch {
+				got[record.key()] = record
+			}
+		}
+		reader.Close()
+
+		for _, want := range records {
+			record := got[want.key()]
+			if record == nil {
+				t.Fatalf("%q: record %s missing", partitionBy, want.ID)
+			}
+			if !reflect.DeepEqual(record.Vector, want.Vector) || !reflect.DeepEqual(record.Metadata, want.Metadata) {
+				t.Errorf("%q: record %s read back as %+v", partitionBy, want.ID, record)
+			}
+		}
+	}
+}
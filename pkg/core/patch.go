@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MetadataPatchRequest changes the metadata of the records given by ID, or
+// of those matching a filter, leaving their vectors and the index alone
+type MetadataPatchRequest struct {
+	IDs       []string               `json:"ids,omitempty"`
+	Filter    *Filter                `json:"filter,omitempty"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Set       map[string]interface{} `json:"set,omitempty"`   // Keys to add or overwrite
+	Unset     []string               `json:"unset,omitempty"` // Keys to remove
+	If        *Filter                `json:"if,omitempty"`    // Condition every selected record must meet, or none is patched
+}
+
+// validateMetadataPatch checks that a patch selects records and changes
+// something
+func validateMetadataPatch(req *MetadataPatchRequest) error {
+	if (len(req.IDs) > 0) == (req.Filter != nil) {
+		return fmt.Errorf("a metadata patch needs either ids or a filter")
+	}
+	if len(req.Set) == 0 && len(req.Unset) == 0 {
+		return fmt.Errorf("a metadata patch needs keys to set or unset")
+	}
+	for _, key := range req.Unset {
+		if _, exists := req.Set[key]; exists {
+			return fmt.Errorf("metadata key '%s' is both set and unset", key)
+		}
+	}
+	if err := ValidateNamespace(req.Namespace); err != nil {
+		return err
+	}
+	if _, _, err := expirationTime(req.Set); err != nil {
+		return err
+	}
+	if err := ValidateFilter(req.If); err != nil {
+		return err
+	}
+	return ValidateFilter(req.Filter)
+}
+
+// PatchMetadata applies a metadata patch and returns how many records it
+// changed. IDs that do not exist are skipped.
+func (c *VittoriaCollection) PatchMetadata(ctx context.Context, req *MetadataPatchRequest) (int, error) {
+	if c.readOnly {
+		return 0, c.errReadOnly()
+	}
+	if err := validateMetadataPatch(req); err != nil {
+		return 0, err
+	}
+
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		patched := 0
+		for i, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				return patched, fmt.Errorf("shard %s: metadata patches require local shards", c.shardName(i))
+			}
+			n, err := local.PatchMetadata(ctx, req)
+			patched += n
+			if err != nil {
+				return patched, err
+			}
+		}
+		return patched, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, errorf(ErrClosed, "collection is closed")
+	}
+
+	var keys []string
+	now := time.Now()
+	if len(req.IDs) > 0 {
+		for _, id := range req.IDs {
+			key := vectorKey(req.Namespace, id)
+			if vector, exists := c.vectors[key]; exists && !isExpired(vector, now) {
+				keys = append(keys, key)
+			}
+		}
+	} else {
+		for key, vector := range c.vectors {
+			if vector.Namespace == req.Namespace && !isExpired(vector, now) && matchFilter(vector.Metadata, req.Filter) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	if req.If != nil {
+		for _, key := range keys {
+			if !c.conditionHolds(req.If, key, now) {
+				return 0, errorf(ErrConditionFailed, "condition not met for vector '%s'", c.vectors[key].ID)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		previous := c.vectors[key]
+		metadata := make(map[string]interface{}, len(previous.Metadata)+len(req.Set))
+		for k, v := range previous.Metadata {
+			metadata[k] = v
+		}
+		for k, v := range req.Set {
+			metadata[k] = v
+		}
+		for _, k := range req.Unset {
+			delete(metadata, k)
+		}
+
+		// Readers may hold the previous vector, so it is replaced rather
+		// than changed; the vector data and its index entry are shared
+		c.vectors[key] = &Vector{
+			ID:        previous.ID,
+			Namespace: previous.Namespace,
+			Vector:    previous.Vector,
+			Metadata:  metadata,
+			bits:      previous.bits,
+			codes:     previous.codes,
+			halves:    previous.halves,
+			norm:      previous.norm,
+			offset:    previous.offset,
+		}
+		c.indexFields(c.vectors[key])
+		c.changes.publish(ChangeUpdate, c.vectors[key])
+	}
+
+	if len(keys) > 0 {
+		c.touch()
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPatchMetadata(t *testing.T) {
+	ctx := context.Background()
+	collection, err := NewCollection("docs", 2, DistanceMetricCosine, IndexTypeFlat, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCollection failed: %v", err)
+	}
+	if err := collection.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	defer collection.Close()
+
+	collection.InsertBatch(ctx, []*Vector{
+		{ID: "a", Vector: []float32{1, 0}, Metadata: map[string]interface{}{"label": "draft", "reviewer": "ann"}},
+		{ID: "b", Vector: []float32{0, 1}, Metadata: map[string]interface{}{"label": "draft"}},
+		{ID: "c", Vector: []float32{1, 1}, Metadata: map[string]interface{}{"label": "final"}},
+	})
+
+	n, err := collection.PatchMetadata(ctx, &MetadataPatchRequest{
+		Filter: &Filter{Field: "label", Operator: FilterOpEq, Value: "draft"},
+		Set:    map[string]interface{}{"label": "review"},
+		Unset:  []string{"reviewer"},
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("PatchMetadata = %d, %v; want 2 records", n, err)
+	}
+	a, _ := collection.Get(ctx, "a")
+	if a.Metadata["label"] != "review" || a.Metadata["reviewer"] != nil || a.Vector[0] != 1 {
+		t.Errorf("unexpected record after patch: %+v", a)
+	}
+
+	n, _ = collection.PatchMetadata(ctx, &MetadataPatchRequest{IDs: []string{"c", "missing"}, Set: map[string]interface{}{"label": "archived"}})
+	if n != 1 {
+		t.Errorf("patch by IDs changed %d records, want 1", n)
+	}
+	if _, err := collection.PatchMetadata(ctx, &MetadataPatchRequest{IDs: []string{"a"}}); err == nil {
+		t.Error("expected an error for a patch without changes")
+	}
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// progressChunkSize is the number of vectors a progressive scan examines
+// between checks for a due snapshot
+const progressChunkSize = 4096
+
+// progressInterval is the least time between two snapshots, so searches that
+// finish quickly report none
+const progressInterval = 100 * time.Millisecond
+
+// SearchProgress is a snapshot of the best results a search has found so far.
+// Later snapshots and the final response can still reorder or replace them.
+type SearchProgress struct {
+	Results []*SearchResult `json:"results"`
+	Scanned int             `json:"scanned"` // Work done so far, in the unit of Total
+	Total   int             `json:"total"`   // Vectors to scan, candidates to fetch or shards to query
+	TookMS  int64           `json:"took_ms"`
+}
+
+// ProgressFunc receives the snapshots of a progressive search. It is called
+// from the searching goroutine, so it should hand the snapshot off rather than
+// block; returning an error stops the search.
+type ProgressFunc func(*SearchProgress) error
+
+// progressReporter rate-limits the snapshots of one search
+type progressReporter struct {
+	fn    ProgressFunc
+	start time.Time
+	last  time.Time
+}
+
+// newProgressReporter creates a reporter for fn; a nil fn reports nothing
+func newProgressReporter(fn ProgressFunc) *progressReporter {
+	if fn == nil {
+		return nil
+	}
+	now := time.Now()
+	return &progressReporter{fn: fn, start: now, last: now}
+}
+
+// due reports whether enough time has passed since the previous snapshot for
+// another one to be reported
+func (p *progressReporter) due() bool {
+	return p != nil && time.Since(p.last) >= progressInterval
+}
+
+// snapshot reports the page of ranked that req asks for, unless the previous
+// snapshot was too recent. ranked is sorted best first and is copied.
+func (p *progressReporter) snapshot(ranked []*SearchResult, req *SearchRequest, scanned, total int) error {
+	if !p.due() {
+		return nil
+	}
+	p.last = time.Now()
+
+	start := min(req.Offset, len(ranked))
+	end := min(start+req.Limit, len(ranked))
+	results := make([]*SearchResult, end-start)
+	copy(results, ranked[start:end])
+
+	return p.fn(&SearchProgress{
+		Results: results,
+		Scanned: scanned,
+		Total:   total,
+		TookMS:  time.Since(p.start).Milliseconds(),
+	})
+}
+
+// SearchProgressive runs a search like Search and reports the best results
+// found so far while it runs: brute-force scans after each chunk of vectors,
+// index searches each time a filtered search has to widen, and sharded
+// searches as each shard answers. Searches that finish within
+// progressInterval report nothing. The final response is the same as Search's.
+func (c *VittoriaCollection) SearchProgressive(ctx context.Context, req *SearchRequest, progress ProgressFunc) (*SearchResponse, error) {
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	report := newProgressReporter(progress)
+	if c.isSharded() {
+		return c.searchShards(ctx, req, report)
+	}
+
+	req, explain := c.planSearch(req)
+	if req.Rescore && c.quantizer != nil {
+		response, err := c.rescoredSearch(ctx, req)
+		if err == nil {
+			addPlan(response, explain)
+		}
+		return response, err
+	}
+
+	var generation uint64
+	if c.searchEngine != nil {
+		cached, current, found := c.searchEngine.lookup(req)
+		if found {
+			addPlan(cached, explain)
+			return cached, nil
+		}
+		generation = current
+	}
+
+	var response *SearchResponse
+	var err error
+	if c.indexReady() {
+		response, err = c.searchIndex(ctx, req, report)
+	} else {
+		response, err = c.progressiveScan(ctx, req, report)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.searchEngine != nil {
+		c.searchEngine.store(generation, req, response)
+	}
+	addPlan(response, explain)
+	return response, nil
+}
+
+// progressiveScan is a brute-force search that keeps only the best results in
+// a top-k heap and reports them as the scan advances
+func (c *VittoriaCollection) progressiveScan(ctx context.Context, req *SearchRequest, report *progressReporter) (*SearchResponse, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	startTime := time.Now()
+
+	if err := c.validateSearchRequest(req); err != nil {
+		return nil, err
+	}
+
+	k := req.Offset + req.Limit
+	top := newTopK(k)
+	matched, scanned := 0, 0
+	now := time.Now()
+	scorer := c.newQueryScorer(req.Vector)
+
+	for _, vector := range c.vectors {
+		scanned++
+		if scanned%progressChunkSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if report.due() {
+				ranked := c.rankedResults(top.sorted(), req)
+				if err := report.snapshot(ranked, req, scanned, len(c.vectors)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if !searchable(vector, req, now) {
+			continue
+		}
+		if !c.matchesFilter(vector.Metadata, req.searchFilter()) {
+			continue
+		}
+		matched++
+
+		score := scorer.score(vector)
+		if c.belowMinScore(req, score) {
+			continue
+		}
+		top.offer(vector, score)
+	}
+
+	ranked := top.sorted()
+	return &SearchResponse{
+		Results:   c.rankedResults(ranked[min(req.Offset, len(ranked)):], req),
+		Total:     int64(matched),
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+	}, nil
+}
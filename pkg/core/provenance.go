@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// EmbeddingModel records a model that generated embeddings of a collection,
+// and when it did, so consumers know which model produced which vectors
+// after the collection is re-embedded with another one
+type EmbeddingModel struct {
+	Type       embeddings.VectorizerType `json:"type"`
+	Model      string                    `json:"model"`
+	Version    string                    `json:"version,omitempty"`
+	Dimensions int                       `json:"dimensions"`
+	FirstUsed  time.Time                 `json:"first_used"`
+	LastUsed   time.Time                 `json:"last_used"`
+	Embeddings int64                     `json:"embeddings"` // Texts embedded with the model
+}
+
+// matches reports whether the model is the one config describes
+func (m *EmbeddingModel) matches(config *embeddings.VectorizerConfig) bool {
+	return m.Type == config.Type && m.Model == config.Model && m.Version == config.ModelVersion() && m.Dimensions == config.Dimensions
+}
+
+// RecordEmbeddings records that n texts were embedded with the collection's
+// current vectorizer. Text inserts record themselves; replicated inserts of
+// vectors embedded on the cluster leader are recorded when applied.
+func (c *VittoriaCollection) RecordEmbeddings(n int) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	config := c.vectorizerConf
+	if config == nil {
+		return
+	}
+	now := time.Now().UTC()
+	for _, model := range c.models {
+		if model.matches(config) {
+			model.LastUsed = now
+			model.Embeddings += int64(n)
+			return
+		}
+	}
+	c.models = append(c.models, &EmbeddingModel{
+		Type:       config.Type,
+		Model:      config.Model,
+		Version:    config.ModelVersion(),
+		Dimensions: config.Dimensions,
+		FirstUsed:  now,
+		LastUsed:   now,
+		Embeddings: int64(n),
+	})
+}
+
+// embeddingModels returns a copy of the models that generated embeddings, in
+// the order they were first used; the caller holds mu
+func (c *VittoriaCollection) embeddingModels() []*EmbeddingModel {
+	if len(c.models) == 0 {
+		return nil
+	}
+	models := make([]*EmbeddingModel, len(c.models))
+	for i, model := range c.models {
+		copied := *model
+		models[i] = &copied
+	}
+	return models
+}
+
+// SetVectorizerConfig switches the vectorizer texts are embedded with. The
+// model must produce embeddings of the collection's dimensions; vectors
+// already stored keep the provenance of the model that produced them.
+func (c *VittoriaCollection) SetVectorizerConfig(config *embeddings.VectorizerConfig) error {
+	if c.readOnly {
+		return c.errReadOnly()
+	}
+
+	vectorizerConfig := *config
+	vectorizerConfig.Profile = "" // Only collections created with a profile take its API key
+	if vectorizerConfig.Dimensions == 0 {
+		vectorizerConfig.Dimensions = c.dimensions
+	}
+	if vectorizerConfig.Dimensions != c.dimensions {
+		return fmt.Errorf("vectorizer produces %d dimensions but the collection has %d", vectorizerConfig.Dimensions, c.dimensions)
+	}
+	vectorizer, err := embeddings.NewVectorizerFactory().CreateVectorizer(&vectorizerConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create vectorizer: %w", err)
+	}
+	if vectorizer.GetDimensions() != c.dimensions {
+		vectorizer.Close()
+		return fmt.Errorf("vectorizer produces %d dimensions but the collection has %d", vectorizer.GetDimensions(), c.dimensions)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		vectorizer.Close()
+		return errorf(ErrClosed, "collection is closed")
+	}
+	previous := c.vectorizer
+	c.vectorizer = vectorizer
+	c.vectorizerConf = vectorizerConfig.WithoutSecrets()
+	if previous != nil {
+		previous.Close()
+	}
+	// Cached results of text queries were embedded with the previous model
+	c.touch()
+	return c.saveMetadata()
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+func TestEmbeddingProvenance(t *testing.T) {
+	// An Ollama stand-in embedding every text as [1, length]
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Input []string }
+		json.NewDecoder(r.Body).Decode(&req)
+		embeddings := make([][]float32, len(req.Input))
+		for i, text := range req.Input {
+			embeddings[i] = []float32{1, float32(len(text))}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+	}))
+	defer ollama.Close()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := openTestDatabase(t, &Config{DataDir: dir})
+	vectorizer := func(model string) *embeddings.VectorizerConfig {
+		return &embeddings.VectorizerConfig{Type: embeddings.VectorizerTypeOllama, Model: model, Dimensions: 2,
+			Options: map[string]interface{}{"base_url": ollama.URL, embeddings.ModelVersionOption: "v1"}}
+	}
+	err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, IndexType: IndexTypeFlat, VectorizerConfig: vectorizer("old")})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	vc := collection.(*VittoriaCollection)
+
+	if err := vc.InsertTextBatch(ctx, []*TextVector{{ID: "a", Text: "one"}, {ID: "b", Text: "two"}}); err != nil {
+		t.Fatalf("InsertTextBatch failed: %v", err)
+	}
+	if err := vc.Update(ctx, &UpdateCollectionRequest{Vectorizer: vectorizer("new")}); err != nil {
+		t.Fatalf("switching models failed: %v", err)
+	}
+	if err := vc.InsertText(ctx, &TextVector{ID: "a", Text: "one again"}); err != nil {
+		t.Fatalf("InsertText failed: %v", err)
+	}
+	if err := vc.Update(ctx, &UpdateCollectionRequest{Vectorizer: &embeddings.VectorizerConfig{Type: embeddings.VectorizerTypeOllama, Dimensions: 3}}); err == nil {
+		t.Errorf("expected a model of other dimensions to be refused")
+	}
+	vc.Flush(ctx)
+	db.Close()
+
+	// The provenance survives a restart
+	db = openTestDatabase(t, &Config{DataDir: dir})
+	collection, _ = db.GetCollection(ctx, "docs")
+	info, _ := collection.(*VittoriaCollection).Info()
+	if len(info.EmbeddingModels) != 2 {
+		t.Fatalf("got %d embedding models, want 2", len(info.EmbeddingModels))
+	}
+	old, current := info.EmbeddingModels[0], info.EmbeddingModels[1]
+	if old.Model != "old" || old.Version != "v1" || old.Embeddings != 2 || current.Model != "new" || current.Embeddings != 1 {
+		t.Errorf("unexpected provenance: %+v, %+v", old, current)
+	}
+	if current.FirstUsed.Before(old.LastUsed) {
+		t.Errorf("the new model was used before the old one")
+	}
+}
@@ -0,0 +1,465 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// QuantizationInt8 keeps one byte per vector component in memory
+const QuantizationInt8 = "int8"
+
+// QuantizationConfig enables quantization of a collection's vectors. With
+// int8, vectors are kept in memory as one byte per component, mapped
+// linearly onto each dimension's range, and in full precision on disk: a
+// quarter of the memory, for approximate scores that searches with
+// rescore=true correct. With float16, vectors are stored in half precision.
+type QuantizationConfig struct {
+	Type string `json:"type"` // int8 or float16
+}
+
+// QuantizationTypes lists the quantization types collections can use
+var QuantizationTypes = []string{QuantizationInt8, QuantizationFloat16}
+
+// rescoreOversampling is how many candidates per requested result a rescored
+// search takes from the quantized scan
+const rescoreOversampling = 4
+
+// quantizerHeadroom is the share of a dimension's range added on the side a
+// new extreme value widens it, so that the range rarely has to widen again:
+// every widening re-encodes the whole collection from disk
+const quantizerHeadroom = 0.25
+
+// originalsFileName holds the full-precision vectors of a quantized collection
+const originalsFileName = "originals.f32"
+
+// validateQuantization checks the quantization of a collection creation request
+func validateQuantization(req *CreateCollectionRequest) error {
+	kind := req.Quantization.Type
+	if kind != QuantizationInt8 && kind != QuantizationFloat16 {
+		return fmt.Errorf("invalid quantization type '%s': use %s or %s", kind, QuantizationInt8, QuantizationFloat16)
+	}
+	// The HNSW graph keeps its own full-precision copy of every vector
+	if req.IndexType != IndexTypeFlat {
+		return fmt.Errorf("%s quantization requires a flat index", kind)
+	}
+	if req.Sharding != nil {
+		return fmt.Errorf("%s quantization is not supported on sharded collections", kind)
+	}
+	if req.Metric.Binary() {
+		return fmt.Errorf("%s quantization does not apply to the %s metric, whose vectors are already bit-packed", kind, req.Metric)
+	}
+	return nil
+}
+
+// scalarQuantizer maps each dimension's range onto the 256 values of a byte:
+// component d of a vector is stored as the code q for which offset[d] +
+// q*scale[d] is closest
+type scalarQuantizer struct {
+	offset  []float32
+	scale   []float32
+	trained bool // Set once the ranges cover a vector
+}
+
+// newScalarQuantizer returns a quantizer whose ranges are set by the first
+// vector it covers
+func newScalarQuantizer(dimensions int) *scalarQuantizer {
+	return &scalarQuantizer{
+		offset: make([]float32, dimensions),
+		scale:  make([]float32, dimensions),
+	}
+}
+
+// cover widens the ranges to cover vector, with headroom, and reports
+// whether they changed
+func (q *scalarQuantizer) cover(vector []float32) bool {
+	if !q.trained {
+		copy(q.offset, vector)
+		q.trained = true
+		return true
+	}
+
+	widened := false
+	for d, value := range vector {
+		low, high := q.offset[d], q.offset[d]+255*q.scale[d]
+		if value >= low && value <= high {
+			continue
+		}
+		if value < low {
+			low = value - quantizerHeadroom*(high-value)
+		} else {
+			high = value + quantizerHeadroom*(value-low)
+		}
+		q.offset[d], q.scale[d] = low, (high-low)/255
+		widened = true
+	}
+	return widened
+}
+
+// encode returns the codes of a vector
+func (q *scalarQuantizer) encode(vector []float32) []uint8 {
+	codes := make([]uint8, len(vector))
+	for d, value := range vector {
+		if q.scale[d] == 0 {
+			continue
+		}
+		code := math.Round(float64((value - q.offset[d]) / q.scale[d]))
+		codes[d] = uint8(max(0, min(255, code)))
+	}
+	return codes
+}
+
+// originalsFile keeps the full-precision vectors of a quantized collection on
+// disk, as fixed-size records appended when vectors are written. It is
+// rebuilt from vectors.json when the collection opens, and compacted when
+// the vectors are saved.
+type originalsFile struct {
+	path string
+	file *os.File
+	size int64 // Bytes written
+	dims int
+}
+
+// createOriginalsFile creates an empty originals file in dir, or in the
+// temporary directory for a collection that must not write to its own
+func createOriginalsFile(dir string, dims int, readOnly bool) (*originalsFile, error) {
+	var file *os.File
+	var err error
+	if readOnly {
+		file, err = os.CreateTemp("", "vittoriadb-"+originalsFileName+"-*")
+	} else if err = os.MkdirAll(dir, 0755); err == nil {
+		file, err = os.OpenFile(filepath.Join(dir, originalsFileName), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the file of original vectors: %w", err)
+	}
+	return &originalsFile{path: file.Name(), file: file, dims: dims}, nil
+}
+
+// append writes a vector at the end of the file and returns its offset
+func (f *originalsFile) append(vector []float32) (int64, error) {
+	record := make([]byte, 4*f.dims)
+	for d, value := range vector {
+		binary.LittleEndian.PutUint32(record[4*d:], math.Float32bits(value))
+	}
+	offset := f.size
+	if _, err := f.file.WriteAt(record, offset); err != nil {
+		return 0, fmt.Errorf("failed to write original vector: %w", err)
+	}
+	f.size += int64(len(record))
+	return offset, nil
+}
+
+// read returns the vector at offset
+func (f *originalsFile) read(offset int64) ([]float32, error) {
+	record := make([]byte, 4*f.dims)
+	if _, err := f.file.ReadAt(record, offset); err != nil {
+		return nil, fmt.Errorf("failed to read original vector: %w", err)
+	}
+	vector := make([]float32, f.dims)
+	for d := range vector {
+		vector[d] = math.Float32frombits(binary.LittleEndian.Uint32(record[4*d:]))
+	}
+	return vector, nil
+}
+
+// close closes the file, removing it: it is rebuilt on the next open
+func (f *originalsFile) close() error {
+	err := f.file.Close()
+	os.Remove(f.path)
+	return err
+}
+
+// closeOriginals closes the originals file of a quantized collection, once
+// its vectors are saved
+func (c *VittoriaCollection) closeOriginals() {
+	if c.originals != nil {
+		c.originals.close()
+	}
+}
+
+// enableQuantization sets up quantization of a collection's vectors; vectors
+// already loaded are moved to disk, or converted to half precision
+func (c *VittoriaCollection) enableQuantization(config *QuantizationConfig) error {
+	if config.Type == QuantizationFloat16 {
+		c.quantization = config
+		for _, vector := range c.vectors {
+			c.quantizeVector(vector)
+		}
+		return nil
+	}
+
+	originals, err := createOriginalsFile(c.dataDir, c.dimensions, c.readOnly)
+	if err != nil {
+		return err
+	}
+	c.quantization = config
+	c.quantizer = newScalarQuantizer(c.dimensions)
+	c.originals = originals
+
+	// Cover every vector first, so they are all encoded once
+	for _, vector := range c.vectors {
+		if vector.hasVector() {
+			c.quantizer.cover(vector.Vector)
+		}
+	}
+	for _, vector := range c.vectors {
+		if err := c.quantizeVector(vector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quantizeVector moves a stored vector to the originals file, keeping its
+// codes in memory, or converts it to half precision. The caller must hold
+// c.mu or own the collection exclusively.
+func (c *VittoriaCollection) quantizeVector(vector *Vector) error {
+	if len(vector.Vector) == 0 {
+		return nil
+	}
+	if c.halfPrecision() {
+		vector.halves = encodeFloat16(vector.Vector)
+		vector.Vector = nil
+		if c.metric == DistanceMetricCosine {
+			vector.norm = vectorNorm(decodeFloat16(vector.halves))
+		}
+		return nil
+	}
+	if c.quantizer == nil {
+		return nil
+	}
+
+	offset, err := c.originals.append(vector.Vector)
+	if err != nil {
+		return err
+	}
+	if c.quantizer.cover(vector.Vector) {
+		if err := c.requantize(); err != nil {
+			return err
+		}
+	}
+	vector.codes = c.quantizer.encode(vector.Vector)
+	vector.norm = float32(math.Sqrt(float64(dotProduct(vector.Vector, vector.Vector))))
+	vector.offset = offset
+	vector.Vector = nil
+	return nil
+}
+
+// requantize re-encodes every quantized vector from its original, once the
+// quantizer's ranges have changed
+func (c *VittoriaCollection) requantize() error {
+	for _, vector := range c.vectors {
+		if vector.codes == nil {
+			continue
+		}
+		original, err := c.originals.read(vector.offset)
+		if err != nil {
+			return err
+		}
+		vector.codes = c.quantizer.encode(original)
+	}
+	return nil
+}
+
+// vectorData returns the components of a stored vector, read from disk in an
+// int8 quantized collection and widened from half precision in a float16
+// one. The caller must hold c.mu and must not change them.
+func (c *VittoriaCollection) vectorData(vector *Vector) ([]float32, error) {
+	if vector.halves != nil {
+		return decodeFloat16(vector.halves), nil
+	}
+	if vector.codes == nil {
+		return vector.Vector, nil
+	}
+	return c.originals.read(vector.offset)
+}
+
+// readVectorData is vectorData for callers that do not hold c.mu
+func (c *VittoriaCollection) readVectorData(vector *Vector) ([]float32, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vectorData(vector)
+}
+
+// withVectorData returns a stored vector with its components, a copy read
+// from disk or widened in a quantized collection. The caller must hold c.mu.
+func (c *VittoriaCollection) withVectorData(vector *Vector) (*Vector, error) {
+	if vector.codes == nil && vector.halves == nil {
+		return vector, nil
+	}
+	data, err := c.vectorData(vector)
+	if err != nil {
+		return nil, fmt.Errorf("vector %s: %w", vector.ID, err)
+	}
+	full := *vector
+	full.Vector = data
+	return &full, nil
+}
+
+// saveQuantizedVectors writes vectors.json with the original vectors, and
+// compacts the originals file on the way. The caller must hold c.mu.
+func (c *VittoriaCollection) saveQuantizedVectors() error {
+	compacted, err := os.OpenFile(c.originals.path+".tmp", os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to compact original vectors: %w", err)
+	}
+	next := &originalsFile{path: compacted.Name(), file: compacted, dims: c.dimensions}
+	offsets := make(map[*Vector]int64, len(c.vectors))
+
+	data, sums, err := encodeVectors(c.vectors, func(vector *Vector) (*Vector, error) {
+		full, err := c.withVectorData(vector)
+		if err == nil && vector.codes != nil {
+			offsets[vector], err = next.append(full.Vector)
+		}
+		return full, err
+	})
+	if err == nil {
+		err = c.writeChecksummed(vectorsFileName, data, sums)
+	}
+	if err != nil {
+		next.close()
+		return err
+	}
+
+	// Readers of a read-only collection never save, so the originals file
+	// is in the data directory
+	if err := os.Rename(next.path, c.originals.path); err != nil {
+		next.close()
+		return fmt.Errorf("failed to compact original vectors: %w", err)
+	}
+	c.originals.file.Close()
+	next.path = c.originals.path
+	c.originals = next
+	for vector, offset := range offsets {
+		vector.offset = offset
+	}
+	return nil
+}
+
+// quantizedQuery scores quantized vectors against a query without decoding
+// them, from terms of the query computed once
+type quantizedQuery struct {
+	metric    DistanceMetric
+	weights   []float32 // query[d]*scale[d], for products
+	base      float32   // Sum of query[d]*offset[d], for products
+	norm      float32   // Norm of the query, for cosine
+	residuals []float32 // query[d]-offset[d], for distances
+	scale     []float32
+}
+
+// newQuantizedQuery prepares a query for scoring quantized vectors
+func (q *scalarQuantizer) newQuantizedQuery(metric DistanceMetric, query []float32) *quantizedQuery {
+	qq := &quantizedQuery{metric: metric, scale: q.scale}
+	switch metric {
+	case DistanceMetricCosine, DistanceMetricDotProduct:
+		qq.weights = make([]float32, len(query))
+		for d, value := range query {
+			qq.weights[d] = value * q.scale[d]
+			qq.base += value * q.offset[d]
+		}
+		qq.norm = float32(math.Sqrt(float64(dotProduct(query, query))))
+	default:
+		qq.residuals = make([]float32, len(query))
+		for d, value := range query {
+			qq.residuals[d] = value - q.offset[d]
+		}
+	}
+	return qq
+}
+
+// score returns the approximate similarity of a quantized vector
+func (qq *quantizedQuery) score(vector *Vector) float32 {
+	switch qq.metric {
+	case DistanceMetricCosine, DistanceMetricDotProduct:
+		dot := qq.base
+		for d, code := range vector.codes {
+			dot += qq.weights[d] * float32(code)
+		}
+		if qq.metric == DistanceMetricDotProduct {
+			return dot
+		}
+		if qq.norm == 0 || vector.norm == 0 {
+			return 0
+		}
+		return dot / (qq.norm * vector.norm)
+	case DistanceMetricEuclidean:
+		var sum float32
+		for d, code := range vector.codes {
+			diff := qq.residuals[d] - qq.scale[d]*float32(code)
+			sum += diff * diff
+		}
+		return 1.0 / (1.0 + float32(math.Sqrt(float64(sum))))
+	case DistanceMetricManhattan:
+		var sum float32
+		for d, code := range vector.codes {
+			sum += float32(math.Abs(float64(qq.residuals[d] - qq.scale[d]*float32(code))))
+		}
+		return 1.0 / (1.0 + sum)
+	default:
+		return 0
+	}
+}
+
+// rescoredSearch searches a quantized collection on its codes for more
+// candidates than asked, and ranks those by their similarity to the query
+// computed from the original vectors
+func (c *VittoriaCollection) rescoredSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	startTime := time.Now()
+
+	coarse := *req
+	coarse.Offset = 0
+	coarse.Limit = (req.Offset + req.Limit) * rescoreOversampling
+	coarse.Rescore = false
+	coarse.MinScore = nil
+	coarse.NormalizeScores = false
+	coarse.IncludeVector = false
+	coarse.IncludeMetadata = false
+	coarse.IncludeContent = false
+	candidates, err := c.Search(ctx, &coarse)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	results := make([]*SearchResult, 0, len(candidates.Results))
+	for _, candidate := range candidates.Results {
+		// The candidate may have been deleted since the scan
+		vector, exists := c.vectors[vectorKey(req.Namespace, candidate.ID)]
+		if !exists || !vector.hasVector() {
+			continue
+		}
+		data, err := c.vectorData(vector)
+		if err != nil {
+			return nil, err
+		}
+		score := c.calculateSimilarity(req.Vector, data)
+		if c.belowMinScore(req, score) {
+			continue
+		}
+		results = append(results, c.newSearchResult(vector, score, req))
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return resultLess(results[i], results[j])
+	})
+
+	total := candidates.Total
+	if req.MinScore != nil {
+		total = int64(len(results))
+	}
+	start := min(req.Offset, len(results))
+	end := min(start+req.Limit, len(results))
+	return &SearchResponse{
+		Results:   results[start:end],
+		Total:     total,
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+	}, nil
+}
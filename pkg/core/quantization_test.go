@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestQuantization(t *testing.T) {
+	dataDir := t.TempDir()
+	ctx := context.Background()
+	db := openTestDatabase(t, &Config{DataDir: dataDir})
+
+	quantization := &QuantizationConfig{Type: QuantizationInt8}
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "hnsw", Dimensions: 8, IndexType: IndexTypeHNSW, Quantization: quantization}); err == nil {
+		t.Errorf("int8 quantization was accepted with an HNSW index")
+	}
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 8, IndexType: IndexTypeFlat, Quantization: quantization}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(7))
+	vectors := make(map[string][]float32)
+	collection, _ := db.GetCollection(ctx, "docs")
+	for i := 0; i < 200; i++ {
+		vector := make([]float32, 8)
+		for d := range vector {
+			// Later vectors widen the ranges, re-encoding the earlier ones
+			vector[d] = (rng.Float32()*2 - 1) * float32(1+i/50)
+		}
+		id := fmt.Sprintf("v%d", i)
+		vectors[id] = vector
+		if err := collection.Insert(ctx, &Vector{ID: id, Vector: vector}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	query := vectors["v42"]
+
+	check := func(collection Collection) {
+		t.Helper()
+		stored, err := collection.Get(ctx, "v7")
+		if err != nil || !reflect.DeepEqual(stored.Vector, vectors["v7"]) {
+			t.Fatalf("Get returned %v (%v), want the original %v", stored, err, vectors["v7"])
+		}
+
+		approximate, err := collection.Search(ctx, &SearchRequest{Vector: query, Limit: 5})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if approximate.Results[0].ID != "v42" || math.Abs(float64(approximate.Results[0].Score-1)) > 0.05 {
+			t.Errorf("quantized search ranked %+v first", approximate.Results[0])
+		}
+
+		rescored, err := collection.Search(ctx, &SearchRequest{Vector: query, Limit: 5, Rescore: true, IncludeVector: true})
+		if err != nil {
+			t.Fatalf("rescored Search failed: %v", err)
+		}
+		if len(rescored.Results) != 5 {
+			t.Fatalf("rescored Search returned %d results", len(rescored.Results))
+		}
+		for _, result := range rescored.Results {
+			if want := cosineSimilarity(query, vectors[result.ID]); math.Abs(float64(result.Score-want)) > 1e-5 {
+				t.Errorf("%s rescored %v, want the exact %v", result.ID, result.Score, want)
+			}
+			if !reflect.DeepEqual(result.Vector, vectors[result.ID]) {
+				t.Errorf("%s: included vector is not the original", result.ID)
+			}
+		}
+	}
+	check(collection)
+
+	// The codes are rebuilt from the saved originals on reopen
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	db = openTestDatabase(t, &Config{DataDir: dataDir})
+	collection, err := db.GetCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("GetCollection failed: %v", err)
+	}
+	info, _ := collection.(*VittoriaCollection).Info()
+	if info.Quantization == nil || info.Quantization.Type != QuantizationInt8 || info.VectorCount != 200 {
+		t.Fatalf("reopened collection info: %+v", info)
+	}
+	check(collection)
+}
@@ -0,0 +1,299 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Recommendation strategies
+const (
+	// RecommendAverageVector searches near the positive examples and away
+	// from the negative ones, with a single query vector
+	RecommendAverageVector = "average_vector"
+	// RecommendBestScore scores every vector against each example, keeping
+	// those closer to a positive example than to any negative one first
+	RecommendBestScore = "best_score"
+)
+
+// RecommendExample is an example given to a recommendation: the ID of a
+// stored vector, or a raw vector
+type RecommendExample struct {
+	ID     string
+	Vector []float32
+}
+
+// UnmarshalJSON accepts a vector ID or an array of numbers
+func (e *RecommendExample) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.ID); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &e.Vector); err != nil {
+		return fmt.Errorf("a recommendation example must be a vector ID or a vector")
+	}
+	return nil
+}
+
+// MarshalJSON writes the ID, or the vector of a raw example
+func (e RecommendExample) MarshalJSON() ([]byte, error) {
+	if e.Vector != nil {
+		return json.Marshal(e.Vector)
+	}
+	return json.Marshal(e.ID)
+}
+
+// RecommendRequest asks for the vectors most like the positive examples and
+// least like the negative ones. Examples given by ID are left out of the
+// results.
+type RecommendRequest struct {
+	Positive        []RecommendExample `json:"positive,omitempty"`
+	Negative        []RecommendExample `json:"negative,omitempty"`
+	Strategy        string             `json:"strategy,omitempty"` // average_vector (default) or best_score
+	Limit           int                `json:"limit"`
+	Offset          int                `json:"offset"`
+	Filter          *Filter            `json:"filter,omitempty"`
+	IncludeVector   bool               `json:"include_vector"`
+	IncludeMetadata bool               `json:"include_metadata"`
+	IncludeContent  bool               `json:"include_content"`
+	Namespace       string             `json:"namespace,omitempty"` // Namespace of the examples and the results
+}
+
+// searchRequest returns the search equivalent of the request, for a query
+// vector and a number of results
+func (req *RecommendRequest) searchRequest(vector []float32, limit int) *SearchRequest {
+	return &SearchRequest{
+		Vector:          vector,
+		Limit:           limit,
+		Filter:          req.Filter,
+		IncludeVector:   req.IncludeVector,
+		IncludeMetadata: req.IncludeMetadata,
+		IncludeContent:  req.IncludeContent,
+		Namespace:       req.Namespace,
+	}
+}
+
+// Recommend finds the vectors most like the positive examples and least like
+// the negative ones, with the given strategy
+func (c *VittoriaCollection) Recommend(ctx context.Context, req *RecommendRequest) (*SearchResponse, error) {
+	startTime := time.Now()
+
+	if req.Strategy == "" {
+		req.Strategy = RecommendAverageVector
+	}
+	switch req.Strategy {
+	case RecommendAverageVector:
+		if len(req.Positive) == 0 {
+			return nil, fmt.Errorf("the %s strategy needs at least one positive example", req.Strategy)
+		}
+	case RecommendBestScore:
+		if len(req.Positive) == 0 && len(req.Negative) == 0 {
+			return nil, fmt.Errorf("a recommendation needs at least one example")
+		}
+	default:
+		return nil, fmt.Errorf("unknown recommendation strategy %q (use %s or %s)", req.Strategy, RecommendAverageVector, RecommendBestScore)
+	}
+	if req.Limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("offset cannot be negative")
+	}
+	if err := ValidateNamespace(req.Namespace); err != nil {
+		return nil, err
+	}
+	if err := ValidateFilter(req.Filter); err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool)
+	positive, err := c.exampleVectors(ctx, req.Namespace, req.Positive, excluded)
+	if err != nil {
+		return nil, err
+	}
+	negative, err := c.exampleVectors(ctx, req.Namespace, req.Negative, excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*SearchResult
+	var total int64
+	if req.Strategy == RecommendAverageVector {
+		// The examples may rank first, so more results are fetched to make
+		// up for them
+		response, err := c.Search(ctx, req.searchRequest(averageTarget(positive, negative), req.Offset+req.Limit+len(excluded)))
+		if err != nil {
+			return nil, err
+		}
+		total = response.Total
+		for _, result := range response.Results {
+			if excluded[result.ID] {
+				total--
+				continue
+			}
+			results = append(results, result)
+		}
+	} else {
+		results, err = c.bestScoreResults(ctx, req, positive, negative, excluded, req.Offset+req.Limit)
+		if err != nil {
+			return nil, err
+		}
+		total = int64(len(results))
+	}
+
+	start := min(req.Offset, len(results))
+	end := min(start+req.Limit, len(results))
+	return &SearchResponse{
+		Results:   results[start:end],
+		Total:     total,
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+	}, nil
+}
+
+// exampleVectors resolves recommendation examples to vectors, adding the IDs
+// of stored examples to excluded
+func (c *VittoriaCollection) exampleVectors(ctx context.Context, namespace string, examples []RecommendExample, excluded map[string]bool) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(examples))
+	for _, example := range examples {
+		if example.Vector != nil {
+			if len(example.Vector) != c.dimensions {
+				return nil, errorf(ErrDimensionMismatch, "example vector dimensions (%d) don't match collection dimensions (%d)", len(example.Vector), c.dimensions)
+			}
+			vectors = append(vectors, example.Vector)
+			continue
+		}
+
+		vector, err := c.GetInNamespace(ctx, namespace, example.ID)
+		if err != nil {
+			return nil, fmt.Errorf("example %s: %w", example.ID, err)
+		}
+		if !vector.hasVector() {
+			return nil, fmt.Errorf("example %s holds metadata only and has no vector", example.ID)
+		}
+		vectors = append(vectors, vector.Vector)
+		excluded[example.ID] = true
+	}
+	return vectors, nil
+}
+
+// averageTarget returns the query of the average_vector strategy: the mean of
+// the positive examples, moved away from the mean of the negative ones by as
+// much again
+func averageTarget(positive, negative [][]float32) []float32 {
+	target := meanVector(positive)
+	if len(negative) == 0 {
+		return target
+	}
+	away := meanVector(negative)
+	for i := range target {
+		target[i] += target[i] - away[i]
+	}
+	return target
+}
+
+// meanVector returns the element-wise mean of vectors
+func meanVector(vectors [][]float32) []float32 {
+	mean := make([]float32, len(vectors[0]))
+	for _, vector := range vectors {
+		for i, value := range vector {
+			mean[i] += value
+		}
+	}
+	for i := range mean {
+		mean[i] /= float32(len(vectors))
+	}
+	return mean
+}
+
+// bestScoreResults returns the k best vectors under the best_score strategy.
+// A vector closer to a positive example than to any negative one scores its
+// best positive similarity; any other scores minus its squared best negative
+// similarity, ranking it below all of those.
+func (c *VittoriaCollection) bestScoreResults(ctx context.Context, req *RecommendRequest, positive, negative [][]float32, excluded map[string]bool, k int) ([]*SearchResult, error) {
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		var merged []*SearchResult
+		for i, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				return nil, fmt.Errorf("shard %s: %s recommendations require local shards", c.shardName(i), RecommendBestScore)
+			}
+			results, err := local.bestScoreResults(ctx, req, positive, negative, excluded, k)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, results...)
+		}
+		sort.Slice(merged, func(i, j int) bool {
+			return resultLess(merged[i], merged[j])
+		})
+		return merged[:min(k, len(merged))], nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	searchReq := req.searchRequest(nil, k)
+	top := make([]*SearchResult, 0, k+1)
+	scanned := 0
+	now := time.Now()
+	for _, vector := range c.vectors {
+		scanned++
+		if scanned%progressChunkSize == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		if excluded[vector.ID] || !searchable(vector, searchReq, now) {
+			continue
+		}
+		if req.Filter != nil && !c.matchesFilter(vector.Metadata, req.Filter) {
+			continue
+		}
+
+		data, err := c.vectorData(vector)
+		if err != nil {
+			return nil, err
+		}
+		score := c.bestScore(data, positive, negative)
+		if len(top) == k && !resultLess(&SearchResult{ID: vector.ID, Score: score}, top[k-1]) {
+			continue
+		}
+		result := c.newSearchResult(vector, score, searchReq)
+		i := sort.Search(len(top), func(i int) bool { return resultLess(result, top[i]) })
+		top = append(top, nil)
+		copy(top[i+1:], top[i:])
+		top[i] = result
+		if len(top) > k {
+			top = top[:k]
+		}
+	}
+	return top, nil
+}
+
+// bestScore scores a vector under the best_score strategy
+func (c *VittoriaCollection) bestScore(vector []float32, positive, negative [][]float32) float32 {
+	bestPositive := float32(math.Inf(-1))
+	for _, example := range positive {
+		bestPositive = max(bestPositive, c.calculateSimilarity(example, vector))
+	}
+	bestNegative := float32(math.Inf(-1))
+	for _, example := range negative {
+		bestNegative = max(bestNegative, c.calculateSimilarity(example, vector))
+	}
+
+	if bestPositive > bestNegative {
+		return bestPositive
+	}
+	return -bestNegative * bestNegative
+}
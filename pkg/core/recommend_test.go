@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRecommend(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "items", Dimensions: 2, Metric: DistanceMetricCosine}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "items")
+	vc := collection.(*VittoriaCollection)
+	for id, vector := range map[string][]float32{"liked": {1, 0.1}, "near": {1, 0.3}, "between": {1, 1}, "disliked": {0.1, 1}, "far": {-1, 0}} {
+		vc.Insert(ctx, &Vector{ID: id, Vector: vector})
+	}
+
+	var examples RecommendRequest
+	if err := json.Unmarshal([]byte(`{"positive": ["liked"], "negative": [[0.1, 1]]}`), &examples); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	for _, strategy := range []string{RecommendAverageVector, RecommendBestScore} {
+		req := examples
+		req.Strategy, req.Limit = strategy, 2
+		response, err := vc.Recommend(ctx, &req)
+		if err != nil {
+			t.Fatalf("%s: Recommend failed: %v", strategy, err)
+		}
+		if len(response.Results) != 2 || response.Results[0].ID != "near" {
+			t.Fatalf("%s: got %+v, want near first", strategy, response.Results)
+		}
+		for _, result := range response.Results {
+			if result.ID == "liked" || result.ID == "disliked" {
+				t.Errorf("%s: returned %s", strategy, result.ID)
+			}
+		}
+	}
+
+	if _, err := vc.Recommend(ctx, &RecommendRequest{Positive: []RecommendExample{{ID: "missing"}}, Limit: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a missing example to be not found, got %v", err)
+	}
+	if _, err := vc.Recommend(ctx, &RecommendRequest{Negative: []RecommendExample{{ID: "liked"}}, Limit: 1}); err == nil {
+		t.Errorf("expected average_vector without positive examples to be refused")
+	}
+}
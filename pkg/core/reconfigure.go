@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// Reconfigure applies the settings of a unified configuration a running
+// database changes without a restart: the size and TTL of the search cache, the
+// database-wide ef_search, which HNSW collections without their own follow
+// at once, and the memory limit writes are checked against. Other settings
+// are left as they were opened.
+func (db *VittoriaDB) Reconfigure(ctx context.Context, unified *config.VittoriaConfig) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed || db.config == nil {
+		return errorf(ErrClosed, "database is closed")
+	}
+
+	ef := unified.Search.Index.HNSW.EfSearch
+	if ef != 0 {
+		if err := validateEfSearch(ef); err != nil {
+			return err
+		}
+	}
+
+	if unified.Performance.MemoryLimit < 0 {
+		return fmt.Errorf("memory limit cannot be negative, got %d", unified.Performance.MemoryLimit)
+	}
+
+	// The caller applies the GC settings to the runtime
+	db.config.Performance.MemoryLimit = unified.Performance.MemoryLimit
+	db.config.Performance.GCTarget = unified.Performance.GCTarget
+	db.memory.limit.Store(unified.Performance.MemoryLimit)
+
+	if db.searchCache != nil {
+		db.searchCache.Resize(unified.Search.Cache.MaxEntries, unified.Search.Cache.TTL)
+	}
+	if ef != db.config.Index.HNSWConfig.EfSearch {
+		db.config.Index.HNSWConfig.EfSearch = ef
+		for _, collection := range db.collections {
+			collection.setDefaultEfSearch(ef)
+		}
+	}
+	return nil
+}
+
+// setDefaultEfSearch changes the database-wide ef_search of the collection,
+// used by its HNSW searches unless it sets its own
+func (c *VittoriaCollection) setDefaultEfSearch(ef int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.indexOptions.hnsw.EfSearch = ef
+	if c.isSharded() {
+		c.shardMu.RLock()
+		for _, s := range c.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				local.setDefaultEfSearch(ef)
+			}
+		}
+		c.shardMu.RUnlock()
+	}
+	if hnsw, ok := c.index.(index.HNSWIndex); ok {
+		hnsw.SetEfSearch(c.hnswParams().EfSearch)
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+)
+
+func TestReconfigure(t *testing.T) {
+	ctx := context.Background()
+	unified := config.DefaultConfig()
+	unified.DataDir = t.TempDir()
+	unified.Search.Cache.MaxEntries = 10
+	unified.Search.Cache.TTL = time.Minute
+	db := openTestDatabase(t, ConfigFromUnified(unified))
+
+	for _, name := range []string{"follows", "own"} {
+		if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: name, Dimensions: 2, IndexType: IndexTypeHNSW}); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+	}
+	own, _ := db.GetCollection(ctx, "own")
+	if err := own.(*VittoriaCollection).SetEfSearch(ctx, 300); err != nil {
+		t.Fatalf("SetEfSearch failed: %v", err)
+	}
+	follows, _ := db.GetCollection(ctx, "follows")
+	if err := follows.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err := follows.Search(ctx, &SearchRequest{Vector: []float32{1, float32(i)}, Limit: i}); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+	}
+
+	unified.Search.Cache.MaxEntries = 2
+	unified.Search.Cache.TTL = time.Hour
+	unified.Search.Index.HNSW.EfSearch = 0
+	if err := db.Reconfigure(ctx, unified); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if stats := db.searchCacheStats(); stats == nil || stats.Entries != 2 {
+		t.Errorf("cache not resized: %+v", stats)
+	}
+
+	unified.Search.Index.HNSW.EfSearch = 5000000
+	if err := db.Reconfigure(ctx, unified); err == nil {
+		t.Error("an invalid ef_search was accepted")
+	}
+	unified.Search.Index.HNSW.EfSearch = 120
+	if err := db.Reconfigure(ctx, unified); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	for name, want := range map[string]int{"follows": 120, "own": 300} {
+		collection, _ := db.GetCollection(ctx, name)
+		info, err := collection.(*VittoriaCollection).Info()
+		if err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+		if info.HNSW == nil || info.HNSW.EfSearch != want {
+			t.Errorf("%s: ef_search %+v, want %d", name, info.HNSW, want)
+		}
+	}
+
+	// Collections created afterwards follow the new default too
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "later", Dimensions: 2, IndexType: IndexTypeHNSW}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	later, _ := db.GetCollection(ctx, "later")
+	if info, _ := later.(*VittoriaCollection).Info(); info.HNSW == nil || info.HNSW.EfSearch != 120 {
+		t.Errorf("later: ef_search %+v, want 120", info.HNSW)
+	}
+}
+
+func TestReconfigureMemoryLimit(t *testing.T) {
+	ctx := context.Background()
+	unified := config.DefaultConfig()
+	unified.DataDir = t.TempDir()
+	unified.Performance.MemoryLimit = 1 << 40
+	db := openTestDatabase(t, ConfigFromUnified(unified))
+
+	if err := db.CheckMemory(1 << 41); !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("CheckMemory past the startup limit = %v, want ErrMemoryLimit", err)
+	}
+
+	// Writes are checked against the reloaded limit at once
+	unified.Performance.MemoryLimit = 1
+	unified.Performance.GCTarget = 50
+	if err := db.Reconfigure(ctx, unified); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if err := db.CheckMemory(1); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("CheckMemory past the reloaded limit = %v, want ErrMemoryLimit", err)
+	}
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Memory == nil || stats.Memory.Limit != 1 {
+		t.Errorf("memory stats = %+v, want the reloaded limit", stats.Memory)
+	}
+	if db.config.Performance.MemoryLimit != 1 || db.config.Performance.GCTarget != 50 {
+		t.Errorf("performance config = %+v, want the reloaded settings", db.config.Performance)
+	}
+
+	unified.Performance.MemoryLimit = -1
+	if err := db.Reconfigure(ctx, unified); err == nil {
+		t.Error("a negative memory limit was accepted")
+	}
+	if db.memoryLimit() != 1 {
+		t.Errorf("a refused reload changed the limit to %d", db.memoryLimit())
+	}
+
+	// Lifting the limit lets every write through
+	unified.Performance.MemoryLimit = 0
+	if err := db.Reconfigure(ctx, unified); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+	if err := db.CheckMemory(1 << 41); err != nil {
+		t.Errorf("CheckMemory without a limit: %v", err)
+	}
+}
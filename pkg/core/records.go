@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueryRequest retrieves stored records by metadata rather than similarity.
+// It finds vectors and metadata-only records alike.
+type QueryRequest struct {
+	Filter        *Filter `json:"filter,omitempty"`
+	Text          string  `json:"text,omitempty"` // Words that must all appear in the record's string metadata, case-insensitively
+	Namespace     string  `json:"namespace,omitempty"`
+	Limit         int     `json:"limit"`
+	Offset        int     `json:"offset"`
+	IncludeVector bool    `json:"include_vector"`
+}
+
+// QueryResponse represents the records matching a query, ordered by ID
+type QueryResponse struct {
+	Records []*Vector `json:"records"`
+	Total   int64     `json:"total"`
+	TookMS  int64     `json:"took_ms"`
+}
+
+// hasVector reports whether a stored record has a vector. Records without
+// one hold metadata only: they are found by queries but never by similarity
+// searches.
+func (v *Vector) hasVector() bool {
+	return len(v.Vector) > 0 || v.codes != nil || v.halves != nil
+}
+
+// Query returns the records of a namespace matching a filter and text
+func (c *VittoriaCollection) Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
+	startTime := time.Now()
+
+	if req.Limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("offset cannot be negative")
+	}
+	if err := ValidateNamespace(req.Namespace); err != nil {
+		return nil, err
+	}
+	if err := ValidateFilter(req.Filter); err != nil {
+		return nil, err
+	}
+
+	matches, err := c.queryMatches(req)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ID < matches[j].ID
+	})
+
+	start := min(req.Offset, len(matches))
+	end := min(start+req.Limit, len(matches))
+	records := make([]*Vector, 0, end-start)
+	for _, match := range matches[start:end] {
+		record := &Vector{
+			ID:        match.ID,
+			Namespace: match.Namespace,
+			Metadata:  make(map[string]interface{}, len(match.Metadata)),
+		}
+		if req.IncludeVector {
+			data, err := c.readVectorData(match)
+			if err != nil {
+				return nil, err
+			}
+			record.Vector = append([]float32(nil), data...)
+		}
+		for k, v := range match.Metadata {
+			record.Metadata[k] = v
+		}
+		records = append(records, record)
+	}
+
+	return &QueryResponse{
+		Records: records,
+		Total:   int64(len(matches)),
+		TookMS:  time.Since(startTime).Milliseconds(),
+	}, nil
+}
+
+// queryMatches returns the stored records matching a query, from every
+// shard of sharded collections
+func (c *VittoriaCollection) queryMatches(req *QueryRequest) ([]*Vector, error) {
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		var matches []*Vector
+		for i, s := range c.shards {
+			local, ok := s.(*VittoriaCollection)
+			if !ok {
+				return nil, fmt.Errorf("shard %s: queries require local shards", c.shardName(i))
+			}
+			shardMatches, err := local.queryMatches(req)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, shardMatches...)
+		}
+		return matches, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	terms := strings.Fields(strings.ToLower(req.Text))
+	now := time.Now()
+	var matches []*Vector
+	c.scanVectors(req.Filter, func(vector *Vector) bool {
+		if vector.Namespace != req.Namespace || isExpired(vector, now) {
+			return true
+		}
+		if matchFilter(vector.Metadata, req.Filter) && matchText(vector.Metadata, terms) {
+			matches = append(matches, vector)
+		}
+		return true
+	})
+	return matches, nil
+}
+
+// matchText reports whether every term appears in one of the string values
+// of metadata; terms are lowercase
+func matchText(metadata map[string]interface{}, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+
+	var text strings.Builder
+	for _, value := range metadata {
+		if s, ok := value.(string); ok {
+			text.WriteString(strings.ToLower(s))
+			text.WriteByte(0)
+		}
+	}
+	haystack := text.String()
+	for _, term := range terms {
+		if !strings.Contains(haystack, term) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMetadataOnlyRecords(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "kb", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeHNSW})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "kb")
+	err = collection.InsertBatch(ctx, []*Vector{
+		{ID: "chunk", Vector: []float32{1, 0}, Metadata: map[string]interface{}{"kind": "chunk", "text": "Vector databases"}},
+		{ID: "country-fr", Metadata: map[string]interface{}{"kind": "lookup", "name": "France", "population": 68.2}},
+		{ID: "country-it", Metadata: map[string]interface{}{"kind": "lookup", "name": "Italy", "population": 58.9}},
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	results, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results.Results) != 1 || results.Results[0].ID != "chunk" {
+		t.Errorf("search found %d results, want only the vector", len(results.Results))
+	}
+
+	response, err := collection.Query(ctx, &QueryRequest{
+		Filter: &Filter{And: []Filter{
+			{Field: "kind", Operator: FilterOpEq, Value: "lookup"},
+			{Field: "population", Operator: FilterOpGt, Value: 60},
+		}},
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if response.Total != 1 || response.Records[0].ID != "country-fr" {
+		t.Errorf("filter query = %d records, want country-fr", response.Total)
+	}
+	response, _ = collection.Query(ctx, &QueryRequest{Text: "ITALY", Limit: 10})
+	if response.Total != 1 || response.Records[0].ID != "country-it" {
+		t.Errorf("text query = %d records, want country-it", response.Total)
+	}
+
+	// A record can gain a vector, and lose it again
+	collection.Insert(ctx, &Vector{ID: "country-it", Vector: []float32{0, 1}})
+	collection.Insert(ctx, &Vector{ID: "country-it", Metadata: map[string]interface{}{"kind": "lookup"}})
+	if err := collection.Delete(ctx, "country-fr"); err != nil {
+		t.Errorf("Delete of a record failed: %v", err)
+	}
+	results, _ = collection.Search(ctx, &SearchRequest{Vector: []float32{0, 1}, Limit: 10})
+	if len(results.Results) != 1 {
+		t.Errorf("search found %d results after updates, want 1", len(results.Results))
+	}
+}
@@ -0,0 +1,53 @@
+package core
+
+import "math"
+
+// normalizedScore maps a similarity score onto [0,1], the same scale for
+// every metric: a cosine similarity s becomes (1+s)/2, the 1/(1+d) scores of
+// Euclidean and Manhattan distances are kept, and dot products go through
+// the logistic function 1/(1+e^-x)
+func normalizedScore(metric DistanceMetric, score float32) float32 {
+	switch metric {
+	case DistanceMetricCosine:
+		return (1 + score) / 2
+	case DistanceMetricDotProduct:
+		return float32(1 / (1 + math.Exp(-float64(score))))
+	default:
+		return score
+	}
+}
+
+// scoreDistance recovers the raw distance behind a similarity score: 1-s for
+// cosine, the Euclidean or Manhattan distance, the negated dot product, the
+// number of differing bits for Hamming and 1-s for Jaccard
+func (c *VittoriaCollection) scoreDistance(score float32) float32 {
+	switch c.metric {
+	case DistanceMetricCosine:
+		return 1 - score
+	case DistanceMetricEuclidean, DistanceMetricManhattan:
+		if score <= 0 {
+			return float32(math.Inf(1))
+		}
+		return 1/score - 1
+	case DistanceMetricDotProduct:
+		return -score
+	case DistanceMetricHamming:
+		return (1 - score) * float32(c.dimensions)
+	case DistanceMetricJaccard:
+		return 1 - score
+	default:
+		return 0
+	}
+}
+
+// belowMinScore reports whether a similarity score misses the request's
+// threshold, compared on the scale the results are reported in
+func (c *VittoriaCollection) belowMinScore(req *SearchRequest, score float32) bool {
+	if req.MinScore == nil {
+		return false
+	}
+	if req.NormalizeScores {
+		score = normalizedScore(c.metric, score)
+	}
+	return score < *req.MinScore
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestMinScoreAndNormalizedScores(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	for _, indexType := range []IndexType{IndexTypeFlat, IndexTypeHNSW} {
+		name := "scores_" + indexType.String()
+		if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: name, Dimensions: 2, Metric: DistanceMetricCosine, IndexType: indexType}); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+		collection, _ := db.GetCollection(ctx, name)
+		// Cosine similarities to (1,0): 1, 0 and -1
+		collection.Insert(ctx, &Vector{ID: "same", Vector: []float32{1, 0}})
+		collection.Insert(ctx, &Vector{ID: "orthogonal", Vector: []float32{0, 1}})
+		collection.Insert(ctx, &Vector{ID: "opposite", Vector: []float32{-1, 0}})
+
+		threshold := float32(0.5)
+		response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 10, MinScore: &threshold, NormalizeScores: true})
+		if err != nil {
+			t.Fatalf("%s: Search failed: %v", name, err)
+		}
+		if len(response.Results) != 2 {
+			t.Fatalf("%s: got %d results, want 2", name, len(response.Results))
+		}
+		for _, result := range response.Results {
+			if result.Distance == nil {
+				t.Fatalf("%s: %s has no distance", name, result.ID)
+			}
+			want := map[string]float32{"same": 1, "orthogonal": 0.5}[result.ID]
+			if math.Abs(float64(result.Score-want)) > 1e-5 || math.Abs(float64(*result.Distance-(1-(2*want-1)))) > 1e-5 {
+				t.Errorf("%s: %s scored %v at distance %v", name, result.ID, result.Score, *result.Distance)
+			}
+		}
+
+		// Without normalization the threshold applies to the raw similarity
+		response, err = collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 10, MinScore: &threshold})
+		if err != nil || len(response.Results) != 1 || response.Results[0].Distance != nil {
+			t.Errorf("%s: raw threshold returned %+v, %v", name, response, err)
+		}
+	}
+}
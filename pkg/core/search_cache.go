@@ -1,9 +1,12 @@
 package core
 
 import (
+	"container/list"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 )
@@ -29,29 +32,39 @@ func DefaultSearchCacheConfig() *SearchCacheConfig {
 // CacheEntry represents a cached search result
 type CacheEntry struct {
 	Key         string          `json:"key"`
+	Collection  string          `json:"collection"`
 	Response    *SearchResponse `json:"response"`
 	CreatedAt   time.Time       `json:"created_at"`
 	AccessedAt  time.Time       `json:"accessed_at"`
 	AccessCount int64           `json:"access_count"`
+
+	generation uint64    // Write generation of the collection the response reflects
+	expiresAt  time.Time // When the first vector of the response expires, zero if none does
 }
 
-// SearchCache provides caching for search results
+// SearchCache provides caching for search results. Entries are kept in least
+// recently used order and expire after the TTL; the entries of a collection
+// are dropped whenever it is written.
 type SearchCache struct {
-	config  *SearchCacheConfig
-	entries map[string]*CacheEntry
-	mu      sync.RWMutex
-	stats   *SearchCacheStats
-	stopCh  chan struct{}
+	config       *SearchCacheConfig
+	entries      map[string]*list.Element       // Values are *CacheEntry
+	lru          *list.List                     // Most recently used first
+	byCollection map[string]map[string]struct{} // Keys of the entries of each collection
+	mu           sync.Mutex
+	stats        *SearchCacheStats
+	stopCh       chan struct{}
+	closeOnce    sync.Once
 }
 
 // SearchCacheStats tracks cache performance
 type SearchCacheStats struct {
-	Hits        int64   `json:"hits"`
-	Misses      int64   `json:"misses"`
-	Entries     int     `json:"entries"`
-	HitRate     float64 `json:"hit_rate"`
-	Evictions   int64   `json:"evictions"`
-	CleanupRuns int64   `json:"cleanup_runs"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Entries       int     `json:"entries"`
+	HitRate       float64 `json:"hit_rate"`
+	Evictions     int64   `json:"evictions"`     // Entries dropped as least recently used or expired
+	Invalidations int64   `json:"invalidations"` // Entries dropped because their collection was written
+	CleanupRuns   int64   `json:"cleanup_runs"`
 }
 
 // NewSearchCache creates a new search cache
@@ -61,14 +74,16 @@ func NewSearchCache(config *SearchCacheConfig) *SearchCache {
 	}
 
 	cache := &SearchCache{
-		config:  config,
-		entries: make(map[string]*CacheEntry),
-		stats:   &SearchCacheStats{},
-		stopCh:  make(chan struct{}),
+		config:       config,
+		entries:      make(map[string]*list.Element),
+		lru:          list.New(),
+		byCollection: make(map[string]map[string]struct{}),
+		stats:        &SearchCacheStats{},
+		stopCh:       make(chan struct{}),
 	}
 
 	// Start cleanup goroutine if enabled
-	if config.Enabled && config.CleanupInterval > 0 {
+	if config.Enabled && config.CleanupInterval > 0 && config.TTL > 0 {
 		go cache.cleanupLoop()
 	}
 
@@ -77,66 +92,113 @@ func NewSearchCache(config *SearchCacheConfig) *SearchCache {
 
 // Get retrieves a cached search result
 func (sc *SearchCache) Get(req *SearchRequest) (*SearchResponse, bool) {
+	return sc.get("", 0, req)
+}
+
+// Set stores a search result in the cache
+func (sc *SearchCache) Set(req *SearchRequest, response *SearchResponse) {
+	sc.set("", 0, req, response)
+}
+
+// get returns a copy of the response cached for req on a collection, unless
+// the collection was written since: generation is its current write generation
+func (sc *SearchCache) get(collection string, generation uint64, req *SearchRequest) (*SearchResponse, bool) {
 	if !sc.config.Enabled {
 		return nil, false
 	}
 
-	key := sc.generateKey(req)
+	key := sc.generateKey(collection, req)
 
-	sc.mu.RLock()
-	entry, exists := sc.entries[key]
-	sc.mu.RUnlock()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 
+	element, exists := sc.entries[key]
 	if !exists {
-		sc.incrementMisses()
+		sc.stats.Misses++
 		return nil, false
 	}
 
-	// Check if entry is expired
-	if time.Since(entry.CreatedAt) > sc.config.TTL {
-		sc.mu.Lock()
-		delete(sc.entries, key)
-		sc.mu.Unlock()
-		sc.incrementMisses()
+	entry := element.Value.(*CacheEntry)
+	if entry.generation != generation {
+		sc.remove(element)
+		sc.stats.Invalidations++
+		sc.stats.Misses++
+		return nil, false
+	}
+	if sc.expired(entry, time.Now()) {
+		sc.remove(element)
+		sc.stats.Evictions++
+		sc.stats.Misses++
 		return nil, false
 	}
 
-	// Update access statistics
-	sc.mu.Lock()
 	entry.AccessedAt = time.Now()
 	entry.AccessCount++
-	sc.mu.Unlock()
+	sc.lru.MoveToFront(element)
+	sc.stats.Hits++
 
-	sc.incrementHits()
-	return entry.Response, true
+	// Callers may modify the response they get, which answers a new request
+	response := sc.copyResponse(entry.Response)
+	response.RequestID = fmt.Sprintf("%d", time.Now().UnixNano())
+	return response, true
 }
 
-// Set stores a search result in the cache
-func (sc *SearchCache) Set(req *SearchRequest, response *SearchResponse) {
-	if !sc.config.Enabled {
+// set caches the response to req on a collection, computed at the given
+// write generation of the collection
+func (sc *SearchCache) set(collection string, generation uint64, req *SearchRequest, response *SearchResponse) {
+	if !sc.config.Enabled || response == nil {
 		return
 	}
 
-	key := sc.generateKey(req)
+	key := sc.generateKey(collection, req)
 	now := time.Now()
 
 	entry := &CacheEntry{
 		Key:         key,
+		Collection:  collection,
 		Response:    sc.copyResponse(response),
 		CreatedAt:   now,
 		AccessedAt:  now,
 		AccessCount: 1,
+		generation:  generation,
+		expiresAt:   resultsExpiry(response.Results),
 	}
 
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	// Check if we need to evict entries
-	if len(sc.entries) >= sc.config.MaxEntries {
-		sc.evictLRU()
+	if sc.config.MaxEntries <= 0 {
+		return
+	}
+	if element, exists := sc.entries[key]; exists {
+		sc.remove(element)
+	}
+	for len(sc.entries) >= sc.config.MaxEntries {
+		sc.remove(sc.lru.Back())
+		sc.stats.Evictions++
+	}
+
+	sc.entries[key] = sc.lru.PushFront(entry)
+	keys := sc.byCollection[collection]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		sc.byCollection[collection] = keys
 	}
+	keys[key] = struct{}{}
+}
+
+// Invalidate drops the cached results of a collection
+func (sc *SearchCache) Invalidate(collection string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 
-	sc.entries[key] = entry
+	for key := range sc.byCollection[collection] {
+		if element, exists := sc.entries[key]; exists {
+			sc.remove(element)
+			sc.stats.Invalidations++
+		}
+	}
+	delete(sc.byCollection, collection)
 }
 
 // Clear removes all cached entries
@@ -144,14 +206,32 @@ func (sc *SearchCache) Clear() {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 
-	sc.entries = make(map[string]*CacheEntry)
 	sc.stats.Evictions += int64(len(sc.entries))
+	sc.entries = make(map[string]*list.Element)
+	sc.lru.Init()
+	sc.byCollection = make(map[string]map[string]struct{})
+}
+
+// Resize changes how many entries the cache keeps and for how long, evicting
+// the least recently used entries past the new limit. A cache that started
+// without a TTL expires entries as they are read rather than in the
+// background.
+func (sc *SearchCache) Resize(maxEntries int, ttl time.Duration) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.config.MaxEntries = maxEntries
+	sc.config.TTL = ttl
+	for len(sc.entries) > max(maxEntries, 0) {
+		sc.remove(sc.lru.Back())
+		sc.stats.Evictions++
+	}
 }
 
 // GetStats returns current cache statistics
 func (sc *SearchCache) GetStats() SearchCacheStats {
-	sc.mu.RLock()
-	defer sc.mu.RUnlock()
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
 
 	stats := *sc.stats
 	stats.Entries = len(sc.entries)
@@ -166,35 +246,58 @@ func (sc *SearchCache) GetStats() SearchCacheStats {
 
 // Close stops the cache cleanup goroutine
 func (sc *SearchCache) Close() {
-	if sc.stopCh != nil {
+	sc.closeOnce.Do(func() {
 		close(sc.stopCh)
+	})
+}
+
+// remove drops an entry; the caller holds mu
+func (sc *SearchCache) remove(element *list.Element) {
+	entry := sc.lru.Remove(element).(*CacheEntry)
+	delete(sc.entries, entry.Key)
+	if keys := sc.byCollection[entry.Collection]; keys != nil {
+		delete(keys, entry.Key)
+		if len(keys) == 0 {
+			delete(sc.byCollection, entry.Collection)
+		}
 	}
 }
 
-// generateKey creates a cache key from a search request
-func (sc *SearchCache) generateKey(req *SearchRequest) string {
-	// Create a deterministic key from the request
-	keyData := struct {
-		Vector          []float32 `json:"vector"`
-		Limit           int       `json:"limit"`
-		Offset          int       `json:"offset"`
-		Filter          *Filter   `json:"filter"`
-		IncludeVector   bool      `json:"include_vector"`
-		IncludeMetadata bool      `json:"include_metadata"`
-		IncludeContent  bool      `json:"include_content"`
-	}{
-		Vector:          req.Vector,
-		Limit:           req.Limit,
-		Offset:          req.Offset,
-		Filter:          req.Filter,
-		IncludeVector:   req.IncludeVector,
-		IncludeMetadata: req.IncludeMetadata,
-		IncludeContent:  req.IncludeContent,
+// expired reports whether an entry has outlived the TTL or one of the
+// vectors it returns
+func (sc *SearchCache) expired(entry *CacheEntry, now time.Time) bool {
+	if !entry.expiresAt.IsZero() && !entry.expiresAt.After(now) {
+		return true
+	}
+	return sc.config.TTL > 0 && now.Sub(entry.CreatedAt) > sc.config.TTL
+}
+
+// resultsExpiry returns when the first vector of the results expires, zero
+// if none does. Results of remote shards only carry their expiration in
+// their metadata.
+func resultsExpiry(results []*SearchResult) time.Time {
+	var earliest time.Time
+	for _, result := range results {
+		expiresAt := result.expiresAt
+		if expiresAt.IsZero() {
+			if at, ok, err := expirationTime(result.Metadata); ok && err == nil {
+				expiresAt = at
+			}
+		}
+		if !expiresAt.IsZero() && (earliest.IsZero() || expiresAt.Before(earliest)) {
+			earliest = expiresAt
+		}
 	}
+	return earliest
+}
 
-	data, _ := json.Marshal(keyData)
+// generateKey creates a cache key from the collection and the whole search
+// request: the query vector, filter, limit and every option that shapes the
+// results
+func (sc *SearchCache) generateKey(collection string, req *SearchRequest) string {
+	data, _ := json.Marshal(req)
 	hash := md5.Sum(data)
-	return hex.EncodeToString(hash[:])
+	return collection + "/" + hex.EncodeToString(hash[:])
 }
 
 // copyResponse creates a deep copy of a search response
@@ -208,11 +311,27 @@ func (sc *SearchCache) copyResponse(response *SearchResponse) *SearchResponse {
 		Total:   response.Total,
 		TookMS:  response.TookMS,
 	}
+	if response.Explain != nil {
+		explain := *response.Explain
+		explain.FilterOrder = slices.Clone(explain.FilterOrder)
+		if explain.EstimatedSelectivity != nil {
+			selectivity := *explain.EstimatedSelectivity
+			explain.EstimatedSelectivity = &selectivity
+		}
+		responseCopy.Explain = &explain
+	}
 
 	for i, result := range response.Results {
 		responseCopy.Results[i] = &SearchResult{
-			ID:    result.ID,
-			Score: result.Score,
+			ID:          result.ID,
+			Score:       result.Score,
+			VectorScore: result.VectorScore,
+			expiresAt:   result.expiresAt,
+		}
+
+		if result.Distance != nil {
+			distance := *result.Distance
+			responseCopy.Results[i].Distance = &distance
 		}
 
 		if result.Vector != nil {
@@ -233,26 +352,6 @@ func (sc *SearchCache) copyResponse(response *SearchResponse) *SearchResponse {
 	return responseCopy
 }
 
-// evictLRU removes the least recently used entry
-func (sc *SearchCache) evictLRU() {
-	var oldestKey string
-	var oldestTime time.Time
-	first := true
-
-	for key, entry := range sc.entries {
-		if first || entry.AccessedAt.Before(oldestTime) {
-			oldestKey = key
-			oldestTime = entry.AccessedAt
-			first = false
-		}
-	}
-
-	if oldestKey != "" {
-		delete(sc.entries, oldestKey)
-		sc.stats.Evictions++
-	}
-}
-
 // cleanupLoop periodically removes expired entries
 func (sc *SearchCache) cleanupLoop() {
 	ticker := time.NewTicker(sc.config.CleanupInterval)
@@ -274,34 +373,24 @@ func (sc *SearchCache) cleanup() {
 	defer sc.mu.Unlock()
 
 	now := time.Now()
-	expiredKeys := make([]string, 0)
-
-	for key, entry := range sc.entries {
-		if now.Sub(entry.CreatedAt) > sc.config.TTL {
-			expiredKeys = append(expiredKeys, key)
+	for element := sc.lru.Back(); element != nil; {
+		previous := element.Prev()
+		if sc.expired(element.Value.(*CacheEntry), now) {
+			sc.remove(element)
+			sc.stats.Evictions++
 		}
-	}
-
-	for _, key := range expiredKeys {
-		delete(sc.entries, key)
+		element = previous
 	}
 
 	sc.stats.CleanupRuns++
-	if len(expiredKeys) > 0 {
-		sc.stats.Evictions += int64(len(expiredKeys))
-	}
 }
 
-// incrementHits safely increments the hit counter
-func (sc *SearchCache) incrementHits() {
-	sc.mu.Lock()
-	defer sc.mu.Unlock()
-	sc.stats.Hits++
-}
-
-// incrementMisses safely increments the miss counter
-func (sc *SearchCache) incrementMisses() {
-	sc.mu.Lock()
-	defer sc.mu.Unlock()
-	sc.stats.Misses++
+// searchCacheStats returns the statistics of the database's search cache, or
+// nil when caching is disabled
+func (db *VittoriaDB) searchCacheStats() *SearchCacheStats {
+	if db.searchCache == nil || !db.searchCache.config.Enabled {
+		return nil
+	}
+	stats := db.searchCache.GetStats()
+	return &stats
 }
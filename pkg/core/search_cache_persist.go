@@ -0,0 +1,197 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The search cache outlives a clean restart, so that a routine deploy does
+// not answer its first searches from a cold cache. Close takes the entries
+// still current before closing the collections, which drops them, and saves
+// them with the modification time the collections are closed at; Open
+// restores those whose collection is unchanged since and which have not
+// expired. Restoring is best-effort: any failure only leaves the cache cold.
+
+// searchCacheFile holds the search cache of the data directory between a
+// clean close and the next open
+const searchCacheFile = "search_cache.json"
+
+// savedSearchCache is the search cache as saved to searchCacheFile
+type savedSearchCache struct {
+	Collections map[string]time.Time `json:"collections"` // Modification time of each collection the entries were computed on
+	Entries     []*CacheEntry        `json:"entries"`     // Least recently used first
+
+	generations map[string]uint64 // Write generation of each collection when the entries were taken
+}
+
+// snapshot returns the entries of collections at their current write
+// generation, least recently used first
+func (sc *SearchCache) snapshot(collections map[string]*VittoriaCollection) []*CacheEntry {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := time.Now()
+	var entries []*CacheEntry
+	for element := sc.lru.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*CacheEntry)
+		collection := collections[entry.Collection]
+		if collection == nil || entry.generation != collection.generation.Load() || sc.expired(entry, now) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// restore adds saved entries, given least recently used first, to the cache
+// at the current write generation of their collections
+func (sc *SearchCache) restore(entries []*CacheEntry, collections map[string]*VittoriaCollection) {
+	if !sc.config.Enabled || sc.config.MaxEntries <= 0 {
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := time.Now()
+	for _, entry := range entries {
+		collection := collections[entry.Collection]
+		if collection == nil || entry.Response == nil || sc.expired(entry, now) {
+			continue
+		}
+		if _, exists := sc.entries[entry.Key]; exists {
+			continue
+		}
+		for len(sc.entries) >= sc.config.MaxEntries {
+			sc.remove(sc.lru.Back())
+			sc.stats.Evictions++
+		}
+
+		entry.generation = collection.generation.Load()
+		sc.entries[entry.Key] = sc.lru.PushFront(entry)
+		keys := sc.byCollection[entry.Collection]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			sc.byCollection[entry.Collection] = keys
+		}
+		keys[entry.Key] = struct{}{}
+	}
+}
+
+// cacheableCollections returns the collections whose searches are cached,
+// shards included, by name; the caller holds mu
+func (db *VittoriaDB) cacheableCollections() map[string]*VittoriaCollection {
+	collections := make(map[string]*VittoriaCollection, len(db.collections))
+	for name, collection := range db.collections {
+		collections[name] = collection
+		if !collection.isSharded() {
+			continue
+		}
+		collection.shardMu.RLock()
+		for _, s := range collection.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				collections[local.name] = local
+			}
+		}
+		collection.shardMu.RUnlock()
+	}
+	return collections
+}
+
+// snapshotSearchCache takes the current entries of the search cache, or
+// returns nil when there are none; the caller holds mu
+func (db *VittoriaDB) snapshotSearchCache() *savedSearchCache {
+	if db.searchCache == nil || !db.searchCache.config.Enabled {
+		return nil
+	}
+
+	collections := db.cacheableCollections()
+	saved := &savedSearchCache{
+		Collections: make(map[string]time.Time),
+		Entries:     db.searchCache.snapshot(collections),
+		generations: make(map[string]uint64),
+	}
+	if len(saved.Entries) == 0 {
+		return nil
+	}
+	for _, entry := range saved.Entries {
+		saved.generations[entry.Collection] = collections[entry.Collection].generation.Load()
+	}
+	return saved
+}
+
+// saveSearchCache writes a snapshot of the search cache to the data
+// directory. The caller holds mu and has closed the collections since the
+// snapshot; entries of collections written in between are left out.
+func (db *VittoriaDB) saveSearchCache(saved *savedSearchCache) error {
+	collections := db.cacheableCollections()
+	for name, generation := range saved.generations {
+		collection := collections[name]
+		// Closing a collection is its last write
+		if collection == nil || collection.generation.Load() != generation+1 {
+			continue
+		}
+		collection.mu.RLock()
+		saved.Collections[name] = collection.modified
+		collection.mu.RUnlock()
+	}
+
+	entries := saved.Entries[:0]
+	for _, entry := range saved.Entries {
+		if _, ok := saved.Collections[entry.Collection]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	saved.Entries = entries
+	if len(entries) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(db.dataDir, searchCacheFile), data)
+}
+
+// restoreSearchCache fills the search cache with the entries saved by the
+// last clean close, for the collections unchanged since, and removes the
+// file, so that a later crash never restores entries of data written after
+// it; the caller holds mu
+func (db *VittoriaDB) restoreSearchCache() error {
+	path := filepath.Join(db.dataDir, searchCacheFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	var saved savedSearchCache
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", searchCacheFile, err)
+	}
+
+	unchanged := make(map[string]*VittoriaCollection)
+	for name, collection := range db.cacheableCollections() {
+		modified, ok := saved.Collections[name]
+		if !ok {
+			continue
+		}
+		collection.mu.RLock()
+		same := collection.modified.Equal(modified)
+		collection.mu.RUnlock()
+		if same {
+			unchanged[name] = collection
+		}
+	}
+	db.searchCache.restore(saved.Entries, unchanged)
+	return nil
+}
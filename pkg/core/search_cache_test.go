@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSearchCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, &Config{Cache: &SearchCacheConfig{Enabled: true, MaxEntries: 2, TTL: time.Minute}})
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	if err := collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 1}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	search := func(query []float32) string {
+		t.Helper()
+		response, err := collection.Search(ctx, &SearchRequest{Vector: query, Limit: 1})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return response.Results[0].ID
+	}
+	cacheStats := func() SearchCacheStats {
+		stats, err := db.Stats(ctx)
+		if err != nil || stats.SearchCache == nil {
+			t.Fatalf("Stats: %v, search_cache %v", err, stats)
+		}
+		return *stats.SearchCache
+	}
+
+	search([]float32{1, 0})
+	if got := search([]float32{1, 0}); got != "a" {
+		t.Fatalf("cached search = %s, want a", got)
+	}
+	if stats := cacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("after a repeated search: %+v, want 1 hit and 1 miss", stats)
+	}
+
+	// A write makes the cached result stale
+	if err := collection.Insert(ctx, &Vector{ID: "b", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if got := search([]float32{1, 0}); got != "b" {
+		t.Errorf("search after insert = %s, want b", got)
+	}
+	if stats := cacheStats(); stats.Invalidations != 1 || stats.Entries != 1 {
+		t.Errorf("after a write: %+v, want 1 invalidation and 1 entry", stats)
+	}
+
+	// The least recently used entry makes room for new ones
+	search([]float32{0, 1})
+	search([]float32{1, 0})
+	search([]float32{1, 2})
+	if stats := cacheStats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("past max_entries: %+v, want 1 eviction and 2 entries", stats)
+	}
+	hits := cacheStats().Hits
+	search([]float32{1, 0})
+	if cacheStats().Hits != hits+1 {
+		t.Error("the most recently used entry was evicted")
+	}
+}
+
+func TestSearchCacheExpiringVectors(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, &Config{Cache: &SearchCacheConfig{Enabled: true, MaxEntries: 10, TTL: time.Minute}})
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	expiresAt := time.Now().Add(200 * time.Millisecond)
+	vectors := []*Vector{
+		{ID: "short", Vector: []float32{1, 0}, Metadata: map[string]interface{}{ExpiresAtField: expiresAt.Format(time.RFC3339Nano)}},
+		{ID: "long", Vector: []float32{1, 1}},
+	}
+	if err := collection.InsertBatch(ctx, vectors); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	search := func() []string {
+		t.Helper()
+		response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 2})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		ids := make([]string, len(response.Results))
+		for i, result := range response.Results {
+			ids[i] = result.ID
+		}
+		return ids
+	}
+	search()
+	if ids := search(); len(ids) != 2 || ids[0] != "short" {
+		t.Fatalf("cached search = %v, want [short long]", ids)
+	}
+	if stats := db.searchCacheStats(); stats.Hits != 1 {
+		t.Fatalf("expected the repeated search to hit the cache: %+v", stats)
+	}
+
+	// The cached response expires with the first vector it returns, without
+	// waiting for the janitor or the cache TTL
+	time.Sleep(time.Until(expiresAt) + 10*time.Millisecond)
+	if _, err := collection.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the expired vector to be gone, got %v", err)
+	}
+	if ids := search(); len(ids) != 1 || ids[0] != "long" {
+		t.Errorf("search after expiry = %v, want [long]", ids)
+	}
+}
+
+func TestSearchCacheRestart(t *testing.T) {
+	ctx := context.Background()
+	config := &Config{DataDir: t.TempDir(), Cache: &SearchCacheConfig{Enabled: true, MaxEntries: 10, TTL: time.Minute}}
+	open := func() (*VittoriaDB, Collection) {
+		t.Helper()
+		db := openTestDatabase(t, config)
+		collection, err := db.GetCollection(ctx, "docs")
+		if err != nil {
+			t.Fatalf("GetCollection failed: %v", err)
+		}
+		return db, collection
+	}
+	search := func(db *VittoriaDB, collection Collection) int64 {
+		t.Helper()
+		if _, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 1, IncludeMetadata: true}); err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return db.searchCache.GetStats().Hits
+	}
+
+	db := openTestDatabase(t, config)
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	if err := collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 1}, Metadata: map[string]interface{}{"title": "hello"}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	search(db, collection)
+	db.Close()
+
+	// The cache is warm after a clean restart, and the file is consumed
+	db, collection = open()
+	if hits := search(db, collection); hits != 1 {
+		t.Errorf("hits after restart = %d, want 1", hits)
+	}
+	if _, err := os.Stat(filepath.Join(config.DataDir, searchCacheFile)); !os.IsNotExist(err) {
+		t.Errorf("%s left behind after restore: %v", searchCacheFile, err)
+	}
+
+	// Entries of a collection written since the close are not restored
+	if err := collection.Insert(ctx, &Vector{ID: "b", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	search(db, collection)
+	db.Close()
+	data, err := os.ReadFile(filepath.Join(config.DataDir, searchCacheFile))
+	if err != nil {
+		t.Fatalf("search cache not saved: %v", err)
+	}
+	var saved savedSearchCache
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("saved search cache: %v", err)
+	}
+	saved.Collections["docs"] = saved.Collections["docs"].Add(-time.Second)
+	data, _ = json.Marshal(saved)
+	if err := os.WriteFile(filepath.Join(config.DataDir, searchCacheFile), data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	db, collection = open()
+	defer db.Close()
+	if hits := search(db, collection); hits != 0 {
+		t.Errorf("hits on a changed collection = %d, want 0", hits)
+	}
+}
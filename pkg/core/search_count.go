@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// countSearch answers a count-only search: it counts the vectors passing the
+// namespace and filter, and the score threshold if there is one, without
+// building results. Vectors are scored only to apply the threshold.
+func (c *VittoriaCollection) countSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errorf(ErrClosed, "collection is closed")
+	}
+
+	startTime := time.Now()
+
+	if err := c.validateSearchRequest(req); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	var err error
+	scanned := 0
+	now := time.Now()
+	scorer := c.newQueryScorer(req.Vector)
+	filter := req.searchFilter()
+	c.scanVectors(filter, func(vector *Vector) bool {
+		scanned++
+		if scanned%progressChunkSize == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+
+		if !searchable(vector, req, now) {
+			return true
+		}
+		if !c.matchesFilter(vector.Metadata, filter) {
+			return true
+		}
+		if req.MinScore != nil && c.belowMinScore(req, scorer.score(vector)) {
+			return true
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchResponse{
+		Results:   []*SearchResult{},
+		Total:     count,
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+	}, nil
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCountOnlySearch(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: DistanceMetricEuclidean}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	for i := 0; i < 10; i++ {
+		collection.Insert(ctx, &Vector{ID: fmt.Sprintf("v%d", i), Vector: []float32{float32(i), 0}, Metadata: map[string]interface{}{"even": i%2 == 0}})
+	}
+
+	count := func(req *SearchRequest) int64 {
+		t.Helper()
+		req.CountOnly = true
+		response, err := collection.Search(ctx, req)
+		if err != nil {
+			t.Fatalf("count failed: %v", err)
+		}
+		if len(response.Results) != 0 {
+			t.Errorf("a count returned %d results", len(response.Results))
+		}
+		return response.Total
+	}
+
+	if n := count(&SearchRequest{Filter: &Filter{Field: "even", Value: true}}); n != 5 {
+		t.Errorf("filtered count = %d, want 5", n)
+	}
+	// Scores are 1/(1+distance): v0 to v3 score at least 0.25
+	threshold := float32(0.25)
+	if n := count(&SearchRequest{Vector: []float32{0, 0}, MinScore: &threshold}); n != 4 {
+		t.Errorf("thresholded count = %d, want 4", n)
+	}
+	if _, err := collection.Search(ctx, &SearchRequest{CountOnly: true, MinScore: &threshold}); err == nil {
+		t.Errorf("expected a threshold without a vector to be refused")
+	}
+}
@@ -0,0 +1,664 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// shardsDirName is the directory, inside the collection directory, holding local shards
+const shardsDirName = "shards"
+
+// Rebalance policies
+const (
+	RebalanceManual   = "manual"   // Shard count can be changed through Reshard
+	RebalanceDisabled = "disabled" // Shard count is fixed at creation time
+)
+
+// ShardingConfig describes how a collection is split across shards.
+// Vectors are placed by jump consistent hashing of their routing key, so
+// growing from N to M shards only moves about (M-N)/M of the vectors.
+type ShardingConfig struct {
+	Shards       int      `json:"shards" yaml:"shards"`
+	RoutingField string   `json:"routing_field,omitempty" yaml:"routing_field"` // Metadata field hashed for placement (default: vector ID)
+	Nodes        []string `json:"nodes,omitempty" yaml:"nodes"`                 // Base URL of the node hosting each shard ("" = local)
+	Rebalance    string   `json:"rebalance,omitempty" yaml:"rebalance"`         // "manual" (default) or "disabled"
+	APIKey       string   `json:"api_key,omitempty" yaml:"api_key"`             // Sent to the remote nodes as "Authorization: Bearer <key>" when they require authentication
+}
+
+// ShardInfo describes a single shard
+type ShardInfo struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Node        string `json:"node,omitempty"` // Empty for local shards
+	VectorCount int64  `json:"vector_count"`
+}
+
+// shard is the subset of collection operations a sharded collection fans out to
+type shard interface {
+	InsertBatch(ctx context.Context, vectors []*Vector) error
+	GetInNamespace(ctx context.Context, namespace, id string) (*Vector, error)
+	DeleteInNamespace(ctx context.Context, namespace, id string) error
+	DeleteBatch(ctx context.Context, namespace string, ids []string) (int, error)
+	Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error)
+	Count() (int64, error)
+	Flush(ctx context.Context) error
+	Close() error
+	SetExpectedCount(ctx context.Context, n int) error
+	SetBulkLoad(ctx context.Context, enabled bool) error
+	SetEfSearch(ctx context.Context, ef int) error
+}
+
+// validateShardingConfig validates and normalizes a sharding configuration
+func validateShardingConfig(config *ShardingConfig) error {
+	if config.Shards < 1 {
+		return fmt.Errorf("sharding.shards must be positive")
+	}
+	if config.Shards > 1024 {
+		return fmt.Errorf("sharding.shards cannot exceed 1024")
+	}
+	if len(config.Nodes) > 0 && len(config.Nodes) != config.Shards {
+		return fmt.Errorf("sharding.nodes must list one node per shard (%d), got %d", config.Shards, len(config.Nodes))
+	}
+
+	switch config.Rebalance {
+	case "":
+		config.Rebalance = RebalanceManual
+	case RebalanceManual, RebalanceDisabled:
+	default:
+		return fmt.Errorf("invalid sharding.rebalance '%s'", config.Rebalance)
+	}
+
+	return nil
+}
+
+// enableSharding turns the collection into a coordinator over the configured shards.
+// The coordinator keeps no vectors or index of its own.
+func (c *VittoriaCollection) enableSharding(config *ShardingConfig) error {
+	if err := validateShardingConfig(config); err != nil {
+		return err
+	}
+
+	c.sharding = config
+	c.index = nil
+	return nil
+}
+
+// isSharded reports whether the collection fans out to shards
+func (c *VittoriaCollection) isSharded() bool {
+	return c.sharding != nil
+}
+
+// shardName returns the name of the i-th shard
+func (c *VittoriaCollection) shardName(i int) string {
+	if c.shardNode(i) != "" {
+		// Remote shards live next to other collections on their node
+		return fmt.Sprintf("%s-shard-%03d", c.name, i)
+	}
+	return fmt.Sprintf("shard-%03d", i)
+}
+
+// shardNode returns the node hosting the i-th shard, or "" when local
+func (c *VittoriaCollection) shardNode(i int) string {
+	if i < len(c.sharding.Nodes) {
+		return c.sharding.Nodes[i]
+	}
+	return ""
+}
+
+// openShards opens (or, with create, creates) the shards of a sharded collection
+func (c *VittoriaCollection) openShards(ctx context.Context, create bool) error {
+	shardsDir := filepath.Join(c.dataDir, shardsDirName)
+	shards := make([]shard, c.sharding.Shards)
+	perShard := c.perShardCount()
+
+	for i := range shards {
+		name := c.shardName(i)
+
+		if node := c.shardNode(i); node != "" {
+			remote := newRemoteShard(node, name, c.sharding.APIKey)
+			if create {
+				req := &CreateCollectionRequest{
+					Dimensions:    c.dimensions,
+					Metric:        c.metric,
+					IndexType:     c.indexType,
+					Config:        c.hnsw.config(),
+					ExpectedCount: perShard,
+					BulkLoad:      c.bulkLoading,
+					IndexedFields: c.indexedFields,
+				}
+				if err := remote.create(ctx, req); err != nil {
+					return fmt.Errorf("failed to create shard %s on %s: %w", name, node, err)
+				}
+			}
+			shards[i] = remote
+			continue
+		}
+
+		var local *VittoriaCollection
+		var err error
+		if create {
+			local, err = NewCollectionWithContentStorage(name, c.dimensions, c.metric, c.indexType, shardsDir, c.contentStorage)
+			if err == nil {
+				local.reserve(perShard)
+				local.bulkLoading = c.bulkLoading
+				local.setIndexedFields(c.indexedFields)
+				if err = local.setIndexOptions(c.indexOptions.forShards(), c.hnsw); err == nil {
+					err = local.Initialize(ctx)
+				}
+			}
+		} else {
+			local, err = openCollection(name, shardsDir, c.indexOptions.forShards(), c.readOnly)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open shard %s: %w", name, err)
+		}
+		local.changes = c.changes // Writes are reported under the sharded collection
+		shards[i] = local
+	}
+
+	c.shardMu.Lock()
+	c.shards = shards
+	c.shardMu.Unlock()
+	return nil
+}
+
+// perShardCount splits the collection's capacity hint evenly across its shards
+func (c *VittoriaCollection) perShardCount() int {
+	if c.expectedCount == 0 {
+		return 0
+	}
+	return (c.expectedCount + c.sharding.Shards - 1) / c.sharding.Shards
+}
+
+// shardFor returns the index of the shard a vector belongs to
+func (c *VittoriaCollection) shardFor(vector *Vector, shards int) int {
+	key := vector.key()
+	if c.sharding.RoutingField != "" {
+		if value, exists := vector.Metadata[c.sharding.RoutingField]; exists {
+			key = fmt.Sprint(value)
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return jumpHash(h.Sum64(), shards)
+}
+
+// routesByID reports whether a vector's shard can be derived from its ID alone
+func (c *VittoriaCollection) routesByID() bool {
+	return c.sharding.RoutingField == ""
+}
+
+// jumpHash maps a key to one of buckets using Lamping and Veach's jump consistent hash
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// shardedInsertBatch routes vectors to their shards and inserts them in parallel
+func (c *VittoriaCollection) shardedInsertBatch(ctx context.Context, vectors []*Vector) error {
+	for _, vector := range vectors {
+		if err := c.validateVector(vector); err != nil {
+			return err
+		}
+	}
+
+	c.shardMu.RLock()
+	groups := make(map[int][]*Vector)
+	for _, vector := range vectors {
+		i := c.shardFor(vector, len(c.shards))
+		groups[i] = append(groups[i], vector)
+	}
+
+	errs := make(chan error, len(groups))
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func(s shard, name string, group []*Vector) {
+			defer wg.Done()
+			if err := s.InsertBatch(ctx, group); err != nil {
+				errs <- fmt.Errorf("shard %s: %w", name, err)
+			}
+		}(c.shards[i], c.shardName(i), group)
+	}
+	wg.Wait()
+	close(errs)
+
+	err := <-errs
+	if err == nil && !c.routesByID() {
+		err = c.removeMovedCopies(ctx, groups)
+	}
+	c.shardMu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.touch()
+	c.mu.Unlock()
+	return nil
+}
+
+// removeMovedCopies deletes the vectors of groups, keyed by the shard they
+// were inserted in, from every other shard. With a routing field a vector's
+// shard follows its metadata, so an upsert changing the routing value leaves
+// the previous copy behind. The caller holds shardMu.
+func (c *VittoriaCollection) removeMovedCopies(ctx context.Context, groups map[int][]*Vector) error {
+	for i, s := range c.shards {
+		// IDs by namespace
+		moved := make(map[string][]string)
+		for j, group := range groups {
+			if j == i {
+				continue
+			}
+			for _, vector := range group {
+				moved[vector.Namespace] = append(moved[vector.Namespace], vector.ID)
+			}
+		}
+
+		for namespace, ids := range moved {
+			if _, err := s.DeleteBatch(ctx, namespace, ids); err != nil {
+				return fmt.Errorf("shard %s: %w", c.shardName(i), err)
+			}
+		}
+	}
+	return nil
+}
+
+// shardedGet fetches a vector from its shard, or from all shards when
+// placement depends on metadata; local shards return a view when view is set
+func (c *VittoriaCollection) shardedGet(ctx context.Context, namespace, id string, view bool) (*Vector, error) {
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	get := func(s shard) (*Vector, error) {
+		if local, ok := s.(*VittoriaCollection); ok {
+			return local.get(ctx, namespace, id, view)
+		}
+		return s.GetInNamespace(ctx, namespace, id)
+	}
+	if c.routesByID() {
+		return get(c.shards[c.shardFor(&Vector{ID: id, Namespace: namespace}, len(c.shards))])
+	}
+
+	for _, s := range c.shards {
+		if vector, err := get(s); err == nil {
+			return vector, nil
+		}
+	}
+	return nil, errorf(ErrNotFound, "vector '%s' not found", id)
+}
+
+// shardedDelete removes a vector from its shard
+func (c *VittoriaCollection) shardedDelete(ctx context.Context, namespace, id string) error {
+	if err := c.deleteFromShards(ctx, namespace, id); err != nil {
+		return err
+	}
+
+	// Lock order is c.mu before c.shardMu, so shardMu must be released here
+	c.mu.Lock()
+	c.touch()
+	c.mu.Unlock()
+	return nil
+}
+
+// deleteFromShards removes a vector from the shard holding it
+func (c *VittoriaCollection) deleteFromShards(ctx context.Context, namespace, id string) error {
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	if c.routesByID() {
+		return c.shards[c.shardFor(&Vector{ID: id, Namespace: namespace}, len(c.shards))].DeleteInNamespace(ctx, namespace, id)
+	}
+
+	for _, s := range c.shards {
+		if err := s.DeleteInNamespace(ctx, namespace, id); err == nil {
+			return nil
+		}
+	}
+	return errorf(ErrNotFound, "vector '%s' not found", id)
+}
+
+// shardedSearch runs the search on every shard and merges the results by score
+func (c *VittoriaCollection) shardedSearch(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	return c.searchShards(ctx, req, nil)
+}
+
+// searchShards is shardedSearch, reporting the merged results of the shards
+// that have answered as each one does
+func (c *VittoriaCollection) searchShards(ctx context.Context, req *SearchRequest, report *progressReporter) (*SearchResponse, error) {
+	startTime := time.Now()
+
+	if err := c.validateSearchRequest(req); err != nil {
+		return nil, err
+	}
+
+	c.shardMu.RLock()
+	shards := c.shards
+	c.shardMu.RUnlock()
+
+	// Each shard must return enough results to fill the requested page
+	shardReq := *req
+	shardReq.Offset = 0
+	shardReq.Limit = req.Offset + req.Limit
+
+	type shardResult struct {
+		i    int
+		resp *SearchResponse
+		err  error
+	}
+	answers := make(chan shardResult, len(shards))
+	for i, s := range shards {
+		go func(i int, s shard) {
+			resp, err := s.Search(ctx, &shardReq)
+			answers <- shardResult{i, resp, err}
+		}(i, s)
+	}
+
+	responses := make([]*SearchResponse, len(shards))
+	errs := make([]error, len(shards))
+	for answered := 1; answered <= len(shards); answered++ {
+		answer := <-answers
+		responses[answer.i], errs[answer.i] = answer.resp, answer.err
+		if report == nil || answer.err != nil || answered == len(shards) {
+			continue
+		}
+		if err := report.snapshot(mergeShardResults(responses), req, answered, len(shards)); err != nil {
+			return nil, err
+		}
+	}
+
+	var total int64
+	var explain *SearchExplain
+	for i, resp := range responses {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard %s search failed: %w", c.shardName(i), errs[i])
+		}
+		total += resp.Total
+		if explain == nil {
+			// Shards hold alike data, so the first one's plan stands for all
+			explain = resp.Explain
+		}
+	}
+	merged := mergeShardResults(responses)
+
+	start := min(req.Offset, len(merged))
+	end := min(start+req.Limit, len(merged))
+
+	return &SearchResponse{
+		Results:   merged[start:end],
+		Total:     total,
+		TookMS:    time.Since(startTime).Milliseconds(),
+		RequestID: fmt.Sprintf("%d", time.Now().UnixNano()),
+		Explain:   explain,
+	}, nil
+}
+
+// mergeShardResults merges the results of the shards that answered, sorted
+// by score with ties by ID
+func mergeShardResults(responses []*SearchResponse) []*SearchResult {
+	var merged []*SearchResult
+	for _, resp := range responses {
+		if resp != nil {
+			merged = append(merged, resp.Results...)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return resultLess(merged[i], merged[j])
+	})
+	return merged
+}
+
+// shardedCount sums the vector counts of all shards
+func (c *VittoriaCollection) shardedCount() (int64, error) {
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	var total int64
+	for i, s := range c.shards {
+		count, err := s.Count()
+		if err != nil {
+			return 0, fmt.Errorf("shard %s: %w", c.shardName(i), err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// flushShards flushes every shard
+func (c *VittoriaCollection) flushShards(ctx context.Context) error {
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	for i, s := range c.shards {
+		if err := s.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush shard %s: %w", c.shardName(i), err)
+		}
+	}
+	return nil
+}
+
+// closeShards closes every shard
+func (c *VittoriaCollection) closeShards() error {
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	for i, s := range c.shards {
+		if err := s.Close(); err != nil {
+			return fmt.Errorf("failed to close shard %s: %w", c.shardName(i), err)
+		}
+	}
+	return nil
+}
+
+// dropRemoteShards drops the shards hosted on other nodes
+func (c *VittoriaCollection) dropRemoteShards(ctx context.Context) error {
+	if !c.isSharded() {
+		return nil
+	}
+
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	for i, s := range c.shards {
+		if remote, ok := s.(*remoteShard); ok {
+			if err := remote.drop(ctx); err != nil {
+				return fmt.Errorf("failed to drop shard %s: %w", c.shardName(i), err)
+			}
+		}
+	}
+	return nil
+}
+
+// restoreRemoteShards restores the remote shards of a collection restored
+// from the trash
+func (c *VittoriaCollection) restoreRemoteShards(ctx context.Context) error {
+	if !c.isSharded() {
+		return nil
+	}
+
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	for i, s := range c.shards {
+		if remote, ok := s.(*remoteShard); ok {
+			if err := remote.restore(ctx); err != nil {
+				return fmt.Errorf("failed to restore shard %s: %w", c.shardName(i), err)
+			}
+		}
+	}
+	return nil
+}
+
+// shardedIndexStats aggregates the index stats of local shards
+func (c *VittoriaCollection) shardedIndexStats() (*index.IndexStats, error) {
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	total := &index.IndexStats{Dimensions: c.dimensions, IndexType: index.IndexType(c.indexType)}
+	for _, s := range c.shards {
+		local, ok := s.(*VittoriaCollection)
+		if !ok {
+			continue
+		}
+		stats, err := local.IndexStats()
+		if err != nil {
+			return nil, err
+		}
+		total.VectorCount += stats.VectorCount
+		total.MemoryUsage += stats.MemoryUsage
+		total.VectorMemory += stats.VectorMemory
+		total.GraphMemory += stats.GraphMemory
+		total.DiskSize += stats.DiskSize
+	}
+	return total, nil
+}
+
+// Shards returns the shards of a sharded collection (nil when not sharded)
+func (c *VittoriaCollection) Shards() ([]*ShardInfo, error) {
+	if !c.isSharded() {
+		return nil, nil
+	}
+
+	c.shardMu.RLock()
+	defer c.shardMu.RUnlock()
+
+	infos := make([]*ShardInfo, len(c.shards))
+	for i, s := range c.shards {
+		count, err := s.Count()
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", c.shardName(i), err)
+		}
+		infos[i] = &ShardInfo{ID: i, Name: c.shardName(i), Node: c.shardNode(i), VectorCount: count}
+	}
+	return infos, nil
+}
+
+// Reshard changes the number of shards of a locally sharded collection and
+// moves the vectors whose placement changed. It returns the number of moved vectors.
+func (c *VittoriaCollection) Reshard(ctx context.Context, shards int) (int, error) {
+	if c.readOnly {
+		return 0, c.errReadOnly()
+	}
+	if !c.isSharded() {
+		return 0, fmt.Errorf("collection '%s' is not sharded", c.name)
+	}
+	if c.sharding.Rebalance == RebalanceDisabled {
+		return 0, fmt.Errorf("rebalancing is disabled for collection '%s'", c.name)
+	}
+	if len(c.sharding.Nodes) > 0 {
+		return 0, fmt.Errorf("rebalancing is only supported for local shards")
+	}
+
+	newConfig := *c.sharding
+	newConfig.Shards = shards
+	if err := validateShardingConfig(&newConfig); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shardMu.Lock()
+	defer c.shardMu.Unlock()
+
+	if c.closed {
+		return 0, errorf(ErrClosed, "collection is closed")
+	}
+
+	// Build the new layout next to the current one and swap directories at the end
+	shardsDir := filepath.Join(c.dataDir, shardsDirName)
+	tmpDir := shardsDir + ".tmp"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return 0, err
+	}
+
+	newShards := make([]*VittoriaCollection, shards)
+	for i := range newShards {
+		local, err := NewCollectionWithContentStorage(fmt.Sprintf("shard-%03d", i), c.dimensions, c.metric, c.indexType, tmpDir, c.contentStorage)
+		if err != nil {
+			return 0, err
+		}
+		if c.expectedCount > 0 {
+			local.reserve((c.expectedCount + shards - 1) / shards)
+		}
+		local.bulkLoading = c.bulkLoading
+		if err := local.setIndexOptions(c.indexOptions.forShards(), c.hnsw); err != nil {
+			return 0, err
+		}
+		if err := local.Initialize(ctx); err != nil {
+			return 0, err
+		}
+		newShards[i] = local
+	}
+
+	moved := 0
+	for i, s := range c.shards {
+		old := s.(*VittoriaCollection)
+
+		old.mu.RLock()
+		groups := make(map[int][]*Vector)
+		for _, vector := range old.vectors {
+			target := c.shardFor(vector, shards)
+			if target != i {
+				moved++
+			}
+			groups[target] = append(groups[target], vector)
+		}
+		old.mu.RUnlock()
+
+		for target, group := range groups {
+			if err := newShards[target].InsertBatch(ctx, group); err != nil {
+				return 0, fmt.Errorf("failed to move vectors to shard %d: %w", target, err)
+			}
+		}
+	}
+
+	for _, s := range newShards {
+		if err := s.Close(); err != nil {
+			return 0, err
+		}
+	}
+	for _, s := range c.shards {
+		s.Close()
+	}
+
+	if err := os.RemoveAll(shardsDir); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmpDir, shardsDir); err != nil {
+		return 0, err
+	}
+
+	reopened := make([]shard, shards)
+	for i := range reopened {
+		local, err := openCollection(fmt.Sprintf("shard-%03d", i), shardsDir, c.indexOptions.forShards(), false)
+		if err != nil {
+			return 0, fmt.Errorf("failed to reopen shard %d: %w", i, err)
+		}
+		local.changes = c.changes
+		reopened[i] = local
+	}
+
+	c.shards = reopened
+	c.sharding.Shards = shards
+	c.touch()
+	if err := c.saveMetadata(); err != nil {
+		return moved, fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	return moved, nil
+}
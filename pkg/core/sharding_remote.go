@@ -0,0 +1,192 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// remoteShard is a shard stored as a regular collection on another VittoriaDB
+// node, reached through its REST API
+type remoteShard struct {
+	baseURL string
+	name    string
+	apiKey  string // Empty when the node does not require authentication
+	client  *http.Client
+}
+
+// newRemoteShard creates a client for the collection name on the node at
+// baseURL, authenticating with apiKey when it is set
+func newRemoteShard(baseURL, name, apiKey string) *remoteShard {
+	return &remoteShard{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		name:    name,
+		apiKey:  apiKey,
+		client:  NewHTTPClient(30 * time.Second),
+	}
+}
+
+// create creates the shard collection on the remote node from req, using the shard's name
+func (r *remoteShard) create(ctx context.Context, req *CreateCollectionRequest) error {
+	req.Name = r.name
+	return r.do(ctx, http.MethodPost, "/collections", req, nil)
+}
+
+// drop drops the shard collection on the remote node
+func (r *remoteShard) drop(ctx context.Context) error {
+	return r.do(ctx, http.MethodDelete, r.collectionPath(""), nil, nil)
+}
+
+// restore brings the remote shard back from its node's trash
+func (r *remoteShard) restore(ctx context.Context) error {
+	return r.do(ctx, http.MethodPost, r.collectionPath("/restore"), nil, nil)
+}
+
+// InsertBatch inserts vectors into the remote shard
+func (r *remoteShard) InsertBatch(ctx context.Context, vectors []*Vector) error {
+	body := map[string]interface{}{"vectors": vectors}
+	return r.do(ctx, http.MethodPost, r.collectionPath("/vectors/batch"), body, nil)
+}
+
+// GetInNamespace retrieves a vector from the remote shard
+func (r *remoteShard) GetInNamespace(ctx context.Context, namespace, id string) (*Vector, error) {
+	var vector Vector
+	if err := r.do(ctx, http.MethodGet, r.vectorPath(namespace, id), nil, &vector); err != nil {
+		return nil, err
+	}
+	return &vector, nil
+}
+
+// DeleteInNamespace removes a vector from the remote shard
+func (r *remoteShard) DeleteInNamespace(ctx context.Context, namespace, id string) error {
+	return r.do(ctx, http.MethodDelete, r.vectorPath(namespace, id), nil, nil)
+}
+
+// DeleteBatch removes vectors from the remote shard, skipping those it doesn't hold
+func (r *remoteShard) DeleteBatch(ctx context.Context, namespace string, ids []string) (int, error) {
+	body := map[string]interface{}{"ids": ids, "namespace": namespace}
+	var result struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := r.do(ctx, http.MethodPost, r.collectionPath("/vectors/delete"), body, &result); err != nil {
+		return 0, err
+	}
+	return result.Deleted, nil
+}
+
+// Search runs a search on the remote shard
+func (r *remoteShard) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	var resp SearchResponse
+	if err := r.do(ctx, http.MethodPost, r.collectionPath("/search"), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Count returns the number of vectors in the remote shard
+func (r *remoteShard) Count() (int64, error) {
+	var stats struct {
+		VectorCount int64 `json:"vector_count"`
+	}
+	if err := r.do(context.Background(), http.MethodGet, r.collectionPath("/stats"), nil, &stats); err != nil {
+		return 0, err
+	}
+	return stats.VectorCount, nil
+}
+
+// Flush is a no-op: the remote node persists its own collections
+func (r *remoteShard) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op: the remote collection stays open on its node
+func (r *remoteShard) Close() error {
+	return nil
+}
+
+// SetExpectedCount updates the capacity hint of the remote shard
+func (r *remoteShard) SetExpectedCount(ctx context.Context, n int) error {
+	req := &UpdateCollectionRequest{ExpectedCount: &n}
+	return r.do(ctx, http.MethodPut, r.collectionPath(""), req, nil)
+}
+
+// SetBulkLoad switches bulk-load mode on the remote shard
+func (r *remoteShard) SetBulkLoad(ctx context.Context, enabled bool) error {
+	req := &UpdateCollectionRequest{BulkLoad: &enabled}
+	return r.do(ctx, http.MethodPut, r.collectionPath(""), req, nil)
+}
+
+// SetEfSearch changes the beam width of the remote shard's searches
+func (r *remoteShard) SetEfSearch(ctx context.Context, ef int) error {
+	req := &UpdateCollectionRequest{EfSearch: &ef}
+	return r.do(ctx, http.MethodPut, r.collectionPath(""), req, nil)
+}
+
+// collectionPath returns the API path of the shard collection with suffix appended
+func (r *remoteShard) collectionPath(suffix string) string {
+	return "/collections/" + url.PathEscape(r.name) + suffix
+}
+
+// vectorPath returns the API path of a vector in the shard collection
+func (r *remoteShard) vectorPath(namespace, id string) string {
+	path := r.collectionPath("/vectors/" + url.PathEscape(id))
+	if namespace != "" {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+	return path
+}
+
+// do performs a JSON request against the remote node
+func (r *remoteShard) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", r.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error   string `json:"error"`
+			Code    string `json:"code"`
+			Details string `json:"details"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Details != "" {
+			return ErrorFromCode(apiErr.Code, apiErr.Details)
+		}
+		return fmt.Errorf("%s returned status %d: %s", r.baseURL, resp.StatusCode, apiErr.Error)
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJumpHash_MinimalMovement(t *testing.T) {
+	const keys = 10000
+
+	moved := 0
+	for k := uint64(0); k < keys; k++ {
+		before := jumpHash(k*0x9E3779B97F4A7C15, 4)
+		after := jumpHash(k*0x9E3779B97F4A7C15, 5)
+		if before < 0 || before >= 4 || after < 0 || after >= 5 {
+			t.Fatalf("Bucket out of range: %d -> %d", before, after)
+		}
+		if before != after {
+			moved++
+			if after != 4 {
+				t.Fatalf("Key moved between existing buckets: %d -> %d", before, after)
+			}
+		}
+	}
+
+	// Roughly 1/5 of the keys should move to the new bucket
+	if moved < keys/10 || moved > keys*3/10 {
+		t.Errorf("Expected about %d moved keys, got %d", keys/5, moved)
+	}
+}
+
+func TestShardedCollection_MatchesUnsharded(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	rng := rand.New(rand.NewSource(42))
+
+	plain, err := NewCollection("plain", 8, DistanceMetricCosine, IndexTypeFlat, dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := plain.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize collection: %v", err)
+	}
+
+	sharded, err := NewCollection("sharded", 8, DistanceMetricCosine, IndexTypeFlat, dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := sharded.enableSharding(&ShardingConfig{Shards: 3}); err != nil {
+		t.Fatalf("Failed to enable sharding: %v", err)
+	}
+	if err := sharded.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize sharded collection: %v", err)
+	}
+
+	vectors := make([]*Vector, 200)
+	for i := range vectors {
+		v := make([]float32, 8)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		vectors[i] = &Vector{ID: fmt.Sprintf("v%d", i), Vector: v}
+	}
+	if err := plain.InsertBatch(ctx, vectors); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := sharded.InsertBatch(ctx, vectors); err != nil {
+		t.Fatalf("Sharded insert failed: %v", err)
+	}
+
+	shards, err := sharded.Shards()
+	if err != nil {
+		t.Fatalf("Shards failed: %v", err)
+	}
+	for _, s := range shards {
+		if s.VectorCount == 0 {
+			t.Errorf("Shard %s is empty", s.Name)
+		}
+	}
+
+	req := &SearchRequest{Vector: vectors[7].Vector, Limit: 10, Offset: 5}
+	expected, err := plain.Search(ctx, req)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	got, err := sharded.Search(ctx, req)
+	if err != nil {
+		t.Fatalf("Sharded search failed: %v", err)
+	}
+	if got.Total != expected.Total || len(got.Results) != len(expected.Results) {
+		t.Fatalf("Expected %d/%d results, got %d/%d", len(expected.Results), expected.Total, len(got.Results), got.Total)
+	}
+	for i := range got.Results {
+		if got.Results[i].ID != expected.Results[i].ID {
+			t.Errorf("Result %d: expected %s, got %s", i, expected.Results[i].ID, got.Results[i].ID)
+		}
+	}
+
+	if err := sharded.Delete(ctx, "v3"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := sharded.Get(ctx, "v3"); err == nil {
+		t.Error("Expected deleted vector to be gone")
+	}
+
+	// Growing the shard count keeps every vector reachable
+	if _, err := sharded.Reshard(ctx, 5); err != nil {
+		t.Fatalf("Reshard failed: %v", err)
+	}
+	if count, _ := sharded.Count(); count != 199 {
+		t.Errorf("Expected 199 vectors after reshard, got %d", count)
+	}
+	if _, err := sharded.Get(ctx, "v42"); err != nil {
+		t.Errorf("Vector lost after reshard: %v", err)
+	}
+
+	// The sharding layout survives a reload
+	if err := sharded.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	reloaded, err := LoadCollection("sharded", dataDir)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	defer reloaded.Close()
+
+	info, _ := reloaded.Info()
+	if info.Shards != 5 || info.VectorCount != 199 {
+		t.Errorf("Expected 5 shards and 199 vectors after reload, got %d and %d", info.Shards, info.VectorCount)
+	}
+}
+
+func TestRemoteShardAuthentication(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer shard-node-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Missing or invalid API key", "code": "unauthorized"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer node.Close()
+
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	// Every call to the remote node carries the key
+	err := db.CreateCollection(ctx, &CreateCollectionRequest{
+		Name:       "remote",
+		Dimensions: 2,
+		Sharding:   &ShardingConfig{Shards: 2, Nodes: []string{"", node.URL}, APIKey: "shard-node-key"},
+	})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "remote")
+	if _, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 0}, Limit: 1}); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	mu.Lock()
+	if len(requests) < 2 {
+		t.Errorf("expected the create and search requests, got %v", requests)
+	}
+	mu.Unlock()
+
+	// Without it the node refuses the coordinator
+	err = db.CreateCollection(ctx, &CreateCollectionRequest{
+		Name:       "anonymous",
+		Dimensions: 2,
+		Sharding:   &ShardingConfig{Shards: 2, Nodes: []string{"", node.URL}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected the node to refuse a coordinator without a key, got %v", err)
+	}
+}
+
+func TestShardedCollection_RoutingFieldUpsert(t *testing.T) {
+	ctx := context.Background()
+	collection, err := NewCollection("tenants", 2, DistanceMetricCosine, IndexTypeFlat, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := collection.enableSharding(&ShardingConfig{Shards: 4, RoutingField: "tenant"}); err != nil {
+		t.Fatalf("Failed to enable sharding: %v", err)
+	}
+	if err := collection.Initialize(ctx); err != nil {
+		t.Fatalf("Failed to initialize sharded collection: %v", err)
+	}
+	defer collection.Close()
+
+	// Two tenants placed on different shards
+	record := func(tenant string) *Vector {
+		return &Vector{ID: "doc", Namespace: "ns", Vector: []float32{1, 0}, Metadata: map[string]interface{}{"tenant": tenant}}
+	}
+	from, to := "acme", ""
+	for i := 0; to == ""; i++ {
+		if tenant := fmt.Sprintf("tenant-%d", i); collection.shardFor(record(tenant), 4) != collection.shardFor(record(from), 4) {
+			to = tenant
+		}
+	}
+
+	if err := collection.Insert(ctx, record(from)); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	// The upsert moves the record to the new tenant's shard
+	if err := collection.InsertBatch(ctx, []*Vector{record(to), {ID: "other", Vector: []float32{0, 1}, Metadata: map[string]interface{}{"tenant": from}}}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+
+	if count, _ := collection.Count(); count != 2 {
+		t.Errorf("Expected 2 vectors after the upsert, got %d", count)
+	}
+	vector, err := collection.GetInNamespace(ctx, "ns", "doc")
+	if err != nil || vector.Metadata["tenant"] != to {
+		t.Fatalf("Expected the upserted record, got %v (%v)", vector, err)
+	}
+	old := collection.shards[collection.shardFor(record(from), 4)]
+	if _, err := old.GetInNamespace(ctx, "ns", "doc"); err == nil {
+		t.Error("The previous copy is still on the old shard")
+	}
+	if _, err := old.GetInNamespace(ctx, "", "other"); err != nil {
+		t.Errorf("A record inserted with the upsert is missing: %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"fmt"
 	"math"
+	"math/rand"
 	"runtime"
 	"sync"
 	"time"
@@ -65,6 +67,14 @@ func (s *SIMDVectorOps) CosineSimilarityBatch(query []float32, vectors [][]float
 	return s.cosineSimilarityBatchVectorized(query, vectors)
 }
 
+// DotProductBatch calculates the dot product of a query with each of vectors
+// into scores, which holds one score per vector
+func (s *SIMDVectorOps) DotProductBatch(query []float32, vectors [][]float32, scores []float32) {
+	for i, vector := range vectors {
+		scores[i] = s.DotProduct(query, vector)
+	}
+}
+
 // EuclideanDistance calculates Euclidean distance between two vectors
 func (s *SIMDVectorOps) EuclideanDistance(a, b []float32) float32 {
 	if !s.config.Enabled || !s.config.VectorizedMath {
@@ -294,32 +304,25 @@ func (s *SIMDVectorOps) euclideanDistanceVectorized(a, b []float32) float32 {
 		return float32(math.Inf(1))
 	}
 
-	const chunkSize = 8
-	length := len(a)
-	chunks := length / chunkSize
-	remainder := length % chunkSize
-
-	var sum float32
-
-	// Process full chunks
-	for i := 0; i < chunks; i++ {
-		start := i * chunkSize
-		end := start + chunkSize
-
-		for j := start; j < end; j++ {
-			diff := a[j] - b[j]
-			sum += diff * diff
-		}
+	// Four independent sums let the CPU overlap the multiply-adds
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		a4, b4 := a[i:i+4:i+4], b[i:i+4:i+4]
+		d0, d1, d2, d3 := a4[0]-b4[0], a4[1]-b4[1], a4[2]-b4[2], a4[3]-b4[3]
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
 	}
 
 	// Process remainder
-	start := chunks * chunkSize
-	for i := start; i < start+remainder; i++ {
+	for ; i < len(a); i++ {
 		diff := a[i] - b[i]
-		sum += diff * diff
+		s0 += diff * diff
 	}
 
-	return float32(math.Sqrt(float64(sum)))
+	return float32(math.Sqrt(float64(s0 + s1 + s2 + s3)))
 }
 
 func (s *SIMDVectorOps) dotProductVectorized(a, b []float32) float32 {
@@ -327,30 +330,23 @@ func (s *SIMDVectorOps) dotProductVectorized(a, b []float32) float32 {
 		return 0.0
 	}
 
-	const chunkSize = 8
-	length := len(a)
-	chunks := length / chunkSize
-	remainder := length % chunkSize
-
-	var sum float32
-
-	// Process full chunks
-	for i := 0; i < chunks; i++ {
-		start := i * chunkSize
-		end := start + chunkSize
-
-		for j := start; j < end; j++ {
-			sum += a[j] * b[j]
-		}
+	// Four independent sums let the CPU overlap the multiply-adds
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		a4, b4 := a[i:i+4:i+4], b[i:i+4:i+4]
+		s0 += a4[0] * b4[0]
+		s1 += a4[1] * b4[1]
+		s2 += a4[2] * b4[2]
+		s3 += a4[3] * b4[3]
 	}
 
 	// Process remainder
-	start := chunks * chunkSize
-	for i := start; i < start+remainder; i++ {
-		sum += a[i] * b[i]
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
 	}
 
-	return sum
+	return s0 + s1 + s2 + s3
 }
 
 func (s *SIMDVectorOps) normalizeVectorized(vector []float32) {
@@ -499,6 +495,59 @@ func (s *SIMDVectorOps) normalizeBatchParallel(vectors [][]float32) {
 	wg.Wait()
 }
 
+// kernelTolerance is the relative difference allowed between the vectorized
+// and scalar results, which sum in different orders
+const kernelTolerance = 1e-4
+
+// VerifyKernels checks the vectorized kernels against the scalar ones on
+// pseudo-random vectors of lengths that are not multiples of the unrolling,
+// as run by the startup self-test
+func VerifyKernels() error {
+	s := NewSIMDVectorOps(nil)
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int) []float32 {
+		vector := make([]float32, n)
+		for i := range vector {
+			vector[i] = rng.Float32()*2 - 1
+		}
+		return vector
+	}
+	check := func(kernel string, n int, vectorized, scalar float32) error {
+		diff := math.Abs(float64(vectorized - scalar))
+		if diff > kernelTolerance*math.Max(1, math.Abs(float64(scalar))) {
+			return fmt.Errorf("%s at %d dimensions: vectorized %v, scalar %v", kernel, n, vectorized, scalar)
+		}
+		return nil
+	}
+
+	for _, n := range []int{1, 3, 4, 7, 8, 9, 17, 31, 128, 384, 1536} {
+		a, b := random(n), random(n)
+		if err := check("cosine", n, s.cosineSimilarityVectorized(a, b), s.cosineSimilarityScalar(a, b)); err != nil {
+			return err
+		}
+		if err := check("euclidean", n, s.euclideanDistanceVectorized(a, b), s.euclideanDistanceScalar(a, b)); err != nil {
+			return err
+		}
+		if err := check("dot product", n, s.dotProductVectorized(a, b), s.dotProductScalar(a, b)); err != nil {
+			return err
+		}
+	}
+
+	// The batch kernels, including the parallel one
+	query := random(17)
+	vectors := make([][]float32, 2*s.config.ChunkSize+1)
+	for i := range vectors {
+		vectors[i] = random(17)
+	}
+	scalar := s.cosineSimilarityBatchScalar(query, vectors)
+	for i, score := range s.cosineSimilarityBatchParallel(query, vectors) {
+		if err := check("parallel cosine batch", 17, score, scalar[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Benchmark utilities
 
 // BenchmarkSIMDOperations runs performance benchmarks
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// scoredVector is a stored vector with its score against a query
+type scoredVector struct {
+	vector *Vector
+	score  float32
+}
+
+// worse reports whether a ranks after b, the reverse of resultLess
+func (a scoredVector) worse(b scoredVector) bool {
+	if a.score != b.score {
+		return a.score < b.score
+	}
+	return a.vector.ID > b.vector.ID
+}
+
+// topK keeps the k best vectors offered to it, in a heap with the worst of
+// them on top, so that a scan of n vectors costs O(n log k) instead of a sort
+// of all of them
+type topK struct {
+	k     int
+	items []scoredVector
+}
+
+// newTopK returns an empty top-k selection
+func newTopK(k int) *topK {
+	return &topK{k: k, items: make([]scoredVector, 0, min(k, 1024))}
+}
+
+func (t *topK) Len() int           { return len(t.items) }
+func (t *topK) Less(i, j int) bool { return t.items[i].worse(t.items[j]) }
+func (t *topK) Swap(i, j int)      { t.items[i], t.items[j] = t.items[j], t.items[i] }
+func (t *topK) Push(x any)         { t.items = append(t.items, x.(scoredVector)) }
+func (t *topK) Pop() any {
+	last := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	return last
+}
+
+// offer adds a vector if it ranks among the k best so far
+func (t *topK) offer(vector *Vector, score float32) {
+	item := scoredVector{vector: vector, score: score}
+	if len(t.items) < t.k {
+		heap.Push(t, item)
+		return
+	}
+	if t.k == 0 || !t.items[0].worse(item) {
+		return
+	}
+	t.items[0] = item
+	heap.Fix(t, 0)
+}
+
+// merge offers every vector of other
+func (t *topK) merge(other *topK) {
+	for _, item := range other.items {
+		t.offer(item.vector, item.score)
+	}
+}
+
+// sorted returns the kept vectors, best first
+func (t *topK) sorted() []scoredVector {
+	items := make([]scoredVector, len(t.items))
+	copy(items, t.items)
+	sort.Slice(items, func(i, j int) bool {
+		return items[j].worse(items[i])
+	})
+	return items
+}
+
+// rankedResults turns scored vectors into search results, in the same order
+func (c *VittoriaCollection) rankedResults(items []scoredVector, req *SearchRequest) []*SearchResult {
+	results := make([]*SearchResult, len(items))
+	for i, item := range items {
+		results[i] = c.newSearchResult(item.vector, item.score, req)
+	}
+	return results
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSearchTieBreaking(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+
+	ids := []string{"k", "c", "h", "a", "l", "e", "j", "b", "g", "d", "i", "f"}
+	for _, indexType := range []IndexType{IndexTypeFlat, IndexTypeHNSW} {
+		name := "ties_" + indexType.String()
+		if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: name, Dimensions: 2, IndexType: indexType}); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+		collection, _ := db.GetCollection(ctx, name)
+		for _, id := range ids {
+			if err := collection.Insert(ctx, &Vector{ID: id, Vector: []float32{1, 1}}); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+
+		// Every record scores the same, so the pages follow the IDs
+		var got []string
+		for offset := 0; offset < len(ids); offset += 4 {
+			response, err := collection.Search(ctx, &SearchRequest{Vector: []float32{1, 1}, Limit: 4, Offset: offset})
+			if err != nil {
+				t.Fatalf("%s: Search failed: %v", name, err)
+			}
+			for _, result := range response.Results {
+				got = append(got, result.ID)
+			}
+		}
+		want := "a b c d e f g h i j k l"
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s: pages = %v, want %s", name, got, want)
+		}
+	}
+}
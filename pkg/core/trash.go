@@ -0,0 +1,235 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// trashDirName is the directory of the data directory dropped collections
+// are moved to while trash retention is enabled. Collection directories
+// inside it are named "<collection>.<unix nanoseconds>".
+const trashDirName = ".trash"
+
+// trashInfoFile records when a trashed collection was dropped
+const trashInfoFile = "trash.json"
+
+// TrashedCollection describes a dropped collection kept in the trash
+type TrashedCollection struct {
+	Name      string    `json:"name"`
+	DroppedAt time.Time `json:"dropped_at"`
+	ExpiresAt time.Time `json:"expires_at"` // When it is permanently deleted
+	Size      int64     `json:"size"`       // Bytes on disk
+
+	dir string
+}
+
+// trashDir returns the directory dropped collections are moved to
+func (db *VittoriaDB) trashDir() string {
+	return filepath.Join(db.dataDir, trashDirName)
+}
+
+// trashCollection moves the files of a dropped collection to the trash; the
+// caller holds mu
+func (db *VittoriaDB) trashCollection(name string, retention time.Duration) error {
+	collectionDir, err := collectionPath(db.dataDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(db.trashDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	now := time.Now()
+	target := filepath.Join(db.trashDir(), name+"."+strconv.FormatInt(now.UnixNano(), 10))
+	if err := os.Rename(collectionDir, target); err != nil {
+		return fmt.Errorf("failed to move collection to trash: %w", err)
+	}
+
+	data, err := json.Marshal(&TrashedCollection{Name: name, DroppedAt: now, ExpiresAt: now.Add(retention)})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(target, trashInfoFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write trash info: %w", err)
+	}
+	return nil
+}
+
+// ListTrash returns the dropped collections that can still be restored,
+// most recently dropped first
+func (db *VittoriaDB) ListTrash(ctx context.Context) ([]*TrashedCollection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return nil, errorf(ErrClosed, "database is closed")
+	}
+	return db.readTrash()
+}
+
+// readTrash reads the trash directory; the caller holds mu
+func (db *VittoriaDB) readTrash() ([]*TrashedCollection, error) {
+	entries, err := os.ReadDir(db.trashDir())
+	if os.IsNotExist(err) {
+		return []*TrashedCollection{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	trashed := make([]*TrashedCollection, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(db.trashDir(), entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, trashInfoFile))
+		if err != nil {
+			continue
+		}
+		var collection TrashedCollection
+		if err := json.Unmarshal(data, &collection); err != nil {
+			continue
+		}
+		collection.dir = dir
+		collection.Size = dirSize(dir)
+		trashed = append(trashed, &collection)
+	}
+
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].DroppedAt.After(trashed[j].DroppedAt)
+	})
+	return trashed, nil
+}
+
+// RestoreCollection brings back the most recently dropped collection named
+// name from the trash. It fails when a collection with that name exists.
+func (db *VittoriaDB) RestoreCollection(ctx context.Context, name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.writable(); err != nil {
+		return err
+	}
+	if _, exists := db.collections[name]; exists {
+		return errorf(ErrAlreadyExists, "collection '%s' already exists", name)
+	}
+	if err := db.checkCollectionLimit(); err != nil {
+		return err
+	}
+
+	trashed, err := db.readTrash()
+	if err != nil {
+		return err
+	}
+	var latest *TrashedCollection
+	for _, t := range trashed {
+		if t.Name == name {
+			latest = t
+			break
+		}
+	}
+	if latest == nil {
+		return errorf(ErrNotFound, "collection '%s' not found in trash", name)
+	}
+
+	collectionDir, err := collectionPath(db.dataDir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(latest.dir, collectionDir); err != nil {
+		return fmt.Errorf("failed to move collection out of trash: %w", err)
+	}
+	os.Remove(filepath.Join(collectionDir, trashInfoFile))
+
+	collection, err := openCollection(name, db.dataDir, db.indexOptions(name), false)
+	if err != nil {
+		return fmt.Errorf("failed to open restored collection: %w", err)
+	}
+
+	// Remote shards were dropped, and so trashed, on their nodes
+	if err := collection.restoreRemoteShards(ctx); err != nil {
+		fmt.Printf("Error restoring shards of %s: %v\n", name, err)
+	}
+
+	db.collections[name] = collection
+	return nil
+}
+
+// purgeTrash permanently deletes the trashed collections past their
+// retention and returns how many were deleted
+func (db *VittoriaDB) purgeTrash(now time.Time) (int, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.closed {
+		return 0, nil
+	}
+	trashed, err := db.readTrash()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, t := range trashed {
+		if now.Before(t.ExpiresAt) {
+			continue
+		}
+		if err := os.RemoveAll(t.dir); err != nil {
+			return purged, fmt.Errorf("failed to delete trashed collection %s: %w", t.Name, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// runTrashPurge periodically deletes expired trash until stop is closed
+func (db *VittoriaDB) runTrashPurge(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if _, err := db.purgeTrash(now); err != nil {
+				fmt.Printf("Error purging trash: %v\n", err)
+			}
+		}
+	}
+}
+
+// trashPurgeInterval returns how often the trash is checked for expired
+// collections: a tenth of the retention, between a minute and an hour
+func trashPurgeInterval(retention time.Duration) time.Duration {
+	interval := retention / 10
+	if interval < time.Minute {
+		return time.Minute
+	}
+	if interval > time.Hour {
+		return time.Hour
+	}
+	return interval
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTrash_DropAndRestore(t *testing.T) {
+	ctx := context.Background()
+	config := &Config{
+		DataDir: t.TempDir(),
+		Storage: StorageConfig{TrashRetention: time.Hour},
+	}
+
+	db := openTestDatabase(t, config)
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", Dimensions: 4, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	if err := collection.Insert(ctx, &Vector{ID: "a", Vector: []float32{1, 0, 0, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := collection.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if err := db.DropCollection(ctx, "docs"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if _, err := db.GetCollection(ctx, "docs"); err == nil {
+		t.Fatal("dropped collection should not be found")
+	}
+	trashed, err := db.ListTrash(ctx)
+	if err != nil || len(trashed) != 1 || trashed[0].Name != "docs" {
+		t.Fatalf("expected docs in trash, got %v (%v)", trashed, err)
+	}
+
+	if err := db.RestoreCollection(ctx, "docs"); err != nil {
+		t.Fatalf("RestoreCollection failed: %v", err)
+	}
+	collection, err = db.GetCollection(ctx, "docs")
+	if err != nil {
+		t.Fatalf("restored collection not found: %v", err)
+	}
+	if _, err := collection.Get(ctx, "a"); err != nil {
+		t.Errorf("restored collection lost its vectors: %v", err)
+	}
+	if err := db.RestoreCollection(ctx, "docs"); err == nil {
+		t.Error("restoring over an existing collection should fail")
+	}
+
+	// Past the retention, trashed collections are purged
+	if err := db.DropCollection(ctx, "docs"); err != nil {
+		t.Fatalf("DropCollection failed: %v", err)
+	}
+	if purged, err := db.purgeTrash(time.Now().Add(2 * time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("expected 1 purged collection, got %d (%v)", purged, err)
+	}
+	if err := db.RestoreCollection(ctx, "docs"); err == nil {
+		t.Error("purged collection should not be restorable")
+	}
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ExpiresAtField is the metadata field holding a vector's expiration time, as
+// an RFC 3339 timestamp or Unix seconds. Expired vectors are excluded from
+// reads and searches and removed by the database janitor.
+const ExpiresAtField = "expires_at"
+
+// expirationTime returns the expiration time stored in metadata, if any
+func expirationTime(metadata map[string]interface{}) (time.Time, bool, error) {
+	value, exists := metadata[ExpiresAtField]
+	if !exists || value == nil {
+		return time.Time{}, false, nil
+	}
+
+	var seconds float64
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s '%s': expected RFC 3339 timestamp", ExpiresAtField, v)
+		}
+		return t, true, nil
+	case time.Time:
+		return v, true, nil
+	case float64:
+		seconds = v
+	case float32:
+		seconds = float64(v)
+	case int:
+		seconds = float64(v)
+	case int64:
+		seconds = float64(v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid %s '%s'", ExpiresAtField, v)
+		}
+		seconds = f
+	default:
+		return time.Time{}, false, fmt.Errorf("invalid %s: expected RFC 3339 timestamp or Unix seconds", ExpiresAtField)
+	}
+
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), true, nil
+}
+
+// isExpired reports whether the vector's expiration time is at or before now
+func isExpired(vector *Vector, now time.Time) bool {
+	expiresAt, ok, err := expirationTime(vector.Metadata)
+	return ok && err == nil && !expiresAt.After(now)
+}
+
+// DeleteExpired removes every expired vector from the collection and returns
+// how many were removed. Remote shards run their own janitor.
+func (c *VittoriaCollection) DeleteExpired(ctx context.Context) (int, error) {
+	return c.deleteExpired(ctx, time.Now())
+}
+
+// deleteExpired removes the vectors expired at now
+func (c *VittoriaCollection) deleteExpired(ctx context.Context, now time.Time) (int, error) {
+	if c.readOnly {
+		return 0, c.errReadOnly()
+	}
+	if c.isSharded() {
+		c.shardMu.RLock()
+		defer c.shardMu.RUnlock()
+
+		removed := 0
+		for _, s := range c.shards {
+			if local, ok := s.(*VittoriaCollection); ok {
+				n, err := local.deleteExpired(ctx, now)
+				removed += n
+				if err != nil {
+					return removed, err
+				}
+			}
+		}
+		return removed, nil
+	}
+
+	// Scan under the read lock so collections without expired vectors do not block writers
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return 0, nil
+	}
+	var expired []string
+	for id, vector := range c.vectors {
+		if isExpired(vector, now) {
+			expired = append(expired, id)
+		}
+	}
+	c.mu.RUnlock()
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	removed := 0
+	for _, id := range expired {
+		// The vector may have been replaced or deleted since the scan
+		vector, exists := c.vectors[id]
+		if !exists || !isExpired(vector, now) {
+			continue
+		}
+		if err := c.indexRemove(ctx, vector); err != nil {
+			c.mu.Unlock()
+			return removed, fmt.Errorf("failed to remove vector from index: %w", err)
+		}
+		delete(c.vectors, id)
+		c.changes.publish(ChangeDelete, vector)
+		removed++
+	}
+	if removed > 0 {
+		c.touch()
+	}
+	c.mu.Unlock()
+
+	return removed, nil
+}
+
+// runJanitor periodically deletes expired vectors until stop is closed
+func (db *VittoriaDB) runJanitor(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			db.expireVectors(context.Background())
+		}
+	}
+}
+
+// expireVectors deletes expired vectors from every collection
+func (db *VittoriaDB) expireVectors(ctx context.Context) {
+	db.mu.RLock()
+	collections := make([]*VittoriaCollection, 0, len(db.collections))
+	for _, collection := range db.collections {
+		collections = append(collections, collection)
+	}
+	db.mu.RUnlock()
+
+	now := db.now()
+	for _, collection := range collections {
+		if _, err := collection.deleteExpired(ctx, now); err != nil {
+			fmt.Printf("Error expiring vectors in collection %s: %v\n", collection.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+// remainingIDs returns the sorted IDs of the vectors of a collection
+func remainingIDs(c *VittoriaCollection) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids := make([]string, 0, len(c.vectors))
+	for _, vector := range c.vectors {
+		ids = append(ids, vector.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// insertExpiring inserts vectors with the given expires_at values, by ID, and
+// one named "garbled" whose expires_at can't be parsed, as stored before
+// expires_at was validated
+func insertExpiring(t *testing.T, collection Collection, expiresAt map[string]interface{}) {
+	t.Helper()
+	ctx := context.Background()
+	for id, value := range expiresAt {
+		metadata := map[string]interface{}{}
+		if value != nil {
+			metadata[ExpiresAtField] = value
+		}
+		if err := collection.Insert(ctx, &Vector{ID: id, Vector: []float32{1, 0}, Metadata: metadata}); err != nil {
+			t.Fatalf("Insert %s failed: %v", id, err)
+		}
+	}
+	if err := collection.Insert(ctx, &Vector{ID: "garbled", Vector: []float32{1, 0}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	c := collection.(*VittoriaCollection)
+	c.mu.Lock()
+	c.vectors[vectorKey("", "garbled")].Metadata[ExpiresAtField] = "next tuesday"
+	c.mu.Unlock()
+}
+
+func TestDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t, nil)
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "sessions", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "sessions")
+	c := collection.(*VittoriaCollection)
+
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	insertExpiring(t, collection, map[string]interface{}{
+		"past":    now.Add(-time.Hour).Format(time.RFC3339),
+		"due":     now.Format(time.RFC3339),
+		"seconds": float64(now.Add(-time.Minute).Unix()),
+		"future":  now.Add(time.Hour).Format(time.RFC3339),
+		"forever": nil,
+	})
+
+	// Vectors expire at their expiration time; an unparsable one never does
+	removed, err := c.deleteExpired(ctx, now)
+	if err != nil {
+		t.Fatalf("deleteExpired failed: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d vectors, want 3", removed)
+	}
+	if ids := remainingIDs(c); len(ids) != 3 || ids[0] != "forever" || ids[1] != "future" || ids[2] != "garbled" {
+		t.Errorf("remaining vectors %v, want forever, future and garbled", ids)
+	}
+
+	// Nothing more is due until the clock passes the next expiration time
+	if removed, _ := c.deleteExpired(ctx, now.Add(time.Minute)); removed != 0 {
+		t.Errorf("removed %d vectors before they expired", removed)
+	}
+	if removed, _ := c.deleteExpired(ctx, now.Add(2*time.Hour)); removed != 1 {
+		t.Errorf("removed %d vectors past the last expiration time, want 1", removed)
+	}
+	if ids := remainingIDs(c); len(ids) != 2 {
+		t.Errorf("remaining vectors %v, want forever and garbled", ids)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	db := NewDatabase()
+	db.now = func() time.Time { return now }
+	if err := db.Open(ctx, &Config{DataDir: t.TempDir(), Storage: StorageConfig{TTLCheckInterval: 10 * time.Millisecond}}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "sessions", Dimensions: 2, Metric: DistanceMetricCosine, IndexType: IndexTypeFlat}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "sessions")
+	c := collection.(*VittoriaCollection)
+	insertExpiring(t, collection, map[string]interface{}{
+		"past":   now.Add(-time.Second).Format(time.RFC3339),
+		"future": now.Add(time.Hour).Format(time.RFC3339),
+	})
+
+	// The janitor removes the vectors expired by its clock, not the wall clock
+	deadline := time.Now().Add(5 * time.Second)
+	for len(remainingIDs(c)) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("remaining vectors %v, want future and garbled", remainingIDs(c))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if ids := remainingIDs(c); len(ids) != 2 || ids[0] != "future" || ids[1] != "garbled" {
+		t.Errorf("remaining vectors %v, want future and garbled", ids)
+	}
+}
@@ -2,10 +2,16 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 
+	"github.com/antonellof/VittoriaDB/pkg/config"
 	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/objectstore"
+	"github.com/antonellof/VittoriaDB/pkg/scheduler"
 )
 
 // DistanceMetric represents the distance calculation method
@@ -16,6 +22,8 @@ const (
 	DistanceMetricEuclidean
 	DistanceMetricDotProduct
 	DistanceMetricManhattan
+	DistanceMetricHamming // Binary vectors: number of differing bits
+	DistanceMetricJaccard // Binary vectors: 1 - shared set bits / all set bits
 )
 
 func (d DistanceMetric) String() string {
@@ -28,11 +36,60 @@ func (d DistanceMetric) String() string {
 		return "dot_product"
 	case DistanceMetricManhattan:
 		return "manhattan"
+	case DistanceMetricHamming:
+		return "hamming"
+	case DistanceMetricJaccard:
+		return "jaccard"
 	default:
 		return "unknown"
 	}
 }
 
+// Binary reports whether the metric compares binary vectors, whose components
+// are all 0 or 1
+func (d DistanceMetric) Binary() bool {
+	return d == DistanceMetricHamming || d == DistanceMetricJaccard
+}
+
+// DistanceMetrics lists the distance metrics collections can use
+var DistanceMetrics = []DistanceMetric{DistanceMetricCosine, DistanceMetricEuclidean, DistanceMetricDotProduct, DistanceMetricManhattan, DistanceMetricHamming, DistanceMetricJaccard}
+
+// ParseDistanceMetric parses the name of a distance metric such as "cosine"
+func ParseDistanceMetric(name string) (DistanceMetric, error) {
+	for _, m := range DistanceMetrics {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid metric '%s': use one of %s", name, allowedValues(DistanceMetrics))
+}
+
+// UnmarshalJSON accepts a distance metric either as its number or its name,
+// and rejects unknown ones
+func (d *DistanceMetric) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		m, err := ParseDistanceMetric(name)
+		if err != nil {
+			return err
+		}
+		*d = m
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("metric must be a name or a number: use one of %s", allowedValues(DistanceMetrics))
+	}
+	for _, m := range DistanceMetrics {
+		if int(m) == n {
+			*d = m
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid metric %d: use one of %s", n, allowedValues(DistanceMetrics))
+}
+
 // IndexType represents the type of vector index
 type IndexType int
 
@@ -55,18 +112,80 @@ func (i IndexType) String() string {
 	}
 }
 
+// IndexTypes lists the index types collections can be created with
+var IndexTypes = []IndexType{IndexTypeFlat, IndexTypeHNSW}
+
+// ParseIndexType parses the name of an index type such as "hnsw"
+func ParseIndexType(name string) (IndexType, error) {
+	for _, t := range IndexTypes {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid index type '%s': use one of %s", name, allowedValues(IndexTypes))
+}
+
+// UnmarshalJSON accepts an index type either as its number or its name, and
+// rejects unknown ones
+func (i *IndexType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		t, err := ParseIndexType(name)
+		if err != nil {
+			return err
+		}
+		*i = t
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("index type must be a name or a number: use one of %s", allowedValues(IndexTypes))
+	}
+	for _, t := range IndexTypes {
+		if int(t) == n {
+			*i = t
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid index type %d: use one of %s", n, allowedValues(IndexTypes))
+}
+
+// allowedValues lists enum values with their numbers, as "cosine (0), ..."
+func allowedValues[T interface {
+	~int
+	String() string
+}](values []T) string {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = fmt.Sprintf("%s (%d)", v, int(v))
+	}
+	return strings.Join(names, ", ")
+}
+
 // Vector represents a vector with metadata
 type Vector struct {
-	ID       string                 `json:"id"`
-	Vector   []float32              `json:"vector"`
-	Metadata map[string]interface{} `json:"metadata"`
+	ID        string                 `json:"id"`
+	Namespace string                 `json:"namespace,omitempty"` // Tenant the vector belongs to; empty is the default namespace
+	Vector    []float32              `json:"vector"`
+	Metadata  map[string]interface{} `json:"metadata"`
+
+	bits []uint64 // Vector packed one bit per component, in collections with a binary metric
+
+	// Quantized collections keep the vector on disk, and codes in memory
+	codes  []uint8
+	norm   float32 // Norm of the original vector
+	offset int64   // Position of the original vector in the originals file
+
+	halves []uint16 // Vector in half precision, in float16 collections
 }
 
 // TextVector represents text that will be automatically vectorized
 type TextVector struct {
-	ID       string                 `json:"id"`
-	Text     string                 `json:"text"`
-	Metadata map[string]interface{} `json:"metadata"`
+	ID        string                 `json:"id"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Text      string                 `json:"text"`
+	Metadata  map[string]interface{} `json:"metadata"`
 }
 
 // ContentStorageConfig represents how original content is stored
@@ -89,13 +208,36 @@ func DefaultContentStorageConfig() *ContentStorageConfig {
 
 // CreateCollectionRequest represents a collection creation request
 type CreateCollectionRequest struct {
-	Name             string                       `json:"name"`
-	Dimensions       int                          `json:"dimensions"`
-	Metric           DistanceMetric               `json:"metric"`
-	IndexType        IndexType                    `json:"index_type"`
-	Config           map[string]interface{}       `json:"config"`
-	VectorizerConfig *embeddings.VectorizerConfig `json:"vectorizer_config,omitempty"`
-	ContentStorage   *ContentStorageConfig        `json:"content_storage,omitempty"`
+	Name              string                       `json:"name"`
+	Dimensions        int                          `json:"dimensions"`
+	Metric            DistanceMetric               `json:"metric"`
+	IndexType         IndexType                    `json:"index_type"`
+	Config            map[string]interface{}       `json:"config"`
+	VectorizerConfig  *embeddings.VectorizerConfig `json:"vectorizer_config,omitempty"`
+	VectorizerProfile string                       `json:"vectorizer_profile,omitempty"` // A vectorizer profile of the configuration, instead of vectorizer_config
+	ContentStorage    *ContentStorageConfig        `json:"content_storage,omitempty"`
+	Sharding          *ShardingConfig              `json:"sharding,omitempty"`
+	ExpectedCount     int                          `json:"expected_count,omitempty"` // Capacity hint used to pre-size internal structures
+	BulkLoad          bool                         `json:"bulk_load,omitempty"`      // Start in bulk-load mode, deferring index construction
+	Internal          bool                         `json:"internal,omitempty"`       // Hide from default listings and protect from deletion
+	Quantization      *QuantizationConfig          `json:"quantization,omitempty"`   // Keep vectors quantized in memory, in full precision on disk
+	IndexedFields     []string                     `json:"indexed_fields,omitempty"` // Metadata fields given range indexes, which narrow filtered searches
+}
+
+// UpdateCollectionRequest represents a request to update collection settings.
+// Nil fields are left unchanged.
+type UpdateCollectionRequest struct {
+	ExpectedCount *int  `json:"expected_count,omitempty"`
+	BulkLoad      *bool `json:"bulk_load,omitempty"` // false ends a bulk load and builds the index
+	Internal      *bool `json:"internal,omitempty"`  // false makes an internal collection a regular one
+
+	// Vectorizer switches the model texts are embedded with from now on, such
+	// as before re-embedding the collection; the dimensions cannot change
+	Vectorizer *embeddings.VectorizerConfig `json:"vectorizer_config,omitempty"`
+
+	// EfSearch changes the beam width of an HNSW collection's searches, at
+	// once and without a rebuild
+	EfSearch *int `json:"ef_search,omitempty"`
 }
 
 // SearchRequest represents a vector search request
@@ -108,6 +250,15 @@ type SearchRequest struct {
 	IncludeMetadata bool                   `json:"include_metadata"`
 	IncludeContent  bool                   `json:"include_content"` // Whether to include original content in results
 	SearchParams    map[string]interface{} `json:"search_params"`
+	Namespace       string                 `json:"namespace,omitempty"`        // Only vectors in this namespace are searched
+	CountOnly       bool                   `json:"count_only,omitempty"`       // Only count the matching vectors, returned as Total with no results
+	MinScore        *float32               `json:"min_score,omitempty"`        // Vectors scoring below this are left out, normalized if NormalizeScores is set
+	NormalizeScores bool                   `json:"normalize_scores,omitempty"` // Report scores on a [0,1] scale for every metric, with the raw distance
+	Rescore         bool                   `json:"rescore,omitempty"`          // Quantized collections: rank the best candidates by their original vectors
+	Explain         bool                   `json:"explain,omitempty"`          // Describe how the search was run in the response
+	ReadOnlyResults bool                   `json:"-"`                          // Embedded use: results share the stored vector and metadata, as GetView does, bypassing the search cache
+
+	filterPlan *Filter // Filter with its clauses in evaluation order, set by planSearch
 }
 
 // SearchResponse represents search results
@@ -116,15 +267,20 @@ type SearchResponse struct {
 	Total     int64           `json:"total"`
 	TookMS    int64           `json:"took_ms"`
 	RequestID string          `json:"request_id"`
+	Explain   *SearchExplain  `json:"explain,omitempty"` // How the search was run, when it asked
 }
 
 // SearchResult represents a single search result
 type SearchResult struct {
-	ID       string                 `json:"id"`
-	Score    float32                `json:"score"`
-	Vector   []float32              `json:"vector,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
-	Content  string                 `json:"content,omitempty"` // Original content if available
+	ID          string                 `json:"id"`
+	Score       float32                `json:"score"`
+	VectorScore float32                `json:"vector_score,omitempty"` // Similarity score before reranking, set on reranked results
+	Distance    *float32               `json:"distance,omitempty"`     // Raw distance to the query, set when scores are normalized
+	Vector      []float32              `json:"vector,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Content     string                 `json:"content,omitempty"` // Original content if available
+
+	expiresAt time.Time // When the vector expires, zero if never; bounds how long the result is cached
 }
 
 // HasContent returns true if the search result contains original content
@@ -174,17 +330,39 @@ const (
 	FilterOpNotIn    FilterOp = "not_in"
 	FilterOpContains FilterOp = "contains"
 	FilterOpExists   FilterOp = "exists"
+
+	FilterOpGeoRadius FilterOp = "geo_radius" // A geo point within a radius, see GeoRadius
+	FilterOpGeoBBox   FilterOp = "geo_bbox"   // A geo point within a box, see GeoBBox
 )
 
+// FilterOperators lists the operators of filter conditions
+var FilterOperators = []FilterOp{
+	FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte,
+	FilterOpIn, FilterOpNotIn, FilterOpContains, FilterOpExists,
+	FilterOpGeoRadius, FilterOpGeoBBox,
+}
+
 // CollectionInfo represents collection metadata
 type CollectionInfo struct {
-	Name        string         `json:"name"`
-	Dimensions  int            `json:"dimensions"`
-	Metric      DistanceMetric `json:"metric"`
-	IndexType   IndexType      `json:"index_type"`
-	VectorCount int64          `json:"vector_count"`
-	Created     time.Time      `json:"created"`
-	Modified    time.Time      `json:"modified"`
+	Name          string              `json:"name"`
+	Dimensions    int                 `json:"dimensions"`
+	Metric        DistanceMetric      `json:"metric"`
+	IndexType     IndexType           `json:"index_type"`
+	VectorCount   int64               `json:"vector_count"`
+	Shards        int                 `json:"shards,omitempty"`
+	ExpectedCount int                 `json:"expected_count,omitempty"`
+	BulkLoad      bool                `json:"bulk_load,omitempty"`
+	Internal      bool                `json:"internal,omitempty"`
+	Group         string              `json:"group,omitempty"` // Collection group the collection stores a field of
+	Quantization  *QuantizationConfig `json:"quantization,omitempty"`
+	IndexedFields []string            `json:"indexed_fields,omitempty"` // Metadata fields with range indexes
+	HNSW          *HNSWParams         `json:"hnsw,omitempty"`           // Parameters in effect, for HNSW collections
+	IndexRebuild  *IndexRebuild       `json:"index_rebuild,omitempty"`  // Background index rebuild in progress
+	Created       time.Time           `json:"created"`
+	Modified      time.Time           `json:"modified"`
+
+	Vectorizer      *embeddings.VectorizerConfig `json:"vectorizer,omitempty"` // Without inline API keys
+	EmbeddingModels []*EmbeddingModel            `json:"embedding_models,omitempty"`
 }
 
 // HealthStatus represents system health
@@ -206,6 +384,13 @@ type DatabaseStats struct {
 	QueriesTotal    int64              `json:"queries_total"`
 	QueriesPerSec   float64            `json:"queries_per_sec"`
 	AvgQueryLatency float64            `json:"avg_query_latency"`
+
+	InternalCollections int `json:"internal_collections,omitempty"` // Left out of Collections, but counted in the totals
+
+	Maintenance []*scheduler.JobStatus `json:"maintenance,omitempty"`          // Scheduled maintenance jobs and their last run
+	Deferred    []*DeferredMaintenance `json:"deferred_maintenance,omitempty"` // Heavy jobs waiting for a maintenance window
+	SearchCache *SearchCacheStats      `json:"search_cache,omitempty"`
+	Memory      *MemoryStats           `json:"memory,omitempty"` // Set when performance.memory_limit is
 }
 
 // CollectionStats represents collection statistics
@@ -214,17 +399,59 @@ type CollectionStats struct {
 	VectorCount  int64     `json:"vector_count"`
 	Dimensions   int       `json:"dimensions"`
 	IndexType    IndexType `json:"index_type"`
+	MemoryBytes  int64     `json:"memory_bytes"` // Estimated memory of the vectors, metadata and index
 	IndexSize    int64     `json:"index_size"`
 	LastModified time.Time `json:"last_modified"`
 }
 
 // Config represents database configuration
 type Config struct {
-	DataDir     string        `yaml:"data_dir"`
-	Server      ServerConfig  `yaml:"server"`
-	Storage     StorageConfig `yaml:"storage"`
-	Index       IndexConfig   `yaml:"index"`
-	Performance PerfConfig    `yaml:"performance"`
+	DataDir     string                `yaml:"data_dir"`
+	ReadOnly    bool                  `yaml:"read_only"` // Share the data directory with the process writing it: take no lock, write nothing
+	Server      ServerConfig          `yaml:"server"`
+	Storage     StorageConfig         `yaml:"storage"`
+	Index       IndexConfig           `yaml:"index"`
+	Performance PerfConfig            `yaml:"performance"`
+	Parallel    *ParallelSearchConfig `yaml:"parallel"` // Brute-force search workers (nil for the defaults)
+	Cache       *SearchCacheConfig    `yaml:"cache"`    // Search result cache (nil for the defaults)
+
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+	Limits      LimitsConfig      `yaml:"limits"`
+
+	ObjectStorage objectstore.Config `yaml:"object_storage"` // Credentials for s3:// and gs:// backup directories
+
+	// VectorizerProfiles are the named vectorizers collections can be created
+	// with, whose API keys stay in the configuration
+	VectorizerProfiles map[string]*embeddings.VectorizerConfig `yaml:"vectorizer_profiles"`
+}
+
+// MaintenanceConfig schedules background maintenance jobs
+type MaintenanceConfig struct {
+	Timezone        string           `yaml:"timezone"`         // IANA zone schedules are evaluated in; empty for local time
+	BackupDir       string           `yaml:"backup_dir"`       // Where backup jobs write archives: an s3:// or gs:// URI, or a path relative to the data directory unless absolute
+	BackupRetention int              `yaml:"backup_retention"` // Archives kept by backup jobs (0 keeps all)
+	Jobs            []MaintenanceJob `yaml:"jobs"`
+
+	// Windows limit when heavy jobs may run on the collections they match;
+	// collections matched by none run them at any time
+	Windows []MaintenanceWindow `yaml:"windows"`
+}
+
+// MaintenanceWindow is a recurring period during which heavy jobs such as
+// compaction and index rebuilds may run on the collections it matches
+type MaintenanceWindow struct {
+	Collections []string      `yaml:"collections"` // Collection names or glob patterns (empty for all)
+	Schedule    string        `yaml:"schedule"`    // Cron expression of when the window opens, such as "0 1 * * *"
+	Duration    time.Duration `yaml:"duration"`    // How long the window stays open
+}
+
+// MaintenanceJob is a maintenance task run on a cron schedule
+type MaintenanceJob struct {
+	Name        string   `yaml:"name"`
+	Type        string   `yaml:"type"`        // compact, backup, dedupe, retention or index_check
+	Schedule    string   `yaml:"schedule"`    // Cron expression, such as "0 3 * * *"
+	Collections []string `yaml:"collections"` // Collection names or glob patterns (empty for all)
+	Repair      bool     `yaml:"repair"`      // index_check: repair the graphs found damaged
 }
 
 // ServerConfig represents HTTP server configuration
@@ -243,14 +470,26 @@ type StorageConfig struct {
 	CacheSize   int  `yaml:"cache_size"`
 	SyncWrites  bool `yaml:"sync_writes"`
 	Compression bool `yaml:"compression"`
+
+	TTLCheckInterval time.Duration `yaml:"ttl_check_interval"` // How often expired vectors are removed (0 disables the janitor)
+	TrashRetention   time.Duration `yaml:"trash_retention"`    // How long dropped collections can be restored (0 deletes them at once)
+	RecoverCorrupted bool          `yaml:"recover_corrupted"`  // Load the intact segments of damaged vector files instead of failing
+}
+
+// LimitsConfig bounds what collection creation accepts
+type LimitsConfig struct {
+	MaxDimensions    int      `yaml:"max_dimensions"`    // Dimensions of a collection (0 for MaxDimensions)
+	MaxCollections   int      `yaml:"max_collections"`   // Collections of the database, group fields included (0 for no limit)
+	ReservedPrefixes []string `yaml:"reserved_prefixes"` // Name prefixes new collections cannot start with
 }
 
 // IndexConfig represents index configuration
 type IndexConfig struct {
-	DefaultType   IndexType      `yaml:"default_type"`
-	DefaultMetric DistanceMetric `yaml:"default_metric"`
-	HNSWConfig    HNSWConfig     `yaml:"hnsw"`
-	FlatConfig    FlatConfig     `yaml:"flat"`
+	DefaultType   IndexType         `yaml:"default_type"`
+	DefaultMetric DistanceMetric    `yaml:"default_metric"`
+	HNSWConfig    HNSWConfig        `yaml:"hnsw"`
+	FlatConfig    FlatConfig        `yaml:"flat"`
+	AutoRebuild   AutoRebuildConfig `yaml:"auto_rebuild"`
 }
 
 // HNSWConfig represents HNSW index configuration
@@ -262,6 +501,9 @@ type HNSWConfig struct {
 	EfConstruction int     `yaml:"ef_construction"`
 	EfSearch       int     `yaml:"ef_search"`
 	Seed           int64   `yaml:"seed"`
+
+	NeighborSelection     string `yaml:"neighbor_selection"`      // "heuristic" (default) or "simple"
+	KeepPrunedConnections bool   `yaml:"keep_pruned_connections"` // Top up neighbor lists with discarded candidates
 }
 
 // FlatConfig represents flat index configuration
@@ -269,12 +511,20 @@ type FlatConfig struct {
 	BatchSize int `yaml:"batch_size"`
 }
 
+// AutoRebuildConfig sets when an index is rebuilt in the background after
+// deletes and updates
+type AutoRebuildConfig struct {
+	DeletedRatio float64 `yaml:"deleted_ratio"` // Share of the index removed since it was built (0 disables)
+	MinVectors   int     `yaml:"min_vectors"`   // Smallest index considered
+}
+
 // PerfConfig represents performance configuration
 type PerfConfig struct {
 	MaxConcurrency int   `yaml:"max_concurrency"`
 	EnableSIMD     bool  `yaml:"enable_simd"`
 	MemoryLimit    int64 `yaml:"memory_limit"`
 	GCTarget       int   `yaml:"gc_target"`
+	NumThreads     int   `yaml:"num_threads"` // Workers used to build HNSW indexes (0 uses all CPUs)
 }
 
 // Database interface represents the main database operations
@@ -283,15 +533,32 @@ type Database interface {
 	Open(ctx context.Context, config *Config) error
 	Close() error
 	Health() *HealthStatus
+	CheckComponents(ctx context.Context) []*ComponentHealth
+	Reconfigure(ctx context.Context, unified *config.VittoriaConfig) error
 
 	// Collection management
 	CreateCollection(ctx context.Context, req *CreateCollectionRequest) error
+	ValidateCreateCollection(ctx context.Context, req *CreateCollectionRequest) error
 	GetCollection(ctx context.Context, name string) (Collection, error)
 	ListCollections(ctx context.Context) ([]*CollectionInfo, error)
+	ListAllCollections(ctx context.Context) ([]*CollectionInfo, error)
 	DropCollection(ctx context.Context, name string) error
+	RestoreCollection(ctx context.Context, name string) error
+	ListTrash(ctx context.Context) ([]*TrashedCollection, error)
+
+	// Collection groups
+	CreateGroup(ctx context.Context, req *CreateGroupRequest) error
+	GetGroup(ctx context.Context, name string) (*GroupInfo, error)
+	ListGroups(ctx context.Context) ([]*GroupInfo, error)
+	DropGroup(ctx context.Context, name string) error
+	SearchGroup(ctx context.Context, name string, req *GroupSearchRequest) (*GroupSearchResponse, error)
+	BackupGroup(ctx context.Context, name string, w io.Writer) error
 
 	// Statistics and maintenance
 	Stats(ctx context.Context) (*DatabaseStats, error)
+	CheckMemory(incoming int64) error
+	LoadTest(ctx context.Context, req *LoadTestRequest) (*LoadTestReport, error)
+	CollectionGrowth(ctx context.Context, name string) (*CollectionGrowth, error)
 	Backup(ctx context.Context, w io.Writer) error
 	Restore(ctx context.Context, r io.Reader) error
 }
@@ -309,6 +576,9 @@ type Collection interface {
 	InsertBatch(ctx context.Context, vectors []*Vector) error
 	Get(ctx context.Context, id string) (*Vector, error)
 	Delete(ctx context.Context, id string) error
+	GetInNamespace(ctx context.Context, namespace, id string) (*Vector, error)
+	GetView(ctx context.Context, namespace, id string) (*Vector, error)
+	DeleteInNamespace(ctx context.Context, namespace, id string) error
 
 	// Text operations (automatic vectorization)
 	InsertText(ctx context.Context, textVector *TextVector) error
@@ -317,6 +587,7 @@ type Collection interface {
 	// Search
 	Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error)
 	SearchText(ctx context.Context, query string, limit int, filter *Filter) (*SearchResponse, error)
+	Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error)
 
 	// Maintenance
 	Compact(ctx context.Context) error
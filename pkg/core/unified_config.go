@@ -0,0 +1,178 @@
+package core
+
+import (
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// ConfigFromUnified returns the database settings of a unified
+// configuration, which is how the server and CLI open databases. Settings the
+// database has no use for, such as the server's or the WAL's, are left out.
+func ConfigFromUnified(unified *config.VittoriaConfig) *Config {
+	defaultType, err := ParseIndexType(unified.Search.Index.DefaultType)
+	if err != nil {
+		defaultType = IndexTypeFlat
+	}
+	defaultMetric, err := ParseDistanceMetric(unified.Search.Index.DefaultMetric)
+	if err != nil {
+		defaultMetric = DistanceMetricCosine
+	}
+	hnsw := unified.Search.Index.HNSW
+
+	return &Config{
+		DataDir:  unified.DataDir,
+		ReadOnly: unified.ReadOnly,
+		Server: ServerConfig{
+			Host:         unified.Server.Host,
+			Port:         unified.Server.Port,
+			ReadTimeout:  unified.Server.ReadTimeout,
+			WriteTimeout: unified.Server.WriteTimeout,
+			MaxBodySize:  unified.Server.MaxBodySize,
+			CORS:         unified.Server.CORS,
+		},
+		Storage: StorageConfig{
+			PageSize:    unified.Storage.PageSize,
+			CacheSize:   unified.Storage.CacheSize,
+			SyncWrites:  unified.Storage.SyncWrites,
+			Compression: unified.Storage.Compression,
+
+			TTLCheckInterval: unified.Storage.TTLCheckInterval,
+			TrashRetention:   unified.Storage.TrashRetention,
+			RecoverCorrupted: unified.Storage.RecoverCorrupted,
+		},
+		Index: IndexConfig{
+			DefaultType:   defaultType,
+			DefaultMetric: defaultMetric,
+			HNSWConfig: HNSWConfig{
+				M:              hnsw.M,
+				MaxM:           hnsw.MaxM,
+				MaxM0:          hnsw.MaxM0,
+				ML:             hnsw.ML,
+				EfConstruction: hnsw.EfConstruction,
+				EfSearch:       hnsw.EfSearch,
+				Seed:           hnsw.Seed,
+
+				NeighborSelection:     hnsw.NeighborSelection,
+				KeepPrunedConnections: hnsw.KeepPrunedConnections,
+			},
+			FlatConfig:  FlatConfig(unified.Search.Index.Flat),
+			AutoRebuild: AutoRebuildConfig(unified.Search.Index.AutoRebuild),
+		},
+		Parallel: &ParallelSearchConfig{
+			Enabled:        unified.Search.Parallel.Enabled,
+			MaxWorkers:     unified.Search.Parallel.MaxWorkers,
+			BatchSize:      unified.Search.Parallel.BatchSize,
+			UseCache:       unified.Search.Parallel.UseCache,
+			PreloadVectors: unified.Search.Parallel.PreloadVectors,
+
+			MinVectorsForParallel: unified.Search.Parallel.MinVectorsForParallel,
+		},
+		Cache: searchCacheConfigFromUnified(unified),
+		Performance: PerfConfig{
+			MaxConcurrency: unified.Performance.MaxConcurrency,
+			EnableSIMD:     unified.Performance.EnableSIMD,
+			MemoryLimit:    unified.Performance.MemoryLimit,
+			GCTarget:       unified.Performance.GCTarget,
+			NumThreads:     unified.Performance.CPU.NumThreads,
+		},
+		Maintenance:        maintenanceConfigFromUnified(unified),
+		ObjectStorage:      unified.ObjectStoreConfig(),
+		VectorizerProfiles: vectorizerProfilesFromUnified(unified),
+		Limits: LimitsConfig{
+			MaxDimensions:    unified.Limits.MaxDimensions,
+			MaxCollections:   unified.Limits.MaxCollections,
+			ReservedPrefixes: unified.Limits.ReservedPrefixes,
+		},
+	}
+}
+
+// searchCacheConfigFromUnified returns the search cache settings of a
+// unified configuration
+func searchCacheConfigFromUnified(unified *config.VittoriaConfig) *SearchCacheConfig {
+	return &SearchCacheConfig{
+		Enabled:         unified.Search.Cache.Enabled,
+		MaxEntries:      unified.Search.Cache.MaxEntries,
+		TTL:             unified.Search.Cache.TTL,
+		CleanupInterval: unified.Search.Cache.CleanupInterval,
+	}
+}
+
+// vectorizerProfilesFromUnified returns the vectorizer profiles of a unified
+// configuration, with their API keys
+func vectorizerProfilesFromUnified(unified *config.VittoriaConfig) map[string]*embeddings.VectorizerConfig {
+	if len(unified.Embeddings.Profiles) == 0 {
+		return nil
+	}
+	profiles := make(map[string]*embeddings.VectorizerConfig, len(unified.Embeddings.Profiles))
+	for name, profile := range unified.Embeddings.Profiles {
+		profiles[name] = profile.Vectorizer()
+	}
+	return profiles
+}
+
+// maintenanceConfigFromUnified returns the maintenance jobs and windows of a
+// unified configuration, which write backups to storage.backup.directory
+func maintenanceConfigFromUnified(unified *config.VittoriaConfig) MaintenanceConfig {
+	jobs := make([]MaintenanceJob, 0, len(unified.Maintenance.Jobs))
+	for _, job := range unified.Maintenance.Jobs {
+		jobs = append(jobs, MaintenanceJob(job))
+	}
+	windows := make([]MaintenanceWindow, 0, len(unified.Maintenance.Windows))
+	for _, window := range unified.Maintenance.Windows {
+		windows = append(windows, MaintenanceWindow(window))
+	}
+	return MaintenanceConfig{
+		Timezone:        unified.Maintenance.Timezone,
+		BackupDir:       unified.Storage.Backup.Directory,
+		BackupRetention: unified.Storage.Backup.Retention,
+		Jobs:            jobs,
+		Windows:         windows,
+	}
+}
+
+// AutoCreateRequest returns the request creating collection name from the
+// auto_create template of a unified configuration. Dimensions are left at 0
+// when neither the template nor its vectorizer sets them.
+func AutoCreateRequest(unified *config.VittoriaConfig, name string) *CreateCollectionRequest {
+	template := unified.AutoCreate.Template
+
+	indexType := template.IndexType
+	if indexType == "" {
+		indexType = unified.Search.Index.DefaultType
+	}
+	metric := template.Metric
+	if metric == "" {
+		metric = unified.Search.Index.DefaultMetric
+	}
+	vectorizer := unified.Embeddings.Default
+	if template.Vectorizer != nil {
+		vectorizer = *template.Vectorizer
+	} else if profile, ok := unified.Embeddings.Profiles[template.VectorizerProfile]; ok {
+		vectorizer = profile
+	}
+	dimensions := template.Dimensions
+	if dimensions == 0 {
+		dimensions = vectorizer.Dimensions
+	}
+
+	req := &CreateCollectionRequest{
+		Name:       name,
+		Dimensions: dimensions,
+		Metric:     DistanceMetricCosine,
+		IndexType:  IndexTypeFlat,
+	}
+	// The database resolves the profile, which keeps its API key out of the
+	// request
+	if template.Vectorizer == nil && template.VectorizerProfile != "" {
+		req.VectorizerProfile = template.VectorizerProfile
+	} else {
+		req.VectorizerConfig = vectorizer.Vectorizer()
+	}
+	if parsed, err := ParseIndexType(indexType); err == nil {
+		req.IndexType = parsed
+	}
+	if parsed, err := ParseDistanceMetric(metric); err == nil {
+		req.Metric = parsed
+	}
+	return req
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+func TestConfigFromUnified(t *testing.T) {
+	unified := config.DefaultConfig()
+	unified.Search.Index.DefaultType = "hnsw"
+	unified.Search.Index.HNSW.EfSearch = 80
+	unified.Storage.Backup.Directory = "s3://bucket/backups"
+	unified.Maintenance.Jobs = []config.MaintenanceJobConfig{{Name: "nightly", Type: config.MaintenanceBackup, Schedule: "0 3 * * *"}}
+
+	cfg := ConfigFromUnified(unified)
+	if cfg.Index.DefaultType != IndexTypeHNSW || cfg.Index.HNSWConfig.EfSearch != 80 {
+		t.Errorf("unexpected index settings %+v", cfg.Index)
+	}
+	if cfg.Maintenance.BackupDir != "s3://bucket/backups" || len(cfg.Maintenance.Jobs) != 1 || cfg.Maintenance.Jobs[0].Type != MaintenanceBackup {
+		t.Errorf("unexpected maintenance settings %+v", cfg.Maintenance)
+	}
+	if cfg.Limits.MaxDimensions != MaxDimensions || cfg.Cache == nil || !cfg.Cache.Enabled {
+		t.Errorf("unexpected limits %+v or cache %+v", cfg.Limits, cfg.Cache)
+	}
+
+	unified.AutoCreate.Template.Metric = "euclidean"
+	unified.AutoCreate.Template.Vectorizer = &config.VectorizerConfig{Type: "openai", Dimensions: 1536, Options: map[string]interface{}{"api_key": "key"}}
+	req := AutoCreateRequest(unified, "notes")
+	if req.Name != "notes" || req.IndexType != IndexTypeHNSW || req.Metric != DistanceMetricEuclidean || req.Dimensions != 1536 {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if req.VectorizerConfig.Type != embeddings.VectorizerTypeOpenAI {
+		t.Errorf("unexpected vectorizer %+v", req.VectorizerConfig)
+	}
+	req.VectorizerConfig.Options["api_key"] = "changed"
+	if unified.AutoCreate.Template.Vectorizer.Options["api_key"] != "key" {
+		t.Error("the request shares the template's options")
+	}
+}
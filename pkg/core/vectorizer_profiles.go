@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// Collections created with a vectorizer profile persist its settings without
+// the API key, like any vectorizer, along with the profile's name. When the
+// database opens them again, the key comes from the profile of the
+// configuration, so rotating a key only takes a restart. The other settings
+// stay as the collection was created: its embeddings must remain comparable.
+
+// profileSecretOptions are the options a profile supplies to the collections
+// created with it each time they are opened
+var profileSecretOptions = []string{"api_key", embeddings.APIKeyEnvOption}
+
+// requestVectorizer returns the vectorizer a collection creation request asks
+// for: its vectorizer_config, or a copy of the profile vectorizer_profile
+// names. Only vectorizer_profile gives a collection a profile's API key.
+func (db *VittoriaDB) requestVectorizer(req *CreateCollectionRequest) (*embeddings.VectorizerConfig, error) {
+	if req.VectorizerProfile == "" {
+		if req.VectorizerConfig == nil || req.VectorizerConfig.Profile == "" {
+			return req.VectorizerConfig, nil
+		}
+		vectorizer := *req.VectorizerConfig
+		vectorizer.Profile = ""
+		return &vectorizer, nil
+	}
+	if req.VectorizerConfig != nil {
+		return nil, fmt.Errorf("set either vectorizer_config or vectorizer_profile, not both")
+	}
+
+	var profiles map[string]*embeddings.VectorizerConfig
+	if db.config != nil {
+		profiles = db.config.VectorizerProfiles
+	}
+	profile, ok := profiles[req.VectorizerProfile]
+	if !ok {
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("unknown vectorizer profile '%s': embeddings.profiles defines none", req.VectorizerProfile)
+		}
+		return nil, fmt.Errorf("unknown vectorizer profile '%s': use one of %s", req.VectorizerProfile,
+			strings.Join(slices.Sorted(maps.Keys(profiles)), ", "))
+	}
+	vectorizer := *profile
+	vectorizer.Options = maps.Clone(profile.Options)
+	vectorizer.Profile = req.VectorizerProfile
+	return &vectorizer, nil
+}
+
+// vectorizer returns the settings the vectorizer of a collection opened with
+// the options is recreated with: the persisted ones, with the API key of the
+// profile they came from when the configuration still defines it
+func (o indexOptions) vectorizer(persisted *embeddings.VectorizerConfig) *embeddings.VectorizerConfig {
+	profile, ok := o.profiles[persisted.Profile]
+	if persisted.Profile == "" || !ok {
+		return persisted
+	}
+	restored := *persisted
+	restored.Options = make(map[string]interface{}, len(persisted.Options)+len(profileSecretOptions))
+	maps.Copy(restored.Options, persisted.Options)
+	for _, option := range profileSecretOptions {
+		if value, set := profile.Options[option]; set {
+			restored.Options[option] = value
+		}
+	}
+	return &restored
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+func TestVectorizerProfiles(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("OPENAI_API_KEY", "")
+	unified := config.DefaultConfig()
+	unified.DataDir = t.TempDir()
+	unified.Embeddings.Profiles = map[string]config.VectorizerConfig{
+		"openai-small": {Type: "openai", Model: "text-embedding-3-small", Dimensions: 4, APIKey: "sk-profile"},
+	}
+	db := openTestDatabase(t, ConfigFromUnified(unified))
+
+	if err := db.CreateCollection(ctx, &CreateCollectionRequest{Name: "docs", VectorizerProfile: "openai-small"}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := db.GetCollection(ctx, "docs")
+	if collection.Dimensions() != 4 || !collection.HasVectorizer() {
+		t.Fatalf("unexpected dimensions %d or missing vectorizer", collection.Dimensions())
+	}
+	for _, req := range []*CreateCollectionRequest{
+		{Name: "unknown", VectorizerProfile: "openai-large"},
+		{Name: "both", VectorizerProfile: "openai-small", VectorizerConfig: &embeddings.VectorizerConfig{Type: embeddings.VectorizerTypeOpenAI, Dimensions: 4}},
+	} {
+		if err := db.CreateCollection(ctx, req); err == nil {
+			t.Errorf("%s: CreateCollection succeeded", req.Name)
+		}
+	}
+	db.Close()
+
+	// The key stays out of the metadata, and comes from the profile again
+	data, err := os.ReadFile(filepath.Join(unified.DataDir, "docs", "metadata.json"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if strings.Contains(string(data), "sk-profile") || !strings.Contains(string(data), "openai-small") {
+		t.Errorf("unexpected metadata %s", data)
+	}
+	db = openTestDatabase(t, ConfigFromUnified(unified))
+	collection, _ = db.GetCollection(ctx, "docs")
+	if !collection.HasVectorizer() {
+		t.Error("vectorizer not restored from the profile")
+	}
+	db.Close()
+
+	unified.Embeddings.Profiles = nil
+	db = openTestDatabase(t, ConfigFromUnified(unified))
+	collection, _ = db.GetCollection(ctx, "docs")
+	if collection.HasVectorizer() {
+		t.Error("vectorizer restored without its profile")
+	}
+}
@@ -0,0 +1,255 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/index"
+)
+
+// VerifyReport is the result of checking the files of a data directory
+type VerifyReport struct {
+	DataDir     string                    `json:"data_dir"`
+	Collections []*CollectionVerification `json:"collections"`
+	Healthy     bool                      `json:"healthy"`
+}
+
+// CollectionVerification is the result of checking the files of a
+// collection. The shards of a sharded collection are reported as
+// "<collection>/<shard>".
+type CollectionVerification struct {
+	Collection string                `json:"collection"`
+	Vectors    int                   `json:"vectors"`
+	Index      *IndexIntegrityReport `json:"index,omitempty"` // Check of the persisted HNSW graph
+	Issues     []*VerifyIssue        `json:"issues,omitempty"`
+}
+
+// VerifyIssue is a problem found in the files of a collection
+type VerifyIssue struct {
+	File    string `json:"file"`
+	Problem string `json:"problem"`
+	// Repairable issues are fixed by repairing or rebuilding the index; the
+	// others need the records rewritten or a backup restored
+	Repairable bool `json:"repairable"`
+}
+
+// Repairable reports whether every issue of the collection is repairable
+func (v *CollectionVerification) Repairable() bool {
+	for _, issue := range v.Issues {
+		if !issue.Repairable {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyDataDir checks the files of every collection of a data directory:
+// that metadata and vectors parse and match their checksums, that vectors
+// have the collection's dimensions and that the persisted index holds exactly
+// the stored vectors with a sound graph. It reads the files without opening the database, so it
+// works while a server runs and on collections that fail to load.
+func VerifyDataDir(ctx context.Context, dataDir string) (*VerifyReport, error) {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{DataDir: dataDir, Collections: []*CollectionVerification{}, Healthy: true}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !entry.IsDir() || entry.Name() == trashDirName {
+			continue
+		}
+		dir := filepath.Join(dataDir, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, "metadata.json")); err != nil {
+			continue
+		}
+		report.add(verifyCollectionDir(entry.Name(), dir)...)
+	}
+	return report, nil
+}
+
+// add appends collection results to the report
+func (r *VerifyReport) add(results ...*CollectionVerification) {
+	for _, result := range results {
+		if len(result.Issues) > 0 {
+			r.Healthy = false
+		}
+		r.Collections = append(r.Collections, result)
+	}
+}
+
+// verifyCollectionDir checks the files of a collection directory, followed
+// by those of its local shards
+func verifyCollectionDir(name, dir string) []*CollectionVerification {
+	result := &CollectionVerification{Collection: name}
+	issue := func(file string, repairable bool, format string, args ...interface{}) {
+		result.Issues = append(result.Issues, &VerifyIssue{File: file, Problem: fmt.Sprintf(format, args...), Repairable: repairable})
+	}
+
+	var metadata CollectionMetadata
+	data, err := os.ReadFile(filepath.Join(dir, "metadata.json"))
+	if err == nil {
+		err = json.Unmarshal(data, &metadata)
+	}
+	if err != nil {
+		issue("metadata.json", false, "unreadable: %v", err)
+		return []*CollectionVerification{result}
+	}
+	if metadata.Dimensions <= 0 {
+		issue("metadata.json", false, "invalid dimensions %d", metadata.Dimensions)
+	}
+	if !slices.Contains(DistanceMetrics, metadata.Metric) {
+		issue("metadata.json", false, "invalid metric %d", metadata.Metric)
+	}
+	if !slices.Contains(IndexTypes, metadata.IndexType) {
+		issue("metadata.json", false, "invalid index type %d", metadata.IndexType)
+	}
+
+	// A sharded collection keeps its records in its shards
+	if metadata.Sharding != nil {
+		results := []*CollectionVerification{result}
+		shardsDir := filepath.Join(dir, shardsDirName)
+		entries, err := os.ReadDir(shardsDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			issue(shardsDirName, false, "unreadable: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				results = append(results, verifyCollectionDir(name+"/"+entry.Name(), filepath.Join(shardsDir, entry.Name()))...)
+			}
+		}
+		return results
+	}
+
+	checksums, err := readChecksums(dir)
+	if err != nil {
+		issue(checksumsFile, false, "%v", err)
+	}
+	vectors := make(map[string]*Vector)
+	data, err = os.ReadFile(filepath.Join(dir, vectorsFileName))
+	if err == nil {
+		var damaged []*damagedSegment
+		vectors, damaged, err = decodeVectors(data, checksums.Files[vectorsFileName])
+		for _, segment := range damaged {
+			issue(vectorsFileName, false, "checksum mismatch in %s", segment)
+		}
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		issue(vectorsFileName, false, "unreadable: %v", err)
+		return []*CollectionVerification{result}
+	}
+	if vectors == nil {
+		vectors = make(map[string]*Vector)
+	}
+	result.Vectors = len(vectors)
+
+	keys := make([]string, 0, len(vectors))
+	for key := range vectors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		vector := vectors[key]
+		if vector == nil {
+			issue("vectors.json", false, "entry %s is empty", key)
+			delete(vectors, key)
+			continue
+		}
+		if vector.key() != key {
+			issue("vectors.json", false, "entry %s holds vector %s of namespace '%s'", key, vector.ID, vector.Namespace)
+		}
+		if vector.hasVector() && len(vector.Vector) != metadata.Dimensions {
+			issue("vectors.json", false, "vector %s has %d dimensions instead of %d", vector.ID, len(vector.Vector), metadata.Dimensions)
+		}
+	}
+	if metadata.IndexType != IndexTypeHNSW || metadata.BulkLoad || len(result.Issues) > 0 {
+		return []*CollectionVerification{result}
+	}
+
+	// Opening the collection silently rebuilds a stale or corrupt index, so
+	// the persisted one is checked on its own
+	c := &VittoriaCollection{
+		name:       name,
+		dimensions: metadata.Dimensions,
+		metric:     metadata.Metric,
+		indexType:  metadata.IndexType,
+		hnsw:       metadata.HNSW,
+		vectors:    vectors,
+	}
+	graph, err := c.newIndex(c.indexType, c.hnswParams())
+	if err != nil {
+		issue(indexFileName, true, "%v", err)
+		return []*CollectionVerification{result}
+	}
+	data, err = os.ReadFile(filepath.Join(dir, indexFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		if c.indexedCount() > 0 {
+			issue(indexFileName, true, "missing: the index is rebuilt from the vectors at every start")
+		}
+		return []*CollectionVerification{result}
+	}
+	if err == nil {
+		if _, damaged := checksums.Files[indexFileName].check(indexFileName, data); len(damaged) > 0 {
+			issue(indexFileName, true, "checksum mismatch in %s", describeDamage(damaged))
+			return []*CollectionVerification{result}
+		}
+		err = graph.Load(bytes.NewReader(data))
+	}
+	if err != nil {
+		issue(indexFileName, true, "unreadable: %v", err)
+		return []*CollectionVerification{result}
+	}
+
+	hnsw, ok := graph.(index.HNSWIndex)
+	if !ok {
+		return []*CollectionVerification{result}
+	}
+	result.Index = c.checkIndex(hnsw)
+	if result.Index.MissingNodes > 0 {
+		issue(indexFileName, true, "%d stored vectors are missing from the index", result.Index.MissingNodes)
+	}
+	if result.Index.StaleNodes > 0 {
+		issue(indexFileName, true, "%d index nodes have no stored vector", result.Index.StaleNodes)
+	}
+	if problems := graphProblems(result.Index.Graph); len(problems) > 0 {
+		issue(indexFileName, true, "graph has %s", strings.Join(problems, ", "))
+	}
+	return []*CollectionVerification{result}
+}
+
+// graphProblems describes the defects of an HNSW graph. One-way links and
+// tombstones are left out, as pruning and deletes leave them in sound graphs.
+func graphProblems(graph *index.IntegrityReport) []string {
+	var problems []string
+	if !graph.EntryPointValid {
+		problems = append(problems, "an invalid entry point")
+	}
+	for _, count := range []struct {
+		n    int
+		what string
+	}{
+		{graph.DanglingLinks, "dangling links"},
+		{graph.SelfLinks, "self links"},
+		{graph.LayerViolations, "links outside their layer"},
+		{graph.DuplicateLinks, "duplicate links"},
+		{graph.MissingLayers, "missing layers"},
+		{graph.OverfullLists, "overfull connection lists"},
+		{graph.UnreachableNodes, "unreachable nodes"},
+	} {
+		if count.n > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s", count.n, count.what))
+		}
+	}
+	return problems
+}
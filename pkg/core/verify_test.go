@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyDataDir(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	db := openTestDatabase(t, &Config{DataDir: dataDir})
+	for _, req := range []*CreateCollectionRequest{
+		{Name: "graph", Dimensions: 3, IndexType: IndexTypeHNSW},
+		{Name: "flat", Dimensions: 3, IndexType: IndexTypeFlat},
+	} {
+		if err := db.CreateCollection(ctx, req); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+		collection, _ := db.GetCollection(ctx, req.Name)
+		for i := 0; i < 20; i++ {
+			vector := &Vector{ID: fmt.Sprintf("v%d", i), Vector: []float32{float32(i), 1, float32(i % 3)}}
+			if err := collection.Insert(ctx, vector); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		if err := collection.Flush(ctx); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	db.Close()
+
+	report, err := VerifyDataDir(ctx, dataDir)
+	if err != nil {
+		t.Fatalf("VerifyDataDir failed: %v", err)
+	}
+	if !report.Healthy || len(report.Collections) != 2 {
+		t.Fatalf("unexpected report of a sound data directory: %+v", report)
+	}
+	for _, result := range report.Collections {
+		if result.Vectors != 20 {
+			t.Errorf("%s: %d vectors, want 20", result.Collection, result.Vectors)
+		}
+	}
+
+	// A missing index is repairable, a vector of the wrong size is not
+	if err := os.Remove(filepath.Join(dataDir, "graph", indexFileName)); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	// Without checksums, the rewritten vectors are checked for their content only
+	if err := os.Remove(filepath.Join(dataDir, "flat", checksumsFile)); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	vectorsPath := filepath.Join(dataDir, "flat", "vectors.json")
+	data, err := os.ReadFile(vectorsPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	var vectors map[string]*Vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	vectors["v1"].Vector = []float32{1, 2}
+	data, _ = json.Marshal(vectors)
+	if err := os.WriteFile(vectorsPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	report, err = VerifyDataDir(ctx, dataDir)
+	if err != nil {
+		t.Fatalf("VerifyDataDir failed: %v", err)
+	}
+	if report.Healthy {
+		t.Fatal("damaged data directory reported healthy")
+	}
+	for _, result := range report.Collections {
+		if len(result.Issues) != 1 {
+			t.Fatalf("%s: unexpected issues %+v", result.Collection, result.Issues)
+		}
+		want := result.Collection == "graph"
+		if result.Repairable() != want {
+			t.Errorf("%s: repairable %v, want %v (%s)", result.Collection, result.Repairable(), want, result.Issues[0].Problem)
+		}
+	}
+
+	// Unreadable metadata stops the checks of its collection
+	if err := os.WriteFile(filepath.Join(dataDir, "flat", "metadata.json"), []byte("{"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	report, _ = VerifyDataDir(ctx, dataDir)
+	for _, result := range report.Collections {
+		if result.Collection == "flat" && (len(result.Issues) != 1 || result.Issues[0].File != "metadata.json") {
+			t.Errorf("unexpected issues of unreadable metadata %+v", result.Issues)
+		}
+	}
+}
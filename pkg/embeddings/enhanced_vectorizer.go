@@ -92,6 +92,14 @@ func (ev *EnhancedVectorizer) Close() error {
 	return ev.baseVectorizer.Close()
 }
 
+// Ping checks the wrapped vectorizer's service, if it has one
+func (ev *EnhancedVectorizer) Ping(ctx context.Context) error {
+	if pinger, ok := ev.baseVectorizer.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
 // GetBatchProcessor returns the underlying batch processor for advanced configuration
 func (ev *EnhancedVectorizer) GetBatchProcessor() *BatchProcessor {
 	return ev.batchProcessor
@@ -19,16 +19,12 @@ func NewHuggingFaceVectorizer(config *VectorizerConfig) (*HuggingFaceVectorizer,
 		config.Model = "sentence-transformers/all-MiniLM-L6-v2"
 	}
 
-	apiKey, _ := config.Options["api_key"].(string) // Optional for HuggingFace
+	apiKey := resolveAPIKey(config, "HUGGINGFACE_API_KEY") // Optional for HuggingFace
 
 	dimensions := config.Dimensions
 	if dimensions == 0 {
 		// Set default dimensions based on model
-		if config.Model == "sentence-transformers/all-MiniLM-L6-v2" {
-			dimensions = 384
-		} else {
-			dimensions = 384 // Default fallback
-		}
+		dimensions = modelDimensionsOr(config.Model, 384)
 	}
 
 	return &HuggingFaceVectorizer{
@@ -0,0 +1,113 @@
+package embeddings
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modelDimensions holds the embedding dimensions of well-known models, keyed
+// by lowercase model name without its organization prefix or Ollama tag
+var modelDimensions = map[string]int{
+	// OpenAI
+	"text-embedding-ada-002": 1536,
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+
+	// sentence-transformers (local or through HuggingFace)
+	"all-minilm-l6-v2":                      384,
+	"all-minilm-l12-v2":                     384,
+	"all-mpnet-base-v2":                     768,
+	"paraphrase-multilingual-minilm-l12-v2": 384,
+	"paraphrase-mpnet-base-v2":              768,
+	"multi-qa-minilm-l6-cos-v1":             384,
+	"multi-qa-mpnet-base-dot-v1":            768,
+	"bge-small-en-v1.5":                     384,
+	"bge-base-en-v1.5":                      768,
+	"bge-large-en-v1.5":                     1024,
+	"e5-small-v2":                           384,
+	"e5-base-v2":                            768,
+	"e5-large-v2":                           1024,
+
+	// Ollama
+	"nomic-embed-text":       768,
+	"mxbai-embed-large":      1024,
+	"all-minilm":             384,
+	"snowflake-arctic-embed": 1024,
+	"bge-m3":                 1024,
+}
+
+// ModelDimensions returns the embedding dimensions of a well-known model.
+// Organization prefixes ("sentence-transformers/", "BAAI/") and Ollama tags
+// (":latest") are ignored.
+func ModelDimensions(model string) (int, bool) {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	dimensions, ok := modelDimensions[name]
+	return dimensions, ok
+}
+
+// InferDimensions returns the dimensions a vectorizer config produces: the
+// configured ones, else those of its model (or the type's default model) when
+// it is well known
+func InferDimensions(config *VectorizerConfig) (int, error) {
+	if config.Dimensions > 0 {
+		return config.Dimensions, nil
+	}
+
+	model := config.Model
+	if model == "" {
+		model = GetDefaultConfig(config.Type).Model
+	}
+	if dimensions, ok := ModelDimensions(model); ok {
+		return dimensions, nil
+	}
+	return 0, fmt.Errorf("cannot infer the dimensions of model '%s': set dimensions explicitly", model)
+}
+
+// modelDimensionsOr returns the dimensions of a well-known model, or fallback
+func modelDimensionsOr(model string, fallback int) int {
+	if dimensions, ok := ModelDimensions(model); ok {
+		return dimensions
+	}
+	return fallback
+}
+
+// modelPrices holds the list prices of hosted embedding models in USD per
+// million tokens, keyed like modelDimensions
+var modelPrices = map[string]float64{
+	"text-embedding-ada-002": 0.10,
+	"text-embedding-3-small": 0.02,
+	"text-embedding-3-large": 0.13,
+}
+
+// ModelPrice returns the price in USD per million tokens of embedding with a
+// vectorizer config. Local providers (Sentence Transformers, Ollama) are free;
+// ok is false for hosted models whose price is not known.
+func ModelPrice(config *VectorizerConfig) (price float64, ok bool) {
+	switch config.Type {
+	case VectorizerTypeSentenceTransformers, VectorizerTypeOllama, VectorizerTypeNone:
+		return 0, true
+	}
+
+	model := config.Model
+	if model == "" {
+		model = GetDefaultConfig(config.Type).Model
+	}
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	price, ok = modelPrices[name]
+	return price, ok
+}
+
+// EstimateTokens approximates the tokens text is split into by embedding
+// models, at about four characters per token for English
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,28 @@
+package embeddings
+
+import (
+	"testing"
+)
+
+func TestInferDimensions(t *testing.T) {
+	tests := []struct {
+		config *VectorizerConfig
+		want   int
+	}{
+		{&VectorizerConfig{Type: VectorizerTypeOpenAI, Model: "text-embedding-3-large"}, 3072},
+		{&VectorizerConfig{Type: VectorizerTypeHuggingFace, Model: "sentence-transformers/all-MiniLM-L6-v2"}, 384},
+		{&VectorizerConfig{Type: VectorizerTypeOllama, Model: "mxbai-embed-large:latest"}, 1024},
+		{&VectorizerConfig{Type: VectorizerTypeOpenAI}, 1536}, // Default model
+		{&VectorizerConfig{Type: VectorizerTypeOllama, Model: "custom", Dimensions: 512}, 512},
+	}
+	for _, tt := range tests {
+		got, err := InferDimensions(tt.config)
+		if err != nil || got != tt.want {
+			t.Errorf("InferDimensions(%s) = %d, %v; want %d", tt.config.Model, got, err, tt.want)
+		}
+	}
+
+	if _, err := InferDimensions(&VectorizerConfig{Type: VectorizerTypeOllama, Model: "custom"}); err == nil {
+		t.Error("inferring the dimensions of an unknown model should fail")
+	}
+}
@@ -7,9 +7,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Ollama defaults, overridden by the base_url, timeout, max_retries and
+// retry_delay options
+const (
+	defaultOllamaBaseURL    = "http://localhost:11434"
+	defaultOllamaTimeout    = 60 * time.Second // Longer timeout for local model inference
+	defaultOllamaMaxRetries = 3
+	defaultOllamaRetryDelay = 500 * time.Millisecond
+)
+
 // OllamaVectorizer implements text vectorization using local Ollama models
 // This provides real ML embeddings without external API dependencies
 type OllamaVectorizer struct {
@@ -18,6 +29,9 @@ type OllamaVectorizer struct {
 	config     *VectorizerConfig
 	client     *http.Client
 	baseURL    string
+	maxRetries int
+	retryDelay time.Duration // Before the first retry, doubled for each further one
+	legacy     atomic.Bool   // The server predates /api/embed, so texts are embedded one at a time
 }
 
 // NewOllamaVectorizer creates a new Ollama vectorizer
@@ -28,25 +42,36 @@ func NewOllamaVectorizer(config *VectorizerConfig) (*OllamaVectorizer, error) {
 
 	dimensions := config.Dimensions
 	if dimensions == 0 {
-		dimensions = 768 // Default for nomic-embed-text
+		dimensions = modelDimensionsOr(config.Model, 768) // 768 is the default for nomic-embed-text
 	}
 
 	// Get Ollama base URL from config or use default
-	baseURL := "http://localhost:11434"
-	if url, exists := config.Options["base_url"]; exists {
-		if urlStr, ok := url.(string); ok {
-			baseURL = urlStr
-		}
+	baseURL := defaultOllamaBaseURL
+	if url, ok := config.Options["base_url"].(string); ok && url != "" {
+		baseURL = strings.TrimRight(url, "/")
+	}
+
+	timeout, err := durationOption(config, "timeout", defaultOllamaTimeout)
+	if err != nil {
+		return nil, err
+	}
+	retryDelay, err := durationOption(config, "retry_delay", defaultOllamaRetryDelay)
+	if err != nil {
+		return nil, err
+	}
+	maxRetries, err := intOption(config, "max_retries", defaultOllamaMaxRetries)
+	if err != nil {
+		return nil, err
 	}
 
 	return &OllamaVectorizer{
 		model:      config.Model,
 		dimensions: dimensions,
 		config:     config,
-		client: &http.Client{
-			Timeout: 60 * time.Second, // Longer timeout for local model inference
-		},
-		baseURL: baseURL,
+		client:     &http.Client{Timeout: timeout},
+		baseURL:    baseURL,
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
 	}, nil
 }
 
@@ -62,12 +87,21 @@ func (v *OllamaVectorizer) GenerateEmbedding(ctx context.Context, text string) (
 	return embeddings[0], nil
 }
 
-// GenerateEmbeddings generates multiple embeddings using Ollama
+// GenerateEmbeddings generates multiple embeddings using Ollama, in one call
+// to /api/embed, or one call per text on servers without it
 func (v *OllamaVectorizer) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return [][]float32{}, nil
 	}
 
+	if !v.legacy.Load() {
+		embeddings, err := v.embedBatch(ctx, texts)
+		if err != errOllamaNoEmbedEndpoint {
+			return embeddings, err
+		}
+		v.legacy.Store(true)
+	}
+
 	embeddings := make([][]float32, len(texts))
 	for i, text := range texts {
 		embedding, err := v.callOllamaAPI(ctx, text)
@@ -91,44 +125,60 @@ type OllamaEmbeddingResponse struct {
 	Embedding []float64 `json:"embedding"`
 }
 
-// callOllamaAPI makes API call to local Ollama service
-func (v *OllamaVectorizer) callOllamaAPI(ctx context.Context, text string) ([]float32, error) {
-	// Prepare request
-	requestBody := OllamaEmbeddingRequest{
-		Model:  v.model,
-		Prompt: text,
-	}
+// OllamaEmbedRequest represents the request format for the batch /api/embed API
+type OllamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
 
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+// OllamaEmbedResponse represents the response format from the batch /api/embed API
+type OllamaEmbedResponse struct {
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+// errOllamaNoEmbedEndpoint reports a server older than Ollama 0.3, which only
+// has the single-text /api/embeddings endpoint
+var errOllamaNoEmbedEndpoint = fmt.Errorf("Ollama does not support /api/embed")
 
-	// Create HTTP request
-	url := fmt.Sprintf("%s/api/embeddings", v.baseURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+// embedBatch embeds texts with one call to /api/embed
+func (v *OllamaVectorizer) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, status, err := v.post(ctx, "/api/embed", OllamaEmbedRequest{Model: v.model, Input: texts})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+	if status == http.StatusNotFound && !isOllamaModelError(body) {
+		return nil, errOllamaNoEmbedEndpoint
+	}
+	if status != http.StatusOK {
+		return nil, v.statusError(status, body)
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-
-	// Make request
-	resp, err := v.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ollama (is it running?): %w", err)
+	var response OllamaEmbedResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
-	defer resp.Body.Close()
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Ollama returned %d embeddings for %d texts", len(response.Embeddings), len(texts))
+	}
+	for _, embedding := range response.Embeddings {
+		if err := v.checkDimensions(embedding); err != nil {
+			return nil, err
+		}
+	}
+	recordTokens(ctx, response.PromptEvalCount)
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	return response.Embeddings, nil
+}
+
+// callOllamaAPI makes API call to local Ollama service
+func (v *OllamaVectorizer) callOllamaAPI(ctx context.Context, text string) ([]float32, error) {
+	body, status, err := v.post(ctx, "/api/embeddings", OllamaEmbeddingRequest{Model: v.model, Prompt: text})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Ollama API request failed with status %d: %s", resp.StatusCode, string(body))
+	if status != http.StatusOK {
+		return nil, v.statusError(status, body)
 	}
 
 	// Parse response
@@ -147,9 +197,123 @@ func (v *OllamaVectorizer) callOllamaAPI(ctx context.Context, text string) ([]fl
 		embedding[i] = float32(val)
 	}
 
+	if err := v.checkDimensions(embedding); err != nil {
+		return nil, err
+	}
 	return embedding, nil
 }
 
+// post sends a JSON request to Ollama and returns the response body and
+// status. Connection failures, 429 and 5xx responses (such as while a model is
+// loading) are retried with exponential backoff.
+func (v *OllamaVectorizer) post(ctx context.Context, path string, request interface{}) ([]byte, int, error) {
+	jsonBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	delay := v.retryDelay
+	for attempt := 0; ; attempt++ {
+		body, status, err := v.postOnce(ctx, path, jsonBody)
+		retryable := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if !retryable || attempt >= v.maxRetries || ctx.Err() != nil {
+			return body, status, err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			if err == nil {
+				err = v.statusError(status, body)
+			}
+			return nil, 0, err
+		}
+		delay *= 2
+	}
+}
+
+// postOnce sends a JSON request to Ollama once
+func (v *OllamaVectorizer) postOnce(ctx context.Context, path string, jsonBody []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", v.baseURL+path, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to connect to Ollama (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// isOllamaModelError reports whether an Ollama error response is about a
+// model that has not been pulled
+func isOllamaModelError(body []byte) bool {
+	var response struct {
+		Error string `json:"error"`
+	}
+	return json.Unmarshal(body, &response) == nil && strings.Contains(response.Error, "not found")
+}
+
+// statusError describes a failed Ollama request, with the command to pull the
+// model when it is missing
+func (v *OllamaVectorizer) statusError(status int, body []byte) error {
+	if status == http.StatusNotFound && isOllamaModelError(body) {
+		return fmt.Errorf("Ollama model '%s' is not available: run 'ollama pull %s'", v.model, v.model)
+	}
+	return fmt.Errorf("Ollama API request failed with status %d: %s", status, string(body))
+}
+
+// checkDimensions rejects embeddings that do not fit the collection, which
+// happens when the model differs from the one the collection was sized for
+func (v *OllamaVectorizer) checkDimensions(embedding []float32) error {
+	if len(embedding) != v.dimensions {
+		return fmt.Errorf("Ollama model '%s' returned %d dimensions, expected %d", v.model, len(embedding), v.dimensions)
+	}
+	return nil
+}
+
+// Ping checks that the Ollama server is reachable and has the model pulled
+func (v *OllamaVectorizer) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/tags", v.baseURL), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Ollama (is it running?): %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Ollama returned status %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("failed to parse Ollama model list: %w", err)
+	}
+	for _, model := range tags.Models {
+		// Models pulled without a tag are listed as "<name>:latest"
+		if model.Name == v.model || model.Name == v.model+":latest" {
+			return nil
+		}
+	}
+	return fmt.Errorf("Ollama model '%s' is not available: run 'ollama pull %s'", v.model, v.model)
+}
+
 // Interface compliance methods
 func (v *OllamaVectorizer) GetDimensions() int {
 	return v.dimensions
@@ -0,0 +1,92 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaVectorizer_BatchRetryAndFallback(t *testing.T) {
+	var embedCalls, legacyCalls int
+	legacy := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"nomic-embed-text:latest"}]}`)
+		case "/api/embed":
+			embedCalls++
+			if legacy {
+				http.NotFound(w, r)
+				return
+			}
+			if embedCalls == 1 {
+				// The model is still loading
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var req OllamaEmbedRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "nomic-embed-text" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"error":"model \"%s\" not found, try pulling it first"}`, req.Model)
+				return
+			}
+			fmt.Fprintf(w, `{"embeddings":[%s],"prompt_eval_count":%d}`, strings.Repeat(`[1,0],`, len(req.Input)-1)+`[1,0]`, len(req.Input))
+		case "/api/embeddings":
+			legacyCalls++
+			fmt.Fprint(w, `{"embedding":[0,1]}`)
+		}
+	}))
+	defer server.Close()
+
+	newVectorizer := func(model string) *OllamaVectorizer {
+		v, err := NewOllamaVectorizer(&VectorizerConfig{
+			Model:      model,
+			Dimensions: 2,
+			Options:    map[string]interface{}{"base_url": server.URL, "retry_delay": "1ms"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create vectorizer: %v", err)
+		}
+		return v
+	}
+
+	v := newVectorizer("nomic-embed-text")
+	if err := v.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the pulled model to pass the health check: %v", err)
+	}
+
+	usage := &TokenUsage{}
+	embeddings, err := v.GenerateEmbeddings(WithTokenUsage(context.Background(), usage), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Batch embedding failed: %v", err)
+	}
+	if len(embeddings) != 3 || embedCalls != 2 {
+		t.Errorf("Expected 3 embeddings from one retried batch call, got %d embeddings in %d calls", len(embeddings), embedCalls)
+	}
+	if usage.Tokens() != 3 {
+		t.Errorf("Expected 3 tokens counted, got %d", usage.Tokens())
+	}
+
+	missing := newVectorizer("llama-embed")
+	if err := missing.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "ollama pull llama-embed") {
+		t.Errorf("Expected a pull hint from the health check, got %v", err)
+	}
+	if _, err := missing.GenerateEmbedding(context.Background(), "a"); err == nil || !strings.Contains(err.Error(), "ollama pull llama-embed") {
+		t.Errorf("Expected a pull hint for a missing model, got %v", err)
+	}
+
+	// Servers without /api/embed are asked one text at a time
+	legacy = true
+	old := newVectorizer("nomic-embed-text")
+	if _, err := old.GenerateEmbeddings(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatalf("Legacy embedding failed: %v", err)
+	}
+	if legacyCalls != 2 {
+		t.Errorf("Expected 2 calls to /api/embeddings, got %d", legacyCalls)
+	}
+}
@@ -7,14 +7,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// defaultOpenAIBaseURL is the OpenAI API, overridden by the base_url option
+// for Azure OpenAI and compatible services
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
 // OpenAIVectorizer implements the Vectorizer interface using OpenAI embeddings
 type OpenAIVectorizer struct {
 	model      string
 	dimensions int
 	apiKey     string
+	baseURL    string
 	config     *VectorizerConfig
 }
 
@@ -24,30 +30,27 @@ func NewOpenAIVectorizer(config *VectorizerConfig) (*OpenAIVectorizer, error) {
 		config.Model = "text-embedding-ada-002"
 	}
 
-	apiKey, ok := config.Options["api_key"].(string)
-	if !ok || apiKey == "" {
-		return nil, fmt.Errorf("OpenAI API key is required")
+	apiKey := resolveAPIKey(config, "OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is required: set the api_key or api_key_env option, or OPENAI_API_KEY")
+	}
+
+	baseURL := defaultOpenAIBaseURL
+	if url, ok := config.Options["base_url"].(string); ok && url != "" {
+		baseURL = strings.TrimRight(url, "/")
 	}
 
 	dimensions := config.Dimensions
 	if dimensions == 0 {
 		// Set default dimensions based on model
-		switch config.Model {
-		case "text-embedding-ada-002":
-			dimensions = 1536
-		case "text-embedding-3-small":
-			dimensions = 1536
-		case "text-embedding-3-large":
-			dimensions = 3072
-		default:
-			dimensions = 1536
-		}
+		dimensions = modelDimensionsOr(config.Model, 1536)
 	}
 
 	return &OpenAIVectorizer{
 		model:      config.Model,
 		dimensions: dimensions,
 		apiKey:     apiKey,
+		baseURL:    baseURL,
 		config:     config,
 	}, nil
 }
@@ -75,13 +78,17 @@ func (v *OpenAIVectorizer) GenerateEmbeddings(ctx context.Context, texts []strin
 		"input": texts,
 		"model": v.model,
 	}
+	// text-embedding-3 models can shorten their embeddings to the collection's size
+	if strings.HasPrefix(v.model, "text-embedding-3") && v.config.Dimensions > 0 {
+		requestBody["dimensions"] = v.config.Dimensions
+	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", v.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -113,6 +120,7 @@ func (v *OpenAIVectorizer) GenerateEmbeddings(ctx context.Context, texts []strin
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	recordTokens(ctx, response.Usage.TotalTokens)
 
 	embeddings := make([][]float32, len(response.Data))
 	for i, data := range response.Data {
@@ -122,6 +130,28 @@ func (v *OpenAIVectorizer) GenerateEmbeddings(ctx context.Context, texts []strin
 	return embeddings, nil
 }
 
+// Ping checks that the OpenAI API is reachable and accepts the API key by
+// retrieving the model, which does not consume tokens
+func (v *OpenAIVectorizer) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", v.baseURL+"/models/"+v.model, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+v.apiKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OpenAI API error (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
 // GetDimensions returns the embedding dimensions
 func (v *OpenAIVectorizer) GetDimensions() int {
 	return v.dimensions
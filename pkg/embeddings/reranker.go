@@ -0,0 +1,156 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Reranker scores documents by their relevance to a query, typically with a
+// cross-encoder that reads the query and each document together
+type Reranker interface {
+	// Rerank returns the relevance score of each document, in document order
+	Rerank(ctx context.Context, query string, documents []string) ([]float32, error)
+}
+
+// Reranking API formats spoken by HTTPReranker
+const (
+	RerankFormatCohere = "cohere" // Cohere, Jina, Voyage, vLLM and other /rerank APIs taking "documents"
+	RerankFormatTEI    = "tei"    // Hugging Face text-embeddings-inference, taking "texts"
+)
+
+// RerankerConfig configures an HTTP reranking service
+type RerankerConfig struct {
+	Format  string        // RerankFormatCohere (default) or RerankFormatTEI
+	URL     string        // Full URL of the rerank endpoint
+	Model   string        // Sent with Cohere-format requests; TEI serves a single model
+	APIKey  string        // Sent as a bearer token when set
+	Timeout time.Duration // Defaults to 30 seconds
+}
+
+// HTTPReranker reranks with a hosted or local reranking service
+type HTTPReranker struct {
+	config *RerankerConfig
+	client *http.Client
+}
+
+// NewHTTPReranker creates a reranker for the service described by config
+func NewHTTPReranker(config *RerankerConfig) (*HTTPReranker, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("reranker URL is required")
+	}
+	switch config.Format {
+	case "":
+		config.Format = RerankFormatCohere
+	case RerankFormatCohere, RerankFormatTEI:
+	default:
+		return nil, fmt.Errorf("unknown reranker format '%s'", config.Format)
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &HTTPReranker{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// rerankScore is one scored document in a reranking response. Cohere-format
+// services report relevance_score, TEI reports score.
+type rerankScore struct {
+	Index          int      `json:"index"`
+	RelevanceScore *float64 `json:"relevance_score"`
+	Score          *float64 `json:"score"`
+}
+
+// Rerank scores documents against query with one call to the service
+func (r *HTTPReranker) Rerank(ctx context.Context, query string, documents []string) ([]float32, error) {
+	if len(documents) == 0 {
+		return []float32{}, nil
+	}
+
+	var request interface{}
+	if r.config.Format == RerankFormatTEI {
+		request = map[string]interface{}{"query": query, "texts": documents}
+	} else {
+		request = map[string]interface{}{
+			"model":     r.config.Model,
+			"query":     query,
+			"documents": documents,
+			"top_n":     len(documents),
+		}
+	}
+
+	jsonBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.config.URL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.config.APIKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call reranker: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reranker request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var results []rerankScore
+	if r.config.Format == RerankFormatTEI {
+		err = json.Unmarshal(body, &results)
+	} else {
+		var response struct {
+			Results []rerankScore `json:"results"`
+		}
+		err = json.Unmarshal(body, &response)
+		results = response.Results
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	scores := make([]float32, len(documents))
+	scored := make([]bool, len(documents))
+	for _, result := range results {
+		if result.Index < 0 || result.Index >= len(documents) {
+			return nil, fmt.Errorf("reranker returned unknown document index %d", result.Index)
+		}
+		switch {
+		case result.RelevanceScore != nil:
+			scores[result.Index] = float32(*result.RelevanceScore)
+		case result.Score != nil:
+			scores[result.Index] = float32(*result.Score)
+		default:
+			return nil, fmt.Errorf("reranker returned no score for document %d", result.Index)
+		}
+		scored[result.Index] = true
+	}
+	for i, ok := range scored {
+		if !ok {
+			return nil, fmt.Errorf("reranker returned no score for document %d", i)
+		}
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,53 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPReranker_Formats(t *testing.T) {
+	for _, format := range []string{RerankFormatCohere, RerankFormatTEI} {
+		t.Run(format, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("Failed to decode request: %v", err)
+				}
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					t.Errorf("Expected the API key as a bearer token, got %q", r.Header.Get("Authorization"))
+				}
+
+				// Scores are returned best first, not in document order
+				if format == RerankFormatTEI {
+					if _, ok := body["texts"]; !ok {
+						t.Errorf("Expected texts in a TEI request, got %v", body)
+					}
+					fmt.Fprint(w, `[{"index":1,"score":0.9},{"index":0,"score":0.2}]`)
+					return
+				}
+				if _, ok := body["documents"]; !ok {
+					t.Errorf("Expected documents in a Cohere request, got %v", body)
+				}
+				fmt.Fprint(w, `{"results":[{"index":1,"relevance_score":0.9},{"index":0,"relevance_score":0.2}]}`)
+			}))
+			defer server.Close()
+
+			reranker, err := NewHTTPReranker(&RerankerConfig{Format: format, URL: server.URL, APIKey: "secret"})
+			if err != nil {
+				t.Fatalf("Failed to create reranker: %v", err)
+			}
+
+			scores, err := reranker.Rerank(context.Background(), "query", []string{"first", "second"})
+			if err != nil {
+				t.Fatalf("Rerank failed: %v", err)
+			}
+			if len(scores) != 2 || scores[0] != 0.2 || scores[1] != 0.9 {
+				t.Errorf("Expected scores [0.2 0.9], got %v", scores)
+			}
+		})
+	}
+}
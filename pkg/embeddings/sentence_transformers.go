@@ -24,16 +24,7 @@ func NewSentenceTransformersVectorizer(config *VectorizerConfig) (*SentenceTrans
 	dimensions := config.Dimensions
 	if dimensions == 0 {
 		// Set default dimensions based on common models
-		switch config.Model {
-		case "all-MiniLM-L6-v2":
-			dimensions = 384
-		case "all-mpnet-base-v2":
-			dimensions = 768
-		case "paraphrase-multilingual-MiniLM-L12-v2":
-			dimensions = 384
-		default:
-			dimensions = 384 // Default fallback
-		}
+		dimensions = modelDimensionsOr(config.Model, 384)
 	}
 
 	return &SentenceTransformersVectorizer{
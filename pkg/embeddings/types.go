@@ -2,6 +2,10 @@ package embeddings
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
 )
 
 // VectorizerType represents different embedding model types
@@ -38,6 +42,115 @@ type VectorizerConfig struct {
 	Model      string                 `json:"model" yaml:"model"`
 	Dimensions int                    `json:"dimensions" yaml:"dimensions"`
 	Options    map[string]interface{} `json:"options" yaml:"options"`
+
+	// Profile names the vectorizer profile of the server configuration the
+	// settings come from, which supplies their API key
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+}
+
+// ParseVectorizerType parses a vectorizer type name such as "openai"
+func ParseVectorizerType(name string) (VectorizerType, error) {
+	for _, t := range []VectorizerType{VectorizerTypeNone, VectorizerTypeSentenceTransformers, VectorizerTypeOpenAI, VectorizerTypeHuggingFace, VectorizerTypeOllama} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return VectorizerTypeNone, fmt.Errorf("unknown vectorizer type '%s'", name)
+}
+
+// UnmarshalJSON accepts a vectorizer type either as its number or its name
+func (v *VectorizerType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		t, err := ParseVectorizerType(name)
+		if err != nil {
+			return err
+		}
+		*v = t
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("vectorizer type must be a name or a number: %w", err)
+	}
+	*v = VectorizerType(n)
+	return nil
+}
+
+// APIKeyEnvOption names the option that holds the environment variable a
+// vectorizer reads its API key from, so the key itself is never stored
+const APIKeyEnvOption = "api_key_env"
+
+// ModelVersionOption names the option recording the version of the model, for
+// providers whose model names stay the same across releases
+const ModelVersionOption = "model_version"
+
+// ModelVersion returns the model version set in the options, if any
+func (c *VectorizerConfig) ModelVersion() string {
+	version, _ := c.Options[ModelVersionOption].(string)
+	return version
+}
+
+// resolveAPIKey returns the API key set inline in the options, else the one in
+// the environment variable named by the api_key_env option, else the one in
+// fallbackEnv
+func resolveAPIKey(config *VectorizerConfig, fallbackEnv string) string {
+	if key, ok := config.Options["api_key"].(string); ok && key != "" {
+		return key
+	}
+	if env, ok := config.Options[APIKeyEnvOption].(string); ok && env != "" {
+		return os.Getenv(env)
+	}
+	return os.Getenv(fallbackEnv)
+}
+
+// durationOption reads a duration option given as a string such as "30s" or
+// as a number of seconds
+func durationOption(config *VectorizerConfig, name string, fallback time.Duration) (time.Duration, error) {
+	switch value := config.Options[name].(type) {
+	case nil:
+		return fallback, nil
+	case string:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s option: %w", name, err)
+		}
+		return d, nil
+	case float64:
+		return time.Duration(value * float64(time.Second)), nil
+	case int:
+		return time.Duration(value) * time.Second, nil
+	default:
+		return 0, fmt.Errorf("invalid %s option: expected a duration, got %v", name, value)
+	}
+}
+
+// intOption reads an integer option, which JSON decodes as a float64
+func intOption(config *VectorizerConfig, name string, fallback int) (int, error) {
+	switch value := config.Options[name].(type) {
+	case nil:
+		return fallback, nil
+	case float64:
+		return int(value), nil
+	case int:
+		return value, nil
+	default:
+		return 0, fmt.Errorf("invalid %s option: expected a number, got %v", name, value)
+	}
+}
+
+// WithoutSecrets returns a copy of the config without an inline API key, safe
+// to write to disk or show to clients
+func (c *VectorizerConfig) WithoutSecrets() *VectorizerConfig {
+	clean := *c
+	clean.Options = make(map[string]interface{}, len(c.Options))
+	for k, v := range c.Options {
+		if k != "api_key" {
+			clean.Options[k] = v
+		}
+	}
+	return &clean
 }
 
 // EmbeddingRequest represents a request to generate embeddings
@@ -72,6 +185,13 @@ type Vectorizer interface {
 	Close() error
 }
 
+// Pinger is implemented by vectorizers backed by a remote service, so that
+// health checks can tell whether the service is reachable without generating
+// an embedding
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 // VectorizerFactory creates vectorizers based on configuration
 type VectorizerFactory interface {
 	CreateVectorizer(config *VectorizerConfig) (Vectorizer, error)
@@ -0,0 +1,35 @@
+package embeddings
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVectorizerConfig_JSON(t *testing.T) {
+	var config VectorizerConfig
+	data := `{"type":"openai","model":"text-embedding-3-small","options":{"api_key":"sk-secret","api_key_env":"MY_KEY"}}`
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if config.Type != VectorizerTypeOpenAI {
+		t.Errorf("Expected type openai, got %s", config.Type)
+	}
+
+	if err := json.Unmarshal([]byte(`{"type":3}`), &config); err != nil || config.Type != VectorizerTypeHuggingFace {
+		t.Errorf("Expected numeric type to parse as huggingface, got %s (%v)", config.Type, err)
+	}
+	if err := json.Unmarshal([]byte(`{"type":"gpt"}`), &config); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+
+	clean := config.WithoutSecrets()
+	if _, ok := clean.Options["api_key"]; ok {
+		t.Error("Expected the inline API key to be removed")
+	}
+	if clean.Options[APIKeyEnvOption] != "MY_KEY" {
+		t.Errorf("Expected api_key_env to be kept, got %v", clean.Options[APIKeyEnvOption])
+	}
+	if config.Options["api_key"] != "sk-secret" {
+		t.Error("Expected the original config to keep its API key")
+	}
+}
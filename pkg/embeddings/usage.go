@@ -0,0 +1,32 @@
+package embeddings
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// TokenUsage counts the embedding tokens consumed on behalf of a request
+type TokenUsage struct {
+	tokens atomic.Int64
+}
+
+// tokenUsageKey carries a *TokenUsage in a context
+type tokenUsageKey struct{}
+
+// WithTokenUsage returns a context whose embedding calls add the tokens they
+// consume to usage
+func WithTokenUsage(ctx context.Context, usage *TokenUsage) context.Context {
+	return context.WithValue(ctx, tokenUsageKey{}, usage)
+}
+
+// Tokens returns the tokens counted so far
+func (u *TokenUsage) Tokens() int64 {
+	return u.tokens.Load()
+}
+
+// recordTokens adds tokens reported by a provider to the usage in ctx, if any
+func recordTokens(ctx context.Context, tokens int) {
+	if usage, ok := ctx.Value(tokenUsageKey{}).(*TokenUsage); ok && tokens > 0 {
+		usage.tokens.Add(int64(tokens))
+	}
+}
@@ -0,0 +1,261 @@
+// Package estimate predicts what ingesting documents into a collection would
+// take — chunks, embedding tokens and cost, disk space and index memory —
+// without embedding or storing anything.
+package estimate
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/index"
+	"github.com/antonellof/VittoriaDB/pkg/processor"
+)
+
+// textRatios approximate how many characters of text a byte of each document
+// type holds, for manifest entries that are not read
+var textRatios = map[processor.DocumentType]float64{
+	processor.DocumentTypeTXT:  1.0,
+	processor.DocumentTypeMD:   0.95,
+	processor.DocumentTypeHTML: 0.4,
+	processor.DocumentTypePDF:  0.3,
+	processor.DocumentTypeDOCX: 0.25,
+	processor.DocumentTypeCode: 1.0,
+}
+
+// Storage overheads of a vector in vectors.json
+const (
+	jsonBytesPerDimension = 20  // An indented float32 on its own line
+	jsonBytesPerVector    = 400 // ID, namespace, metadata keys and document fields
+)
+
+// Target describes the collection documents would be ingested into
+type Target struct {
+	Dimensions int                          `json:"dimensions,omitempty"`
+	IndexType  core.IndexType               `json:"index_type"`
+	Vectorizer *embeddings.VectorizerConfig `json:"vectorizer_config,omitempty"`
+
+	// PricePerMillionTokens overrides the list price of the vectorizer's model
+	PricePerMillionTokens *float64 `json:"price_per_million_tokens,omitempty"`
+}
+
+// ManifestEntry describes documents by name and size, without their content
+type ManifestEntry struct {
+	Name  string `json:"name"`            // File name; its extension gives the document type
+	Size  int64  `json:"size"`            // Bytes
+	Count int    `json:"count,omitempty"` // Documents like this one (default 1)
+}
+
+// FileEstimate is the estimate for a document, or a manifest entry
+type FileEstimate struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	Count      int    `json:"count"`
+	Characters int64  `json:"characters"`
+	Chunks     int64  `json:"chunks"`
+	Tokens     int64  `json:"tokens"`
+	Measured   bool   `json:"measured"` // Processed and chunked, rather than extrapolated from its size
+	Error      string `json:"error,omitempty"`
+
+	chunkCharacters int64
+}
+
+// Estimate is the predicted cost of ingesting a set of documents
+type Estimate struct {
+	Documents        int     `json:"documents"`
+	Chunks           int64   `json:"chunks"`
+	Characters       int64   `json:"characters"`
+	EmbeddingTokens  int64   `json:"embedding_tokens"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model,omitempty"`
+	PricePerMillion  float64 `json:"price_per_million_tokens"`
+	EmbeddingCostUSD float64 `json:"embedding_cost_usd"`
+	Dimensions       int     `json:"dimensions"`
+	IndexType        string  `json:"index_type"`
+	StorageBytes     int64   `json:"storage_bytes"`      // vectors.json and the index file
+	IndexMemoryBytes int64   `json:"index_memory_bytes"` // Memory held by the index once loaded
+
+	Files []*FileEstimate `json:"files"`
+	Notes []string        `json:"notes,omitempty"`
+}
+
+// Estimator estimates ingestion with the chunking settings of uploads
+type Estimator struct {
+	factory    *processor.ProcessorFactory
+	processing *processor.ProcessingConfig
+}
+
+// NewEstimator creates an estimator chunking documents with processing
+func NewEstimator(processing *processor.ProcessingConfig) *Estimator {
+	return &Estimator{
+		factory:    processor.NewProcessorFactory(),
+		processing: processing,
+	}
+}
+
+// Supported reports whether filename has the extension of a document type
+// uploads accept; unknown extensions would otherwise be read as text
+func (e *Estimator) Supported(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, supported := range e.factory.GetSupportedExtensions() {
+		if ext == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Document processes and chunks a document the way an upload would. A
+// document that fails to process is reported in its estimate's Error.
+func (e *Estimator) Document(reader io.Reader, filename string) *FileEstimate {
+	file := &FileEstimate{Name: filename, Type: string(e.factory.DetectDocumentType(filename)), Count: 1, Measured: true}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		file.Error = err.Error()
+		return file
+	}
+	file.Size = int64(len(content))
+
+	proc, err := e.factory.GetProcessorByFilename(filename)
+	if err != nil {
+		file.Error = err.Error()
+		return file
+	}
+	// OCR is not run for an estimate
+	config := *e.processing
+	config.OCR = nil
+	doc, err := proc.ProcessDocument(strings.NewReader(string(content)), filename, &config)
+	if err != nil {
+		file.Error = err.Error()
+		return file
+	}
+
+	file.Characters = int64(len(doc.Content))
+	file.Chunks = int64(len(doc.Chunks))
+	for _, chunk := range doc.Chunks {
+		file.Tokens += int64(embeddings.EstimateTokens(chunk.Content))
+		file.chunkCharacters += int64(len(chunk.Content))
+	}
+	return file
+}
+
+// ManifestEntry extrapolates the chunks and tokens of documents from their
+// type and size
+func (e *Estimator) ManifestEntry(entry ManifestEntry) *FileEstimate {
+	count := entry.Count
+	if count <= 0 {
+		count = 1
+	}
+	docType := e.factory.DetectDocumentType(entry.Name)
+	file := &FileEstimate{Name: entry.Name, Type: string(docType), Size: entry.Size, Count: count}
+
+	ratio, ok := textRatios[docType]
+	if !ok || !e.Supported(entry.Name) {
+		file.Error = fmt.Sprintf("unsupported document type for '%s'", entry.Name)
+		return file
+	}
+	if entry.Size < 0 {
+		file.Error = "size must be non-negative"
+		return file
+	}
+
+	// Chunks advance by their size less the overlap they repeat
+	characters := int64(float64(entry.Size) * ratio)
+	chunks := int64(0)
+	if characters > 0 {
+		step := int64(e.processing.ChunkSize - e.processing.ChunkOverlap)
+		if step <= 0 {
+			step = int64(e.processing.ChunkSize)
+		}
+		chunks = int64(math.Ceil(float64(characters) / float64(step)))
+	}
+	chunkCharacters := characters + (chunks-1)*int64(e.processing.ChunkOverlap)
+	if chunks == 0 {
+		chunkCharacters = 0
+	}
+
+	n := int64(count)
+	file.Characters = characters * n
+	file.Chunks = chunks * n
+	file.chunkCharacters = chunkCharacters * n
+	file.Tokens = (file.chunkCharacters + 3) / 4
+	return file
+}
+
+// Summarize totals the file estimates and prices them for target
+func Summarize(files []*FileEstimate, target *Target) (*Estimate, error) {
+	vectorizer := target.Vectorizer
+	if vectorizer == nil {
+		vectorizer = &embeddings.VectorizerConfig{Type: embeddings.VectorizerTypeNone}
+	}
+
+	estimate := &Estimate{
+		Provider:  vectorizer.Type.String(),
+		Model:     vectorizer.Model,
+		IndexType: target.IndexType.String(),
+		Files:     files,
+	}
+	if estimate.Model == "" && vectorizer.Type != embeddings.VectorizerTypeNone {
+		estimate.Model = embeddings.GetDefaultConfig(vectorizer.Type).Model
+	}
+
+	estimate.Dimensions = target.Dimensions
+	if estimate.Dimensions <= 0 {
+		if vectorizer.Type == embeddings.VectorizerTypeNone {
+			return nil, fmt.Errorf("dimensions are required without a vectorizer")
+		}
+		dimensions, err := embeddings.InferDimensions(vectorizer)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Dimensions = dimensions
+	}
+
+	var chunkCharacters int64
+	extrapolated := 0
+	for _, file := range files {
+		if file.Error != "" {
+			estimate.Notes = append(estimate.Notes, fmt.Sprintf("%s skipped: %s", file.Name, file.Error))
+			continue
+		}
+		estimate.Documents += file.Count
+		estimate.Chunks += file.Chunks
+		estimate.Characters += file.Characters
+		estimate.EmbeddingTokens += file.Tokens
+		chunkCharacters += file.chunkCharacters
+		if !file.Measured {
+			extrapolated += file.Count
+		}
+	}
+	if extrapolated > 0 {
+		estimate.Notes = append(estimate.Notes, fmt.Sprintf("%d manifest documents extrapolated from their type and size", extrapolated))
+	}
+
+	switch {
+	case target.PricePerMillionTokens != nil:
+		estimate.PricePerMillion = *target.PricePerMillionTokens
+	case vectorizer.Type == embeddings.VectorizerTypeNone:
+		estimate.Notes = append(estimate.Notes, "no vectorizer: vectors are supplied by the client, so no embedding cost")
+	default:
+		price, ok := embeddings.ModelPrice(vectorizer)
+		if !ok {
+			estimate.Notes = append(estimate.Notes, fmt.Sprintf("price of %s model '%s' is unknown; set price_per_million_tokens", estimate.Provider, estimate.Model))
+		}
+		estimate.PricePerMillion = price
+	}
+	estimate.EmbeddingCostUSD = float64(estimate.EmbeddingTokens) * estimate.PricePerMillion / 1e6
+
+	// Each chunk becomes a vector, storing its text in its metadata twice
+	// (chunk_content and the original content field)
+	vectorBytes := estimate.Chunks * (int64(estimate.Dimensions)*jsonBytesPerDimension + jsonBytesPerVector)
+	estimate.IndexMemoryBytes = index.EstimateMemoryUsage(index.IndexType(target.IndexType), estimate.Dimensions, int(estimate.Chunks), nil)
+	estimate.StorageBytes = vectorBytes + 2*chunkCharacters + estimate.IndexMemoryBytes
+
+	return estimate, nil
+}
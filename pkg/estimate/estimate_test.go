@@ -0,0 +1,59 @@
+package estimate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/processor"
+)
+
+func TestSummarize(t *testing.T) {
+	processing := processor.DefaultProcessingConfig()
+	processing.ChunkSize = 100
+	processing.ChunkOverlap = 0
+	processing.MinChunkSize = 20
+	estimator := NewEstimator(processing)
+
+	files := []*FileEstimate{
+		estimator.Document(strings.NewReader(strings.Repeat("Some words in a sentence. ", 20)), "notes.txt"),
+		estimator.ManifestEntry(ManifestEntry{Name: "book.txt", Size: 1000, Count: 3}),
+		estimator.ManifestEntry(ManifestEntry{Name: "setup.exe", Size: 1000}),
+	}
+	if !files[0].Measured || files[0].Chunks == 0 {
+		t.Fatalf("uploaded document not chunked: %+v", files[0])
+	}
+	if files[1].Chunks != 30 || files[1].Tokens != 750 {
+		t.Errorf("manifest entry = %d chunks, %d tokens, want 30 chunks, 750 tokens", files[1].Chunks, files[1].Tokens)
+	}
+	if files[2].Error == "" {
+		t.Error("unsupported manifest entry was not rejected")
+	}
+
+	price := 0.5
+	result, err := Summarize(files, &Target{
+		IndexType:             core.IndexTypeHNSW,
+		Vectorizer:            &embeddings.VectorizerConfig{Type: embeddings.VectorizerTypeOpenAI, Model: "text-embedding-3-small"},
+		PricePerMillionTokens: &price,
+	})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if result.Documents != 4 {
+		t.Errorf("documents = %d, want 4", result.Documents)
+	}
+	if result.Dimensions != 1536 {
+		t.Errorf("dimensions = %d, want 1536 inferred from the model", result.Dimensions)
+	}
+	if want := float64(result.EmbeddingTokens) * price / 1e6; result.EmbeddingCostUSD != want {
+		t.Errorf("cost = %f, want %f", result.EmbeddingCostUSD, want)
+	}
+	if result.IndexMemoryBytes <= 0 || result.StorageBytes <= result.IndexMemoryBytes {
+		t.Errorf("storage = %d, index memory = %d", result.StorageBytes, result.IndexMemoryBytes)
+	}
+
+	if _, err := Summarize(files, &Target{IndexType: core.IndexTypeFlat}); err == nil {
+		t.Error("expected an error without dimensions or a vectorizer")
+	}
+}
@@ -0,0 +1,321 @@
+package flight
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// errMalformed reports a flatbuffer that points outside itself
+var errMalformed = errors.New("malformed flatbuffer")
+
+// fbValue is a field of a flatbuffer table being built: a scalar of 1 to 8
+// bytes, or an offset to an object written after the table
+type fbValue struct {
+	size   int                  // Bytes of the scalar, 4 for an offset
+	scalar uint64               // Scalar value
+	object func(*fbBuilder) int // Writes the referenced object and returns its position
+}
+
+// Field constructors; a nil fbValue pointer leaves the field out
+func fbByte(v byte) *fbValue   { return &fbValue{size: 1, scalar: uint64(v)} }
+func fbInt16(v int16) *fbValue { return &fbValue{size: 2, scalar: uint64(uint16(v))} }
+func fbInt32(v int32) *fbValue { return &fbValue{size: 4, scalar: uint64(uint32(v))} }
+func fbInt64(v int64) *fbValue { return &fbValue{size: 8, scalar: uint64(v)} }
+func fbObject(write func(*fbBuilder) int) *fbValue {
+	return &fbValue{size: 4, object: write}
+}
+
+// fbBuilder writes a flatbuffer front to back: every table is followed by
+// the objects it references, so that all offsets point forward as the
+// format requires, and every scalar is aligned to its size
+type fbBuilder struct {
+	buf []byte
+}
+
+// buildFlatbuffer returns a flatbuffer whose root is the table root writes
+func buildFlatbuffer(root func(*fbBuilder) int) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 256)}
+	pos := root(b)
+	binary.LittleEndian.PutUint32(b.buf[0:], uint32(pos))
+	b.pad(8)
+	return b.buf
+}
+
+// pad appends zeros until the length is a multiple of n
+func (b *fbBuilder) pad(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// patch points the offset stored at at to target
+func (b *fbBuilder) patch(at, target int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(target-at))
+}
+
+// table writes a table with the given fields, indexed by field ID, and the
+// objects they reference, and returns its position
+func (b *fbBuilder) table(fields ...*fbValue) int {
+	// The vtable goes first, so the table's signed offset to it is positive
+	b.pad(2)
+	vtablePos := len(b.buf)
+	vtableSize := 4 + 2*len(fields)
+	tablePos := vtablePos + vtableSize
+	for tablePos%8 != 0 {
+		tablePos++
+	}
+
+	offsets := make([]int, len(fields))
+	end := tablePos + 4
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+		for end%f.size != 0 {
+			end++
+		}
+		offsets[i] = end - tablePos
+		end += f.size
+	}
+
+	vtable := make([]byte, vtableSize)
+	binary.LittleEndian.PutUint16(vtable[0:], uint16(vtableSize))
+	binary.LittleEndian.PutUint16(vtable[2:], uint16(end-tablePos))
+	for i, offset := range offsets {
+		binary.LittleEndian.PutUint16(vtable[4+2*i:], uint16(offset))
+	}
+	b.buf = append(b.buf, vtable...)
+	b.buf = append(b.buf, make([]byte, end-vtablePos-vtableSize)...)
+	binary.LittleEndian.PutUint32(b.buf[tablePos:], uint32(tablePos-vtablePos))
+
+	for i, f := range fields {
+		if f == nil || f.object != nil {
+			continue
+		}
+		at := tablePos + offsets[i]
+		switch f.size {
+		case 1:
+			b.buf[at] = byte(f.scalar)
+		case 2:
+			binary.LittleEndian.PutUint16(b.buf[at:], uint16(f.scalar))
+		case 4:
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(f.scalar))
+		case 8:
+			binary.LittleEndian.PutUint64(b.buf[at:], f.scalar)
+		}
+	}
+	for i, f := range fields {
+		if f != nil && f.object != nil {
+			b.patch(tablePos+offsets[i], f.object(b))
+		}
+	}
+	return tablePos
+}
+
+// str writes a string and returns its position
+func (b *fbBuilder) str(s string) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// tables writes a vector of tables and returns its position
+func (b *fbBuilder) tables(n int, write func(i int) int) int {
+	b.pad(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(n))
+	b.buf = append(b.buf, make([]byte, 4*n)...)
+	for i := 0; i < n; i++ {
+		b.patch(pos+4+4*i, write(i))
+	}
+	return pos
+}
+
+// int64Pairs writes a vector of structs of two int64s, as FieldNode and
+// Buffer are, and returns its position
+func (b *fbBuilder) int64Pairs(pairs [][2]int64) int {
+	// The elements are 8-byte aligned, after the 4-byte length
+	for (len(b.buf)+4)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(pairs)))
+	for _, pair := range pairs {
+		b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(pair[0]))
+		b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(pair[1]))
+	}
+	return pos
+}
+
+// fbTable reads a table of a flatbuffer. Reads outside the buffer panic with
+// errMalformed, which the decoding entry points recover into an error.
+type fbTable struct {
+	buf []byte
+	pos int
+}
+
+// rootTable returns the root table of a flatbuffer
+func rootTable(buf []byte) fbTable {
+	return fbTable{buf: buf, pos: int(readUint32(buf, 0))}
+}
+
+func readUint32(buf []byte, at int) uint32 {
+	if at < 0 || at > len(buf)-4 {
+		panic(errMalformed)
+	}
+	return binary.LittleEndian.Uint32(buf[at:])
+}
+
+// field returns the position of a field, or 0 when it is absent
+func (t fbTable) field(id int) int {
+	vtable := t.pos - int(int32(readUint32(t.buf, t.pos)))
+	if vtable < 0 || vtable > len(t.buf)-4 {
+		panic(errMalformed)
+	}
+	size := int(binary.LittleEndian.Uint16(t.buf[vtable:]))
+	entry := 4 + 2*id
+	if entry+2 > size || vtable+entry+2 > len(t.buf) {
+		return 0
+	}
+	offset := int(binary.LittleEndian.Uint16(t.buf[vtable+entry:]))
+	if offset == 0 {
+		return 0
+	}
+	return t.pos + offset
+}
+
+// scalar returns the n bytes of a scalar field, or ok false when absent
+func (t fbTable) scalar(id, n int) (uint64, bool) {
+	at := t.field(id)
+	if at == 0 {
+		return 0, false
+	}
+	if at+n > len(t.buf) {
+		panic(errMalformed)
+	}
+	switch n {
+	case 1:
+		return uint64(t.buf[at]), true
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(t.buf[at:])), true
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(t.buf[at:])), true
+	default:
+		return binary.LittleEndian.Uint64(t.buf[at:]), true
+	}
+}
+
+func (t fbTable) byteField(id int, def byte) byte {
+	if v, ok := t.scalar(id, 1); ok {
+		return byte(v)
+	}
+	return def
+}
+
+func (t fbTable) int16Field(id int, def int16) int16 {
+	if v, ok := t.scalar(id, 2); ok {
+		return int16(v)
+	}
+	return def
+}
+
+func (t fbTable) int32Field(id int, def int32) int32 {
+	if v, ok := t.scalar(id, 4); ok {
+		return int32(v)
+	}
+	return def
+}
+
+func (t fbTable) int64Field(id int, def int64) int64 {
+	if v, ok := t.scalar(id, 8); ok {
+		return int64(v)
+	}
+	return def
+}
+
+// ref follows the offset stored in a field, returning 0 when it is absent
+func (t fbTable) ref(id int) int {
+	at := t.field(id)
+	if at == 0 {
+		return 0
+	}
+	target := at + int(readUint32(t.buf, at))
+	if target <= at || target >= len(t.buf) {
+		panic(errMalformed)
+	}
+	return target
+}
+
+// table returns the table a field references
+func (t fbTable) table(id int) (fbTable, bool) {
+	pos := t.ref(id)
+	return fbTable{buf: t.buf, pos: pos}, pos != 0
+}
+
+// str returns the string a field references
+func (t fbTable) str(id int) string {
+	pos := t.ref(id)
+	if pos == 0 {
+		return ""
+	}
+	n := int(readUint32(t.buf, pos))
+	if n > len(t.buf)-pos-4 {
+		panic(errMalformed)
+	}
+	return string(t.buf[pos+4 : pos+4+n])
+}
+
+// vector returns the position of the first element and the length of the
+// vector a field references
+func (t fbTable) vector(id, elementSize int) (int, int) {
+	pos := t.ref(id)
+	if pos == 0 {
+		return 0, 0
+	}
+	n := int(readUint32(t.buf, pos))
+	if n < 0 || n > (len(t.buf)-pos-4)/elementSize {
+		panic(errMalformed)
+	}
+	return pos + 4, n
+}
+
+// tables returns the tables of a vector of tables
+func (t fbTable) tables(id int) []fbTable {
+	start, n := t.vector(id, 4)
+	tables := make([]fbTable, n)
+	for i := range tables {
+		at := start + 4*i
+		target := at + int(readUint32(t.buf, at))
+		if target <= at || target >= len(t.buf) {
+			panic(errMalformed)
+		}
+		tables[i] = fbTable{buf: t.buf, pos: target}
+	}
+	return tables
+}
+
+// int64Pairs returns a vector of structs of two int64s
+func (t fbTable) int64Pairs(id int) [][2]int64 {
+	start, n := t.vector(id, 16)
+	pairs := make([][2]int64, n)
+	for i := range pairs {
+		at := start + 16*i
+		pairs[i][0] = int64(binary.LittleEndian.Uint64(t.buf[at:]))
+		pairs[i][1] = int64(binary.LittleEndian.Uint64(t.buf[at+8:]))
+	}
+	return pairs
+}
+
+// recoverMalformed turns a panic on a malformed flatbuffer into an
+// InvalidArgument status
+func recoverMalformed(err *error) {
+	if r := recover(); r != nil {
+		if r != errMalformed {
+			panic(r)
+		}
+		*err = Errorf(CodeInvalidArgument, "%v", errMalformed)
+	}
+}
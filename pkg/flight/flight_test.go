@@ -0,0 +1,81 @@
+package flight
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+func TestRecordBatchRoundTrip(t *testing.T) {
+	records := []*core.Vector{
+		{ID: "a", Vector: []float32{0.5, -1}, Metadata: map[string]interface{}{
+			"title": "first", "year": float64(2020), "score": 0.25, "draft": true, "tags": []interface{}{"x", "y"},
+		}},
+		{ID: "b", Namespace: "tenant", Vector: []float32{2, 3}, Metadata: map[string]interface{}{
+			"year": 1999, "score": float64(1), "mixed": "text",
+		}},
+		{ID: "c", Metadata: map[string]interface{}{"mixed": float64(7)}}, // Metadata-only
+	}
+
+	schema := NewRecordSchema(2, records)
+	header, body := schema.Batch(records)
+
+	// Messages travel as FlightData
+	data := &FlightData{DataHeader: header, DataBody: body}
+	var received FlightData
+	if err := received.Unmarshal(data.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	var decoder Decoder
+	if got, err := decoder.Decode(schema.Header(), nil); err != nil || got != nil {
+		t.Fatalf("schema message: got %v, %v", got, err)
+	}
+	got, err := decoder.Decode(received.DataHeader, received.DataBody)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	want := []*core.Vector{
+		{ID: "a", Vector: []float32{0.5, -1}, Metadata: map[string]interface{}{
+			"title": "first", "year": float64(2020), "score": 0.25, "draft": true, "tags": []interface{}{"x", "y"},
+		}},
+		{ID: "b", Namespace: "tenant", Vector: []float32{2, 3}, Metadata: map[string]interface{}{
+			"year": float64(1999), "score": float64(1), "mixed": "text",
+		}},
+		{ID: "c", Metadata: map[string]interface{}{"mixed": float64(7)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for i := range got {
+			t.Logf("got %+v", got[i])
+		}
+		t.Fatalf("records changed in the round trip")
+	}
+}
+
+func TestDecodeRejectsMalformedMessages(t *testing.T) {
+	var decoder Decoder
+	if _, err := decoder.Decode([]byte{1, 2, 3, 4, 5, 6, 7, 8}, nil); !isInvalidArgument(err) {
+		t.Errorf("expected InvalidArgument for garbage, got %v", err)
+	}
+
+	records := []*core.Vector{{ID: "a", Vector: []float32{1, 2}}}
+	schema := NewRecordSchema(2, records)
+	header, body := schema.Batch(records)
+	if _, err := decoder.Decode(header, body); !isInvalidArgument(err) {
+		t.Errorf("expected InvalidArgument for a batch before the schema, got %v", err)
+	}
+	if _, err := decoder.Decode(schema.Header(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decoder.Decode(header, body[:8]); !isInvalidArgument(err) {
+		t.Errorf("expected InvalidArgument for a truncated body, got %v", err)
+	}
+}
+
+func isInvalidArgument(err error) bool {
+	var status *Status
+	return errors.As(err, &status) && status.Code == CodeInvalidArgument
+}
@@ -0,0 +1,165 @@
+package flight
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ServicePath is the URL path prefix of the Flight RPCs, followed by the
+// method name
+const ServicePath = "/arrow.flight.protocol.FlightService/"
+
+// ContentType is the content type of gRPC requests and responses
+const ContentType = "application/grpc"
+
+// MaxMessageSize bounds a received message, as gRPC does by default for
+// clients sending record batches
+const MaxMessageSize = 64 << 20
+
+// Code is a gRPC status code
+type Code int
+
+// gRPC status codes used by the service
+const (
+	CodeOK                 Code = 0
+	CodeUnknown            Code = 2
+	CodeInvalidArgument    Code = 3
+	CodeNotFound           Code = 5
+	CodeAlreadyExists      Code = 6
+	CodePermissionDenied   Code = 7
+	CodeResourceExhausted  Code = 8
+	CodeFailedPrecondition Code = 9
+	CodeUnimplemented      Code = 12
+	CodeInternal           Code = 13
+	CodeUnavailable        Code = 14
+	CodeUnauthenticated    Code = 16
+)
+
+// Status is an error ending a call with a gRPC status
+type Status struct {
+	Code    Code
+	Message string
+}
+
+// Errorf returns a Status error
+func Errorf(code Code, format string, args ...interface{}) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("flight: code %d: %s", s.Code, s.Message)
+}
+
+// Stream is the server side of a gRPC call: a sequence of length-prefixed
+// messages each way, ended by a status sent in the trailers
+type Stream struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	flusher http.Flusher
+	started bool
+}
+
+// NewStream starts a call made with request r, which needs HTTP/2
+func NewStream(w http.ResponseWriter, r *http.Request) (*Stream, error) {
+	if r.ProtoMajor != 2 {
+		return nil, fmt.Errorf("gRPC requires HTTP/2, got %s", r.Proto)
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), ContentType) {
+		return nil, fmt.Errorf("content type must be %s", ContentType)
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Add("Trailer", "Grpc-Status")
+	w.Header().Add("Trailer", "Grpc-Message")
+	return &Stream{w: w, r: r, flusher: flusher}, nil
+}
+
+// Method returns the name of the RPC called
+func (s *Stream) Method() string {
+	return strings.TrimPrefix(s.r.URL.Path, ServicePath)
+}
+
+// Recv returns the next message of the client, or io.EOF once it has sent
+// all of them
+func (s *Stream) Recv() ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(s.r.Body, prefix[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, Errorf(CodeInvalidArgument, "truncated message")
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, Errorf(CodeUnimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > MaxMessageSize {
+		return nil, Errorf(CodeResourceExhausted, "message of %d bytes exceeds the limit of %d", size, MaxMessageSize)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(s.r.Body, msg); err != nil {
+		return nil, Errorf(CodeInvalidArgument, "truncated message")
+	}
+	return msg, nil
+}
+
+// RecvOne returns the only message of a unary call
+func (s *Stream) RecvOne() ([]byte, error) {
+	msg, err := s.Recv()
+	if err == io.EOF {
+		return nil, Errorf(CodeInvalidArgument, "missing request message")
+	}
+	return msg, err
+}
+
+// Send sends a message to the client
+func (s *Stream) Send(msg []byte) error {
+	if !s.started {
+		s.started = true
+		s.w.WriteHeader(http.StatusOK)
+	}
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err := s.w.Write(prefix[:]); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(msg); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// Finish ends the call with status, OK when nil
+func (s *Stream) Finish(status *Status) {
+	if !s.started {
+		s.w.WriteHeader(http.StatusOK)
+	}
+	if status == nil {
+		s.w.Header().Set("Grpc-Status", strconv.Itoa(int(CodeOK)))
+		return
+	}
+	s.w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	s.w.Header().Set("Grpc-Message", encodeMessage(status.Message))
+}
+
+// encodeMessage percent-encodes a status message as gRPC requires
+func encodeMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,579 @@
+package flight
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+)
+
+// Arrow type IDs, as numbered by the Type union of Schema.fbs
+const (
+	typeNull          = 1
+	typeInt           = 2
+	typeFloat         = 3
+	typeBinary        = 4
+	typeUtf8          = 5
+	typeBool          = 6
+	typeList          = 12
+	typeStruct        = 13
+	typeFixedSizeList = 16
+	typeLargeBinary   = 19
+	typeLargeUtf8     = 20
+	typeLargeList     = 21
+)
+
+// Floating point precisions
+const (
+	precisionHalf   = 0
+	precisionSingle = 1
+	precisionDouble = 2
+)
+
+// IPC message header types and the metadata version written
+const (
+	headerSchema          = 1
+	headerDictionaryBatch = 2
+	headerRecordBatch     = 3
+	metadataV4            = 3
+	metadataV5            = 4
+)
+
+// ipcContinuation starts every encapsulated IPC message
+const ipcContinuation = 0xFFFFFFFF
+
+// field is a column of an Arrow schema, or a child of a nested column
+type field struct {
+	name      string
+	nullable  bool
+	typ       byte
+	bitWidth  int   // Int
+	signed    bool  // Int
+	precision int16 // FloatingPoint
+	listSize  int   // FixedSizeList
+	json      bool  // Utf8 holding JSON text, the arrow.json extension type
+	children  []*field
+}
+
+// Field metadata keys naming an extension type, and the JSON one
+const (
+	extensionNameKey     = "ARROW:extension:name"
+	extensionMetadataKey = "ARROW:extension:metadata"
+	extensionJSON        = "arrow.json"
+)
+
+// write writes the Field table of f
+func (f *field) write(b *fbBuilder) int {
+	var typ func(*fbBuilder) int
+	switch f.typ {
+	case typeInt:
+		typ = func(b *fbBuilder) int { return b.table(fbInt32(int32(f.bitWidth)), fbByte(boolByte(f.signed))) }
+	case typeFloat:
+		typ = func(b *fbBuilder) int { return b.table(fbInt16(f.precision)) }
+	case typeFixedSizeList:
+		typ = func(b *fbBuilder) int { return b.table(fbInt32(int32(f.listSize))) }
+	default:
+		typ = func(b *fbBuilder) int { return b.table() }
+	}
+	var custom *fbValue
+	if f.json {
+		pairs := [][2]string{{extensionNameKey, extensionJSON}, {extensionMetadataKey, ""}}
+		custom = fbObject(func(b *fbBuilder) int {
+			return b.tables(len(pairs), func(i int) int {
+				return b.table(
+					fbObject(func(b *fbBuilder) int { return b.str(pairs[i][0]) }),
+					fbObject(func(b *fbBuilder) int { return b.str(pairs[i][1]) }),
+				)
+			})
+		})
+	}
+	return b.table(
+		fbObject(func(b *fbBuilder) int { return b.str(f.name) }),
+		fbByte(boolByte(f.nullable)),
+		fbByte(f.typ),
+		fbObject(typ),
+		nil,
+		fbObject(func(b *fbBuilder) int {
+			return b.tables(len(f.children), func(i int) int { return f.children[i].write(b) })
+		}),
+		custom,
+	)
+}
+
+// readField reads a Field table
+func readField(t fbTable) (*field, error) {
+	f := &field{
+		name:     t.str(0),
+		nullable: t.byteField(1, 0) != 0,
+		typ:      t.byteField(2, 0),
+	}
+	if _, ok := t.table(4); ok {
+		return nil, Errorf(CodeUnimplemented, "column '%s' is dictionary-encoded, which is not supported", f.name)
+	}
+	typ, ok := t.table(3)
+	if !ok {
+		return nil, Errorf(CodeInvalidArgument, "column '%s' has no type", f.name)
+	}
+	switch f.typ {
+	case typeInt:
+		f.bitWidth = int(typ.int32Field(0, 0))
+		f.signed = typ.byteField(1, 0) != 0
+		switch f.bitWidth {
+		case 8, 16, 32, 64:
+		default:
+			return nil, Errorf(CodeInvalidArgument, "column '%s' has an invalid bit width %d", f.name, f.bitWidth)
+		}
+	case typeFloat:
+		f.precision = typ.int16Field(0, precisionHalf)
+		if f.precision == precisionHalf {
+			return nil, Errorf(CodeUnimplemented, "column '%s' is float16, which is not supported", f.name)
+		}
+	case typeFixedSizeList:
+		f.listSize = int(typ.int32Field(0, 0))
+		if f.listSize < 0 {
+			return nil, Errorf(CodeInvalidArgument, "column '%s' has a negative list size", f.name)
+		}
+	case typeNull, typeBinary, typeUtf8, typeBool, typeList, typeStruct, typeLargeBinary, typeLargeUtf8, typeLargeList:
+	default:
+		return nil, Errorf(CodeUnimplemented, "column '%s' has Arrow type %d, which is not supported", f.name, f.typ)
+	}
+	for _, kv := range t.tables(6) {
+		if kv.str(0) == extensionNameKey && kv.str(1) == extensionJSON {
+			f.json = f.typ == typeUtf8 || f.typ == typeLargeUtf8
+		}
+	}
+	for _, child := range t.tables(5) {
+		c, err := readField(child)
+		if err != nil {
+			return nil, err
+		}
+		f.children = append(f.children, c)
+	}
+	switch f.typ {
+	case typeList, typeLargeList, typeFixedSizeList:
+		if len(f.children) != 1 {
+			return nil, Errorf(CodeInvalidArgument, "list column '%s' needs one child", f.name)
+		}
+	}
+	return f, nil
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// message returns the flatbuffer of an IPC message
+func message(headerType byte, bodyLength int, header func(*fbBuilder) int) []byte {
+	return buildFlatbuffer(func(b *fbBuilder) int {
+		return b.table(fbInt16(metadataV5), fbByte(headerType), fbObject(header), fbInt64(int64(bodyLength)))
+	})
+}
+
+// schemaMessage returns the IPC message of a schema
+func schemaMessage(fields []*field) []byte {
+	return message(headerSchema, 0, func(b *fbBuilder) int {
+		return b.table(nil, fbObject(func(b *fbBuilder) int {
+			return b.tables(len(fields), func(i int) int { return fields[i].write(b) })
+		}))
+	})
+}
+
+// encapsulate frames an IPC message as the IPC stream format does, which is
+// how FlightInfo and SchemaResult carry schemas
+func encapsulate(msg []byte) []byte {
+	b := make([]byte, 8, 8+len(msg))
+	binary.LittleEndian.PutUint32(b[0:], ipcContinuation)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// array is the data of a column in a record batch: the buffers its type
+// lays out and the arrays of its children
+type array struct {
+	length    int
+	nullCount int
+	buffers   [][]byte
+	children  []*array
+}
+
+// recordBatch returns the IPC message and body of a record batch of columns
+func recordBatch(length int, columns []*array) ([]byte, []byte) {
+	var nodes, buffers [][2]int64
+	var body []byte
+	var walk func(a *array)
+	walk = func(a *array) {
+		nodes = append(nodes, [2]int64{int64(a.length), int64(a.nullCount)})
+		for _, buf := range a.buffers {
+			buffers = append(buffers, [2]int64{int64(len(body)), int64(len(buf))})
+			body = append(body, buf...)
+			for len(body)%8 != 0 {
+				body = append(body, 0)
+			}
+		}
+		for _, child := range a.children {
+			walk(child)
+		}
+	}
+	for _, column := range columns {
+		walk(column)
+	}
+
+	header := message(headerRecordBatch, len(body), func(b *fbBuilder) int {
+		return b.table(
+			fbInt64(int64(length)),
+			fbObject(func(b *fbBuilder) int { return b.int64Pairs(nodes) }),
+			fbObject(func(b *fbBuilder) int { return b.int64Pairs(buffers) }),
+		)
+	})
+	return header, body
+}
+
+// validity returns the validity bitmap of valid and the null count; the
+// bitmap is empty when there are no nulls
+func validity(valid []bool) ([]byte, int) {
+	nulls := 0
+	for _, v := range valid {
+		if !v {
+			nulls++
+		}
+	}
+	if nulls == 0 {
+		return nil, 0
+	}
+	bitmap := make([]byte, (len(valid)+7)/8)
+	for i, v := range valid {
+		if v {
+			bitmap[i/8] |= 1 << (i % 8)
+		}
+	}
+	return bitmap, nulls
+}
+
+// utf8Array returns an array of strings; invalid ones are null
+func utf8Array(values []string, valid []bool) *array {
+	bitmap, nulls := validity(valid)
+	offsets := make([]byte, 4*(len(values)+1))
+	var data []byte
+	for i, v := range values {
+		if valid == nil || valid[i] {
+			data = append(data, v...)
+		}
+		binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+	}
+	return &array{length: len(values), nullCount: nulls, buffers: [][]byte{bitmap, offsets, data}}
+}
+
+// boolArray returns an array of booleans; invalid ones are null
+func boolArray(values, valid []bool) *array {
+	bitmap, nulls := validity(valid)
+	data := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			data[i/8] |= 1 << (i % 8)
+		}
+	}
+	return &array{length: len(values), nullCount: nulls, buffers: [][]byte{bitmap, data}}
+}
+
+// int64Array returns an array of int64s; invalid ones are null
+func int64Array(values []int64, valid []bool) *array {
+	bitmap, nulls := validity(valid)
+	data := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(data[8*i:], uint64(v))
+	}
+	return &array{length: len(values), nullCount: nulls, buffers: [][]byte{bitmap, data}}
+}
+
+// float64Array returns an array of float64s; invalid ones are null
+func float64Array(values []float64, valid []bool) *array {
+	bitmap, nulls := validity(valid)
+	data := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(v))
+	}
+	return &array{length: len(values), nullCount: nulls, buffers: [][]byte{bitmap, data}}
+}
+
+// vectorArray returns a fixed-size list array of float32 vectors of
+// dimensions values; empty vectors are null
+func vectorArray(vectors [][]float32, dimensions int) *array {
+	valid := make([]bool, len(vectors))
+	data := make([]byte, 4*dimensions*len(vectors))
+	for i, vector := range vectors {
+		if len(vector) != dimensions {
+			continue
+		}
+		valid[i] = true
+		for j, v := range vector {
+			binary.LittleEndian.PutUint32(data[4*(i*dimensions+j):], math.Float32bits(v))
+		}
+	}
+	bitmap, nulls := validity(valid)
+	values := &array{length: dimensions * len(vectors), buffers: [][]byte{nil, data}}
+	return &array{length: len(vectors), nullCount: nulls, buffers: [][]byte{bitmap}, children: []*array{values}}
+}
+
+// column is a column of a received record batch, with its buffers checked
+// to be large enough for its length
+type column struct {
+	field    *field
+	length   int
+	nulls    []byte // Validity bitmap, nil without nulls
+	offsets  []byte // Offsets of strings and lists
+	values   []byte // Values of primitives, bytes of strings
+	children []*column
+}
+
+// batchReader hands out the nodes and buffers of a record batch in the
+// order of its columns
+type batchReader struct {
+	nodes   [][2]int64
+	buffers [][2]int64
+	body    []byte
+}
+
+func (r *batchReader) node() ([2]int64, error) {
+	if len(r.nodes) == 0 {
+		return [2]int64{}, Errorf(CodeInvalidArgument, "record batch has too few field nodes")
+	}
+	node := r.nodes[0]
+	r.nodes = r.nodes[1:]
+	if node[0] < 0 || node[1] < 0 || node[1] > node[0] {
+		return node, Errorf(CodeInvalidArgument, "record batch has an invalid field node")
+	}
+	return node, nil
+}
+
+func (r *batchReader) buffer() ([]byte, error) {
+	if len(r.buffers) == 0 {
+		return nil, Errorf(CodeInvalidArgument, "record batch has too few buffers")
+	}
+	buf := r.buffers[0]
+	r.buffers = r.buffers[1:]
+	if buf[0] < 0 || buf[1] < 0 || buf[0] > int64(len(r.body)) || buf[1] > int64(len(r.body))-buf[0] {
+		return nil, Errorf(CodeInvalidArgument, "record batch buffer lies outside the body")
+	}
+	return r.body[buf[0] : buf[0]+buf[1]], nil
+}
+
+// read reads the column of f and its children
+func (r *batchReader) read(f *field) (*column, error) {
+	node, err := r.node()
+	if err != nil {
+		return nil, err
+	}
+	c := &column{field: f, length: int(node[0])}
+	tooShort := func() error {
+		return Errorf(CodeInvalidArgument, "buffers of column '%s' are too short for %d rows", f.name, c.length)
+	}
+
+	if f.typ != typeNull {
+		if c.nulls, err = r.buffer(); err != nil {
+			return nil, err
+		}
+		if node[1] == 0 {
+			c.nulls = nil
+		} else if len(c.nulls) < (c.length+7)/8 {
+			return nil, tooShort()
+		}
+	}
+
+	switch f.typ {
+	case typeBool, typeInt, typeFloat:
+		if c.values, err = r.buffer(); err != nil {
+			return nil, err
+		}
+		if len(c.values) < (c.length*f.width()+7)/8 {
+			return nil, tooShort()
+		}
+	case typeUtf8, typeBinary, typeLargeUtf8, typeLargeBinary:
+		if c.offsets, err = r.buffer(); err != nil {
+			return nil, err
+		}
+		if c.values, err = r.buffer(); err != nil {
+			return nil, err
+		}
+		if err := c.checkOffsets(len(c.values)); err != nil {
+			return nil, err
+		}
+	case typeList, typeLargeList:
+		if c.offsets, err = r.buffer(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, child := range f.children {
+		cc, err := r.read(child)
+		if err != nil {
+			return nil, err
+		}
+		c.children = append(c.children, cc)
+	}
+
+	switch f.typ {
+	case typeList, typeLargeList:
+		if err := c.checkOffsets(c.children[0].length); err != nil {
+			return nil, err
+		}
+	case typeFixedSizeList:
+		if c.children[0].length < c.length*f.listSize {
+			return nil, tooShort()
+		}
+	case typeStruct:
+		for _, child := range c.children {
+			if child.length < c.length {
+				return nil, tooShort()
+			}
+		}
+	}
+	return c, nil
+}
+
+// width returns the bits of a value of a primitive type
+func (f *field) width() int {
+	switch f.typ {
+	case typeBool:
+		return 1
+	case typeInt:
+		return f.bitWidth
+	case typeFloat:
+		if f.precision == precisionSingle {
+			return 32
+		}
+		return 64
+	}
+	return 0
+}
+
+// large reports whether a string or list type has 64-bit offsets
+func (f *field) large() bool {
+	return f.typ == typeLargeUtf8 || f.typ == typeLargeBinary || f.typ == typeLargeList
+}
+
+// offset returns the i-th offset of a string or list column
+func (c *column) offset(i int) int {
+	if c.field.large() {
+		return int(binary.LittleEndian.Uint64(c.offsets[8*i:]))
+	}
+	return int(int32(binary.LittleEndian.Uint32(c.offsets[4*i:])))
+}
+
+// checkOffsets checks that the offsets are in order and within limit
+func (c *column) checkOffsets(limit int) error {
+	size := 4
+	if c.field.large() {
+		size = 8
+	}
+	if c.length > 0 && len(c.offsets) < size*(c.length+1) {
+		return Errorf(CodeInvalidArgument, "offsets of column '%s' are too short for %d rows", c.field.name, c.length)
+	}
+	previous := 0
+	for i := 0; c.length > 0 && i <= c.length; i++ {
+		offset := c.offset(i)
+		if offset < previous || offset > limit {
+			return Errorf(CodeInvalidArgument, "column '%s' has invalid offsets", c.field.name)
+		}
+		previous = offset
+	}
+	return nil
+}
+
+// valid reports whether row i is not null
+func (c *column) valid(i int) bool {
+	if c.field.typ == typeNull {
+		return false
+	}
+	return c.nulls == nil || c.nulls[i/8]&(1<<(i%8)) != 0
+}
+
+// float returns row i of a floating point or integer column
+func (c *column) float(i int) float64 {
+	f := c.field
+	switch f.typ {
+	case typeFloat:
+		if f.precision == precisionSingle {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(c.values[4*i:])))
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(c.values[8*i:]))
+	case typeInt:
+		var bits uint64
+		switch f.bitWidth {
+		case 8:
+			bits = uint64(c.values[i])
+			if f.signed {
+				return float64(int8(bits))
+			}
+		case 16:
+			bits = uint64(binary.LittleEndian.Uint16(c.values[2*i:]))
+			if f.signed {
+				return float64(int16(bits))
+			}
+		case 32:
+			bits = uint64(binary.LittleEndian.Uint32(c.values[4*i:]))
+			if f.signed {
+				return float64(int32(bits))
+			}
+		default:
+			bits = binary.LittleEndian.Uint64(c.values[8*i:])
+			if f.signed {
+				return float64(int64(bits))
+			}
+		}
+		return float64(bits)
+	}
+	return 0
+}
+
+// str returns row i of a string column
+func (c *column) str(i int) string {
+	return string(c.values[c.offset(i):c.offset(i+1)])
+}
+
+// span returns the child rows of row i of a list column
+func (c *column) span(i int) (int, int) {
+	if c.field.typ == typeFixedSizeList {
+		return i * c.field.listSize, (i + 1) * c.field.listSize
+	}
+	return c.offset(i), c.offset(i + 1)
+}
+
+// value returns row i as a metadata value: nil, a bool, a float64, a
+// string, a list or a map
+func (c *column) value(i int) interface{} {
+	if !c.valid(i) {
+		return nil
+	}
+	switch c.field.typ {
+	case typeBool:
+		return c.values[i/8]&(1<<(i%8)) != 0
+	case typeInt, typeFloat:
+		return c.float(i)
+	case typeUtf8, typeLargeUtf8, typeBinary, typeLargeBinary:
+		if c.field.json {
+			var v interface{}
+			if err := json.Unmarshal(c.values[c.offset(i):c.offset(i+1)], &v); err == nil {
+				return v
+			}
+		}
+		return c.str(i)
+	case typeList, typeLargeList, typeFixedSizeList:
+		start, end := c.span(i)
+		list := make([]interface{}, 0, end-start)
+		for j := start; j < end; j++ {
+			list = append(list, c.children[0].value(j))
+		}
+		return list
+	case typeStruct:
+		fields := make(map[string]interface{}, len(c.children))
+		for _, child := range c.children {
+			if v := child.value(i); v != nil {
+				fields[child.field.name] = v
+			}
+		}
+		return fields
+	}
+	return nil
+}
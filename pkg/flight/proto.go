@@ -0,0 +1,321 @@
+package flight
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// errTruncated reports a protobuf message cut short
+var errTruncated = errors.New("truncated protobuf message")
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// parseFields calls fn with every field of a protobuf message: data holds
+// the payload of length-delimited fields, v the value of the others
+func parseFields(b []byte, fn func(field, wire int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		field, wire := int(tag>>3), int(tag&7)
+
+		var v uint64
+		var data []byte
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return errTruncated
+			}
+			data, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+		if err := fn(field, wire, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DescriptorType tells how a FlightDescriptor names its dataset
+type DescriptorType int
+
+// Descriptor types
+const (
+	DescriptorUnknown DescriptorType = 0
+	DescriptorPath    DescriptorType = 1 // Path holds the name of the dataset
+	DescriptorCmd     DescriptorType = 2 // Cmd holds an opaque command
+)
+
+// FlightDescriptor names a dataset
+type FlightDescriptor struct {
+	Type DescriptorType
+	Cmd  []byte
+	Path []string
+}
+
+// Marshal encodes the descriptor
+func (d *FlightDescriptor) Marshal() []byte {
+	b := appendVarintField(nil, 1, uint64(d.Type))
+	if len(d.Cmd) > 0 {
+		b = appendBytesField(b, 2, d.Cmd)
+	}
+	for _, p := range d.Path {
+		b = appendBytesField(b, 3, []byte(p))
+	}
+	return b
+}
+
+// Unmarshal decodes the descriptor
+func (d *FlightDescriptor) Unmarshal(b []byte) error {
+	return parseFields(b, func(field, wire int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			d.Type = DescriptorType(v)
+		case 2:
+			d.Cmd = append([]byte(nil), data...)
+		case 3:
+			d.Path = append(d.Path, string(data))
+		}
+		return nil
+	})
+}
+
+// Ticket identifies a stream of DoGet
+type Ticket struct {
+	Ticket []byte
+}
+
+// Marshal encodes the ticket
+func (t *Ticket) Marshal() []byte {
+	return appendBytesField(nil, 1, t.Ticket)
+}
+
+// Unmarshal decodes the ticket
+func (t *Ticket) Unmarshal(b []byte) error {
+	return parseFields(b, func(field, wire int, v uint64, data []byte) error {
+		if field == 1 {
+			t.Ticket = append([]byte(nil), data...)
+		}
+		return nil
+	})
+}
+
+// FlightEndpoint is where a part of a dataset is fetched; no locations
+// means the service that answered
+type FlightEndpoint struct {
+	Ticket    *Ticket
+	Locations []string
+}
+
+// Marshal encodes the endpoint
+func (e *FlightEndpoint) Marshal() []byte {
+	var b []byte
+	if e.Ticket != nil {
+		b = appendBytesField(b, 1, e.Ticket.Marshal())
+	}
+	for _, uri := range e.Locations {
+		b = appendBytesField(b, 2, appendBytesField(nil, 1, []byte(uri)))
+	}
+	return b
+}
+
+// Unmarshal decodes the endpoint
+func (e *FlightEndpoint) Unmarshal(b []byte) error {
+	return parseFields(b, func(field, wire int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			e.Ticket = &Ticket{}
+			return e.Ticket.Unmarshal(data)
+		case 2:
+			return parseFields(data, func(field, wire int, v uint64, data []byte) error {
+				if field == 1 {
+					e.Locations = append(e.Locations, string(data))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// FlightInfo describes a dataset and where to fetch it
+type FlightInfo struct {
+	Schema       []byte // Encapsulated IPC schema message
+	Descriptor   *FlightDescriptor
+	Endpoints    []*FlightEndpoint
+	TotalRecords int64 // -1 when unknown
+	TotalBytes   int64 // -1 when unknown
+	Ordered      bool
+}
+
+// Marshal encodes the info
+func (i *FlightInfo) Marshal() []byte {
+	b := appendBytesField(nil, 1, i.Schema)
+	if i.Descriptor != nil {
+		b = appendBytesField(b, 2, i.Descriptor.Marshal())
+	}
+	for _, e := range i.Endpoints {
+		b = appendBytesField(b, 3, e.Marshal())
+	}
+	b = appendVarintField(b, 4, uint64(i.TotalRecords))
+	b = appendVarintField(b, 5, uint64(i.TotalBytes))
+	if i.Ordered {
+		b = appendVarintField(b, 6, 1)
+	}
+	return b
+}
+
+// Unmarshal decodes the info
+func (i *FlightInfo) Unmarshal(b []byte) error {
+	return parseFields(b, func(field, wire int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			i.Schema = append([]byte(nil), data...)
+		case 2:
+			i.Descriptor = &FlightDescriptor{}
+			return i.Descriptor.Unmarshal(data)
+		case 3:
+			endpoint := &FlightEndpoint{}
+			i.Endpoints = append(i.Endpoints, endpoint)
+			return endpoint.Unmarshal(data)
+		case 4:
+			i.TotalRecords = int64(v)
+		case 5:
+			i.TotalBytes = int64(v)
+		case 6:
+			i.Ordered = v != 0
+		}
+		return nil
+	})
+}
+
+// SchemaResult is the answer of GetSchema
+type SchemaResult struct {
+	Schema []byte // Encapsulated IPC schema message
+}
+
+// Marshal encodes the result
+func (r *SchemaResult) Marshal() []byte {
+	return appendBytesField(nil, 1, r.Schema)
+}
+
+// FlightData is a message of a data stream: an IPC message header with its
+// body. The first message of a DoPut also carries the descriptor.
+type FlightData struct {
+	Descriptor  *FlightDescriptor
+	DataHeader  []byte // Flatbuffer of the IPC message
+	AppMetadata []byte
+	DataBody    []byte
+}
+
+// Marshal encodes the data
+func (d *FlightData) Marshal() []byte {
+	b := make([]byte, 0, len(d.DataHeader)+len(d.DataBody)+32)
+	if d.Descriptor != nil {
+		b = appendBytesField(b, 1, d.Descriptor.Marshal())
+	}
+	if len(d.DataHeader) > 0 {
+		b = appendBytesField(b, 2, d.DataHeader)
+	}
+	if len(d.AppMetadata) > 0 {
+		b = appendBytesField(b, 3, d.AppMetadata)
+	}
+	if len(d.DataBody) > 0 {
+		b = appendBytesField(b, 1000, d.DataBody)
+	}
+	return b
+}
+
+// Unmarshal decodes the data. The header and body alias b.
+func (d *FlightData) Unmarshal(b []byte) error {
+	return parseFields(b, func(field, wire int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			d.Descriptor = &FlightDescriptor{}
+			return d.Descriptor.Unmarshal(data)
+		case 2:
+			d.DataHeader = data
+		case 3:
+			d.AppMetadata = data
+		case 1000:
+			d.DataBody = data
+		}
+		return nil
+	})
+}
+
+// PutResult acknowledges the batches of a DoPut
+type PutResult struct {
+	AppMetadata []byte
+}
+
+// Marshal encodes the result
+func (r *PutResult) Marshal() []byte {
+	return appendBytesField(nil, 1, r.AppMetadata)
+}
+
+// Unmarshal decodes the result
+func (r *PutResult) Unmarshal(b []byte) error {
+	return parseFields(b, func(field, wire int, v uint64, data []byte) error {
+		if field == 1 {
+			r.AppMetadata = append([]byte(nil), data...)
+		}
+		return nil
+	})
+}
+
+// HandshakeResponse answers a handshake request
+type HandshakeResponse struct {
+	ProtocolVersion uint64
+	Payload         []byte
+}
+
+// Marshal encodes the response
+func (r *HandshakeResponse) Marshal() []byte {
+	b := appendVarintField(nil, 1, r.ProtocolVersion)
+	if len(r.Payload) > 0 {
+		b = appendBytesField(b, 2, r.Payload)
+	}
+	return b
+}
@@ -0,0 +1,413 @@
+package flight
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// Columns of the record batches collections are exchanged in
+const (
+	ColumnID        = "id"
+	ColumnNamespace = "namespace"
+	ColumnVector    = "vector"
+	ColumnMetadata  = "metadata"
+)
+
+// maxSafeInteger is the largest integer every float64 up to which is exact
+const maxSafeInteger = 1 << 53
+
+// metadataKind is the Arrow type a metadata field is exported as
+type metadataKind int
+
+const (
+	kindBool   metadataKind = iota
+	kindInt                 // Integral numbers, as int64
+	kindFloat               // Other numbers, as float64
+	kindString              // Strings, as utf8
+	kindJSON                // Lists, objects and mixed types, as arrow.json
+)
+
+// metadataColumn is a child of the metadata struct column
+type metadataColumn struct {
+	key  string
+	kind metadataKind
+}
+
+// RecordSchema is the Arrow schema records of a collection are exported in:
+// an id and a namespace string, the vector as a fixed-size list of float32
+// (null for metadata-only records) and the metadata as a struct with a
+// child per key found in the records
+type RecordSchema struct {
+	dimensions int
+	metadata   []metadataColumn
+	fields     []*field
+}
+
+// NewRecordSchema infers the schema of records of a collection of the given
+// dimensions. A metadata key holding only booleans, integers, numbers or
+// strings gets a column of that type; one holding lists, objects or values
+// of several types gets JSON text, typed as the arrow.json extension.
+func NewRecordSchema(dimensions int, records []*core.Vector) *RecordSchema {
+	kinds := make(map[string]metadataKind)
+	for _, record := range records {
+		for key, value := range record.Metadata {
+			if value == nil {
+				continue
+			}
+			kind := kindOf(value)
+			if previous, seen := kinds[key]; seen && previous != kind {
+				if (previous == kindInt || previous == kindFloat) && (kind == kindInt || kind == kindFloat) {
+					kind = kindFloat
+				} else {
+					kind = kindJSON
+				}
+			}
+			kinds[key] = kind
+		}
+	}
+
+	s := &RecordSchema{dimensions: dimensions}
+	for key, kind := range kinds {
+		s.metadata = append(s.metadata, metadataColumn{key: key, kind: kind})
+	}
+	sort.Slice(s.metadata, func(i, j int) bool { return s.metadata[i].key < s.metadata[j].key })
+
+	metadata := &field{name: ColumnMetadata, nullable: true, typ: typeStruct}
+	for _, m := range s.metadata {
+		child := &field{name: m.key, nullable: true}
+		switch m.kind {
+		case kindBool:
+			child.typ = typeBool
+		case kindInt:
+			child.typ, child.bitWidth, child.signed = typeInt, 64, true
+		case kindFloat:
+			child.typ, child.precision = typeFloat, precisionDouble
+		case kindString:
+			child.typ = typeUtf8
+		default:
+			child.typ, child.json = typeUtf8, true
+		}
+		metadata.children = append(metadata.children, child)
+	}
+	s.fields = []*field{
+		{name: ColumnID, typ: typeUtf8},
+		{name: ColumnNamespace, typ: typeUtf8},
+		{name: ColumnVector, nullable: true, typ: typeFixedSizeList, listSize: dimensions, children: []*field{
+			{name: "item", typ: typeFloat, precision: precisionSingle},
+		}},
+		metadata,
+	}
+	return s
+}
+
+// kindOf returns the kind of a metadata value
+func kindOf(value interface{}) metadataKind {
+	switch v := value.(type) {
+	case bool:
+		return kindBool
+	case string:
+		return kindString
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return kindInt
+	case float32, float64, json.Number:
+		f, _ := number(v)
+		if f == math.Trunc(f) && math.Abs(f) <= maxSafeInteger {
+			return kindInt
+		}
+		return kindFloat
+	}
+	return kindJSON
+}
+
+// number returns a numeric metadata value as a float64
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Header returns the IPC schema message, for the first FlightData of a
+// stream
+func (s *RecordSchema) Header() []byte {
+	return schemaMessage(s.fields)
+}
+
+// IPC returns the encapsulated IPC schema message, for FlightInfo and
+// SchemaResult
+func (s *RecordSchema) IPC() []byte {
+	return encapsulate(s.Header())
+}
+
+// Batch returns the IPC message and body of a record batch of records
+func (s *RecordSchema) Batch(records []*core.Vector) ([]byte, []byte) {
+	n := len(records)
+	ids := make([]string, n)
+	namespaces := make([]string, n)
+	vectors := make([][]float32, n)
+	for i, record := range records {
+		ids[i], namespaces[i], vectors[i] = record.ID, record.Namespace, record.Vector
+	}
+
+	metadata := &array{length: n}
+	for _, m := range s.metadata {
+		valid := make([]bool, n)
+		var child *array
+		switch m.kind {
+		case kindBool:
+			values := make([]bool, n)
+			for i, record := range records {
+				values[i], valid[i] = record.Metadata[m.key].(bool)
+			}
+			child = boolArray(values, valid)
+		case kindInt:
+			values := make([]int64, n)
+			for i, record := range records {
+				f, ok := number(record.Metadata[m.key])
+				values[i], valid[i] = int64(f), ok
+			}
+			child = int64Array(values, valid)
+		case kindFloat:
+			values := make([]float64, n)
+			for i, record := range records {
+				values[i], valid[i] = number(record.Metadata[m.key])
+			}
+			child = float64Array(values, valid)
+		case kindString:
+			values := make([]string, n)
+			for i, record := range records {
+				values[i], valid[i] = record.Metadata[m.key].(string)
+			}
+			child = utf8Array(values, valid)
+		default:
+			values := make([]string, n)
+			for i, record := range records {
+				if value := record.Metadata[m.key]; value != nil {
+					if data, err := json.Marshal(value); err == nil {
+						values[i], valid[i] = string(data), true
+					}
+				}
+			}
+			child = utf8Array(values, valid)
+		}
+		metadata.children = append(metadata.children, child)
+	}
+	metadata.buffers = [][]byte{nil}
+
+	return recordBatch(n, []*array{
+		utf8Array(ids, nil),
+		utf8Array(namespaces, nil),
+		vectorArray(vectors, s.dimensions),
+		metadata,
+	})
+}
+
+// Decoder turns the IPC messages of an uploaded stream into records. It
+// takes the id column as record IDs, the vector column (a list of floats)
+// as vectors and the namespace column, if any, as namespaces. The metadata
+// column, a struct or JSON text, and any other column make up the metadata.
+type Decoder struct {
+	fields []*field
+}
+
+// Decode decodes an IPC message with its body. The schema message, which
+// comes first, yields no records.
+func (d *Decoder) Decode(header, body []byte) (records []*core.Vector, err error) {
+	defer recoverMalformed(&err)
+	if len(header) < 4 {
+		return nil, Errorf(CodeInvalidArgument, "missing IPC message")
+	}
+	msg := rootTable(header)
+	if version := msg.int16Field(0, 0); version < metadataV4 {
+		return nil, Errorf(CodeUnimplemented, "IPC metadata version %d is not supported", version)
+	}
+
+	switch msg.byteField(1, 0) {
+	case headerSchema:
+		schema, ok := msg.table(2)
+		if !ok {
+			return nil, Errorf(CodeInvalidArgument, "schema message without a schema")
+		}
+		return nil, d.readSchema(schema)
+	case headerRecordBatch:
+		batch, ok := msg.table(2)
+		if !ok {
+			return nil, Errorf(CodeInvalidArgument, "record batch message without a record batch")
+		}
+		if d.fields == nil {
+			return nil, Errorf(CodeInvalidArgument, "record batch before the schema")
+		}
+		return d.readBatch(batch, body)
+	case headerDictionaryBatch:
+		return nil, Errorf(CodeUnimplemented, "dictionary batches are not supported")
+	default:
+		return nil, Errorf(CodeInvalidArgument, "unexpected IPC message type %d", msg.byteField(1, 0))
+	}
+}
+
+// readSchema reads the schema of the stream and checks its columns
+func (d *Decoder) readSchema(schema fbTable) error {
+	if schema.int16Field(0, 0) != 0 {
+		return Errorf(CodeUnimplemented, "big-endian data is not supported")
+	}
+	var fields []*field
+	hasID := false
+	for _, t := range schema.tables(1) {
+		f, err := readField(t)
+		if err != nil {
+			return err
+		}
+		switch f.name {
+		case ColumnID, ColumnNamespace:
+			if f.typ != typeUtf8 && f.typ != typeLargeUtf8 {
+				return Errorf(CodeInvalidArgument, "column '%s' must be a string column", f.name)
+			}
+			hasID = hasID || f.name == ColumnID
+		case ColumnVector:
+			if !isFloatList(f) {
+				return Errorf(CodeInvalidArgument, "column '%s' must be a list of floats", f.name)
+			}
+		}
+		fields = append(fields, f)
+	}
+	if !hasID {
+		return Errorf(CodeInvalidArgument, "schema has no '%s' column", ColumnID)
+	}
+	d.fields = fields
+	return nil
+}
+
+// isFloatList reports whether f is a list of float32 or float64
+func isFloatList(f *field) bool {
+	switch f.typ {
+	case typeFixedSizeList, typeList, typeLargeList:
+		return f.children[0].typ == typeFloat
+	}
+	return false
+}
+
+// readBatch converts a record batch into records
+func (d *Decoder) readBatch(batch fbTable, body []byte) ([]*core.Vector, error) {
+	if _, compressed := batch.table(3); compressed {
+		return nil, Errorf(CodeUnimplemented, "compressed record batches are not supported")
+	}
+	length := batch.int64Field(0, 0)
+	if length < 0 || length > MaxMessageSize {
+		return nil, Errorf(CodeInvalidArgument, "invalid record batch length %d", length)
+	}
+	reader := &batchReader{nodes: batch.int64Pairs(1), buffers: batch.int64Pairs(2), body: body}
+	columns := make([]*column, len(d.fields))
+	for i, f := range d.fields {
+		c, err := reader.read(f)
+		if err != nil {
+			return nil, err
+		}
+		if c.length < int(length) {
+			return nil, Errorf(CodeInvalidArgument, "column '%s' has %d rows, expected %d", f.name, c.length, length)
+		}
+		columns[i] = c
+	}
+
+	records := make([]*core.Vector, length)
+	for i := range records {
+		record := &core.Vector{Metadata: make(map[string]interface{})}
+		for _, c := range columns {
+			switch c.field.name {
+			case ColumnID:
+				if !c.valid(i) {
+					return nil, Errorf(CodeInvalidArgument, "row %d has no id", i)
+				}
+				record.ID = c.str(i)
+			case ColumnNamespace:
+				if c.valid(i) {
+					record.Namespace = c.str(i)
+				}
+			case ColumnVector:
+				vector, err := c.vector(i)
+				if err != nil {
+					return nil, err
+				}
+				record.Vector = vector
+			case ColumnMetadata:
+				if err := c.mergeMetadata(i, record.Metadata); err != nil {
+					return nil, err
+				}
+			default:
+				if v := c.value(i); v != nil {
+					record.Metadata[c.field.name] = v
+				}
+			}
+		}
+		records[i] = record
+	}
+	return records, nil
+}
+
+// vector returns row i of a vector column, nil when null
+func (c *column) vector(i int) ([]float32, error) {
+	if !c.valid(i) {
+		return nil, nil
+	}
+	values := c.children[0]
+	start, end := c.span(i)
+	vector := make([]float32, 0, end-start)
+	for j := start; j < end; j++ {
+		if !values.valid(j) {
+			return nil, Errorf(CodeInvalidArgument, "vector of row %d has a null value", i)
+		}
+		vector = append(vector, float32(values.float(j)))
+	}
+	return vector, nil
+}
+
+// mergeMetadata adds the metadata of row i of the metadata column, a struct
+// or JSON text, to metadata
+func (c *column) mergeMetadata(i int, metadata map[string]interface{}) error {
+	switch c.field.typ {
+	case typeStruct:
+		if fields, ok := c.value(i).(map[string]interface{}); ok {
+			for key, value := range fields {
+				metadata[key] = value
+			}
+		}
+	case typeUtf8, typeLargeUtf8:
+		if !c.valid(i) || c.str(i) == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(c.str(i)), &metadata); err != nil {
+			return Errorf(CodeInvalidArgument, "metadata of row %d is not a JSON object: %v", i, err)
+		}
+	default:
+		return Errorf(CodeInvalidArgument, "column '%s' must be a struct or JSON text", ColumnMetadata)
+	}
+	return nil
+}
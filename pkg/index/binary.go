@@ -0,0 +1,92 @@
+package index
+
+import "math/bits"
+
+// Binary vectors hold 0 and 1 components. Packed, they take one bit per
+// component in 64-bit words, and Hamming and Jaccard distances become a few
+// popcounts per word.
+
+// PackBits packs a binary vector into 64-bit words, setting the bit of every
+// non-zero component
+func PackBits(vector []float32) []uint64 {
+	packed := make([]uint64, (len(vector)+63)/64)
+	for i, value := range vector {
+		if value != 0 {
+			packed[i/64] |= 1 << (i % 64)
+		}
+	}
+	return packed
+}
+
+// HammingBits returns the number of bits that differ between packed vectors
+func HammingBits(a, b []uint64) int {
+	distance := 0
+	for i := range a {
+		distance += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return distance
+}
+
+// JaccardBits returns the Jaccard distance between packed vectors: one minus
+// the share of their set bits that both have set. Two vectors without set
+// bits are at distance 0.
+func JaccardBits(a, b []uint64) float32 {
+	intersection, union := 0, 0
+	for i := range a {
+		intersection += bits.OnesCount64(a[i] & b[i])
+		union += bits.OnesCount64(a[i] | b[i])
+	}
+	if union == 0 {
+		return 0
+	}
+	return 1 - float32(intersection)/float32(union)
+}
+
+// HammingDistanceCalculator implements Hamming distance on binary vectors
+type HammingDistanceCalculator struct{}
+
+func (h *HammingDistanceCalculator) Calculate(a, b []float32) float32 {
+	distance := 0
+	for i := range a {
+		if (a[i] != 0) != (b[i] != 0) {
+			distance++
+		}
+	}
+	return float32(distance)
+}
+
+func (h *HammingDistanceCalculator) Name() string {
+	return "hamming"
+}
+
+func (h *HammingDistanceCalculator) IsSymmetric() bool {
+	return true
+}
+
+// JaccardDistanceCalculator implements Jaccard distance on binary vectors
+type JaccardDistanceCalculator struct{}
+
+func (j *JaccardDistanceCalculator) Calculate(a, b []float32) float32 {
+	intersection, union := 0, 0
+	for i := range a {
+		x, y := a[i] != 0, b[i] != 0
+		if x && y {
+			intersection++
+		}
+		if x || y {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return 1 - float32(intersection)/float32(union)
+}
+
+func (j *JaccardDistanceCalculator) Name() string {
+	return "jaccard"
+}
+
+func (j *JaccardDistanceCalculator) IsSymmetric() bool {
+	return true
+}
@@ -75,6 +75,10 @@ func NewDistanceCalculator(metric DistanceMetric) DistanceCalculator {
 		return &DotProductDistanceCalculator{}
 	case DistanceMetricManhattan:
 		return &ManhattanDistanceCalculator{}
+	case DistanceMetricHamming:
+		return &HammingDistanceCalculator{}
+	case DistanceMetricJaccard:
+		return &JaccardDistanceCalculator{}
 	default:
 		return &CosineDistanceCalculator{} // Default to cosine
 	}
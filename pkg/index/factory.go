@@ -38,6 +38,19 @@ func CreateIndex(indexType IndexType, dimensions int, metric DistanceMetric, con
 			if seed, ok := config["seed"].(int64); ok {
 				hnswConfig.Seed = seed
 			}
+			if buildThreads, ok := config["build_threads"].(int); ok {
+				hnswConfig.BuildThreads = buildThreads
+			}
+			if selection, ok := config["neighbor_selection"].(string); ok {
+				parsed, err := ParseNeighborSelection(selection)
+				if err != nil {
+					return nil, err
+				}
+				hnswConfig.NeighborSelection = parsed
+			}
+			if keepPruned, ok := config["keep_pruned_connections"].(bool); ok {
+				hnswConfig.KeepPrunedConnections = keepPruned
+			}
 		}
 		return NewHNSWIndex(dimensions, metric, hnswConfig), nil
 
@@ -74,11 +87,28 @@ func ParseDistanceMetric(s string) (DistanceMetric, error) {
 		return DistanceMetricDotProduct, nil
 	case "manhattan":
 		return DistanceMetricManhattan, nil
+	case "hamming":
+		return DistanceMetricHamming, nil
+	case "jaccard":
+		return DistanceMetricJaccard, nil
 	default:
 		return DistanceMetricCosine, fmt.Errorf("unknown distance metric: %s", s)
 	}
 }
 
+// ParseNeighborSelection validates a neighbor selection strategy name ("" is
+// the default heuristic)
+func ParseNeighborSelection(s string) (string, error) {
+	switch s {
+	case "", NeighborSelectionHeuristic:
+		return NeighborSelectionHeuristic, nil
+	case NeighborSelectionSimple:
+		return NeighborSelectionSimple, nil
+	default:
+		return "", fmt.Errorf("unknown neighbor selection '%s': expected heuristic or simple", s)
+	}
+}
+
 // RecommendedConfig returns recommended configuration for different use cases
 func RecommendedConfig(useCase string, dimensions int, expectedSize int) map[string]interface{} {
 	config := make(map[string]interface{})
@@ -153,7 +183,7 @@ func EstimateMemoryUsage(indexType IndexType, dimensions int, vectorCount int, c
 
 		// Estimate average connections per vector
 		avgConnections := float64(m) * 1.5                                   // Rough estimate
-		connectionMemory := int64(float64(vectorCount) * avgConnections * 8) // 8 bytes per connection
+		connectionMemory := int64(float64(vectorCount) * avgConnections * 4) // 4 bytes per connection
 
 		return vectorMemory + connectionMemory + int64(vectorCount)*128 // 128 bytes overhead per node
 
@@ -10,9 +10,11 @@ import (
 	"time"
 )
 
-// FlatIndex implements a brute-force flat index
+// FlatIndex implements a brute-force flat index. Deleted vectors are marked
+// in a bitmap and skipped by scans until the index compacts them.
 type FlatIndex struct {
 	vectors    []*IndexVector
+	deleted    *roaringBitmap // Positions in vectors of the deleted entries
 	dimensions int
 	metric     DistanceMetric
 	calculator DistanceCalculator
@@ -29,6 +31,7 @@ func NewFlatIndex(dimensions int, metric DistanceMetric, config *FlatConfig) *Fl
 
 	return &FlatIndex{
 		vectors:    make([]*IndexVector, 0),
+		deleted:    newRoaringBitmap(),
 		dimensions: dimensions,
 		metric:     metric,
 		calculator: NewDistanceCalculator(metric),
@@ -65,6 +68,7 @@ func (idx *FlatIndex) Build(vectors []*IndexVector) error {
 		}
 		copy(idx.vectors[i].Vector, vector.Vector)
 	}
+	idx.deleted = newRoaringBitmap()
 
 	// Update stats
 	idx.stats.VectorCount = len(idx.vectors)
@@ -102,15 +106,21 @@ func (idx *FlatIndex) Load(r io.Reader) error {
 	}
 
 	idx.vectors = data.Vectors
+	idx.deleted = newRoaringBitmap()
 	idx.stats = data.Stats
+	if idx.stats == nil {
+		idx.stats = &IndexStats{IndexType: IndexTypeFlat, Dimensions: idx.dimensions}
+	}
 
 	return nil
 }
 
-// Save saves the index to a writer
+// Save saves the index to a writer, compacting deleted entries first
 func (idx *FlatIndex) Save(w io.Writer) error {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.compact()
 
 	encoder := json.NewEncoder(w)
 
@@ -141,10 +151,8 @@ func (idx *FlatIndex) Add(ctx context.Context, vector *IndexVector) error {
 	}
 
 	// Check for duplicate ID
-	for _, existing := range idx.vectors {
-		if existing.ID == vector.ID {
-			return fmt.Errorf("vector with ID %s already exists", vector.ID)
-		}
+	if _, exists := idx.position(vector.ID); exists {
+		return fmt.Errorf("vector with ID %s already exists", vector.ID)
 	}
 
 	// Add vector
@@ -155,28 +163,96 @@ func (idx *FlatIndex) Add(ctx context.Context, vector *IndexVector) error {
 	copy(newVector.Vector, vector.Vector)
 
 	idx.vectors = append(idx.vectors, newVector)
-	idx.stats.VectorCount = len(idx.vectors)
+	idx.stats.VectorCount = len(idx.vectors) - idx.deleted.Len()
 
 	return nil
 }
 
-// Delete removes a vector from the index
+// Delete removes a vector from the index. The entry is only marked deleted,
+// and dropped once deleted entries pass tombstoneCompactFraction of the
+// index, or on Optimize or Save.
 func (idx *FlatIndex) Delete(ctx context.Context, id string) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	// Find and remove vector
+	position, exists := idx.position(id)
+	if !exists {
+		return fmt.Errorf("vector with ID %s not found", id)
+	}
+
+	idx.deleted.Add(uint32(position))
+	if float64(idx.deleted.Len()) > tombstoneCompactFraction*float64(len(idx.vectors)) {
+		idx.compact()
+	}
+	idx.stats.VectorCount = len(idx.vectors) - idx.deleted.Len()
+	return nil
+}
+
+// DeleteBatch removes many vectors in a single pass over the index and
+// returns how many were in it; unknown IDs are skipped
+func (idx *FlatIndex) DeleteBatch(ctx context.Context, ids []string) (int, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	removed := 0
+	for i, vector := range idx.vectors {
+		if remove[vector.ID] && !idx.deleted.Contains(uint32(i)) {
+			idx.deleted.Add(uint32(i))
+			removed++
+		}
+	}
+
+	if float64(idx.deleted.Len()) > tombstoneCompactFraction*float64(len(idx.vectors)) {
+		idx.compact()
+	}
+	idx.stats.VectorCount = len(idx.vectors) - idx.deleted.Len()
+	return removed, nil
+}
+
+// position returns where the live vector with the given ID is stored; the
+// caller holds mu
+func (idx *FlatIndex) position(id string) (int, bool) {
+	for i, vector := range idx.vectors {
+		if vector.ID == id && !idx.deleted.Contains(uint32(i)) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// compact drops the deleted entries; the caller holds mu exclusively
+func (idx *FlatIndex) compact() {
+	if idx.deleted.Len() == 0 {
+		return
+	}
+
+	live := make([]*IndexVector, 0, len(idx.vectors)-idx.deleted.Len())
 	for i, vector := range idx.vectors {
-		if vector.ID == id {
-			// Remove by swapping with last element
-			idx.vectors[i] = idx.vectors[len(idx.vectors)-1]
-			idx.vectors = idx.vectors[:len(idx.vectors)-1]
-			idx.stats.VectorCount = len(idx.vectors)
-			return nil
+		if !idx.deleted.Contains(uint32(i)) {
+			live = append(live, vector)
 		}
 	}
+	idx.vectors = live
+	idx.deleted.Clear()
+}
+
+// Reserve grows the vector slice capacity to hold capacity vectors
+func (idx *FlatIndex) Reserve(capacity int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if capacity <= cap(idx.vectors) {
+		return
+	}
 
-	return fmt.Errorf("vector with ID %s not found", id)
+	vectors := make([]*IndexVector, len(idx.vectors), capacity)
+	copy(vectors, idx.vectors)
+	idx.vectors = vectors
 }
 
 // Search performs k-nearest neighbor search
@@ -196,14 +272,24 @@ func (idx *FlatIndex) Search(ctx context.Context, query []float32, k int, params
 		return nil, fmt.Errorf("k must be positive")
 	}
 
-	if k > len(idx.vectors) {
-		k = len(idx.vectors)
+	var allowed map[string]bool
+	if params != nil && params.Allowed != nil {
+		allowed = make(map[string]bool, len(params.Allowed))
+		for _, id := range params.Allowed {
+			allowed[id] = true
+		}
 	}
 
-	// Calculate distances for all vectors
-	candidates := make([]*Candidate, 0, len(idx.vectors))
+	// Calculate distances for all live vectors
+	candidates := make([]*Candidate, 0, len(idx.vectors)-idx.deleted.Len())
 
-	for _, vector := range idx.vectors {
+	for i, vector := range idx.vectors {
+		if idx.deleted.Len() > 0 && idx.deleted.Contains(uint32(i)) {
+			continue
+		}
+		if allowed != nil && !allowed[vector.ID] {
+			continue
+		}
 		distance := idx.calculator.Calculate(query, vector.Vector)
 		candidates = append(candidates, &Candidate{
 			ID:    vector.ID,
@@ -213,7 +299,7 @@ func (idx *FlatIndex) Search(ctx context.Context, query []float32, k int, params
 
 	// Sort by distance (ascending for distance, descending for similarity)
 	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].Score < candidates[j].Score
+		return candidateLess(candidates[i], candidates[j])
 	})
 
 	// Return top-k results
@@ -235,7 +321,7 @@ func (idx *FlatIndex) Search(ctx context.Context, query []float32, k int, params
 func (idx *FlatIndex) Size() int {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
-	return len(idx.vectors)
+	return len(idx.vectors) - idx.deleted.Len()
 }
 
 // Dimensions returns the vector dimensions
@@ -248,9 +334,13 @@ func (idx *FlatIndex) Type() IndexType {
 	return IndexTypeFlat
 }
 
-// Optimize optimizes the index (no-op for flat index)
+// Optimize compacts the deleted entries
 func (idx *FlatIndex) Optimize() error {
-	// Flat index doesn't need optimization
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.compact()
+	idx.stats.VectorCount = len(idx.vectors)
 	return nil
 }
 
@@ -267,8 +357,10 @@ func (idx *FlatIndex) Stats() *IndexStats {
 	}
 
 	stats := *idx.stats
-	stats.MemoryUsage = vectorMemory + idMemory
-	stats.VectorCount = len(idx.vectors)
+	stats.MemoryUsage = vectorMemory + idMemory + idx.deleted.MemoryUsage()
+	stats.VectorMemory = vectorMemory
+	stats.VectorCount = len(idx.vectors) - idx.deleted.Len()
+	stats.DeletedCount = idx.deleted.Len()
 
 	return &stats
 }
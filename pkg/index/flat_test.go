@@ -0,0 +1,44 @@
+package index
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFlatReserve(t *testing.T) {
+	ctx := context.Background()
+	vectors, _ := clusteredVectors(100, 0, 4, 3)
+	idx := NewFlatIndex(4, DistanceMetricEuclidean, nil)
+	for _, vector := range vectors[:10] {
+		if err := idx.Add(ctx, vector); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// Reserving keeps the vectors and makes room for the rest
+	idx.Reserve(len(vectors))
+	if cap(idx.vectors) < len(vectors) || len(idx.vectors) != 10 {
+		t.Fatalf("got %d vectors with capacity %d, want 10 with capacity %d", len(idx.vectors), cap(idx.vectors), len(vectors))
+	}
+	backing := &idx.vectors[0]
+	for _, vector := range vectors[10:] {
+		if err := idx.Add(ctx, vector); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if &idx.vectors[0] != backing {
+		t.Error("adding the reserved vectors grew the slice again")
+	}
+
+	// A smaller reservation changes nothing
+	idx.Reserve(10)
+	if cap(idx.vectors) < len(vectors) || &idx.vectors[0] != backing {
+		t.Error("a smaller reservation reallocated the vectors")
+	}
+	for _, vector := range []*IndexVector{vectors[0], vectors[50], vectors[99]} {
+		results, err := idx.Search(ctx, vector.Vector, 1, nil)
+		if err != nil || len(results) != 1 || results[0].ID != vector.ID {
+			t.Errorf("search for %s found %v (%v)", vector.ID, results, err)
+		}
+	}
+}
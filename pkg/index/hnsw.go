@@ -7,23 +7,66 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// HNSWIndexImpl implements the HNSW (Hierarchical Navigable Small World) algorithm
+// parallelBuildThreshold is the smallest Build that is split across workers;
+// below it the coordination overhead outweighs the speedup
+const parallelBuildThreshold = 1000
+
+// hnswFormatVersion is the version of the serialized graph written by Save.
+// Version 2 stores neighbors as positions in the node list instead of IDs.
+const hnswFormatVersion = 2
+
+// tombstoneCompactFraction is the share of deleted entries above which an
+// index drops them for good instead of skipping them on every search
+const tombstoneCompactFraction = 0.2
+
+// HNSWIndexImpl implements the HNSW (Hierarchical Navigable Small World) algorithm.
+// Nodes are numbered with internal uint32 IDs, their position in nodes, and
+// adjacency lists hold those numbers instead of the vectors' string IDs.
+// Deleted nodes stay in the graph as tombstones until the next compaction.
 type HNSWIndexImpl struct {
-	nodes      map[string]*HNSWNode
+	nodes      []*HNSWNode       // Indexed by internal ID; nil once compacted away
+	ids        map[string]uint32 // Vector ID to internal ID of the live nodes
+	deleted    *roaringBitmap    // Internal IDs of the tombstones
 	entryPoint *HNSWNode
 	dimensions int
 	metric     DistanceMetric
 	calculator DistanceCalculator
 	config     *HNSWConfig
-	mu         sync.RWMutex
 	rng        *rand.Rand
 	stats      *IndexStats
 	maxLayer   int
+
+	// Searches, inserts and deletes hold mu shared and run concurrently;
+	// operations that restructure the graph (Build, Load, Save, compaction,
+	// Repair) hold it exclusively. Under the shared lock nodesMu guards nodes,
+	// ids and deleted, entryMu
+	// guards entryPoint and maxLayer, and each node's lock guards its
+	// connections, which are replaced rather than modified in place so that a
+	// search can keep reading a list after releasing the lock.
+	mu      sync.RWMutex
+	nodesMu sync.RWMutex
+	entryMu sync.Mutex
+	rngMu   sync.Mutex
+	statsMu sync.Mutex
+}
+
+// hnswFile is the serialized form of the graph
+type hnswFile struct {
+	Version    int            `json:"version"`
+	Nodes      []*HNSWNode    `json:"nodes"` // Connections refer to positions in this list
+	EntryPoint string         `json:"entry_point"`
+	Dimensions int            `json:"dimensions"`
+	Metric     DistanceMetric `json:"metric"`
+	Config     *HNSWConfig    `json:"config"`
+	MaxLayer   int            `json:"max_layer"`
+	Stats      *IndexStats    `json:"stats"`
 }
 
 // NewHNSWIndex creates a new HNSW index
@@ -33,7 +76,8 @@ func NewHNSWIndex(dimensions int, metric DistanceMetric, config *HNSWConfig) HNS
 	}
 
 	return &HNSWIndexImpl{
-		nodes:      make(map[string]*HNSWNode),
+		ids:        make(map[string]uint32),
+		deleted:    newRoaringBitmap(),
 		dimensions: dimensions,
 		metric:     metric,
 		calculator: NewDistanceCalculator(metric),
@@ -52,27 +96,66 @@ func (idx *HNSWIndexImpl) Build(vectors []*IndexVector) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	startTime := time.Now()
+	return idx.build(context.Background(), vectors, nil)
+}
 
-	// Clear existing index
-	idx.nodes = make(map[string]*HNSWNode)
-	idx.entryPoint = nil
-	idx.maxLayer = 0
+// BuildTracked is Build counting the nodes linked so far in linked, which
+// another goroutine may read while the build runs. It stops early with the
+// context's error when ctx is done, leaving the graph partly built.
+func (idx *HNSWIndexImpl) BuildTracked(ctx context.Context, vectors []*IndexVector, linked *atomic.Int64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
 
-	// Add vectors one by one
+	return idx.build(ctx, vectors, linked)
+}
+
+// build replaces the graph with one built from vectors, counting linked
+// nodes in linked unless it is nil; the caller holds mu
+func (idx *HNSWIndexImpl) build(ctx context.Context, vectors []*IndexVector, linked *atomic.Int64) error {
+	startTime := time.Now()
+
+	ids := make(map[string]uint32, len(vectors))
 	for i, vector := range vectors {
 		if len(vector.Vector) != idx.dimensions {
 			return fmt.Errorf("vector %d has wrong dimensions: expected %d, got %d",
 				i, idx.dimensions, len(vector.Vector))
 		}
+		if _, exists := ids[vector.ID]; exists {
+			return fmt.Errorf("vector %d has duplicate ID %s", i, vector.ID)
+		}
+		ids[vector.ID] = uint32(i)
+	}
+
+	// Clear existing index and create every node up front, with layers drawn
+	// in input order
+	idx.nodes = make([]*HNSWNode, len(vectors))
+	idx.ids = ids
+	idx.deleted = newRoaringBitmap()
+	idx.entryPoint = nil
+	idx.maxLayer = 0
+	for i, vector := range vectors {
+		idx.nodes[i] = idx.newNode(vector, uint32(i), idx.randomLevel())
+	}
 
-		if err := idx.addVector(vector); err != nil {
-			return fmt.Errorf("failed to add vector %d: %w", i, err)
+	if workers := idx.buildWorkers(len(vectors)); workers > 1 {
+		if err := idx.buildParallel(ctx, workers, linked); err != nil {
+			return err
+		}
+	} else {
+		// Link nodes one by one
+		for _, node := range idx.nodes {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			idx.insert(node)
+			if linked != nil {
+				linked.Add(1)
+			}
 		}
 	}
 
 	// Update stats
-	idx.stats.VectorCount = len(idx.nodes)
+	idx.stats.VectorCount = len(idx.ids)
 	idx.stats.BuildTime = time.Since(startTime).Milliseconds()
 	idx.stats.MaxLayer = idx.maxLayer
 	idx.stats.AvgDegree = idx.calculateAverageDegree()
@@ -87,21 +170,15 @@ func (idx *HNSWIndexImpl) Load(r io.Reader) error {
 
 	decoder := json.NewDecoder(r)
 
-	var data struct {
-		Nodes      map[string]*HNSWNode `json:"nodes"`
-		EntryPoint string               `json:"entry_point"`
-		Dimensions int                  `json:"dimensions"`
-		Metric     DistanceMetric       `json:"metric"`
-		Config     *HNSWConfig          `json:"config"`
-		MaxLayer   int                  `json:"max_layer"`
-		Stats      *IndexStats          `json:"stats"`
-	}
-
+	var data hnswFile
 	if err := decoder.Decode(&data); err != nil {
 		return fmt.Errorf("failed to decode HNSW index: %w", err)
 	}
 
 	// Validate
+	if data.Version != hnswFormatVersion {
+		return fmt.Errorf("unsupported HNSW index format version %d", data.Version)
+	}
 	if data.Dimensions != idx.dimensions {
 		return fmt.Errorf("dimension mismatch: expected %d, got %d",
 			idx.dimensions, data.Dimensions)
@@ -111,24 +188,43 @@ func (idx *HNSWIndexImpl) Load(r io.Reader) error {
 			idx.metric.String(), data.Metric.String())
 	}
 
+	ids := make(map[string]uint32, len(data.Nodes))
+	for i, node := range data.Nodes {
+		if node == nil {
+			continue
+		}
+		if _, exists := ids[node.ID]; exists {
+			return fmt.Errorf("HNSW index has duplicate node %s", node.ID)
+		}
+		node.internalID = uint32(i)
+		ids[node.ID] = uint32(i)
+	}
+
 	idx.nodes = data.Nodes
+	idx.ids = ids
+	idx.deleted = newRoaringBitmap()
 	idx.maxLayer = data.MaxLayer
 	idx.stats = data.Stats
+	if idx.stats == nil {
+		idx.stats = &IndexStats{IndexType: IndexTypeHNSW, Dimensions: idx.dimensions}
+	}
 
 	// Set entry point
-	if data.EntryPoint != "" {
-		if node, exists := idx.nodes[data.EntryPoint]; exists {
-			idx.entryPoint = node
-		}
+	idx.entryPoint = nil
+	if internalID, exists := ids[data.EntryPoint]; exists {
+		idx.entryPoint = idx.nodes[internalID]
 	}
 
 	return nil
 }
 
-// Save saves the index to a writer
+// Save saves the index to a writer. Tombstones are compacted first, so the
+// saved graph is renumbered without gaps.
 func (idx *HNSWIndexImpl) Save(w io.Writer) error {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.purgeDeleted()
 
 	encoder := json.NewEncoder(w)
 
@@ -137,16 +233,9 @@ func (idx *HNSWIndexImpl) Save(w io.Writer) error {
 		entryPointID = idx.entryPoint.ID
 	}
 
-	data := struct {
-		Nodes      map[string]*HNSWNode `json:"nodes"`
-		EntryPoint string               `json:"entry_point"`
-		Dimensions int                  `json:"dimensions"`
-		Metric     DistanceMetric       `json:"metric"`
-		Config     *HNSWConfig          `json:"config"`
-		MaxLayer   int                  `json:"max_layer"`
-		Stats      *IndexStats          `json:"stats"`
-	}{
-		Nodes:      idx.nodes,
+	data := hnswFile{
+		Version:    hnswFormatVersion,
+		Nodes:      idx.compactNodes(),
 		EntryPoint: entryPointID,
 		Dimensions: idx.dimensions,
 		Metric:     idx.metric,
@@ -158,10 +247,11 @@ func (idx *HNSWIndexImpl) Save(w io.Writer) error {
 	return encoder.Encode(data)
 }
 
-// Add adds a vector to the index
+// Add adds a vector to the index. It runs concurrently with searches and
+// other inserts.
 func (idx *HNSWIndexImpl) Add(ctx context.Context, vector *IndexVector) error {
-	idx.mu.Lock()
-	defer idx.mu.Unlock()
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
 
 	// Validate vector
 	if len(vector.Vector) != idx.dimensions {
@@ -169,45 +259,83 @@ func (idx *HNSWIndexImpl) Add(ctx context.Context, vector *IndexVector) error {
 			idx.dimensions, len(vector.Vector))
 	}
 
-	// Check for duplicate ID
-	if _, exists := idx.nodes[vector.ID]; exists {
+	level := idx.randomLevel()
+
+	// Register the node before linking it so that pruning a neighbor's
+	// connections can see it and keep the back-link when it is close enough
+	idx.nodesMu.Lock()
+	if _, exists := idx.ids[vector.ID]; exists {
+		idx.nodesMu.Unlock()
 		return fmt.Errorf("vector with ID %s already exists", vector.ID)
 	}
+	node := idx.newNode(vector, uint32(len(idx.nodes)), level)
+	idx.nodes = append(idx.nodes, node)
+	idx.ids[vector.ID] = node.internalID
+	idx.nodesMu.Unlock()
 
-	return idx.addVector(vector)
+	idx.insert(node)
+	return nil
 }
 
-// Delete removes a vector from the index
+// Delete removes a vector from the index. The node only becomes a tombstone:
+// searches keep routing through it but never return it, and it is unlinked
+// from the graph once tombstones pass tombstoneCompactFraction of the nodes,
+// or on Optimize or Save. Its internal ID is not reused until the graph is
+// rebuilt or reloaded.
 func (idx *HNSWIndexImpl) Delete(ctx context.Context, id string) error {
-	idx.mu.Lock()
-	defer idx.mu.Unlock()
+	idx.mu.RLock()
+	idx.nodesMu.Lock()
+	internalID, exists := idx.ids[id]
+	if exists {
+		delete(idx.ids, id)
+		idx.deleted.Add(internalID)
+	}
+	compact := idx.needsCompaction()
+	idx.nodesMu.Unlock()
+	idx.mu.RUnlock()
 
-	node, exists := idx.nodes[id]
 	if !exists {
 		return fmt.Errorf("vector with ID %s not found", id)
 	}
 
-	// Remove connections to this node from other nodes
-	for layer := 0; layer <= node.Layer; layer++ {
-		if connections, hasLayer := node.Connections[layer]; hasLayer {
-			for _, connID := range connections {
-				if connNode, exists := idx.nodes[connID]; exists {
-					idx.removeConnection(connNode, id, layer)
-				}
-			}
+	if compact {
+		idx.mu.Lock()
+		// Another delete may have compacted in the meantime
+		if idx.needsCompaction() {
+			idx.purgeDeleted()
 		}
+		idx.mu.Unlock()
 	}
+	return nil
+}
 
-	// Remove the node
-	delete(idx.nodes, id)
-
-	// Update entry point if necessary
-	if idx.entryPoint != nil && idx.entryPoint.ID == id {
-		idx.findNewEntryPoint()
+// DeleteBatch removes many vectors at once and returns how many were in the
+// index; unknown IDs are skipped. All the tombstones are placed before the
+// graph is repaired, so a large delete purges them once rather than each
+// time the deletes cross the compaction threshold.
+func (idx *HNSWIndexImpl) DeleteBatch(ctx context.Context, ids []string) (int, error) {
+	idx.mu.RLock()
+	idx.nodesMu.Lock()
+	removed := 0
+	for _, id := range ids {
+		if internalID, exists := idx.ids[id]; exists {
+			delete(idx.ids, id)
+			idx.deleted.Add(internalID)
+			removed++
+		}
 	}
-
-	idx.stats.VectorCount = len(idx.nodes)
-	return nil
+	compact := idx.needsCompaction()
+	idx.nodesMu.Unlock()
+	idx.mu.RUnlock()
+
+	if compact {
+		idx.mu.Lock()
+		if idx.needsCompaction() {
+			idx.purgeDeleted()
+		}
+		idx.mu.Unlock()
+	}
+	return removed, nil
 }
 
 // Search performs k-nearest neighbor search using HNSW algorithm
@@ -227,34 +355,61 @@ func (idx *HNSWIndexImpl) Search(ctx context.Context, query []float32, k int, pa
 		return nil, fmt.Errorf("k must be positive")
 	}
 
-	if len(idx.nodes) == 0 {
-		return []*Candidate{}, nil
-	}
-
 	// Get search parameters
 	ef := idx.config.EfSearch
 	if params != nil && params.EF > 0 {
 		ef = params.EF
 	}
+	if ef < k {
+		ef = k
+	}
+
+	// Pre-filtering: the allowed IDs are looked up once into a bitmap of
+	// internal IDs, which the bottom layer checks as it visits nodes
+	var allowed *roaringBitmap
+	if params != nil && params.Allowed != nil {
+		allowed = newRoaringBitmap()
+		idx.nodesMu.RLock()
+		for _, id := range params.Allowed {
+			if internalID, ok := idx.ids[id]; ok {
+				allowed.Add(internalID)
+			}
+		}
+		idx.nodesMu.RUnlock()
+		if allowed.Len() == 0 {
+			return []*Candidate{}, nil
+		}
+	}
 
 	// Start from entry point
-	if idx.entryPoint == nil {
+	idx.entryMu.Lock()
+	entry, maxLayer := idx.entryPoint, idx.maxLayer
+	idx.entryMu.Unlock()
+	if entry == nil {
 		return []*Candidate{}, nil
 	}
 
 	// Search from top layer down to layer 1
 	entryPoints := []*QueueItem{{
-		ID:       idx.entryPoint.ID,
-		Distance: idx.calculator.Calculate(query, idx.entryPoint.Vector),
-		Vector:   idx.entryPoint.Vector,
+		Node:     entry,
+		Distance: idx.calculator.Calculate(query, entry.Vector),
 	}}
 
-	for layer := idx.maxLayer; layer >= 1; layer-- {
-		entryPoints = idx.searchLayer(query, entryPoints, 1, layer)
+	for layer := maxLayer; layer >= 1; layer-- {
+		if closest := idx.searchLayer(query, entryPoints, 1, layer, nil); len(closest) > 0 {
+			entryPoints = closest
+		}
 	}
 
-	// Search layer 0 with ef
-	candidates := idx.searchLayer(query, entryPoints, ef, 0)
+	// Search layer 0 with ef; the beam is ordered by distance only, so equal
+	// distances are put in ID order before the top k are cut
+	candidates := idx.searchLayer(query, entryPoints, ef, 0, allowed)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Distance != candidates[j].Distance {
+			return candidates[i].Distance < candidates[j].Distance
+		}
+		return candidates[i].Node.ID < candidates[j].Node.ID
+	})
 
 	// Convert to results and limit to k
 	results := make([]*Candidate, 0, k)
@@ -263,15 +418,17 @@ func (idx *HNSWIndexImpl) Search(ctx context.Context, query []float32, k int, pa
 			break
 		}
 		results = append(results, &Candidate{
-			ID:    candidate.ID,
+			ID:    candidate.Node.ID,
 			Score: candidate.Distance,
 		})
 	}
 
 	// Update search latency stats
 	latency := time.Since(startTime).Seconds() * 1000
+	idx.statsMu.Lock()
 	idx.stats.SearchLatencyP50 = latency // Simplified
 	idx.stats.SearchLatencyP99 = latency
+	idx.statsMu.Unlock()
 
 	return results, nil
 }
@@ -280,7 +437,10 @@ func (idx *HNSWIndexImpl) Search(ctx context.Context, query []float32, k int, pa
 func (idx *HNSWIndexImpl) Size() int {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
-	return len(idx.nodes)
+
+	idx.nodesMu.RLock()
+	defer idx.nodesMu.RUnlock()
+	return len(idx.ids)
 }
 
 // Dimensions returns the vector dimensions
@@ -293,9 +453,12 @@ func (idx *HNSWIndexImpl) Type() IndexType {
 	return IndexTypeHNSW
 }
 
-// Optimize optimizes the index
+// Optimize compacts the tombstones left by deletes
 func (idx *HNSWIndexImpl) Optimize() error {
-	// HNSW doesn't need explicit optimization
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.purgeDeleted()
 	return nil
 }
 
@@ -304,21 +467,47 @@ func (idx *HNSWIndexImpl) Stats() *IndexStats {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
+	nodes := idx.nodeList()
+
+	idx.nodesMu.RLock()
+	deleted, deletedMemory := idx.deleted.Len(), idx.deleted.MemoryUsage()
+	idx.nodesMu.RUnlock()
+
 	// Calculate memory usage
-	vectorMemory := int64(len(idx.nodes)) * int64(idx.dimensions) * 4 // 4 bytes per float32
-	connectionMemory := int64(0)
+	vectorMemory := int64(len(nodes)) * int64(idx.dimensions) * 4 // 4 bytes per float32
+	totalDegree := 0
 
-	for _, node := range idx.nodes {
+	// Count nodes by their top layer
+	histogram := make(map[int]int)
+	for _, node := range nodes {
+		histogram[node.Layer]++
+
+		node.mu.Lock()
 		for _, connections := range node.Connections {
-			connectionMemory += int64(len(connections)) * 8 // 8 bytes per string pointer (approximate)
+			totalDegree += len(connections)
 		}
+		node.mu.Unlock()
 	}
+	connectionMemory := int64(totalDegree)*4 + deletedMemory // 4 bytes per uint32 neighbor ID
 
+	idx.statsMu.Lock()
 	stats := *idx.stats
-	stats.MemoryUsage = vectorMemory + connectionMemory
-	stats.VectorCount = len(idx.nodes)
+	idx.statsMu.Unlock()
+
+	idx.entryMu.Lock()
 	stats.MaxLayer = idx.maxLayer
-	stats.AvgDegree = idx.calculateAverageDegree()
+	idx.entryMu.Unlock()
+
+	stats.MemoryUsage = vectorMemory + connectionMemory
+	stats.VectorMemory = vectorMemory
+	stats.GraphMemory = connectionMemory
+	stats.VectorCount = len(nodes)
+	stats.DeletedCount = deleted
+	stats.AvgDegree = 0
+	if len(nodes) > 0 {
+		stats.AvgDegree = float64(totalDegree) / float64(len(nodes))
+	}
+	stats.LayerHistogram = histogram
 
 	return &stats
 }
@@ -329,20 +518,51 @@ func (idx *HNSWIndexImpl) Stats() *IndexStats {
 func (idx *HNSWIndexImpl) GetNode(id string) *HNSWNode {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
-	return idx.nodes[id]
+	return idx.node(id)
 }
 
-// GetConnections returns connections for a node at a specific layer
+// GetConnections returns the IDs of a node's neighbors at a specific layer
 func (idx *HNSWIndexImpl) GetConnections(id string, layer int) []string {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
-	if node, exists := idx.nodes[id]; exists {
-		if connections, hasLayer := node.Connections[layer]; hasLayer {
-			return connections
+	node := idx.node(id)
+	if node == nil {
+		return []string{}
+	}
+
+	connections := idx.connectionsAt(node, layer)
+	neighbors := make([]string, 0, len(connections))
+
+	idx.nodesMu.RLock()
+	defer idx.nodesMu.RUnlock()
+	for _, neighbor := range connections {
+		if neighborNode := idx.nodeAt(neighbor); neighborNode != nil && !idx.deleted.Contains(neighbor) {
+			neighbors = append(neighbors, neighborNode.ID)
 		}
 	}
-	return []string{}
+	return neighbors
+}
+
+// Reserve pre-sizes the node table so that inserting up to capacity vectors
+// does not trigger incremental growth
+func (idx *HNSWIndexImpl) Reserve(capacity int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if capacity <= len(idx.nodes) {
+		return
+	}
+
+	nodes := make([]*HNSWNode, len(idx.nodes), capacity)
+	copy(nodes, idx.nodes)
+	idx.nodes = nodes
+
+	ids := make(map[string]uint32, capacity)
+	for id, internalID := range idx.ids {
+		ids[id] = internalID
+	}
+	idx.ids = ids
 }
 
 // SetEfSearch sets the search parameter ef
@@ -354,81 +574,239 @@ func (idx *HNSWIndexImpl) SetEfSearch(ef int) {
 
 // Private methods
 
-func (idx *HNSWIndexImpl) addVector(vector *IndexVector) error {
-	// Determine layer for new node
-	layer := idx.randomLevel()
-
-	// Create new node
+// newNode creates an unlinked graph node for vector with the given internal
+// ID and top layer
+func (idx *HNSWIndexImpl) newNode(vector *IndexVector, internalID uint32, layer int) *HNSWNode {
 	node := &HNSWNode{
 		ID:          vector.ID,
 		Vector:      make([]float32, len(vector.Vector)),
 		Layer:       layer,
-		Connections: make(map[int][]string),
+		Connections: make([][]uint32, layer+1),
+		internalID:  internalID,
 	}
 	copy(node.Vector, vector.Vector)
 
 	// Initialize connections for each layer
 	for l := 0; l <= layer; l++ {
-		node.Connections[l] = make([]string, 0)
-	}
-
-	// If this is the first node, make it the entry point
-	if idx.entryPoint == nil {
-		idx.entryPoint = node
-		idx.maxLayer = layer
-		idx.nodes[vector.ID] = node
-		return nil
+		node.Connections[l] = make([]uint32, 0)
 	}
+	return node
+}
 
+// link connects a registered node to the graph, descending from entry at maxLayer
+func (idx *HNSWIndexImpl) link(node *HNSWNode, entry *HNSWNode, maxLayer int) {
 	// Search for closest nodes starting from entry point
 	entryPoints := []*QueueItem{{
-		ID:       idx.entryPoint.ID,
-		Distance: idx.calculator.Calculate(node.Vector, idx.entryPoint.Vector),
-		Vector:   idx.entryPoint.Vector,
+		Node:     entry,
+		Distance: idx.calculator.Calculate(node.Vector, entry.Vector),
 	}}
 
 	// Search from top layer down to layer+1
-	for l := idx.maxLayer; l >= layer+1; l-- {
-		entryPoints = idx.searchLayer(node.Vector, entryPoints, 1, l)
+	for l := maxLayer; l >= node.Layer+1; l-- {
+		if closest := idx.searchLayer(node.Vector, entryPoints, 1, l, nil); len(closest) > 0 {
+			entryPoints = closest
+		}
 	}
 
-	// Search and connect at each layer from layer down to 0
-	for l := min(layer, idx.maxLayer); l >= 0; l-- {
-		candidates := idx.searchLayer(node.Vector, entryPoints, idx.config.EfConstruction, l)
+	// Search for neighbors at each layer from layer down to 0
+	top := min(node.Layer, maxLayer)
+	layerNeighbors := make([][]*QueueItem, top+1)
+	for l := top; l >= 0; l-- {
+		candidates := idx.searchLayer(node.Vector, entryPoints, idx.config.EfConstruction, l, nil)
+
+		// Another insert may already have linked this node, so it can show
+		// up among its own candidates
+		for i, candidate := range candidates {
+			if candidate.Node == node {
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+		}
 
-		// Select neighbors
-		maxConn := idx.config.MaxM
-		if l == 0 {
-			maxConn = idx.config.MaxM0
+		layerNeighbors[l] = idx.selectNeighbors(candidates, idx.layerLimit(l))
+		if len(layerNeighbors[l]) > 0 {
+			entryPoints = layerNeighbors[l]
 		}
+	}
 
-		neighbors := idx.selectNeighbors(candidates, maxConn)
+	// Connect from layer 0 up, so that a concurrent search that reaches the
+	// node on an upper layer can always continue below it
+	for l := 0; l <= top; l++ {
+		maxConn := idx.layerLimit(l)
+		for _, neighbor := range layerNeighbors[l] {
+			neighborNode := neighbor.Node
 
-		// Add connections
-		for _, neighbor := range neighbors {
-			idx.addConnection(node, neighbor.ID, l)
-			idx.addConnection(idx.nodes[neighbor.ID], node.ID, l)
+			node.mu.Lock()
+			idx.addConnection(node, neighborNode.internalID, l)
+			node.mu.Unlock()
+
+			neighborNode.mu.Lock()
+			idx.addConnection(neighborNode, node.internalID, l)
 
 			// Prune connections if necessary
-			if len(idx.nodes[neighbor.ID].Connections[l]) > maxConn {
-				idx.pruneConnections(idx.nodes[neighbor.ID], l, maxConn)
+			if len(neighborNode.Connections[l]) > maxConn {
+				idx.pruneConnections(neighborNode, l, maxConn)
 			}
+			neighborNode.mu.Unlock()
 		}
+	}
+}
 
-		entryPoints = neighbors
+// buildWorkers returns how many workers should build a graph of n vectors
+func (idx *HNSWIndexImpl) buildWorkers(n int) int {
+	if n < parallelBuildThreshold {
+		return 1
 	}
+	workers := idx.config.BuildThreads
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
 
-	// Update entry point if new node has higher layer
-	if layer > idx.maxLayer {
-		idx.entryPoint = node
-		idx.maxLayer = layer
+// buildParallel links the registered nodes of an empty graph from several
+// workers, which only contend on the connection lists they modify
+func (idx *HNSWIndexImpl) buildParallel(ctx context.Context, workers int, linked *atomic.Int64) error {
+	nodes := idx.nodes
+	idx.entryPoint = nodes[0]
+	idx.maxLayer = nodes[0].Layer
+	if linked != nil {
+		linked.Add(1)
 	}
 
-	idx.nodes[vector.ID] = node
-	return nil
+	var next atomic.Int64
+	next.Store(1)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(nodes) || ctx.Err() != nil {
+					return
+				}
+				idx.insert(nodes[i])
+				if linked != nil {
+					linked.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// insert links a registered node while other inserts and searches run. The
+// first node becomes the entry point, and a node that raises the top layer
+// holds entryMu for its whole insertion so that no insert descends from an
+// entry point that is about to be replaced.
+func (idx *HNSWIndexImpl) insert(node *HNSWNode) {
+	idx.entryMu.Lock()
+	entry, maxLayer := idx.entryPoint, idx.maxLayer
+	if entry != nil && node.Layer <= maxLayer {
+		idx.entryMu.Unlock()
+		idx.link(node, entry, maxLayer)
+		return
+	}
+	defer idx.entryMu.Unlock()
+
+	if entry != nil {
+		idx.link(node, entry, maxLayer)
+	}
+	idx.entryPoint = node
+	idx.maxLayer = node.Layer
+}
+
+// node returns the node with the given vector ID, or nil
+func (idx *HNSWIndexImpl) node(id string) *HNSWNode {
+	idx.nodesMu.RLock()
+	defer idx.nodesMu.RUnlock()
+
+	internalID, exists := idx.ids[id]
+	if !exists {
+		return nil
+	}
+	return idx.nodes[internalID]
+}
+
+// nodeAt returns the node with the given internal ID, or nil if it was
+// compacted away or never existed. Tombstones are returned, since searches
+// route through them. The caller holds nodesMu or mu exclusively.
+func (idx *HNSWIndexImpl) nodeAt(internalID uint32) *HNSWNode {
+	if int(internalID) >= len(idx.nodes) {
+		return nil
+	}
+	return idx.nodes[internalID]
+}
+
+// nodeList returns a snapshot of the graph's live nodes
+func (idx *HNSWIndexImpl) nodeList() []*HNSWNode {
+	idx.nodesMu.RLock()
+	defer idx.nodesMu.RUnlock()
+
+	nodes := make([]*HNSWNode, 0, len(idx.ids))
+	for _, node := range idx.nodes {
+		if node != nil && !idx.deleted.Contains(node.internalID) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// compactNodes returns the nodes with their connections renumbered to
+// positions in the returned list; the caller holds mu exclusively and has
+// purged the tombstones
+func (idx *HNSWIndexImpl) compactNodes() []*HNSWNode {
+	if len(idx.ids) == len(idx.nodes) {
+		return idx.nodes
+	}
+
+	positions := make([]uint32, len(idx.nodes))
+	live := make([]*HNSWNode, 0, len(idx.ids))
+	for internalID, node := range idx.nodes {
+		if node != nil {
+			positions[internalID] = uint32(len(live))
+			live = append(live, node)
+		}
+	}
+
+	compacted := make([]*HNSWNode, len(live))
+	for i, node := range live {
+		connections := make([][]uint32, len(node.Connections))
+		for layer, neighbors := range node.Connections {
+			renumbered := make([]uint32, 0, len(neighbors))
+			for _, neighbor := range neighbors {
+				// Links to deleted nodes are dropped
+				if idx.nodeAt(neighbor) != nil {
+					renumbered = append(renumbered, positions[neighbor])
+				}
+			}
+			connections[layer] = renumbered
+		}
+		compacted[i] = &HNSWNode{ID: node.ID, Vector: node.Vector, Layer: node.Layer, Connections: connections}
+	}
+	return compacted
+}
+
+// connectionsAt returns the node's connections at layer. Writers replace or
+// only append to connection lists, so the returned slice stays valid to read
+// after the node lock is released.
+func (idx *HNSWIndexImpl) connectionsAt(node *HNSWNode, layer int) []uint32 {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	if layer >= len(node.Connections) {
+		return nil
+	}
+	return node.Connections[layer]
 }
 
 func (idx *HNSWIndexImpl) randomLevel() int {
+	idx.rngMu.Lock()
+	defer idx.rngMu.Unlock()
+
 	level := 0
 	for idx.rng.Float64() < idx.config.ML && level < 16 { // Cap at 16 layers
 		level++
@@ -436,62 +814,83 @@ func (idx *HNSWIndexImpl) randomLevel() int {
 	return level
 }
 
-func (idx *HNSWIndexImpl) searchLayer(query []float32, entryPoints []*QueueItem, ef int, layer int) []*QueueItem {
-	visited := make(map[string]bool)
+// searchLayer returns the ef live nodes closest to query that it finds on
+// layer, starting from entryPoints. Tombstones, and nodes outside allowed when
+// it is set, are traversed but left out, so the result can be empty when only
+// those are reachable.
+func (idx *HNSWIndexImpl) searchLayer(query []float32, entryPoints []*QueueItem, ef int, layer int, allowed *roaringBitmap) []*QueueItem {
+	visited := make(map[uint32]bool)
 	candidates := &PriorityQueue{}
 	w := &PriorityQueue{}
+	var neighbors []*HNSWNode
+	var tombstones []bool
 
 	// Initialize with entry points
+	idx.nodesMu.RLock()
 	for _, ep := range entryPoints {
 		heap.Push(candidates, &QueueItem{
-			ID:       ep.ID,
+			Node:     ep.Node,
 			Distance: ep.Distance,
-			Vector:   ep.Vector,
-		})
-		heap.Push(w, &QueueItem{
-			ID:       ep.ID,
-			Distance: -ep.Distance, // Max heap for w
-			Vector:   ep.Vector,
 		})
-		visited[ep.ID] = true
+		if !idx.deleted.Contains(ep.Node.internalID) && (allowed == nil || allowed.Contains(ep.Node.internalID)) {
+			heap.Push(w, &QueueItem{
+				Node:     ep.Node,
+				Distance: -ep.Distance, // Max heap for w
+			})
+		}
+		visited[ep.Node.internalID] = true
 	}
+	idx.nodesMu.RUnlock()
 
 	for candidates.Len() > 0 {
 		current := heap.Pop(candidates).(*QueueItem)
 
-		// Check if we should continue
-		if w.Len() > 0 && current.Distance > -(*w)[0].Distance {
+		// Check if we should continue; with an allowed set, not before the
+		// beam is full, as most nodes visited may be left out
+		if w.Len() > 0 && current.Distance > -(*w)[0].Distance && (allowed == nil || w.Len() >= ef) {
 			break
 		}
 
 		// Explore neighbors
-		if node, exists := idx.nodes[current.ID]; exists {
-			if connections, hasLayer := node.Connections[layer]; hasLayer {
-				for _, neighborID := range connections {
-					if !visited[neighborID] {
-						visited[neighborID] = true
-
-						if neighbor, exists := idx.nodes[neighborID]; exists {
-							distance := idx.calculator.Calculate(query, neighbor.Vector)
-
-							if w.Len() < ef || distance < -(*w)[0].Distance {
-								heap.Push(candidates, &QueueItem{
-									ID:       neighborID,
-									Distance: distance,
-									Vector:   neighbor.Vector,
-								})
-								heap.Push(w, &QueueItem{
-									ID:       neighborID,
-									Distance: -distance,
-									Vector:   neighbor.Vector,
-								})
-
-								if w.Len() > ef {
-									heap.Pop(w)
-								}
-							}
-						}
-					}
+		connections := idx.connectionsAt(current.Node, layer)
+		if len(connections) == 0 {
+			continue
+		}
+
+		// Resolve the unvisited neighbors under a single read lock
+		neighbors, tombstones = neighbors[:0], tombstones[:0]
+		idx.nodesMu.RLock()
+		for _, neighborID := range connections {
+			if !visited[neighborID] {
+				visited[neighborID] = true
+				if neighbor := idx.nodeAt(neighborID); neighbor != nil {
+					neighbors = append(neighbors, neighbor)
+					tombstones = append(tombstones, idx.deleted.Contains(neighborID) || allowed != nil && !allowed.Contains(neighborID))
+				}
+			}
+		}
+		idx.nodesMu.RUnlock()
+
+		for i, neighbor := range neighbors {
+			distance := idx.calculator.Calculate(query, neighbor.Vector)
+
+			if w.Len() < ef || distance < -(*w)[0].Distance {
+				heap.Push(candidates, &QueueItem{
+					Node:     neighbor,
+					Distance: distance,
+				})
+
+				// Tombstones are explored but never returned
+				if tombstones[i] {
+					continue
+				}
+				heap.Push(w, &QueueItem{
+					Node:     neighbor,
+					Distance: -distance,
+				})
+
+				if w.Len() > ef {
+					heap.Pop(w)
 				}
 			}
 		}
@@ -508,36 +907,81 @@ func (idx *HNSWIndexImpl) searchLayer(query []float32, entryPoints []*QueueItem,
 	return result
 }
 
+// selectNeighbors picks up to m neighbors from candidates sorted by their
+// distance to the node being linked
 func (idx *HNSWIndexImpl) selectNeighbors(candidates []*QueueItem, m int) []*QueueItem {
 	if len(candidates) <= m {
 		return candidates
 	}
 
-	// Simple selection - take closest m neighbors
-	// In a more sophisticated implementation, this would use heuristics
-	// to maintain connectivity and avoid hubs
-	return candidates[:m]
+	if idx.config.NeighborSelection == NeighborSelectionSimple {
+		return candidates[:m]
+	}
+	return idx.selectNeighborsHeuristic(candidates, m)
+}
+
+// selectNeighborsHeuristic implements the neighbor selection heuristic of
+// Malkov & Yashunin (Algorithm 4). A candidate is kept only if it is closer to
+// the node than to any neighbor already kept, so links reach out in different
+// directions instead of all pointing into the nearest cluster.
+func (idx *HNSWIndexImpl) selectNeighborsHeuristic(candidates []*QueueItem, m int) []*QueueItem {
+	selected := make([]*QueueItem, 0, m)
+	var discarded []*QueueItem
+
+	for _, candidate := range candidates {
+		if len(selected) >= m {
+			break
+		}
+
+		diverse := true
+		for _, neighbor := range selected {
+			if idx.calculator.Calculate(candidate.Node.Vector, neighbor.Node.Vector) < candidate.Distance {
+				diverse = false
+				break
+			}
+		}
+
+		if diverse {
+			selected = append(selected, candidate)
+		} else if idx.config.KeepPrunedConnections {
+			discarded = append(discarded, candidate)
+		}
+	}
+
+	// Top up with the closest discarded candidates
+	for _, candidate := range discarded {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, candidate)
+	}
+
+	return selected
 }
 
-func (idx *HNSWIndexImpl) addConnection(node *HNSWNode, neighborID string, layer int) {
-	if connections, hasLayer := node.Connections[layer]; hasLayer {
+func (idx *HNSWIndexImpl) addConnection(node *HNSWNode, neighbor uint32, layer int) {
+	if layer < len(node.Connections) {
+		connections := node.Connections[layer]
 		// Check if connection already exists
 		for _, existing := range connections {
-			if existing == neighborID {
+			if existing == neighbor {
 				return
 			}
 		}
-		node.Connections[layer] = append(connections, neighborID)
+		node.Connections[layer] = append(connections, neighbor)
 	}
 }
 
-func (idx *HNSWIndexImpl) removeConnection(node *HNSWNode, neighborID string, layer int) {
-	if connections, hasLayer := node.Connections[layer]; hasLayer {
+func (idx *HNSWIndexImpl) removeConnection(node *HNSWNode, neighbor uint32, layer int) {
+	if layer < len(node.Connections) {
+		connections := node.Connections[layer]
 		for i, existing := range connections {
-			if existing == neighborID {
-				// Remove by swapping with last element
-				connections[i] = connections[len(connections)-1]
-				node.Connections[layer] = connections[:len(connections)-1]
+			if existing == neighbor {
+				// Copy rather than remove in place, since a search may be
+				// reading the current list
+				remaining := make([]uint32, 0, len(connections)-1)
+				remaining = append(remaining, connections[:i]...)
+				node.Connections[layer] = append(remaining, connections[i+1:]...)
 				return
 			}
 		}
@@ -545,27 +989,32 @@ func (idx *HNSWIndexImpl) removeConnection(node *HNSWNode, neighborID string, la
 }
 
 func (idx *HNSWIndexImpl) pruneConnections(node *HNSWNode, layer int, maxConn int) {
-	if connections, hasLayer := node.Connections[layer]; hasLayer && len(connections) > maxConn {
-		// Simple pruning - keep closest neighbors
-		// In practice, this should use more sophisticated heuristics
+	if layer < len(node.Connections) && len(node.Connections[layer]) > maxConn {
+		connections := node.Connections[layer]
+
+		// Re-select the node's neighbors with the same strategy used on insert
 		candidates := make([]*QueueItem, 0, len(connections))
-		for _, connID := range connections {
-			if neighbor, exists := idx.nodes[connID]; exists {
+		// Links to tombstones are dropped along the way
+		idx.nodesMu.RLock()
+		for _, neighborID := range connections {
+			if neighbor := idx.nodeAt(neighborID); neighbor != nil && !idx.deleted.Contains(neighborID) {
 				candidates = append(candidates, &QueueItem{
-					ID:       connID,
+					Node:     neighbor,
 					Distance: idx.calculator.Calculate(node.Vector, neighbor.Vector),
 				})
 			}
 		}
+		idx.nodesMu.RUnlock()
 
 		if len(candidates) > 0 {
 			sort.Slice(candidates, func(i, j int) bool {
 				return candidates[i].Distance < candidates[j].Distance
 			})
 
-			newConnections := make([]string, 0, maxConn)
-			for i := 0; i < maxConn && i < len(candidates); i++ {
-				newConnections = append(newConnections, candidates[i].ID)
+			selected := idx.selectNeighbors(candidates, maxConn)
+			newConnections := make([]uint32, 0, len(selected))
+			for _, candidate := range selected {
+				newConnections = append(newConnections, candidate.Node.internalID)
 			}
 			node.Connections[layer] = newConnections
 		}
@@ -577,7 +1026,7 @@ func (idx *HNSWIndexImpl) findNewEntryPoint() {
 	var newEntryPoint *HNSWNode
 
 	for _, node := range idx.nodes {
-		if node.Layer > maxLayer {
+		if node != nil && !idx.deleted.Contains(node.internalID) && node.Layer > maxLayer {
 			maxLayer = node.Layer
 			newEntryPoint = node
 		}
@@ -587,19 +1036,108 @@ func (idx *HNSWIndexImpl) findNewEntryPoint() {
 	idx.maxLayer = maxLayer
 }
 
+// needsCompaction reports whether the tombstones should be purged; the
+// caller holds nodesMu or mu exclusively
+func (idx *HNSWIndexImpl) needsCompaction() bool {
+	deleted := float64(idx.deleted.Len())
+	return deleted > 0 && deleted > tombstoneCompactFraction*float64(len(idx.ids)+idx.deleted.Len())
+}
+
+// purgeDeleted unlinks the tombstones and frees their slots. A live node that
+// linked to a tombstone is offered the tombstone's own neighbors in its place,
+// so that the graph stays connected around the removed nodes, and nodes left
+// unreachable are linked back in. The caller holds mu exclusively.
+func (idx *HNSWIndexImpl) purgeDeleted() int {
+	purged := idx.deleted.Len()
+	if purged == 0 {
+		return 0
+	}
+
+	for _, node := range idx.nodes {
+		if node == nil || idx.deleted.Contains(node.internalID) {
+			continue
+		}
+		for layer, connections := range node.Connections {
+			if !idx.linksDeleted(connections) {
+				continue
+			}
+			node.Connections[layer] = idx.bypassDeleted(node, connections, layer)
+			if limit := idx.layerLimit(layer); len(node.Connections[layer]) > limit {
+				idx.pruneConnections(node, layer, limit)
+			}
+		}
+	}
+
+	idx.deleted.ForEach(func(internalID uint32) {
+		idx.nodes[internalID] = nil
+	})
+	idx.deleted.Clear()
+
+	if idx.entryPoint != nil && idx.nodes[idx.entryPoint.internalID] == nil {
+		idx.findNewEntryPoint()
+	}
+
+	// Nodes that only tombstones led to are linked back in
+	for _, node := range idx.unreachableNodes() {
+		idx.relink(node)
+	}
+
+	idx.stats.VectorCount = len(idx.ids)
+	return purged
+}
+
+// linksDeleted reports whether connections hold a tombstone
+func (idx *HNSWIndexImpl) linksDeleted(connections []uint32) bool {
+	for _, neighbor := range connections {
+		if idx.deleted.Contains(neighbor) {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassDeleted returns the node's connections at layer with every tombstone
+// replaced by the tombstone's live neighbors on the same layer
+func (idx *HNSWIndexImpl) bypassDeleted(node *HNSWNode, connections []uint32, layer int) []uint32 {
+	seen := map[uint32]bool{node.internalID: true}
+	kept := make([]uint32, 0, len(connections))
+	keep := func(neighbor uint32) {
+		if !seen[neighbor] && idx.nodeAt(neighbor) != nil && !idx.deleted.Contains(neighbor) {
+			seen[neighbor] = true
+			kept = append(kept, neighbor)
+		}
+	}
+
+	for _, neighbor := range connections {
+		if !idx.deleted.Contains(neighbor) {
+			keep(neighbor)
+			continue
+		}
+		if tombstone := idx.nodeAt(neighbor); tombstone != nil && layer < len(tombstone.Connections) {
+			for _, replacement := range tombstone.Connections[layer] {
+				keep(replacement)
+			}
+		}
+	}
+	return kept
+}
+
 func (idx *HNSWIndexImpl) calculateAverageDegree() float64 {
-	if len(idx.nodes) == 0 {
+	if len(idx.ids) == 0 {
 		return 0
 	}
 
 	totalDegree := 0
 	for _, node := range idx.nodes {
+		if node == nil || idx.deleted.Contains(node.internalID) {
+			continue
+		}
 		for _, connections := range node.Connections {
 			totalDegree += len(connections)
 		}
 	}
 
-	return float64(totalDegree) / float64(len(idx.nodes))
+	return float64(totalDegree) / float64(len(idx.ids))
 }
 
 func min(a, b int) int {
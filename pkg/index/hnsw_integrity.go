@@ -0,0 +1,295 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// repairRebuildFraction is the share of unreachable nodes above which Repair
+// rebuilds the whole graph instead of relinking the nodes one by one
+const repairRebuildFraction = 0.1
+
+// maxReportedNodes caps the node IDs listed in an integrity report
+const maxReportedNodes = 100
+
+// IntegrityReport describes the structural problems found in an HNSW graph
+type IntegrityReport struct {
+	Nodes            int      `json:"nodes"`
+	DeletedNodes     int      `json:"deleted_nodes"` // Tombstones awaiting compaction; searches route through them
+	MaxLayer         int      `json:"max_layer"`
+	EntryPoint       string   `json:"entry_point"`
+	EntryPointValid  bool     `json:"entry_point_valid"` // Entry point exists and sits on the top layer
+	DanglingLinks    int      `json:"dangling_links"`    // Links to nodes that no longer exist
+	SelfLinks        int      `json:"self_links"`
+	LayerViolations  int      `json:"layer_violations"` // Links on a layer the target node is not part of
+	DuplicateLinks   int      `json:"duplicate_links"`
+	MissingLayers    int      `json:"missing_layers"`    // Layers a node belongs to without a connection list
+	OverfullLists    int      `json:"overfull_lists"`    // Connection lists longer than the layer allows
+	OneWayLinks      int      `json:"one_way_links"`     // Links the target does not mirror; expected after pruning
+	UnreachableNodes int      `json:"unreachable_nodes"` // Nodes a search cannot reach from the entry point
+	Unreachable      []string `json:"unreachable,omitempty"`
+	Healthy          bool     `json:"healthy"`
+}
+
+// RepairReport describes what Repair changed
+type RepairReport struct {
+	PurgedNodes     int  `json:"purged_nodes"` // Tombstones compacted before the repair
+	RemovedLinks    int  `json:"removed_links"`
+	AddedLayers     int  `json:"added_layers"`
+	TrimmedLists    int  `json:"trimmed_lists"`
+	EntryPointReset bool `json:"entry_point_reset"`
+	RelinkedNodes   int  `json:"relinked_nodes"`
+	Rebuilt         bool `json:"rebuilt"` // Too much of the graph was unreachable and it was rebuilt
+}
+
+// CheckIntegrity validates the graph structure: link targets, layer
+// membership, connection list sizes, the entry point and whether every node
+// is reachable from the entry point on the bottom layer
+func (idx *HNSWIndexImpl) CheckIntegrity() *IntegrityReport {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.checkIntegrity()
+}
+
+// Repair compacts the tombstones and then fixes the problems CheckIntegrity
+// reports: invalid links are removed, missing layers added, overfull lists
+// pruned, the entry point reset and unreachable nodes linked back into the
+// graph. If too many nodes are
+// unreachable the graph is rebuilt from the node vectors instead.
+func (idx *HNSWIndexImpl) Repair() (*RepairReport, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	report := &RepairReport{PurgedNodes: idx.purgeDeleted()}
+
+	for _, node := range idx.nodes {
+		if node == nil {
+			continue
+		}
+		if len(node.Connections) > node.Layer+1 {
+			for _, connections := range node.Connections[node.Layer+1:] {
+				report.RemovedLinks += len(connections)
+			}
+			node.Connections = node.Connections[:node.Layer+1]
+		}
+		for len(node.Connections) < node.Layer+1 {
+			node.Connections = append(node.Connections, make([]uint32, 0))
+			report.AddedLayers++
+		}
+
+		for layer, connections := range node.Connections {
+			seen := make(map[uint32]bool, len(connections))
+			valid := make([]uint32, 0, len(connections))
+			for _, neighbor := range connections {
+				target := idx.nodeAt(neighbor)
+				if target == node || target == nil || target.Layer < layer || seen[neighbor] {
+					report.RemovedLinks++
+					continue
+				}
+				seen[neighbor] = true
+				valid = append(valid, neighbor)
+			}
+			node.Connections[layer] = valid
+
+			if limit := idx.layerLimit(layer); len(valid) > limit {
+				idx.pruneConnections(node, layer, limit)
+				report.TrimmedLists++
+			}
+		}
+	}
+
+	if !idx.entryPointValid() {
+		idx.findNewEntryPoint()
+		report.EntryPointReset = true
+	}
+
+	// Relink unreachable nodes; linking one can leave another's back-link
+	// pruned, so retry once before giving up and rebuilding
+	for attempt := 0; attempt < 2; attempt++ {
+		unreachable := idx.unreachableNodes()
+		if len(unreachable) == 0 {
+			break
+		}
+		if float64(len(unreachable)) > repairRebuildFraction*float64(len(idx.ids)) || attempt == 1 {
+			if err := idx.rebuildFromNodes(); err != nil {
+				return report, err
+			}
+			report.Rebuilt = true
+			break
+		}
+		for _, node := range unreachable {
+			idx.relink(node)
+			report.RelinkedNodes++
+		}
+	}
+
+	idx.stats.VectorCount = len(idx.ids)
+	idx.stats.MaxLayer = idx.maxLayer
+	idx.stats.AvgDegree = idx.calculateAverageDegree()
+
+	return report, nil
+}
+
+// checkIntegrity builds an integrity report; the caller holds mu
+func (idx *HNSWIndexImpl) checkIntegrity() *IntegrityReport {
+	report := &IntegrityReport{
+		Nodes:           len(idx.ids),
+		DeletedNodes:    idx.deleted.Len(),
+		MaxLayer:        idx.maxLayer,
+		EntryPointValid: idx.entryPointValid(),
+	}
+	if idx.entryPoint != nil {
+		report.EntryPoint = idx.entryPoint.ID
+	}
+
+	for _, node := range idx.nodes {
+		if node == nil || idx.deleted.Contains(node.internalID) {
+			continue
+		}
+		if missing := node.Layer + 1 - len(node.Connections); missing > 0 {
+			report.MissingLayers += missing
+		}
+
+		for layer, connections := range node.Connections {
+			if len(connections) > idx.layerLimit(layer) {
+				report.OverfullLists++
+			}
+
+			seen := make(map[uint32]bool, len(connections))
+			for _, neighbor := range connections {
+				if seen[neighbor] {
+					report.DuplicateLinks++
+					continue
+				}
+				seen[neighbor] = true
+
+				target := idx.nodeAt(neighbor)
+				switch {
+				case target == node:
+					report.SelfLinks++
+				case target == nil:
+					report.DanglingLinks++
+				case layer > node.Layer || target.Layer < layer:
+					report.LayerViolations++
+				case layer >= len(target.Connections) || !containsID(target.Connections[layer], node.internalID):
+					report.OneWayLinks++
+				}
+			}
+		}
+	}
+
+	unreachable := idx.unreachableNodes()
+	report.UnreachableNodes = len(unreachable)
+	if len(unreachable) > maxReportedNodes {
+		unreachable = unreachable[:maxReportedNodes]
+	}
+	for _, node := range unreachable {
+		report.Unreachable = append(report.Unreachable, node.ID)
+	}
+
+	report.Healthy = report.EntryPointValid &&
+		report.DanglingLinks == 0 && report.SelfLinks == 0 && report.LayerViolations == 0 &&
+		report.DuplicateLinks == 0 && report.MissingLayers == 0 && report.OverfullLists == 0 &&
+		report.UnreachableNodes == 0
+
+	return report
+}
+
+// entryPointValid reports whether the entry point is a live node on the top layer
+func (idx *HNSWIndexImpl) entryPointValid() bool {
+	if idx.entryPoint == nil {
+		return len(idx.ids) == 0
+	}
+	if idx.nodeAt(idx.entryPoint.internalID) != idx.entryPoint || idx.entryPoint.Layer != idx.maxLayer {
+		return false
+	}
+	for _, node := range idx.nodes {
+		if node != nil && node.Layer > idx.maxLayer {
+			return false
+		}
+	}
+	return true
+}
+
+// unreachableNodes returns, sorted by ID, the live nodes a search cannot reach
+// by following links from the entry point on the bottom layer
+func (idx *HNSWIndexImpl) unreachableNodes() []*HNSWNode {
+	reached := make([]bool, len(idx.nodes))
+	if idx.entryPoint != nil && idx.nodeAt(idx.entryPoint.internalID) == idx.entryPoint {
+		queue := []*HNSWNode{idx.entryPoint}
+		reached[idx.entryPoint.internalID] = true
+		for len(queue) > 0 {
+			node := queue[0]
+			queue = queue[1:]
+			if len(node.Connections) == 0 {
+				continue
+			}
+			for _, neighbor := range node.Connections[0] {
+				if target := idx.nodeAt(neighbor); target != nil && !reached[neighbor] {
+					reached[neighbor] = true
+					queue = append(queue, target)
+				}
+			}
+		}
+	}
+
+	var unreachable []*HNSWNode
+	for internalID, node := range idx.nodes {
+		if node != nil && !reached[internalID] && !idx.deleted.Contains(uint32(internalID)) {
+			unreachable = append(unreachable, node)
+		}
+	}
+	sort.Slice(unreachable, func(i, j int) bool {
+		return unreachable[i].ID < unreachable[j].ID
+	})
+	return unreachable
+}
+
+// relink links a node the entry point cannot reach back into the graph; the
+// caller holds mu exclusively
+func (idx *HNSWIndexImpl) relink(node *HNSWNode) {
+	idx.link(node, idx.entryPoint, idx.maxLayer)
+	for layer := range node.Connections {
+		if limit := idx.layerLimit(layer); len(node.Connections[layer]) > limit {
+			idx.pruneConnections(node, layer, limit)
+		}
+	}
+}
+
+// rebuildFromNodes rebuilds the graph from the vectors held by its nodes
+func (idx *HNSWIndexImpl) rebuildFromNodes() error {
+	live := idx.nodeList()
+	sort.Slice(live, func(i, j int) bool {
+		return live[i].ID < live[j].ID
+	})
+
+	vectors := make([]*IndexVector, len(live))
+	for i, node := range live {
+		vectors[i] = &IndexVector{ID: node.ID, Vector: node.Vector}
+	}
+
+	if err := idx.build(context.Background(), vectors, nil); err != nil {
+		return fmt.Errorf("failed to rebuild graph: %w", err)
+	}
+	return nil
+}
+
+// layerLimit returns the maximum number of connections a node keeps at layer
+func (idx *HNSWIndexImpl) layerLimit(layer int) int {
+	if layer == 0 {
+		return idx.config.MaxM0
+	}
+	return idx.config.MaxM
+}
+
+// containsID reports whether ids contains id
+func containsID(ids []uint32, id uint32) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,798 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// recallAt10 returns the fraction of the true 10 nearest neighbors the index finds
+func recallAt10(t testing.TB, idx HNSWIndex, vectors []*IndexVector, queries [][]float32) float64 {
+	t.Helper()
+
+	calculator := NewDistanceCalculator(DistanceMetricEuclidean)
+	found := 0
+	for _, query := range queries {
+		exact := make([]*Candidate, len(vectors))
+		for i, vector := range vectors {
+			exact[i] = &Candidate{ID: vector.ID, Score: calculator.Calculate(query, vector.Vector)}
+		}
+		sort.Slice(exact, func(i, j int) bool { return exact[i].Score < exact[j].Score })
+
+		truth := make(map[string]bool, 10)
+		for _, candidate := range exact[:10] {
+			truth[candidate.ID] = true
+		}
+
+		results, err := idx.Search(context.Background(), query, 10, nil)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		for _, result := range results {
+			if truth[result.ID] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(len(queries)*10)
+}
+
+func TestHNSWBuild_ParallelRecallParity(t *testing.T) {
+	const dimensions = 16
+	rng := rand.New(rand.NewSource(1))
+
+	vectors := make([]*IndexVector, 3000)
+	for i := range vectors {
+		v := make([]float32, dimensions)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		vectors[i] = &IndexVector{ID: fmt.Sprintf("v%d", i), Vector: v}
+	}
+	queries := make([][]float32, 100)
+	for i := range queries {
+		queries[i] = make([]float32, dimensions)
+		for j := range queries[i] {
+			queries[i][j] = rng.Float32()
+		}
+	}
+
+	build := func(threads int) HNSWIndex {
+		config := DefaultHNSWConfig()
+		config.BuildThreads = threads
+		idx := NewHNSWIndex(dimensions, DistanceMetricEuclidean, config)
+		if err := idx.Build(vectors); err != nil {
+			t.Fatalf("Build with %d threads failed: %v", threads, err)
+		}
+		if idx.Size() != len(vectors) {
+			t.Fatalf("Build with %d threads indexed %d vectors, want %d", threads, idx.Size(), len(vectors))
+		}
+		return idx
+	}
+
+	sequential := recallAt10(t, build(1), vectors, queries)
+	parallel := recallAt10(t, build(8), vectors, queries)
+	t.Logf("recall@10: sequential %.3f, parallel %.3f", sequential, parallel)
+
+	if sequential < 0.9 {
+		t.Errorf("Sequential build recall too low: %.3f", sequential)
+	}
+	if parallel < sequential-0.02 {
+		t.Errorf("Parallel build recall %.3f is below sequential %.3f", parallel, sequential)
+	}
+}
+
+func TestHNSWBuild_ParallelGraphIsConsistent(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	vectors := make([]*IndexVector, 2000)
+	for i := range vectors {
+		v := make([]float32, 8)
+		for j := range v {
+			v[j] = rng.Float32()
+		}
+		vectors[i] = &IndexVector{ID: fmt.Sprintf("v%d", i), Vector: v}
+	}
+
+	config := DefaultHNSWConfig()
+	config.BuildThreads = 4
+	idx := NewHNSWIndex(8, DistanceMetricEuclidean, config).(*HNSWIndexImpl)
+	if err := idx.Build(vectors); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if idx.entryPoint == nil || idx.entryPoint.Layer != idx.maxLayer {
+		t.Fatalf("Entry point does not sit on the top layer %d", idx.maxLayer)
+	}
+	for _, node := range idx.nodes {
+		for layer, connections := range node.Connections {
+			limit := config.MaxM
+			if layer == 0 {
+				limit = config.MaxM0
+			}
+			if len(connections) > limit {
+				t.Errorf("Node %s has %d connections at layer %d, limit %d", node.ID, len(connections), layer, limit)
+			}
+			for _, neighborID := range connections {
+				if neighborID == node.internalID {
+					t.Errorf("Node %s links to itself at layer %d", node.ID, layer)
+				}
+				if neighbor := idx.nodeAt(neighborID); neighbor == nil || neighbor.Layer < layer {
+					t.Errorf("Node %s has invalid link to %d at layer %d", node.ID, neighborID, layer)
+				}
+			}
+		}
+	}
+}
+
+// clusteredVectors returns n vectors and queries drawn around a few dozen
+// cluster centers, the data shape where neighbor selection matters most
+func clusteredVectors(n, queries, dimensions int, seed int64) ([]*IndexVector, [][]float32) {
+	rng := rand.New(rand.NewSource(seed))
+
+	centers := make([][]float32, 40)
+	for i := range centers {
+		centers[i] = make([]float32, dimensions)
+		for j := range centers[i] {
+			centers[i][j] = rng.Float32() * 10
+		}
+	}
+	sample := func() []float32 {
+		center := centers[rng.Intn(len(centers))]
+		v := make([]float32, dimensions)
+		for j := range v {
+			v[j] = center[j] + float32(rng.NormFloat64())
+		}
+		return v
+	}
+
+	vectors := make([]*IndexVector, n)
+	for i := range vectors {
+		vectors[i] = &IndexVector{ID: fmt.Sprintf("v%d", i), Vector: sample()}
+	}
+	qs := make([][]float32, queries)
+	for i := range qs {
+		qs[i] = sample()
+	}
+	return vectors, qs
+}
+
+// neighborSelectionConfig returns a small graph configuration using the given
+// neighbor selection strategy
+func neighborSelectionConfig(selection string, keepPruned bool) *HNSWConfig {
+	config := DefaultHNSWConfig()
+	config.M, config.MaxM, config.MaxM0 = 8, 8, 16
+	config.EfConstruction = 100
+	config.EfSearch = 20
+	config.BuildThreads = 1
+	config.NeighborSelection = selection
+	config.KeepPrunedConnections = keepPruned
+	return config
+}
+
+func TestHNSWNeighborSelection_HeuristicImprovesRecall(t *testing.T) {
+	vectors, queries := clusteredVectors(3000, 100, 16, 3)
+
+	recall := func(selection string, keepPruned bool) float64 {
+		idx := NewHNSWIndex(16, DistanceMetricEuclidean, neighborSelectionConfig(selection, keepPruned))
+		if err := idx.Build(vectors); err != nil {
+			t.Fatalf("Build with %s selection failed: %v", selection, err)
+		}
+		return recallAt10(t, idx, vectors, queries)
+	}
+
+	simple := recall(NeighborSelectionSimple, false)
+	heuristic := recall(NeighborSelectionHeuristic, false)
+	keepPruned := recall(NeighborSelectionHeuristic, true)
+	t.Logf("recall@10: simple %.3f, heuristic %.3f, heuristic+keepPruned %.3f", simple, heuristic, keepPruned)
+
+	if heuristic < simple+0.05 {
+		t.Errorf("Heuristic recall %.3f is not clearly above simple %.3f", heuristic, simple)
+	}
+	if keepPruned < heuristic-0.02 {
+		t.Errorf("keepPrunedConnections recall %.3f is below heuristic %.3f", keepPruned, heuristic)
+	}
+}
+
+// BenchmarkHNSWNeighborSelection reports build time and recall@10 for each
+// neighbor selection strategy on clustered data
+func BenchmarkHNSWNeighborSelection(b *testing.B) {
+	vectors, queries := clusteredVectors(10000, 200, 32, 3)
+
+	for _, mode := range []struct {
+		name       string
+		selection  string
+		keepPruned bool
+	}{
+		{"simple", NeighborSelectionSimple, false},
+		{"heuristic", NeighborSelectionHeuristic, false},
+		{"heuristic+keepPruned", NeighborSelectionHeuristic, true},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			var idx HNSWIndex
+			for i := 0; i < b.N; i++ {
+				idx = NewHNSWIndex(32, DistanceMetricEuclidean, neighborSelectionConfig(mode.selection, mode.keepPruned))
+				if err := idx.Build(vectors); err != nil {
+					b.Fatalf("Build failed: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(recallAt10(b, idx, vectors, queries), "recall@10")
+		})
+	}
+}
+
+func TestHNSWIntegrity_CheckAndRepair(t *testing.T) {
+	vectors, queries := clusteredVectors(500, 20, 8, 4)
+
+	config := DefaultHNSWConfig()
+	config.BuildThreads = 1
+	idx := NewHNSWIndex(8, DistanceMetricEuclidean, config).(*HNSWIndexImpl)
+	if err := idx.Build(vectors); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if report := idx.CheckIntegrity(); !report.Healthy {
+		t.Fatalf("Freshly built graph reported unhealthy: %+v", report)
+	}
+
+	// Damage the graph the way a crash or a partial write could: a dangling
+	// link, a node no other node links to, and an entry point that is gone
+	victim := idx.node("v0")
+	victim.Connections[0] = append(victim.Connections[0], uint32(len(idx.nodes)))
+	isolated := idx.node("v1")
+	for _, node := range idx.nodes {
+		for layer := range node.Connections {
+			idx.removeConnection(node, isolated.internalID, layer)
+		}
+	}
+	entry := idx.entryPoint
+	idx.nodes[entry.internalID] = nil
+	delete(idx.ids, entry.ID)
+
+	report := idx.CheckIntegrity()
+	if report.Healthy || report.EntryPointValid || report.DanglingLinks == 0 || report.UnreachableNodes == 0 {
+		t.Fatalf("Damage not detected: %+v", report)
+	}
+
+	changes, err := idx.Repair()
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if !changes.EntryPointReset || changes.RemovedLinks == 0 || (changes.RelinkedNodes == 0 && !changes.Rebuilt) {
+		t.Errorf("Repair did not report its changes: %+v", changes)
+	}
+	if report := idx.CheckIntegrity(); !report.Healthy {
+		t.Fatalf("Graph still unhealthy after repair: %+v", report)
+	}
+
+	live := make([]*IndexVector, 0, len(vectors)-1)
+	for _, vector := range vectors {
+		if vector.ID != entry.ID {
+			live = append(live, vector)
+		}
+	}
+	if recall := recallAt10(t, idx, live, queries); recall < 0.9 {
+		t.Errorf("Recall after repair too low: %.3f", recall)
+	}
+}
+
+func TestHNSWSaveLoad_RenumbersAfterDeletes(t *testing.T) {
+	vectors, queries := clusteredVectors(1000, 20, 8, 6)
+
+	config := DefaultHNSWConfig()
+	config.BuildThreads = 1
+	idx := NewHNSWIndex(8, DistanceMetricEuclidean, config).(*HNSWIndexImpl)
+	if err := idx.Build(vectors); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx := context.Background()
+	live := make([]*IndexVector, 0, len(vectors))
+	for i, vector := range vectors {
+		if i%10 == 0 {
+			if err := idx.Delete(ctx, vector.ID); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			continue
+		}
+		live = append(live, vector)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded := NewHNSWIndex(8, DistanceMetricEuclidean, config).(*HNSWIndexImpl)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(loaded.nodes) != len(live) || loaded.Size() != len(live) {
+		t.Fatalf("Expected %d nodes without gaps, got %d slots for %d nodes", len(live), len(loaded.nodes), loaded.Size())
+	}
+	if report := loaded.CheckIntegrity(); report.DanglingLinks != 0 || report.SelfLinks != 0 || report.LayerViolations != 0 {
+		t.Errorf("Renumbered graph has invalid links: %+v", report)
+	}
+	for _, vector := range live[:50] {
+		want := idx.GetConnections(vector.ID, 0)
+		got := loaded.GetConnections(vector.ID, 0)
+		sort.Strings(want)
+		sort.Strings(got)
+		if fmt.Sprint(want) != fmt.Sprint(got) {
+			t.Fatalf("Connections of %s changed across save/load: %v, want %v", vector.ID, got, want)
+		}
+	}
+	if recall := recallAt10(t, loaded, live, queries); recall < 0.9 {
+		t.Errorf("Recall after reload too low: %.3f", recall)
+	}
+}
+
+func TestRoaringBitmap(t *testing.T) {
+	b := newRoaringBitmap()
+	want := make(map[uint32]bool)
+	rng := rand.New(rand.NewSource(7))
+
+	// Dense enough in the first container to switch it to a bitmap, sparse in
+	// the others
+	for i := 0; i < 3*arrayContainerMax; i++ {
+		want[uint32(rng.Intn(1<<16))] = true
+	}
+	for i := 0; i < 100; i++ {
+		want[uint32(rng.Int63n(1<<32))] = true
+	}
+	for x := range want {
+		if !b.Add(x) {
+			t.Fatalf("Add(%d) reported a duplicate", x)
+		}
+	}
+	for x := range want {
+		if b.Add(x) {
+			t.Fatalf("Add(%d) accepted a duplicate", x)
+		}
+	}
+
+	if b.Len() != len(want) {
+		t.Fatalf("Expected %d values, got %d", len(want), b.Len())
+	}
+	for i := 0; i < 10000; i++ {
+		x := uint32(rng.Int63n(1 << 17))
+		if b.Contains(x) != want[x] {
+			t.Fatalf("Contains(%d) = %v, want %v", x, b.Contains(x), want[x])
+		}
+	}
+
+	var last uint32
+	seen := 0
+	b.ForEach(func(x uint32) {
+		if seen > 0 && x <= last {
+			t.Fatalf("ForEach out of order: %d after %d", x, last)
+		}
+		if !want[x] {
+			t.Fatalf("ForEach returned %d, which was never added", x)
+		}
+		last = x
+		seen++
+	})
+	if seen != len(want) {
+		t.Errorf("ForEach visited %d values, want %d", seen, len(want))
+	}
+}
+
+func TestHNSWDelete_TombstonesSkippedThenCompacted(t *testing.T) {
+	vectors, queries := clusteredVectors(1000, 20, 8, 8)
+
+	config := DefaultHNSWConfig()
+	config.BuildThreads = 1
+	idx := NewHNSWIndex(8, DistanceMetricEuclidean, config).(*HNSWIndexImpl)
+	if err := idx.Build(vectors); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// Delete below the compaction threshold: the nodes stay in the graph
+	ctx := context.Background()
+	deleted := make(map[string]bool)
+	for i := 0; i < 150; i++ {
+		id := vectors[i*6].ID
+		if err := idx.Delete(ctx, id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		deleted[id] = true
+	}
+	if err := idx.Delete(ctx, vectors[0].ID); err == nil {
+		t.Error("Deleting a deleted vector should fail")
+	}
+
+	if stats := idx.Stats(); stats.DeletedCount != 150 || stats.VectorCount != 850 || idx.Size() != 850 {
+		t.Fatalf("Expected 850 live vectors and 150 tombstones, got %d, %d (size %d)", stats.VectorCount, stats.DeletedCount, idx.Size())
+	}
+	if report := idx.CheckIntegrity(); !report.Healthy || report.DeletedNodes != 150 {
+		t.Fatalf("Graph with tombstones reported unhealthy: %+v", report)
+	}
+
+	live := make([]*IndexVector, 0, len(vectors))
+	for _, vector := range vectors {
+		if !deleted[vector.ID] {
+			live = append(live, vector)
+		}
+	}
+	for _, query := range queries {
+		results, err := idx.Search(ctx, query, 10, nil)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 10 {
+			t.Fatalf("Expected 10 results, got %d", len(results))
+		}
+		for _, result := range results {
+			if deleted[result.ID] {
+				t.Fatalf("Search returned deleted vector %s", result.ID)
+			}
+		}
+	}
+	if recall := recallAt10(t, idx, live, queries); recall < 0.9 {
+		t.Errorf("Recall with tombstones too low: %.3f", recall)
+	}
+
+	// A deleted ID can be inserted again
+	if err := idx.Add(ctx, vectors[0]); err != nil {
+		t.Fatalf("Re-adding a deleted ID failed: %v", err)
+	}
+	delete(deleted, vectors[0].ID)
+	live = append(live, vectors[0])
+
+	// Crossing the threshold compacts the tombstones away
+	for i := 1; len(deleted) < 250; i += 6 {
+		if err := idx.Delete(ctx, vectors[i].ID); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		deleted[vectors[i].ID] = true
+	}
+	live = live[:0]
+	for _, vector := range vectors {
+		if !deleted[vector.ID] {
+			live = append(live, vector)
+		}
+	}
+
+	if stats := idx.Stats(); stats.DeletedCount >= 200 {
+		t.Fatalf("Expected tombstones to be compacted, %d remain", stats.DeletedCount)
+	}
+	if err := idx.Optimize(); err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	report := idx.CheckIntegrity()
+	if !report.Healthy || report.DeletedNodes != 0 || report.Nodes != len(live) {
+		t.Fatalf("Compacted graph unhealthy: %+v", report)
+	}
+	if recall := recallAt10(t, idx, live, queries); recall < 0.9 {
+		t.Errorf("Recall after compaction too low: %.3f", recall)
+	}
+}
+
+func TestHNSWDeleteBatch_CompactsOnce(t *testing.T) {
+	vectors, queries := clusteredVectors(1000, 20, 8, 8)
+
+	config := DefaultHNSWConfig()
+	config.BuildThreads = 1
+	idx := NewHNSWIndex(8, DistanceMetricEuclidean, config).(*HNSWIndexImpl)
+	if err := idx.Build(vectors); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// Far past the threshold in one call, with an unknown and a repeated ID
+	ids := []string{"missing", vectors[0].ID}
+	deleted := make(map[string]bool)
+	for i := 0; i < len(vectors); i += 2 {
+		ids = append(ids, vectors[i].ID)
+		deleted[vectors[i].ID] = true
+	}
+	removed, err := idx.DeleteBatch(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if removed != 500 || idx.Size() != 500 {
+		t.Fatalf("Expected 500 removed and 500 left, got %d and %d", removed, idx.Size())
+	}
+
+	live := make([]*IndexVector, 0, len(vectors))
+	for _, vector := range vectors {
+		if !deleted[vector.ID] {
+			live = append(live, vector)
+		}
+	}
+	report := idx.CheckIntegrity()
+	if !report.Healthy || report.DeletedNodes != 0 || report.Nodes != len(live) {
+		t.Fatalf("Graph after batch delete unhealthy: %+v", report)
+	}
+	if recall := recallAt10(t, idx, live, queries); recall < 0.9 {
+		t.Errorf("Recall after batch delete too low: %.3f", recall)
+	}
+
+	flat := NewFlatIndex(8, DistanceMetricEuclidean, nil)
+	flat.Build(vectors)
+	if removed, err := flat.DeleteBatch(context.Background(), ids); err != nil || removed != 500 || flat.Size() != 500 {
+		t.Fatalf("Flat DeleteBatch = %d, %v with %d left; want 500 and 500", removed, err, flat.Size())
+	}
+}
+
+func TestFlatIndexDelete_SkipsAndCompacts(t *testing.T) {
+	ctx := context.Background()
+	idx := NewFlatIndex(2, DistanceMetricEuclidean, nil)
+	for i := 0; i < 10; i++ {
+		if err := idx.Add(ctx, &IndexVector{ID: fmt.Sprintf("v%d", i), Vector: []float32{float32(i), 0}}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	if err := idx.Delete(ctx, "v0"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if idx.Size() != 9 || idx.Stats().DeletedCount != 1 || len(idx.vectors) != 10 {
+		t.Fatalf("Expected one tombstone among 10 entries, got size %d with %d entries", idx.Size(), len(idx.vectors))
+	}
+	results, err := idx.Search(ctx, []float32{0, 0}, 3, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].ID != "v1" {
+		t.Fatalf("Expected v1 first without the deleted v0, got %+v", results)
+	}
+	if err := idx.Add(ctx, &IndexVector{ID: "v0", Vector: []float32{0, 0}}); err != nil {
+		t.Fatalf("Re-adding a deleted ID failed: %v", err)
+	}
+
+	// Three tombstones among 11 entries cross the threshold
+	for _, id := range []string{"v0", "v1"} {
+		if err := idx.Delete(ctx, id); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+	}
+	if idx.deleted.Len() != 0 || len(idx.vectors) != 8 || idx.Size() != 8 {
+		t.Fatalf("Expected tombstones compacted into 8 entries, got %d entries and %d tombstones", len(idx.vectors), idx.deleted.Len())
+	}
+}
+
+func TestHNSWConcurrentSearchWithInserts(t *testing.T) {
+	vectors, queries := clusteredVectors(2000, 50, 16, 5)
+
+	config := DefaultHNSWConfig()
+	config.BuildThreads = 1
+	idx := NewHNSWIndex(16, DistanceMetricEuclidean, config)
+	if err := idx.Build(vectors[:1000]); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1000 + w; i < len(vectors); i += 4 {
+				if err := idx.Add(ctx, vectors[i]); err != nil {
+					t.Errorf("Add failed: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	for s := 0; s < 4; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				results, err := idx.Search(ctx, queries[(s+i)%len(queries)], 10, nil)
+				if err != nil {
+					t.Errorf("Search failed: %v", err)
+					return
+				}
+				if len(results) != 10 {
+					t.Errorf("Expected 10 results, got %d", len(results))
+					return
+				}
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	if idx.Size() != len(vectors) {
+		t.Fatalf("Expected %d nodes, got %d", len(vectors), idx.Size())
+	}
+	if report := idx.CheckIntegrity(); !report.Healthy {
+		t.Errorf("Graph unhealthy after concurrent inserts: %+v", report)
+	}
+	if recall := recallAt10(t, idx, vectors, queries); recall < 0.9 {
+		t.Errorf("Recall after concurrent inserts too low: %.3f", recall)
+	}
+}
+
+// BenchmarkHNSWConcurrentSearchWithInserts measures search throughput while a
+// writer keeps inserting into the graph
+func BenchmarkHNSWConcurrentSearchWithInserts(b *testing.B) {
+	vectors, queries := clusteredVectors(20000, 200, 32, 5)
+
+	idx := NewHNSWIndex(32, DistanceMetricEuclidean, DefaultHNSWConfig())
+	if err := idx.Build(vectors[:10000]); err != nil {
+		b.Fatalf("Build failed: %v", err)
+	}
+
+	ctx := context.Background()
+	stop := make(chan struct{})
+	var inserted atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, vector := range vectors[10000:] {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := idx.Add(ctx, vector); err != nil {
+				b.Errorf("Add failed: %v", err)
+				return
+			}
+			inserted.Add(1)
+		}
+	}()
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			query := queries[next.Add(1)%int64(len(queries))]
+			if _, err := idx.Search(ctx, query, 10, nil); err != nil {
+				b.Errorf("Search failed: %v", err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	wg.Wait()
+	b.ReportMetric(float64(inserted.Load()), "inserts")
+}
+
+// BenchmarkHNSWGraphFootprint reports the heap held by a built graph and the
+// size of its saved form
+func BenchmarkHNSWGraphFootprint(b *testing.B) {
+	vectors, _ := clusteredVectors(20000, 1, 32, 7)
+	for i, vector := range vectors {
+		vector.ID = fmt.Sprintf("document-%08d", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	idx := NewHNSWIndex(32, DistanceMetricEuclidean, DefaultHNSWConfig())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := idx.Build(vectors); err != nil {
+			b.Fatalf("Build failed: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		b.Fatalf("Save failed: %v", err)
+	}
+
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/(1<<20), "heap-MB")
+	b.ReportMetric(float64(buf.Len())/(1<<20), "saved-MB")
+}
+
+func TestHNSWSearch_AllowedIDs(t *testing.T) {
+	vectors, queries := clusteredVectors(3000, 50, 16, 5)
+	idx := NewHNSWIndex(16, DistanceMetricEuclidean, neighborSelectionConfig(NeighborSelectionHeuristic, false))
+	if err := idx.Build(vectors); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	// Every tenth vector is allowed
+	var allowed []string
+	var allowedVectors []*IndexVector
+	for i, vector := range vectors {
+		if i%10 == 0 {
+			allowed = append(allowed, vector.ID)
+			allowedVectors = append(allowedVectors, vector)
+		}
+	}
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, id := range allowed {
+		isAllowed[id] = true
+	}
+
+	calculator := NewDistanceCalculator(DistanceMetricEuclidean)
+	params := &SearchParams{EF: 100, Allowed: allowed}
+	found := 0
+	for _, query := range queries {
+		results, err := idx.Search(context.Background(), query, 10, params)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 10 {
+			t.Fatalf("Search returned %d results, want 10", len(results))
+		}
+
+		exact := make([]*Candidate, len(allowedVectors))
+		for i, vector := range allowedVectors {
+			exact[i] = &Candidate{ID: vector.ID, Score: calculator.Calculate(query, vector.Vector)}
+		}
+		sort.Slice(exact, func(i, j int) bool { return exact[i].Score < exact[j].Score })
+		truth := make(map[string]bool, 10)
+		for _, candidate := range exact[:10] {
+			truth[candidate.ID] = true
+		}
+		for _, result := range results {
+			if !isAllowed[result.ID] {
+				t.Fatalf("Search returned %s, which is not allowed", result.ID)
+			}
+			if truth[result.ID] {
+				found++
+			}
+		}
+	}
+	if recall := float64(found) / float64(len(queries)*10); recall < 0.9 {
+		t.Errorf("recall@10 among the allowed vectors is %.3f", recall)
+	}
+
+	results, err := idx.Search(context.Background(), queries[0], 10, &SearchParams{Allowed: []string{}})
+	if err != nil || len(results) != 0 {
+		t.Errorf("Search with nothing allowed returned %d results, %v", len(results), err)
+	}
+
+	flat := NewFlatIndex(16, DistanceMetricEuclidean, nil)
+	if err := flat.Build(vectors); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	results, err = flat.Search(context.Background(), queries[0], 5, &SearchParams{Allowed: allowed[:3]})
+	if err != nil || len(results) != 3 {
+		t.Errorf("flat Search returned %d results, %v", len(results), err)
+	}
+}
+
+func TestHNSWReserve(t *testing.T) {
+	ctx := context.Background()
+	vectors, queries := clusteredVectors(500, 20, 8, 9)
+	idx := NewHNSWIndex(8, DistanceMetricEuclidean, DefaultHNSWConfig()).(*HNSWIndexImpl)
+	for _, vector := range vectors[:100] {
+		if err := idx.Add(ctx, vector); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// Reserving keeps the graph and makes room for the rest
+	idx.Reserve(len(vectors))
+	if cap(idx.nodes) < len(vectors) || len(idx.nodes) != 100 || len(idx.ids) != 100 {
+		t.Fatalf("got %d nodes and %d IDs with capacity %d, want 100 with capacity %d", len(idx.nodes), len(idx.ids), cap(idx.nodes), len(vectors))
+	}
+	backing := &idx.nodes[0]
+	for _, vector := range vectors[100:] {
+		if err := idx.Add(ctx, vector); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if &idx.nodes[0] != backing {
+		t.Error("adding the reserved vectors grew the node table again")
+	}
+	if report := idx.CheckIntegrity(); report.DanglingLinks != 0 || report.SelfLinks != 0 || report.LayerViolations != 0 {
+		t.Errorf("graph built after reserving has invalid links: %+v", report)
+	}
+	if recall := recallAt10(t, idx, vectors, queries); recall < 0.9 {
+		t.Errorf("recall after reserving too low: %.3f", recall)
+	}
+}
@@ -0,0 +1,151 @@
+package index
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// arrayContainerMax is the most values an array container holds before it is
+// converted to a bitmap container, the point where both take 8 KiB
+const arrayContainerMax = 4096
+
+// roaringBitmap is a compressed set of uint32 values in the roaring layout:
+// values are grouped by their high 16 bits, and each group stores its low 16
+// bits as a sorted array while sparse or as a 65536-bit bitmap once dense.
+// Membership tests cost a binary search over the groups plus one lookup, and
+// memory stays proportional to the number of values rather than the largest.
+type roaringBitmap struct {
+	keys        []uint16 // High 16 bits of each container, sorted
+	containers  []*roaringContainer
+	cardinality int
+}
+
+// roaringContainer holds the low 16 bits of the values sharing one high half.
+// Exactly one of array and bitmap is set.
+type roaringContainer struct {
+	array  []uint16 // Sorted
+	bitmap []uint64 // 1024 words
+}
+
+// newRoaringBitmap creates an empty bitmap
+func newRoaringBitmap() *roaringBitmap {
+	return &roaringBitmap{}
+}
+
+// Add inserts x and reports whether it was not already present
+func (b *roaringBitmap) Add(x uint32) bool {
+	high, low := uint16(x>>16), uint16(x)
+
+	i, found := b.find(high)
+	if !found {
+		b.keys = append(b.keys, 0)
+		copy(b.keys[i+1:], b.keys[i:])
+		b.keys[i] = high
+
+		b.containers = append(b.containers, nil)
+		copy(b.containers[i+1:], b.containers[i:])
+		b.containers[i] = &roaringContainer{}
+	}
+
+	if !b.containers[i].add(low) {
+		return false
+	}
+	b.cardinality++
+	return true
+}
+
+// Contains reports whether x is in the set
+func (b *roaringBitmap) Contains(x uint32) bool {
+	i, found := b.find(uint16(x >> 16))
+	return found && b.containers[i].contains(uint16(x))
+}
+
+// Len returns the number of values in the set
+func (b *roaringBitmap) Len() int {
+	return b.cardinality
+}
+
+// ForEach calls fn with every value in ascending order
+func (b *roaringBitmap) ForEach(fn func(x uint32)) {
+	for i, c := range b.containers {
+		high := uint32(b.keys[i]) << 16
+		if c.bitmap == nil {
+			for _, low := range c.array {
+				fn(high | uint32(low))
+			}
+			continue
+		}
+		for w, word := range c.bitmap {
+			for word != 0 {
+				bit := bits.TrailingZeros64(word)
+				fn(high | uint32(w*64+bit))
+				word &= word - 1
+			}
+		}
+	}
+}
+
+// Clear removes every value
+func (b *roaringBitmap) Clear() {
+	b.keys = nil
+	b.containers = nil
+	b.cardinality = 0
+}
+
+// MemoryUsage estimates the bytes held by the set
+func (b *roaringBitmap) MemoryUsage() int64 {
+	size := int64(len(b.keys)) * 2
+	for _, c := range b.containers {
+		size += int64(len(c.array))*2 + int64(len(c.bitmap))*8
+	}
+	return size
+}
+
+// find returns the position of the container for high, or where it belongs
+func (b *roaringBitmap) find(high uint16) (int, bool) {
+	i := sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= high })
+	return i, i < len(b.keys) && b.keys[i] == high
+}
+
+// add inserts low and reports whether it was not already present
+func (c *roaringContainer) add(low uint16) bool {
+	if c.bitmap != nil {
+		word, mask := low/64, uint64(1)<<(low%64)
+		if c.bitmap[word]&mask != 0 {
+			return false
+		}
+		c.bitmap[word] |= mask
+		return true
+	}
+
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	if i < len(c.array) && c.array[i] == low {
+		return false
+	}
+	if len(c.array) >= arrayContainerMax {
+		c.toBitmap()
+		return c.add(low)
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = low
+	return true
+}
+
+// contains reports whether low is in the container
+func (c *roaringContainer) contains(low uint16) bool {
+	if c.bitmap != nil {
+		return c.bitmap[low/64]&(uint64(1)<<(low%64)) != 0
+	}
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= low })
+	return i < len(c.array) && c.array[i] == low
+}
+
+// toBitmap converts an array container into a bitmap container
+func (c *roaringContainer) toBitmap() {
+	c.bitmap = make([]uint64, 1024)
+	for _, low := range c.array {
+		c.bitmap[low/64] |= uint64(1) << (low % 64)
+	}
+	c.array = nil
+}
@@ -3,6 +3,8 @@ package index
 import (
 	"context"
 	"io"
+	"sync"
+	"sync/atomic"
 )
 
 // Index provides vector similarity search
@@ -15,6 +17,7 @@ type Index interface {
 	// Operations
 	Add(ctx context.Context, vector *IndexVector) error
 	Delete(ctx context.Context, id string) error
+	DeleteBatch(ctx context.Context, ids []string) (int, error) // Removes many vectors, repairing once; unknown IDs are skipped
 	Search(ctx context.Context, query []float32, k int, params *SearchParams) ([]*Candidate, error)
 
 	// Metadata
@@ -25,6 +28,7 @@ type Index interface {
 	// Maintenance
 	Optimize() error
 	Stats() *IndexStats
+	Reserve(capacity int) // Pre-size internal structures for the expected number of vectors
 }
 
 // IndexType represents the type of vector index
@@ -61,12 +65,25 @@ type Candidate struct {
 	Score float32 `json:"score"`
 }
 
+// candidateLess orders candidates by ascending distance, and equal distances
+// by ID, so ties come back in the same order on every search
+func candidateLess(a, b *Candidate) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.ID < b.ID
+}
+
 // SearchParams contains search parameters
 type SearchParams struct {
 	EF          int                    `json:"ef"`           // HNSW search parameter
 	NProbes     int                    `json:"n_probes"`     // IVF parameter
 	ExactSearch bool                   `json:"exact_search"` // Force exact search
 	Params      map[string]interface{} `json:"params"`       // Algorithm-specific
+
+	// Allowed restricts the results to these IDs when set. HNSW traverses
+	// the other nodes, so the graph stays connected, but never returns them.
+	Allowed []string `json:"-"`
 }
 
 // DistanceMetric represents distance calculation methods
@@ -77,6 +94,8 @@ const (
 	DistanceMetricEuclidean
 	DistanceMetricDotProduct
 	DistanceMetricManhattan
+	DistanceMetricHamming // Binary vectors: number of differing bits
+	DistanceMetricJaccard // Binary vectors: 1 - shared set bits / all set bits
 )
 
 func (d DistanceMetric) String() string {
@@ -89,6 +108,10 @@ func (d DistanceMetric) String() string {
 		return "dot_product"
 	case DistanceMetricManhattan:
 		return "manhattan"
+	case DistanceMetricHamming:
+		return "hamming"
+	case DistanceMetricJaccard:
+		return "jaccard"
 	default:
 		return "unknown"
 	}
@@ -110,8 +133,27 @@ type HNSWConfig struct {
 	EfConstruction int     `json:"ef_construction"`
 	EfSearch       int     `json:"ef_search"`
 	Seed           int64   `json:"seed"`
+	BuildThreads   int     `json:"build_threads"` // Workers used by Build (0 uses GOMAXPROCS)
+
+	// NeighborSelection picks how a node's neighbors are chosen from the
+	// candidates found during insertion: NeighborSelectionHeuristic (default)
+	// or NeighborSelectionSimple
+	NeighborSelection string `json:"neighbor_selection"`
+	// KeepPrunedConnections fills the remaining neighbor slots with candidates
+	// the heuristic discarded, so nodes keep their full degree
+	KeepPrunedConnections bool `json:"keep_pruned_connections"`
 }
 
+// Neighbor selection strategies
+const (
+	// NeighborSelectionHeuristic keeps a candidate only if it is closer to the
+	// node than to every neighbor already selected (Malkov & Yashunin,
+	// Algorithm 4). This spreads links across clusters and improves recall.
+	NeighborSelectionHeuristic = "heuristic"
+	// NeighborSelectionSimple keeps the closest candidates (Algorithm 3)
+	NeighborSelectionSimple = "simple"
+)
+
 // DefaultHNSWConfig returns default HNSW configuration
 func DefaultHNSWConfig() *HNSWConfig {
 	return &HNSWConfig{
@@ -122,6 +164,8 @@ func DefaultHNSWConfig() *HNSWConfig {
 		EfConstruction: 200,
 		EfSearch:       50,
 		Seed:           42,
+
+		NeighborSelection: NeighborSelectionHeuristic,
 	}
 }
 
@@ -131,14 +175,23 @@ type HNSWIndex interface {
 	GetNode(id string) *HNSWNode
 	GetConnections(id string, layer int) []string
 	SetEfSearch(ef int)
+	BuildTracked(ctx context.Context, vectors []*IndexVector, linked *atomic.Int64) error
+
+	// Maintenance
+	CheckIntegrity() *IntegrityReport
+	Repair() (*RepairReport, error)
 }
 
-// HNSWNode represents a node in the HNSW graph
+// HNSWNode represents a node in the HNSW graph. Connections holds, for each
+// layer from 0 to Layer, the internal IDs of the node's neighbors.
 type HNSWNode struct {
-	ID          string           `json:"id"`
-	Vector      []float32        `json:"vector"`
-	Layer       int              `json:"layer"`
-	Connections map[int][]string `json:"connections"`
+	ID          string     `json:"id"`
+	Vector      []float32  `json:"vector"`
+	Layer       int        `json:"layer"`
+	Connections [][]uint32 `json:"connections"`
+
+	internalID uint32     // Position of the node in the index's node table
+	mu         sync.Mutex // Guards Connections against concurrent inserts
 }
 
 // Flat index configuration
@@ -161,9 +214,18 @@ type IndexStats struct {
 	MemoryUsage int64     `json:"memory_usage"`
 	BuildTime   int64     `json:"build_time_ms"`
 
+	// Deleted entries skipped by searches until the index compacts them
+	DeletedCount int `json:"deleted_count,omitempty"`
+
+	// Memory breakdown
+	VectorMemory int64 `json:"vector_memory"`
+	GraphMemory  int64 `json:"graph_memory"`
+	DiskSize     int64 `json:"disk_size"` // Serialized size on disk (filled in by the owner)
+
 	// HNSW specific
-	MaxLayer  int     `json:"max_layer,omitempty"`
-	AvgDegree float64 `json:"avg_degree,omitempty"`
+	MaxLayer       int         `json:"max_layer,omitempty"`
+	AvgDegree      float64     `json:"avg_degree,omitempty"`
+	LayerHistogram map[int]int `json:"layer_histogram,omitempty"` // Nodes per top layer
 
 	// Performance metrics
 	SearchLatencyP50 float64 `json:"search_latency_p50"`
@@ -175,9 +237,8 @@ type IndexStats struct {
 type PriorityQueue []*QueueItem
 
 type QueueItem struct {
-	ID       string
+	Node     *HNSWNode
 	Distance float32
-	Vector   []float32
 }
 
 func (pq PriorityQueue) Len() int { return len(pq) }
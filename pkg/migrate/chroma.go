@@ -0,0 +1,283 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Files of a Chroma DuckDB+Parquet store
+const (
+	chromaEmbeddingsFile  = "chroma-embeddings.parquet"
+	chromaCollectionsFile = "chroma-collections.parquet"
+)
+
+// chromaReadBatch is how many rows are read from the Parquet file at a time
+const chromaReadBatch = 256
+
+// chromaReader reads the embeddings table of a Chroma store. Columns are
+// found by name and values converted by kind, since stores written by
+// different DuckDB versions differ in physical types.
+type chromaReader struct {
+	file       *os.File
+	rowGroups  []parquet.RowGroup
+	rows       parquet.Rows
+	buffer     []parquet.Row
+	buffered   int
+	next       int
+	columns    parquetColumns
+	collection string // UUID of the collection to read; empty reads all of them
+}
+
+// openChroma reads the embeddings of a Chroma store persisted as Parquet:
+// the store directory, or its chroma-embeddings.parquet file. The document of
+// an embedding becomes the content field of its record.
+func openChroma(path string, opts *Options) (Reader, error) {
+	dir := path
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		path = filepath.Join(dir, chromaEmbeddingsFile)
+	} else {
+		dir = filepath.Dir(path)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".duckdb", ".sqlite3", ".sqlite":
+		return nil, fmt.Errorf("%s is a Chroma database file: export its embeddings table to Parquet (for DuckDB, COPY embeddings TO '%s' (FORMAT PARQUET)) and migrate from that", path, chromaEmbeddingsFile)
+	}
+
+	r := &chromaReader{}
+	if opts.Collection != "" {
+		if r.collection, err = chromaCollectionUUID(filepath.Join(dir, chromaCollectionsFile), opts.Collection); err != nil {
+			return nil, err
+		}
+	}
+
+	file, pf, columns, err := openParquet(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range []string{"id", "embedding"} {
+		if columns.index(column) < 0 {
+			file.Close()
+			return nil, fmt.Errorf("%s has no %s column: not a Chroma embeddings table", path, column)
+		}
+	}
+	r.file = file
+	r.columns = columns
+	r.buffer = make([]parquet.Row, chromaReadBatch)
+	r.rowGroups = pf.RowGroups()
+	return r, nil
+}
+
+func (r *chromaReader) Next() (*core.Vector, error) {
+	for {
+		row, err := r.readRow()
+		if err != nil {
+			return nil, err
+		}
+
+		var record core.Vector
+		var collection, metadata string
+		var document *string
+		var invalid error
+		row.Range(func(column int, values []parquet.Value) bool {
+			switch column {
+			case r.columns.index("id"):
+				record.ID = parquetString(values)
+			case r.columns.index("collection_uuid"):
+				collection = parquetString(values)
+			case r.columns.index("metadata"):
+				metadata = parquetString(values)
+			case r.columns.index("document"):
+				if len(values) > 0 && !values[0].IsNull() {
+					text := parquetString(values)
+					document = &text
+				}
+			case r.columns.index("embedding"):
+				record.Vector, invalid = parquetFloats(values)
+			}
+			return invalid == nil
+		})
+		if r.collection != "" && collection != r.collection {
+			continue
+		}
+		if invalid != nil {
+			return nil, fmt.Errorf("embedding %s: %w", record.ID, invalid)
+		}
+		if record.ID == "" || len(record.Vector) == 0 {
+			return nil, fmt.Errorf("embedding '%s' has no id or no vector", record.ID)
+		}
+
+		record.Metadata = make(map[string]interface{})
+		if metadata != "" {
+			if err := json.Unmarshal([]byte(metadata), &record.Metadata); err != nil {
+				return nil, fmt.Errorf("embedding %s: invalid metadata: %w", record.ID, err)
+			}
+		}
+		if document != nil {
+			record.Metadata[core.DefaultContentStorageConfig().FieldName] = *document
+		}
+		return &record, nil
+	}
+}
+
+// readRow returns the next row of the file, across row groups
+func (r *chromaReader) readRow() (parquet.Row, error) {
+	for r.next == r.buffered {
+		if r.rows == nil {
+			if len(r.rowGroups) == 0 {
+				return nil, io.EOF
+			}
+			r.rows = r.rowGroups[0].Rows()
+			r.rowGroups = r.rowGroups[1:]
+		}
+		n, err := r.rows.ReadRows(r.buffer)
+		r.buffered, r.next = n, 0
+		if err == io.EOF {
+			r.rows.Close()
+			r.rows = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	row := r.buffer[r.next]
+	r.next++
+	return row, nil
+}
+
+func (r *chromaReader) Close() error {
+	if r.rows != nil {
+		r.rows.Close()
+	}
+	return r.file.Close()
+}
+
+// chromaCollectionUUID returns the UUID of a collection of a Chroma store
+func chromaCollectionUUID(path, name string) (string, error) {
+	file, pf, columns, err := openParquet(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the collections of the store: %w", err)
+	}
+	defer file.Close()
+
+	for _, rowGroup := range pf.RowGroups() {
+		rows := rowGroup.Rows()
+		buffer := make([]parquet.Row, chromaReadBatch)
+		for {
+			n, err := rows.ReadRows(buffer)
+			for _, row := range buffer[:n] {
+				var uuid, rowName string
+				row.Range(func(column int, values []parquet.Value) bool {
+					switch column {
+					case columns.index("uuid"):
+						uuid = parquetString(values)
+					case columns.index("name"):
+						rowName = parquetString(values)
+					}
+					return true
+				})
+				if rowName == name {
+					rows.Close()
+					return uuid, nil
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				rows.Close()
+				return "", err
+			}
+		}
+		rows.Close()
+	}
+	return "", fmt.Errorf("collection '%s' not found in the Chroma store", name)
+}
+
+// parquetColumns are the indexes of the leaf columns of a Parquet file by
+// top-level column name
+type parquetColumns map[string]int
+
+// index returns the index of a column, or -1 when the file has none
+func (c parquetColumns) index(name string) int {
+	if index, exists := c[name]; exists {
+		return index
+	}
+	return -1
+}
+
+// openParquet opens a Parquet file and returns its columns
+func openParquet(path string) (*os.File, *parquet.File, parquetColumns, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, err
+	}
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	columns := make(parquetColumns)
+	for i, column := range pf.Schema().Columns() {
+		columns[column[0]] = i
+	}
+	return file, pf, columns, nil
+}
+
+// parquetString returns a value as a string; 16-byte values are UUIDs
+func parquetString(values []parquet.Value) string {
+	if len(values) == 0 || values[0].IsNull() {
+		return ""
+	}
+	value := values[0]
+	switch value.Kind() {
+	case parquet.ByteArray:
+		return string(value.ByteArray())
+	case parquet.FixedLenByteArray:
+		b := value.ByteArray()
+		if len(b) == 16 {
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+		}
+		return string(b)
+	case parquet.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case parquet.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	default:
+		return value.String()
+	}
+}
+
+// parquetFloats returns the elements of a list of floats or doubles
+func parquetFloats(values []parquet.Value) ([]float32, error) {
+	floats := make([]float32, 0, len(values))
+	for _, value := range values {
+		if value.IsNull() {
+			continue
+		}
+		switch value.Kind() {
+		case parquet.Float:
+			floats = append(floats, value.Float())
+		case parquet.Double:
+			floats = append(floats, float32(value.Double()))
+		default:
+			return nil, fmt.Errorf("embedding values are %s, not floats", value.Kind())
+		}
+	}
+	return floats, nil
+}
@@ -0,0 +1,166 @@
+// Package migrate reads the dumps of other vector databases as VittoriaDB
+// records, for loading them into a collection.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// Source formats
+const (
+	FormatQdrant   = "qdrant"   // Points as returned by the scroll API, as JSON lines or JSON
+	FormatChroma   = "chroma"   // The Parquet files of a Chroma DuckDB+Parquet store
+	FormatPinecone = "pinecone" // Vectors as JSON lines, or a fetch response
+)
+
+// Formats lists the source formats
+var Formats = []string{FormatQdrant, FormatChroma, FormatPinecone}
+
+// Options select what to read from a dump
+type Options struct {
+	VectorName string // Qdrant: the named vector to import, when points have several
+	Collection string // Chroma: the collection to import, when the store has several
+}
+
+// Reader returns the records of a dump one at a time
+type Reader interface {
+	// Next returns the next record, or io.EOF after the last one
+	Next() (*core.Vector, error)
+	Close() error
+}
+
+// Open opens the dump at path in the given format
+func Open(format, path string, opts *Options) (Reader, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	switch format {
+	case FormatQdrant:
+		return openQdrant(path, opts)
+	case FormatChroma:
+		return openChroma(path, opts)
+	case FormatPinecone:
+		return openPinecone(path)
+	default:
+		return nil, fmt.Errorf("unknown format '%s': expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Mapping maps the metadata of the records read to the fields of the
+// collection
+type Mapping struct {
+	Fields         []string          // Source fields to keep; empty keeps all of them
+	Rename         map[string]string // New names of source fields; an empty name drops the field
+	NamespaceField string            // Source field holding the namespace of a record, removed from its metadata
+}
+
+// ParseRename parses renames given as source=target, where an empty target
+// drops the field
+func ParseRename(specs []string) (map[string]string, error) {
+	rename := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		source, target, ok := strings.Cut(spec, "=")
+		if !ok || source == "" {
+			return nil, fmt.Errorf("invalid mapping '%s': expected source=target", spec)
+		}
+		rename[source] = target
+	}
+	return rename, nil
+}
+
+// Apply maps the metadata of a record in place
+func (m *Mapping) Apply(record *core.Vector) error {
+	if m.NamespaceField != "" {
+		if value, exists := record.Metadata[m.NamespaceField]; exists {
+			namespace, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("record %s: namespace field '%s' is not a string", record.ID, m.NamespaceField)
+			}
+			record.Namespace = namespace
+			delete(record.Metadata, m.NamespaceField)
+		}
+	}
+
+	if len(m.Fields) > 0 {
+		kept := make(map[string]interface{}, len(m.Fields))
+		for _, field := range m.Fields {
+			if value, exists := record.Metadata[field]; exists {
+				kept[field] = value
+			}
+		}
+		record.Metadata = kept
+	}
+
+	for source, target := range m.Rename {
+		value, exists := record.Metadata[source]
+		if !exists {
+			continue
+		}
+		delete(record.Metadata, source)
+		if target != "" {
+			record.Metadata[target] = value
+		}
+	}
+	return core.ValidateNamespace(record.Namespace)
+}
+
+// jsonReader reads records from JSON lines, a JSON array of records, or JSON
+// objects wrapping records, such as API responses
+type jsonReader struct {
+	file    io.Closer
+	decoder *json.Decoder
+	pending []json.RawMessage // Records of the current array or wrapping object
+	read    int               // Records returned, to locate errors
+
+	// unwrap returns the records wrapped by an object, if it wraps any
+	unwrap func(object json.RawMessage) ([]json.RawMessage, bool, error)
+	// convert turns a record into a vector
+	convert func(record json.RawMessage) (*core.Vector, error)
+}
+
+func (r *jsonReader) Next() (*core.Vector, error) {
+	for len(r.pending) == 0 {
+		var value json.RawMessage
+		if err := r.decoder.Decode(&value); err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("record %d: %w", r.read+1, err)
+		}
+		if value[0] == '[' {
+			if err := json.Unmarshal(value, &r.pending); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		records, wrapped, err := r.unwrap(value)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", r.read+1, err)
+		}
+		if !wrapped {
+			records = []json.RawMessage{value}
+		}
+		r.pending = records
+	}
+
+	record := r.pending[0]
+	r.pending = r.pending[1:]
+	r.read++
+	vector, err := r.convert(record)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", r.read, err)
+	}
+	if vector.Metadata == nil {
+		vector.Metadata = make(map[string]interface{})
+	}
+	return vector, nil
+}
+
+func (r *jsonReader) Close() error {
+	return r.file.Close()
+}
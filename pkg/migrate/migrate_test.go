@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/parquet-go/parquet-go"
+)
+
+// readAll returns every record of a dump
+func readAll(t *testing.T, format, path string, opts *Options) []*core.Vector {
+	t.Helper()
+	reader, err := Open(format, path, opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reader.Close()
+
+	var records []*core.Vector
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestQdrant(t *testing.T) {
+	scroll := writeFile(t, "points.json", `{"result": {"points": [
+		{"id": 7, "vector": [0.1, 0.2], "payload": {"city": "Rome"}},
+		{"id": "5c56c793-69f3-4fbf-87e6-c4bf54c28c26", "vector": [0.3, 0.4], "payload": null}
+	], "next_page_offset": null}, "status": "ok"}`)
+	records := readAll(t, FormatQdrant, scroll, nil)
+	if len(records) != 2 || records[0].ID != "7" || records[0].Metadata["city"] != "Rome" || records[1].ID != "5c56c793-69f3-4fbf-87e6-c4bf54c28c26" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	named := writeFile(t, "points.jsonl", `{"id": 1, "vector": {"image": [1, 0], "text": [0, 1, 0]}}`+"\n")
+	if records := readAll(t, FormatQdrant, named, &Options{VectorName: "text"}); len(records) != 1 || len(records[0].Vector) != 3 {
+		t.Errorf("expected the text vector, got %+v", records)
+	}
+	reader, _ := Open(FormatQdrant, named, nil)
+	if _, err := reader.Next(); err == nil || !strings.Contains(err.Error(), "image, text") {
+		t.Errorf("expected an ambiguous vector error, got %v", err)
+	}
+	reader.Close()
+
+	snapshot := writeFile(t, "collection.snapshot", strings.Repeat("\x00", 257)+"ustar\x0000")
+	if _, err := Open(FormatQdrant, snapshot, nil); err == nil || !strings.Contains(err.Error(), "scroll API") {
+		t.Errorf("expected snapshots to be refused, got %v", err)
+	}
+}
+
+func TestPinecone(t *testing.T) {
+	lines := writeFile(t, "vectors.jsonl", `{"id": "a", "values": [1, 2], "metadata": {"genre": "drama"}, "namespace": "films"}
+{"id": "b", "values": [3, 4], "sparseValues": {"indices": [1], "values": [0.5]}}
+{"vectors": {"d": {"id": "d", "values": [7, 8]}, "c": {"values": [5, 6]}}, "namespace": "books"}
+`)
+	records := readAll(t, FormatPinecone, lines, nil)
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+	if records[0].Namespace != "films" || records[1].Namespace != "" || records[1].Metadata == nil {
+		t.Errorf("unexpected records %+v %+v", records[0], records[1])
+	}
+	if records[2].ID != "c" || records[2].Namespace != "books" || records[3].ID != "d" {
+		t.Errorf("unexpected fetched records %+v %+v", records[2], records[3])
+	}
+}
+
+type chromaCollectionRow struct {
+	UUID string `parquet:"uuid"`
+	Name string `parquet:"name"`
+}
+
+type chromaEmbeddingRow struct {
+	CollectionUUID string    `parquet:"collection_uuid"`
+	UUID           string    `parquet:"uuid"`
+	Embedding      []float64 `parquet:"embedding,list"`
+	Document       *string   `parquet:"document,optional"`
+	ID             string    `parquet:"id"`
+	Metadata       *string   `parquet:"metadata,optional"`
+}
+
+func TestChroma(t *testing.T) {
+	dir := t.TempDir()
+	document, metadata := "The first document", `{"source": "wiki", "page": 3}`
+	err := parquet.WriteFile(filepath.Join(dir, chromaEmbeddingsFile), []chromaEmbeddingRow{
+		{CollectionUUID: "c1", UUID: "u1", Embedding: []float64{0.5, 0.25}, Document: &document, ID: "doc1", Metadata: &metadata},
+		{CollectionUUID: "c2", UUID: "u2", Embedding: []float64{1, 0}, ID: "doc2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parquet.WriteFile(filepath.Join(dir, chromaCollectionsFile), []chromaCollectionRow{{UUID: "c1", Name: "articles"}, {UUID: "c2", Name: "notes"}})
+
+	records := readAll(t, FormatChroma, dir, nil)
+	if len(records) != 2 || records[1].ID != "doc2" || len(records[1].Metadata) != 0 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	records = readAll(t, FormatChroma, filepath.Join(dir, chromaEmbeddingsFile), &Options{Collection: "articles"})
+	if len(records) != 1 || records[0].Vector[1] != 0.25 || records[0].Metadata["_content"] != document || records[0].Metadata["page"] != float64(3) {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if _, err := Open(FormatChroma, dir, &Options{Collection: "missing"}); err == nil {
+		t.Error("expected an unknown collection to be rejected")
+	}
+}
+
+func TestMapping(t *testing.T) {
+	rename, err := ParseRename([]string{"title=name", "internal="})
+	if err != nil {
+		t.Fatalf("ParseRename failed: %v", err)
+	}
+	if _, err := ParseRename([]string{"title"}); err == nil {
+		t.Error("expected a mapping without = to be rejected")
+	}
+
+	mapping := &Mapping{Fields: []string{"title", "internal", "tenant"}, Rename: rename, NamespaceField: "tenant"}
+	record := &core.Vector{ID: "1", Metadata: map[string]interface{}{"title": "Dune", "internal": 1, "year": 1965, "tenant": "acme"}}
+	if err := mapping.Apply(record); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if record.Namespace != "acme" || len(record.Metadata) != 1 || record.Metadata["name"] != "Dune" {
+		t.Errorf("unexpected mapped record %+v", record)
+	}
+}
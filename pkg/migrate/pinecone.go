@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// pineconeVector is a vector as Pinecone upserts, fetches and lists them.
+// Sparse values have no counterpart in a collection and are ignored.
+type pineconeVector struct {
+	ID        string                 `json:"id"`
+	Values    []float32              `json:"values"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	Namespace string                 `json:"namespace"`
+}
+
+// pineconeFetch is the response of a fetch, holding vectors by ID
+type pineconeFetch struct {
+	Vectors   map[string]json.RawMessage `json:"vectors"`
+	Namespace string                     `json:"namespace"`
+}
+
+// openPinecone reads Pinecone vectors, one per line, or fetch responses
+func openPinecone(path string) (Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonReader{
+		file:    file,
+		decoder: json.NewDecoder(file),
+		unwrap: func(object json.RawMessage) ([]json.RawMessage, bool, error) {
+			var fetch pineconeFetch
+			if err := json.Unmarshal(object, &fetch); err != nil || fetch.Vectors == nil {
+				return nil, false, nil
+			}
+			ids := make([]string, 0, len(fetch.Vectors))
+			for id := range fetch.Vectors {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+
+			// The namespace applies to every vector of the response
+			records := make([]json.RawMessage, 0, len(ids))
+			for _, id := range ids {
+				var vector pineconeVector
+				if err := json.Unmarshal(fetch.Vectors[id], &vector); err != nil {
+					return nil, false, fmt.Errorf("vector %s: %w", id, err)
+				}
+				if vector.ID == "" {
+					vector.ID = id
+				}
+				vector.Namespace = fetch.Namespace
+				record, err := json.Marshal(vector)
+				if err != nil {
+					return nil, false, err
+				}
+				records = append(records, record)
+			}
+			return records, true, nil
+		},
+		convert: func(record json.RawMessage) (*core.Vector, error) {
+			var vector pineconeVector
+			if err := json.Unmarshal(record, &vector); err != nil {
+				return nil, err
+			}
+			if vector.ID == "" {
+				return nil, fmt.Errorf("vector has no id")
+			}
+			if len(vector.Values) == 0 {
+				return nil, fmt.Errorf("vector %s has no dense values", vector.ID)
+			}
+			return &core.Vector{ID: vector.ID, Namespace: vector.Namespace, Vector: vector.Values, Metadata: vector.Metadata}, nil
+		},
+	}, nil
+}
@@ -0,0 +1,117 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// qdrantPoint is a point as the Qdrant scroll API returns it
+type qdrantPoint struct {
+	ID      json.RawMessage        `json:"id"`     // Unsigned integer or UUID
+	Vector  json.RawMessage        `json:"vector"` // A vector, or vectors by name
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// qdrantScroll is a response of the scroll API
+type qdrantScroll struct {
+	Result *struct {
+		Points []json.RawMessage `json:"points"`
+	} `json:"result"`
+	Points []json.RawMessage `json:"points"`
+}
+
+// openQdrant reads Qdrant points, one per line, in a JSON array or in scroll
+// responses. Snapshots keep points in the internal storage of their segments,
+// which only Qdrant reads, so they are refused with a way around.
+func openQdrant(path string, opts *Options) (Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tar archives have "ustar" at offset 257
+	header := make([]byte, 262)
+	n, _ := io.ReadFull(file, header)
+	if n == len(header) && bytes.Equal(header[257:], []byte("ustar")) {
+		file.Close()
+		return nil, fmt.Errorf("%s is a Qdrant snapshot, whose segments only Qdrant can read: restore it into a Qdrant instance and dump its points with the scroll API (with_payload and with_vector set)", path)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &jsonReader{
+		file:    file,
+		decoder: json.NewDecoder(file),
+		unwrap: func(object json.RawMessage) ([]json.RawMessage, bool, error) {
+			var scroll qdrantScroll
+			if err := json.Unmarshal(object, &scroll); err != nil {
+				return nil, false, nil
+			}
+			if scroll.Result != nil {
+				return scroll.Result.Points, true, nil
+			}
+			return scroll.Points, scroll.Points != nil, nil
+		},
+		convert: func(record json.RawMessage) (*core.Vector, error) {
+			var point qdrantPoint
+			if err := json.Unmarshal(record, &point); err != nil {
+				return nil, err
+			}
+			return point.toVector(opts.VectorName)
+		},
+	}, nil
+}
+
+// toVector converts a point, taking the named vector when it has several
+func (p *qdrantPoint) toVector(vectorName string) (*core.Vector, error) {
+	var id string
+	if err := json.Unmarshal(p.ID, &id); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(p.ID, &number); err != nil || number == "" {
+			return nil, fmt.Errorf("point has no valid id")
+		}
+		id = number.String()
+	}
+
+	if len(p.Vector) == 0 || string(p.Vector) == "null" {
+		return nil, fmt.Errorf("point %s has no vector: dump points with with_vector set", id)
+	}
+	var values []float32
+	if err := json.Unmarshal(p.Vector, &values); err != nil {
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(p.Vector, &named); err != nil {
+			return nil, fmt.Errorf("point %s: invalid vector", id)
+		}
+		if vectorName == "" {
+			if len(named) != 1 {
+				names := make([]string, 0, len(named))
+				for name := range named {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return nil, fmt.Errorf("point %s has vectors %s: choose one by name", id, strings.Join(names, ", "))
+			}
+			for name := range named {
+				vectorName = name
+			}
+		}
+		vector, exists := named[vectorName]
+		if !exists {
+			return nil, fmt.Errorf("point %s has no vector '%s'", id, vectorName)
+		}
+		if err := json.Unmarshal(vector, &values); err != nil {
+			return nil, fmt.Errorf("point %s: vector '%s' is not a dense vector", id, vectorName)
+		}
+	}
+
+	return &core.Vector{ID: id, Vector: values, Metadata: p.Payload}, nil
+}
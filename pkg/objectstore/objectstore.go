@@ -0,0 +1,328 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// URI schemes naming object storage locations
+const (
+	SchemeS3  = "s3" // Amazon S3 and S3-compatible stores: s3://bucket/prefix
+	SchemeGCS = "gs" // Google Cloud Storage: gs://bucket/prefix
+)
+
+// Default endpoints and regions
+const (
+	defaultS3Region    = "us-east-1"
+	defaultGCSEndpoint = "https://storage.googleapis.com"
+	gcsRegion          = "auto" // GCS accepts any region in HMAC signatures
+)
+
+// Config holds the endpoints and credentials of object stores
+type Config struct {
+	S3  S3Config
+	GCS GCSConfig
+}
+
+// S3Config configures access to Amazon S3 and S3-compatible stores such as
+// MinIO. Unset credentials and region are read from the standard AWS
+// environment variables.
+type S3Config struct {
+	Region          string // Defaults to AWS_REGION, else us-east-1
+	Endpoint        string // Defaults to https://s3.<region>.amazonaws.com
+	AccessKeyID     string // Defaults to AWS_ACCESS_KEY_ID
+	SecretAccessKey string // Defaults to AWS_SECRET_ACCESS_KEY
+	SessionToken    string // Defaults to AWS_SESSION_TOKEN
+	PathStyle       bool   // Address buckets as endpoint/bucket rather than bucket.endpoint
+}
+
+// GCSConfig configures access to Google Cloud Storage through its XML API,
+// with an HMAC key of a service account
+type GCSConfig struct {
+	Endpoint        string // Defaults to https://storage.googleapis.com
+	AccessKeyID     string // HMAC access ID; defaults to GCS_ACCESS_KEY_ID
+	SecretAccessKey string // HMAC secret; defaults to GCS_SECRET_ACCESS_KEY
+}
+
+// Location is a bucket and a key, or key prefix, parsed from a URI
+type Location struct {
+	Scheme string
+	Bucket string
+	Key    string
+}
+
+// String returns the URI of the location
+func (l *Location) String() string {
+	if l.Key == "" {
+		return l.Scheme + "://" + l.Bucket
+	}
+	return l.Scheme + "://" + l.Bucket + "/" + l.Key
+}
+
+// IsURI reports whether s names an object storage location rather than a
+// local path
+func IsURI(s string) bool {
+	return strings.HasPrefix(s, SchemeS3+"://") || strings.HasPrefix(s, SchemeGCS+"://")
+}
+
+// ParseURI parses an s3:// or gs:// URI
+func ParseURI(uri string) (*Location, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok || (scheme != SchemeS3 && scheme != SchemeGCS) {
+		return nil, fmt.Errorf("invalid object storage URI '%s': expected s3://bucket/key or gs://bucket/key", uri)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid object storage URI '%s': bucket is missing", uri)
+	}
+	return &Location{Scheme: scheme, Bucket: bucket, Key: key}, nil
+}
+
+// Object describes a stored object
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Store reads and writes the objects of a bucket. Both S3 and GCS are spoken
+// to with the S3 REST API and Signature Version 4.
+type Store struct {
+	location     Location
+	endpoint     *url.URL
+	pathStyle    bool
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// New returns the store of a location's bucket
+func New(location *Location, config *Config) (*Store, error) {
+	s := &Store{
+		location: Location{Scheme: location.Scheme, Bucket: location.Bucket},
+		client:   &http.Client{},
+	}
+
+	var endpoint string
+	switch location.Scheme {
+	case SchemeS3:
+		s.region = firstNonEmpty(config.S3.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), defaultS3Region)
+		endpoint = firstNonEmpty(config.S3.Endpoint, "https://s3."+s.region+".amazonaws.com")
+		s.pathStyle = config.S3.PathStyle
+		s.accessKey = firstNonEmpty(config.S3.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID"))
+		s.secretKey = firstNonEmpty(config.S3.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+		s.sessionToken = firstNonEmpty(config.S3.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+		if s.accessKey == "" || s.secretKey == "" {
+			return nil, fmt.Errorf("no credentials for %s: set object_storage.s3.access_key_id and secret_access_key, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", location)
+		}
+	case SchemeGCS:
+		s.region = gcsRegion
+		endpoint = firstNonEmpty(config.GCS.Endpoint, defaultGCSEndpoint)
+		s.pathStyle = true
+		s.accessKey = firstNonEmpty(config.GCS.AccessKeyID, os.Getenv("GCS_ACCESS_KEY_ID"))
+		s.secretKey = firstNonEmpty(config.GCS.SecretAccessKey, os.Getenv("GCS_SECRET_ACCESS_KEY"))
+		if s.accessKey == "" || s.secretKey == "" {
+			return nil, fmt.Errorf("no credentials for %s: set object_storage.gcs.access_key_id and secret_access_key, or GCS_ACCESS_KEY_ID and GCS_SECRET_ACCESS_KEY", location)
+		}
+	default:
+		return nil, fmt.Errorf("unknown object storage scheme '%s'", location.Scheme)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid object storage endpoint '%s'", endpoint)
+	}
+	s.endpoint = u
+	return s, nil
+}
+
+// Open parses a URI and returns the store of its bucket and the key it names
+func Open(uri string, config *Config) (*Store, string, error) {
+	location, err := ParseURI(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := New(location, config)
+	if err != nil {
+		return nil, "", err
+	}
+	return s, location.Key, nil
+}
+
+// URI returns the URI of a key in the store
+func (s *Store) URI(key string) string {
+	location := s.location
+	location.Key = key
+	return location.String()
+}
+
+// Put writes an object of size bytes read from body
+func (s *Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	req, err := s.newRequest(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	resp, err := s.do(req, key, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PutFile writes an object with the contents of a local file
+func (s *Store) PutFile(ctx context.Context, key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, key, file, info.Size())
+}
+
+// Get returns the contents of an object, which the caller closes
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, key, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes an object; removing a missing object is not an error
+func (s *Store) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, key, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the answer to a ListObjectsV2 request
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the objects whose keys start with prefix, in key order
+func (s *Store) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, prefix, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid listing of %s: %w", s.URI(prefix), err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{Key: content.Key, Size: content.Size, LastModified: content.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// newRequest builds an unsigned request for a key of the bucket, or for the
+// bucket itself when key is empty
+func (s *Store) newRequest(ctx context.Context, method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	if s.pathStyle {
+		u.Path = base + "/" + s.location.Bucket + "/" + key
+	} else {
+		u.Host = s.location.Bucket + "." + u.Host
+		u.Path = base + "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+// errorResponse is the error document of the S3 API
+type errorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do signs and sends a request about key, turning error statuses into
+// errors
+func (s *Store) do(req *http.Request, key, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", s.URI(""), err)
+	}
+	if resp.StatusCode < 300 || (resp.StatusCode == http.StatusNotFound && req.Method == http.MethodDelete) {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s not found", s.URI(key))
+	}
+	var e errorResponse
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, s.URI(key), e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("%s %s: status %d", req.Method, s.URI(key), resp.StatusCode)
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,394 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// codeDeclaration matches a line starting a top-level declaration. Its
+// "name" group is the symbol; Go methods also have a "receiver" group.
+type codeDeclaration struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+// codeLanguage describes how to find the declarations of a programming
+// language. Top-level declarations are expected to start at column 0, as
+// formatters write them.
+type codeLanguage struct {
+	name         string
+	declarations []codeDeclaration
+	// member matches a method inside a class body, for splitting classes
+	// too large for one chunk
+	member *regexp.Regexp
+	// leading are the prefixes of comment and decorator lines that belong
+	// to the declaration below them
+	leading []string
+}
+
+var (
+	goLanguage = &codeLanguage{
+		name: "go",
+		declarations: []codeDeclaration{
+			{"method", regexp.MustCompile(`^func\s+\(\s*(?:\w+\s+)?\*?(?P<receiver>\w+)(?:\[[^\]]*\])?\s*\)\s*(?P<name>\w+)`)},
+			{"function", regexp.MustCompile(`^func\s+(?P<name>\w+)`)},
+			{"type", regexp.MustCompile(`^type\s+(?P<name>\w+)?`)},
+			{"variable", regexp.MustCompile(`^(?:var|const)\s+(?P<name>\w+)?`)},
+		},
+		leading: []string{"//", "/*", "*"},
+	}
+
+	pythonLanguage = &codeLanguage{
+		name: "python",
+		declarations: []codeDeclaration{
+			{"function", regexp.MustCompile(`^(?:async\s+)?def\s+(?P<name>\w+)`)},
+			{"class", regexp.MustCompile(`^class\s+(?P<name>\w+)`)},
+		},
+		member:  regexp.MustCompile(`^\s+(?:async\s+)?def\s+(?P<name>\w+)`),
+		leading: []string{"#", "@"},
+	}
+
+	// scriptDeclarations cover both JavaScript and TypeScript
+	scriptDeclarations = []codeDeclaration{
+		{"function", regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[\w$]+)`)},
+		{"class", regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`)},
+		{"function", regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[\w$]+\s*=>)`)},
+		{"interface", regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?interface\s+(?P<name>[\w$]+)`)},
+		{"type", regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?type\s+(?P<name>[\w$]+)`)},
+		{"enum", regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(?:const\s+)?enum\s+(?P<name>[\w$]+)`)},
+	}
+	scriptMember = regexp.MustCompile(`^\s+(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?(?P<name>#?[\w$]+)\s*(?:<[^>]*>)?\(`)
+
+	javascriptLanguage = &codeLanguage{
+		name:         "javascript",
+		declarations: scriptDeclarations,
+		member:       scriptMember,
+		leading:      []string{"//", "/*", "*", "@"},
+	}
+
+	typescriptLanguage = &codeLanguage{
+		name:         "typescript",
+		declarations: scriptDeclarations,
+		member:       scriptMember,
+		leading:      []string{"//", "/*", "*", "@"},
+	}
+
+	// codeLanguages maps source file extensions to their language
+	codeLanguages = map[string]*codeLanguage{
+		".go":  goLanguage,
+		".py":  pythonLanguage,
+		".js":  javascriptLanguage,
+		".mjs": javascriptLanguage,
+		".jsx": javascriptLanguage,
+		".ts":  typescriptLanguage,
+		".tsx": typescriptLanguage,
+	}
+
+	// scriptKeywords start statements that look like method calls to
+	// scriptMember, such as "if (ready) {"
+	scriptKeywords = map[string]bool{
+		"if": true, "for": true, "while": true, "switch": true, "catch": true,
+		"return": true, "function": true, "super": true, "this": true,
+	}
+)
+
+// symbolBlock is a run of source lines holding one declaration, with its
+// leading comments, or the code between declarations
+type symbolBlock struct {
+	symbol string // Declared name, "Type.Method" for methods
+	kind   string // function, method, class, type, ... or "module"
+	start  int    // Index of the first line
+	end    int    // Index after the last line
+}
+
+// CodeProcessor handles source code files, chunking them along function and
+// class boundaries
+type CodeProcessor struct{}
+
+// NewCodeProcessor creates a new source code processor
+func NewCodeProcessor() *CodeProcessor {
+	return &CodeProcessor{}
+}
+
+// ProcessDocument processes a source file, one chunk per top-level
+// declaration. Declarations longer than the maximum chunk size are split by
+// method for classes, else by lines.
+func (p *CodeProcessor) ProcessDocument(reader io.Reader, filename string, config *ProcessingConfig) (*Document, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+
+	language, ok := codeLanguages[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported source file: %s", filename)
+	}
+
+	code := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if strings.TrimSpace(code) == "" {
+		return nil, fmt.Errorf("source file contains no code")
+	}
+	lines := strings.Split(code, "\n")
+
+	doc := &Document{
+		ID:          generateDocumentID(filename),
+		Title:       filepath.Base(filename),
+		Content:     code,
+		Type:        DocumentTypeCode,
+		Size:        int64(len(content)),
+		Language:    config.Language,
+		Metadata:    make(map[string]string),
+		ProcessedAt: time.Now(),
+	}
+
+	for k, v := range config.Metadata {
+		doc.Metadata[k] = v
+	}
+	doc.Metadata["filename"] = filename
+	doc.Metadata["file_extension"] = filepath.Ext(filename)
+	doc.Metadata["language"] = language.name
+	doc.Metadata["line_count"] = fmt.Sprintf("%d", len(lines))
+	doc.Metadata["char_count"] = fmt.Sprintf("%d", len(code))
+
+	blocks := language.blocks(lines)
+	symbols := 0
+	for _, block := range blocks {
+		if block.symbol != "" {
+			symbols++
+		}
+	}
+	doc.Metadata["symbol_count"] = fmt.Sprintf("%d", symbols)
+
+	limit := config.MaxChunkSize
+	if limit < config.ChunkSize {
+		limit = config.ChunkSize
+	}
+
+	var chunks []DocumentChunk
+	for _, block := range blocks {
+		for _, part := range language.split(lines, block, limit, config.ChunkSize) {
+			text := strings.Join(lines[part.start:part.end], "\n")
+			for _, piece := range splitLongLine(text, limit) {
+				position := len(chunks)
+				chunk := DocumentChunk{
+					ID:       fmt.Sprintf("%s_chunk_%d", doc.ID, position),
+					Content:  piece,
+					Position: position,
+					Size:     len(piece),
+					Metadata: map[string]string{
+						"chunk_type":     "code",
+						"boundary_type":  "symbol",
+						"language":       language.name,
+						"symbol_kind":    part.kind,
+						"start_line":     fmt.Sprintf("%d", part.start+1),
+						"end_line":       fmt.Sprintf("%d", part.end),
+						"char_count":     fmt.Sprintf("%d", len(piece)),
+						"document_id":    doc.ID,
+						"document_title": doc.Title,
+						"document_type":  string(doc.Type),
+					},
+				}
+				if part.symbol != "" {
+					chunk.Metadata["symbol"] = part.symbol
+				}
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+	doc.Chunks = chunks
+
+	return doc, nil
+}
+
+// SupportedTypes returns the document types this processor handles
+func (p *CodeProcessor) SupportedTypes() []DocumentType {
+	return []DocumentType{DocumentTypeCode}
+}
+
+// ExtractText returns the source code unchanged
+func (p *CodeProcessor) ExtractText(reader io.Reader) (string, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// ExtractMetadata returns no metadata; the language comes from the file name
+func (p *CodeProcessor) ExtractMetadata(reader io.Reader) (map[string]string, error) {
+	return make(map[string]string), nil
+}
+
+// declaration returns the kind and symbol of the declaration line starts,
+// if any
+func (l *codeLanguage) declaration(line string) (string, string, bool) {
+	for _, d := range l.declarations {
+		match := d.pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		symbol := match[d.pattern.SubexpIndex("name")]
+		if i := d.pattern.SubexpIndex("receiver"); i >= 0 && match[i] != "" {
+			symbol = match[i] + "." + symbol
+		}
+		return d.kind, symbol, true
+	}
+	return "", "", false
+}
+
+// isLeading reports whether line is a comment or decorator that belongs to
+// the declaration below it
+func (l *codeLanguage) isLeading(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, prefix := range l.leading {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// blocks splits source lines into declarations, each starting at its leading
+// comments and running to the next declaration, and the code between them
+func (l *codeLanguage) blocks(lines []string) []symbolBlock {
+	var blocks []symbolBlock
+	current := symbolBlock{kind: "module"}
+
+	for i, line := range lines {
+		kind, symbol, ok := l.declaration(line)
+		if !ok {
+			continue
+		}
+		start := i
+		for start > current.start && l.isLeading(lines[start-1]) {
+			start--
+		}
+		current.end = start
+		blocks = append(blocks, current)
+		current = symbolBlock{symbol: symbol, kind: kind, start: start}
+	}
+	current.end = len(lines)
+	blocks = append(blocks, current)
+
+	// Drop blank blocks and the blank lines ending blocks
+	kept := blocks[:0]
+	for _, block := range blocks {
+		for block.end > block.start && strings.TrimSpace(lines[block.end-1]) == "" {
+			block.end--
+		}
+		for block.start < block.end && strings.TrimSpace(lines[block.start]) == "" {
+			block.start++
+		}
+		if block.end > block.start {
+			kept = append(kept, block)
+		}
+	}
+	return kept
+}
+
+// split divides a block longer than limit: a class at its methods, anything
+// else, and methods still too long, into runs of lines up to size
+func (l *codeLanguage) split(lines []string, block symbolBlock, limit, size int) []symbolBlock {
+	if blockLength(lines, block) <= limit {
+		return []symbolBlock{block}
+	}
+
+	if block.kind == "class" && l.member != nil {
+		if members := l.members(lines, block); len(members) > 1 {
+			var parts []symbolBlock
+			for _, member := range members {
+				parts = append(parts, l.split(lines, member, limit, size)...)
+			}
+			return parts
+		}
+	}
+
+	var parts []symbolBlock
+	part := symbolBlock{symbol: block.symbol, kind: block.kind, start: block.start}
+	length := 0
+	for i := block.start; i < block.end; i++ {
+		if length > 0 && length+len(lines[i])+1 > size {
+			part.end = i
+			parts = append(parts, part)
+			part.start = i
+			length = 0
+		}
+		length += len(lines[i]) + 1
+	}
+	part.end = block.end
+	return append(parts, part)
+}
+
+// members splits a class at the methods of its body, the first part holding
+// the class header and anything before its first method
+func (l *codeLanguage) members(lines []string, class symbolBlock) []symbolBlock {
+	// Methods sit at the indentation of the first line of the body
+	indent := -1
+	for i := class.start + 1; i < class.end; i++ {
+		if trimmed := strings.TrimLeft(lines[i], " \t"); trimmed != "" && len(trimmed) < len(lines[i]) {
+			indent = len(lines[i]) - len(trimmed)
+			break
+		}
+	}
+	if indent < 0 {
+		return nil
+	}
+
+	members := []symbolBlock{{symbol: class.symbol, kind: class.kind, start: class.start}}
+	for i := class.start + 1; i < class.end; i++ {
+		line := lines[i]
+		if len(line)-len(strings.TrimLeft(line, " \t")) != indent {
+			continue
+		}
+		match := l.member.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		name := match[l.member.SubexpIndex("name")]
+		if scriptKeywords[name] {
+			continue
+		}
+		start := i
+		previous := &members[len(members)-1]
+		for start > previous.start+1 && l.isLeading(lines[start-1]) {
+			start--
+		}
+		previous.end = start
+		members = append(members, symbolBlock{symbol: class.symbol + "." + name, kind: "method", start: start})
+	}
+	members[len(members)-1].end = class.end
+	return members
+}
+
+// blockLength returns the characters of a block's lines
+func blockLength(lines []string, block symbolBlock) int {
+	length := 0
+	for i := block.start; i < block.end; i++ {
+		length += len(lines[i]) + 1
+	}
+	return length
+}
+
+// splitLongLine cuts text longer than limit that has no lines to split at,
+// such as minified code
+func splitLongLine(text string, limit int) []string {
+	if len(text) <= limit || strings.Contains(text, "\n") || limit <= 0 {
+		return []string{text}
+	}
+	var pieces []string
+	for len(text) > limit {
+		// Cut at the start of a character
+		cut := limit
+		for cut > 1 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		pieces = append(pieces, text[:cut])
+		text = text[cut:]
+	}
+	return append(pieces, text)
+}
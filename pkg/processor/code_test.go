@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCodeProcessor_ChunksBySymbol(t *testing.T) {
+	source := `package shapes
+
+import "math"
+
+// Circle is a round shape
+type Circle struct {
+	Radius float64
+}
+
+// Area returns the area of the circle
+func (c *Circle) Area() float64 {
+	return math.Pi * c.Radius * c.Radius
+}
+
+func NewCircle(radius float64) *Circle {
+	return &Circle{Radius: radius}
+}
+`
+	doc, err := NewCodeProcessor().ProcessDocument(strings.NewReader(source), "shapes.go", DefaultProcessingConfig())
+	if err != nil {
+		t.Fatalf("ProcessDocument failed: %v", err)
+	}
+
+	want := []struct{ symbol, kind, start string }{
+		{"", "module", "1"},
+		{"Circle", "type", "5"},
+		{"Circle.Area", "method", "10"},
+		{"NewCircle", "function", "15"},
+	}
+	if len(doc.Chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %d", len(want), len(doc.Chunks))
+	}
+	for i, chunk := range doc.Chunks {
+		if chunk.Metadata["symbol"] != want[i].symbol || chunk.Metadata["symbol_kind"] != want[i].kind || chunk.Metadata["start_line"] != want[i].start {
+			t.Errorf("chunk %d: symbol %q (%s) at line %s, want %q (%s) at line %s", i,
+				chunk.Metadata["symbol"], chunk.Metadata["symbol_kind"], chunk.Metadata["start_line"], want[i].symbol, want[i].kind, want[i].start)
+		}
+		if chunk.Metadata["language"] != "go" {
+			t.Errorf("chunk %d: language = %q", i, chunk.Metadata["language"])
+		}
+	}
+	if !strings.HasPrefix(doc.Chunks[2].Content, "// Area returns") || !strings.HasSuffix(doc.Chunks[2].Content, "}") {
+		t.Errorf("method chunk should hold its comment and whole body: %q", doc.Chunks[2].Content)
+	}
+
+	// A class too large for one chunk is split at its methods
+	var python strings.Builder
+	python.WriteString("class Store:\n    \"\"\"Keeps items.\"\"\"\n\n")
+	for _, name := range []string{"get", "put", "delete"} {
+		python.WriteString("    @traced\n    def " + name + "(self, key):\n")
+		python.WriteString(strings.Repeat("        self.log(key)\n", 30))
+	}
+	config := &ProcessingConfig{ChunkSize: 400, MaxChunkSize: 800, MinChunkSize: 10}
+	doc, err = NewCodeProcessor().ProcessDocument(strings.NewReader(python.String()), "store.py", config)
+	if err != nil {
+		t.Fatalf("ProcessDocument failed: %v", err)
+	}
+	symbols := []string{"Store", "Store.get", "Store.put", "Store.delete"}
+	if len(doc.Chunks) != len(symbols) {
+		t.Fatalf("expected %d chunks, got %d", len(symbols), len(doc.Chunks))
+	}
+	for i, chunk := range doc.Chunks {
+		if chunk.Metadata["symbol"] != symbols[i] {
+			t.Errorf("chunk %d: symbol = %q, want %q", i, chunk.Metadata["symbol"], symbols[i])
+		}
+	}
+	if !strings.HasPrefix(strings.TrimSpace(doc.Chunks[1].Content), "@traced") {
+		t.Errorf("method chunk should start at its decorator: %q", doc.Chunks[1].Content)
+	}
+}
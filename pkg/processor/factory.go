@@ -22,6 +22,7 @@ func NewProcessorFactory() *ProcessorFactory {
 	factory.RegisterProcessor(NewHTMLProcessor())
 	factory.RegisterProcessor(NewPDFProcessor())
 	factory.RegisterProcessor(NewDOCXProcessor())
+	factory.RegisterProcessor(NewCodeProcessor())
 
 	return factory
 }
@@ -67,6 +68,8 @@ func (f *ProcessorFactory) DetectDocumentType(filename string) DocumentType {
 		return DocumentTypeHTML
 	case ".rtf":
 		return DocumentTypeRTF
+	case ".go", ".py", ".js", ".mjs", ".jsx", ".ts", ".tsx":
+		return DocumentTypeCode
 	default:
 		// Default to text for unknown extensions
 		return DocumentTypeTXT
@@ -107,6 +110,13 @@ func (f *ProcessorFactory) GetSupportedExtensions() []string {
 		".html",     // HTML documents
 		".htm",      // HTML documents (alternative)
 		".rtf",      // Rich Text Format (placeholder)
+		".go",       // Go source
+		".py",       // Python source
+		".js",       // JavaScript source
+		".mjs",      // JavaScript modules
+		".jsx",      // JavaScript with JSX
+		".ts",       // TypeScript source
+		".tsx",      // TypeScript with JSX
 	}
 }
 
@@ -163,6 +173,12 @@ func (f *ProcessorFactory) GetProcessorInfo() []ProcessorInfo {
 			Description: "Rich Text Format documents (not yet implemented)",
 			Status:      "not_implemented",
 		},
+		{
+			Type:        DocumentTypeCode,
+			Extensions:  []string{".go", ".py", ".js", ".mjs", ".jsx", ".ts", ".tsx"},
+			Description: "Source code chunked by function and class, with language and symbol metadata",
+			Status:      "fully_implemented",
+		},
 	}
 
 	return info
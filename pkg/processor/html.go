@@ -11,13 +11,13 @@ import (
 
 // HTMLProcessor handles HTML documents
 type HTMLProcessor struct {
-	chunker ChunkingStrategy
+	chunker *SmartChunker
 }
 
 // NewHTMLProcessor creates a new HTML processor
 func NewHTMLProcessor() *HTMLProcessor {
 	return &HTMLProcessor{
-		chunker: NewSentenceChunker(),
+		chunker: NewSmartChunker(),
 	}
 }
 
@@ -69,8 +69,8 @@ func (p *HTMLProcessor) ProcessDocument(reader io.Reader, filename string, confi
 	// Extract HTML-specific metadata
 	p.extractHTMLMetadata(html, doc)
 
-	// Chunk the document
-	chunks, err := p.chunker.ChunkText(text, config)
+	// Chunk the document by its headings
+	chunks, err := p.chunker.ChunkHTML(html, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to chunk HTML document: %w", err)
 	}
@@ -100,8 +100,11 @@ func (p *HTMLProcessor) ExtractText(reader io.Reader) (string, error) {
 		return "", fmt.Errorf("failed to read HTML: %w", err)
 	}
 
-	html := string(content)
+	return htmlToText(string(content)), nil
+}
 
+// stripHTMLNonContent removes script and style elements and comments
+func stripHTMLNonContent(html string) string {
 	// Remove script and style tags with their content
 	scriptRegex := regexp.MustCompile(`(?i)<script[^>]*>.*?</script>`)
 	html = scriptRegex.ReplaceAllString(html, "")
@@ -111,19 +114,22 @@ func (p *HTMLProcessor) ExtractText(reader io.Reader) (string, error) {
 
 	// Remove HTML comments
 	commentRegex := regexp.MustCompile(`<!--.*?-->`)
-	html = commentRegex.ReplaceAllString(html, "")
+	return commentRegex.ReplaceAllString(html, "")
+}
+
+// htmlToText returns the text content of HTML
+func htmlToText(html string) string {
+	html = stripHTMLNonContent(html)
 
 	// Convert common HTML entities
-	html = p.decodeHTMLEntities(html)
+	html = decodeHTMLEntities(html)
 
 	// Remove all HTML tags
 	tagRegex := regexp.MustCompile(`<[^>]*>`)
 	text := tagRegex.ReplaceAllString(html, " ")
 
 	// Clean up whitespace
-	text = cleanText(text)
-
-	return text, nil
+	return cleanText(text)
 }
 
 // ExtractMetadata extracts metadata from HTML
@@ -175,7 +181,7 @@ func (p *HTMLProcessor) extractHTMLTitle(html, filename string) string {
 	if len(matches) > 1 {
 		title := strings.TrimSpace(matches[1])
 		if title != "" {
-			return p.decodeHTMLEntities(title)
+			return decodeHTMLEntities(title)
 		}
 	}
 
@@ -188,7 +194,7 @@ func (p *HTMLProcessor) extractHTMLTitle(html, filename string) string {
 		title := tagRegex.ReplaceAllString(matches[1], "")
 		title = strings.TrimSpace(title)
 		if title != "" {
-			return p.decodeHTMLEntities(title)
+			return decodeHTMLEntities(title)
 		}
 	}
 
@@ -246,7 +252,7 @@ func (p *HTMLProcessor) parseHTMLAttributes(attrString string) map[string]string
 }
 
 // decodeHTMLEntities decodes common HTML entities
-func (p *HTMLProcessor) decodeHTMLEntities(text string) string {
+func decodeHTMLEntities(text string) string {
 	// Common HTML entities
 	entities := map[string]string{
 		"&amp;":    "&",
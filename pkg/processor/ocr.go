@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OCR defaults
+const (
+	defaultOCRCommand  = "tesseract"
+	defaultOCRRenderer = "pdftoppm"
+	defaultOCRLanguage = "eng"
+	defaultOCRDPI      = 300
+	defaultOCRTimeout  = 60 * time.Second
+)
+
+// OCRConfig enables optical character recognition of PDF pages without
+// extractable text, such as scanned pages. Pages are recognized either by an
+// external OCR service, when URL is set, or by rendering them with pdftoppm
+// and reading them with tesseract.
+type OCRConfig struct {
+	Enabled  bool          `json:"enabled"`
+	URL      string        `json:"url,omitempty"`      // OCR service each page is sent to
+	Command  string        `json:"command,omitempty"`  // tesseract binary (default "tesseract")
+	Renderer string        `json:"renderer,omitempty"` // Binary rendering pages to images (default "pdftoppm")
+	Language string        `json:"language,omitempty"` // Tesseract language codes, such as "eng+ita" (default "eng")
+	DPI      int           `json:"dpi,omitempty"`      // Page rendering resolution (default 300)
+	Timeout  time.Duration `json:"timeout,omitempty"`  // Per page (default 60s)
+}
+
+// OCREngine recognizes the text of PDF pages
+type OCREngine interface {
+	// RecognizePage returns the text of a page of a PDF document, numbered from 1
+	RecognizePage(ctx context.Context, document []byte, page int) (string, error)
+}
+
+// NewOCREngine creates the OCR engine described by config
+func NewOCREngine(config *OCRConfig) OCREngine {
+	language := config.Language
+	if language == "" {
+		language = defaultOCRLanguage
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultOCRTimeout
+	}
+
+	if config.URL != "" {
+		return &serviceOCR{
+			url:      config.URL,
+			language: language,
+			client:   &http.Client{Timeout: timeout},
+		}
+	}
+
+	command := config.Command
+	if command == "" {
+		command = defaultOCRCommand
+	}
+	renderer := config.Renderer
+	if renderer == "" {
+		renderer = defaultOCRRenderer
+	}
+	dpi := config.DPI
+	if dpi <= 0 {
+		dpi = defaultOCRDPI
+	}
+	return &commandOCR{
+		command:  command,
+		renderer: renderer,
+		language: language,
+		dpi:      dpi,
+		timeout:  timeout,
+	}
+}
+
+// serviceOCR sends each page to an OCR service as
+// "POST <url>?page=N&language=L" with the PDF as the body, and expects
+// {"text": "..."} back
+type serviceOCR struct {
+	url      string
+	language string
+	client   *http.Client
+}
+
+// RecognizePage sends the document to the OCR service for one page
+func (o *serviceOCR) RecognizePage(ctx context.Context, document []byte, page int) (string, error) {
+	target, err := url.Parse(o.url)
+	if err != nil {
+		return "", fmt.Errorf("invalid OCR service URL: %w", err)
+	}
+	query := target.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("language", o.language)
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", target.String(), bytes.NewReader(document))
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCR request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/pdf")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OCR service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read OCR response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OCR service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse OCR response: %w", err)
+	}
+	return result.Text, nil
+}
+
+// commandOCR renders a page to an image with pdftoppm and reads it with
+// tesseract
+type commandOCR struct {
+	command  string
+	renderer string
+	language string
+	dpi      int
+	timeout  time.Duration
+}
+
+// RecognizePage renders and recognizes one page
+func (o *commandOCR) RecognizePage(ctx context.Context, document []byte, page int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "vittoriadb-ocr-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCR directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pdfPath := filepath.Join(dir, "document.pdf")
+	if err := os.WriteFile(pdfPath, document, 0600); err != nil {
+		return "", fmt.Errorf("failed to write PDF for OCR: %w", err)
+	}
+
+	// -singlefile writes <prefix>.png without a page number suffix
+	pageNumber := strconv.Itoa(page)
+	imagePrefix := filepath.Join(dir, "page")
+	render := exec.CommandContext(ctx, o.renderer, "-f", pageNumber, "-l", pageNumber,
+		"-r", strconv.Itoa(o.dpi), "-png", "-singlefile", pdfPath, imagePrefix)
+	if output, err := render.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to render page %d with %s: %w: %s", page, o.renderer, err, strings.TrimSpace(string(output)))
+	}
+
+	var stdout, stderr bytes.Buffer
+	recognize := exec.CommandContext(ctx, o.command, imagePrefix+".png", "stdout", "-l", o.language)
+	recognize.Stdout = &stdout
+	recognize.Stderr = &stderr
+	if err := recognize.Run(); err != nil {
+		return "", fmt.Errorf("failed to recognize page %d with %s: %w: %s", page, o.command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
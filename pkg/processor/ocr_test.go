@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// pageOCR is an OCR engine returning a fixed text per page
+type pageOCR map[int]string
+
+func (o pageOCR) RecognizePage(ctx context.Context, document []byte, page int) (string, error) {
+	return o[page], nil
+}
+
+// blankPDF returns a PDF document with one page without text
+func blankPDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestPDFProcessor_OCRFallback(t *testing.T) {
+	p := NewPDFProcessor()
+	p.SetOCREngine(pageOCR{1: "Scanned invoice number 42. Total due is one hundred euros."})
+	config := DefaultProcessingConfig()
+
+	if _, err := p.ProcessDocument(bytes.NewReader(blankPDF()), "scan.pdf", config); err == nil || !strings.Contains(err.Error(), "enable OCR") {
+		t.Fatalf("expected an error suggesting OCR, got %v", err)
+	}
+
+	config.OCR = &OCRConfig{Enabled: true}
+	doc, err := p.ProcessDocument(bytes.NewReader(blankPDF()), "scan.pdf", config)
+	if err != nil {
+		t.Fatalf("ProcessDocument with OCR failed: %v", err)
+	}
+	if !strings.Contains(doc.Content, "invoice number 42") {
+		t.Errorf("expected recognized text in content, got %q", doc.Content)
+	}
+	if doc.Metadata["ocr_pages"] != "1" || doc.Metadata["extraction_method"] != "library_based+ocr" {
+		t.Errorf("unexpected OCR metadata: %v", doc.Metadata)
+	}
+}
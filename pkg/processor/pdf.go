@@ -2,6 +2,7 @@ package processor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 // PDFProcessor handles PDF documents using github.com/ledongthuc/pdf
 type PDFProcessor struct {
 	chunker ChunkingStrategy
+	ocr     OCREngine // Overrides the engine built from the processing config
 }
 
 // NewPDFProcessor creates a new PDF processor
@@ -24,6 +26,12 @@ func NewPDFProcessor() *PDFProcessor {
 	}
 }
 
+// SetOCREngine sets the engine recognizing pages without extractable text
+// when OCR is enabled, in place of the one the processing config describes
+func (p *PDFProcessor) SetOCREngine(engine OCREngine) {
+	p.ocr = engine
+}
+
 // ProcessDocument processes a PDF document
 func (p *PDFProcessor) ProcessDocument(reader io.Reader, filename string, config *ProcessingConfig) (*Document, error) {
 	// Read PDF content
@@ -32,13 +40,26 @@ func (p *PDFProcessor) ProcessDocument(reader io.Reader, filename string, config
 		return nil, fmt.Errorf("failed to read PDF document: %w", err)
 	}
 
-	// Extract text from PDF (placeholder implementation)
-	text, err := p.ExtractText(strings.NewReader(string(content)))
+	pages, err := p.extractPages(content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract text from PDF: %w", err)
 	}
 
+	// Scanned pages have no text layer: read them with OCR
+	ocrPages := 0
+	var ocrErr error
+	if config.OCR != nil && config.OCR.Enabled {
+		ocrPages, ocrErr = p.recognizeEmptyPages(content, pages, config.OCR)
+	}
+
+	text := joinPages(pages)
 	if text == "" {
+		if ocrErr != nil {
+			return nil, fmt.Errorf("PDF document contains no readable text: %w", ocrErr)
+		}
+		if config.OCR == nil || !config.OCR.Enabled {
+			return nil, fmt.Errorf("PDF document contains no readable text (enable OCR to read scanned pages)")
+		}
 		return nil, fmt.Errorf("PDF document contains no readable text")
 	}
 
@@ -69,6 +90,13 @@ func (p *PDFProcessor) ProcessDocument(reader io.Reader, filename string, config
 
 	// Extract PDF-specific metadata (placeholder)
 	p.extractPDFMetadata(string(content), doc)
+	if ocrPages > 0 {
+		doc.Metadata["extraction_method"] = "library_based+ocr"
+		doc.Metadata["ocr_pages"] = strconv.Itoa(ocrPages)
+	}
+	if ocrErr != nil {
+		doc.Metadata["ocr_error"] = ocrErr.Error()
+	}
 
 	// Chunk the document
 	chunks, err := p.chunker.ChunkText(text, config)
@@ -101,14 +129,30 @@ func (p *PDFProcessor) ExtractText(reader io.Reader) (string, error) {
 		return "", fmt.Errorf("failed to read PDF: %w", err)
 	}
 
+	pages, err := p.extractPages(content)
+	if err != nil {
+		return "", err
+	}
+
+	text := joinPages(pages)
+	if text == "" {
+		return "", fmt.Errorf("no readable text found in PDF")
+	}
+
+	return text, nil
+}
+
+// extractPages returns the text of each page, empty for pages without a
+// text layer or whose text cannot be extracted
+func (p *PDFProcessor) extractPages(content []byte) ([]string, error) {
 	// Create PDF reader
 	pdfReader, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
 	if err != nil {
-		return "", fmt.Errorf("failed to parse PDF: %w", err)
+		return nil, fmt.Errorf("failed to parse PDF: %w", err)
 	}
 
-	var textBuilder strings.Builder
 	numPages := pdfReader.NumPage()
+	pages := make([]string, numPages)
 
 	// Extract text from each page
 	for i := 1; i <= numPages; i++ {
@@ -120,26 +164,62 @@ func (p *PDFProcessor) ExtractText(reader io.Reader) (string, error) {
 		// Get plain text from page (pass empty font map for basic extraction)
 		pageText, err := page.GetPlainText(nil)
 		if err != nil {
-			// Log error but continue with other pages
+			// Leave the page empty and continue with other pages
 			continue
 		}
 
-		// Clean and add page text
-		pageText = strings.TrimSpace(pageText)
-		if pageText != "" {
-			if textBuilder.Len() > 0 {
-				textBuilder.WriteString("\n\n")
+		pages[i-1] = strings.TrimSpace(pageText)
+	}
+
+	return pages, nil
+}
+
+// recognizeEmptyPages fills in the pages without text using OCR and returns
+// how many it recognized. Pages that fail are left empty and the first
+// failure is returned.
+func (p *PDFProcessor) recognizeEmptyPages(content []byte, pages []string, config *OCRConfig) (int, error) {
+	engine := p.ocr
+	if engine == nil {
+		engine = NewOCREngine(config)
+	}
+
+	recognized := 0
+	var firstErr error
+	for i, text := range pages {
+		if text != "" {
+			continue
+		}
+		pageText, err := engine.RecognizePage(context.Background(), content, i+1)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("OCR of page %d failed: %w", i+1, err)
 			}
-			textBuilder.WriteString(pageText)
+			continue
+		}
+		if pageText = strings.TrimSpace(pageText); pageText != "" {
+			pages[i] = pageText
+			recognized++
 		}
 	}
+	return recognized, firstErr
+}
 
-	text := textBuilder.String()
-	if text == "" {
-		return "", fmt.Errorf("no readable text found in PDF")
+// joinPages joins the non-empty pages into the document text
+func joinPages(pages []string) string {
+	var textBuilder strings.Builder
+	for _, pageText := range pages {
+		if pageText == "" {
+			continue
+		}
+		if textBuilder.Len() > 0 {
+			textBuilder.WriteString("\n\n")
+		}
+		textBuilder.WriteString(pageText)
 	}
-
-	return cleanText(text), nil
+	if textBuilder.Len() == 0 {
+		return ""
+	}
+	return cleanText(textBuilder.String())
 }
 
 // ExtractMetadata extracts metadata from PDF
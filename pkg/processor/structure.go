@@ -0,0 +1,197 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// HeadingPathSeparator joins the headings of a chunk's heading_path metadata
+const HeadingPathSeparator = " > "
+
+var (
+	// markdownHeadingPattern matches ATX headings ("## Installation ##")
+	markdownHeadingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	// markdownFencePattern matches the lines opening and closing code blocks
+	markdownFencePattern = regexp.MustCompile("^ {0,3}(```|~~~)")
+	// htmlHeadingPattern matches <h1> to <h6> elements
+	htmlHeadingPattern = regexp.MustCompile(`(?is)<h([1-6])\b[^>]*>(.*?)</h[1-6]\s*>`)
+	// htmlHeadPattern matches the <head> element, whose title is not content
+	htmlHeadPattern = regexp.MustCompile(`(?is)<head\b.*?</head\s*>`)
+)
+
+// section is the text under a heading, up to the next heading of any level
+type section struct {
+	path  []string // Headings from the top level down to this section's
+	level int      // Heading level, 0 before the first heading
+	text  string   // Body text, without the heading
+}
+
+// ChunkMarkdown splits a Markdown document by its heading hierarchy, so that
+// chunks never span two sections, and records each chunk's headings in
+// heading_path metadata (such as "Chapter 2 > Installation"). Documents
+// without headings are chunked with ChunkText.
+func (sc *SmartChunker) ChunkMarkdown(markdown string, config *ProcessingConfig) ([]DocumentChunk, error) {
+	sections := markdownSections(markdown)
+	if len(sections) == 0 || (len(sections) == 1 && sections[0].level == 0) {
+		return sc.ChunkText(cleanText(markdown), config)
+	}
+	return sc.chunkSections(sections, "structured_markdown", config)
+}
+
+// ChunkHTML splits an HTML document by its <h1> to <h6> headings, like
+// ChunkMarkdown does for Markdown
+func (sc *SmartChunker) ChunkHTML(html string, config *ProcessingConfig) ([]DocumentChunk, error) {
+	sections := htmlSections(html)
+	if len(sections) == 0 || (len(sections) == 1 && sections[0].level == 0) {
+		return sc.ChunkText(htmlToText(html), config)
+	}
+	return sc.chunkSections(sections, "structured_html", config)
+}
+
+// chunkSections turns each section into one chunk, or into several when it is
+// longer than the chunk size, prefixed with the section's heading
+func (sc *SmartChunker) chunkSections(sections []section, chunkType string, config *ProcessingConfig) ([]DocumentChunk, error) {
+	var chunks []DocumentChunk
+	for _, s := range sections {
+		body := cleanText(s.text)
+		if body == "" {
+			// Heading without text of its own, such as a chapter title
+			// followed by its first subsection
+			continue
+		}
+
+		content := body
+		if s.level > 0 {
+			content = s.path[len(s.path)-1] + "\n\n" + body
+		}
+
+		var parts []DocumentChunk
+		if len(content) > config.ChunkSize {
+			var err error
+			if parts, err = sc.ChunkText(content, config); err != nil {
+				return nil, err
+			}
+		}
+		if len(parts) == 0 {
+			parts = []DocumentChunk{sc.createChunk(content, 0, chunkType, nil)}
+		}
+
+		for i := range parts {
+			position := len(chunks)
+			parts[i].ID = fmt.Sprintf("chunk_%d", position)
+			parts[i].Position = position
+			parts[i].Metadata["chunk_type"] = chunkType
+			parts[i].Metadata["boundary_type"] = "section"
+			if s.level > 0 {
+				parts[i].Metadata["heading"] = s.path[len(s.path)-1]
+				parts[i].Metadata["heading_level"] = fmt.Sprintf("%d", s.level)
+				parts[i].Metadata["heading_path"] = strings.Join(s.path, HeadingPathSeparator)
+			}
+			chunks = append(chunks, parts[i])
+		}
+	}
+	return chunks, nil
+}
+
+// headingStack tracks the headings enclosing the current position
+type headingStack struct {
+	levels   []int
+	headings []string
+}
+
+// push enters a heading, leaving the sections of the same or deeper levels,
+// and returns the path to it
+func (h *headingStack) push(level int, heading string) []string {
+	n := len(h.levels)
+	for n > 0 && h.levels[n-1] >= level {
+		n--
+	}
+	h.levels = append(h.levels[:n], level)
+	h.headings = append(h.headings[:n], heading)
+	return append([]string(nil), h.headings...)
+}
+
+// markdownSections splits Markdown into sections at ATX headings, skipping
+// YAML frontmatter and ignoring "#" lines inside code blocks
+func markdownSections(markdown string) []section {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+
+	// Frontmatter is document metadata, not content
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+
+	var sections []section
+	var stack headingStack
+	current := section{}
+	var body []string
+	fence := ""
+
+	for _, line := range lines {
+		if match := markdownFencePattern.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if fence == match[1] {
+				fence = ""
+			}
+		}
+
+		if fence == "" {
+			if match := markdownHeadingPattern.FindStringSubmatch(line); match != nil {
+				if heading := strings.TrimSpace(match[2]); heading != "" {
+					current.text = strings.Join(body, "\n")
+					sections = append(sections, current)
+					level := len(match[1])
+					current = section{path: stack.push(level, heading), level: level}
+					body = body[:0]
+					continue
+				}
+			}
+		}
+		body = append(body, line)
+	}
+	current.text = strings.Join(body, "\n")
+	sections = append(sections, current)
+
+	// The text before the first heading is only a section when it has content
+	if strings.TrimSpace(sections[0].text) == "" {
+		sections = sections[1:]
+	}
+	return sections
+}
+
+// htmlSections splits HTML into sections at <h1> to <h6> headings
+func htmlSections(html string) []section {
+	html = htmlHeadPattern.ReplaceAllString(stripHTMLNonContent(html), "")
+
+	var sections []section
+	var stack headingStack
+	current := section{}
+	start := 0
+
+	for _, match := range htmlHeadingPattern.FindAllStringSubmatchIndex(html, -1) {
+		heading := htmlToText(html[match[4]:match[5]])
+		if heading == "" {
+			continue
+		}
+		current.text = htmlToText(html[start:match[0]])
+		sections = append(sections, current)
+
+		level := int(html[match[2]] - '0')
+		current = section{path: stack.push(level, heading), level: level}
+		start = match[1]
+	}
+	current.text = htmlToText(html[start:])
+	sections = append(sections, current)
+
+	if strings.TrimSpace(sections[0].text) == "" {
+		sections = sections[1:]
+	}
+	return sections
+}
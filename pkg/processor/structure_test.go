@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSmartChunker_MarkdownHeadingPaths(t *testing.T) {
+	chunker := NewSmartChunker()
+	config := &ProcessingConfig{ChunkSize: 500, ChunkOverlap: 0, MinChunkSize: 10}
+
+	markdown := `---
+title: Guide
+---
+Intro text before any heading, long enough to be kept.
+
+# Chapter 1
+
+## Overview
+VittoriaDB is an embedded vector database.
+
+# Chapter 2
+
+## Installation
+Download the binary and run it.
+
+` + "```bash\n# not a heading\nvittoriadb run\n```" + `
+
+### From source
+Build it with go build.
+`
+
+	chunks, err := chunker.ChunkMarkdown(markdown, config)
+	if err != nil {
+		t.Fatalf("ChunkMarkdown failed: %v", err)
+	}
+
+	want := []string{"", "Chapter 1 > Overview", "Chapter 2 > Installation", "Chapter 2 > Installation > From source"}
+	if len(chunks) != len(want) {
+		for _, c := range chunks {
+			t.Logf("%q: %q", c.Metadata["heading_path"], c.Content)
+		}
+		t.Fatalf("expected %d chunks, got %d", len(want), len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Metadata["heading_path"] != want[i] {
+			t.Errorf("chunk %d: heading_path = %q, want %q", i, chunk.Metadata["heading_path"], want[i])
+		}
+		if chunk.Position != i {
+			t.Errorf("chunk %d: position = %d", i, chunk.Position)
+		}
+	}
+	if !strings.Contains(chunks[2].Content, "# not a heading") {
+		t.Errorf("code block should stay in its section: %q", chunks[2].Content)
+	}
+	if strings.Contains(chunks[0].Content, "title: Guide") {
+		t.Errorf("frontmatter should not be chunked: %q", chunks[0].Content)
+	}
+}
+
+func TestSmartChunker_HTMLHeadingPaths(t *testing.T) {
+	chunker := NewSmartChunker()
+	config := &ProcessingConfig{ChunkSize: 500, ChunkOverlap: 0, MinChunkSize: 10}
+
+	html := `<html><head><title>Docs</title><style>h1 { color: red }</style></head><body>
+<h1>Chapter 2</h1><p>Getting started.</p>
+<h2 id="install">Installation &amp; setup</h2><p>Run the <b>installer</b>.</p>
+<h2>Usage</h2><p>Start the server.</p>
+</body></html>`
+
+	chunks, err := chunker.ChunkHTML(html, config)
+	if err != nil {
+		t.Fatalf("ChunkHTML failed: %v", err)
+	}
+
+	want := []string{"Chapter 2", "Chapter 2 > Installation & setup", "Chapter 2 > Usage"}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %d", len(want), len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Metadata["heading_path"] != want[i] {
+			t.Errorf("chunk %d: heading_path = %q, want %q", i, chunk.Metadata["heading_path"], want[i])
+		}
+	}
+	if !strings.Contains(chunks[1].Content, "Run the installer") {
+		t.Errorf("unexpected section content: %q", chunks[1].Content)
+	}
+}
@@ -10,13 +10,15 @@ import (
 
 // TextProcessor handles plain text and markdown files
 type TextProcessor struct {
-	chunker ChunkingStrategy
+	chunker   ChunkingStrategy
+	structure *SmartChunker // Chunks markdown by its headings
 }
 
 // NewTextProcessor creates a new text processor
 func NewTextProcessor() *TextProcessor {
 	return &TextProcessor{
-		chunker: NewSentenceChunker(),
+		chunker:   NewSentenceChunker(),
+		structure: NewSmartChunker(),
 	}
 }
 
@@ -70,8 +72,13 @@ func (p *TextProcessor) ProcessDocument(reader io.Reader, filename string, confi
 		p.extractMarkdownMetadata(text, doc)
 	}
 
-	// Chunk the document
-	chunks, err := p.chunker.ChunkText(text, config)
+	// Chunk the document; markdown by its headings, from the original lines
+	var chunks []DocumentChunk
+	if docType == DocumentTypeMD {
+		chunks, err = p.structure.ChunkMarkdown(string(content), config)
+	} else {
+		chunks, err = p.chunker.ChunkText(text, config)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to chunk document: %w", err)
 	}
@@ -16,6 +16,7 @@ const (
 	DocumentTypeMD   DocumentType = "md"
 	DocumentTypeHTML DocumentType = "html"
 	DocumentTypeRTF  DocumentType = "rtf"
+	DocumentTypeCode DocumentType = "code"
 )
 
 // Document represents a processed document
@@ -50,6 +51,7 @@ type ProcessingConfig struct {
 	MaxChunkSize int               `json:"max_chunk_size"` // Maximum chunk size
 	Language     string            `json:"language"`       // Document language
 	Metadata     map[string]string `json:"metadata"`       // Additional metadata
+	OCR          *OCRConfig        `json:"ocr,omitempty"`  // Recognize PDF pages without extractable text
 }
 
 // DefaultProcessingConfig returns default processing configuration
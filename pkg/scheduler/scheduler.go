@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobFunc runs a job and returns a short summary of what it did
+type JobFunc func(ctx context.Context) (string, error)
+
+// JobStatus describes a scheduled job and its last run
+type JobStatus struct {
+	Name           string    `json:"name"`
+	Type           string    `json:"type,omitempty"`
+	Schedule       string    `json:"schedule"`
+	Running        bool      `json:"running"`
+	Runs           int64     `json:"runs"`
+	Failures       int64     `json:"failures"`
+	Skipped        int64     `json:"skipped"` // Not started because the previous run had not finished
+	LastStart      time.Time `json:"last_start,omitzero"`
+	LastDurationMS int64     `json:"last_duration_ms"`
+	LastResult     string    `json:"last_result,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	NextRun        time.Time `json:"next_run,omitzero"`
+}
+
+// job is a registered job and its status
+type job struct {
+	fn       JobFunc
+	schedule *Schedule
+	busy     chan struct{} // Holds a token while a run is going
+	mu       sync.Mutex
+	status   JobStatus
+}
+
+// Scheduler runs jobs on cron schedules. A job never overlaps itself: a run
+// that comes due while the previous one is still going is skipped.
+type Scheduler struct {
+	mu       sync.Mutex
+	jobs     map[string]*job
+	location *time.Location
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	running  bool
+}
+
+// New creates a scheduler evaluating schedules in location (nil for local time)
+func New(location *time.Location) *Scheduler {
+	if location == nil {
+		location = time.Local
+	}
+	return &Scheduler{
+		jobs:     make(map[string]*job),
+		location: location,
+	}
+}
+
+// Add registers a job under a unique name to run on a cron schedule. jobType
+// is reported in the job's status.
+func (s *Scheduler) Add(name, jobType, cron string, fn JobFunc) error {
+	schedule, err := ParseCron(cron)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("cannot add job '%s' to a running scheduler", name)
+	}
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job '%s' already exists", name)
+	}
+	s.jobs[name] = &job{
+		fn:       fn,
+		schedule: schedule,
+		busy:     make(chan struct{}, 1),
+		status:   JobStatus{Name: name, Type: jobType, Schedule: cron},
+	}
+	return nil
+}
+
+// Start starts running the registered jobs on their schedules
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+	}
+}
+
+// Stop stops scheduling jobs, cancels running ones and waits for them to return
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.cancel()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Statuses returns the status of every job, sorted by name
+func (s *Scheduler) Statuses() []*JobStatus {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	statuses := make([]*JobStatus, 0, len(jobs))
+	for _, j := range jobs {
+		j.mu.Lock()
+		status := j.status
+		j.mu.Unlock()
+		statuses = append(statuses, &status)
+	}
+	sort.Slice(statuses, func(i, k int) bool {
+		return statuses[i].Name < statuses[k].Name
+	})
+	return statuses
+}
+
+// loop waits for each time a job comes due and starts it
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	defer s.wg.Done()
+
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	for {
+		next := j.schedule.Next(time.Now().In(s.location))
+		j.mu.Lock()
+		j.status.NextRun = next
+		j.mu.Unlock()
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.trigger(ctx, j, &running)
+	}
+}
+
+// trigger starts a run of a job in the background, unless one is still going
+func (s *Scheduler) trigger(ctx context.Context, j *job, running *sync.WaitGroup) bool {
+	select {
+	case j.busy <- struct{}{}:
+	default:
+		j.mu.Lock()
+		j.status.Skipped++
+		j.mu.Unlock()
+		log.Printf("Skipping scheduled job %s: the previous run is still going", j.status.Name)
+		return false
+	}
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		defer func() { <-j.busy }()
+		s.run(ctx, j)
+	}()
+	return true
+}
+
+// run runs a job once and records the outcome
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	start := time.Now()
+	j.mu.Lock()
+	j.status.Running = true
+	j.status.LastStart = start
+	j.mu.Unlock()
+
+	result, err := j.fn(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastDurationMS = time.Since(start).Milliseconds()
+	j.status.LastResult = result
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		log.Printf("Scheduled job %s failed: %v", j.status.Name, err)
+	}
+}
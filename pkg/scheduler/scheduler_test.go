@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// Wednesday, 15 January 2025
+	start := time.Date(2025, 1, 15, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, 1, 16, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * sun", time.Date(2025, 1, 19, 2, 30, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2025, 1, 19, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 jun *", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week both restricted: either one matches
+		{"0 0 20 * fri", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", tt.expr, err)
+		}
+		if got := schedule.Next(start); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "* * * * funday"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) should fail", expr)
+		}
+	}
+}
+
+func TestScheduler_SkipsOverlappingRuns(t *testing.T) {
+	s := New(time.UTC)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := s.Add("slow", "test", "@daily", func(ctx context.Context) (string, error) {
+		close(started)
+		<-release
+		return "done", nil
+	}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add("slow", "test", "@daily", nil); err == nil {
+		t.Error("adding a duplicate job name should fail")
+	}
+
+	j := s.jobs["slow"]
+	var running sync.WaitGroup
+	if !s.trigger(context.Background(), j, &running) {
+		t.Fatal("first run should start")
+	}
+	<-started
+	if s.trigger(context.Background(), j, &running) {
+		t.Error("second run should be skipped while the first is going")
+	}
+	close(release)
+	running.Wait()
+
+	status := s.Statuses()[0]
+	if status.Runs != 1 || status.Skipped != 1 || status.Running || status.LastResult != "done" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
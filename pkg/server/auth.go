@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// APIKeyHeader is an alternative to "Authorization: Bearer <key>"
+const APIKeyHeader = "X-API-Key"
+
+// errKeysReadOnly answers key changes on a read-only server: the keys file
+// belongs to the server writing the data directory
+var errKeysReadOnly = fmt.Errorf("API keys are managed by the server writing the data directory: %w", core.ErrReadOnly)
+
+// apiKeyContextKey carries the authenticated key in the request context
+type apiKeyContextKey struct{}
+
+// accessRule is the access a route requires
+type accessRule struct {
+	public     bool            // No key required
+	peer       bool            // Raft RPC, authenticated by the cluster secret instead of a key
+	permission auth.Permission // Permission the key must grant
+	database   bool            // Acts on the whole database, so the key must cover every collection
+}
+
+// unlimited reports whether the route is left out of rate and concurrency
+// limits, as the dashboard, health checks and Raft RPCs are
+func (a accessRule) unlimited() bool {
+	return a.public || a.peer
+}
+
+// SetAuth enables API key authentication, with keys and their permissions
+// taken from store
+func (s *Server) SetAuth(store *auth.Store) {
+	s.auth = store
+}
+
+// routeAccess returns the access required by the matched route. Routes under
+// /collections/{name} are checked against that collection, and those under
+// /groups/{name} against that group; anything not
+// listed needs read for GET and write for other methods.
+func routeAccess(r *http.Request) accessRule {
+	template := ""
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ = route.GetPathTemplate()
+	}
+
+	switch template {
+	case "/", "/ui/{file}", "/health", "/health/live", "/health/ready":
+		// The dashboard only holds static files and reads data through the API
+		return accessRule{public: true}
+	case cluster.VotePath, cluster.AppendPath:
+		return accessRule{peer: true}
+	case "/auth/keys", "/auth/keys/{name}", "/admin/usage", "/admin/loadtest", "/trash":
+		return accessRule{permission: auth.PermissionAdmin, database: true}
+	case "/config":
+		// The public view leaves out deployment settings; the full one and
+		// changes are for admins
+		if r.Method != http.MethodGet || r.URL.Query().Get("view") == "full" {
+			return accessRule{permission: auth.PermissionAdmin, database: true}
+		}
+		return accessRule{permission: auth.PermissionRead, database: true}
+	case "/stats":
+		return accessRule{permission: auth.PermissionRead, database: true}
+	case "/collections", "/groups":
+		// Listing is filtered and creation is checked against the requested name
+		// by the handlers
+		if r.Method == http.MethodGet {
+			return accessRule{permission: auth.PermissionRead}
+		}
+		return accessRule{permission: auth.PermissionAdmin}
+	case "/collections/{name}", "/collections/{name}/rebalance", "/collections/{name}/namespaces/{namespace}", "/collections/{name}/shadow", "/collections/{name}/index/rebuild", "/groups/{name}":
+		if r.Method == http.MethodGet {
+			return accessRule{permission: auth.PermissionRead}
+		}
+		return accessRule{permission: auth.PermissionAdmin}
+	case "/collections/{name}/index/repair", "/collections/{name}/restore", "/groups/{name}/backup":
+		return accessRule{permission: auth.PermissionAdmin}
+	case "/groups/{name}/search", "/collections/{name}/query", "/collections/{name}/export/parquet", "/collections/{name}/recommend":
+		return accessRule{permission: auth.PermissionRead}
+	case "/cluster/status", "/documents/process", "/documents/supported", "/estimate":
+		return accessRule{permission: auth.PermissionRead}
+	case FlightRoute:
+		// The RPCs check the collections they read or write
+		return accessRule{permission: auth.PermissionRead}
+	}
+
+	rule := accessRule{permission: auth.PermissionWrite}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		rule.permission = auth.PermissionRead
+	}
+	if _, scoped := mux.Vars(r)["name"]; !scoped {
+		rule.database = true
+	}
+	return rule
+}
+
+// requestKey returns the API key secret sent with the request
+func requestKey(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.Header.Get(APIKeyHeader)
+}
+
+// requestAPIKey returns the key that authenticated the request, or nil when
+// authentication is disabled
+func requestAPIKey(r *http.Request) *auth.Key {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*auth.Key)
+	return key
+}
+
+// authorize checks that the request's key grants p on the named collection
+// and writes a 403 response if it does not
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, collection string, p auth.Permission) bool {
+	key := requestAPIKey(r)
+	if key == nil || key.Allows(collection, p) {
+		return true
+	}
+	s.writeError(w, http.StatusForbidden, "Insufficient permissions",
+		fmt.Errorf("key '%s' does not grant %s access to collection '%s'", key.Name, p, collection))
+	return false
+}
+
+// authMiddleware authenticates requests by API key and enforces the route's
+// required permission. Raft RPCs are authenticated by the cluster secret,
+// whether or not API keys are enabled.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule := routeAccess(r)
+		if rule.peer {
+			if s.cluster == nil || s.authenticatePeer(w, r) {
+				next.ServeHTTP(w, r)
+			}
+			return
+		}
+		if s.auth == nil || r.Method == http.MethodOptions || rule.public {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, ok := s.auth.Authenticate(requestKey(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vittoriadb"`)
+			s.writeError(w, http.StatusUnauthorized, "Missing or invalid API key", nil)
+			return
+		}
+
+		collection := mux.Vars(r)["name"]
+		var allowed bool
+		switch {
+		case rule.database:
+			allowed = key.Allows("", rule.permission)
+			collection = ""
+		case collection != "":
+			allowed = key.Allows(collection, rule.permission)
+		default:
+			allowed = key.Can(rule.permission)
+		}
+
+		if !allowed {
+			err := fmt.Errorf("key '%s' does not grant %s access to the database", key.Name, rule.permission)
+			if collection != "" {
+				err = fmt.Errorf("key '%s' does not grant %s access to collection '%s'", key.Name, rule.permission, collection)
+			}
+			s.writeError(w, http.StatusForbidden, "Insufficient permissions", err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	})
+}
+
+// Keys endpoint (GET: list, POST: create)
+func (s *Server) handleAuthKeys(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		s.writeError(w, http.StatusNotFound, "Authentication is not enabled", nil)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		keys := s.auth.List()
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys":  keys,
+			"count": len(keys),
+		})
+		return
+	}
+	if s.readOnly() {
+		s.writeError(w, http.StatusForbidden, "Server is read-only", errKeysReadOnly)
+		return
+	}
+
+	var req struct {
+		Name        string   `json:"name"`
+		Permissions []string `json:"permissions"`
+		Collections []string `json:"collections"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	permissions := make([]auth.Permission, 0, len(req.Permissions))
+	for _, name := range req.Permissions {
+		p, err := auth.ParsePermission(name)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid permission", err)
+			return
+		}
+		permissions = append(permissions, p)
+	}
+
+	key, secret, err := s.auth.Create(req.Name, permissions, req.Collections)
+	if err != nil {
+		if errors.Is(err, auth.ErrKeyExists) {
+			s.writeError(w, http.StatusConflict, "Key already exists", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to create key", err)
+		}
+		return
+	}
+
+	// The secret is only ever returned here
+	s.writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"key":         secret,
+		"name":        key.Name,
+		"permissions": key.Permissions,
+		"collections": key.Collections,
+		"created":     key.Created,
+	})
+}
+
+// Key endpoint (DELETE: revoke)
+func (s *Server) handleAuthKey(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		s.writeError(w, http.StatusNotFound, "Authentication is not enabled", nil)
+		return
+	}
+
+	if s.readOnly() {
+		s.writeError(w, http.StatusForbidden, "Server is read-only", errKeysReadOnly)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := s.auth.Delete(name); err != nil {
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			s.writeError(w, http.StatusNotFound, "Key not found", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to delete key", err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]string{
+		"status": "deleted",
+		"name":   name,
+	})
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/gorilla/mux"
+)
+
+// accessOf returns the access routeAccess requires of a request to target
+// routed to template
+func accessOf(method, template, target string) accessRule {
+	var rule accessRule
+	router := mux.NewRouter()
+	router.HandleFunc(template, func(w http.ResponseWriter, r *http.Request) {
+		rule = routeAccess(r)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, target, nil))
+	return rule
+}
+
+func TestRouteAccess(t *testing.T) {
+	read := auth.PermissionRead
+	write := auth.PermissionWrite
+	admin := auth.PermissionAdmin
+	templates := routeTemplates(newTestServer(t))
+
+	for _, test := range []struct {
+		method, template, target string
+		want                     accessRule
+	}{
+		{"GET", "/", "/", accessRule{public: true}},
+		{"GET", "/health/ready", "/health/ready", accessRule{public: true}},
+		{"POST", cluster.VotePath, cluster.VotePath, accessRule{peer: true}},
+		{"POST", cluster.AppendPath, cluster.AppendPath, accessRule{peer: true}},
+		{"GET", "/auth/keys", "/auth/keys", accessRule{permission: admin, database: true}},
+		{"GET", "/trash", "/trash", accessRule{permission: admin, database: true}},
+		{"GET", "/config", "/config", accessRule{permission: read, database: true}},
+		{"GET", "/config", "/config?view=full", accessRule{permission: admin, database: true}},
+		{"PUT", "/config", "/config", accessRule{permission: admin, database: true}},
+		{"GET", "/stats", "/stats", accessRule{permission: read, database: true}},
+		{"GET", "/collections", "/collections", accessRule{permission: read}},
+		{"POST", "/collections", "/collections", accessRule{permission: admin}},
+		{"GET", "/collections/{name}", "/collections/docs", accessRule{permission: read}},
+		{"DELETE", "/collections/{name}", "/collections/docs", accessRule{permission: admin}},
+		{"POST", "/collections/{name}/index/repair", "/collections/docs/index/repair", accessRule{permission: admin}},
+		{"POST", "/collections/{name}/query", "/collections/docs/query", accessRule{permission: read}},
+		{"GET", "/collections/{name}/search", "/collections/docs/search", accessRule{permission: read}},
+		{"POST", "/collections/{name}/vectors", "/collections/docs/vectors", accessRule{permission: write}},
+		{"POST", "/groups/{name}/backup", "/groups/news/backup", accessRule{permission: admin}},
+		{"POST", "/documents/process", "/documents/process", accessRule{permission: read}},
+		{"GET", "/capabilities", "/capabilities", accessRule{permission: read, database: true}},
+	} {
+		if !templates[test.template] {
+			t.Errorf("%s is not a route", test.template)
+		}
+		if got := accessOf(test.method, test.template, test.target); got != test.want {
+			t.Errorf("%s %s: got %+v, want %+v", test.method, test.target, got, test.want)
+		}
+	}
+
+	for _, rule := range []accessRule{{public: true}, {peer: true}} {
+		if !rule.unlimited() {
+			t.Errorf("%+v should not be rate or concurrency limited", rule)
+		}
+	}
+	if (accessRule{permission: read}).unlimited() {
+		t.Error("API routes should be rate and concurrency limited")
+	}
+}
+
+func TestPeerAuthentication(t *testing.T) {
+	s := newTestServer(t)
+	store := auth.NewStore("")
+	if err := store.AddConfigKey("reader", "reader-key", []auth.Permission{auth.PermissionRead}, nil); err != nil {
+		t.Fatalf("AddConfigKey failed: %v", err)
+	}
+	s.SetAuth(store)
+
+	post := func(path string, headers map[string]string) int {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		s.router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// Without a cluster, Raft RPCs reach their handler, which refuses them
+	if code := post(cluster.VotePath, nil); code != http.StatusNotFound {
+		t.Errorf("standalone vote: got status %d, want 404", code)
+	}
+
+	config := cluster.DefaultConfig()
+	config.NodeID = "n1"
+	config.Address = "http://127.0.0.1:1"
+	config.DataDir = t.TempDir()
+	config.Peers = map[string]string{"n2": "http://127.0.0.1:2"}
+	config.Secret = "test-cluster-secret"
+	node, err := cluster.NewNode(config, cluster.NewDatabaseFSM(s.db))
+	if err != nil {
+		t.Fatalf("NewNode failed: %v", err)
+	}
+	defer node.Stop()
+	s.SetCluster(node)
+
+	for _, test := range []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"no secret", cluster.VotePath, nil, http.StatusUnauthorized},
+		{"API key", cluster.AppendPath, map[string]string{"Authorization": "Bearer reader-key"}, http.StatusUnauthorized},
+		{"wrong secret", cluster.AppendPath, map[string]string{cluster.SecretHeader: "not-the-secret"}, http.StatusUnauthorized},
+		{"secret", cluster.VotePath, map[string]string{cluster.SecretHeader: config.Secret}, http.StatusOK},
+		{"secret", cluster.AppendPath, map[string]string{cluster.SecretHeader: config.Secret}, http.StatusOK},
+		// The cluster secret grants nothing beyond the Raft RPCs
+		{"secret on the API", "/collections/docs/vectors", map[string]string{cluster.SecretHeader: config.Secret}, http.StatusUnauthorized},
+	} {
+		if code := post(test.path, test.headers); code != test.want {
+			t.Errorf("%s to %s: got status %d, want %d", test.name, test.path, code, test.want)
+		}
+	}
+}
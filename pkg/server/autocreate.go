@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// textCollection returns the collection texts are inserted into. When it does
+// not exist and auto_create allows it, it is created from the template first,
+// and created is true.
+func (s *Server) textCollection(ctx context.Context, name string) (collection core.Collection, created bool, err error) {
+	collection, err = s.db.GetCollection(ctx, name)
+	if err == nil || !errors.Is(err, core.ErrNotFound) || !s.autoCreates(name) {
+		return collection, false, err
+	}
+
+	// Without dimensions in the template, the collection takes those of the
+	// vectorizer's model
+	req := core.AutoCreateRequest(s.currentConfig(), name)
+	s.applyVectorizerDefaults(req.VectorizerConfig)
+
+	err = s.execute(ctx, &cluster.Command{Op: cluster.OpCreateCollection, Create: req})
+	created = err == nil
+	// Another request may have created it in the meantime
+	if err != nil && !errors.Is(err, core.ErrAlreadyExists) {
+		return nil, false, fmt.Errorf("failed to auto-create collection '%s': %w", name, err)
+	}
+
+	collection, err = s.db.GetCollection(ctx, name)
+	return collection, created, err
+}
+
+// autoCreates reports whether collection name is created on its first text
+// insert
+func (s *Server) autoCreates(name string) bool {
+	cfg := s.currentConfig()
+	if cfg == nil || !cfg.AutoCreate.Enabled {
+		return false
+	}
+
+	patterns := cfg.AutoCreate.Collections
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// batchChunkSize is how many vectors of a batch insert are decoded and
+// inserted at a time
+const batchChunkSize = 1000
+
+// decodeBatch reads the body of a batch insert, {"vectors": [...], "if":
+// {...}}, passing its vectors to insert a chunk at a time along with the
+// condition, so that a large batch is never held in memory whole. A batch of
+// up to one chunk is inserted once the whole body is read, wherever its
+// condition is; a larger one must give its condition before its vectors.
+func decodeBatch(body io.Reader, chunkSize int, insert func(vectors []*core.Vector, condition *core.Filter) error) error {
+	decoder := json.NewDecoder(body)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	var condition *core.Filter
+	var pending []*core.Vector
+	decoded := 0
+	inserting := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case "vectors":
+			token, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if token == nil {
+				continue // null
+			}
+			if token != json.Delim('[') {
+				return fmt.Errorf("vectors must be an array, got %v", token)
+			}
+			for decoder.More() {
+				// The full chunk is held back until another vector follows,
+				// so a batch of one chunk is only inserted at the end
+				if len(pending) == chunkSize {
+					inserting = true
+					if err := insert(pending, condition); err != nil {
+						return err
+					}
+					pending = make([]*core.Vector, 0, chunkSize)
+				}
+
+				var vector *core.Vector
+				if err := decoder.Decode(&vector); err != nil {
+					return fmt.Errorf("vector %d: %w", decoded, err)
+				}
+				if vector == nil {
+					return fmt.Errorf("vector %d is null", decoded)
+				}
+				pending = append(pending, vector)
+				decoded++
+			}
+			if err := expectDelim(decoder, ']'); err != nil {
+				return err
+			}
+
+		case "if":
+			if inserting {
+				return fmt.Errorf("the if condition of a batch of more than %d vectors must come before the vectors", chunkSize)
+			}
+			if err := decoder.Decode(&condition); err != nil {
+				return fmt.Errorf("if: %w", err)
+			}
+
+		default:
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return err
+	}
+
+	if len(pending) == 0 && inserting {
+		return nil
+	}
+	return insert(pending, condition)
+}
+
+// expectDelim reads the next token, which must be delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected '%s', got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// batchBody returns a batch insert body of n vectors, with extra members
+// before and after them
+func batchBody(n int, before, after string) string {
+	vectors := make([]string, n)
+	for i := range vectors {
+		vectors[i] = fmt.Sprintf(`{"id": "v%d", "vector": [%d, 1]}`, i, i)
+	}
+	return "{" + before + `"vectors": [` + strings.Join(vectors, ", ") + "]" + after + "}"
+}
+
+// insertedChunk is a call of decodeBatch's insert function
+type insertedChunk struct {
+	ids       []string
+	condition *core.Filter
+}
+
+// decodeChunks decodes body in chunks of chunkSize, returning the chunks
+// inserted
+func decodeChunks(t *testing.T, body string, chunkSize int) ([]insertedChunk, error) {
+	t.Helper()
+	var chunks []insertedChunk
+	err := decodeBatch(strings.NewReader(body), chunkSize, func(vectors []*core.Vector, condition *core.Filter) error {
+		chunk := insertedChunk{ids: []string{}, condition: condition}
+		for _, vector := range vectors {
+			chunk.ids = append(chunk.ids, vector.ID)
+		}
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	return chunks, err
+}
+
+func TestDecodeBatchChunks(t *testing.T) {
+	for _, test := range []struct {
+		vectors int
+		sizes   []int
+	}{
+		{0, []int{0}},
+		{1, []int{1}},
+		{3, []int{3}},
+		{4, []int{3, 1}},
+		{6, []int{3, 3}},
+		{7, []int{3, 3, 1}},
+	} {
+		chunks, err := decodeChunks(t, batchBody(test.vectors, "", ""), 3)
+		if err != nil {
+			t.Fatalf("%d vectors: %v", test.vectors, err)
+		}
+		sizes := make([]int, len(chunks))
+		decoded := 0
+		for i, chunk := range chunks {
+			sizes[i] = len(chunk.ids)
+			for _, id := range chunk.ids {
+				if id != fmt.Sprintf("v%d", decoded) {
+					t.Errorf("%d vectors: got %s at position %d", test.vectors, id, decoded)
+				}
+				decoded++
+			}
+		}
+		if !reflect.DeepEqual(sizes, test.sizes) {
+			t.Errorf("%d vectors: chunks of %v, want %v", test.vectors, sizes, test.sizes)
+		}
+	}
+}
+
+func TestDecodeBatchCondition(t *testing.T) {
+	condition := `"if": {"field": "version", "operator": "eq", "value": 1}`
+
+	// A batch of one chunk takes its condition from anywhere in the body
+	chunks, err := decodeChunks(t, batchBody(3, "", ", "+condition), 3)
+	if err != nil {
+		t.Fatalf("decodeBatch failed: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].condition == nil || chunks[0].condition.Field != "version" {
+		t.Errorf("condition after the vectors was not applied: %+v", chunks)
+	}
+
+	// Larger batches apply it to every chunk when it comes first
+	chunks, err = decodeChunks(t, batchBody(5, condition+", ", ""), 3)
+	if err != nil {
+		t.Fatalf("decodeBatch failed: %v", err)
+	}
+	for _, chunk := range chunks {
+		if chunk.condition == nil {
+			t.Errorf("chunk %v inserted without the condition", chunk.ids)
+		}
+	}
+
+	// and refuse it after chunks were inserted
+	if _, err := decodeChunks(t, batchBody(5, "", ", "+condition), 3); err == nil || !strings.Contains(err.Error(), "must come before the vectors") {
+		t.Errorf("expected a late condition to be refused, got %v", err)
+	}
+}
+
+func TestDecodeBatchMembers(t *testing.T) {
+	// A "vectors" key nested in another member is skipped with it
+	body := `{"options": {"vectors": [{"id": "nested"}]}, "vectors": [{"id": "a"}], "note": "vectors"}`
+	chunks, err := decodeChunks(t, body, 3)
+	if err != nil {
+		t.Fatalf("decodeBatch failed: %v", err)
+	}
+	if len(chunks) != 1 || !reflect.DeepEqual(chunks[0].ids, []string{"a"}) {
+		t.Errorf("nested vectors were inserted: %+v", chunks)
+	}
+
+	// Null vectors insert nothing
+	chunks, err = decodeChunks(t, `{"vectors": null}`, 3)
+	if err != nil || len(chunks) != 1 || len(chunks[0].ids) != 0 {
+		t.Errorf("null vectors: %+v, %v", chunks, err)
+	}
+
+	for _, test := range []struct {
+		body string
+		want string
+	}{
+		{`{"vectors": {"id": "a"}}`, "must be an array"},
+		{`{"vectors": [{"id": "a"}, null]}`, "vector 1 is null"},
+		{`{"vectors": [{"id": "a"}, {"id": 5}]}`, "vector 1:"},
+		{`{"vectors": [{"id": "a"}, {"vector": "up"}]}`, "vector 1:"},
+		{`{"vectors": [{"id": "a"}`, "unexpected end"},
+		{`[{"id": "a"}]`, "expected '{'"},
+	} {
+		if _, err := decodeChunks(t, test.body, 3); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want an error with %q", test.body, err, test.want)
+		}
+	}
+}
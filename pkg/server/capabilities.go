@@ -0,0 +1,91 @@
+package server
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// metricCapability describes a distance metric collections can use
+type metricCapability struct {
+	Name   string `json:"name"`
+	ID     int    `json:"id"`     // Accepted in place of the name
+	Binary bool   `json:"binary"` // Compares vectors of 0 and 1 components
+}
+
+// indexCapability describes an index type collections can use
+type indexCapability struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"` // Accepted in place of the name
+}
+
+// capabilitiesResponse is what GET /capabilities answers with
+type capabilitiesResponse struct {
+	Metrics            []metricCapability `json:"metrics"`
+	IndexTypes         []indexCapability  `json:"index_types"`
+	FilterOperators    []core.FilterOp    `json:"filter_operators"`
+	QuantizationTypes  []string           `json:"quantization_types"`
+	Vectorizers        []string           `json:"vectorizers"`
+	VectorizerProfiles []string           `json:"vectorizer_profiles"` // Names collections can be created with by vectorizer_profile
+	ExportFormats      []string           `json:"export_formats"`
+	DocumentFormats    []string           `json:"document_formats"` // Extensions POST /collections/{name}/documents accepts
+	MaxDimensions      int                `json:"max_dimensions"`
+	MaxCollections     int                `json:"max_collections,omitempty"` // 0 when unlimited
+	MaxSearchLimit     int                `json:"max_search_limit,omitempty"`
+	Features           map[string]bool    `json:"features"`
+}
+
+// handleCapabilities describes what this server supports, so that clients
+// can adapt to it without checking its version
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	cfg := s.currentConfig()
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+
+	response := capabilitiesResponse{
+		FilterOperators:   core.FilterOperators,
+		QuantizationTypes: core.QuantizationTypes,
+		ExportFormats:     core.ExportFormats,
+		DocumentFormats:   s.processor.GetSupportedExtensions(),
+		MaxDimensions:     core.MaxDimensions,
+		MaxCollections:    cfg.Limits.MaxCollections,
+		MaxSearchLimit:    cfg.Search.MaxLimit,
+		Features: map[string]bool{
+			"auth":            s.auth != nil,
+			"cluster":         s.cluster != nil,
+			"read_only":       s.readOnly(),
+			"flight":          true,
+			"search_cache":    cfg.Search.Cache.Enabled,
+			"parallel_search": cfg.Search.Parallel.Enabled,
+			"rerank":          cfg.Search.Rerank.Enabled,
+			"ocr":             cfg.Embeddings.Processing.OCR.Enabled,
+			"auto_create":     cfg.AutoCreate.Enabled,
+			"edge":            cfg.Edge.Enabled,
+			"tls":             cfg.Server.TLS.Enabled,
+			"rate_limit":      cfg.Server.RateLimit.Enabled,
+		},
+	}
+	if cfg.Limits.MaxDimensions > 0 {
+		response.MaxDimensions = cfg.Limits.MaxDimensions
+	}
+	for _, metric := range core.DistanceMetrics {
+		response.Metrics = append(response.Metrics, metricCapability{Name: metric.String(), ID: int(metric), Binary: metric.Binary()})
+	}
+	for _, indexType := range core.IndexTypes {
+		response.IndexTypes = append(response.IndexTypes, indexCapability{Name: indexType.String(), ID: int(indexType)})
+	}
+	for _, vectorizer := range embeddings.NewVectorizerFactory().SupportedTypes() {
+		response.Vectorizers = append(response.Vectorizers, vectorizer.String())
+	}
+	response.VectorizerProfiles = slices.Sorted(maps.Keys(cfg.Embeddings.Profiles))
+	if response.VectorizerProfiles == nil {
+		response.VectorizerProfiles = []string{}
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// capabilities reads GET /capabilities
+func capabilities(t *testing.T, s *Server) *capabilitiesResponse {
+	t.Helper()
+	recorder := send(s, http.MethodGet, "/capabilities", "")
+	var response capabilitiesResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("invalid capabilities: %v", err)
+	}
+	return &response
+}
+
+func TestCapabilitiesCreate(t *testing.T) {
+	s := newTestServer(t)
+	caps := capabilities(t, s)
+	if len(caps.Metrics) != len(core.DistanceMetrics) || len(caps.IndexTypes) != len(core.IndexTypes) {
+		t.Fatalf("advertised %d metrics and %d index types", len(caps.Metrics), len(caps.IndexTypes))
+	}
+
+	// Every advertised metric and index type creates a collection, by name
+	// and by ID, that reports it back
+	created := 0
+	for _, metric := range caps.Metrics {
+		for _, index := range caps.IndexTypes {
+			for _, spelling := range []string{
+				fmt.Sprintf(`"metric": %q, "index_type": %q`, metric.Name, index.Name),
+				fmt.Sprintf(`"metric": %d, "index_type": %d`, metric.ID, index.ID),
+			} {
+				name := fmt.Sprintf("c%d", created)
+				created++
+				if code := send(s, http.MethodPost, "/collections", `{"name": "`+name+`", "dimensions": 8, `+spelling+`}`).Code; code != http.StatusCreated {
+					t.Errorf("%s: got status %d, want 201", spelling, code)
+					continue
+				}
+				var info core.CollectionInfo
+				json.NewDecoder(send(s, http.MethodGet, "/collections/"+name, "").Body).Decode(&info)
+				if int(info.Metric) != metric.ID || int(info.IndexType) != index.ID {
+					t.Errorf("%s: created a collection with %s and %s", spelling, info.Metric, info.IndexType)
+				}
+			}
+		}
+	}
+
+	// and nothing else does
+	for _, spelling := range []string{
+		`"metric": "cosine_similarity"`,
+		fmt.Sprintf(`"metric": %d`, len(caps.Metrics)),
+		`"index_type": "ivf"`,
+		fmt.Sprintf(`"index_type": %d`, len(caps.IndexTypes)),
+	} {
+		if code := send(s, http.MethodPost, "/collections", `{"name": "unadvertised", "dimensions": 8, `+spelling+`}`).Code; code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", spelling, code)
+		}
+	}
+}
+
+func TestCapabilitiesFilterOperators(t *testing.T) {
+	s := newTestServer(t)
+	if code := send(s, http.MethodPost, "/collections", `{"name": "docs", "dimensions": 2}`).Code; code != http.StatusCreated {
+		t.Fatalf("creating the collection: got status %d", code)
+	}
+	body := `{"vectors": [
+		{"id": "match", "vector": [1, 0], "metadata": {"n": 5, "tag": "red apple", "place": {"lat": 45.46, "lon": 9.19}}},
+		{"id": "other", "vector": [0, 1], "metadata": {"n": 1, "tag": "pear"}}
+	]}`
+	if code := send(s, http.MethodPost, "/collections/docs/vectors/batch", body).Code; code >= 300 {
+		t.Fatalf("inserting: got status %d", code)
+	}
+
+	// A condition with every advertised operator that only one record meets
+	conditions := map[core.FilterOp]string{
+		core.FilterOpEq:        `{"field": "n", "operator": "eq", "value": 5}`,
+		core.FilterOpNe:        `{"field": "n", "operator": "ne", "value": 1}`,
+		core.FilterOpGt:        `{"field": "n", "operator": "gt", "value": 1}`,
+		core.FilterOpGte:       `{"field": "n", "operator": "gte", "value": 5}`,
+		core.FilterOpLt:        `{"field": "n", "operator": "lt", "value": 5}`,
+		core.FilterOpLte:       `{"field": "n", "operator": "lte", "value": 1}`,
+		core.FilterOpIn:        `{"field": "tag", "operator": "in", "value": ["red apple", "plum"]}`,
+		core.FilterOpNotIn:     `{"field": "tag", "operator": "not_in", "value": ["pear"]}`,
+		core.FilterOpContains:  `{"field": "tag", "operator": "contains", "value": "apple"}`,
+		core.FilterOpExists:    `{"field": "place", "operator": "exists"}`,
+		core.FilterOpGeoRadius: `{"field": "place", "operator": "geo_radius", "value": {"lat": 45.47, "lon": 9.19, "radius": 5000}}`,
+		core.FilterOpGeoBBox:   `{"field": "place", "operator": "geo_bbox", "value": {"min_lat": 45, "min_lon": 9, "max_lat": 46, "max_lon": 10}}`,
+	}
+	// The comparisons below a value keep the other record
+	want := map[core.FilterOp]string{core.FilterOpLt: "other", core.FilterOpLte: "other"}
+
+	for _, op := range capabilities(t, s).FilterOperators {
+		condition, ok := conditions[op]
+		if !ok {
+			t.Errorf("operator %s is advertised but not tested", op)
+			continue
+		}
+		delete(conditions, op)
+
+		recorder := send(s, http.MethodPost, "/collections/docs/search", `{"vector": [1, 0], "limit": 10, "filter": `+condition+`}`)
+		var response core.SearchResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || recorder.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, %v", op, recorder.Code, err)
+			continue
+		}
+		id, ok := want[op]
+		if !ok {
+			id = "match"
+		}
+		if len(response.Results) != 1 || response.Results[0].ID != id {
+			t.Errorf("%s: got %d results, want %s alone", op, len(response.Results), id)
+		}
+	}
+	for op := range conditions {
+		t.Errorf("operator %s is accepted but not advertised", op)
+	}
+
+	// An operator that is not advertised is refused
+	if code := send(s, http.MethodPost, "/collections/docs/search", `{"vector": [1, 0], "filter": {"field": "n", "operator": "regex", "value": "5"}}`).Code; code != http.StatusBadRequest {
+		t.Errorf("unknown operator: got status %d, want 400", code)
+	}
+}
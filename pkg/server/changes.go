@@ -0,0 +1,130 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// changeBuffer is the number of events a change stream holds for a slow client
+// before it starts dropping them
+const changeBuffer = 1024
+
+// changeKeepalive is how often an idle change stream sends a comment, so
+// proxies and clients do not time the connection out
+const changeKeepalive = 15 * time.Second
+
+// Change notifications endpoint: streams the inserts, updates and deletes
+// applied to a collection as Server-Sent Events
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	namespace, err := requestNamespace(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	types, err := parseChangeTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid change types", err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Streaming not supported", nil)
+		return
+	}
+
+	sub := vittoriaCollection.SubscribeChanges(changeBuffer)
+	defer sub.Close()
+
+	beginEventStream(w)
+	writeEvent(w, "subscribed", map[string]interface{}{
+		"collection": name,
+		"namespace":  namespace,
+		"time":       time.Now(),
+	})
+	flusher.Flush()
+
+	// Events dropped for this client are reported once it has caught up, so
+	// it knows to resync from the collection
+	reportMissed := func() {
+		if missed := sub.TakeMissed(); missed > 0 {
+			writeEvent(w, "lagged", map[string]interface{}{"missed": missed})
+		}
+	}
+
+	keepalive := time.NewTicker(changeKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				writeEvent(w, "closed", map[string]interface{}{"collection": name})
+				flusher.Flush()
+				return
+			}
+			if (namespace == "" || event.Namespace == namespace) && (types == nil || types[event.Type]) {
+				if err := writeEvent(w, string(event.Type), event); err != nil {
+					return
+				}
+			}
+			if len(sub.Events()) == 0 {
+				reportMissed()
+				flusher.Flush()
+			}
+		case <-keepalive.C:
+			reportMissed()
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// parseChangeTypes parses a comma-separated list of change types; an empty
+// list selects every type
+func parseChangeTypes(list string) (map[core.ChangeType]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	types := make(map[core.ChangeType]bool)
+	for _, t := range strings.Split(list, ",") {
+		switch changeType := core.ChangeType(strings.TrimSpace(t)); changeType {
+		case core.ChangeInsert, core.ChangeUpdate, core.ChangeDelete:
+			types[changeType] = true
+		default:
+			return nil, fmt.Errorf("unknown change type '%s'", t)
+		}
+	}
+	return types, nil
+}
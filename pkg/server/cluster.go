@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// SetCluster enables cluster mode: writes are replicated through the node's
+// Raft log and followers redirect write requests to the leader
+func (s *Server) SetCluster(node *cluster.Node) {
+	s.cluster = node
+}
+
+// Cluster status endpoint
+func (s *Server) handleClusterStatus(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"enabled": false,
+			"state":   "standalone",
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": true,
+		"status":  s.cluster.Status(),
+	})
+}
+
+// authenticatePeer checks that a Raft RPC carries the cluster secret and
+// writes a 401 response if it does not; authMiddleware calls it for the RPC
+// routes
+func (s *Server) authenticatePeer(w http.ResponseWriter, r *http.Request) bool {
+	if s.cluster.Authenticate(r.Header.Get(cluster.SecretHeader)) {
+		return true
+	}
+	s.writeError(w, http.StatusUnauthorized, "Missing or invalid cluster secret", nil)
+	return false
+}
+
+// RequestVote RPC endpoint
+func (s *Server) handleRaftVote(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		s.writeError(w, http.StatusNotFound, "Clustering is not enabled", nil)
+		return
+	}
+
+	var req cluster.VoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, s.cluster.RequestVote(&req))
+}
+
+// AppendEntries RPC endpoint
+func (s *Server) handleRaftAppend(w http.ResponseWriter, r *http.Request) {
+	if s.cluster == nil {
+		s.writeError(w, http.StatusNotFound, "Clustering is not enabled", nil)
+		return
+	}
+
+	var req cluster.AppendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, s.cluster.AppendEntries(&req))
+}
+
+// redirectIfFollower redirects write requests received by a follower to the
+// current leader. It returns true when the request has been answered.
+func (s *Server) redirectIfFollower(w http.ResponseWriter, r *http.Request) bool {
+	if s.cluster == nil || s.cluster.IsLeader() {
+		return false
+	}
+
+	_, leaderAddr := s.cluster.Leader()
+	if leaderAddr == "" {
+		s.writeError(w, http.StatusServiceUnavailable, "No cluster leader elected", nil)
+		return true
+	}
+
+	// 307 preserves the method and body
+	w.Header().Set("Location", strings.TrimRight(leaderAddr, "/")+r.URL.RequestURI())
+	s.writeError(w, http.StatusTemporaryRedirect, "Not the cluster leader", cluster.ErrNotLeader)
+	return true
+}
+
+// execute applies a write command, through the replicated log in cluster mode
+func (s *Server) execute(ctx context.Context, cmd *cluster.Command) error {
+	// Refused before replication, so that every node applies the same writes
+	if cmd.Op == cluster.OpInsert {
+		if err := s.db.CheckMemory(core.EstimateVectorMemory(cmd.Vectors)); err != nil {
+			return err
+		}
+	}
+	if err := s.apply(ctx, cmd); err != nil {
+		return err
+	}
+	if cmd.Op == cluster.OpInsert {
+		recordStored(ctx, cmd.Vectors)
+	}
+	return nil
+}
+
+// apply applies a write command locally, or replicates it in cluster mode
+func (s *Server) apply(ctx context.Context, cmd *cluster.Command) error {
+	if s.cluster == nil {
+		return cluster.Execute(ctx, s.db, cmd)
+	}
+
+	data, err := cmd.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode command: %w", err)
+	}
+	return s.cluster.Apply(ctx, data)
+}
+
+// insertTexts vectorizes and inserts texts. In cluster mode the embeddings are
+// generated once on the leader and the resulting vectors are replicated.
+func (s *Server) insertTexts(ctx context.Context, collection core.Collection, textVectors []*core.TextVector) error {
+	if s.cluster == nil {
+		if err := s.db.CheckMemory(core.EstimateTextMemory(textVectors, collection.Dimensions())); err != nil {
+			return err
+		}
+		var err error
+		if len(textVectors) == 1 {
+			err = collection.InsertText(ctx, textVectors[0])
+		} else {
+			err = collection.InsertTextBatch(ctx, textVectors)
+		}
+		if err == nil {
+			recordStoredTexts(ctx, collection, textVectors)
+		}
+		return err
+	}
+
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		return fmt.Errorf("invalid collection type")
+	}
+
+	vectors, err := vittoriaCollection.PrepareTextVectors(ctx, textVectors)
+	if err != nil {
+		return err
+	}
+
+	return s.execute(ctx, &cluster.Command{Op: cluster.OpInsert, Collection: collection.Name(), Vectors: vectors, Embeddings: len(vectors)})
+}
+
+// writeIfLeadershipLost answers a replicated write that failed because this
+// node lost leadership while the request was in flight
+func (s *Server) writeIfLeadershipLost(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, cluster.ErrNotLeader) {
+		return false
+	}
+	s.writeError(w, http.StatusServiceUnavailable, "Cluster leadership changed, retry the request", err)
+	return true
+}
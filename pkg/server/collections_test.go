@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// send routes a request with a JSON body through the server
+func send(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestCollectionExpectedCount(t *testing.T) {
+	s := newTestServer(t)
+	expectedCount := func() int {
+		t.Helper()
+		recorder := send(s, http.MethodGet, "/collections/docs", "")
+		var info struct {
+			ExpectedCount int `json:"expected_count"`
+		}
+		if err := json.NewDecoder(recorder.Body).Decode(&info); err != nil {
+			t.Fatalf("invalid collection info: %v", err)
+		}
+		return info.ExpectedCount
+	}
+
+	// Hints that are not counts are refused at creation
+	for _, value := range []string{"-1", "1.5", `"many"`} {
+		if code := send(s, http.MethodPost, "/collections", `{"name": "docs", "dimensions": 2, "expected_count": `+value+`}`).Code; code != http.StatusBadRequest {
+			t.Errorf("expected_count %s: got status %d, want 400", value, code)
+		}
+	}
+
+	// The hint given at creation is reported with the collection
+	if recorder := send(s, http.MethodPost, "/collections", `{"name": "docs", "dimensions": 2, "expected_count": 1000}`); recorder.Code != http.StatusCreated {
+		t.Fatalf("create: got status %d: %s", recorder.Code, recorder.Body)
+	}
+	if count := expectedCount(); count != 1000 {
+		t.Errorf("expected_count is %d after the create, want 1000", count)
+	}
+
+	// and can be changed later
+	if recorder := send(s, http.MethodPut, "/collections/docs", `{"expected_count": 5000}`); recorder.Code != http.StatusOK {
+		t.Fatalf("update: got status %d: %s", recorder.Code, recorder.Body)
+	}
+	if count := expectedCount(); count != 5000 {
+		t.Errorf("expected_count is %d after the update, want 5000", count)
+	}
+	for _, value := range []string{"-1", "1.5", `"many"`, "1000000000"} {
+		if code := send(s, http.MethodPut, "/collections/docs", `{"expected_count": `+value+`}`).Code; code != http.StatusBadRequest {
+			t.Errorf("expected_count %s: got status %d, want 400", value, code)
+		}
+	}
+	if count := expectedCount(); count != 5000 {
+		t.Errorf("expected_count is %d after refused updates, want 5000", count)
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// webFiles holds the dashboard: a static page that reads and writes through
+// the REST API, so it sees only what the caller's API key allows
+//
+//go:embed web
+var webFiles embed.FS
+
+// dashboardFS is webFiles rooted at the web directory
+var dashboardFS, _ = fs.Sub(webFiles, "web")
+
+// handleDashboard serves the dashboard page
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	page, err := fs.ReadFile(dashboardFS, "index.html")
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Dashboard not available", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page)
+}
+
+// handleDashboardAsset serves the dashboard's scripts and stylesheets
+func (s *Server) handleDashboardAsset(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["file"]
+	if _, err := fs.Stat(dashboardFS, name); err != nil {
+		s.writeError(w, http.StatusNotFound, "File not found", err)
+		return
+	}
+
+	// Let the file server pick the content type from the extension
+	w.Header().Del("Content-Type")
+	http.ServeFileFS(w, r, dashboardFS, name)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// deleteVectorsRequest selects the records removed by a batch delete
+type deleteVectorsRequest struct {
+	IDs       []string     `json:"ids,omitempty"`
+	Filter    *core.Filter `json:"filter,omitempty"`
+	Namespace string       `json:"namespace,omitempty"`
+}
+
+// Batch delete endpoint: removes the records given by ID or matching a
+// metadata filter, such as every chunk of a removed document
+func (s *Server) handleDeleteVectors(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	var req deleteVectorsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &req.Namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	if (len(req.IDs) > 0) == (req.Filter != nil) {
+		s.writeError(w, http.StatusBadRequest, "Invalid delete request", fmt.Errorf("a batch delete needs either ids or a filter"))
+		return
+	}
+	if req.Filter != nil {
+		if err := core.ValidateDeleteFilter(req.Filter); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid delete request", err)
+			return
+		}
+	}
+
+	// Count the records before they are deleted, possibly on other nodes
+	matched, err := countPatchTargets(r, collection, &core.MetadataPatchRequest{IDs: uniqueIDs(req.IDs), Filter: req.Filter, Namespace: req.Namespace})
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid delete request", err)
+		return
+	}
+
+	cmd := &cluster.Command{Op: cluster.OpDeleteBatch, Collection: name, IDs: req.IDs, Filter: req.Filter, Namespace: req.Namespace}
+	if err := s.execute(r.Context(), cmd); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		s.writeError(w, http.StatusInternalServerError, "Failed to delete vectors", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "deleted",
+		"deleted": matched,
+	})
+}
+
+// uniqueIDs returns ids without repetitions, in their first order
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,397 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// edgeSyncBatch is how many records a sync inserts at a time
+const edgeSyncBatch = 1000
+
+// edgeTimeout bounds a request to the upstream; syncs stream without one
+const edgeTimeout = 10 * time.Second
+
+// edgeMissWait bounds how long a read of a collection not cached yet waits for
+// its first sync, which goes on in the background
+const edgeMissWait = 2 * time.Second
+
+// edgeCache keeps local copies of collections of an upstream VittoriaDB.
+// Records read by ID are pulled from the upstream when missing or older than
+// the TTL, whole collections are synced periodically, and searches are
+// served from the local copy. A collection read before its first sync is
+// synced in the background, and served from what was pulled after a bounded
+// wait.
+type edgeCache struct {
+	upstream    string
+	apiKey      string
+	ttl         time.Duration
+	interval    time.Duration
+	collections map[string]bool
+	client      *http.Client
+	stop        chan struct{}
+	syncMu      sync.Mutex // Serializes syncs
+
+	mu      sync.Mutex
+	pulled  map[string]time.Time     // When records were last pulled, by collection, namespace and ID
+	synced  map[string]time.Time     // When collections were last synced in full
+	syncing map[string]chan struct{} // Background syncs, closed when they end
+}
+
+// SetEdge makes the server a read-through cache of the configured
+// collections of the upstream and starts syncing them
+func (s *Server) SetEdge(cfg config.EdgeConfig) {
+	e := &edgeCache{
+		upstream:    strings.TrimSuffix(cfg.Upstream, "/"),
+		apiKey:      cfg.APIKey,
+		ttl:         cfg.TTL,
+		interval:    cfg.SyncInterval,
+		collections: make(map[string]bool, len(cfg.Collections)),
+		client:      core.NewHTTPClient(0),
+		stop:        make(chan struct{}),
+		pulled:      make(map[string]time.Time),
+		synced:      make(map[string]time.Time),
+		syncing:     make(map[string]chan struct{}),
+	}
+	for _, name := range cfg.Collections {
+		e.collections[name] = true
+	}
+	s.edge = e
+	go e.run(s.db)
+}
+
+// edgeMiddleware serves the reads of cached collections from the local copy,
+// pulling what is missing or stale first, and redirects their writes to the
+// upstream (no-op until SetEdge)
+func (s *Server) edgeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		template := ""
+		if route := mux.CurrentRoute(r); route != nil {
+			template, _ = route.GetPathTemplate()
+		}
+		name := mux.Vars(r)["name"]
+		if s.edge == nil || !strings.HasPrefix(template, "/collections/{name}") || !s.edge.collections[name] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !isEdgeRead(r, template) {
+			// 307 preserves the method and body
+			w.Header().Set("Location", s.edge.upstream+r.URL.RequestURI())
+			s.writeError(w, http.StatusTemporaryRedirect, "Edge collections are read-only", fmt.Errorf("write to the upstream %s", s.edge.upstream))
+			return
+		}
+
+		if _, err := s.db.GetCollection(r.Context(), name); err != nil {
+			if err := s.edge.pull(r.Context(), s.db, name); err != nil {
+				s.writeError(w, http.StatusBadGateway, "Failed to pull collection from upstream", err)
+				return
+			}
+		}
+		if template == "/collections/{name}/vectors/{id}" {
+			if ns, err := requestNamespace(r); err == nil {
+				s.edge.refresh(r.Context(), s.db, name, ns, mux.Vars(r)["id"])
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isEdgeRead reports whether a request to a collection only reads it
+func isEdgeRead(r *http.Request, template string) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		switch template {
+		case "/collections/{name}/search", "/collections/{name}/search/text", "/collections/{name}/query":
+			return true
+		}
+	}
+	return false
+}
+
+// run syncs every cached collection now and then every interval, until the
+// server stops
+func (e *edgeCache) run(db core.Database) {
+	for {
+		for name := range e.collections {
+			if err := e.sync(context.Background(), db, name); err != nil {
+				log.Printf("Edge: failed to sync collection %s from %s: %v", name, e.upstream, err)
+			}
+		}
+		if e.interval <= 0 {
+			return
+		}
+		select {
+		case <-e.stop:
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// close stops the periodic syncs
+func (e *edgeCache) close() {
+	close(e.stop)
+}
+
+// recordKey identifies a record of a cached collection
+func recordKey(collection, ns, id string) string {
+	return collection + "\x00" + ns + "\x00" + id
+}
+
+// refresh pulls a record from the upstream unless it was pulled or its
+// collection synced within the TTL. A record gone upstream is deleted
+// locally; when the upstream cannot be reached the local copy is served.
+func (e *edgeCache) refresh(ctx context.Context, db core.Database, name, ns, id string) {
+	key := recordKey(name, ns, id)
+	now := time.Now()
+	e.mu.Lock()
+	fresh := now.Sub(e.pulled[key]) < e.ttl || now.Sub(e.synced[name]) < e.ttl
+	e.mu.Unlock()
+	if fresh {
+		return
+	}
+
+	collection, err := db.GetCollection(ctx, name)
+	if err != nil {
+		return
+	}
+	var record core.Vector
+	err = e.get(ctx, "/collections/"+url.PathEscape(name)+"/vectors/"+url.PathEscape(id)+"?namespace="+url.QueryEscape(ns), &record)
+	switch {
+	case err == nil:
+		if err := collection.Insert(ctx, &record); err != nil {
+			log.Printf("Edge: failed to store record %s of %s: %v", id, name, err)
+			return
+		}
+	case isUpstreamNotFound(err):
+		if err := collection.DeleteInNamespace(ctx, ns, id); err != nil && !errors.Is(err, core.ErrNotFound) {
+			log.Printf("Edge: failed to delete record %s of %s: %v", id, name, err)
+			return
+		}
+	default:
+		log.Printf("Edge: failed to pull record %s of %s, serving the local copy: %v", id, name, err)
+		return
+	}
+
+	e.mu.Lock()
+	e.pulled[key] = now
+	e.mu.Unlock()
+}
+
+// pull creates the local copy of a collection read before it was synced and
+// syncs it in the background, waiting for the sync at most edgeMissWait
+func (e *edgeCache) pull(ctx context.Context, db core.Database, name string) error {
+	if _, err := e.localCollection(ctx, db, name); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(edgeMissWait)
+	defer timer.Stop()
+	select {
+	case <-e.syncInBackground(db, name):
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// syncInBackground starts a sync of a collection unless one is running and
+// returns a channel closed when it ends
+func (e *edgeCache) syncInBackground(db core.Database, name string) <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if done, running := e.syncing[name]; running {
+		return done
+	}
+
+	done := make(chan struct{})
+	e.syncing[name] = done
+	go func() {
+		if err := e.sync(context.Background(), db, name); err != nil {
+			log.Printf("Edge: failed to sync collection %s from %s: %v", name, e.upstream, err)
+		}
+		e.mu.Lock()
+		delete(e.syncing, name)
+		e.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// localCollection returns the local copy of a collection, creating it like
+// the upstream's when missing
+func (e *edgeCache) localCollection(ctx context.Context, db core.Database, name string) (*core.VittoriaCollection, error) {
+	var info core.CollectionInfo
+	if err := e.get(ctx, "/collections/"+url.PathEscape(name), &info); err != nil {
+		return nil, err
+	}
+	collection, err := db.GetCollection(ctx, name)
+	if err != nil {
+		err = db.CreateCollection(ctx, &core.CreateCollectionRequest{
+			Name:             name,
+			Dimensions:       info.Dimensions,
+			Metric:           info.Metric,
+			IndexType:        info.IndexType,
+			VectorizerConfig: info.Vectorizer,
+		})
+		if err != nil && !errors.Is(err, core.ErrAlreadyExists) {
+			return nil, fmt.Errorf("failed to create local collection: %w", err)
+		}
+		if collection, err = db.GetCollection(ctx, name); err != nil {
+			return nil, err
+		}
+	} else if collection.Dimensions() != info.Dimensions {
+		return nil, fmt.Errorf("local collection has %d dimensions, the upstream %d", collection.Dimensions(), info.Dimensions)
+	}
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		return nil, fmt.Errorf("invalid collection type")
+	}
+	return vittoriaCollection, nil
+}
+
+// sync makes the local copy of a collection match the upstream: it creates
+// the collection when missing, upserts every upstream record and deletes the
+// records the upstream no longer has
+func (e *edgeCache) sync(ctx context.Context, db core.Database, name string) error {
+	e.syncMu.Lock()
+	defer e.syncMu.Unlock()
+	started := time.Now()
+
+	collection, err := e.localCollection(ctx, db, name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.do(ctx, "/collections/"+url.PathEscape(name)+"/export?format="+core.ExportFormatJSONL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	upstream := make(map[string]bool)
+	batch := make([]*core.Vector, 0, edgeSyncBatch)
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var record core.Vector
+		err := decoder.Decode(&record)
+		if err == nil {
+			upstream[recordKey(name, record.Namespace, record.ID)] = true
+			batch = append(batch, &record)
+		}
+		if len(batch) == edgeSyncBatch || err != nil && len(batch) > 0 {
+			if err := collection.InsertBatch(ctx, batch); err != nil {
+				return fmt.Errorf("failed to store records: %w", err)
+			}
+			batch = batch[:0]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read export: %w", err)
+		}
+	}
+	if message := resp.Trailer.Get(ExportErrorTrailer); message != "" {
+		return fmt.Errorf("upstream export failed: %s", message)
+	}
+
+	// Only a complete export tells which records are gone
+	removed := 0
+	namespaces, err := collection.Namespaces()
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		local, err := collection.Query(ctx, &core.QueryRequest{Namespace: namespace.Name, Limit: math.MaxInt32})
+		if err != nil {
+			return err
+		}
+		for _, record := range local.Records {
+			if upstream[recordKey(name, record.Namespace, record.ID)] {
+				continue
+			}
+			if err := collection.DeleteInNamespace(ctx, record.Namespace, record.ID); err != nil {
+				return fmt.Errorf("failed to delete record %s: %w", record.ID, err)
+			}
+			removed++
+		}
+	}
+
+	e.mu.Lock()
+	e.synced[name] = started
+	e.mu.Unlock()
+	log.Printf("Edge: synced collection %s from %s (%d records, %d removed)", name, e.upstream, len(upstream), removed)
+	return nil
+}
+
+// upstreamError is the upstream's answer to a failed request
+type upstreamError struct {
+	status int
+	body   string
+}
+
+func (e *upstreamError) Error() string {
+	return fmt.Sprintf("upstream returned status %d: %s", e.status, e.body)
+}
+
+// isUpstreamNotFound reports whether err is the upstream's 404
+func isUpstreamNotFound(err error) bool {
+	upstream, ok := err.(*upstreamError)
+	return ok && upstream.status == http.StatusNotFound
+}
+
+// get requests a path of the upstream and decodes the JSON answer into out
+func (e *edgeCache) get(ctx context.Context, path string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, edgeTimeout)
+	defer cancel()
+
+	resp, err := e.do(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid upstream response: %w", err)
+	}
+	return nil
+}
+
+// do sends a GET request to the upstream; statuses other than 200 are
+// returned as an *upstreamError
+func (e *edgeCache) do(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.upstream+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach upstream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &upstreamError{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
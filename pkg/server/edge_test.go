@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// newEdge returns an upstream server behind an httptest server, and an edge
+// server caching its collection docs. handler wraps the upstream's router.
+func newEdge(t *testing.T, cfg config.EdgeConfig, handler func(http.Handler) http.Handler) (upstream, edge *Server, url string) {
+	t.Helper()
+	upstream = newTestServer(t)
+	server := httptest.NewServer(handler(upstream.router))
+	t.Cleanup(server.Close)
+
+	edge = newTestServer(t)
+	cfg.Upstream = server.URL
+	cfg.Collections = []string{"docs"}
+	edge.SetEdge(cfg)
+	t.Cleanup(edge.edge.close)
+	return upstream, edge, server.URL
+}
+
+// upsert creates the upstream collection docs when missing and writes a record to it
+func upsert(t *testing.T, upstream *Server, id, version string) {
+	t.Helper()
+	ctx := context.Background()
+	collection, err := upstream.db.GetCollection(ctx, "docs")
+	if err != nil {
+		if err := upstream.db.CreateCollection(ctx, &core.CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: core.DistanceMetricCosine, IndexType: core.IndexTypeFlat}); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+		collection, _ = upstream.db.GetCollection(ctx, "docs")
+	}
+	if err := collection.Insert(ctx, &core.Vector{ID: id, Vector: []float32{1, 0}, Metadata: map[string]interface{}{"version": version}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+}
+
+// edgeVersion reads a record through the edge and returns its version, or
+// the status of a failed read
+func edgeVersion(t *testing.T, edge *Server, id string) string {
+	t.Helper()
+	recorder := serve(edge, http.MethodGet, "/collections/docs/vectors/"+id, "192.0.2.1:1234")
+	if recorder.Code != http.StatusOK {
+		return http.StatusText(recorder.Code)
+	}
+	var vector core.Vector
+	if err := json.NewDecoder(recorder.Body).Decode(&vector); err != nil {
+		t.Fatalf("invalid record: %v", err)
+	}
+	version, _ := vector.Metadata["version"].(string)
+	return version
+}
+
+// localCount returns how many records the edge holds for docs, -1 when it
+// has no copy
+func localCount(edge *Server) int64 {
+	collection, err := edge.db.GetCollection(context.Background(), "docs")
+	if err != nil {
+		return -1
+	}
+	count, _ := collection.Count()
+	return count
+}
+
+// waitFor polls condition for up to five seconds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEdgePullOnMiss(t *testing.T) {
+	// The upstream's export hangs until released, so a sync can't finish
+	release := make(chan struct{})
+	missing := make(chan struct{}, 1)
+	upstream, edge, _ := newEdge(t, config.EdgeConfig{TTL: time.Hour}, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/export") {
+				<-release
+			}
+			next.ServeHTTP(w, r)
+			if r.URL.Path == "/collections/docs" {
+				select {
+				case missing <- struct{}{}:
+				default:
+				}
+			}
+		})
+	})
+	defer close(release)
+
+	// The sync at startup finds no collection upstream
+	<-missing
+	upsert(t, upstream, "a", "1")
+	upsert(t, upstream, "b", "1")
+
+	// The read is served after a bounded wait, with the record it pulled alone
+	read := make(chan string)
+	go func() { read <- edgeVersion(t, edge, "a") }()
+	select {
+	case version := <-read:
+		if version != "1" {
+			t.Fatalf("got %s, want version 1 of the record", version)
+		}
+	case <-time.After(edgeMissWait + 3*time.Second):
+		t.Fatal("the read waited for the whole collection")
+	}
+	if count := localCount(edge); count != 1 {
+		t.Errorf("the edge holds %d records, want the one read", count)
+	}
+
+	// while the rest of the collection arrives in the background
+	release <- struct{}{}
+	waitFor(t, "the background sync", func() bool { return localCount(edge) == 2 })
+}
+
+func TestEdgeTTL(t *testing.T) {
+	upstream, edge, _ := newEdge(t, config.EdgeConfig{TTL: 100 * time.Millisecond}, func(next http.Handler) http.Handler { return next })
+	upsert(t, upstream, "a", "1")
+	waitFor(t, "the first read", func() bool { return edgeVersion(t, edge, "a") == "1" })
+
+	// Within the TTL the cached record is served
+	upsert(t, upstream, "a", "2")
+	if version := edgeVersion(t, edge, "a"); version != "1" {
+		t.Errorf("got version %s within the TTL, want the cached 1", version)
+	}
+
+	// Past it the record is pulled again
+	time.Sleep(150 * time.Millisecond)
+	if version := edgeVersion(t, edge, "a"); version != "2" {
+		t.Errorf("got version %s past the TTL, want 2", version)
+	}
+
+	// and deleted when gone upstream
+	collection, _ := upstream.db.GetCollection(context.Background(), "docs")
+	if err := collection.Delete(context.Background(), "a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if status := edgeVersion(t, edge, "a"); status != http.StatusText(http.StatusNotFound) {
+		t.Errorf("got %s for a record deleted upstream, want Not Found", status)
+	}
+}
+
+func TestEdgePeriodicSync(t *testing.T) {
+	upstream, edge, _ := newEdge(t, config.EdgeConfig{TTL: time.Hour, SyncInterval: 50 * time.Millisecond}, func(next http.Handler) http.Handler { return next })
+	upsert(t, upstream, "a", "1")
+	waitFor(t, "a sync", func() bool { return localCount(edge) == 1 })
+
+	// Records written and deleted upstream reach the edge without being read
+	upsert(t, upstream, "b", "1")
+	collection, _ := upstream.db.GetCollection(context.Background(), "docs")
+	if err := collection.Delete(context.Background(), "a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	waitFor(t, "the next sync", func() bool {
+		local, err := edge.db.GetCollection(context.Background(), "docs")
+		if err != nil {
+			return false
+		}
+		_, errA := local.Get(context.Background(), "a")
+		_, errB := local.Get(context.Background(), "b")
+		return errA != nil && errB == nil
+	})
+}
+
+func TestEdgeRedirectsWrites(t *testing.T) {
+	_, edge, url := newEdge(t, config.EdgeConfig{TTL: time.Hour}, func(next http.Handler) http.Handler { return next })
+
+	recorder := serve(edge, http.MethodPost, "/collections/docs/vectors?namespace=ns", "192.0.2.1:1234")
+	if recorder.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("write to a cached collection: got status %d, want 307", recorder.Code)
+	}
+	if location := recorder.Header().Get("Location"); location != url+"/collections/docs/vectors?namespace=ns" {
+		t.Errorf("redirected to %q", location)
+	}
+
+	// Other collections are written locally
+	if code := serve(edge, http.MethodPost, "/collections/other/vectors", "192.0.2.1:1234").Code; code == http.StatusTemporaryRedirect {
+		t.Error("a write to a collection that is not cached was redirected")
+	}
+}
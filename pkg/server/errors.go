@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// Error codes of the API besides the core ones (core.CodeNotFound, ...).
+// Codes are stable: clients may switch on them, messages may change.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeRateLimited      = "rate_limited"
+	CodeNotLeader        = "not_leader"
+	CodeRedirect         = "redirect"
+	CodeUpstream         = "upstream_error"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal"
+)
+
+// ErrorResponse is the body of every error answer of the API
+type ErrorResponse struct {
+	Error   string `json:"error"`             // Human-readable summary
+	Code    string `json:"code"`              // Machine-readable code
+	Details string `json:"details,omitempty"` // The underlying error, if any
+	Status  int    `json:"status"`
+	Time    int64  `json:"time"`
+}
+
+// newErrorResponse builds the body of an error answer
+func newErrorResponse(status int, message string, err error) *ErrorResponse {
+	response := &ErrorResponse{
+		Error:  message,
+		Code:   errorCode(status, err),
+		Status: status,
+		Time:   time.Now().Unix(),
+	}
+	if err != nil {
+		response.Details = err.Error()
+	}
+	return response
+}
+
+// errorCode returns the code of err, falling back to one derived from the
+// status when err is not of a known kind
+func errorCode(status int, err error) string {
+	if code := core.ErrorCode(err); code != "" {
+		return code
+	}
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		return core.CodeNotFound
+	case errors.Is(err, auth.ErrKeyExists):
+		return core.CodeAlreadyExists
+	case errors.Is(err, cluster.ErrNotLeader):
+		return CodeNotLeader
+	}
+
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return core.CodeNotFound
+	case http.StatusConflict:
+		return core.CodeConflict
+	case http.StatusPreconditionFailed:
+		return core.CodeConditionFailed
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusTemporaryRedirect:
+		return CodeRedirect
+	case http.StatusBadGateway:
+		return CodeUpstream
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
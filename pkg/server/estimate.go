@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+	"github.com/antonellof/VittoriaDB/pkg/estimate"
+	"github.com/antonellof/VittoriaDB/pkg/processor"
+)
+
+// estimateRequest describes the documents to estimate and where they would go.
+// With a collection, its dimensions, index type and vectorizer are used.
+type estimateRequest struct {
+	Collection            string                       `json:"collection,omitempty"`
+	Manifest              []estimate.ManifestEntry     `json:"manifest,omitempty"`
+	Dimensions            int                          `json:"dimensions,omitempty"`
+	IndexType             *core.IndexType              `json:"index_type,omitempty"`
+	VectorizerConfig      *embeddings.VectorizerConfig `json:"vectorizer_config,omitempty"`
+	PricePerMillionTokens *float64                     `json:"price_per_million_tokens,omitempty"`
+	ChunkSize             int                          `json:"chunk_size,omitempty"`
+	ChunkOverlap          int                          `json:"chunk_overlap,omitempty"`
+}
+
+// handleEstimate estimates the cost of ingesting documents without ingesting
+// them. It takes either a JSON request with a manifest, or a multipart form
+// with "file" parts and the JSON request in a "request" field.
+func (s *Server) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	var req estimateRequest
+	multipart := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+	if multipart {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Failed to parse multipart form", err)
+			return
+		}
+		if value := r.FormValue("request"); value != "" {
+			if err := json.Unmarshal([]byte(value), &req); err != nil {
+				s.writeError(w, http.StatusBadRequest, "Invalid JSON in request field", err)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	target, err := s.estimateTarget(w, r, &req)
+	if err != nil {
+		return
+	}
+
+	processing := processor.DefaultProcessingConfig()
+	if req.ChunkSize > 0 {
+		processing.ChunkSize = req.ChunkSize
+	}
+	if req.ChunkOverlap > 0 {
+		processing.ChunkOverlap = req.ChunkOverlap
+	}
+	estimator := estimate.NewEstimator(processing)
+
+	var files []*estimate.FileEstimate
+	if multipart && r.MultipartForm != nil {
+		for _, header := range r.MultipartForm.File["file"] {
+			file, err := header.Open()
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, "Failed to read uploaded file", err)
+				return
+			}
+			files = append(files, estimator.Document(file, header.Filename))
+			file.Close()
+		}
+	}
+	for _, entry := range req.Manifest {
+		files = append(files, estimator.ManifestEntry(entry))
+	}
+	if len(files) == 0 {
+		s.writeError(w, http.StatusBadRequest, "No documents to estimate", fmt.Errorf("upload files or send a manifest"))
+		return
+	}
+
+	result, err := estimate.Summarize(files, target)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Failed to estimate ingestion", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+// estimateTarget returns where an estimate's documents would be ingested: the
+// named collection, else the request's settings over the server defaults. It
+// writes the error response itself.
+func (s *Server) estimateTarget(w http.ResponseWriter, r *http.Request, req *estimateRequest) (*estimate.Target, error) {
+	target := &estimate.Target{
+		Dimensions:            req.Dimensions,
+		Vectorizer:            req.VectorizerConfig,
+		PricePerMillionTokens: req.PricePerMillionTokens,
+	}
+
+	if req.Collection != "" {
+		if !s.authorize(w, r, req.Collection, auth.PermissionRead) {
+			return nil, fmt.Errorf("forbidden")
+		}
+		collection, err := s.db.GetCollection(r.Context(), req.Collection)
+		if err != nil {
+			if errors.Is(err, core.ErrNotFound) {
+				s.writeError(w, http.StatusNotFound, "Collection not found", err)
+			} else {
+				s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+			}
+			return nil, err
+		}
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			err := fmt.Errorf("invalid collection type")
+			s.writeError(w, http.StatusInternalServerError, "Invalid collection type", err)
+			return nil, err
+		}
+		info, err := vittoriaCollection.Info()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection info", err)
+			return nil, err
+		}
+		target.Dimensions = info.Dimensions
+		target.IndexType = info.IndexType
+		target.Vectorizer = info.Vectorizer
+		return target, nil
+	}
+
+	cfg := s.currentConfig()
+	if req.IndexType != nil {
+		target.IndexType = *req.IndexType
+	} else if cfg != nil {
+		if indexType, err := core.ParseIndexType(cfg.Search.Index.DefaultType); err == nil {
+			target.IndexType = indexType
+		}
+	}
+	if target.Vectorizer == nil && target.Dimensions <= 0 && cfg != nil {
+		target.Vectorizer = cfg.Embeddings.Default.Vectorizer()
+	}
+	s.applyVectorizerDefaults(target.Vectorizer)
+	return target, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// Trailers ending an export with the number of records written and, when it
+// failed partway, the error
+const (
+	ExportCountTrailer = "X-Export-Count"
+	ExportErrorTrailer = "X-Export-Error"
+)
+
+// exportContentTypes are the media types of the export formats
+var exportContentTypes = map[string]string{
+	core.ExportFormatJSONL:   "application/x-ndjson",
+	core.ExportFormatParquet: "application/vnd.apache.parquet",
+}
+
+// handleExport streams every record of a collection as JSON lines or Parquet.
+// Requests scoped to a namespace export only that namespace.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = core.ExportFormatJSONL
+	}
+	if !slices.Contains(core.ExportFormats, format) {
+		s.writeError(w, http.StatusBadRequest, "Invalid format", fmt.Errorf("expected one of %s", strings.Join(core.ExportFormats, ", ")))
+		return
+	}
+	req := &core.ExportRequest{Format: format}
+	scope, err := requestNamespace(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+	if scope != "" {
+		req.Namespace = &scope
+	}
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	w.Header().Set("Trailer", ExportCountTrailer+", "+ExportErrorTrailer)
+	count, err := vittoriaCollection.Export(r.Context(), w, req)
+	w.Header().Set(ExportCountTrailer, strconv.Itoa(count))
+	if err != nil {
+		// Headers are sent, so the failure is reported in a trailer
+		log.Printf("Failed to export collection %s: %v", name, err)
+		w.Header().Set(ExportErrorTrailer, err.Error())
+	}
+}
@@ -0,0 +1,407 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/flight"
+)
+
+// FlightRoute is the route of the Arrow Flight RPCs, served over gRPC on the
+// API port
+const FlightRoute = flight.ServicePath + "{method}"
+
+// flightBatchSize is how many records each record batch of a DoGet holds
+const flightBatchSize = 4096
+
+// flightTicket names the records of a stream: a collection, and optionally
+// one of its namespaces. Tickets are its JSON, or a bare collection name.
+type flightTicket struct {
+	Collection string  `json:"collection"`
+	Namespace  *string `json:"namespace,omitempty"`
+}
+
+// parseFlightTicket decodes a ticket
+func parseFlightTicket(data []byte) (*flightTicket, error) {
+	ticket := &flightTicket{}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, ticket); err != nil {
+			return nil, flight.Errorf(flight.CodeInvalidArgument, "invalid ticket: %v", err)
+		}
+	} else {
+		ticket.Collection = string(data)
+	}
+	if ticket.Collection == "" {
+		return nil, flight.Errorf(flight.CodeInvalidArgument, "ticket names no collection")
+	}
+	return ticket, nil
+}
+
+// parseFlightDescriptor decodes a descriptor: a path [collection] or
+// [collection, namespace], or a command holding a ticket
+func parseFlightDescriptor(d *flight.FlightDescriptor) (*flightTicket, error) {
+	switch d.Type {
+	case flight.DescriptorPath:
+		if len(d.Path) == 0 || len(d.Path) > 2 {
+			return nil, flight.Errorf(flight.CodeInvalidArgument, "descriptor path must be [collection] or [collection, namespace]")
+		}
+		ticket := &flightTicket{Collection: d.Path[0]}
+		if len(d.Path) == 2 {
+			ticket.Namespace = &d.Path[1]
+		}
+		return ticket, nil
+	case flight.DescriptorCmd:
+		return parseFlightTicket(d.Cmd)
+	}
+	return nil, flight.Errorf(flight.CodeInvalidArgument, "descriptor has no path or command")
+}
+
+// Flight endpoint: serves the Arrow Flight RPCs, which exchange the records
+// of collections as Arrow record batches
+func (s *Server) handleFlight(w http.ResponseWriter, r *http.Request) {
+	stream, err := flight.NewStream(w, r)
+	if err != nil {
+		status := http.StatusUnsupportedMediaType
+		if r.ProtoMajor != 2 {
+			status = http.StatusBadRequest
+		}
+		s.writeError(w, status, "Invalid Flight request", err)
+		return
+	}
+
+	// Streams may outlast the server's read and write timeouts
+	controller := http.NewResponseController(w)
+	controller.SetReadDeadline(time.Time{})
+	controller.SetWriteDeadline(time.Time{})
+
+	switch method := stream.Method(); method {
+	case "Handshake":
+		err = s.flightHandshake(stream)
+	case "ListFlights":
+		err = s.flightListFlights(stream, r)
+	case "GetFlightInfo":
+		err = s.flightGetFlightInfo(stream, r)
+	case "GetSchema":
+		err = s.flightGetSchema(stream, r)
+	case "DoGet":
+		err = s.flightDoGet(stream, r)
+	case "DoPut":
+		err = s.flightDoPut(stream, r)
+	case "ListActions":
+		// No actions
+	default:
+		err = flight.Errorf(flight.CodeUnimplemented, "%s is not supported", method)
+	}
+
+	status := flightStatus(err)
+	if status != nil && status.Code == flight.CodeInternal {
+		log.Printf("Flight %s failed: %s", stream.Method(), status.Message)
+	}
+	stream.Finish(status)
+}
+
+// flightStatus converts an error into the gRPC status reported for it, nil
+// for no error
+func flightStatus(err error) *flight.Status {
+	var status *flight.Status
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &status):
+		return status
+	case errors.Is(err, core.ErrNotFound):
+		return flight.Errorf(flight.CodeNotFound, "%v", err)
+	case errors.Is(err, core.ErrAlreadyExists):
+		return flight.Errorf(flight.CodeAlreadyExists, "%v", err)
+	case errors.Is(err, core.ErrDimensionMismatch):
+		return flight.Errorf(flight.CodeInvalidArgument, "%v", err)
+	case errors.Is(err, core.ErrConditionFailed):
+		return flight.Errorf(flight.CodeFailedPrecondition, "%v", err)
+	case errors.Is(err, core.ErrReadOnly):
+		return flight.Errorf(flight.CodePermissionDenied, "%v", err)
+	case errors.Is(err, core.ErrClosed), errors.Is(err, cluster.ErrNotLeader):
+		return flight.Errorf(flight.CodeUnavailable, "%v", err)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return flight.Errorf(flight.CodeUnavailable, "%v", err)
+	}
+	return flight.Errorf(flight.CodeInternal, "%v", err)
+}
+
+// flightAuthorize checks that the request's key grants p on a collection
+func flightAuthorize(r *http.Request, collection string, p auth.Permission) error {
+	key := requestAPIKey(r)
+	if key == nil || key.Allows(collection, p) {
+		return nil
+	}
+	return flight.Errorf(flight.CodePermissionDenied, "key '%s' does not grant %s access to collection '%s'", key.Name, p, collection)
+}
+
+// flightCollection returns the collection of a ticket, checked against the
+// key and namespace of the request. Edge collections are pulled first when
+// there is no local copy yet.
+func (s *Server) flightCollection(r *http.Request, ticket *flightTicket, p auth.Permission) (*core.VittoriaCollection, error) {
+	if err := flightAuthorize(r, ticket.Collection, p); err != nil {
+		return nil, err
+	}
+	scope, err := requestNamespace(r)
+	if err != nil {
+		return nil, flight.Errorf(flight.CodeInvalidArgument, "invalid namespace: %v", err)
+	}
+	if scope != "" {
+		if ticket.Namespace == nil {
+			ticket.Namespace = &scope
+		} else if *ticket.Namespace != scope {
+			return nil, flight.Errorf(flight.CodePermissionDenied, "namespace '%s' does not match request namespace '%s'", *ticket.Namespace, scope)
+		}
+	}
+
+	if s.edge != nil && s.edge.collections[ticket.Collection] {
+		if p != auth.PermissionRead {
+			return nil, flight.Errorf(flight.CodeFailedPrecondition, "edge collections are read-only: write to the upstream %s", s.edge.upstream)
+		}
+		if _, err := s.db.GetCollection(r.Context(), ticket.Collection); err != nil {
+			if err := s.edge.sync(r.Context(), s.db, ticket.Collection); err != nil {
+				return nil, flight.Errorf(flight.CodeUnavailable, "failed to pull collection from upstream: %v", err)
+			}
+		}
+	}
+
+	collection, err := s.db.GetCollection(r.Context(), ticket.Collection)
+	if err != nil {
+		return nil, err
+	}
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		return nil, flight.Errorf(flight.CodeInternal, "invalid collection type")
+	}
+	return vittoriaCollection, nil
+}
+
+// flightInfo describes the records of a ticket
+func (s *Server) flightInfo(r *http.Request, ticket *flightTicket, descriptor *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	collection, err := s.flightCollection(r, ticket, auth.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+	records, err := collection.ExportRecords(ticket.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return nil, err
+	}
+	return &flight.FlightInfo{
+		Schema:       flight.NewRecordSchema(collection.Dimensions(), records).IPC(),
+		Descriptor:   descriptor,
+		Endpoints:    []*flight.FlightEndpoint{{Ticket: &flight.Ticket{Ticket: data}}},
+		TotalRecords: int64(len(records)),
+		TotalBytes:   -1,
+		Ordered:      true,
+	}, nil
+}
+
+// flightHandshake answers every handshake request; clients authenticate
+// with the API key headers of every call instead
+func (s *Server) flightHandshake(stream *flight.Stream) error {
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.Send((&flight.HandshakeResponse{}).Marshal()); err != nil {
+			return err
+		}
+	}
+}
+
+// flightListFlights lists a flight per collection the key can read
+func (s *Server) flightListFlights(stream *flight.Stream, r *http.Request) error {
+	if _, err := stream.RecvOne(); err != nil {
+		return err
+	}
+	collections, err := s.db.ListCollections(r.Context())
+	if err != nil {
+		return err
+	}
+	for _, info := range collections {
+		if flightAuthorize(r, info.Name, auth.PermissionRead) != nil {
+			continue
+		}
+		descriptor := &flight.FlightDescriptor{Type: flight.DescriptorPath, Path: []string{info.Name}}
+		flightInfo, err := s.flightInfo(r, &flightTicket{Collection: info.Name}, descriptor)
+		if errors.Is(err, core.ErrNotFound) {
+			// Dropped since it was listed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(flightInfo.Marshal()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recvFlightDescriptor reads the descriptor of a unary call
+func recvFlightDescriptor(stream *flight.Stream) (*flight.FlightDescriptor, *flightTicket, error) {
+	msg, err := stream.RecvOne()
+	if err != nil {
+		return nil, nil, err
+	}
+	descriptor := &flight.FlightDescriptor{}
+	if err := descriptor.Unmarshal(msg); err != nil {
+		return nil, nil, flight.Errorf(flight.CodeInvalidArgument, "invalid descriptor: %v", err)
+	}
+	ticket, err := parseFlightDescriptor(descriptor)
+	return descriptor, ticket, err
+}
+
+// flightGetFlightInfo describes the records of a descriptor
+func (s *Server) flightGetFlightInfo(stream *flight.Stream, r *http.Request) error {
+	descriptor, ticket, err := recvFlightDescriptor(stream)
+	if err != nil {
+		return err
+	}
+	info, err := s.flightInfo(r, ticket, descriptor)
+	if err != nil {
+		return err
+	}
+	return stream.Send(info.Marshal())
+}
+
+// flightGetSchema returns the schema of the records of a descriptor
+func (s *Server) flightGetSchema(stream *flight.Stream, r *http.Request) error {
+	descriptor, ticket, err := recvFlightDescriptor(stream)
+	if err != nil {
+		return err
+	}
+	info, err := s.flightInfo(r, ticket, descriptor)
+	if err != nil {
+		return err
+	}
+	return stream.Send((&flight.SchemaResult{Schema: info.Schema}).Marshal())
+}
+
+// flightDoGet streams the records of a ticket, ordered by namespace and ID,
+// in record batches of flightBatchSize
+func (s *Server) flightDoGet(stream *flight.Stream, r *http.Request) error {
+	msg, err := stream.RecvOne()
+	if err != nil {
+		return err
+	}
+	var t flight.Ticket
+	if err := t.Unmarshal(msg); err != nil {
+		return flight.Errorf(flight.CodeInvalidArgument, "invalid ticket: %v", err)
+	}
+	ticket, err := parseFlightTicket(t.Ticket)
+	if err != nil {
+		return err
+	}
+	collection, err := s.flightCollection(r, ticket, auth.PermissionRead)
+	if err != nil {
+		return err
+	}
+	records, err := collection.ExportRecords(ticket.Namespace)
+	if err != nil {
+		return err
+	}
+
+	schema := flight.NewRecordSchema(collection.Dimensions(), records)
+	if err := stream.Send((&flight.FlightData{DataHeader: schema.Header()}).Marshal()); err != nil {
+		return err
+	}
+	for start := 0; start < len(records); start += flightBatchSize {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		header, body := schema.Batch(records[start:min(start+flightBatchSize, len(records))])
+		if err := stream.Send((&flight.FlightData{DataHeader: header, DataBody: body}).Marshal()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flightDoPut inserts the record batches of an upload into the collection
+// of its descriptor, batch by batch as the JSON batch endpoint does, and
+// answers with the number of records inserted
+func (s *Server) flightDoPut(stream *flight.Stream, r *http.Request) error {
+	if s.cluster != nil && !s.cluster.IsLeader() {
+		_, leaderAddr := s.cluster.Leader()
+		return flight.Errorf(flight.CodeUnavailable, "not the cluster leader: send writes to %s", leaderAddr)
+	}
+
+	var ticket *flightTicket
+	var decoder flight.Decoder
+	inserted := 0
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var data flight.FlightData
+		if err := data.Unmarshal(msg); err != nil {
+			return flight.Errorf(flight.CodeInvalidArgument, "invalid flight data: %v", err)
+		}
+
+		if ticket == nil {
+			if data.Descriptor == nil {
+				return flight.Errorf(flight.CodeInvalidArgument, "the first message must carry a descriptor")
+			}
+			if ticket, err = parseFlightDescriptor(data.Descriptor); err != nil {
+				return err
+			}
+			if _, err := s.flightCollection(r, ticket, auth.PermissionWrite); err != nil {
+				return err
+			}
+		}
+
+		records, err := decoder.Decode(data.DataHeader, data.DataBody)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		if ticket.Namespace != nil {
+			for _, record := range records {
+				if err := scopeNamespace(*ticket.Namespace, &record.Namespace); err != nil {
+					return flight.Errorf(flight.CodeInvalidArgument, "%v", err)
+				}
+			}
+		}
+
+		if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpInsert, Collection: ticket.Collection, Vectors: records}); err != nil {
+			status := flightStatus(err)
+			if status.Code == flight.CodeInternal {
+				// As for the JSON batch endpoint, failed inserts are the request's fault
+				status.Code = flight.CodeInvalidArgument
+			}
+			return flight.Errorf(status.Code, "%s (%d records were inserted before)", status.Message, inserted)
+		}
+		inserted += len(records)
+	}
+	if ticket == nil {
+		return flight.Errorf(flight.CodeInvalidArgument, "no descriptor was sent")
+	}
+
+	result, err := json.Marshal(map[string]int{"inserted": inserted})
+	if err != nil {
+		return err
+	}
+	return stream.Send((&flight.PutResult{AppMetadata: result}).Marshal())
+}
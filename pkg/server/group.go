@@ -0,0 +1,328 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// groupRecordRequest is a record of a collection group: a vector or a text
+// for some of its fields, stored under the same ID in each field's collection
+type groupRecordRequest struct {
+	ID        string                 `json:"id"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Vectors   map[string][]float32   `json:"vectors,omitempty"`
+	Texts     map[string]string      `json:"texts,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// handleGroups lists (GET) or creates (POST) collection groups
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		s.handleCreateGroup(w, r)
+		return
+	}
+
+	groups, err := s.db.ListGroups(r.Context())
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to list groups", err)
+		return
+	}
+
+	// Only show the groups the API key can access
+	if key := requestAPIKey(r); key != nil && !key.AllCollections() {
+		visible := groups[:0]
+		for _, group := range groups {
+			if key.CanAccess(group.Name) {
+				visible = append(visible, group)
+			}
+		}
+		groups = visible
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"groups": groups,
+		"count":  len(groups),
+	})
+}
+
+// handleCreateGroup creates a collection group and its collections
+func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	var req core.CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	if !s.authorize(w, r, req.Name, auth.PermissionAdmin) {
+		return
+	}
+	for i := range req.Fields {
+		s.applyVectorizerDefaults(req.Fields[i].VectorizerConfig)
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpCreateGroup, CreateGroup: &req}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrAlreadyExists) {
+			s.writeError(w, http.StatusConflict, "Group or collection already exists", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to create group", err)
+		}
+		return
+	}
+
+	group, err := s.db.GetGroup(r.Context(), req.Name)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to get group", err)
+		return
+	}
+	s.writeJSON(w, http.StatusCreated, group)
+}
+
+// handleGroup returns (GET) or drops (DELETE) a collection group
+func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if r.Method == http.MethodGet {
+		group, err := s.db.GetGroup(r.Context(), name)
+		if err != nil {
+			s.writeGroupError(w, err, "Failed to get group")
+			return
+		}
+		s.writeJSON(w, http.StatusOK, group)
+		return
+	}
+
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpDropGroup, Group: name}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		s.writeGroupError(w, err, "Failed to drop group")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]string{
+		"status": "dropped",
+		"group":  name,
+	})
+}
+
+// handleGroupRecords inserts a record into the collections of the fields it
+// has a vector or a text for. Texts are vectorized by their field's
+// vectorizer.
+func (s *Server) handleGroupRecords(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+	name := mux.Vars(r)["name"]
+
+	var req groupRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	if req.ID == "" {
+		s.writeError(w, http.StatusBadRequest, "Record ID is required", nil)
+		return
+	}
+	if len(req.Vectors)+len(req.Texts) == 0 {
+		s.writeError(w, http.StatusBadRequest, "Record has no fields", fmt.Errorf("send vectors or texts by field name"))
+		return
+	}
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &req.Namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	// Check every field before writing any
+	group, err := s.db.GetGroup(r.Context(), name)
+	if err != nil {
+		s.writeGroupError(w, err, "Failed to get group")
+		return
+	}
+	fields := make(map[string]bool, len(group.Fields))
+	for _, field := range group.Fields {
+		fields[field.Name] = true
+	}
+	for field := range req.Vectors {
+		if !fields[field] {
+			s.writeError(w, http.StatusBadRequest, "Unknown field", fmt.Errorf("group '%s' has no field '%s'", name, field))
+			return
+		}
+		if _, text := req.Texts[field]; text {
+			s.writeError(w, http.StatusBadRequest, "Field given twice", fmt.Errorf("field '%s' has both a vector and a text", field))
+			return
+		}
+	}
+	for field := range req.Texts {
+		if !fields[field] {
+			s.writeError(w, http.StatusBadRequest, "Unknown field", fmt.Errorf("group '%s' has no field '%s'", name, field))
+			return
+		}
+	}
+
+	var inserted []string
+	for _, field := range group.Fields {
+		vector, hasVector := req.Vectors[field.Name]
+		text, hasText := req.Texts[field.Name]
+		if !hasVector && !hasText {
+			continue
+		}
+
+		if hasVector {
+			err = s.execute(r.Context(), &cluster.Command{Op: cluster.OpInsert, Collection: field.Collection, Vectors: []*core.Vector{{
+				ID:        req.ID,
+				Namespace: req.Namespace,
+				Vector:    vector,
+				Metadata:  req.Metadata,
+			}}})
+		} else {
+			var collection core.Collection
+			if collection, err = s.db.GetCollection(r.Context(), field.Collection); err == nil {
+				err = s.insertTexts(r.Context(), collection, []*core.TextVector{{
+					ID:        req.ID,
+					Namespace: req.Namespace,
+					Text:      text,
+					Metadata:  req.Metadata,
+				}})
+			}
+		}
+		if err != nil {
+			if s.writeIfLeadershipLost(w, err) {
+				return
+			}
+			s.writeError(w, http.StatusBadRequest, "Failed to insert record",
+				fmt.Errorf("field '%s' (fields inserted before it: %v): %w", field.Name, inserted, err))
+			return
+		}
+		inserted = append(inserted, field.Name)
+	}
+
+	s.writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"status": "inserted",
+		"id":     req.ID,
+		"fields": inserted,
+	})
+}
+
+// handleGroupRecord deletes a record from every collection of a group
+func (s *Server) handleGroupRecord(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+	vars := mux.Vars(r)
+	name, id := vars["name"], vars["id"]
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+	group, err := s.db.GetGroup(r.Context(), name)
+	if err != nil {
+		s.writeGroupError(w, err, "Failed to get group")
+		return
+	}
+
+	var deleted []string
+	for _, field := range group.Fields {
+		err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpDelete, Collection: field.Collection, IDs: []string{id}, Namespace: ns})
+		if err != nil {
+			if s.writeIfLeadershipLost(w, err) {
+				return
+			}
+			// Records need not have every field
+			if errors.Is(err, core.ErrNotFound) {
+				continue
+			}
+			s.writeError(w, http.StatusInternalServerError, "Failed to delete record", err)
+			return
+		}
+		deleted = append(deleted, field.Name)
+	}
+	if len(deleted) == 0 {
+		s.writeError(w, http.StatusNotFound, "Record not found", fmt.Errorf("record '%s' not found in group '%s'", id, name))
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "deleted",
+		"id":     id,
+		"fields": deleted,
+	})
+}
+
+// handleGroupSearch searches several fields of a group and fuses the results
+func (s *Server) handleGroupSearch(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req core.GroupSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &req.Namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	results, err := s.db.SearchGroup(r.Context(), name, &req)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Group not found", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Search failed", err)
+		}
+		return
+	}
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+// handleGroupBackup streams a backup archive of a group's collections
+func (s *Server) handleGroupBackup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if _, err := s.db.GetGroup(r.Context(), name); err != nil {
+		s.writeGroupError(w, err, "Failed to get group")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+	if err := s.db.BackupGroup(r.Context(), name, w); err != nil {
+		// Headers are sent; the truncated archive fails to extract
+		log.Printf("Failed to back up group %s: %v", name, err)
+	}
+}
+
+// writeGroupError answers a failed group lookup or operation
+func (s *Server) writeGroupError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, core.ErrNotFound) {
+		s.writeError(w, http.StatusNotFound, "Group not found", err)
+		return
+	}
+	s.writeError(w, http.StatusInternalServerError, message, err)
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// handleCollectionGrowth returns the daily size history of a collection and
+// its growth trend, for forecasting when it outgrows memory or disk
+func (s *Server) handleCollectionGrowth(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	growth, err := s.db.CollectionGrowth(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection growth", err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, growth)
+}
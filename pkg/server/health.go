@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// Liveness probe endpoint: the process is serving and the database is open.
+// It checks no dependencies, so a failing disk or embedding service never
+// gets the process restarted.
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	health := s.db.Health()
+
+	status, code := "alive", http.StatusOK
+	if health.Status != "healthy" {
+		status, code = "dead", http.StatusServiceUnavailable
+	}
+
+	s.writeJSON(w, code, map[string]interface{}{
+		"status": status,
+		"uptime": health.Uptime,
+		"time":   time.Now().Unix(),
+	})
+}
+
+// Readiness probe endpoint: 503 when a component needed to serve requests
+// has failed. Degraded components (such as an unreachable embedding service)
+// are reported but keep the node ready, since the rest of the API works.
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	components := []*core.ComponentHealth{s.checkDatabase()}
+	components = append(components, s.db.CheckComponents(r.Context())...)
+	components = append(components, s.checkWAL())
+
+	status, code := "ready", http.StatusOK
+	for _, component := range components {
+		switch component.Status {
+		case core.ComponentFailed:
+			status, code = "not_ready", http.StatusServiceUnavailable
+		case core.ComponentDegraded:
+			if code == http.StatusOK {
+				status = "degraded"
+			}
+		}
+	}
+
+	s.writeJSON(w, code, map[string]interface{}{
+		"status":     status,
+		"components": components,
+		"time":       time.Now().Unix(),
+	})
+}
+
+// checkDatabase reports whether the database is open
+func (s *Server) checkDatabase() *core.ComponentHealth {
+	if health := s.db.Health(); health.Status != "healthy" {
+		return &core.ComponentHealth{Name: "database", Status: core.ComponentFailed, Message: "database is " + health.Status}
+	}
+	return &core.ComponentHealth{Name: "database", Status: core.ComponentOK}
+}
+
+// checkWAL reports on the Raft log, which is the write-ahead log in cluster
+// mode. A node without a known leader is degraded: it serves reads but
+// cannot accept writes.
+func (s *Server) checkWAL() *core.ComponentHealth {
+	component := &core.ComponentHealth{Name: "wal", Status: core.ComponentOK}
+	switch {
+	case s.cluster == nil:
+		component.Status = core.ComponentDisabled
+		component.Message = "standalone mode has no write-ahead log; collections are persisted on close"
+	case s.cluster.LogError() != nil:
+		component.Status = core.ComponentFailed
+		component.Message = "raft log write failed: " + s.cluster.LogError().Error()
+	default:
+		if _, leaderAddr := s.cluster.Leader(); leaderAddr == "" {
+			component.Status = core.ComponentDegraded
+			component.Message = "no cluster leader elected; writes are rejected"
+		}
+	}
+	return component
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// probe is the body of a liveness or readiness response
+type probe struct {
+	Status     string                  `json:"status"`
+	Components []*core.ComponentHealth `json:"components"`
+}
+
+// getProbe requests a probe endpoint and decodes its answer
+func getProbe(t *testing.T, s *Server, path string) (int, *probe, map[string]*core.ComponentHealth) {
+	t.Helper()
+	recorder := serve(s, http.MethodGet, path, "192.0.2.1:1234")
+	var body probe
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatalf("%s: invalid response: %v", path, err)
+	}
+	components := make(map[string]*core.ComponentHealth)
+	for _, component := range body.Components {
+		components[component.Name] = component
+	}
+	return recorder.Code, &body, components
+}
+
+// newProbedServer returns a server on a database in dataDir, which the test closes
+func newProbedServer(t *testing.T, dataDir string) (*Server, core.Database) {
+	t.Helper()
+	db := core.NewDatabase()
+	if err := db.Open(context.Background(), &core.Config{DataDir: dataDir}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Logging.Level = "error"
+	return NewServer(db, &ServerConfig{}, cfg), db
+}
+
+func TestLiveness(t *testing.T) {
+	dataDir := t.TempDir()
+	s, db := newProbedServer(t, dataDir)
+	if code, body, _ := getProbe(t, s, "/health/live"); code != http.StatusOK || body.Status != "alive" {
+		t.Errorf("open database: got %d %q, want 200 alive", code, body.Status)
+	}
+
+	// Liveness checks no dependency, so a missing data directory keeps the process alive
+	os.RemoveAll(dataDir)
+	if code, body, _ := getProbe(t, s, "/health/live"); code != http.StatusOK || body.Status != "alive" {
+		t.Errorf("missing data directory: got %d %q, want 200 alive", code, body.Status)
+	}
+
+	db.Close()
+	if code, body, _ := getProbe(t, s, "/health/live"); code != http.StatusServiceUnavailable || body.Status != "dead" {
+		t.Errorf("closed database: got %d %q, want 503 dead", code, body.Status)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	code, body, components := getProbe(t, s, "/health/ready")
+	if code != http.StatusOK || body.Status != "ready" {
+		t.Errorf("got %d %q, want 200 ready", code, body.Status)
+	}
+	for name, want := range map[string]string{
+		"database":   core.ComponentOK,
+		"storage":    core.ComponentOK,
+		"index":      core.ComponentOK,
+		"vectorizer": core.ComponentDisabled,
+		"wal":        core.ComponentDisabled,
+	} {
+		if component := components[name]; component == nil || component.Status != want {
+			t.Errorf("component %s: got %+v, want %s", name, component, want)
+		}
+	}
+
+	// An unreachable embedding service degrades the node without taking it out of rotation
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ollama.Close()
+	err := s.db.CreateCollection(ctx, &core.CreateCollectionRequest{
+		Name: "notes", Dimensions: 2, Metric: core.DistanceMetricCosine, IndexType: core.IndexTypeFlat,
+		VectorizerConfig: &embeddings.VectorizerConfig{Type: embeddings.VectorizerTypeOllama, Dimensions: 2, Options: map[string]interface{}{"base_url": ollama.URL}},
+	})
+	if err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	code, body, components = getProbe(t, s, "/health/ready")
+	if code != http.StatusOK || body.Status != "degraded" || components["vectorizer"].Status != core.ComponentDegraded {
+		t.Errorf("unreachable embedding service: got %d %q with vectorizer %+v, want 200 degraded", code, body.Status, components["vectorizer"])
+	}
+
+	// A collection whose index can't be searched fails the node
+	if err := s.db.CreateCollection(ctx, &core.CreateCollectionRequest{Name: "docs", Dimensions: 2, Metric: core.DistanceMetricCosine, IndexType: core.IndexTypeHNSW}); err != nil {
+		t.Fatalf("CreateCollection failed: %v", err)
+	}
+	collection, _ := s.db.GetCollection(ctx, "docs")
+	collection.(*core.VittoriaCollection).Close()
+	code, body, components = getProbe(t, s, "/health/ready")
+	if code != http.StatusServiceUnavailable || body.Status != "not_ready" || components["index"].Status != core.ComponentFailed {
+		t.Errorf("closed index: got %d %q with index %+v, want 503 not_ready", code, body.Status, components["index"])
+	}
+}
+
+func TestReadinessStorage(t *testing.T) {
+	dataDir := t.TempDir()
+	s, db := newProbedServer(t, dataDir)
+
+	// The data directory can't be written to once it is gone
+	os.RemoveAll(dataDir)
+	code, body, components := getProbe(t, s, "/health/ready")
+	if code != http.StatusServiceUnavailable || body.Status != "not_ready" || components["storage"].Status != core.ComponentFailed {
+		t.Errorf("missing data directory: got %d %q with storage %+v, want 503 not_ready", code, body.Status, components["storage"])
+	}
+	if components["database"].Status != core.ComponentOK {
+		t.Errorf("database %+v, want ok", components["database"])
+	}
+
+	db.Close()
+	code, body, components = getProbe(t, s, "/health/ready")
+	if code != http.StatusServiceUnavailable || components["database"].Status != core.ComponentFailed {
+		t.Errorf("closed database: got %d with database %+v, want 503 and failed", code, components["database"])
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// handleLoadTest runs a load test against a temporary collection of this
+// node and reports its latencies. The body may be left out to run with the
+// defaults.
+func (s *Server) handleLoadTest(w http.ResponseWriter, r *http.Request) {
+	var req core.LoadTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	if err := req.Normalize(); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid load test", err)
+		return
+	}
+
+	log.Printf("Load test: %g operations per second for %gs on %d vectors of %d dimensions", req.QPS, req.DurationSeconds, req.Vectors, req.Dimensions)
+	report, err := s.db.LoadTest(r.Context(), &req)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Load test failed", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, report)
+}
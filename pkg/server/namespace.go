@@ -0,0 +1,139 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// NamespaceHeader selects the namespace (tenant) a request is scoped to
+const NamespaceHeader = "X-Namespace"
+
+// requestNamespace returns the namespace a request is scoped to, taken from
+// the X-Namespace header or the namespace query parameter
+func requestNamespace(r *http.Request) (string, error) {
+	ns := r.Header.Get(NamespaceHeader)
+	if ns == "" {
+		ns = r.URL.Query().Get("namespace")
+	}
+	if err := core.ValidateNamespace(ns); err != nil {
+		return "", err
+	}
+	return ns, nil
+}
+
+// scopeNamespace applies the request namespace to a namespace given in the
+// request body. A body namespace is only accepted when it matches the request
+// scope, so a request scoped to one tenant cannot touch another.
+func scopeNamespace(scope string, ns *string) error {
+	if scope == "" {
+		return nil
+	}
+	if *ns == "" {
+		*ns = scope
+		return nil
+	}
+	if *ns != scope {
+		return fmt.Errorf("namespace '%s' does not match request namespace '%s'", *ns, scope)
+	}
+	return nil
+}
+
+// scopeVectors applies the request namespace to every vector
+func scopeVectors(r *http.Request, vectors []*core.Vector) error {
+	scope, err := requestNamespace(r)
+	if err != nil {
+		return err
+	}
+	for _, vector := range vectors {
+		if err := scopeNamespace(scope, &vector.Namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeTextVectors applies the request namespace to every text vector
+func scopeTextVectors(r *http.Request, textVectors []*core.TextVector) error {
+	scope, err := requestNamespace(r)
+	if err != nil {
+		return err
+	}
+	for _, textVector := range textVectors {
+		if err := scopeNamespace(scope, &textVector.Namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Namespaces endpoint: lists the namespaces of a collection with their vector counts
+func (s *Server) handleNamespaces(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	namespaces, err := vittoriaCollection.Namespaces()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to list namespaces", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"collection": name,
+		"namespaces": namespaces,
+	})
+}
+
+// Drop namespace endpoint: deletes every vector of one tenant
+func (s *Server) handleDropNamespace(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+	ns := vars["namespace"]
+
+	if err := core.ValidateNamespace(ns); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpDropNamespace, Collection: name, Namespace: ns}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to drop namespace", err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]string{
+		"status":     "dropped",
+		"collection": name,
+		"namespace":  ns,
+	})
+}
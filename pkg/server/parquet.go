@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// parquetImportBatch is how many records an import inserts per command
+const parquetImportBatch = 1000
+
+// handleExportParquet streams a collection as a Parquet dataset: a tar.gz
+// archive of Hive-partitioned Parquet files with typed metadata columns, for
+// querying with DuckDB or Spark. Requests scoped to a namespace export only
+// that namespace.
+func (s *Server) handleExportParquet(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	// The options are all optional, and so is the body
+	var req core.ParquetExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	scope, err := requestNamespace(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+	if scope != "" {
+		req.Namespace = &scope
+	}
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	// The files are encoded before anything is written, so invalid options
+	// are still answered with an error
+	out := &deferredWriter{w: w, start: func() {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".parquet.tar.gz"))
+	}}
+	manifest, err := vittoriaCollection.ExportParquet(r.Context(), out, &req)
+	if err != nil {
+		if out.started {
+			// Headers are sent; the truncated archive fails to extract
+			log.Printf("Failed to export collection %s as Parquet: %v", name, err)
+			return
+		}
+		s.writeError(w, http.StatusBadRequest, "Failed to export collection", err)
+		return
+	}
+	log.Printf("Exported %d records of collection %s as %d Parquet files", manifest.Records, name, len(manifest.Files))
+}
+
+// deferredWriter calls start before the first write, to set the headers of
+// a response only once there is something to send
+type deferredWriter struct {
+	w       io.Writer
+	start   func()
+	started bool
+}
+
+func (d *deferredWriter) Write(p []byte) (int, error) {
+	if !d.started {
+		d.started = true
+		d.start()
+	}
+	return d.w.Write(p)
+}
+
+// handleImportParquet loads records into a collection from a Parquet dataset
+// archive, as written by the Parquet export, or from a single Parquet file.
+// A missing collection is created from the dataset's manifest.
+func (s *Server) handleImportParquet(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+	name := mux.Vars(r)["name"]
+
+	scope, err := requestNamespace(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	// Archives are gzip-compressed; anything else is read as a Parquet file
+	body := bufio.NewReader(r.Body)
+	magic, _ := body.Peek(2)
+	var next func() ([]*core.Vector, error)
+	var manifest *core.ParquetManifest
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		reader, err := core.NewParquetDatasetReader(body)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid Parquet dataset", err)
+			return
+		}
+		defer reader.Close()
+		manifest = reader.Manifest()
+		next = reader.Next
+	} else {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "Failed to read request body", err)
+			return
+		}
+		read := false
+		next = func() ([]*core.Vector, error) {
+			if read {
+				return nil, io.EOF
+			}
+			read = true
+			return core.ReadParquetRecords(data, nil, nil)
+		}
+	}
+
+	created := false
+	if _, err := s.db.GetCollection(r.Context(), name); err != nil {
+		if !errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+			return
+		}
+		if manifest == nil {
+			s.writeError(w, http.StatusNotFound, "Collection not found",
+				fmt.Errorf("collection '%s' does not exist and the upload has no manifest to create it from", name))
+			return
+		}
+		if !s.authorize(w, r, name, auth.PermissionAdmin) {
+			return
+		}
+		create := &core.CreateCollectionRequest{Name: name, Dimensions: manifest.Dimensions, Metric: manifest.Metric, IndexType: manifest.IndexType}
+		if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpCreateCollection, Create: create}); err != nil {
+			if s.writeIfLeadershipLost(w, err) {
+				return
+			}
+			s.writeError(w, http.StatusBadRequest, "Failed to create collection", err)
+			return
+		}
+		created = true
+	}
+
+	inserted := 0
+	for {
+		records, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid Parquet data", fmt.Errorf("%w (%d records were inserted before)", err, inserted))
+			return
+		}
+		for _, record := range records {
+			if err := scopeNamespace(scope, &record.Namespace); err != nil {
+				s.writeError(w, http.StatusBadRequest, "Invalid namespace", fmt.Errorf("record %s: %w", record.ID, err))
+				return
+			}
+		}
+
+		for start := 0; start < len(records); start += parquetImportBatch {
+			batch := records[start:min(start+parquetImportBatch, len(records))]
+			if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpInsert, Collection: name, Vectors: batch}); err != nil {
+				if s.writeIfLeadershipLost(w, err) {
+					return
+				}
+				s.writeError(w, http.StatusBadRequest, "Failed to import records", fmt.Errorf("%w (%d records were inserted before)", err, inserted))
+				return
+			}
+			inserted += len(batch)
+		}
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "imported",
+		"collection": name,
+		"inserted":   inserted,
+		"created":    created,
+	})
+}
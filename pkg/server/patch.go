@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// Metadata patch endpoint: sets and unsets metadata keys of the records given
+// by ID or matching a filter, without touching their vectors
+func (s *Server) handlePatchMetadata(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	var req core.MetadataPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &req.Namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	// Count the records before the patch is applied, possibly on other nodes
+	matched, err := countPatchTargets(r, collection, &req)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid metadata patch", err)
+		return
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpPatchMetadata, Collection: name, Patch: &req}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrConditionFailed) {
+			s.writeError(w, http.StatusPreconditionFailed, "Condition not met", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to patch metadata", err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "patched",
+		"matched": matched,
+	})
+}
+
+// countPatchTargets returns how many records a metadata patch applies to
+func countPatchTargets(r *http.Request, collection core.Collection, req *core.MetadataPatchRequest) (int64, error) {
+	if len(req.IDs) > 0 {
+		var matched int64
+		for _, id := range req.IDs {
+			if _, err := collection.GetInNamespace(r.Context(), req.Namespace, id); err == nil {
+				matched++
+			}
+		}
+		return matched, nil
+	}
+	if req.Filter == nil {
+		return 0, nil
+	}
+
+	response, err := collection.Query(r.Context(), &core.QueryRequest{Filter: req.Filter, Namespace: req.Namespace, Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	return response.Total, nil
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+// PriorityHeader selects the priority class of a request: interactive or batch
+const PriorityHeader = "X-Priority"
+
+// priorityClass orders the requests waiting for a concurrency slot
+type priorityClass int
+
+const (
+	priorityInteractive priorityClass = iota // Searches and lookups a user waits for
+	priorityBatch                            // Bulk ingestion, exports and index maintenance
+)
+
+// String returns the name clients use for the class
+func (p priorityClass) String() string {
+	if p == priorityBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// batchRoutes are the routes whose requests are batch work unless they ask
+// for another class
+var batchRoutes = map[string]bool{
+	"/collections/{name}/vectors/batch":    true,
+	"/collections/{name}/vectors/delete":   true,
+	"/collections/{name}/vectors/metadata": true,
+	"/collections/{name}/text/batch":       true,
+	"/collections/{name}/documents":        true,
+	"/documents/process":                   true,
+	"/collections/{name}/import/parquet":   true,
+	"/collections/{name}/export":           true,
+	"/collections/{name}/export/parquet":   true,
+	"/collections/{name}/index/integrity":  true,
+	"/collections/{name}/index/repair":     true,
+	"/collections/{name}/index/rebuild":    true,
+	"/collections/{name}/rebalance":        true,
+	"/groups/{name}/backup":                true,
+	"/admin/loadtest":                      true,
+}
+
+// unlimitedRoutes keep their connection open for as long as the client
+// listens, so they would hold a slot indefinitely
+var unlimitedRoutes = map[string]bool{
+	"/collections/{name}/changes": true,
+}
+
+// requestPriority returns the priority class of a request, taken from the
+// X-Priority header or the priority query parameter, or else from its route
+func requestPriority(r *http.Request, template string) (priorityClass, error) {
+	value := r.Header.Get(PriorityHeader)
+	if value == "" {
+		value = r.URL.Query().Get("priority")
+	}
+	switch value {
+	case "":
+		if batchRoutes[template] {
+			return priorityBatch, nil
+		}
+		return priorityInteractive, nil
+	case "interactive":
+		return priorityInteractive, nil
+	case "batch":
+		return priorityBatch, nil
+	}
+	return 0, fmt.Errorf("invalid priority '%s': use interactive or batch", value)
+}
+
+// slotWaiter is a request waiting for a concurrency slot
+type slotWaiter struct {
+	ready   chan struct{} // Closed when the slot is handed over
+	granted bool
+}
+
+// concurrencyLimiter bounds the requests served at once. Freed slots go to
+// waiting interactive requests before batch ones, and batch requests never
+// take the last reserved slots, so that searches keep a steady latency while
+// bulk loads run.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	max      int
+	reserved int           // Slots only interactive requests may take
+	timeout  time.Duration // How long a request may wait for a slot
+	inFlight int
+	waiting  [2]*list.List // Waiters of each class, in arrival order
+}
+
+// newConcurrencyLimiter creates a limiter from config; fallback is the bound
+// used when the configuration sets no max_requests
+func newConcurrencyLimiter(cfg config.ConcurrencyConfig, fallback int) *concurrencyLimiter {
+	limit := fallback
+	if cfg.MaxRequests > 0 {
+		limit = cfg.MaxRequests
+	}
+	limit = max(limit, 1)
+	return &concurrencyLimiter{
+		max:      limit,
+		reserved: min(cfg.ReservedInteractive, limit-1),
+		timeout:  cfg.QueueTimeout,
+		waiting:  [2]*list.List{list.New(), list.New()},
+	}
+}
+
+// acquire waits for a slot for a request of the given class. It fails when
+// none frees up within the limiter's timeout or ctx ends first.
+func (l *concurrencyLimiter) acquire(ctx context.Context, class priorityClass) error {
+	l.mu.Lock()
+	if l.admits(class) {
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+	waiter := &slotWaiter{ready: make(chan struct{})}
+	element := l.waiting[class].PushBack(waiter)
+	l.mu.Unlock()
+
+	var expired <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-expired:
+		err = fmt.Errorf("no %s request slot freed up within %s", class, l.timeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if waiter.granted {
+		// The slot was handed over as the wait ended; pass it on
+		l.inFlight--
+		l.dispatch()
+	} else {
+		l.waiting[class].Remove(element)
+	}
+	return err
+}
+
+// release frees the slot of a request that has been served
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	l.dispatch()
+}
+
+// admits reports whether a request of the given class arriving now may take
+// a slot at once: it may not pass requests waiting ahead of it; the caller
+// holds mu
+func (l *concurrencyLimiter) admits(class priorityClass) bool {
+	if l.waiting[priorityInteractive].Len() > 0 {
+		return false
+	}
+	if class == priorityInteractive {
+		return l.inFlight < l.max
+	}
+	return l.waiting[priorityBatch].Len() == 0 && l.inFlight < l.max-l.reserved
+}
+
+// dispatch hands free slots to waiting requests, interactive ones first; the
+// caller holds mu
+func (l *concurrencyLimiter) dispatch() {
+	for _, class := range []priorityClass{priorityInteractive, priorityBatch} {
+		limit := l.max
+		if class == priorityBatch {
+			limit -= l.reserved
+		}
+		for l.inFlight < limit && l.waiting[class].Len() > 0 {
+			waiter := l.waiting[class].Remove(l.waiting[class].Front()).(*slotWaiter)
+			waiter.granted = true
+			close(waiter.ready)
+			l.inFlight++
+		}
+	}
+}
+
+// SetConcurrency bounds the requests served at once; fallback is the bound
+// used when the configuration sets none
+func (s *Server) SetConcurrency(cfg config.ConcurrencyConfig, fallback int) {
+	s.limiter = newConcurrencyLimiter(cfg, fallback)
+}
+
+// concurrencyMiddleware makes each request wait for a concurrency slot,
+// answering 503 when none frees up in time
+func (s *Server) concurrencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil || r.Method == http.MethodOptions || routeAccess(r).unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template := ""
+		if route := mux.CurrentRoute(r); route != nil {
+			template, _ = route.GetPathTemplate()
+		}
+		if unlimitedRoutes[template] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		class, err := requestPriority(r, template)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid priority", err)
+			return
+		}
+		if err := s.limiter.acquire(r.Context(), class); err != nil {
+			if r.Context().Err() != nil {
+				return // The client is gone
+			}
+			// Not logged through writeError, so that an overload does not flood the log
+			w.Header().Set("Retry-After", "1")
+			s.writeJSON(w, http.StatusServiceUnavailable, newErrorResponse(http.StatusServiceUnavailable, "Server busy", err))
+			return
+		}
+		defer s.limiter.release()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+)
+
+// waitForWaiters waits until n requests of class wait for a slot of l
+func waitForWaiters(t *testing.T, l *concurrencyLimiter, class priorityClass, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		l.mu.Lock()
+		waiting := l.waiting[class].Len()
+		l.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d %s requests waiting, want %d", waiting, class, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRequestPriority(t *testing.T) {
+	for _, test := range []struct {
+		target, header, template string
+		want                     priorityClass
+	}{
+		{"/collections/docs/search", "", "/collections/{name}/search", priorityInteractive},
+		{"/collections/docs/vectors/batch", "", "/collections/{name}/vectors/batch", priorityBatch},
+		{"/collections/docs/vectors/batch", "interactive", "/collections/{name}/vectors/batch", priorityInteractive},
+		{"/collections/docs/search?priority=batch", "", "/collections/{name}/search", priorityBatch},
+		{"/collections/docs/search?priority=batch", "interactive", "/collections/{name}/search", priorityInteractive},
+	} {
+		request := httptest.NewRequest(http.MethodPost, test.target, nil)
+		if test.header != "" {
+			request.Header.Set(PriorityHeader, test.header)
+		}
+		if got, err := requestPriority(request, test.template); err != nil || got != test.want {
+			t.Errorf("%s with %q: got %s, %v; want %s", test.target, test.header, got, err, test.want)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/collections/docs/search?priority=urgent", nil)
+	if _, err := requestPriority(request, "/collections/{name}/search"); err == nil {
+		t.Error("expected an invalid priority to be refused")
+	}
+}
+
+func TestConcurrencyLimiterInteractiveFirst(t *testing.T) {
+	l := newConcurrencyLimiter(config.ConcurrencyConfig{MaxRequests: 1}, 0)
+	ctx := context.Background()
+	if err := l.acquire(ctx, priorityInteractive); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	// A batch request queues first, then an interactive one
+	served := make(chan priorityClass, 2)
+	for _, class := range []priorityClass{priorityBatch, priorityInteractive} {
+		go func() {
+			if err := l.acquire(ctx, class); err != nil {
+				t.Errorf("%s acquire failed: %v", class, err)
+			}
+			served <- class
+		}()
+		waitForWaiters(t, l, class, 1)
+	}
+
+	// Each freed slot goes to the interactive request before the batch one
+	var order []priorityClass
+	for range 2 {
+		l.release()
+		order = append(order, <-served)
+	}
+	if want := []priorityClass{priorityInteractive, priorityBatch}; !reflect.DeepEqual(order, want) {
+		t.Errorf("requests served in the order %v, want %v", order, want)
+	}
+}
+
+func TestConcurrencyLimiterReservedSlots(t *testing.T) {
+	l := newConcurrencyLimiter(config.ConcurrencyConfig{MaxRequests: 2, ReservedInteractive: 1, QueueTimeout: 20 * time.Millisecond}, 0)
+	ctx := context.Background()
+
+	// Batch requests never take the reserved slot
+	if err := l.acquire(ctx, priorityBatch); err != nil {
+		t.Fatalf("batch acquire failed: %v", err)
+	}
+	if err := l.acquire(ctx, priorityBatch); err == nil {
+		t.Fatal("a batch request took the reserved slot")
+	}
+	if err := l.acquire(ctx, priorityInteractive); err != nil {
+		t.Fatalf("interactive acquire failed: %v", err)
+	}
+
+	// Requests that give up leave the slots they did not get
+	if err := l.acquire(ctx, priorityInteractive); err == nil {
+		t.Fatal("a request took a slot beyond the limit")
+	}
+	l.release()
+	if err := l.acquire(ctx, priorityInteractive); err != nil {
+		t.Errorf("acquire after a release failed: %v", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight != 2 || l.waiting[priorityInteractive].Len() != 0 || l.waiting[priorityBatch].Len() != 0 {
+		t.Errorf("%d requests in flight and %d waiting, want 2 and 0", l.inFlight, l.waiting[priorityInteractive].Len()+l.waiting[priorityBatch].Len())
+	}
+}
+
+func TestConcurrencyMiddleware(t *testing.T) {
+	s := newTestServer(t)
+	s.SetConcurrency(config.ConcurrencyConfig{MaxRequests: 1, QueueTimeout: 20 * time.Millisecond}, 0)
+	if err := s.limiter.acquire(context.Background(), priorityInteractive); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	// With every slot taken, requests are answered 503, except health checks
+	recorder := serve(s, http.MethodGet, "/stats", "192.0.2.1:1234")
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("got status %d with Retry-After %q, want 503", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+	if code := serve(s, http.MethodGet, "/health", "192.0.2.1:1234").Code; code != http.StatusOK {
+		t.Errorf("health check: got status %d", code)
+	}
+	if code := serve(s, http.MethodGet, "/stats?priority=urgent", "192.0.2.1:1234").Code; code != http.StatusBadRequest {
+		t.Errorf("invalid priority: got status %d", code)
+	}
+
+	s.limiter.release()
+	if code := serve(s, http.MethodGet, "/stats", "192.0.2.1:1234").Code; code != http.StatusOK {
+		t.Errorf("after a release: got status %d", code)
+	}
+}
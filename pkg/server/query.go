@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// Query endpoint: retrieves records by filter and text instead of similarity,
+// metadata-only records included
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	req := core.QueryRequest{Limit: 10}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &req.Namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	response, err := collection.Query(r.Context(), &req)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Query failed", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
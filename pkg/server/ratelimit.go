@@ -0,0 +1,174 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+)
+
+// rateLimitSweepInterval is how often idle client buckets are dropped
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket tracks the tokens left to one client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter applies a token bucket limit to each client independently
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64       // Tokens added per second
+	burst     float64       // Bucket capacity
+	timeout   time.Duration // How long a request may wait for a token before it is rejected
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// newRateLimiter creates a limiter from config, or returns nil when the limit
+// is disabled
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	if cfg.RequestsPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:      float64(cfg.RequestsPerSecond),
+		burst:     float64(cfg.BurstSize),
+		timeout:   cfg.Timeout,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// reserve takes a token for client and returns how long the caller must wait
+// before using it. When the wait would exceed the limiter's timeout no token
+// is taken, and the returned duration is when the client should retry.
+func (l *rateLimiter) reserve(client string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, exists := l.buckets[client]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, true
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	if wait > l.timeout {
+		return wait, false
+	}
+	// Borrow the token; the bucket stays negative until it refills
+	bucket.tokens--
+	return wait, true
+}
+
+// sweep drops the buckets of clients idle long enough to have refilled
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= refill {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// SetRateLimit limits the request rate of each API key and each client IP,
+// or lifts the limits when cfg is disabled. Clients start with full buckets.
+func (s *Server) SetRateLimit(cfg config.ServerRateLimitConfig) {
+	if !cfg.Enabled {
+		cfg = config.ServerRateLimitConfig{}
+	}
+	s.keyLimiter.Store(newRateLimiter(cfg.PerKey))
+	s.ipLimiter.Store(newRateLimiter(cfg.PerIP))
+	s.trustProxy.Store(cfg.TrustProxy)
+}
+
+// clientIP returns the address a request came from
+func (s *Server) clientIP(r *http.Request) string {
+	if s.trustProxy.Load() {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// throttle waits for the client's next token, or writes a 429 response and
+// returns false if the client is over its limit
+func (s *Server) throttle(w http.ResponseWriter, r *http.Request, limiter *rateLimiter, client string) bool {
+	wait, ok := limiter.reserve(client, time.Now())
+	if !ok {
+		// Not logged through writeError, so a flooding client cannot flood the log
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		s.writeJSON(w, http.StatusTooManyRequests, newErrorResponse(http.StatusTooManyRequests, "Rate limit exceeded",
+			fmt.Errorf("%s exceeded %g requests per second", client, limiter.rate)))
+		return false
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// ipRateLimitMiddleware limits each client IP. It runs before authentication
+// so that requests with invalid keys are limited too.
+func (s *Server) ipRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limiter := s.ipLimiter.Load()
+		if limiter == nil || r.Method == http.MethodOptions || routeAccess(r).unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.throttle(w, r, limiter, "ip "+s.clientIP(r)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// keyRateLimitMiddleware limits each authenticated API key
+func (s *Server) keyRateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		limiter := s.keyLimiter.Load()
+		if limiter == nil || key == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.throttle(w, r, limiter, "key '"+key.Name+"'") {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+)
+
+// reserveAt reserves a token for client at now, failing the test if the
+// outcome is not the expected one
+func reserveAt(t *testing.T, l *rateLimiter, client string, now time.Time, wantWait time.Duration, wantOK bool) {
+	t.Helper()
+	wait, ok := l.reserve(client, now)
+	if ok != wantOK || wait.Round(time.Millisecond) != wantWait {
+		t.Errorf("reserve(%s) = %v, %v; want %v, %v", client, wait, ok, wantWait, wantOK)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := newRateLimiter(config.RateLimitConfig{RequestsPerSecond: 10, BurstSize: 2})
+	now := time.Unix(1000, 0)
+
+	// A new client starts with a full bucket
+	reserveAt(t, l, "a", now, 0, true)
+	reserveAt(t, l, "a", now, 0, true)
+	reserveAt(t, l, "a", now, 100*time.Millisecond, false)
+
+	// Clients have buckets of their own
+	reserveAt(t, l, "b", now, 0, true)
+
+	// A token comes back every 1/rate seconds
+	reserveAt(t, l, "a", now.Add(50*time.Millisecond), 50*time.Millisecond, false)
+	reserveAt(t, l, "a", now.Add(100*time.Millisecond), 0, true)
+	reserveAt(t, l, "a", now.Add(100*time.Millisecond), 100*time.Millisecond, false)
+
+	// and the bucket never holds more than the burst
+	later := now.Add(time.Hour)
+	reserveAt(t, l, "a", later, 0, true)
+	reserveAt(t, l, "a", later, 0, true)
+	reserveAt(t, l, "a", later, 100*time.Millisecond, false)
+
+	if newRateLimiter(config.RateLimitConfig{}) != nil {
+		t.Error("a limit of 0 requests per second should disable the limiter")
+	}
+}
+
+func TestRateLimiterBorrow(t *testing.T) {
+	l := newRateLimiter(config.RateLimitConfig{RequestsPerSecond: 10, BurstSize: 1, Timeout: 250 * time.Millisecond})
+	now := time.Unix(1000, 0)
+
+	// Within the timeout, requests borrow the next tokens and wait for them
+	reserveAt(t, l, "a", now, 0, true)
+	reserveAt(t, l, "a", now, 100*time.Millisecond, true)
+	reserveAt(t, l, "a", now, 200*time.Millisecond, true)
+
+	// Beyond it they are rejected without taking a token
+	reserveAt(t, l, "a", now, 300*time.Millisecond, false)
+	reserveAt(t, l, "a", now, 300*time.Millisecond, false)
+
+	// The borrowed tokens are paid back before the bucket has any
+	reserveAt(t, l, "a", now.Add(200*time.Millisecond), 100*time.Millisecond, true)
+}
+
+func TestThrottle(t *testing.T) {
+	s := &Server{}
+	request := httptest.NewRequest(http.MethodGet, "/health", nil)
+
+	// Requests within the timeout are delayed until their token is due
+	l := newRateLimiter(config.RateLimitConfig{RequestsPerSecond: 20, BurstSize: 1, Timeout: time.Second})
+	s.throttle(httptest.NewRecorder(), request, l, "a")
+	start := time.Now()
+	if !s.throttle(httptest.NewRecorder(), request, l, "a") {
+		t.Fatal("a request within the timeout was rejected")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("the request was served after %v, before its token was due", elapsed)
+	}
+
+	// and give up when the client goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s.throttle(httptest.NewRecorder(), request.WithContext(ctx), l, "a") {
+		t.Error("a request was served after its client went away")
+	}
+
+	// Requests beyond it are answered with 429 and when to retry
+	l = newRateLimiter(config.RateLimitConfig{RequestsPerSecond: 1, BurstSize: 1})
+	s.throttle(httptest.NewRecorder(), request, l, "a")
+	recorder := httptest.NewRecorder()
+	if s.throttle(recorder, request, l, "a") {
+		t.Fatal("a request over the limit was served")
+	}
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "1" {
+		t.Errorf("got status %d with Retry-After %q, want 429 with 1", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := newTestServer(t)
+	s.SetRateLimit(config.ServerRateLimitConfig{Enabled: true, PerIP: config.RateLimitConfig{RequestsPerSecond: 1, BurstSize: 1}})
+
+	if code := serve(s, http.MethodGet, "/stats", "192.0.2.1:1234").Code; code != http.StatusOK {
+		t.Fatalf("first request: got status %d", code)
+	}
+	recorder := serve(s, http.MethodGet, "/stats", "192.0.2.1:1234")
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("second request: got status %d with Retry-After %q", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+
+	// Other clients and health checks are not held back
+	if code := serve(s, http.MethodGet, "/stats", "192.0.2.2:1234").Code; code != http.StatusOK {
+		t.Errorf("another client: got status %d", code)
+	}
+	if code := serve(s, http.MethodGet, "/health", "192.0.2.1:1234").Code; code != http.StatusOK {
+		t.Errorf("health check: got status %d", code)
+	}
+
+	// Disabling the limit lifts it at once
+	s.SetRateLimit(config.ServerRateLimitConfig{})
+	if code := serve(s, http.MethodGet, "/stats", "192.0.2.1:1234").Code; code != http.StatusOK {
+		t.Errorf("after disabling the limit: got status %d", code)
+	}
+}
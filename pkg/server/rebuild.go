@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// handleIndexRebuild starts a background index rebuild (POST) or reports the
+// progress of the latest one (GET)
+func (s *Server) handleIndexRebuild(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		s.handleStartIndexRebuild(w, r)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	status, err := vittoriaCollection.IndexRebuildStatus()
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "No index rebuild", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get index rebuild", err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, status)
+}
+
+// handleStartIndexRebuild starts rebuilding a collection's index, optionally
+// as another type, and answers with the progress so far
+func (s *Server) handleStartIndexRebuild(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	var req core.IndexRebuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	if collection, err := s.db.GetCollection(r.Context(), name); err == nil {
+		if vittoriaCollection, ok := collection.(*core.VittoriaCollection); ok && s.refuseOutsideWindow(w, r, vittoriaCollection) {
+			return
+		}
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpRebuildIndex, Collection: name, Rebuild: &req}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		case errors.Is(err, core.ErrConflict):
+			s.writeError(w, http.StatusConflict, "Index cannot be rebuilt now", err)
+		default:
+			s.writeError(w, http.StatusBadRequest, "Failed to start index rebuild", err)
+		}
+		return
+	}
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		return
+	}
+	status, err := collection.(*core.VittoriaCollection).IndexRebuildStatus()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to get index rebuild", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusAccepted, status)
+}
+
+// refuseOutsideWindow answers 409 when the collection is outside its
+// maintenance windows, unless the request passes force=true
+func (s *Server) refuseOutsideWindow(w http.ResponseWriter, r *http.Request, collection *core.VittoriaCollection) bool {
+	if r.URL.Query().Get("force") == "true" {
+		return false
+	}
+	if err := collection.CheckMaintenanceWindow(); err != nil {
+		s.writeError(w, http.StatusConflict, "Outside maintenance window", err)
+		return true
+	}
+	return false
+}
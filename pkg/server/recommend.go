@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// Recommend endpoint: finds the vectors most like the positive examples and
+// least like the negative ones, given by ID or as raw vectors
+func (s *Server) handleRecommend(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	req := core.RecommendRequest{IncludeMetadata: true}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &req.Namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	// Same defaults as searches
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+	if req.Limit > 1000 {
+		req.Limit = 1000
+	}
+
+	results, err := vittoriaCollection.Recommend(r.Context(), &req)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Example not found", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Recommendation failed", err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+)
+
+// maxConfigUpdateSize bounds the body of PUT /config
+const maxConfigUpdateSize = 1 << 20
+
+// currentConfig returns the configuration in effect, nil when the server
+// was created without one
+func (s *Server) currentConfig() *config.VittoriaConfig {
+	return s.unifiedConfig.Load()
+}
+
+// logs reports whether messages of a level are logged under logging.level
+func (s *Server) logs(level string) bool {
+	cfg := s.currentConfig()
+	return cfg == nil || cfg.Logging.Logs(level)
+}
+
+// ReloadConfig moves the server to the reloadable settings of cfg: the log
+// level, the search cache's size and TTL, the default ef_search, rate limits
+// and runtime tuning. Other changed settings are reported as needing a
+// restart and keep their running value.
+func (s *Server) ReloadConfig(ctx context.Context, cfg *config.VittoriaConfig) (*config.ReloadResult, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	current := s.currentConfig()
+	if current == nil {
+		return nil, fmt.Errorf("configuration not available")
+	}
+	effective, result, err := config.Reload(current, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+
+	if err := s.db.Reconfigure(ctx, effective); err != nil {
+		return nil, fmt.Errorf("failed to reconfigure the database: %w", err)
+	}
+	if effective.Server.RateLimit != current.Server.RateLimit {
+		s.SetRateLimit(effective.Server.RateLimit)
+	}
+	if effective.Performance.GCTarget != current.Performance.GCTarget ||
+		effective.Performance.MemoryLimit != current.Performance.MemoryLimit {
+		config.ApplyRuntimeSettings(&effective.Performance)
+	}
+	s.unifiedConfig.Store(effective)
+	return result, nil
+}
+
+// Change reloadable settings: the body holds the settings to change, in the
+// layout of the configuration file, as YAML or JSON. Changes to settings
+// that need a restart are refused, and changes are not written to the file.
+func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	current := s.currentConfig()
+	if current == nil {
+		s.writeError(w, http.StatusInternalServerError, "Configuration not available", nil)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxConfigUpdateSize+1))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Failed to read request body", err)
+		return
+	}
+	if len(body) > maxConfigUpdateSize {
+		s.writeError(w, http.StatusRequestEntityTooLarge, "Configuration too large", fmt.Errorf("the body exceeds %d bytes", maxConfigUpdateSize))
+		return
+	}
+
+	next := current.Clone()
+	if err := next.FromYAML(body); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid configuration", err)
+		return
+	}
+	if err := next.Validate(); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid configuration", err)
+		return
+	}
+	changed, err := config.ChangedSettings(current, next)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to compare configurations", err)
+		return
+	}
+	var restart []string
+	for _, path := range changed {
+		if !config.IsReloadable(path) {
+			restart = append(restart, path)
+		}
+	}
+	if len(restart) > 0 {
+		s.writeError(w, http.StatusBadRequest, "Settings require a restart", fmt.Errorf("%s cannot change without a restart; the reloadable settings are %s",
+			strings.Join(restart, ", "), strings.Join(config.ReloadableSettings(), ", ")))
+		return
+	}
+
+	result, err := s.ReloadConfig(r.Context(), next)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Failed to apply configuration", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// maxRerankTopK bounds the candidates a single request may send to the reranker
+const maxRerankTopK = 1000
+
+// rerankOptions are the reranking settings of a search request
+type rerankOptions struct {
+	Rerank     bool   `json:"rerank"`
+	RerankTopK int    `json:"rerank_top_k"` // Candidates retrieved and reranked
+	Query      string `json:"query"`        // Text the results are reranked against; text searches use their query
+}
+
+// reranking is the second search stage requests can opt into
+type reranking struct {
+	reranker  embeddings.Reranker
+	topK      int
+	textField string
+}
+
+// SetRerank enables the reranking stage with the service described by cfg
+func (s *Server) SetRerank(cfg config.RerankConfig) error {
+	reranker, err := embeddings.NewHTTPReranker(&embeddings.RerankerConfig{
+		Format:  cfg.Format,
+		URL:     cfg.URL,
+		Model:   cfg.Model,
+		APIKey:  cfg.APIKey,
+		Timeout: cfg.Timeout,
+	})
+	if err != nil {
+		return err
+	}
+	s.SetReranker(reranker, cfg.TopK, cfg.TextField)
+	return nil
+}
+
+// SetReranker enables the reranking stage with reranker, retrieving topK
+// candidates by default and reading the text of results without stored
+// content from the textField metadata field
+func (s *Server) SetReranker(reranker embeddings.Reranker, topK int, textField string) {
+	s.rerank = &reranking{reranker: reranker, topK: topK, textField: textField}
+}
+
+// parseRerankParams reads the reranking settings of a GET search
+func parseRerankParams(query url.Values) (rerankOptions, error) {
+	opts := rerankOptions{
+		Rerank: query.Get("rerank") == "true",
+		Query:  query.Get("query"),
+	}
+	if topK := query.Get("rerank_top_k"); topK != "" {
+		n, err := strconv.Atoi(topK)
+		if err != nil {
+			return opts, fmt.Errorf("invalid rerank_top_k: %w", err)
+		}
+		opts.RerankTopK = n
+	}
+	return opts, nil
+}
+
+// checkRerank validates the reranking settings of a search for req
+func (s *Server) checkRerank(opts *rerankOptions, req *core.SearchRequest) error {
+	if s.rerank == nil {
+		return fmt.Errorf("reranking is not configured on this server")
+	}
+	if opts.Query == "" {
+		return fmt.Errorf("a query text is required to rerank a vector search")
+	}
+	if opts.RerankTopK < 0 || opts.RerankTopK > maxRerankTopK {
+		return fmt.Errorf("rerank_top_k must be between 0 and %d", maxRerankTopK)
+	}
+	if opts.RerankTopK > 0 && opts.RerankTopK < req.Offset+req.Limit {
+		return fmt.Errorf("rerank_top_k must be at least offset + limit (%d)", req.Offset+req.Limit)
+	}
+	return nil
+}
+
+// rerankSearch retrieves the top candidates for req, reorders them by the
+// reranker's relevance scores and returns the page req asks for. Candidates
+// without text get a score of 0 and are ranked after the ones the reranker
+// scored.
+func (s *Server) rerankSearch(ctx context.Context, collection core.Collection, req *core.SearchRequest, opts *rerankOptions) (*core.SearchResponse, error) {
+	start := time.Now()
+
+	topK := opts.RerankTopK
+	if topK == 0 {
+		topK = max(s.rerank.topK, req.Offset+req.Limit)
+	}
+
+	// The reranker reads the content and metadata of every candidate
+	candidateReq := *req
+	candidateReq.Offset = 0
+	candidateReq.Limit = topK
+	candidateReq.IncludeContent = true
+	candidateReq.IncludeMetadata = true
+
+	candidates, err := collection.Search(ctx, &candidateReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var scored, unscored []*core.SearchResult
+	var documents []string
+	for _, candidate := range candidates.Results {
+		// Copy, since the response may be shared with the search cache
+		result := *candidate
+		result.VectorScore = result.Score
+		if !req.IncludeContent {
+			result.Content = ""
+		}
+		if !req.IncludeMetadata {
+			result.Metadata = nil
+		}
+
+		text := candidate.Content
+		if text == "" {
+			text, _ = candidate.Metadata[s.rerank.textField].(string)
+		}
+		if text == "" {
+			result.Score = 0
+			unscored = append(unscored, &result)
+			continue
+		}
+		scored = append(scored, &result)
+		documents = append(documents, text)
+	}
+
+	scores, err := s.rerank.reranker.Rerank(ctx, opts.Query, documents)
+	if err != nil {
+		return nil, fmt.Errorf("reranking failed: %w", err)
+	}
+	for i, result := range scored {
+		result.Score = scores[i]
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+
+	results := append(scored, unscored...)
+	from := min(req.Offset, len(results))
+	to := min(from+req.Limit, len(results))
+
+	return &core.SearchResponse{
+		Results:   results[from:to],
+		Total:     candidates.Total,
+		TookMS:    time.Since(start).Milliseconds(),
+		RequestID: candidates.RequestID,
+	}, nil
+}
+
+// rerankAndRespond answers a search that opted into reranking
+func (s *Server) rerankAndRespond(w http.ResponseWriter, r *http.Request, collection core.Collection, req *core.SearchRequest, opts *rerankOptions) {
+	if err := s.checkRerank(opts, req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid rerank request", err)
+		return
+	}
+	if wantsEventStream(r) {
+		s.writeError(w, http.StatusBadRequest, "Reranked searches cannot be streamed", nil)
+		return
+	}
+
+	results, err := s.rerankSearch(r.Context(), collection, req, opts)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Search failed", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, results)
+}
@@ -3,15 +3,21 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
 	"github.com/antonellof/VittoriaDB/pkg/config"
 	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
 	"github.com/antonellof/VittoriaDB/pkg/processor"
 	"github.com/gorilla/mux"
 )
@@ -22,8 +28,20 @@ type Server struct {
 	router        *mux.Router
 	server        *http.Server
 	config        *ServerConfig
-	unifiedConfig *config.VittoriaConfig
+	unifiedConfig atomic.Pointer[config.VittoriaConfig] // Replaced by ReloadConfig
+	reloadMu      sync.Mutex                            // Serializes ReloadConfig
 	processor     *processor.ProcessorFactory
+	cluster       *cluster.Node               // nil when running standalone
+	auth          *auth.Store                 // nil when authentication is disabled
+	keyLimiter    atomic.Pointer[rateLimiter] // nil when API keys are not rate limited
+	ipLimiter     atomic.Pointer[rateLimiter] // nil when client IPs are not rate limited
+	limiter       *concurrencyLimiter         // nil when concurrent requests are not limited
+	trustProxy    atomic.Bool                 // Take client IPs from X-Forwarded-For
+	shadows       *shadowMirror               // Searches mirrored to shadow collections
+	rerank        *reranking                  // nil when reranking is not configured
+	usage         *auth.UsageLedger           // nil when usage is not tracked
+	edge          *edgeCache                  // nil unless the server caches an upstream
+	routeTimeouts map[string]routeTimeout     // Timeouts overriding the server's, by route template
 }
 
 // ServerConfig represents server configuration
@@ -34,6 +52,9 @@ type ServerConfig struct {
 	WriteTimeout time.Duration
 	MaxBodySize  int64
 	CORS         bool
+	IdleTimeout  time.Duration // Zero uses ReadTimeout
+	KeepAlive    bool
+	HTTP2        config.HTTP2Config
 }
 
 // NewServer creates a new HTTP server
@@ -42,19 +63,34 @@ func NewServer(db core.Database, config *ServerConfig, unifiedConfig *config.Vit
 		db:            db,
 		router:        mux.NewRouter(),
 		config:        config,
-		unifiedConfig: unifiedConfig,
 		processor:     processor.NewProcessorFactory(),
+		shadows:       newShadowMirror(),
+		routeTimeouts: defaultRouteTimeouts,
 	}
+	s.unifiedConfig.Store(unifiedConfig)
 
 	s.setupRoutes()
 	s.setupMiddleware()
 
+	// HTTP/2 next to HTTP/1.1, in cleartext too for the gRPC of Arrow Flight
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(config.HTTP2.Enabled)
+	protocols.SetUnencryptedHTTP2(config.HTTP2.Enabled)
+
 	s.server = &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", config.Host, config.Port),
 		Handler:      s.router,
 		ReadTimeout:  config.ReadTimeout,
 		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+		Protocols:    protocols,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: config.HTTP2.MaxConcurrentStreams,
+			SendPingTimeout:      config.HTTP2.PingInterval,
+		},
 	}
+	s.server.SetKeepAlivesEnabled(config.KeepAlive)
 
 	return s
 }
@@ -68,6 +104,9 @@ func (s *Server) Start() error {
 // Stop stops the HTTP server
 func (s *Server) Stop(ctx context.Context) error {
 	log.Println("Stopping VittoriaDB server...")
+	if s.edge != nil {
+		s.edge.close()
+	}
 	return s.server.Shutdown(ctx)
 }
 
@@ -75,19 +114,62 @@ func (s *Server) Stop(ctx context.Context) error {
 func (s *Server) setupRoutes() {
 	// Health and stats
 	s.router.HandleFunc("/health", s.handleHealth).Methods("GET")
+	s.router.HandleFunc("/health/live", s.handleLiveness).Methods("GET")
+	s.router.HandleFunc("/health/ready", s.handleReadiness).Methods("GET")
 	s.router.HandleFunc("/stats", s.handleStats).Methods("GET")
-	s.router.HandleFunc("/config", s.handleConfig).Methods("GET")
+	s.router.HandleFunc("/config", s.handleConfig).Methods("GET", "PUT")
+	s.router.HandleFunc("/capabilities", s.handleCapabilities).Methods("GET")
+
+	// Cluster
+	s.router.HandleFunc("/cluster/status", s.handleClusterStatus).Methods("GET")
+	s.router.HandleFunc(cluster.VotePath, s.handleRaftVote).Methods("POST")
+	s.router.HandleFunc(cluster.AppendPath, s.handleRaftAppend).Methods("POST")
+
+	// API key management
+	s.router.HandleFunc("/auth/keys", s.handleAuthKeys).Methods("GET", "POST")
+	s.router.HandleFunc("/auth/keys/{name}", s.handleAuthKey).Methods("DELETE")
+	s.router.HandleFunc("/admin/usage", s.handleUsage).Methods("GET")
+	s.router.HandleFunc("/admin/loadtest", s.handleLoadTest).Methods("POST")
 
 	// Collection management
 	s.router.HandleFunc("/collections", s.handleCollections).Methods("GET", "POST")
-	s.router.HandleFunc("/collections/{name}", s.handleCollection).Methods("GET", "DELETE")
+	s.router.HandleFunc("/collections/{name}", s.handleCollection).Methods("GET", "PUT", "DELETE")
+	s.router.HandleFunc("/collections/{name}/restore", s.handleRestoreCollection).Methods("POST")
+	s.router.HandleFunc("/trash", s.handleTrash).Methods("GET")
+	s.router.HandleFunc("/groups", s.handleGroups).Methods("GET", "POST")
+	s.router.HandleFunc("/groups/{name}", s.handleGroup).Methods("GET", "DELETE")
+	s.router.HandleFunc("/groups/{name}/records", s.handleGroupRecords).Methods("POST")
+	s.router.HandleFunc("/groups/{name}/records/{id}", s.handleGroupRecord).Methods("DELETE")
+	s.router.HandleFunc("/groups/{name}/search", s.handleGroupSearch).Methods("POST")
+	s.router.HandleFunc("/groups/{name}/backup", s.handleGroupBackup).Methods("GET")
 	s.router.HandleFunc("/collections/{name}/stats", s.handleCollectionStats).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/growth", s.handleCollectionGrowth).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/index/stats", s.handleIndexStats).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/index/integrity", s.handleIndexIntegrity).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/index/repair", s.handleIndexRepair).Methods("POST")
+	s.router.HandleFunc("/collections/{name}/index/rebuild", s.handleIndexRebuild).Methods("GET", "POST")
+	s.router.HandleFunc("/collections/{name}/shards", s.handleShards).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/rebalance", s.handleRebalance).Methods("POST")
+	s.router.HandleFunc("/collections/{name}/namespaces", s.handleNamespaces).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/namespaces/{namespace}", s.handleDropNamespace).Methods("DELETE")
+	s.router.HandleFunc("/collections/{name}/changes", s.handleChanges).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/shadow", s.handleShadow).Methods("GET", "PUT", "DELETE")
 
 	// Vector operations
 	s.router.HandleFunc("/collections/{name}/vectors", s.handleVectors).Methods("POST")
 	s.router.HandleFunc("/collections/{name}/vectors/batch", s.handleVectorsBatch).Methods("POST")
+	s.router.HandleFunc("/collections/{name}/vectors/metadata", s.handlePatchMetadata).Methods("PATCH")
+	s.router.HandleFunc("/collections/{name}/vectors/delete", s.handleDeleteVectors).Methods("POST")
 	s.router.HandleFunc("/collections/{name}/vectors/{id}", s.handleVector).Methods("GET", "DELETE")
 	s.router.HandleFunc("/collections/{name}/search", s.handleSearch).Methods("GET", "POST")
+	s.router.HandleFunc("/collections/{name}/recommend", s.handleRecommend).Methods("POST")
+	s.router.HandleFunc("/collections/{name}/query", s.handleQuery).Methods("POST")
+	s.router.HandleFunc("/collections/{name}/export", s.handleExport).Methods("GET")
+	s.router.HandleFunc("/collections/{name}/export/parquet", s.handleExportParquet).Methods("POST")
+	s.router.HandleFunc("/collections/{name}/import/parquet", s.handleImportParquet).Methods("POST")
+
+	// Arrow Flight (gRPC over HTTP/2)
+	s.router.HandleFunc(FlightRoute, s.handleFlight).Methods("POST")
 
 	// Text vectorization operations (automatic embedding generation)
 	s.router.HandleFunc("/collections/{name}/text", s.handleTextInsert).Methods("POST")
@@ -98,13 +180,18 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/collections/{name}/documents", s.handleDocumentUpload).Methods("POST")
 	s.router.HandleFunc("/documents/process", s.handleDocumentProcess).Methods("POST")
 	s.router.HandleFunc("/documents/supported", s.handleSupportedFormats).Methods("GET")
+	s.router.HandleFunc("/estimate", s.handleEstimate).Methods("POST")
 
-	// Web dashboard (simple HTML page)
+	// Web dashboard
 	s.router.HandleFunc("/", s.handleDashboard).Methods("GET")
+	s.router.HandleFunc("/ui/{file}", s.handleDashboardAsset).Methods("GET")
 }
 
 // setupMiddleware configures HTTP middleware
 func (s *Server) setupMiddleware() {
+	// Per-route timeouts, first so that they cover the other middleware
+	s.router.Use(s.timeoutMiddleware)
+
 	// CORS middleware
 	if s.config.CORS {
 		s.router.Use(s.corsMiddleware)
@@ -115,6 +202,24 @@ func (s *Server) setupMiddleware() {
 
 	// JSON content type middleware
 	s.router.Use(s.jsonMiddleware)
+
+	// Rate limiting, per client IP before authentication and per API key
+	// after it (no-op until SetRateLimit)
+	s.router.Use(s.ipRateLimitMiddleware)
+
+	// API key authentication and access control (no-op until SetAuth, except
+	// for Raft RPCs, which carry the cluster secret)
+	s.router.Use(s.authMiddleware)
+	s.router.Use(s.keyRateLimitMiddleware)
+
+	// Bound on requests served at once, interactive ones first (no-op until SetConcurrency)
+	s.router.Use(s.concurrencyMiddleware)
+
+	// Per-key usage accounting (no-op until SetUsage)
+	s.router.Use(s.usageMiddleware)
+
+	// Edge collections pulled from the upstream (no-op until SetEdge)
+	s.router.Use(s.edgeMiddleware)
 }
 
 // Health check endpoint
@@ -134,36 +239,80 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, stats)
 }
 
-// Configuration endpoint
+// Configuration endpoint (GET: show, PUT: change reloadable settings)
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
-	if s.unifiedConfig == nil {
+	switch r.Method {
+	case "GET":
+		s.handleGetConfig(w, r)
+	case "PUT":
+		s.handleUpdateConfig(w, r)
+	}
+}
+
+// Show the configuration in effect
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	unifiedConfig := s.currentConfig()
+	if unifiedConfig == nil {
 		s.writeError(w, http.StatusInternalServerError, "Configuration not available", nil)
 		return
 	}
 
+	// ?view=full shows every section to admins; others see the public ones
+	view := r.URL.Query().Get("view")
+	switch view {
+	case "":
+		view = config.ViewPublic
+	case config.ViewPublic, config.ViewFull:
+	default:
+		s.writeError(w, http.StatusBadRequest, "Invalid view", fmt.Errorf("unknown view '%s': use %s or %s", view, config.ViewPublic, config.ViewFull))
+		return
+	}
+	sections, err := unifiedConfig.View(view == config.ViewFull)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to render configuration", err)
+		return
+	}
+	fingerprint, err := unifiedConfig.Fingerprint()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to render configuration", err)
+		return
+	}
+
+	// The fingerprint lets clients poll for changes without downloading
+	// the configuration again
+	etag := fmt.Sprintf(`"%s-%s-%s"`, config.ConfigAPIVersion, fingerprint, view)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	// Create a response with configuration and metadata
 	response := map[string]interface{}{
-		"config": s.unifiedConfig,
+		"config": sections,
 		"metadata": map[string]interface{}{
-			"source":      s.unifiedConfig.Source,
+			"source":      unifiedConfig.Source,
 			"loaded_at":   time.Now().Format(time.RFC3339),
-			"version":     "v1",
+			"version":     config.ConfigAPIVersion,
 			"description": "VittoriaDB unified configuration",
+			"view":        view,
+			"fingerprint": fingerprint,
 		},
 		"features": map[string]interface{}{
-			"parallel_search":    s.unifiedConfig.Search.Parallel.Enabled,
-			"search_cache":       s.unifiedConfig.Search.Cache.Enabled,
-			"memory_mapped_io":   s.unifiedConfig.Performance.IO.UseMemoryMap,
-			"simd_optimizations": s.unifiedConfig.Performance.EnableSIMD,
-			"async_io":           s.unifiedConfig.Performance.IO.AsyncIO,
+			"parallel_search":    unifiedConfig.Search.Parallel.Enabled,
+			"search_cache":       unifiedConfig.Search.Cache.Enabled,
+			"memory_mapped_io":   unifiedConfig.Performance.IO.UseMemoryMap,
+			"simd_optimizations": unifiedConfig.Performance.EnableSIMD,
+			"async_io":           unifiedConfig.Performance.IO.AsyncIO,
 		},
 		"performance": map[string]interface{}{
-			"max_workers":      s.unifiedConfig.Search.Parallel.MaxWorkers,
-			"cache_entries":    s.unifiedConfig.Search.Cache.MaxEntries,
-			"cache_ttl":        s.unifiedConfig.Search.Cache.TTL.String(),
-			"max_concurrency":  s.unifiedConfig.Performance.MaxConcurrency,
-			"memory_limit_mb":  s.unifiedConfig.Performance.MemoryLimit / (1024 * 1024),
+			"max_workers":     unifiedConfig.Search.Parallel.MaxWorkers,
+			"cache_entries":   unifiedConfig.Search.Cache.MaxEntries,
+			"cache_ttl":       unifiedConfig.Search.Cache.TTL.String(),
+			"max_concurrency": unifiedConfig.Performance.MaxConcurrency,
+			"memory_limit_mb": unifiedConfig.Performance.MemoryLimit / (1024 * 1024),
 		},
+		"runtime": config.CurrentRuntimeSettings(),
 	}
 
 	s.writeJSON(w, http.StatusOK, response)
@@ -179,14 +328,29 @@ func (s *Server) handleCollections(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// List collections
+// List collections, internal ones with ?include_internal=true
 func (s *Server) handleListCollections(w http.ResponseWriter, r *http.Request) {
-	collections, err := s.db.ListCollections(r.Context())
+	list := s.db.ListCollections
+	if r.URL.Query().Get("include_internal") == "true" {
+		list = s.db.ListAllCollections
+	}
+	collections, err := list(r.Context())
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, "Failed to list collections", err)
 		return
 	}
 
+	// Only show the collections the API key can access
+	if key := requestAPIKey(r); key != nil && !key.AllCollections() {
+		visible := collections[:0]
+		for _, info := range collections {
+			if key.CanAccess(info.Name) {
+				visible = append(visible, info)
+			}
+		}
+		collections = visible
+	}
+
 	response := map[string]interface{}{
 		"collections": collections,
 		"count":       len(collections),
@@ -197,30 +361,150 @@ func (s *Server) handleListCollections(w http.ResponseWriter, r *http.Request) {
 
 // Create collection
 func (s *Server) handleCreateCollection(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	// Unknown fields are rejected, so that a misspelled setting is not
+	// silently replaced by its default
 	var req core.CreateCollectionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid collection request", err)
+		return
+	}
+
+	if !s.authorize(w, r, req.Name, auth.PermissionAdmin) {
+		return
+	}
+
+	if err := validateCreateCollection(&req, s.maxDimensions()); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid collection request", err)
+		return
+	}
+
+	s.applyVectorizerDefaults(req.VectorizerConfig)
+
+	// ?dry_run=true checks everything creating the collection would without
+	// creating it
+	if r.URL.Query().Get("dry_run") == "true" {
+		if err := s.db.ValidateCreateCollection(r.Context(), &req); err != nil {
+			if errors.Is(err, core.ErrAlreadyExists) {
+				s.writeError(w, http.StatusConflict, "Collection already exists", err)
+			} else if errors.Is(err, core.ErrLimitExceeded) {
+				s.writeError(w, http.StatusConflict, "Collection limit reached", err)
+			} else {
+				s.writeError(w, http.StatusBadRequest, "Invalid collection request", err)
+			}
+			return
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status":     "valid",
+			"collection": req.Name,
+			"dimensions": req.Dimensions,
+			"dry_run":    true,
+		})
 		return
 	}
 
-	if err := s.db.CreateCollection(r.Context(), &req); err != nil {
-		if strings.Contains(err.Error(), "already exists") {
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpCreateCollection, Create: &req}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrAlreadyExists) {
 			s.writeError(w, http.StatusConflict, "Collection already exists", err)
+		} else if errors.Is(err, core.ErrLimitExceeded) {
+			s.writeError(w, http.StatusConflict, "Collection limit reached", err)
 		} else {
 			s.writeError(w, http.StatusBadRequest, "Failed to create collection", err)
 		}
 		return
 	}
 
-	response := map[string]string{
+	response := map[string]interface{}{
 		"status":     "created",
 		"collection": req.Name,
 	}
+	// Dimensions may have been inferred from the vectorizer
+	if collection, err := s.db.GetCollection(r.Context(), req.Name); err == nil {
+		response["dimensions"] = collection.Dimensions()
+	}
 
 	s.writeJSON(w, http.StatusCreated, response)
 }
 
-// Collection endpoint (GET: info, DELETE: drop)
+// maxDimensions returns the most dimensions a collection may have
+func (s *Server) maxDimensions() int {
+	if cfg := s.currentConfig(); cfg != nil && cfg.Limits.MaxDimensions > 0 {
+		return cfg.Limits.MaxDimensions
+	}
+	return core.MaxDimensions
+}
+
+// validateCreateCollection checks the fields of a collection creation
+// request and reports every problem found at once. Metric and index type
+// were checked as they were decoded; the database checks the remaining
+// limits when it creates the collection.
+func validateCreateCollection(req *core.CreateCollectionRequest, maxDimensions int) error {
+	var problems []string
+	if req.Name == "" {
+		problems = append(problems, "name is required")
+	} else if err := core.ValidateCollectionName(req.Name); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	switch {
+	case req.Dimensions == 0 && req.VectorizerConfig == nil && req.VectorizerProfile == "":
+		problems = append(problems, "dimensions is required without a vectorizer_config or vectorizer_profile")
+	case req.Dimensions < 0 || req.Dimensions > maxDimensions:
+		problems = append(problems, fmt.Sprintf("dimensions must be between 1 and %d, got %d", maxDimensions, req.Dimensions))
+	}
+
+	if req.ExpectedCount < 0 {
+		problems = append(problems, "expected_count cannot be negative")
+	}
+	if req.ContentStorage != nil && req.ContentStorage.MaxSize < 0 {
+		problems = append(problems, "content_storage.max_size cannot be negative")
+	}
+	if req.Sharding != nil && req.Sharding.Shards < 1 {
+		problems = append(problems, "sharding.shards must be at least 1")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// applyVectorizerDefaults fills in the provider settings a new collection's
+// vectorizer leaves out from the server's embeddings configuration. They are
+// saved with the collection, so later configuration changes do not affect it.
+func (s *Server) applyVectorizerDefaults(vectorizerConfig *embeddings.VectorizerConfig) {
+	cfg := s.currentConfig()
+	if vectorizerConfig == nil || cfg == nil {
+		return
+	}
+
+	switch vectorizerConfig.Type {
+	case embeddings.VectorizerTypeOllama:
+		ollama := cfg.Embeddings.Ollama
+		if vectorizerConfig.Model == "" {
+			vectorizerConfig.Model = ollama.Model
+		}
+		if vectorizerConfig.Options == nil {
+			vectorizerConfig.Options = make(map[string]interface{})
+		}
+		if _, set := vectorizerConfig.Options["base_url"]; !set && ollama.BaseURL != "" {
+			vectorizerConfig.Options["base_url"] = ollama.BaseURL
+		}
+		if _, set := vectorizerConfig.Options["timeout"]; !set && ollama.Timeout > 0 {
+			vectorizerConfig.Options["timeout"] = ollama.Timeout.String()
+		}
+	}
+}
+
+// Collection endpoint (GET: info, PUT: update, DELETE: drop)
 func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
@@ -228,6 +512,8 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
 		s.handleGetCollection(w, r, name)
+	case "PUT":
+		s.handleUpdateCollection(w, r, name)
 	case "DELETE":
 		s.handleDropCollection(w, r, name)
 	}
@@ -237,7 +523,7 @@ func (s *Server) handleCollection(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetCollection(w http.ResponseWriter, r *http.Request, name string) {
 	collection, err := s.db.GetCollection(r.Context(), name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -258,25 +544,231 @@ func (s *Server) handleGetCollection(w http.ResponseWriter, r *http.Request, nam
 	}
 }
 
+// Update collection settings
+func (s *Server) handleUpdateCollection(w http.ResponseWriter, r *http.Request, name string) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	var req core.UpdateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+	s.applyVectorizerDefaults(req.Vectorizer)
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpUpdateCollection, Collection: name, Update: &req}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to update collection", err)
+		}
+		return
+	}
+
+	s.handleGetCollection(w, r, name)
+}
+
 // Drop collection
 func (s *Server) handleDropCollection(w http.ResponseWriter, r *http.Request, name string) {
-	if err := s.db.DropCollection(r.Context(), name); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpDropCollection, Collection: name}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else if errors.Is(err, core.ErrConflict) {
+			// Group members and internal collections
+			s.writeError(w, http.StatusConflict, "Collection cannot be dropped", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to drop collection", err)
 		}
 		return
 	}
+	s.shadows.remove(name)
 
-	response := map[string]string{
+	response := map[string]interface{}{
 		"status":     "deleted",
 		"collection": name,
 	}
+	if cfg := s.currentConfig(); cfg != nil && cfg.Storage.TrashRetention > 0 {
+		response["restorable_until"] = time.Now().Add(cfg.Storage.TrashRetention)
+	}
 
 	s.writeJSON(w, http.StatusOK, response)
 }
 
+// Index stats endpoint
+func (s *Server) handleIndexStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	stats, err := vittoriaCollection.IndexStats()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to get index stats", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, stats)
+}
+
+// Index integrity endpoint: validates the HNSW graph without changing it
+func (s *Server) handleIndexIntegrity(w http.ResponseWriter, r *http.Request) {
+	s.serveIndexMaintenance(w, r, (*core.VittoriaCollection).CheckIndex, false)
+}
+
+// Index repair endpoint: fixes the problems the integrity check finds. Each
+// node repairs its own copy of the index, so this is not replicated.
+func (s *Server) handleIndexRepair(w http.ResponseWriter, r *http.Request) {
+	s.serveIndexMaintenance(w, r, (*core.VittoriaCollection).RepairIndex, true)
+}
+
+// serveIndexMaintenance runs an index check or repair on the named collection;
+// heavy runs wait for its maintenance windows
+func (s *Server) serveIndexMaintenance(w http.ResponseWriter, r *http.Request, run func(*core.VittoriaCollection, context.Context) (*core.IndexIntegrityReport, error), heavy bool) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+	if heavy && s.refuseOutsideWindow(w, r, vittoriaCollection) {
+		return
+	}
+
+	report, err := run(vittoriaCollection, r.Context())
+	if err != nil {
+		if errors.Is(err, core.ErrConflict) {
+			s.writeError(w, http.StatusConflict, "Index cannot be checked", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Index maintenance failed", err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, report)
+}
+
+// Shards endpoint
+func (s *Server) handleShards(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+		return
+	}
+
+	shards, err := vittoriaCollection.Shards()
+	if err != nil {
+		s.writeError(w, http.StatusBadGateway, "Failed to get shards", err)
+		return
+	}
+	if shards == nil {
+		s.writeError(w, http.StatusBadRequest, "Collection is not sharded", nil)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"collection": name,
+		"shards":     shards,
+	})
+}
+
+// Rebalance endpoint: changes the shard count of a sharded collection
+func (s *Server) handleRebalance(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	var req struct {
+		Shards int `json:"shards"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpReshard, Collection: name, Shards: req.Shards}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to rebalance collection", err)
+		}
+		return
+	}
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		return
+	}
+
+	shards, err := collection.(*core.VittoriaCollection).Shards()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to get shards", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "rebalanced",
+		"collection": name,
+		"shards":     shards,
+	})
+}
+
 // Collection stats endpoint
 func (s *Server) handleCollectionStats(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -284,7 +776,7 @@ func (s *Server) handleCollectionStats(w http.ResponseWriter, r *http.Request) {
 
 	collection, err := s.db.GetCollection(r.Context(), name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -292,17 +784,16 @@ func (s *Server) handleCollectionStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	count, err := collection.Count()
-	if err != nil {
-		s.writeError(w, http.StatusInternalServerError, "Failed to get collection count", err)
+	vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
 		return
 	}
 
-	stats := map[string]interface{}{
-		"name":         collection.Name(),
-		"dimensions":   collection.Dimensions(),
-		"metric":       collection.Metric().String(),
-		"vector_count": count,
+	stats, err := vittoriaCollection.Details()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to get collection stats", err)
+		return
 	}
 
 	s.writeJSON(w, http.StatusOK, stats)
@@ -310,12 +801,16 @@ func (s *Server) handleCollectionStats(w http.ResponseWriter, r *http.Request) {
 
 // Insert vector endpoint
 func (s *Server) handleVectors(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	collection, err := s.db.GetCollection(r.Context(), name)
+	_, err := s.db.GetCollection(r.Context(), name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -323,14 +818,31 @@ func (s *Server) handleVectors(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var vector core.Vector
-	if err := json.NewDecoder(r.Body).Decode(&vector); err != nil {
+	// An "if" condition makes the write conditional on the stored record
+	var req struct {
+		core.Vector
+		If *core.Filter `json:"if"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
 		return
 	}
+	vector := req.Vector
 
-	if err := collection.Insert(r.Context(), &vector); err != nil {
-		s.writeError(w, http.StatusBadRequest, "Failed to insert vector", err)
+	if err := scopeVectors(r, []*core.Vector{&vector}); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpInsert, Collection: name, Vectors: []*core.Vector{&vector}, Condition: req.If}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrConditionFailed) {
+			s.writeError(w, http.StatusPreconditionFailed, "Condition not met", err)
+		} else {
+			s.writeError(w, http.StatusBadRequest, "Failed to insert vector", err)
+		}
 		return
 	}
 
@@ -344,12 +856,16 @@ func (s *Server) handleVectors(w http.ResponseWriter, r *http.Request) {
 
 // Batch insert vectors endpoint
 func (s *Server) handleVectorsBatch(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	collection, err := s.db.GetCollection(r.Context(), name)
+	_, err := s.db.GetCollection(r.Context(), name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -357,24 +873,45 @@ func (s *Server) handleVectorsBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Vectors []*core.Vector `json:"vectors"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
-		return
-	}
-
-	if err := collection.InsertBatch(r.Context(), req.Vectors); err != nil {
-		s.writeError(w, http.StatusBadRequest, "Failed to insert vectors", err)
+	// The body is {"vectors": [...], "if": {...}}, where if is a condition
+	// every vector's stored record must meet. Vectors are decoded and
+	// inserted a chunk at a time, each chunk a command of its own.
+	inserted, batches := 0, 0
+	var namespaceErr, insertErr error
+	err = decodeBatch(r.Body, batchChunkSize, func(vectors []*core.Vector, condition *core.Filter) error {
+		if namespaceErr = scopeVectors(r, vectors); namespaceErr != nil {
+			return namespaceErr
+		}
+		if insertErr = s.execute(r.Context(), &cluster.Command{Op: cluster.OpInsert, Collection: name, Vectors: vectors, Condition: condition}); insertErr != nil {
+			return insertErr
+		}
+		inserted += len(vectors)
+		batches++
+		return nil
+	})
+	if err != nil {
+		if inserted > 0 {
+			err = fmt.Errorf("%w (%d vectors were inserted before)", err, inserted)
+		}
+		switch {
+		case insertErr != nil && s.writeIfLeadershipLost(w, insertErr):
+		case errors.Is(insertErr, core.ErrConditionFailed):
+			s.writeError(w, http.StatusPreconditionFailed, "Condition not met", err)
+		case insertErr != nil:
+			s.writeError(w, http.StatusBadRequest, "Failed to insert vectors", err)
+		case namespaceErr != nil:
+			s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		default:
+			s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		}
 		return
 	}
 
 	response := map[string]interface{}{
 		"status":   "inserted",
-		"inserted": len(req.Vectors),
+		"inserted": inserted,
 		"failed":   0,
+		"batches":  batches,
 	}
 
 	s.writeJSON(w, http.StatusCreated, response)
@@ -388,7 +925,7 @@ func (s *Server) handleVector(w http.ResponseWriter, r *http.Request) {
 
 	collection, err := s.db.GetCollection(r.Context(), collectionName)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -396,19 +933,25 @@ func (s *Server) handleVector(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ns, err := requestNamespace(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
 	switch r.Method {
 	case "GET":
-		s.handleGetVector(w, r, collection, vectorID)
+		s.handleGetVector(w, r, collection, ns, vectorID)
 	case "DELETE":
-		s.handleDeleteVector(w, r, collection, vectorID)
+		s.handleDeleteVector(w, r, collection, ns, vectorID)
 	}
 }
 
 // Get vector by ID
-func (s *Server) handleGetVector(w http.ResponseWriter, r *http.Request, collection core.Collection, id string) {
-	vector, err := collection.Get(r.Context(), id)
+func (s *Server) handleGetVector(w http.ResponseWriter, r *http.Request, collection core.Collection, ns, id string) {
+	vector, err := collection.GetInNamespace(r.Context(), ns, id)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Vector not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get vector", err)
@@ -420,9 +963,16 @@ func (s *Server) handleGetVector(w http.ResponseWriter, r *http.Request, collect
 }
 
 // Delete vector by ID
-func (s *Server) handleDeleteVector(w http.ResponseWriter, r *http.Request, collection core.Collection, id string) {
-	if err := collection.Delete(r.Context(), id); err != nil {
-		if strings.Contains(err.Error(), "not found") {
+func (s *Server) handleDeleteVector(w http.ResponseWriter, r *http.Request, collection core.Collection, ns, id string) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpDelete, Collection: collection.Name(), IDs: []string{id}, Namespace: ns}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Vector not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to delete vector", err)
@@ -445,7 +995,7 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	collection, err := s.db.GetCollection(r.Context(), name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -454,21 +1004,42 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var searchReq core.SearchRequest
+	var rerank rerankOptions
 
 	if r.Method == "GET" {
 		// Parse query parameters
-		if err := s.parseSearchParams(r, &searchReq); err != nil {
+		if err := s.parseSearchParams(r, &searchReq); err == nil {
+			rerank, err = parseRerankParams(r.URL.Query())
+		}
+		if err != nil {
 			s.writeError(w, http.StatusBadRequest, "Invalid search parameters", err)
 			return
 		}
 	} else {
-		// Parse JSON body
-		if err := json.NewDecoder(r.Body).Decode(&searchReq); err != nil {
+		// Parse JSON body; the rerank settings sit next to the search fields
+		body := struct {
+			*core.SearchRequest
+			*rerankOptions
+		}{&searchReq, &rerank}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
 			return
 		}
 	}
 
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &searchReq.Namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+	if err := core.ValidateFilter(searchReq.Filter); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid filter", err)
+		return
+	}
+
 	// Set defaults
 	if searchReq.Limit <= 0 {
 		searchReq.Limit = 10
@@ -477,11 +1048,46 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		searchReq.Limit = 1000
 	}
 
+	// Counts skip ranking, so reranking and streaming do not apply
+	if searchReq.CountOnly {
+		results, err := collection.Search(r.Context(), &searchReq)
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, "Search failed", err)
+			return
+		}
+		response := map[string]interface{}{
+			"count":   results.Total,
+			"took_ms": results.TookMS,
+		}
+		if results.Explain != nil {
+			response["explain"] = results.Explain
+		}
+		s.writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	if rerank.Rerank {
+		s.rerankAndRespond(w, r, collection, &searchReq, &rerank)
+		return
+	}
+
+	if wantsEventStream(r) {
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+			return
+		}
+		s.streamSearch(w, r, vittoriaCollection, &searchReq)
+		return
+	}
+
+	start := time.Now()
 	results, err := collection.Search(r.Context(), &searchReq)
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, "Search failed", err)
 		return
 	}
+	s.mirrorSearch(name, &searchReq, results, time.Since(start))
 
 	s.writeJSON(w, http.StatusOK, results)
 }
@@ -490,17 +1096,27 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 func (s *Server) parseSearchParams(r *http.Request, req *core.SearchRequest) error {
 	query := r.URL.Query()
 
-	// Parse vector
-	vectorStr := query.Get("vector")
-	if vectorStr == "" {
-		return fmt.Errorf("vector parameter is required")
+	// Parse count mode and threshold; a count without one needs no vector
+	req.CountOnly = query.Get("count_only") == "true"
+	if minScoreStr := query.Get("min_score"); minScoreStr != "" {
+		minScore, err := strconv.ParseFloat(minScoreStr, 32)
+		if err != nil {
+			return fmt.Errorf("invalid min_score: %w", err)
+		}
+		threshold := float32(minScore)
+		req.MinScore = &threshold
 	}
 
-	vector, err := s.parseVectorString(vectorStr)
-	if err != nil {
-		return fmt.Errorf("invalid vector format: %w", err)
+	// Parse vector
+	if vectorStr := query.Get("vector"); vectorStr != "" {
+		vector, err := s.parseVectorString(vectorStr)
+		if err != nil {
+			return fmt.Errorf("invalid vector format: %w", err)
+		}
+		req.Vector = vector
+	} else if !req.CountOnly || req.MinScore != nil {
+		return fmt.Errorf("vector parameter is required")
 	}
-	req.Vector = vector
 
 	// Parse limit
 	if limitStr := query.Get("limit"); limitStr != "" {
@@ -521,6 +1137,9 @@ func (s *Server) parseSearchParams(r *http.Request, req *core.SearchRequest) err
 	}
 
 	// Parse include flags
+	req.NormalizeScores = query.Get("normalize_scores") == "true"
+	req.Rescore = query.Get("rescore") == "true"
+	req.Explain = query.Get("explain") == "true"
 	req.IncludeVector = query.Get("include_vector") == "true"
 	req.IncludeMetadata = query.Get("include_metadata") != "false" // default true
 
@@ -560,83 +1179,33 @@ func (s *Server) parseVectorString(vectorStr string) ([]float32, error) {
 	return vector, nil
 }
 
-// Simple web dashboard
-func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <title>VittoriaDB Dashboard</title>
-    <style>
-        body { font-family: Arial, sans-serif; margin: 40px; }
-        .header { background: #f4f4f4; padding: 20px; border-radius: 5px; }
-        .section { margin: 20px 0; }
-        .endpoint { background: #f9f9f9; padding: 10px; margin: 5px 0; border-left: 4px solid #007cba; }
-        code { background: #f4f4f4; padding: 2px 4px; border-radius: 3px; }
-    </style>
-</head>
-<body>
-    <div class="header">
-        <h1>🚀 VittoriaDB</h1>
-        <p>Simple Embedded Vector Database</p>
-    </div>
-    
-    <div class="section">
-        <h2>Quick Start</h2>
-        <div class="endpoint">
-            <strong>Health Check:</strong> <code>GET /health</code>
-        </div>
-        <div class="endpoint">
-            <strong>List Collections:</strong> <code>GET /collections</code>
-        </div>
-        <div class="endpoint">
-            <strong>Create Collection:</strong> <code>POST /collections</code>
-        </div>
-    </div>
-    
-    <div class="section">
-        <h2>Example Usage</h2>
-        <pre><code># Create collection
-curl -X POST http://localhost:8080/collections \
-  -H "Content-Type: application/json" \
-  -d '{"name": "docs", "dimensions": 4, "metric": "cosine"}'
-
-# Insert vector
-curl -X POST http://localhost:8080/collections/docs/vectors \
-  -H "Content-Type: application/json" \
-  -d '{"id": "doc1", "vector": [0.1, 0.2, 0.3, 0.4], "metadata": {"title": "Test"}}'
-
-# Search
-curl "http://localhost:8080/collections/docs/search?vector=0.1,0.2,0.3,0.4&limit=5"</code></pre>
-    </div>
-    
-    <div class="section">
-        <h2>API Endpoints</h2>
-        <div class="endpoint"><code>GET /health</code> - Health check</div>
-        <div class="endpoint"><code>GET /stats</code> - Database statistics</div>
-        <div class="endpoint"><code>GET /config</code> - Current configuration</div>
-        <div class="endpoint"><code>GET /collections</code> - List collections</div>
-        <div class="endpoint"><code>POST /collections</code> - Create collection</div>
-        <div class="endpoint"><code>GET /collections/{name}</code> - Get collection info</div>
-        <div class="endpoint"><code>DELETE /collections/{name}</code> - Delete collection</div>
-        <div class="endpoint"><code>POST /collections/{name}/vectors</code> - Insert vector</div>
-        <div class="endpoint"><code>GET /collections/{name}/search</code> - Search vectors</div>
-    </div>
-</body>
-</html>`
-
-	w.Header().Set("Content-Type", "text/html")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(html))
-}
-
 // Text insertion endpoint (automatic vectorization)
 func (s *Server) handleTextInsert(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	collection, err := s.db.GetCollection(r.Context(), name)
+	var textVector core.TextVector
+	if err := json.NewDecoder(r.Body).Decode(&textVector); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+		return
+	}
+
+	if err := scopeTextVectors(r, []*core.TextVector{&textVector}); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	// Create the collection only once the request is known to be valid
+	collection, created, err := s.textCollection(r.Context(), name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -644,46 +1213,40 @@ func (s *Server) handleTextInsert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var textVector core.TextVector
-	if err := json.NewDecoder(r.Body).Decode(&textVector); err != nil {
-		s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
-		return
-	}
-
 	// Check if collection has vectorizer
 	if !collection.HasVectorizer() {
 		s.writeError(w, http.StatusBadRequest, "Collection does not have vectorizer configured", nil)
 		return
 	}
 
-	if err := collection.InsertText(r.Context(), &textVector); err != nil {
+	if err := s.insertTexts(r.Context(), collection, []*core.TextVector{&textVector}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
 		s.writeError(w, http.StatusBadRequest, "Failed to insert text", err)
 		return
 	}
 
-	response := map[string]string{
+	response := map[string]interface{}{
 		"status": "inserted",
 		"id":     textVector.ID,
 	}
+	if created {
+		response["collection_created"] = true
+	}
 
 	s.writeJSON(w, http.StatusCreated, response)
 }
 
 // Batch text insertion endpoint (automatic vectorization)
 func (s *Server) handleTextBatch(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	name := vars["name"]
-
-	collection, err := s.db.GetCollection(r.Context(), name)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			s.writeError(w, http.StatusNotFound, "Collection not found", err)
-		} else {
-			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
-		}
+	if s.redirectIfFollower(w, r) {
 		return
 	}
 
+	vars := mux.Vars(r)
+	name := vars["name"]
+
 	var req struct {
 		Texts []*core.TextVector `json:"texts"`
 	}
@@ -693,13 +1256,35 @@ func (s *Server) handleTextBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := scopeTextVectors(r, req.Texts); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
+	// Create the collection only once the request is known to be valid
+	collection, created, err := s.textCollection(r.Context(), name)
+	if err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
 	// Check if collection has vectorizer
 	if !collection.HasVectorizer() {
 		s.writeError(w, http.StatusBadRequest, "Collection does not have vectorizer configured", nil)
 		return
 	}
 
-	if err := collection.InsertTextBatch(r.Context(), req.Texts); err != nil {
+	if err := s.insertTexts(r.Context(), collection, req.Texts); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
 		s.writeError(w, http.StatusBadRequest, "Failed to insert texts", err)
 		return
 	}
@@ -709,6 +1294,9 @@ func (s *Server) handleTextBatch(w http.ResponseWriter, r *http.Request) {
 		"inserted": len(req.Texts),
 		"failed":   0,
 	}
+	if created {
+		response["collection_created"] = true
+	}
 
 	s.writeJSON(w, http.StatusCreated, response)
 }
@@ -720,7 +1308,7 @@ func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
 
 	collection, err := s.db.GetCollection(r.Context(), name)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, core.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, "Collection not found", err)
 		} else {
 			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
@@ -739,6 +1327,8 @@ func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
 	var limit int = 10
 	var includeMetadata bool = true
 	var includeContent bool = false
+	var namespace string
+	var rerank rerankOptions
 	
 	if r.Method == "POST" {
 		// Parse JSON body for POST requests
@@ -747,12 +1337,17 @@ func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
 			Limit           int    `json:"limit"`
 			IncludeMetadata bool   `json:"include_metadata"`
 			IncludeContent  bool   `json:"include_content"`
+			Namespace       string `json:"namespace"`
+			Rerank          bool   `json:"rerank"`
+			RerankTopK      int    `json:"rerank_top_k"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
 			return
 		}
 		query = req.Query
+		rerank = rerankOptions{Rerank: req.Rerank, RerankTopK: req.RerankTopK}
+		namespace = req.Namespace
 		if req.Limit > 0 {
 			limit = req.Limit
 		}
@@ -779,6 +1374,12 @@ func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
 		if contentStr := r.URL.Query().Get("include_content"); contentStr != "" {
 			includeContent = contentStr == "true"
 		}
+
+		var err error
+		if rerank, err = parseRerankParams(r.URL.Query()); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid search parameters", err)
+			return
+		}
 	}
 
 	if query == "" {
@@ -786,11 +1387,21 @@ func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scope, err := requestNamespace(r)
+	if err == nil {
+		err = scopeNamespace(scope, &namespace)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
 	// Create search request with content inclusion
 	searchReq := &core.SearchRequest{
 		Limit:           limit,
 		IncludeMetadata: includeMetadata,
 		IncludeContent:  includeContent,
+		Namespace:       namespace,
 	}
 
 	// Perform text search with automatic vectorization using the enhanced search
@@ -809,11 +1420,30 @@ func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	searchReq.Vector = queryEmbedding
+
+	if rerank.Rerank {
+		rerank.Query = query
+		s.rerankAndRespond(w, r, collection, searchReq, &rerank)
+		return
+	}
+
+	if wantsEventStream(r) {
+		vittoriaCollection, ok := collection.(*core.VittoriaCollection)
+		if !ok {
+			s.writeError(w, http.StatusInternalServerError, "Invalid collection type", nil)
+			return
+		}
+		s.streamSearch(w, r, vittoriaCollection, searchReq)
+		return
+	}
+
+	start := time.Now()
 	results, err := collection.Search(r.Context(), searchReq)
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, "Search failed", err)
 		return
 	}
+	s.mirrorSearch(name, searchReq, results, time.Since(start))
 
 	s.writeJSON(w, http.StatusOK, results)
 }
@@ -824,7 +1454,7 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+APIKeyHeader+", "+NamespaceHeader)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -839,7 +1469,9 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		next.ServeHTTP(w, r)
-		log.Printf("%s %s %v", r.Method, r.URL.Path, time.Since(start))
+		if s.logs("info") {
+			log.Printf("%s %s %v", r.Method, r.URL.Path, time.Since(start))
+		}
 	})
 }
 
@@ -859,31 +1491,54 @@ func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{})
 	}
 }
 
+// readOnly reports whether the server serves a data directory written by
+// another process
+func (s *Server) readOnly() bool {
+	cfg := s.currentConfig()
+	return cfg != nil && cfg.ReadOnly
+}
+
 func (s *Server) writeError(w http.ResponseWriter, status int, message string, err error) {
-	errorResponse := map[string]interface{}{
-		"error":  message,
-		"status": status,
-		"time":   time.Now().Unix(),
+	// Whatever the write, a read-only server refuses it the same way
+	if errors.Is(err, core.ErrReadOnly) {
+		status = http.StatusForbidden
 	}
-
-	if err != nil {
-		errorResponse["details"] = err.Error()
+	// Writes refused for the memory limit may succeed once memory is freed
+	if errors.Is(err, core.ErrMemoryLimit) {
+		status = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", "5")
+	}
+	level := "warn"
+	if status >= http.StatusInternalServerError {
+		level = "error"
+	}
+	if err != nil && s.logs(level) {
 		log.Printf("API Error: %s - %v", message, err)
 	}
 
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(errorResponse)
+	json.NewEncoder(w).Encode(newErrorResponse(status, message, err))
 }
 
 // Document processing handlers
 
 // handleDocumentUpload handles document upload and processing for a collection
 func (s *Server) handleDocumentUpload(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
 	vars := mux.Vars(r)
 	collectionName := vars["name"]
 
+	ns, err := requestNamespace(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid namespace", err)
+		return
+	}
+
 	// Parse multipart form
-	err := r.ParseMultipartForm(32 << 20) // 32MB max
+	err = r.ParseMultipartForm(32 << 20) // 32MB max
 	if err != nil {
 		s.writeError(w, http.StatusBadRequest, "Failed to parse multipart form", err)
 		return
@@ -908,6 +1563,7 @@ func (s *Server) handleDocumentUpload(w http.ResponseWriter, r *http.Request) {
 			config.ChunkOverlap = size
 		}
 	}
+	config.OCR = s.ocrConfig(r)
 	if lang := r.FormValue("language"); lang != "" {
 		config.Language = lang
 	}
@@ -947,8 +1603,9 @@ func (s *Server) handleDocumentUpload(w http.ResponseWriter, r *http.Request) {
 		if collection.HasVectorizer() {
 			// Create TextVector for automatic embedding generation
 			textVector := &core.TextVector{
-				ID:   chunk.ID,
-				Text: chunk.Content,
+				ID:        chunk.ID,
+				Namespace: ns,
+				Text:      chunk.Content,
 				Metadata: map[string]interface{}{
 					"document_id":    doc.ID,
 					"document_title": doc.Title,
@@ -963,15 +1620,16 @@ func (s *Server) handleDocumentUpload(w http.ResponseWriter, r *http.Request) {
 				textVector.Metadata["chunk_"+k] = v
 			}
 
-			if err := collection.InsertText(r.Context(), textVector); err != nil {
+			if err := s.insertTexts(r.Context(), collection, []*core.TextVector{textVector}); err != nil {
 				log.Printf("Failed to insert text chunk %s: %v", chunk.ID, err)
 				continue
 			}
 		} else {
 			// Fallback to placeholder vector for collections without vectorizer
 			vector := &core.Vector{
-				ID:     chunk.ID,
-				Vector: make([]float32, 384), // Placeholder vector
+				ID:        chunk.ID,
+				Namespace: ns,
+				Vector:    make([]float32, 384), // Placeholder vector
 				Metadata: map[string]interface{}{
 					"document_id":    doc.ID,
 					"document_title": doc.Title,
@@ -986,7 +1644,7 @@ func (s *Server) handleDocumentUpload(w http.ResponseWriter, r *http.Request) {
 				vector.Metadata["chunk_"+k] = v
 			}
 
-			if err := collection.Insert(r.Context(), vector); err != nil {
+			if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpInsert, Collection: collectionName, Vectors: []*core.Vector{vector}}); err != nil {
 				log.Printf("Failed to insert chunk %s: %v", chunk.ID, err)
 				continue
 			}
@@ -1037,6 +1695,7 @@ func (s *Server) handleDocumentProcess(w http.ResponseWriter, r *http.Request) {
 			config.ChunkOverlap = size
 		}
 	}
+	config.OCR = s.ocrConfig(r)
 
 	// Process document
 	proc, err := s.processor.GetProcessorByFilename(header.Filename)
@@ -1066,3 +1725,20 @@ func (s *Server) handleSupportedFormats(w http.ResponseWriter, r *http.Request)
 
 	s.writeJSON(w, http.StatusOK, response)
 }
+
+// ocrConfig returns the OCR settings of an upload: the configured ones, turned
+// on or off by the "ocr" form field when present
+func (s *Server) ocrConfig(r *http.Request) *processor.OCRConfig {
+	var ocr *processor.OCRConfig
+	if cfg := s.currentConfig(); cfg != nil {
+		ocr = cfg.OCRConfig()
+	} else {
+		ocr = &processor.OCRConfig{}
+	}
+	if value := r.FormValue("ocr"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			ocr.Enabled = enabled
+		}
+	}
+	return ocr
+}
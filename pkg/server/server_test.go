@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// newTestServer returns a server over a database in a temporary directory,
+// closed when the test ends
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	db := core.NewDatabase()
+	if err := db.Open(context.Background(), &core.Config{DataDir: t.TempDir()}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := config.DefaultConfig()
+	cfg.Logging.Level = "error"
+	return NewServer(db, &ServerConfig{}, cfg)
+}
+
+// serve routes a request from addr through the server's middleware and
+// handlers
+func serve(s *Server, method, target, addr string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	request.RemoteAddr = addr
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// routeTemplates returns the path templates of the server's routes
+func routeTemplates(s *Server) map[string]bool {
+	templates := make(map[string]bool)
+	s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if template, err := route.GetPathTemplate(); err == nil {
+			templates[template] = true
+		}
+		return nil
+	})
+	return templates
+}
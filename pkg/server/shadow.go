@@ -0,0 +1,269 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// maxShadowInFlight bounds the mirrored searches running at once, so shadow
+// traffic cannot pile up behind a slow target
+const maxShadowInFlight = 16
+
+// shadowTimeout bounds a single mirrored search
+const shadowTimeout = 30 * time.Second
+
+// ShadowConfig mirrors a share of a collection's searches to another collection
+type ShadowConfig struct {
+	Target  string  `json:"target"`  // Collection receiving the mirrored searches
+	Percent float64 `json:"percent"` // Share of searches mirrored, in (0, 100]
+}
+
+// ShadowStats compares the mirrored searches with the searches they copied
+type ShadowStats struct {
+	Mirrored          int64   `json:"mirrored"`
+	Failed            int64   `json:"failed"`
+	Skipped           int64   `json:"skipped"`       // Not mirrored because too many mirrored searches were running
+	MeanOverlap       float64 `json:"mean_overlap"`  // Share of the primary results the target also returned
+	ExactMatches      int64   `json:"exact_matches"` // Target returned the same IDs in the same order
+	PrimaryMeanMS     float64 `json:"primary_mean_ms"`
+	ShadowMeanMS      float64 `json:"shadow_mean_ms"`
+	MeanLatencyDiffMS float64 `json:"mean_latency_diff_ms"` // Target minus primary
+	LastError         string  `json:"last_error,omitempty"`
+}
+
+// shadow is the mirroring set up for one collection
+type shadow struct {
+	config  ShadowConfig
+	since   time.Time
+	mu      sync.Mutex
+	stats   ShadowStats
+	overlap float64 // Sums behind the means in stats
+	primary time.Duration
+	target  time.Duration
+}
+
+// record adds the outcome of one mirrored search
+func (sh *shadow) record(primary, target *core.SearchResponse, primaryTook, targetTook time.Duration, err error) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if err != nil {
+		sh.stats.Failed++
+		sh.stats.LastError = err.Error()
+		return
+	}
+
+	overlap, exact := compareResults(primary.Results, target.Results)
+	sh.stats.Mirrored++
+	if exact {
+		sh.stats.ExactMatches++
+	}
+	sh.overlap += overlap
+	sh.primary += primaryTook
+	sh.target += targetTook
+
+	n := float64(sh.stats.Mirrored)
+	sh.stats.MeanOverlap = sh.overlap / n
+	sh.stats.PrimaryMeanMS = float64(sh.primary.Microseconds()) / 1000 / n
+	sh.stats.ShadowMeanMS = float64(sh.target.Microseconds()) / 1000 / n
+	sh.stats.MeanLatencyDiffMS = sh.stats.ShadowMeanMS - sh.stats.PrimaryMeanMS
+}
+
+// snapshot returns a copy of the stats
+func (sh *shadow) snapshot() ShadowStats {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.stats
+}
+
+// compareResults returns the share of primary's IDs found in target, and
+// whether both list the same IDs in the same order
+func compareResults(primary, target []*core.SearchResult) (float64, bool) {
+	exact := len(primary) == len(target)
+	found := make(map[string]bool, len(target))
+	for i, result := range target {
+		found[result.ID] = true
+		if exact && primary[i].ID != result.ID {
+			exact = false
+		}
+	}
+
+	if len(primary) == 0 {
+		return 1, exact
+	}
+	shared := 0
+	for _, result := range primary {
+		if found[result.ID] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(primary)), exact
+}
+
+// shadowMirror holds the mirroring of every collection that has one
+type shadowMirror struct {
+	mu       sync.RWMutex
+	shadows  map[string]*shadow
+	inFlight chan struct{}
+}
+
+// newShadowMirror creates an empty mirror
+func newShadowMirror() *shadowMirror {
+	return &shadowMirror{
+		shadows:  make(map[string]*shadow),
+		inFlight: make(chan struct{}, maxShadowInFlight),
+	}
+}
+
+// get returns the mirroring of a collection, or nil
+func (m *shadowMirror) get(collection string) *shadow {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.shadows[collection]
+}
+
+// set starts mirroring a collection, replacing any previous mirroring and its stats
+func (m *shadowMirror) set(collection string, config ShadowConfig) *shadow {
+	sh := &shadow{config: config, since: time.Now()}
+	m.mu.Lock()
+	m.shadows[collection] = sh
+	m.mu.Unlock()
+	return sh
+}
+
+// remove stops mirroring a collection and reports whether it was mirrored
+func (m *shadowMirror) remove(collection string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.shadows[collection]
+	delete(m.shadows, collection)
+	return ok
+}
+
+// mirrorSearch samples a search answered by collection and, when it is picked,
+// repeats it against the shadow target in the background
+func (s *Server) mirrorSearch(collection string, req *core.SearchRequest, response *core.SearchResponse, took time.Duration) {
+	sh := s.shadows.get(collection)
+	if sh == nil || rand.Float64()*100 >= sh.config.Percent {
+		return
+	}
+
+	select {
+	case s.shadows.inFlight <- struct{}{}:
+	default:
+		sh.mu.Lock()
+		sh.stats.Skipped++
+		sh.mu.Unlock()
+		return
+	}
+
+	mirrored := *req
+	go func() {
+		defer func() { <-s.shadows.inFlight }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+
+		target, err := s.db.GetCollection(ctx, sh.config.Target)
+		if err != nil {
+			sh.record(response, nil, took, 0, err)
+			return
+		}
+
+		start := time.Now()
+		shadowResponse, err := target.Search(ctx, &mirrored)
+		sh.record(response, shadowResponse, took, time.Since(start), err)
+	}()
+}
+
+// Shadow mirroring endpoint: shows (GET), sets up (PUT) or stops (DELETE) the
+// mirroring of a collection's searches to a second collection
+func (s *Server) handleShadow(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	collection, err := s.db.GetCollection(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, "Collection not found", err)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to get collection", err)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		var config ShadowConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid JSON", err)
+			return
+		}
+		if err := s.validateShadow(r.Context(), collection, &config); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid shadow configuration", err)
+			return
+		}
+		s.shadows.set(name, config)
+
+	case "DELETE":
+		if !s.shadows.remove(name) {
+			s.writeError(w, http.StatusNotFound, "Shadow mirroring not enabled", nil)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"collection": name,
+			"status":     "disabled",
+		})
+		return
+	}
+
+	sh := s.shadows.get(name)
+	if sh == nil {
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"collection": name,
+			"enabled":    false,
+		})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"collection": name,
+		"enabled":    true,
+		"target":     sh.config.Target,
+		"percent":    sh.config.Percent,
+		"since":      sh.since,
+		"stats":      sh.snapshot(),
+	})
+}
+
+// validateShadow checks that config mirrors collection to a different
+// collection that can answer the same queries
+func (s *Server) validateShadow(ctx context.Context, collection core.Collection, config *ShadowConfig) error {
+	if config.Percent <= 0 || config.Percent > 100 {
+		return fmt.Errorf("percent must be greater than 0 and at most 100")
+	}
+	if config.Target == "" {
+		return fmt.Errorf("target collection is required")
+	}
+	if config.Target == collection.Name() {
+		return fmt.Errorf("a collection cannot shadow itself")
+	}
+
+	target, err := s.db.GetCollection(ctx, config.Target)
+	if err != nil {
+		return err
+	}
+	if target.Dimensions() != collection.Dimensions() {
+		return fmt.Errorf("target collection has %d dimensions, expected %d", target.Dimensions(), collection.Dimensions())
+	}
+	return nil
+}
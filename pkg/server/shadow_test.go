@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// gatedDatabase holds searches on the collection target until the gate is opened
+type gatedDatabase struct {
+	core.Database
+	target string
+	gate   chan struct{}
+	open   sync.Once
+}
+
+// gatedCollection is the target collection of a gatedDatabase
+type gatedCollection struct {
+	core.Collection
+	gate chan struct{}
+}
+
+func (db *gatedDatabase) GetCollection(ctx context.Context, name string) (core.Collection, error) {
+	collection, err := db.Database.GetCollection(ctx, name)
+	if err != nil || name != db.target {
+		return collection, err
+	}
+	return &gatedCollection{Collection: collection, gate: db.gate}, nil
+}
+
+func (db *gatedDatabase) release() {
+	db.open.Do(func() { close(db.gate) })
+}
+
+func (c *gatedCollection) Search(ctx context.Context, req *core.SearchRequest) (*core.SearchResponse, error) {
+	select {
+	case <-c.gate:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return c.Collection.Search(ctx, req)
+}
+
+// newShadowServer returns a server with the collections docs, holding a, b,
+// c and d, and docs_v2, holding a, b, e and f, whose searches wait for the
+// returned database to be released
+func newShadowServer(t *testing.T) (*Server, *gatedDatabase) {
+	t.Helper()
+	ctx := context.Background()
+	db := core.NewDatabase()
+	if err := db.Open(ctx, &core.Config{DataDir: t.TempDir()}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	gated := &gatedDatabase{Database: db, target: "docs_v2", gate: make(chan struct{})}
+	t.Cleanup(gated.release)
+
+	vectors := map[string][]float32{"a": {1, 0}, "b": {1, 0.1}, "c": {1, 0.2}, "d": {1, 0.3}, "e": {0, 1}, "f": {-1, 0}}
+	for name, ids := range map[string][]string{"docs": {"a", "b", "c", "d"}, "docs_v2": {"a", "b", "e", "f"}} {
+		if err := db.CreateCollection(ctx, &core.CreateCollectionRequest{Name: name, Dimensions: 2, Metric: core.DistanceMetricCosine, IndexType: core.IndexTypeFlat}); err != nil {
+			t.Fatalf("CreateCollection failed: %v", err)
+		}
+		collection, _ := db.GetCollection(ctx, name)
+		for _, id := range ids {
+			if err := collection.Insert(ctx, &core.Vector{ID: id, Vector: vectors[id]}); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Logging.Level = "error"
+	return NewServer(gated, &ServerConfig{}, cfg), gated
+}
+
+// shadowStats reads the stats of the mirroring of docs
+func shadowStats(t *testing.T, s *Server) ShadowStats {
+	t.Helper()
+	var body struct {
+		Stats ShadowStats `json:"stats"`
+	}
+	if err := json.NewDecoder(send(s, http.MethodGet, "/collections/docs/shadow", "").Body).Decode(&body); err != nil {
+		t.Fatalf("invalid shadow status: %v", err)
+	}
+	return body.Stats
+}
+
+func TestCompareResults(t *testing.T) {
+	results := func(ids ...string) []*core.SearchResult {
+		list := make([]*core.SearchResult, len(ids))
+		for i, id := range ids {
+			list[i] = &core.SearchResult{ID: id}
+		}
+		return list
+	}
+
+	for _, test := range []struct {
+		primary, target []string
+		overlap         float64
+		exact           bool
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, 1, true},
+		{[]string{"a", "b"}, []string{"b", "a"}, 1, false},
+		{[]string{"a", "b", "c", "d"}, []string{"a", "x", "c"}, 0.5, false},
+		{[]string{"a"}, []string{"a", "b"}, 1, false},
+		{[]string{"a"}, nil, 0, false},
+		{nil, nil, 1, true},
+	} {
+		overlap, exact := compareResults(results(test.primary...), results(test.target...))
+		if overlap != test.overlap || exact != test.exact {
+			t.Errorf("%v against %v: got %v, %v, want %v, %v", test.primary, test.target, overlap, exact, test.overlap, test.exact)
+		}
+	}
+}
+
+func TestShadowRecord(t *testing.T) {
+	response := func(ids ...string) *core.SearchResponse {
+		results := make([]*core.SearchResult, len(ids))
+		for i, id := range ids {
+			results[i] = &core.SearchResult{ID: id}
+		}
+		return &core.SearchResponse{Results: results}
+	}
+
+	sh := &shadow{}
+	sh.record(response("a", "b"), response("a", "b"), 10*time.Millisecond, 30*time.Millisecond, nil)
+	sh.record(response("a", "b"), response("b", "c"), 20*time.Millisecond, 10*time.Millisecond, nil)
+	sh.record(response("a", "b"), nil, 20*time.Millisecond, 0, context.DeadlineExceeded)
+
+	stats := sh.snapshot()
+	want := ShadowStats{
+		Mirrored:          2,
+		Failed:            1,
+		MeanOverlap:       0.75,
+		ExactMatches:      1,
+		PrimaryMeanMS:     15,
+		ShadowMeanMS:      20,
+		MeanLatencyDiffMS: 5,
+		LastError:         context.DeadlineExceeded.Error(),
+	}
+	if stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
+
+func TestShadowMirroring(t *testing.T) {
+	s, db := newShadowServer(t)
+
+	for _, body := range []string{
+		`{"target": "docs_v2", "percent": 0}`,
+		`{"target": "docs_v2", "percent": 101}`,
+		`{"target": "docs", "percent": 100}`,
+		`{"target": "missing", "percent": 100}`,
+	} {
+		if code := send(s, http.MethodPut, "/collections/docs/shadow", body).Code; code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", body, code)
+		}
+	}
+	if code := send(s, http.MethodPut, "/collections/docs/shadow", `{"target": "docs_v2", "percent": 100}`).Code; code != http.StatusOK {
+		t.Fatalf("enabling mirroring: got status %d", code)
+	}
+
+	// Searches are answered while their mirrors are still waiting on the target
+	for i := 0; i < 5; i++ {
+		recorder := send(s, http.MethodPost, "/collections/docs/search", `{"vector": [1, 0], "limit": 4}`)
+		var response core.SearchResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || len(response.Results) != 4 {
+			t.Fatalf("search %d: status %d with %d results, %v", i, recorder.Code, len(response.Results), err)
+		}
+	}
+	if stats := shadowStats(t, s); stats.Mirrored != 0 || stats.Failed != 0 {
+		t.Fatalf("recorded %+v before the target answered", stats)
+	}
+
+	// Every search was mirrored, and the target's slower answers, with half
+	// the primary's results, are recorded
+	time.Sleep(50 * time.Millisecond)
+	db.release()
+	waitFor(t, "the mirrored searches", func() bool { return shadowStats(t, s).Mirrored == 5 })
+	stats := shadowStats(t, s)
+	if stats.MeanOverlap != 0.5 || stats.ExactMatches != 0 || stats.Failed != 0 {
+		t.Errorf("recorded %+v, want an overlap of 0.5 and no exact match", stats)
+	}
+	// Mirrors started during the hold waited for part of it
+	if stats.ShadowMeanMS < 25 || stats.MeanLatencyDiffMS != stats.ShadowMeanMS-stats.PrimaryMeanMS || stats.MeanLatencyDiffMS <= 0 {
+		t.Errorf("recorded latencies %+v, want the target well behind the primary", stats)
+	}
+
+	if code := send(s, http.MethodDelete, "/collections/docs/shadow", "").Code; code != http.StatusOK {
+		t.Errorf("disabling mirroring: got status %d", code)
+	}
+	send(s, http.MethodPost, "/collections/docs/search", `{"vector": [1, 0]}`)
+	if s.shadows.get("docs") != nil {
+		t.Error("mirroring is still set up")
+	}
+}
+
+func TestShadowSampling(t *testing.T) {
+	s, db := newShadowServer(t)
+	sh := s.shadows.set("docs", ShadowConfig{Target: "docs_v2", Percent: 25})
+
+	// With the target held, the first sampled searches fill the in-flight
+	// slots and the others are skipped, so every sampled search is counted
+	const searches = 4000
+	req := &core.SearchRequest{Vector: []float32{1, 0}, Limit: 4}
+	for i := 0; i < searches; i++ {
+		s.mirrorSearch("docs", req, &core.SearchResponse{}, time.Millisecond)
+	}
+	sampled := sh.snapshot().Skipped + maxShadowInFlight
+	if sampled < searches/4-150 || sampled > searches/4+150 {
+		t.Errorf("mirrored %d of %d searches, want about 25%%", sampled, searches)
+	}
+
+	db.release()
+	waitFor(t, "the mirrored searches", func() bool { return sh.snapshot().Mirrored == maxShadowInFlight })
+}
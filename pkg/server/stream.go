@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/core"
+)
+
+// EventStreamType is the content type of Server-Sent Events responses
+const EventStreamType = "text/event-stream"
+
+// wantsEventStream reports whether a search request opted into streaming,
+// with ?stream=true or by accepting text/event-stream
+func wantsEventStream(r *http.Request) bool {
+	return r.URL.Query().Get("stream") == "true" || strings.Contains(r.Header.Get("Accept"), EventStreamType)
+}
+
+// searchOutcome is the final result of a streamed search
+type searchOutcome struct {
+	response *core.SearchResponse
+	err      error
+}
+
+// streamSearch runs a progressive search and streams it as Server-Sent
+// Events: "progress" events carry the best results found so far, and a final
+// "result" or "error" event ends the stream. A search that fails before
+// anything was streamed gets a regular JSON error response.
+func (s *Server) streamSearch(w http.ResponseWriter, r *http.Request, collection *core.VittoriaCollection, req *core.SearchRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "Streaming not supported", nil)
+		return
+	}
+	ctx := r.Context()
+
+	// The search runs in the background and leaves only its latest snapshot
+	// in the mailbox, so a slow client never holds up the search
+	latest := make(chan *core.SearchProgress, 1)
+	done := make(chan searchOutcome, 1)
+	go func() {
+		response, err := collection.SearchProgressive(ctx, req, func(progress *core.SearchProgress) error {
+			select {
+			case <-latest:
+			default:
+			}
+			latest <- progress
+			return nil
+		})
+		done <- searchOutcome{response, err}
+	}()
+
+	started := false
+	send := func(event string, data interface{}) {
+		if !started {
+			started = true
+			beginEventStream(w)
+		}
+		if err := writeEvent(w, event, data); err != nil {
+			log.Printf("Failed to write %s event: %v", event, err)
+		}
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case progress := <-latest:
+			send("progress", progress)
+		case outcome := <-done:
+			switch {
+			case outcome.err == nil:
+				send("result", outcome.response)
+			case !started:
+				s.writeError(w, http.StatusInternalServerError, "Search failed", outcome.err)
+			default:
+				send("error", newErrorResponse(http.StatusInternalServerError, "Search failed", outcome.err))
+			}
+			return
+		case <-ctx.Done():
+			// The search stops at its next snapshot
+			return
+		}
+	}
+}
+
+// beginEventStream writes the headers of a Server-Sent Events response
+func beginEventStream(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", EventStreamType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep reverse proxies from buffering the stream
+	// The stream may outlive the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeEvent writes one Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
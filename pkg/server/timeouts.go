@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// routeTimeout overrides the server's read and write timeouts for a route
+type routeTimeout struct {
+	read   time.Duration // 0 keeps the server's
+	write  time.Duration // 0 keeps the server's
+	stream bool          // No write deadline
+}
+
+// ingestionTimeout bounds uploads that are parsed, embedded or indexed while
+// the client waits
+const ingestionTimeout = 10 * time.Minute
+
+// loadTestTimeout covers the longest load test, and the insertion of its
+// initial vectors
+const loadTestTimeout = core.MaxLoadTestDuration + 5*time.Minute
+
+// defaultRouteTimeouts give ingestion routes more time than the server
+// timeouts, and let exports stream for as long as they need. Event streams
+// and Arrow Flight clear their deadlines themselves.
+var defaultRouteTimeouts = map[string]routeTimeout{
+	"/collections/{name}/documents":      {read: ingestionTimeout, write: ingestionTimeout},
+	"/collections/{name}/vectors/batch":  {read: ingestionTimeout, write: ingestionTimeout},
+	"/documents/process":                 {read: ingestionTimeout, write: ingestionTimeout},
+	"/collections/{name}/text/batch":     {read: ingestionTimeout, write: ingestionTimeout},
+	"/collections/{name}/import/parquet": {read: ingestionTimeout, write: ingestionTimeout},
+	"/collections/{name}/export":         {stream: true},
+	"/collections/{name}/export/parquet": {stream: true},
+	"/groups/{name}/backup":              {stream: true},
+	"/admin/loadtest":                    {write: loadTestTimeout},
+}
+
+// SetRouteTimeouts overrides the timeouts of routes, on top of the built-in
+// ones. Unknown routes are rejected.
+func (s *Server) SetRouteTimeouts(routes []config.RouteTimeoutConfig) error {
+	known := make(map[string]bool)
+	s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if template, err := route.GetPathTemplate(); err == nil {
+			known[template] = true
+		}
+		return nil
+	})
+
+	timeouts := maps.Clone(defaultRouteTimeouts)
+	for _, route := range routes {
+		if !known[route.Path] {
+			return fmt.Errorf("no route %s to set timeouts for", route.Path)
+		}
+		timeouts[route.Path] = routeTimeout{read: route.ReadTimeout, write: route.WriteTimeout, stream: route.Stream}
+	}
+	s.routeTimeouts = timeouts
+	return nil
+}
+
+// timeoutMiddleware replaces the server's deadlines with those of the
+// matched route, counted from when its request is routed
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				if timeout, ok := s.routeTimeouts[template]; ok {
+					timeout.apply(w)
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apply sets the deadlines of the connection serving w
+func (t routeTimeout) apply(w http.ResponseWriter) {
+	controller := http.NewResponseController(w)
+	now := time.Now()
+	if t.read > 0 {
+		controller.SetReadDeadline(now.Add(t.read))
+	}
+	switch {
+	case t.stream:
+		controller.SetWriteDeadline(time.Time{})
+	case t.write > 0:
+		controller.SetWriteDeadline(now.Add(t.write))
+	}
+}
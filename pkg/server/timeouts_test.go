@@ -0,0 +1,85 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+func TestDefaultRouteTimeouts(t *testing.T) {
+	s := newTestServer(t)
+	templates := routeTemplates(s)
+	for template := range defaultRouteTimeouts {
+		if !templates[template] {
+			t.Errorf("timeouts set for %s, which is not a route", template)
+		}
+	}
+	if timeout := s.routeTimeouts["/collections/{name}/vectors/batch"]; timeout.read != ingestionTimeout || timeout.write != ingestionTimeout {
+		t.Errorf("batch inserts have timeouts %+v, want the ingestion timeout", timeout)
+	}
+
+	// Configured timeouts are added to the built-in ones
+	if err := s.SetRouteTimeouts([]config.RouteTimeoutConfig{{Path: "/collections/{name}/search", WriteTimeout: time.Minute}}); err != nil {
+		t.Fatalf("SetRouteTimeouts failed: %v", err)
+	}
+	if timeout := s.routeTimeouts["/collections/{name}/search"]; timeout.write != time.Minute {
+		t.Errorf("search has timeouts %+v, want a write timeout of a minute", timeout)
+	}
+	if _, ok := s.routeTimeouts["/collections/{name}/documents"]; !ok {
+		t.Error("configured timeouts replaced the built-in ones")
+	}
+
+	if err := s.SetRouteTimeouts([]config.RouteTimeoutConfig{{Path: "/collections/{name}/serch", WriteTimeout: time.Minute}}); err == nil {
+		t.Error("expected timeouts for an unknown route to be refused")
+	}
+	if _, ok := s.routeTimeouts["/collections/{name}/search"]; !ok {
+		t.Error("a refused configuration dropped the previous one")
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	// Each route answers after the server's write timeout
+	s := &Server{router: mux.NewRouter(), routeTimeouts: defaultRouteTimeouts}
+	for _, path := range []string{"/slow", "/extended", "/stream"} {
+		s.router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(150 * time.Millisecond)
+			io.WriteString(w, "done")
+		})
+	}
+	s.router.Use(s.timeoutMiddleware)
+	err := s.SetRouteTimeouts([]config.RouteTimeoutConfig{
+		{Path: "/extended", WriteTimeout: 5 * time.Second},
+		{Path: "/stream", Stream: true},
+	})
+	if err != nil {
+		t.Fatalf("SetRouteTimeouts failed: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(s.router)
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	get := func(path string) (string, error) {
+		response, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		return string(body), err
+	}
+	if body, err := get("/slow"); err == nil {
+		t.Errorf("/slow answered %q past the server's write timeout", body)
+	}
+	for _, path := range []string{"/extended", "/stream"} {
+		if body, err := get(path); err != nil || body != "done" {
+			t.Errorf("%s: got %q, %v", path, body, err)
+		}
+	}
+}
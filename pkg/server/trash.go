@@ -0,0 +1,51 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/antonellof/VittoriaDB/pkg/cluster"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/gorilla/mux"
+)
+
+// handleRestoreCollection brings a dropped collection back from the trash
+func (s *Server) handleRestoreCollection(w http.ResponseWriter, r *http.Request) {
+	if s.redirectIfFollower(w, r) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := s.execute(r.Context(), &cluster.Command{Op: cluster.OpRestoreCollection, Collection: name}); err != nil {
+		if s.writeIfLeadershipLost(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			s.writeError(w, http.StatusNotFound, "Collection not found in trash", err)
+		case errors.Is(err, core.ErrAlreadyExists):
+			s.writeError(w, http.StatusConflict, "Collection already exists", err)
+		case errors.Is(err, core.ErrLimitExceeded):
+			s.writeError(w, http.StatusConflict, "Collection limit reached", err)
+		default:
+			s.writeError(w, http.StatusInternalServerError, "Failed to restore collection", err)
+		}
+		return
+	}
+
+	s.handleGetCollection(w, r, name)
+}
+
+// handleTrash lists the dropped collections that can be restored
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	trashed, err := s.db.ListTrash(r.Context())
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, "Failed to list trash", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"collections": trashed,
+		"count":       len(trashed),
+	})
+}
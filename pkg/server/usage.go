@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/antonellof/VittoriaDB/pkg/auth"
+	"github.com/antonellof/VittoriaDB/pkg/core"
+	"github.com/antonellof/VittoriaDB/pkg/embeddings"
+)
+
+// requestUsageKey carries the *requestUsage of a request in its context
+type requestUsageKey struct{}
+
+// requestUsage collects what one request stores and consumes, attributed to
+// its API key once it completes
+type requestUsage struct {
+	vectors atomic.Int64
+	bytes   atomic.Int64
+	tokens  embeddings.TokenUsage
+}
+
+// SetUsage attributes the requests, inserts and embedding tokens of each API
+// key to it in ledger. It only has an effect with authentication enabled.
+func (s *Server) SetUsage(ledger *auth.UsageLedger) {
+	s.usage = ledger
+}
+
+// usageMiddleware measures each authenticated request for its key's usage
+func (s *Server) usageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		if s.usage == nil || key == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		usage := &requestUsage{}
+		ctx := context.WithValue(r.Context(), requestUsageKey{}, usage)
+		ctx = embeddings.WithTokenUsage(ctx, &usage.tokens)
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		s.usage.Record(key.Name, auth.Usage{
+			Requests:        1,
+			VectorsInserted: usage.vectors.Load(),
+			BytesStored:     usage.bytes.Load(),
+			EmbeddingTokens: usage.tokens.Tokens(),
+		}, time.Now())
+	})
+}
+
+// recordStored adds vectors written by the request in ctx to its key's usage
+func recordStored(ctx context.Context, vectors []*core.Vector) {
+	usage, ok := ctx.Value(requestUsageKey{}).(*requestUsage)
+	if !ok {
+		return
+	}
+	for _, vector := range vectors {
+		usage.vectors.Add(1)
+		usage.bytes.Add(storedSize(vector.ID, len(vector.Vector), "", vector.Metadata))
+	}
+}
+
+// recordStoredTexts adds texts vectorized into collection by the request in
+// ctx to its key's usage
+func recordStoredTexts(ctx context.Context, collection core.Collection, textVectors []*core.TextVector) {
+	usage, ok := ctx.Value(requestUsageKey{}).(*requestUsage)
+	if !ok {
+		return
+	}
+	for _, textVector := range textVectors {
+		usage.vectors.Add(1)
+		usage.bytes.Add(storedSize(textVector.ID, collection.Dimensions(), textVector.Text, textVector.Metadata))
+	}
+}
+
+// storedSize estimates the bytes a vector takes up: its ID, float32
+// components, stored content and JSON-encoded metadata
+func storedSize(id string, dimensions int, content string, metadata map[string]interface{}) int64 {
+	size := int64(len(id) + 4*dimensions + len(content))
+	if len(metadata) > 0 {
+		if data, err := json.Marshal(metadata); err == nil {
+			size += int64(len(data))
+		}
+	}
+	return size
+}
+
+// Usage endpoint: reports each API key's usage over a day, month or year for
+// chargeback
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil || s.usage == nil {
+		s.writeError(w, http.StatusNotFound, "Authentication is not enabled", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	period := query.Get("period")
+	if period == "" {
+		period = auth.PeriodMonth
+	}
+
+	// date selects a past period, such as last month with period=month&date=2025-09-01
+	at := time.Now()
+	if date := query.Get("date"); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid date", err)
+			return
+		}
+		at = parsed
+	}
+
+	report, err := s.usage.Report(period, at)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid period", err)
+		return
+	}
+
+	if name := query.Get("key"); name != "" {
+		usage := report.Keys[name]
+		report.Keys = map[string]auth.Usage{name: usage}
+		report.Total = usage
+	}
+
+	s.writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,446 @@
+// VittoriaDB dashboard. Everything shown here comes from the REST API, so the
+// page sees exactly what the API key in use is allowed to see.
+(function () {
+    'use strict';
+
+    const METRICS = ['cosine', 'euclidean', 'dot_product', 'manhattan', 'hamming', 'jaccard'];
+    const INDEX_TYPES = ['flat', 'hnsw', 'ivf'];
+    const BROWSE_PAGE_SIZE = 10;
+    const KEY_STORAGE = 'vittoriadb.apiKey';
+
+    const state = {
+        collection: null, // Selected collection info
+        browse: null,     // {id, namespace, vector, offset}
+    };
+
+    const $ = (id) => document.getElementById(id);
+
+    // api calls an endpoint and returns the decoded JSON body, throwing the
+    // server's error message on failure
+    async function api(path, options = {}) {
+        const headers = new Headers(options.headers || {});
+        const key = localStorage.getItem(KEY_STORAGE);
+        if (key) {
+            headers.set('Authorization', 'Bearer ' + key);
+        }
+        const response = await fetch(path, Object.assign({}, options, { headers }));
+        let body = null;
+        try {
+            body = await response.json();
+        } catch (e) {
+            // Non-JSON bodies are only expected on errors
+        }
+        if (!response.ok && !(body && body.components)) {
+            let message = `${response.status} ${response.statusText}`;
+            if (body && body.error) {
+                message = body.error + (body.details ? ': ' + body.details : '');
+            }
+            throw new Error(message);
+        }
+        return body;
+    }
+
+    function collectionPath(suffix = '') {
+        return '/collections/' + encodeURIComponent(state.collection.name) + suffix;
+    }
+
+    function withQuery(path, params) {
+        const query = new URLSearchParams();
+        for (const [name, value] of Object.entries(params)) {
+            if (value !== undefined && value !== null && value !== '') {
+                query.set(name, value);
+            }
+        }
+        const encoded = query.toString();
+        return encoded ? path + '?' + encoded : path;
+    }
+
+    function showError(err) {
+        const box = $('error');
+        if (!err) {
+            box.hidden = true;
+            return;
+        }
+        box.textContent = err.message || String(err);
+        box.hidden = false;
+    }
+
+    // run executes an action, reporting any failure in the error box
+    async function run(action) {
+        showError(null);
+        try {
+            await action();
+        } catch (err) {
+            showError(err);
+        }
+    }
+
+    function el(tag, attrs = {}, ...children) {
+        const node = document.createElement(tag);
+        for (const [name, value] of Object.entries(attrs)) {
+            if (name === 'class') {
+                node.className = value;
+            } else if (name.startsWith('on')) {
+                node.addEventListener(name.slice(2), value);
+            } else {
+                node.setAttribute(name, value);
+            }
+        }
+        for (const child of children) {
+            if (child !== null && child !== undefined) {
+                node.append(child instanceof Node ? child : String(child));
+            }
+        }
+        return node;
+    }
+
+    function formatValue(value) {
+        if (value === null || value === undefined) {
+            return el('span', { class: 'muted' }, '—');
+        }
+        if (typeof value === 'object') {
+            return el('pre', {}, JSON.stringify(value, null, 2));
+        }
+        return String(value);
+    }
+
+    // table renders an object's fields as a two-column table
+    function table(object) {
+        const rows = Object.entries(object || {}).map(([name, value]) =>
+            el('tr', {}, el('th', {}, name), el('td', {}, formatValue(value))));
+        if (rows.length === 0) {
+            return el('p', { class: 'muted' }, 'Nothing to show');
+        }
+        return el('table', {}, ...rows);
+    }
+
+    function render(id, ...nodes) {
+        $(id).replaceChildren(...nodes);
+    }
+
+    function describeCollection(info) {
+        return Object.assign({}, info, {
+            metric: METRICS[info.metric] || info.metric,
+            index_type: INDEX_TYPES[info.index_type] || info.index_type,
+        });
+    }
+
+    function showView(name) {
+        for (const view of document.querySelectorAll('.view')) {
+            view.hidden = view.id !== 'view-' + name;
+        }
+        for (const link of document.querySelectorAll('nav a')) {
+            link.classList.toggle('active',
+                link.dataset.view === name || (name === 'collection' && state.collection && link.dataset.collection === state.collection.name));
+        }
+    }
+
+    function showTab(name) {
+        for (const button of document.querySelectorAll('.tabs button')) {
+            button.classList.toggle('active', button.dataset.tab === name);
+        }
+        for (const tab of document.querySelectorAll('.tab')) {
+            tab.hidden = tab.id !== 'tab-' + name;
+        }
+    }
+
+    async function loadReadiness() {
+        const badge = $('readiness');
+        try {
+            const readiness = await api('/health/ready');
+            badge.textContent = readiness.status;
+            badge.className = 'badge ' + readiness.status;
+            return readiness;
+        } catch (err) {
+            badge.textContent = 'unreachable';
+            badge.className = 'badge failed';
+            throw err;
+        }
+    }
+
+    async function loadCollections() {
+        const list = await api('/collections');
+        const items = (list.collections || [])
+            .sort((a, b) => a.name.localeCompare(b.name))
+            .map((info) => el('li', {},
+                el('a', {
+                    href: '#',
+                    'data-collection': info.name,
+                    onclick: (event) => {
+                        event.preventDefault();
+                        run(() => openCollection(info.name));
+                    },
+                }, info.name, el('span', { class: 'count' }, info.vector_count))));
+        if (items.length === 0) {
+            items.push(el('li', { class: 'muted' }, 'No collections'));
+        }
+        render('collections', ...items);
+    }
+
+    async function openCollection(name) {
+        const info = await api('/collections/' + encodeURIComponent(name));
+        state.collection = info;
+        state.browse = null;
+        $('collection-title').textContent = info.name;
+        render('browse-vector');
+        render('browse-neighbours');
+        $('browse-pager').hidden = true;
+        render('search-results');
+        render('search-summary');
+        render('upload-result');
+        showView('collection');
+        showTab('overview');
+        await loadOverview();
+    }
+
+    async function loadOverview() {
+        render('collection-info', table(describeCollection(state.collection)));
+
+        // The panels load independently; a failing one does not hide the others
+        const panels = [
+            ['collection-stats', () => api(collectionPath('/stats')), (stats) => table(describeCollection(stats))],
+            ['index-stats', () => api(collectionPath('/index/stats')), table],
+            ['namespaces', () => api(collectionPath('/namespaces')), (list) => namespaceTable(list.namespaces || [])],
+        ];
+        await Promise.all(panels.map(async ([id, load, view]) => {
+            try {
+                render(id, view(await load()));
+            } catch (err) {
+                render(id, el('p', { class: 'muted' }, err.message));
+            }
+        }));
+    }
+
+    function namespaceTable(namespaces) {
+        if (namespaces.length === 0) {
+            return el('p', { class: 'muted' }, 'No vectors stored');
+        }
+        return el('table', {},
+            el('tr', {}, el('th', {}, 'namespace'), el('th', {}, 'vectors')),
+            ...namespaces.map((ns) => el('tr', {},
+                el('td', {}, ns.name || el('span', { class: 'muted' }, 'default')),
+                el('td', {}, ns.vector_count))));
+    }
+
+    function parseVector(text) {
+        const values = text.replace(/[\[\]]/g, '').split(/[\s,]+/).filter((part) => part !== '');
+        const vector = values.map(Number);
+        if (vector.length === 0 || vector.some(Number.isNaN)) {
+            throw new Error('A vector is a list of numbers, such as 0.1, 0.2, 0.3');
+        }
+        return vector;
+    }
+
+    function parseFilter(text) {
+        if (!text.trim()) {
+            return undefined;
+        }
+        try {
+            return JSON.parse(text);
+        } catch (err) {
+            throw new Error('The filter is not valid JSON: ' + err.message);
+        }
+    }
+
+    // resultList renders search results; clicking one opens it in the browser tab
+    function resultList(results, namespace) {
+        if (!results || results.length === 0) {
+            return el('p', { class: 'muted' }, 'No results');
+        }
+        return el('div', {}, ...results.map((result) => {
+            const content = result.content || (result.metadata && result.metadata.chunk_content);
+            return el('div', {
+                class: 'result',
+                onclick: () => run(async () => {
+                    showTab('browse');
+                    $('browse-id').value = result.id;
+                    $('browse-namespace').value = namespace || '';
+                    await browse(result.id, namespace, 0);
+                }),
+            },
+            el('span', { class: 'score' }, result.score.toFixed(4)),
+            el('strong', {}, result.id),
+            content ? el('div', { class: 'content' }, content.length > 300 ? content.slice(0, 300) + '…' : content) : null);
+        }));
+    }
+
+    async function search(event) {
+        event.preventDefault();
+        const mode = document.querySelector('input[name=mode]:checked').value;
+        const query = $('search-query').value.trim();
+        const namespace = $('search-namespace').value.trim();
+        const limit = Number($('search-limit').value) || 10;
+        if (!query) {
+            throw new Error('Enter something to search for');
+        }
+
+        let response;
+        if (mode === 'vector') {
+            response = await api(collectionPath('/search'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    vector: parseVector(query),
+                    limit,
+                    namespace,
+                    filter: parseFilter($('search-filter').value),
+                    include_metadata: true,
+                }),
+            });
+        } else {
+            response = await api(collectionPath('/search/text'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ query, limit, namespace, include_metadata: true, include_content: true }),
+            });
+        }
+
+        const results = response.results || [];
+        render('search-summary', `${results.length} results in ${response.took_ms || 0} ms`);
+        render('search-results', resultList(results, namespace));
+    }
+
+    // browse shows a vector and a page of its nearest neighbours
+    async function browse(id, namespace, offset) {
+        if (!id) {
+            throw new Error('Enter a vector ID');
+        }
+        const vector = await api(withQuery(collectionPath('/vectors/' + encodeURIComponent(id)), { namespace }));
+        state.browse = { id, namespace, vector: vector.vector, offset };
+
+        render('browse-vector', el('div', { class: 'card' },
+            el('h3', {}, vector.id),
+            table({
+                namespace: vector.namespace || 'default',
+                dimensions: (vector.vector || []).length,
+                metadata: vector.metadata,
+                vector: (vector.vector || []).map((v) => Number(v.toFixed(4))).join(', '),
+            })));
+
+        // The vector itself is the nearest match, so skip past it
+        const neighbours = await api(collectionPath('/search'), {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                vector: vector.vector,
+                limit: BROWSE_PAGE_SIZE,
+                offset: offset + 1,
+                namespace,
+                include_metadata: true,
+            }),
+        });
+        const results = neighbours.results || [];
+
+        render('browse-neighbours', el('h3', {}, 'Nearest neighbours'), resultList(results, namespace));
+        $('browse-pager').hidden = false;
+        $('browse-prev').disabled = offset === 0;
+        $('browse-next').disabled = results.length < BROWSE_PAGE_SIZE;
+        $('browse-page').textContent = `${offset + 1}–${offset + results.length}`;
+    }
+
+    async function upload(event) {
+        event.preventDefault();
+        const file = $('upload-file').files[0];
+        if (!file) {
+            throw new Error('Choose a file to upload');
+        }
+
+        const form = new FormData();
+        form.append('file', file);
+        for (const [field, input] of [['chunk_size', 'upload-chunk-size'], ['chunk_overlap', 'upload-overlap'], ['language', 'upload-language']]) {
+            if ($(input).value) {
+                form.append(field, $(input).value);
+            }
+        }
+        const namespace = $('upload-namespace').value.trim();
+
+        render('upload-result', el('p', { class: 'muted' }, 'Processing ' + file.name + '…'));
+        const result = await api(withQuery(collectionPath('/documents'), { namespace }), { method: 'POST', body: form });
+        render('upload-result', el('div', { class: 'card' }, el('h3', {}, 'Uploaded ' + file.name), table(result)));
+        await loadCollections();
+    }
+
+    async function loadSupportedFormats() {
+        try {
+            const formats = await api('/documents/supported');
+            $('upload-formats').textContent = 'Supported formats: ' + (formats.extensions || []).join(', ');
+        } catch (err) {
+            // Formats are informational only
+        }
+    }
+
+    async function openServer() {
+        showView('server');
+        const panels = [
+            ['server-readiness', loadReadiness, (readiness) => el('table', {},
+                ...(readiness.components || []).map((component) => el('tr', {},
+                    el('th', {}, component.name),
+                    el('td', {}, el('span', { class: 'badge ' + component.status }, component.status)),
+                    el('td', {}, component.message || ''))))],
+            ['server-stats', () => api('/stats'), table],
+            ['server-config', () => api('/config'), (config) => el('pre', {}, JSON.stringify(config, null, 2))],
+        ];
+        await Promise.all(panels.map(async ([id, load, view]) => {
+            try {
+                render(id, view(await load()));
+            } catch (err) {
+                render(id, el('p', { class: 'muted' }, err.message));
+            }
+        }));
+    }
+
+    function bind() {
+        $('api-key').value = localStorage.getItem(KEY_STORAGE) || '';
+        $('key-form').addEventListener('submit', (event) => {
+            event.preventDefault();
+            const key = $('api-key').value.trim();
+            if (key) {
+                localStorage.setItem(KEY_STORAGE, key);
+            } else {
+                localStorage.removeItem(KEY_STORAGE);
+            }
+            run(start);
+        });
+
+        $('refresh').addEventListener('click', () => run(loadCollections));
+        for (const link of document.querySelectorAll('nav a[data-view]')) {
+            link.addEventListener('click', (event) => {
+                event.preventDefault();
+                state.collection = null;
+                if (link.dataset.view === 'server') {
+                    run(openServer);
+                } else {
+                    showView(link.dataset.view);
+                }
+            });
+        }
+        for (const button of document.querySelectorAll('.tabs button')) {
+            button.addEventListener('click', () => {
+                showTab(button.dataset.tab);
+                if (button.dataset.tab === 'overview') {
+                    run(loadOverview);
+                }
+            });
+        }
+
+        $('search-form').addEventListener('submit', (event) => run(() => search(event)));
+        $('browse-form').addEventListener('submit', (event) => {
+            event.preventDefault();
+            run(() => browse($('browse-id').value.trim(), $('browse-namespace').value.trim(), 0));
+        });
+        $('browse-prev').addEventListener('click', () => run(() =>
+            browse(state.browse.id, state.browse.namespace, Math.max(0, state.browse.offset - BROWSE_PAGE_SIZE))));
+        $('browse-next').addEventListener('click', () => run(() =>
+            browse(state.browse.id, state.browse.namespace, state.browse.offset + BROWSE_PAGE_SIZE)));
+        $('upload-form').addEventListener('submit', (event) => run(() => upload(event)));
+    }
+
+    async function start() {
+        loadReadiness().catch(() => {});
+        loadSupportedFormats();
+        await loadCollections();
+    }
+
+    bind();
+    run(start);
+})();
@@ -41,7 +41,8 @@ class VittoriaDB:
                  port: int = 8080,
                  host: str = "localhost",
                  data_dir: Optional[str] = None,
-                 extra_args: Optional[List[str]] = None):
+                 extra_args: Optional[List[str]] = None,
+                 priority: Optional[str] = None):
         """Initialize VittoriaDB client.
         
         Args:
@@ -51,6 +52,8 @@ class VittoriaDB:
             host: Host to bind auto-started server to
             data_dir: Data directory for auto-started server
             extra_args: Additional command-line arguments for auto-started server
+            priority: Priority class of every request, "interactive" or "batch"
+                (if None, the server picks one per endpoint)
         """
         self.url = url or f"http://{host}:{port}"
         self.auto_start = auto_start
@@ -61,6 +64,15 @@ class VittoriaDB:
         self.process = None
         self.auto_started = False
         
+        # Reuse kept-alive connections: a new connection per request costs
+        # more than a small search itself
+        self.session = requests.Session()
+        adapter = requests.adapters.HTTPAdapter(pool_connections=4, pool_maxsize=32)
+        self.session.mount("http://", adapter)
+        self.session.mount("https://", adapter)
+        if priority is not None:
+            self.session.headers["X-Priority"] = priority
+        
         if auto_start and url is None:
             self._start_server()
     
@@ -161,7 +173,7 @@ class VittoriaDB:
         url = f"{self.url}{endpoint}"
         
         try:
-            response = requests.request(method, url, **kwargs)
+            response = self.session.request(method, url, **kwargs)
             return response
         except requests.exceptions.RequestException as e:
             raise ConnectionError(f"Failed to connect to VittoriaDB server: {e}")
@@ -176,13 +188,14 @@ class VittoriaDB:
         if response.status_code >= 400:
             error_msg = data.get("error", f"HTTP {response.status_code}")
             details = data.get("details", "")
+            code = data.get("code")
             
             if response.status_code == 404:
-                raise CollectionError(f"{error_msg}: {details}")
+                raise CollectionError(f"{error_msg}: {details}", code)
             elif response.status_code == 409:
-                raise CollectionError(f"{error_msg}: {details}")
+                raise CollectionError(f"{error_msg}: {details}", code)
             else:
-                raise VittoriaDBError(f"{error_msg}: {details}")
+                raise VittoriaDBError(f"{error_msg}: {details}", code)
         
         return data
     
@@ -193,8 +206,16 @@ class VittoriaDB:
                          index_type: Union[IndexType, str] = IndexType.FLAT,
                          config: Optional[Dict[str, Any]] = None,
                          vectorizer_config: Optional[VectorizerConfig] = None,
-                         content_storage: Optional[ContentStorageConfig] = None) -> 'Collection':
-        """Create a new vector collection."""
+                         content_storage: Optional[ContentStorageConfig] = None,
+                         quantization: Optional[str] = None,
+                         indexed_fields: Optional[List[str]] = None,
+                         vectorizer_profile: Optional[str] = None) -> 'Collection':
+        """Create a new vector collection. quantization="int8" keeps the
+        vectors of a flat collection as one byte per component in memory,
+        and quantization="float16" as two. indexed_fields names numeric
+        metadata fields given range indexes, which narrow filtered searches.
+        vectorizer_profile names a vectorizer of the server configuration's
+        embeddings.profiles, in place of vectorizer_config."""
         # Convert to enum values and then to integers (Go server expects integers)
         if isinstance(metric, DistanceMetric):
             metric_int = metric.value
@@ -221,11 +242,19 @@ class VittoriaDB:
         # Add vectorizer configuration if provided
         if vectorizer_config:
             payload["vectorizer_config"] = vectorizer_config.to_dict()
+        if vectorizer_profile:
+            payload["vectorizer_profile"] = vectorizer_profile
         
         # Add content storage configuration if provided
         if content_storage:
             payload["content_storage"] = content_storage.to_dict()
         
+        if quantization:
+            payload["quantization"] = {"type": quantization}
+        
+        if indexed_fields:
+            payload["indexed_fields"] = indexed_fields
+        
         response = self._make_request("POST", "/collections", json=payload)
         self._handle_response(response)
         
@@ -268,13 +297,39 @@ class VittoriaDB:
         
         return DatabaseStats.from_dict(data)
     
-    def config(self) -> Dict[str, Any]:
-        """Get current server configuration (v0.5.0+)."""
-        response = self._make_request("GET", "/config")
+    def capabilities(self) -> Dict[str, Any]:
+        """Get the metrics, index types, filter operators, vectorizers, limits
+        and enabled features the server supports."""
+        response = self._make_request("GET", "/capabilities")
+        return self._handle_response(response)
+
+    def config(self, full: bool = False) -> Dict[str, Any]:
+        """Get current server configuration (v0.5.0+), with secrets redacted.
+
+        Args:
+            full: Include the auth, cluster, object storage and edge sections
+                (requires an admin key)
+        """
+        params = {"view": "full"} if full else None
+        response = self._make_request("GET", "/config", params=params)
+        return self._handle_response(response)
+    
+    def update_config(self, settings: Dict[str, Any]) -> Dict[str, Any]:
+        """Change settings the server applies without a restart (requires an admin key).
+
+        Args:
+            settings: Settings to change, in the layout of the configuration file,
+                e.g. {"logging": {"level": "warn"}}
+
+        Returns:
+            The changed settings under "applied"
+        """
+        response = self._make_request("PUT", "/config", json=settings)
         return self._handle_response(response)
     
     def close(self) -> None:
         """Close connection and cleanup."""
+        self.session.close()
         self._cleanup()
 
 
@@ -353,8 +408,10 @@ class Collection:
                filter: Optional[Dict[str, Any]] = None,
                include_vector: bool = False,
                include_metadata: bool = True,
-               include_content: bool = False) -> List[SearchResult]:
-        """Search for similar vectors."""
+               include_content: bool = False,
+               rescore: bool = False) -> List[SearchResult]:
+        """Search for similar vectors. In a quantized collection, rescore
+        ranks the best candidates by their original vectors."""
         params = {
             "vector": ",".join(map(str, vector)),
             "limit": limit,
@@ -366,6 +423,8 @@ class Collection:
         
         if filter:
             params["filter"] = json.dumps(filter)
+        if rescore:
+            params["rescore"] = "true"
         
         response = self.client._make_request(
             "GET", 
@@ -404,6 +463,37 @@ class Collection:
         # Invalidate cached info
         self._info = None
     
+    def update_metadata(self,
+                        filter: Optional[Dict[str, Any]] = None,
+                        ids: Optional[List[str]] = None,
+                        set: Optional[Dict[str, Any]] = None,
+                        unset: Optional[List[str]] = None) -> int:
+        """Patch the metadata of the vectors matching a filter or listed by ID.
+        
+        Keys in ``set`` are added or overwritten and keys in ``unset`` removed,
+        without re-inserting the vectors. Returns how many vectors matched.
+        """
+        if (filter is None) == (ids is None):
+            raise ValueError("Pass either filter or ids")
+        
+        payload: Dict[str, Any] = {}
+        if filter is not None:
+            payload["filter"] = filter
+        if ids is not None:
+            payload["ids"] = ids
+        if set:
+            payload["set"] = set
+        if unset:
+            payload["unset"] = unset
+        
+        response = self.client._make_request(
+            "PATCH",
+            f"/collections/{self.name}/vectors/metadata",
+            json=payload
+        )
+        data = self.client._handle_response(response)
+        return data.get("matched", 0)
+    
     def count(self) -> int:
         """Get total number of vectors."""
         return self.info.vector_count
@@ -13,6 +13,8 @@ class DistanceMetric(Enum):
     EUCLIDEAN = 1
     DOT_PRODUCT = 2
     MANHATTAN = 3
+    HAMMING = 4
+    JACCARD = 5
     
     @classmethod
     def from_string(cls, value: str) -> 'DistanceMetric':
@@ -21,7 +23,9 @@ class DistanceMetric(Enum):
             "cosine": cls.COSINE,
             "euclidean": cls.EUCLIDEAN,
             "dot_product": cls.DOT_PRODUCT,
-            "manhattan": cls.MANHATTAN
+            "manhattan": cls.MANHATTAN,
+            "hamming": cls.HAMMING,
+            "jaccard": cls.JACCARD
         }
         return string_map.get(value.lower(), cls.COSINE)
     
@@ -31,7 +35,9 @@ class DistanceMetric(Enum):
             self.COSINE: "cosine",
             self.EUCLIDEAN: "euclidean", 
             self.DOT_PRODUCT: "dot_product",
-            self.MANHATTAN: "manhattan"
+            self.MANHATTAN: "manhattan",
+            self.HAMMING: "hamming",
+            self.JACCARD: "jaccard"
         }
         return string_map.get(self, "cosine")
 
@@ -281,8 +287,15 @@ class DatabaseStats:
 
 
 class VittoriaDBError(Exception):
-    """Base exception for VittoriaDB errors."""
-    pass
+    """Base exception for VittoriaDB errors.
+
+    ``code`` is the server's machine-readable error code (e.g. ``not_found``),
+    or None for errors raised by the client itself.
+    """
+
+    def __init__(self, message: str = "", code: Optional[str] = None):
+        super().__init__(message)
+        self.code = code
 
 
 class ConnectionError(VittoriaDBError):